@@ -54,6 +54,7 @@ type Cache interface {
 type SignerResolver interface {
 	GetTLSSecretContent(secretName string) (*TLSSecret, error)
 	SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error
+	SetTLSSecretFailure(secretName string, domains []string, err error)
 }
 
 // TLSSecret ...
@@ -159,6 +160,9 @@ func (s *signer) verify(secretName, preferredChain string, domains []string) (ve
 			verifyErr = err
 		}
 		collector(strdomains, verifyErr == nil)
+		if verifyErr != nil {
+			s.cache.SetTLSSecretFailure(secretName, domains, verifyErr)
+		}
 	} else {
 		s.logger.InfoV(2, "acme: skipping sign, certificate is updated: secret=%s domain(s)=%s", secretName, strdomains)
 	}
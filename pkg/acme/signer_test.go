@@ -21,6 +21,7 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -105,6 +106,20 @@ INFO acme: new certificate issued: id=1 secret=s1 domain(s)=other.s3.dev.local p
 	}
 }
 
+func TestNotifyVerifyFailure(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	signer := c.newSigner()
+	signer.client = &clientMock{err: fmt.Errorf("rate limited")}
+	signer.account.Endpoint = "https://acme-v2.local"
+	err := signer.Notify("ns1/s1,,d1.local,d2.local")
+	require.EqualError(t, err, "rate limited")
+	require.Equal(t, []string{"ns1/s1:d1.local,d2.local:rate limited"}, c.cache.failures)
+	c.logger.CompareLogging(`
+INFO acme: authorizing: id=1 secret=ns1/s1 domain(s)=d1.local,d2.local endpoint=https://acme-v2.local reason='certificate does not exist (secret not found: ns1/s1)'
+WARN acme: error signing new certificate: id=1 secret=ns1/s1 domain(s)=d1.local,d2.local error=rate limited`)
+}
+
 func setup(t *testing.T) *config {
 	return &config{
 		t: t,
@@ -133,14 +148,20 @@ func (c *config) newSigner() *signer {
 	return signer
 }
 
-type clientMock struct{}
+type clientMock struct {
+	err error
+}
 
 func (c *clientMock) Sign(domains []string, preferredChain string) (crt, key []byte, err error) {
+	if c.err != nil {
+		return nil, nil, c.err
+	}
 	return []byte("fake-crt"), []byte("fake-key"), nil
 }
 
 type cache struct {
 	tlsSecret map[string]*TLSSecret
+	failures  []string
 }
 
 func (c *cache) GetKey() (crypto.Signer, error) {
@@ -166,3 +187,7 @@ func (c *cache) GetTLSSecretContent(secretName string) (*TLSSecret, error) {
 func (c *cache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error {
 	return nil
 }
+
+func (c *cache) SetTLSSecretFailure(secretName string, domains []string, err error) {
+	c.failures = append(c.failures, fmt.Sprintf("%s:%s:%v", secretName, strings.Join(domains, ","), err))
+}
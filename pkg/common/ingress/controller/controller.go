@@ -101,6 +101,8 @@ type Configuration struct {
 	VerifyHostname         bool
 	DefaultHealthzURL      string
 	StatsCollectProcPeriod time.Duration
+	StatsCollectBackPeriod time.Duration
+	StatsScalingSignalsMax int
 	PublishService         string
 	TrackOldInstances      bool
 	Backend                ingress.Controller
@@ -111,6 +113,7 @@ type Configuration struct {
 	UpdateStatusOnShutdown bool
 
 	BackendShards           int
+	PartitionBackends       bool
 	SortEndpointsBy         string
 	EnableEndpointSlicesAPI bool
 }
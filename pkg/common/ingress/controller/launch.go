@@ -189,6 +189,18 @@ the time to receive all/most of the changes of a batch update.`)
 haproxy updates Idle_pct every 500ms, which makes that the best configuration
 value. Change to 0 (zero) to disable this metric.`)
 
+		statsCollectBackPeriod = flags.Duration("stats-collect-backend-period", time.Minute,
+			`Defines the interval between two consecutive readings of haproxy's backend
+statistics, used by the backend retry metrics and the retry budget. Change to
+0 (zero) to disable backend statistics.`)
+
+		statsScalingSignalsMax = flags.Int("stats-scaling-signals-max", 0,
+			`Exports the current sessions, queue and average times of the backends, labeled
+by namespace and service name, so they can be used as scaling signals. The value
+defines the maximum number of services that are exported. Needs backend
+statistics, see --stats-collect-backend-period. Defaults to 0 (zero), which
+disables scaling signals.`)
+
 		profiling = flags.Bool("profiling", true,
 			`Enable profiling via web interface host:port/debug/pprof/`)
 
@@ -244,6 +256,11 @@ IP/hostname when the controller is being stopped.`)
 		backendShards = flags.Int("backend-shards", 0,
 			`Defines how much files should be used to configure the haproxy backends`)
 
+		partitionBackends = flags.Bool("partition-backends", false,
+			`Groups the backends of the same namespace in the same backend file, and
+isolates a file rejected by haproxy, so the other ones continue to be updated.
+Needs --backend-shards greater than zero.`)
+
 		sortBackends = flags.Bool("sort-backends", false,
 			`Defines if backend's endpoints should be sorted by name. This option has less
 precedence than --sort-endpoints-by if both are declared.`)
@@ -570,6 +587,8 @@ k8s >=1.21+`)
 		VerifyHostname:           *verifyHostname,
 		DefaultHealthzURL:        *defHealthzURL,
 		StatsCollectProcPeriod:   *statsCollectProcPeriod,
+		StatsCollectBackPeriod:   *statsCollectBackPeriod,
+		StatsScalingSignalsMax:   *statsScalingSignalsMax,
 		PublishService:           *publishSvc,
 		Backend:                  backend,
 		ForceNamespaceIsolation:  *forceIsolation,
@@ -581,6 +600,7 @@ k8s >=1.21+`)
 		TrackOldInstances:        *trackOldInstances,
 		UpdateStatusOnShutdown:   *updateStatusOnShutdown,
 		BackendShards:            *backendShards,
+		PartitionBackends:        *partitionBackends,
 		SortEndpointsBy:          sortEndpoints,
 		UseNodeInternalIP:        *useNodeInternalIP,
 		EnableEndpointSlicesAPI:  *enableEndpointSlicesAPI,
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
@@ -52,6 +53,8 @@ import (
 	gatewayv1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 	gwapiversioned "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
 
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/version"
 )
@@ -263,12 +266,12 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 
 	// we could `|| hasGateway[version...]` instead of `|| opt.WatchGateway` here,
 	// but we're choosing a consistent startup behavior despite of the cluster configuration.
-	election := opt.UpdateStatus || opt.AcmeServer || opt.WatchGateway
+	election := opt.UpdateStatus || opt.AcmeServer || opt.WatchGateway || opt.ReportEpWeightsPeriod > 0
 	if election && podNamespace == "" {
-		return nil, fmt.Errorf("POD_NAMESPACE envvar should be configured when --update-status=true, --acme-server=true, or --watch-gateway=true")
+		return nil, fmt.Errorf("POD_NAMESPACE envvar should be configured when --update-status=true, --acme-server=true, --watch-gateway=true, or --report-endpoint-weights-period is configured")
 	}
 	if election && opt.IngressClass == "" {
-		return nil, fmt.Errorf("--ingress-class should not be empty when --update-status=true, --acme-server=true, or --watch-gateway=true")
+		return nil, fmt.Errorf("--ingress-class should not be empty when --update-status=true, --acme-server=true, --watch-gateway=true, or --report-endpoint-weights-period is configured")
 	}
 	var electionID string
 	if election {
@@ -293,6 +296,45 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 		acmeTokenConfigMapNamespaceName = podNamespace + "/" + acmeTokenConfigMapNamespaceName
 	}
 
+	hostOwnershipConfigMap := opt.HostOwnershipConfigMap
+	if hostOwnershipConfigMap != "" && !strings.Contains(hostOwnershipConfigMap, "/") {
+		hostOwnershipConfigMap = podNamespace + "/" + hostOwnershipConfigMap
+	}
+
+	pathTombstonesConfigMap := opt.PathTombstonesConfigMap
+	if pathTombstonesConfigMap != "" && !strings.Contains(pathTombstonesConfigMap, "/") {
+		pathTombstonesConfigMap = podNamespace + "/" + pathTombstonesConfigMap
+	}
+
+	if !opt.AnnUsage && (opt.AnnUsageNamespace || opt.AnnUsageHandler) {
+		return nil, fmt.Errorf("--annotation-usage should be enabled when --annotation-usage-namespace or --annotation-usage-handler is enabled")
+	}
+	if opt.InternalAddr == "" && (opt.InternalAllowCIDR != "" || opt.InternalAuthSecret != "" || opt.InternalHealthz) {
+		return nil, fmt.Errorf("--internal-addr should be configured when --internal-allow-cidr, --internal-auth-secret or --internal-healthz is configured")
+	}
+	var internalAllowCIDR []*net.IPNet
+	for _, cidr := range utils.Split(opt.InternalAllowCIDR, ",") {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --internal-allow-cidr: %w", err)
+		}
+		internalAllowCIDR = append(internalAllowCIDR, ipnet)
+	}
+	internalAuthSecret := opt.InternalAuthSecret
+	if internalAuthSecret != "" && !strings.Contains(internalAuthSecret, "/") {
+		if podNamespace == "" {
+			return nil, fmt.Errorf("POD_NAMESPACE envvar should be configured when --internal-auth-secret does not have a namespace")
+		}
+		internalAuthSecret = podNamespace + "/" + internalAuthSecret
+	}
+
 	masterWorkerCfg := opt.MasterWorker
 	if !masterWorkerCfg && opt.MasterSocket != "" {
 		// TODO Change to FATAL when default masterWorker changes to true
@@ -319,10 +361,16 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 		if err != nil {
 			return nil, fmt.Errorf("invalid format for global ConfigMap '%s': %w", opt.ConfigMap, err)
 		}
-		_, err = client.CoreV1().ConfigMaps(ns).Get(ctx, name, metav1.GetOptions{})
+		cm, err := client.CoreV1().ConfigMaps(ns).Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("error reading global ConfigMap '%s': %w", opt.ConfigMap, err)
 		}
+		if _, err := ingutils.ParseTrafficClasses(cm.Data[ingtypes.GlobalTrafficClasses]); err != nil {
+			return nil, fmt.Errorf("invalid %s on global ConfigMap '%s': %w", ingtypes.GlobalTrafficClasses, opt.ConfigMap, err)
+		}
+		if _, err := ingutils.ParseSPOEAgents(cm.Data[ingtypes.GlobalSPOEAgents]); err != nil {
+			return nil, fmt.Errorf("invalid %s on global ConfigMap '%s': %w", ingtypes.GlobalSPOEAgents, opt.ConfigMap, err)
+		}
 		configLog.Info("watching for global config options - --configmap was defined", "configmap", opt.ConfigMap)
 	}
 
@@ -448,6 +496,43 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 	}
 
 	disableKeywords := utils.Split(opt.DisableConfigKeywords, ",")
+	annLimitPolicy := strings.ToLower(opt.AnnLimitPolicy)
+	if annLimitPolicy != "reject" && annLimitPolicy != "truncate" {
+		return nil, fmt.Errorf("unsupported --annotation-limit-policy option: %s", opt.AnnLimitPolicy)
+	}
+	var modelLimitClassPriority []string
+	switch strings.ToLower(opt.ModelLimitPolicy) {
+	case "oldest":
+	case "class-priority":
+		modelLimitClassPriority = utils.Split(opt.ModelLimitClassPriority, ",")
+		if len(modelLimitClassPriority) == 0 {
+			return nil, fmt.Errorf("--model-limit-class-priority should be configured when --model-limit-policy is class-priority")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported --model-limit-policy option: %s", opt.ModelLimitPolicy)
+	}
+	if opt.StatusWithdrawDelay < 0 || opt.StatusRestoreDelay < 0 {
+		return nil, fmt.Errorf("--status-withdraw-delay and --status-restore-delay should not be negative")
+	}
+	var healthPushKey []byte
+	if opt.HealthPushURL != "" {
+		u, err := url.Parse(opt.HealthPushURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid --health-push-url, an absolute http or https URL is expected: %s", opt.HealthPushURL)
+		}
+		if opt.HealthPushKeyFile == "" {
+			return nil, fmt.Errorf("--health-push-key-file should be configured when --health-push-url is configured")
+		}
+		key, err := os.ReadFile(opt.HealthPushKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading --health-push-key-file: %w", err)
+		}
+		healthPushKey = []byte(strings.TrimSpace(string(key)))
+		if len(healthPushKey) == 0 {
+			return nil, fmt.Errorf("--health-push-key-file should not be empty")
+		}
+		configLog.Info("pushing unavailable hosts", "url", u.Redacted())
+	}
 	var healthz string
 	if opt.HealthzPort > 0 {
 		healthz = fmt.Sprintf(":%d", opt.HealthzPort)
@@ -464,8 +549,17 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 		AcmeTokenConfigMapName:   acmeTokenConfigMapNamespaceName,
 		AcmeTrackTLSAnn:          opt.AcmeTrackTLSAnn,
 		AllowCrossNamespace:      opt.AllowCrossNamespace,
+		AnnLimitTruncate:         annLimitPolicy == "truncate",
+		AnnMaxCIDRs:              opt.AnnMaxCIDRs,
+		AnnMaxHeaders:            opt.AnnMaxHeaders,
+		AnnMaxRewritePaths:       opt.AnnMaxRewritePaths,
+		AnnMaxValueLength:        opt.AnnMaxValueLength,
 		AnnPrefix:                annPrefixList,
+		AnnUsage:                 opt.AnnUsage,
+		AnnUsageHandler:          opt.AnnUsageHandler,
+		AnnUsageNamespace:        opt.AnnUsageNamespace,
 		BackendShards:            opt.BackendShards,
+		PartitionBackends:        opt.PartitionBackends,
 		BucketsResponseTime:      opt.BucketsResponseTime,
 		ConfigMapName:            opt.ConfigMap,
 		ControllerName:           controllerName,
@@ -488,15 +582,34 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 		HasGatewayB1:             hasGatewayB1,
 		HasGatewayV1:             hasGatewayV1,
 		HasTCPRouteA2:            hasTCPRouteA2,
+		HealthPushKey:            healthPushKey,
+		HealthPushURL:            opt.HealthPushURL,
 		HealthzAddr:              healthz,
 		HealthzURL:               opt.HealthzURL,
+		HostOwnershipConfigMap:   hostOwnershipConfigMap,
+		HostOwnershipWildcard:    opt.HostOwnershipWildcard,
 		IngressClass:             opt.IngressClass,
 		IngressClassPrecedence:   opt.IngressClassPrecedence,
+		InternalAddr:             opt.InternalAddr,
+		InternalAllowCIDR:        internalAllowCIDR,
+		InternalAuthSecret:       internalAuthSecret,
+		InternalHealthz:          opt.InternalHealthz,
 		KubeConfig:               kubeConfig,
 		LocalFSPrefix:            opt.LocalFSPrefix,
 		MasterSocket:             opt.MasterSocket,
 		MasterWorker:             masterWorkerCfg,
 		MaxOldConfigFiles:        opt.MaxOldConfigFiles,
+		MetricsHandler:           opt.MetricsHandler,
+		MigrationReport:          opt.MigrationReport,
+		MigrationReportHandler:   opt.MigrationReportHandler,
+		ModelAPI:                 opt.ModelAPI,
+		ModelLimitClassPriority:  modelLimitClassPriority,
+		ModelMaxBackends:         opt.ModelMaxBackends,
+		ModelMaxConfigSize:       opt.ModelMaxConfigSize,
+		ModelMaxEndpoints:        opt.ModelMaxEndpoints,
+		ModelMaxHosts:            opt.ModelMaxHosts,
+		ModelMaxIngresses:        opt.ModelMaxIngresses,
+		PathTombstonesConfigMap:  pathTombstonesConfigMap,
 		PodName:                  podName,
 		PodNamespace:             podNamespace,
 		Profiling:                opt.Profiling,
@@ -507,12 +620,19 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 		ReadyzURL:                opt.ReadyzURL,
 		ReloadInterval:           opt.ReloadInterval,
 		ReloadStrategy:           opt.ReloadStrategy,
+		ReportEpWeightsPeriod:    opt.ReportEpWeightsPeriod,
 		ResyncPeriod:             &opt.ResyncPeriod,
 		RootContext:              rootcontext,
 		Scheme:                   scheme,
 		ShutdownTimeout:          &opt.ShutdownTimeout,
+		SimulateHandler:          opt.SimulateHandler,
 		SortEndpointsBy:          sortEndpoints,
 		StatsCollectProcPeriod:   opt.StatsCollectProcPeriod,
+		StatsCollectBackPeriod:   opt.StatsCollectBackPeriod,
+		StatsScalingSignalsMax:   opt.StatsScalingSignalsMax,
+		StatusRestoreDelay:       opt.StatusRestoreDelay,
+		StatusWithdrawDelay:      opt.StatusWithdrawDelay,
+		StatusWithdrawUnhealthy:  opt.UpdateStatus && opt.StatusWithdrawUnhealthy,
 		StopHandler:              opt.StopHandler,
 		TCPConfigMapName:         opt.TCPConfigMapName,
 		TrackOldInstances:        opt.TrackOldInstances,
@@ -523,6 +643,7 @@ func CreateWithConfig(ctx context.Context, restConfig *rest.Config, opt *Options
 		VerifyHostname:           opt.VerifyHostname,
 		VersionInfo:              versionInfo,
 		WaitBeforeUpdate:         opt.WaitBeforeUpdate,
+		InitialSyncDelay:         opt.InitialSyncDelay,
 		WatchIngressWithoutClass: opt.WatchIngressWithoutClass,
 		WatchNamespace:           opt.WatchNamespace,
 	}, nil
@@ -647,8 +768,17 @@ type Config struct {
 	AcmeTokenConfigMapName   string
 	AcmeTrackTLSAnn          bool
 	AllowCrossNamespace      bool
+	AnnLimitTruncate         bool
+	AnnMaxCIDRs              int
+	AnnMaxHeaders            int
+	AnnMaxRewritePaths       int
+	AnnMaxValueLength        int
 	AnnPrefix                []string
+	AnnUsage                 bool
+	AnnUsageHandler          bool
+	AnnUsageNamespace        bool
 	BackendShards            int
+	PartitionBackends        bool
 	BucketsResponseTime      []float64
 	ConfigMapName            string
 	ControllerName           string
@@ -671,15 +801,34 @@ type Config struct {
 	HasGatewayB1             bool
 	HasGatewayV1             bool
 	HasTCPRouteA2            bool
+	HealthPushKey            []byte
+	HealthPushURL            string
 	HealthzAddr              string
 	HealthzURL               string
+	HostOwnershipConfigMap   string
+	HostOwnershipWildcard    bool
 	IngressClass             string
 	IngressClassPrecedence   bool
+	InternalAddr             string
+	InternalAllowCIDR        []*net.IPNet
+	InternalAuthSecret       string
+	InternalHealthz          bool
 	KubeConfig               *rest.Config
 	LocalFSPrefix            string
 	MasterSocket             string
 	MasterWorker             bool
 	MaxOldConfigFiles        int
+	MetricsHandler           bool
+	MigrationReport          bool
+	MigrationReportHandler   bool
+	ModelAPI                 bool
+	ModelLimitClassPriority  []string
+	ModelMaxBackends         int
+	ModelMaxConfigSize       int
+	ModelMaxEndpoints        int
+	ModelMaxHosts            int
+	ModelMaxIngresses        int
+	PathTombstonesConfigMap  string
 	PodName                  string
 	PodNamespace             string
 	Profiling                bool
@@ -690,12 +839,19 @@ type Config struct {
 	ReadyzURL                string
 	ReloadInterval           time.Duration
 	ReloadStrategy           string
+	ReportEpWeightsPeriod    time.Duration
 	ResyncPeriod             *time.Duration
 	RootContext              context.Context
 	Scheme                   *runtime.Scheme
 	ShutdownTimeout          *time.Duration
+	SimulateHandler          bool
 	SortEndpointsBy          string
 	StatsCollectProcPeriod   time.Duration
+	StatsCollectBackPeriod   time.Duration
+	StatsScalingSignalsMax   int
+	StatusRestoreDelay       time.Duration
+	StatusWithdrawDelay      time.Duration
+	StatusWithdrawUnhealthy  bool
 	StopHandler              bool
 	TCPConfigMapName         string
 	TrackOldInstances        bool
@@ -706,6 +862,7 @@ type Config struct {
 	VerifyHostname           bool
 	VersionInfo              version.Info
 	WaitBeforeUpdate         time.Duration
+	InitialSyncDelay         time.Duration
 	WatchIngressWithoutClass bool
 	WatchNamespace           string
 }
@@ -22,20 +22,31 @@ func NewOptions() *Options {
 		AcmeTokenConfigMapName:  "acme-validation-tokens",
 		BucketsResponseTime:     []float64{.0005, .001, .002, .005, .01},
 		AnnPrefix:               "haproxy-ingress.github.io,ingress.kubernetes.io",
+		AnnMaxValueLength:       65536,
+		AnnMaxCIDRs:             4096,
+		AnnMaxHeaders:           256,
+		AnnMaxRewritePaths:      1024,
+		AnnLimitPolicy:          "reject",
+		ModelLimitPolicy:        "oldest",
+		HostOwnershipWildcard:   true,
 		RateLimitUpdate:         0.5,
 		WaitBeforeUpdate:        200 * time.Millisecond,
 		ResyncPeriod:            10 * time.Hour,
 		WatchNamespace:          corev1.NamespaceAll,
 		StatsCollectProcPeriod:  500 * time.Millisecond,
+		StatsCollectBackPeriod:  time.Minute,
 		HealthzAddr:             ":10254",
 		HealthzURL:              "/healthz",
 		ReadyzURL:               "/readyz",
 		Profiling:               true,
+		MetricsHandler:          true,
 		VerifyHostname:          true,
 		UpdateStatus:            true,
 		ElectionID:              "class-%s.haproxy-ingress.github.io",
 		ShutdownTimeout:         25 * time.Second,
 		UpdateStatusOnShutdown:  true,
+		StatusWithdrawDelay:     time.Minute,
+		StatusRestoreDelay:      30 * time.Second,
 		LogLevel:                2,
 	}
 }
@@ -69,17 +80,52 @@ type Options struct {
 	PublishAddress           string
 	TCPConfigMapName         string
 	AnnPrefix                string
+	AnnMaxValueLength        int
+	AnnMaxCIDRs              int
+	AnnMaxHeaders            int
+	AnnMaxRewritePaths       int
+	AnnLimitPolicy           string
+	ModelMaxIngresses        int
+	ModelMaxHosts            int
+	ModelMaxBackends         int
+	ModelMaxEndpoints        int
+	ModelMaxConfigSize       int
+	ModelLimitPolicy         string
+	ModelLimitClassPriority  string
+	HostOwnershipConfigMap   string
+	HostOwnershipWildcard    bool
+	PathTombstonesConfigMap  string
 	RateLimitUpdate          float64
 	ReloadInterval           time.Duration
 	WaitBeforeUpdate         time.Duration
+	InitialSyncDelay         time.Duration
 	ResyncPeriod             time.Duration
 	WatchNamespace           string
 	StatsCollectProcPeriod   time.Duration
+	StatsCollectBackPeriod   time.Duration
+	StatsScalingSignalsMax   int
+	ReportEpWeightsPeriod    time.Duration
+	HealthPushURL            string
+	HealthPushKeyFile        string
 	HealthzAddr              string
 	HealthzURL               string
 	ReadyzURL                string
 	Profiling                bool
 	StopHandler              bool
+	SimulateHandler          bool
+	MetricsHandler           bool
+	AnnUsage                 bool
+	AnnUsageNamespace        bool
+	AnnUsageHandler          bool
+	MigrationReport          bool
+	MigrationReportHandler   bool
+	ModelAPI                 bool
+	InternalAddr             string
+	InternalAllowCIDR        string
+	InternalAuthSecret       string
+	InternalHealthz          bool
+	SnapshotDir              string
+	SnapshotOutput           string
 	DefSSLCertificate        string
 	VerifyHostname           bool
 	UpdateStatus             bool
@@ -90,7 +136,11 @@ type Options struct {
 	DisableExternalName      bool
 	DisableConfigKeywords    string
 	UpdateStatusOnShutdown   bool
+	StatusWithdrawUnhealthy  bool
+	StatusWithdrawDelay      time.Duration
+	StatusRestoreDelay       time.Duration
 	BackendShards            int
+	PartitionBackends        bool
 	SortBackends             bool
 	SortEndpointsBy          string
 	TrackOldInstances        bool
@@ -293,6 +343,13 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 		"the time to receive all/most of the changes of a batch update.",
 	)
 
+	fs.DurationVar(&o.InitialSyncDelay, "initial-sync-delay", o.InitialSyncDelay, ""+
+		"Amount of time to wait, after all the informers have synced, before the first "+
+		"reconciliation and haproxy update. The previous configuration, if any, continues "+
+		"to be served in the meantime. Default is 0, which means to update haproxy as "+
+		"soon as the informers are synced.",
+	)
+
 	fs.DurationVar(&o.ResyncPeriod, "sync-period", o.ResyncPeriod, ""+
 		"Configures the default resync period of Kubernetes' informer factory.",
 	)
@@ -307,6 +364,41 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 		"value. Change to 0 (zero) to disable this metric.",
 	)
 
+	fs.DurationVar(&o.StatsCollectBackPeriod, "stats-collect-backend-period", o.StatsCollectBackPeriod, ""+
+		"Defines the interval between two consecutive readings of haproxy's backend "+
+		"statistics, used by the backend retry metrics and the retry budget. Change to "+
+		"0 (zero) to disable backend statistics.",
+	)
+
+	fs.IntVar(&o.StatsScalingSignalsMax, "stats-scaling-signals-max", o.StatsScalingSignalsMax, ""+
+		"Exports the current sessions, queue and average times of the backends, labeled "+
+		"by namespace and service name, so they can be used as scaling signals. The value "+
+		"defines the maximum number of services that are exported. Needs backend "+
+		"statistics, see --stats-collect-backend-period. Defaults to 0 (zero), which "+
+		"disables scaling signals.",
+	)
+
+	fs.DurationVar(&o.ReportEpWeightsPeriod, "report-endpoint-weights-period", o.ReportEpWeightsPeriod, ""+
+		"Enables reporting the effective weight of every pod used as a backend endpoint, "+
+		"written in the haproxy-ingress.github.io/endpoint-weights pod annotation. The "+
+		"value defines the minimum interval between two consecutive reports. Only the "+
+		"leader reports, so this option also enables leader election. Default value is "+
+		"0 (zero), which disables the report.",
+	)
+
+	fs.StringVar(&o.HealthPushURL, "health-push-url", o.HealthPushURL, ""+
+		"Enables pushing a summary of the hostnames without any available backend "+
+		"server, sent as a signed JSON via POST to the configured http or https URL "+
+		"after every update and whenever the backend statistics change the summary. "+
+		"Default value is empty, which disables the push.",
+	)
+
+	fs.StringVar(&o.HealthPushKeyFile, "health-push-key-file", o.HealthPushKeyFile, ""+
+		"Path to a file with the key used to sign the summary pushed to --health-push-url. "+
+		"The HMAC-SHA256 signature of the payload is sent in the X-HAProxy-Ingress-Signature "+
+		"header. Mandatory if --health-push-url is configured.",
+	)
+
 	fs.StringVar(&o.HealthzAddr, "healthz-addr", o.HealthzAddr, ""+
 		"The address the healthz service should bind to. Configure with an empty string "+
 		"to disable it.",
@@ -329,6 +421,87 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 		"endpoint.",
 	)
 
+	fs.BoolVar(&o.SimulateHandler, "simulate-handler", o.SimulateHandler, ""+
+		"Allows to simulate the outcome of an ingress resource, without applying it, "+
+		"via a POST request to host:healthzport/debug/simulate endpoint.",
+	)
+
+	fs.BoolVar(&o.MetricsHandler, "metrics-handler", o.MetricsHandler, ""+
+		"Enable the Prometheus metrics exporter via host:healthzport/metrics endpoint.",
+	)
+
+	fs.BoolVar(&o.AnnUsage, "annotation-usage", o.AnnUsage, ""+
+		"Counts the ingress resources declaring each configuration key, exported as "+
+		"the haproxyingress_annotation_usage metric.",
+	)
+
+	fs.BoolVar(&o.AnnUsageNamespace, "annotation-usage-namespace", o.AnnUsageNamespace, ""+
+		"Counts the configuration keys per namespace of the ingress resources. Note that "+
+		"this might create a large number of metric series. Needs --annotation-usage.",
+	)
+
+	fs.BoolVar(&o.AnnUsageHandler, "annotation-usage-handler", o.AnnUsageHandler, ""+
+		"Allows to read the configuration keys usage in JSON format via "+
+		"host:healthzport/debug/annotations endpoint. Needs --annotation-usage.",
+	)
+
+	fs.BoolVar(&o.MigrationReportHandler, "migration-report-handler", o.MigrationReportHandler, ""+
+		"Allows to read, in JSON format via host:healthzport/debug/migration endpoint, "+
+		"the annotation prefixes used to declare the configuration keys of each ingress "+
+		"resource, and the keys in use whose semantics differ from other controllers.",
+	)
+
+	fs.BoolVar(&o.ModelAPI, "model-api", o.ModelAPI, ""+
+		"Allows to read the hosts, backends and endpoints applied by the last sync in "+
+		"JSON format via host:healthzport/api/v1/model endpoint, and to watch their "+
+		"changes via host:healthzport/api/v1/model/watch endpoint.",
+	)
+
+	fs.BoolVar(&o.MigrationReport, "migration-report", o.MigrationReport, ""+
+		"Prints the migration report of all the ingress resources of the cluster in JSON "+
+		"format, and exits. Ingress resources of all the ingress classes are reported, "+
+		"and haproxy is not configured or started. See --migration-report-handler.",
+	)
+
+	fs.StringVar(&o.InternalAddr, "internal-addr", o.InternalAddr, ""+
+		"The address of a dedicated server for the controller-internal endpoints, e.g. "+
+		"127.0.0.1:10255. If configured, metrics, build, acme check, profiling, simulate "+
+		"and stop endpoints are moved from the healthz server to this one, and requests "+
+		"to their old location are answered with 404.",
+	)
+
+	fs.StringVar(&o.InternalAllowCIDR, "internal-allow-cidr", o.InternalAllowCIDR, ""+
+		"Comma-separated list of CIDRs allowed to connect to the internal server. All "+
+		"clients are allowed if not configured. Needs --internal-addr.",
+	)
+
+	fs.StringVar(&o.InternalAuthSecret, "internal-auth-secret", o.InternalAuthSecret, ""+
+		"Name of a secret, in the format namespace/name, with users and passwords of the "+
+		"basic authentication of the internal server. The secret has the same format of "+
+		"the auth-secret configuration key. Passwords should be in plain text or bcrypt "+
+		"hashes. All the requests are denied if the secret cannot be read or has no valid "+
+		"user. Needs --internal-addr.",
+	)
+
+	fs.BoolVar(&o.InternalHealthz, "internal-healthz", o.InternalHealthz, ""+
+		"Move also the health and readiness checks to the internal server. Note that "+
+		"kubelet probes should be able to reach the internal server if enabled. Needs "+
+		"--internal-addr.",
+	)
+
+	fs.StringVar(&o.SnapshotDir, "snapshot-dir", o.SnapshotDir, ""+
+		"Directory with yaml or json dumps of cluster resources. If configured, the "+
+		"controller does not connect to the API server: it converts the resources of "+
+		"the snapshot, renders the haproxy configuration, writes it along with the "+
+		"warnings and errors found, and exits.",
+	)
+
+	fs.StringVar(&o.SnapshotOutput, "snapshot-output", o.SnapshotOutput, ""+
+		"Directory where the configuration files and the report of --snapshot-dir "+
+		"are written. The haproxy.cfg file and the report are written to the standard "+
+		"output if not configured.",
+	)
+
 	fs.StringVar(&o.DefSSLCertificate, "default-ssl-certificate", o.DefSSLCertificate, ""+
 		"Name of the secret that contains a SSL certificate to be used as "+
 		"default for a HTTPS catch-all server.",
@@ -380,15 +553,123 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 		"configuration snippets using annotations.",
 	)
 
+	fs.IntVar(&o.AnnMaxValueLength, "annotation-max-value-length", o.AnnMaxValueLength, ""+
+		"Defines the maximum length, in bytes, of a configuration value read from an "+
+		"annotation. Use 0 (zero) to disable this limit.",
+	)
+
+	fs.IntVar(&o.AnnMaxCIDRs, "annotation-max-cidrs", o.AnnMaxCIDRs, ""+
+		"Defines the maximum number of IPs or CIDRs of an allow list, deny list or rate "+
+		"limit whitelist annotation. Use 0 (zero) to disable this limit.",
+	)
+
+	fs.IntVar(&o.AnnMaxHeaders, "annotation-max-headers", o.AnnMaxHeaders, ""+
+		"Defines the maximum number of headers of a headers or oauth-headers "+
+		"annotation. Use 0 (zero) to disable this limit.",
+	)
+
+	fs.IntVar(&o.AnnMaxRewritePaths, "annotation-max-rewrite-paths", o.AnnMaxRewritePaths, ""+
+		"Defines the maximum number of paths of a backend that can be configured with "+
+		"rewrite-target. Use 0 (zero) to disable this limit.",
+	)
+
+	fs.StringVar(&o.AnnLimitPolicy, "annotation-limit-policy", o.AnnLimitPolicy, ""+
+		"Defines what should be done with a configuration that exceeds one of the "+
+		"annotation limits: 'reject' ignores the whole configuration, 'truncate' uses "+
+		"it up to the limit. Values over --annotation-max-value-length, deny lists and "+
+		"access lists with exceptions are always rejected.",
+	)
+
+	fs.IntVar(&o.ModelMaxIngresses, "model-max-ingresses", o.ModelMaxIngresses, ""+
+		"Defines the maximum number of ingress resources added to the configuration. "+
+		"Use 0 (zero), the default value, to disable this limit.",
+	)
+
+	fs.IntVar(&o.ModelMaxHosts, "model-max-hosts", o.ModelMaxHosts, ""+
+		"Defines the maximum number of hostnames added to the configuration. "+
+		"Use 0 (zero), the default value, to disable this limit.",
+	)
+
+	fs.IntVar(&o.ModelMaxBackends, "model-max-backends", o.ModelMaxBackends, ""+
+		"Defines the maximum number of backends added to the configuration. "+
+		"Use 0 (zero), the default value, to disable this limit.",
+	)
+
+	fs.IntVar(&o.ModelMaxEndpoints, "model-max-endpoints", o.ModelMaxEndpoints, ""+
+		"Defines the maximum number of endpoints, considering all the backends, added "+
+		"to the configuration. Use 0 (zero), the default value, to disable this limit.",
+	)
+
+	fs.IntVar(&o.ModelMaxConfigSize, "model-max-config-size", o.ModelMaxConfigSize, ""+
+		"Defines the maximum size, in bytes, of a rendered haproxy configuration file. "+
+		"A bigger configuration is not applied. Use 0 (zero), the default value, to "+
+		"disable this limit.",
+	)
+
+	fs.StringVar(&o.ModelLimitPolicy, "model-limit-policy", o.ModelLimitPolicy, ""+
+		"Defines which ingress resources are added to the configuration when a model "+
+		"limit is exceeded: 'oldest' adds the oldest ones first, 'class-priority' adds "+
+		"first the ones whose class is listed first in --model-limit-class-priority.",
+	)
+
+	fs.StringVar(&o.ModelLimitClassPriority, "model-limit-class-priority", o.ModelLimitClassPriority, ""+
+		"Comma-separated list of ingress class names, in the order their ingress "+
+		"resources should be added to the configuration when --model-limit-policy is "+
+		"'class-priority'.",
+	)
+
+	fs.StringVar(&o.HostOwnershipConfigMap, "host-ownership-configmap", o.HostOwnershipConfigMap, ""+
+		"Enables host ownership: the first namespace to use a hostname owns it, and "+
+		"ingress resources of other namespaces using the same hostname are rejected, "+
+		"unless the owner delegates the hostname. Owners are persisted in this "+
+		"ConfigMap, in the format <namespace>/<name>, the namespace of the controller "+
+		"pod is used if missing. Host ownership is disabled by default.",
+	)
+
+	fs.BoolVar(&o.HostOwnershipWildcard, "host-ownership-wildcard", o.HostOwnershipWildcard, ""+
+		"Defines if the owner of a wildcard hostname, like *.example.com, also owns "+
+		"the hostnames it matches, like sub.example.com, that do not have an owner yet.",
+	)
+
+	fs.StringVar(&o.PathTombstonesConfigMap, "path-tombstones-configmap", o.PathTombstonesConfigMap, ""+
+		"Name of a ConfigMap, in the format <namespace>/<name>, used to persist the "+
+		"paths of deleted ingress resources that are still routed due to the "+
+		"graceful-path-removal configuration key, so the removal window survives a "+
+		"controller restart. The namespace of the controller pod is used if missing. "+
+		"Tombstones are only kept in memory if not configured.",
+	)
+
 	fs.BoolVar(&o.UpdateStatusOnShutdown, "update-status-on-shutdown", o.UpdateStatusOnShutdown, ""+
 		"Indicates if the ingress controller should update the Ingress status "+
 		"IP/hostname when the controller is being stopped.",
 	)
 
+	fs.BoolVar(&o.StatusWithdrawUnhealthy, "status-withdraw-on-unhealthy", o.StatusWithdrawUnhealthy, ""+
+		"Removes the IP/hostname from the status of the ingress resources whose backends "+
+		"don't have any available server, restoring it when a server is available again. "+
+		"Needs --update-status.",
+	)
+
+	fs.DurationVar(&o.StatusWithdrawDelay, "status-withdraw-delay", o.StatusWithdrawDelay, ""+
+		"How long the backends of an ingress should be continuously unavailable before "+
+		"its status is withdrawn, see --status-withdraw-on-unhealthy.",
+	)
+
+	fs.DurationVar(&o.StatusRestoreDelay, "status-restore-delay", o.StatusRestoreDelay, ""+
+		"How long a withdrawn ingress should have continuously available backends before "+
+		"its status is restored, see --status-withdraw-on-unhealthy.",
+	)
+
 	fs.IntVar(&o.BackendShards, "backend-shards", o.BackendShards, ""+
 		"Defines how much files should be used to configure the haproxy backends",
 	)
 
+	fs.BoolVar(&o.PartitionBackends, "partition-backends", o.PartitionBackends, ""+
+		"Groups the backends and hosts of the same namespace in the same partition, "+
+		"and isolates a partition rejected by haproxy, so the other ones continue to "+
+		"be updated. Needs --backend-shards greater than zero.",
+	)
+
 	fs.BoolVar(&o.SortBackends, "sort-backends", o.SortBackends, ""+
 		"Defines if backend's endpoints should be sorted by name. This option has less "+
 		"precedence than --sort-endpoints-by if both are declared.",
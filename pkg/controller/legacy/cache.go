@@ -22,6 +22,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"net"
@@ -37,6 +38,7 @@ import (
 	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -50,7 +52,9 @@ import (
 	cfile "github.com/jcmoraisjr/haproxy-ingress/pkg/common/file"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/ingress/controller"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/common/net/ssl"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
@@ -66,6 +70,7 @@ type k8scache struct {
 	cfg                    *controller.Configuration
 	tracker                convtypes.Tracker
 	dynamicConfig          *convtypes.DynamicConfig
+	recorder               record.EventRecorder
 	podNamespace           string
 	globalConfigMapKey     string
 	tcpConfigMapKey        string
@@ -125,6 +130,7 @@ func createCache(
 		cfg:                    cfg,
 		tracker:                tracker,
 		dynamicConfig:          configOptions,
+		recorder:               recorder,
 		podNamespace:           podNamespace,
 		globalConfigMapKey:     globalConfigMapName,
 		tcpConfigMapKey:        tcpConfigMapName,
@@ -549,6 +555,23 @@ func (c *k8scache) GetPasswdSecretContent(defaultNamespace, secretName string, t
 	return data, nil
 }
 
+func (c *k8scache) GetPasswdSecretMap(defaultNamespace, secretName string, track []convtypes.TrackingRef) (map[string][]byte, error) {
+	proto, content := getContentProtocol(secretName)
+	if proto != "secret" {
+		return nil, fmt.Errorf("unsupported protocol: %s", proto)
+	}
+	namespace, name, err := c.buildResourceName(defaultNamespace, "secret", content, c.dynamicConfig.CrossNamespaceSecretPasswd)
+	if err != nil {
+		return nil, err
+	}
+	c.tracker.TrackRefName(track, convtypes.ResourceSecret, namespace+"/"+name)
+	secret, err := c.listers.secretLister.Secrets(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
 // Implements acme.ClientResolver
 func (c *k8scache) GetKey() (crypto.Signer, error) {
 	secret, err := c.GetSecret(c.acmeSecretKeyName)
@@ -631,6 +654,105 @@ func (c *k8scache) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte)
 	return c.CreateOrUpdateSecret(secret)
 }
 
+// backendIngresses lists the ingress resources that use a backend. The
+// tracker is read, so the model should be locked.
+func (c *k8scache) backendIngresses(backend string) []string {
+	return c.tracker.LinkedNames(convtypes.ResourceHABackend, backend, convtypes.ResourceIngress)
+}
+
+// notifyRetryBudget reports a retry budget exceeded by a backend on the
+// ingress resources ingNames, see backendIngresses.
+func (c *k8scache) notifyRetryBudget(budget haproxy.RetryBudgetExceeded, ingNames []string) {
+	for _, ingName := range ingNames {
+		ing, err := c.GetIngress(ingName)
+		if err != nil {
+			c.logger.Warn("cannot read ingress '%s' to report retry budget: %v", ingName, err)
+			continue
+		}
+		c.recorder.Eventf(ing, api.EventTypeWarning, "RetryBudgetExceeded",
+			"backend '%s' retried %d connections on %d requests, above the budget of %.1f%%",
+			budget.Backend, budget.Retries, budget.Requests, budget.Budget)
+	}
+}
+
+// pauseBlueGreen adds the paused annotation, with the weights in use, to the
+// ingress resources ingNames of the backend, so the converter keeps these
+// weights until the annotation is removed, see backendIngresses.
+func (c *k8scache) pauseBlueGreen(pause haproxy.BlueGreenPause, ingNames []string) {
+	if len(c.cfg.AnnPrefix) == 0 {
+		c.logger.Error("cannot pause blue/green balance of backend '%s': annotation prefix is empty", pause.Backend)
+		return
+	}
+	annName := c.cfg.AnnPrefix[0] + "/" + ingtypes.BackBlueGreenPaused
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				annName: pause.Balance,
+			},
+		},
+	})
+	if err != nil {
+		c.logger.Error("cannot pause blue/green balance of backend '%s': %v", pause.Backend, err)
+		return
+	}
+	for _, ingName := range ingNames {
+		ing, err := c.GetIngress(ingName)
+		if err != nil {
+			c.logger.Warn("cannot read ingress '%s' to pause blue/green balance: %v", ingName, err)
+			continue
+		}
+		if ing.Annotations[annName] == pause.Balance {
+			continue
+		}
+		_, err = c.client.NetworkingV1().Ingresses(ing.Namespace).Patch(c.ctx, ing.Name, k8stypes.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			c.logger.Error("cannot pause blue/green balance on ingress '%s': %v", ingName, err)
+			continue
+		}
+		c.recorder.Eventf(ing, api.EventTypeWarning, "BlueGreenPaused",
+			"blue/green balance of backend '%s' was paused: canary error rate %.1f%% is above the baseline rate %.1f%% plus %.1f%%, remove the '%s' annotation to resume",
+			pause.Backend, pause.CanaryRate, pause.BaselineRate, pause.Threshold, annName)
+	}
+}
+
+func (c *k8scache) notifyRejectedBackend(rejected haproxy.RejectedBackend) {
+	for _, ingName := range c.tracker.LinkedNames(convtypes.ResourceHABackend, rejected.Backend, convtypes.ResourceIngress) {
+		ing, err := c.GetIngress(ingName)
+		if err != nil {
+			c.logger.Warn("cannot read ingress '%s' to report rejected configuration: %v", ingName, err)
+			continue
+		}
+		c.recorder.Eventf(ing, api.EventTypeWarning, "ConfigRejected",
+			"configuration of backend '%s' was rejected by haproxy, rolled back to the last known good state: %s",
+			rejected.Backend, rejected.Error)
+	}
+}
+
+// Implements acme.SignerResolver
+func (c *k8scache) SetTLSSecretFailure(secretName string, domains []string, err error) {
+	namespace, name, errKey := cache.SplitMetaNamespaceKey(secretName)
+	if errKey != nil {
+		return
+	}
+	ingList, errList := c.GetIngressList()
+	if errList != nil {
+		c.logger.Warn("cannot list ingress to report acme failure of secret '%s': %v", secretName, errList)
+		return
+	}
+	for _, ing := range ingList {
+		if ing.Namespace != namespace {
+			continue
+		}
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == name {
+				c.recorder.Eventf(ing, api.EventTypeWarning, "AcmeSigningFailed",
+					"error signing certificate of secret '%s' and domain(s) %s: %v", name, strings.Join(domains, ","), err)
+				break
+			}
+		}
+	}
+}
+
 // Implements acme.ServerResolver
 func (c *k8scache) GetToken(domain, uri string) string {
 	config, err := c.GetConfigMap(c.acmeTokenConfigmapName)
@@ -775,6 +897,27 @@ func (c *k8scache) IsValidConfigMap(cm *api.ConfigMap) bool {
 // used by this version of the controller.
 func (c *k8scache) UpdateStatus(client.Object) {}
 
+func (c *k8scache) NotifyIngressWarning(ingressName, reason, message string) {
+	ing, err := c.GetIngress(ingressName)
+	if err != nil {
+		c.logger.Warn("cannot read ingress '%s' to report a %s warning event: %v", ingressName, reason, err)
+		return
+	}
+	c.recorder.Event(ing, api.EventTypeWarning, reason, message)
+}
+
+func (c *k8scache) NotifyGlobalConfigEvent(eventType, reason, message string) {
+	if c.globalConfigMapKey == "" {
+		return
+	}
+	cm, err := c.GetConfigMap(c.globalConfigMapKey)
+	if err != nil {
+		c.logger.Warn("cannot read configmap '%s' to report a %s event: %v", c.globalConfigMapKey, reason, err)
+		return
+	}
+	c.recorder.Event(cm, eventType, reason, message)
+}
+
 // implements ListerEvents
 func (c *k8scache) Notify(old, cur interface{}) {
 	// IMPLEMENT
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -54,6 +55,7 @@ type HAProxyController struct {
 	ingressQueue     utils.Queue
 	acmeQueue        utils.Queue
 	reloadQueue      utils.Queue
+	reloadCheck      *time.Timer
 	leaderelector    types.LeaderElector
 	updateCount      int
 	reloadCount      int
@@ -137,6 +139,7 @@ func (hc *HAProxyController) configController() {
 		AdminSocket:       ingress.DefaultVarRunDirectory + "/admin.sock",
 		AcmeSocket:        ingress.DefaultVarRunDirectory + "/acme.sock",
 		BackendShards:     hc.cfg.BackendShards,
+		PartitionBackends: hc.cfg.PartitionBackends,
 		AcmeSigner:        acmeSigner,
 		AcmeQueue:         hc.acmeQueue,
 		ReloadQueue:       hc.reloadQueue,
@@ -144,6 +147,7 @@ func (hc *HAProxyController) configController() {
 		Metrics:           hc.metrics,
 		ReloadStrategy:    hc.cfg.ReloadStrategy,
 		MaxOldConfigFiles: hc.cfg.MaxOldConfigFiles,
+		ScalingSignalsMax: hc.cfg.StatsScalingSignalsMax,
 		SortEndpointsBy:   hc.cfg.SortEndpointsBy,
 		StopCh:            hc.stopCh,
 		TrackInstances:    hc.cfg.TrackOldInstances,
@@ -153,10 +157,18 @@ func (hc *HAProxyController) configController() {
 	if err := hc.instance.ParseTemplates(); err != nil {
 		klog.Exitf("error creating HAProxy instance: %v", err)
 	}
+	var haproxyVersion string
+	var haproxyFeatures []string
+	if !instanceOptions.IsExternal {
+		// external haproxy is not reachable from here, assume it is up to date
+		haproxyVersion = utils.HAProxyVersion()
+		haproxyFeatures = utils.HAProxyFeatures()
+	}
 	hc.converterOptions = &convtypes.ConverterOptions{
 		Logger:           hc.logger,
 		Cache:            hc.cache,
 		Tracker:          hc.tracker,
+		Metrics:          hc.metrics,
 		DynamicConfig:    hc.dynamicConfig,
 		LocalFSPrefix:    hc.cfg.LocalFSPrefix,
 		IsExternal:       instanceOptions.IsExternal,
@@ -174,6 +186,13 @@ func (hc *HAProxyController) configController() {
 		HasGatewayA2:     hc.cache.hasGateway(),
 		HasGatewayB1:     false,
 		EnableEPSlices:   hc.cfg.EnableEndpointSlicesAPI,
+		PodName:          os.Getenv("POD_NAME"),
+		HAProxyVersion:   haproxyVersion,
+		HAProxyFeatures:  haproxyFeatures,
+	}
+	if hc.leaderelector != nil {
+		// leader election is only configured along with acme
+		hc.converterOptions.IsLeader = hc.leaderelector.IsLeader
 	}
 }
 
@@ -188,6 +207,9 @@ func (hc *HAProxyController) startServices() {
 			hc.instance.CalcIdleMetric()
 		}, hc.cfg.StatsCollectProcPeriod, hc.stopCh)
 	}
+	if hc.cfg.StatsCollectBackPeriod.Milliseconds() > 0 {
+		go wait.Until(hc.calcBackendStats, hc.cfg.StatsCollectBackPeriod, hc.stopCh)
+	}
 	if hc.leaderelector != nil {
 		go hc.leaderelector.Run(hc.stopCh)
 	}
@@ -346,15 +368,69 @@ func (hc *HAProxyController) syncIngress(item interface{}) {
 	//
 	hc.instance.AcmeUpdate()
 	hc.instance.HAProxyUpdate(timer)
+	hc.notifyRejectedBackends()
+	hc.scheduleReloadCheck()
 	hc.logger.Info("finish haproxy update id=%d: %s", hc.updateCount, timer.AsString("total"))
 }
 
+// scheduleReloadCheck enqueues a new sync when a reload was deferred, so
+// the reload is checked again even if no resource changes.
+func (hc *HAProxyController) scheduleReloadCheck() {
+	if hc.reloadCheck != nil {
+		hc.reloadCheck.Stop()
+		hc.reloadCheck = nil
+	}
+	if next := hc.instance.NextReloadCheck(); next > 0 {
+		hc.reloadCheck = time.AfterFunc(next, func() { hc.ingressQueue.Notify() })
+	}
+}
+
 func (hc *HAProxyController) acmeCheck(source string) (int, error) {
 	hc.writeModelMutex.Lock()
 	defer hc.writeModelMutex.Unlock()
 	return hc.instance.AcmeCheck(source)
 }
 
+func (hc *HAProxyController) calcBackendStats() {
+	// the admin socket is read without the model lock, so a slow
+	// response does not delay the synchronization of the model
+	hc.writeModelMutex.Lock()
+	sample := hc.instance.NewStatsSample()
+	hc.writeModelMutex.Unlock()
+	sample.Read()
+
+	hc.writeModelMutex.Lock()
+	budgets := hc.instance.CalcBackendStats(sample)
+	budgetIngs := make([][]string, len(budgets))
+	for j, budget := range budgets {
+		budgetIngs[j] = hc.cache.backendIngresses(budget.Backend)
+	}
+	var pauses []haproxy.BlueGreenPause
+	var pauseIngs [][]string
+	if hc.leaderelector == nil || hc.leaderelector.IsLeader() {
+		// leader election is only configured along with acme
+		pauses = hc.instance.BlueGreenPauses()
+		pauseIngs = make([][]string, len(pauses))
+		for j, pause := range pauses {
+			pauseIngs[j] = hc.cache.backendIngresses(pause.Backend)
+		}
+	}
+	hc.writeModelMutex.Unlock()
+
+	for j, budget := range budgets {
+		hc.cache.notifyRetryBudget(budget, budgetIngs[j])
+	}
+	for j, pause := range pauses {
+		hc.cache.pauseBlueGreen(pause, pauseIngs[j])
+	}
+}
+
+func (hc *HAProxyController) notifyRejectedBackends() {
+	for _, rejected := range hc.instance.RejectedBackends() {
+		hc.cache.notifyRejectedBackend(rejected)
+	}
+}
+
 func (hc *HAProxyController) reloadHAProxy(item interface{}) {
 	hc.writeModelMutex.Lock()
 	defer hc.writeModelMutex.Unlock()
@@ -364,5 +440,6 @@ func (hc *HAProxyController) reloadHAProxy(item interface{}) {
 	timer := utils.NewTimer(hc.metrics.ControllerProcTime)
 
 	hc.instance.Reload(timer)
+	hc.notifyRejectedBackends()
 	hc.logger.Info("finish haproxy reload id=%d: %s", hc.reloadCount, timer.AsString("total"))
 }
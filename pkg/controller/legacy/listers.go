@@ -547,7 +547,10 @@ func (l *listers) createPodLister(informer informerscore.PodInformer) {
 		UpdateFunc: func(old, cur interface{}) {
 			oldPod := old.(*api.Pod)
 			curPod := cur.(*api.Pod)
-			if oldPod.DeletionTimestamp != curPod.DeletionTimestamp {
+			// labels and annotations are used by pod-maintenance-key
+			if oldPod.DeletionTimestamp != curPod.DeletionTimestamp ||
+				!reflect.DeepEqual(oldPod.Labels, curPod.Labels) ||
+				!reflect.DeepEqual(oldPod.Annotations, curPod.Annotations) {
 				l.events.Notify(old, cur)
 			}
 		},
@@ -21,16 +21,34 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 type metrics struct {
 	responseTime       *prometheus.HistogramVec
+	socketCmdCounter   *prometheus.CounterVec
 	ctlProcTimeSum     *prometheus.CounterVec
 	ctlProcCount       *prometheus.CounterVec
 	procSecondsCounter *prometheus.CounterVec
+	backendRetries     *prometheus.CounterVec
+	backendRedispatch  *prometheus.CounterVec
+	lintFindings       *prometheus.GaugeVec
+	annotationLimits   *prometheus.CounterVec
+	annotationsDropped *prometheus.CounterVec
+	hostClassConflict  *prometheus.CounterVec
+	healthPushFailures *prometheus.CounterVec
 	updatesCounter     *prometheus.CounterVec
 	updateSuccessGauge *prometheus.GaugeVec
 	certExpireGauge    *prometheus.GaugeVec
+	epMaintGauge       *prometheus.GaugeVec
+	aclSpilledGauge    *prometheus.GaugeVec
+	modelLimitGauge    *prometheus.GaugeVec
+	peerSessionsGauge  *prometheus.GaugeVec
+	backendSessions    *prometheus.GaugeVec
+	backendQueue       *prometheus.GaugeVec
+	backendConnTime    *prometheus.GaugeVec
+	backendRespTime    *prometheus.GaugeVec
 	certSigningCounter *prometheus.CounterVec
 	lastTrack          time.Time
 }
@@ -47,6 +65,14 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{"command"},
 		),
+		socketCmdCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_socket_commands_total",
+				Help:      "Cumulative number of commands sent via admin socket. Status can be attempted, succeeded, failed.",
+			},
+			[]string{"status"},
+		),
 		ctlProcTimeSum: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -71,6 +97,62 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{},
 		),
+		backendRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_backend_retries_total",
+				Help:      "Cumulative number of connection retries of a backend, based on haproxy's wretr.",
+			},
+			[]string{"backend"},
+		),
+		backendRedispatch: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "haproxy_backend_redispatches_total",
+				Help:      "Cumulative number of requests redispatched to another server of a backend, based on haproxy's wredis.",
+			},
+			[]string{"backend"},
+		),
+		lintFindings: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "lint_findings",
+				Help:      "Number of risky configurations currently found by the configuration linter.",
+			},
+			[]string{"rule"},
+		),
+		annotationLimits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "annotation_limits_total",
+				Help:      "Cumulative number of configuration values that exceeded an annotation limit.",
+			},
+			[]string{"limit"},
+		),
+		annotationsDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "annotations_dropped_total",
+				Help:      "Cumulative number of configuration keys not applied due to a conflict, an override or an annotation limit.",
+			},
+			[]string{"namespace", "class", "key"},
+		),
+		hostClassConflict: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "host_class_conflicts_total",
+				Help:      "Cumulative number of hostnames skipped because they were already claimed by an ingress of another IngressClass.",
+			},
+			[]string{"class"},
+		),
+		healthPushFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "health_push_failures_total",
+				Help:      "Cumulative number of failed attempts to push the summary of unavailable hosts.",
+			},
+			[]string{},
+		),
 		updatesCounter: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -95,6 +177,70 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 			},
 			[]string{"domain", "cn"},
 		),
+		epMaintGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "endpoints_maintenance",
+				Help:      "Number of endpoints of a backend in maintenance due to pod-maintenance-key.",
+			},
+			[]string{"backend"},
+		),
+		aclSpilledGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "acl_lists_spilled",
+				Help:      "Number of ACL lists written to a file due to their size, per kind of configuration section.",
+			},
+			[]string{"section"},
+		),
+		modelLimitGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "model_limit_rejected",
+				Help:      "Number of ingress resources, or rendered configuration, currently rejected by a model limit.",
+			},
+			[]string{"limit"},
+		),
+		peerSessionsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "peer_sessions",
+				Help:      "Number of remote peers, sharing stick tables with this replica, by the status of their sessions.",
+			},
+			[]string{"status"},
+		),
+		backendSessions: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "backend_current_sessions",
+				Help:      "Current number of sessions of the backends of a service.",
+			},
+			[]string{"namespace", "service"},
+		),
+		backendQueue: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "backend_current_queue",
+				Help:      "Current number of queued requests of the backends of a service.",
+			},
+			[]string{"namespace", "service"},
+		),
+		backendConnTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "backend_connect_time_seconds",
+				Help:      "Average connect time of the last 1024 requests of the backends of a service.",
+			},
+			[]string{"namespace", "service"},
+		),
+		backendRespTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "backend_response_time_seconds",
+				Help:      "Average response time of the last 1024 requests of the backends of a service.",
+			},
+			[]string{"namespace", "service"},
+		),
 		certSigningCounter: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
@@ -105,12 +251,28 @@ func createMetrics(bucketsResponseTime []float64) *metrics {
 		),
 	}
 	prometheus.MustRegister(metrics.responseTime)
+	prometheus.MustRegister(metrics.socketCmdCounter)
 	prometheus.MustRegister(metrics.ctlProcTimeSum)
 	prometheus.MustRegister(metrics.ctlProcCount)
 	prometheus.MustRegister(metrics.procSecondsCounter)
+	prometheus.MustRegister(metrics.backendRetries)
+	prometheus.MustRegister(metrics.backendRedispatch)
+	prometheus.MustRegister(metrics.lintFindings)
+	prometheus.MustRegister(metrics.annotationLimits)
+	prometheus.MustRegister(metrics.annotationsDropped)
+	prometheus.MustRegister(metrics.hostClassConflict)
+	prometheus.MustRegister(metrics.healthPushFailures)
 	prometheus.MustRegister(metrics.updatesCounter)
 	prometheus.MustRegister(metrics.updateSuccessGauge)
 	prometheus.MustRegister(metrics.certExpireGauge)
+	prometheus.MustRegister(metrics.epMaintGauge)
+	prometheus.MustRegister(metrics.aclSpilledGauge)
+	prometheus.MustRegister(metrics.modelLimitGauge)
+	prometheus.MustRegister(metrics.peerSessionsGauge)
+	prometheus.MustRegister(metrics.backendSessions)
+	prometheus.MustRegister(metrics.backendQueue)
+	prometheus.MustRegister(metrics.backendConnTime)
+	prometheus.MustRegister(metrics.backendRespTime)
 	prometheus.MustRegister(metrics.certSigningCounter)
 	return metrics
 }
@@ -119,6 +281,10 @@ func (m *metrics) HAProxyShowInfoResponseTime(duration time.Duration) {
 	m.responseTime.WithLabelValues("show_info").Observe(duration.Seconds())
 }
 
+func (m *metrics) HAProxyShowStatResponseTime(duration time.Duration) {
+	m.responseTime.WithLabelValues("show_stat").Observe(duration.Seconds())
+}
+
 func (m *metrics) HAProxySetServerResponseTime(duration time.Duration) {
 	m.responseTime.WithLabelValues("set_server").Observe(duration.Seconds())
 }
@@ -127,6 +293,18 @@ func (m *metrics) HAProxySetSSLCertResponseTime(duration time.Duration) {
 	m.responseTime.WithLabelValues("set_ssl_cert").Observe(duration.Seconds())
 }
 
+func (m *metrics) AddSocketCmdAttempted(count int) {
+	m.socketCmdCounter.WithLabelValues("attempted").Add(float64(count))
+}
+
+func (m *metrics) AddSocketCmdSucceeded(count int) {
+	m.socketCmdCounter.WithLabelValues("succeeded").Add(float64(count))
+}
+
+func (m *metrics) AddSocketCmdFailed(count int) {
+	m.socketCmdCounter.WithLabelValues("failed").Add(float64(count))
+}
+
 func (m *metrics) ControllerProcTime(task string, duration time.Duration) {
 	m.ctlProcTimeSum.WithLabelValues(task).Add(duration.Seconds())
 	m.ctlProcCount.WithLabelValues(task).Inc()
@@ -143,6 +321,30 @@ func (m *metrics) AddIdleFactor(idle int) {
 	m.procSecondsCounter.WithLabelValues().Add(float64(100-idle) * totalTime / 100)
 }
 
+func (m *metrics) AddBackendRetries(backend string, count int) {
+	m.backendRetries.WithLabelValues(backend).Add(float64(count))
+}
+
+func (m *metrics) AddBackendRedispatches(backend string, count int) {
+	m.backendRedispatch.WithLabelValues(backend).Add(float64(count))
+}
+
+func (m *metrics) IncAnnotationLimit(limit string) {
+	m.annotationLimits.WithLabelValues(limit).Inc()
+}
+
+func (m *metrics) IncAnnotationDropped(namespace, class, key string) {
+	m.annotationsDropped.WithLabelValues(namespace, class, key).Inc()
+}
+
+func (m *metrics) IncHostClassConflict(class string) {
+	m.hostClassConflict.WithLabelValues(class).Inc()
+}
+
+func (m *metrics) IncHealthPushFailure() {
+	m.healthPushFailures.WithLabelValues().Inc()
+}
+
 func (m *metrics) IncUpdateNoop() {
 	m.updatesCounter.WithLabelValues("noop").Inc()
 }
@@ -172,6 +374,56 @@ func (m *metrics) ClearCertExpire() {
 	m.certExpireGauge.Reset()
 }
 
+func (m *metrics) SetEndpointsMaintenance(backend string, count int) {
+	if count == 0 {
+		m.epMaintGauge.DeleteLabelValues(backend)
+		return
+	}
+	m.epMaintGauge.WithLabelValues(backend).Set(float64(count))
+}
+
+func (m *metrics) SetACLListsSpilled(section string, count int) {
+	if count == 0 {
+		m.aclSpilledGauge.DeleteLabelValues(section)
+		return
+	}
+	m.aclSpilledGauge.WithLabelValues(section).Set(float64(count))
+}
+
+func (m *metrics) SetModelLimitRejected(limit string, count int) {
+	if count == 0 {
+		m.modelLimitGauge.DeleteLabelValues(limit)
+		return
+	}
+	m.modelLimitGauge.WithLabelValues(limit).Set(float64(count))
+}
+
+func (m *metrics) SetLintFindings(rule string, count int) {
+	if count == 0 {
+		m.lintFindings.DeleteLabelValues(rule)
+		return
+	}
+	m.lintFindings.WithLabelValues(rule).Set(float64(count))
+}
+
+func (m *metrics) SetPeerSessions(status string, count int) {
+	m.peerSessionsGauge.WithLabelValues(status).Set(float64(count))
+}
+
+func (m *metrics) SetBackendLoad(namespace, service string, load *types.BackendLoad) {
+	if load == nil {
+		m.backendSessions.DeleteLabelValues(namespace, service)
+		m.backendQueue.DeleteLabelValues(namespace, service)
+		m.backendConnTime.DeleteLabelValues(namespace, service)
+		m.backendRespTime.DeleteLabelValues(namespace, service)
+		return
+	}
+	m.backendSessions.WithLabelValues(namespace, service).Set(float64(load.Sessions))
+	m.backendQueue.WithLabelValues(namespace, service).Set(float64(load.Queue))
+	m.backendConnTime.WithLabelValues(namespace, service).Set(load.ConnectTime.Seconds())
+	m.backendRespTime.WithLabelValues(namespace, service).Set(load.ResponseTime.Seconds())
+}
+
 func (m *metrics) IncCertSigningMissing(domains string, success bool) {
 	m.certSigningCounter.WithLabelValues(domains, "missing", strconv.FormatBool(success)).Inc()
 }
@@ -0,0 +1,57 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	networking "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+)
+
+// Run writes the migration report of the ingress resources of all the
+// ingress classes to the standard output, in JSON format. Only the watched
+// namespace is read, if configured. Haproxy is neither configured nor started.
+func Run(cfg *config.Config) error {
+	cli, err := client.New(cfg.KubeConfig, client.Options{Scheme: cfg.Scheme})
+	if err != nil {
+		return fmt.Errorf("error creating client: %w", err)
+	}
+	return run(cfg.RootContext, cli, cfg.WatchNamespace, cfg.AnnPrefix, os.Stdout)
+}
+
+func run(ctx context.Context, cli client.Reader, namespace string, prefixes []string, stdout io.Writer) error {
+	ingList := networking.IngressList{}
+	if err := cli.List(ctx, &ingList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("error listing ingress resources: %w", err)
+	}
+	migration := annotations.NewMigrationCollector(prefixes)
+	for i := range ingList.Items {
+		ing := &ingList.Items[i]
+		migration.Add(ing.Namespace, ing.Name, ing.Annotations)
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(migration.Report())
+}
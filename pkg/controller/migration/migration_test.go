@@ -0,0 +1,96 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMigrationReport(t *testing.T) {
+	ing := func(namespace, name string, ann map[string]string) *networking.Ingress {
+		return &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Annotations: ann}}
+	}
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		ing("ns1", "app1", map[string]string{
+			"ingress.kubernetes.io/rewrite-target":       "/",
+			"nginx.ingress.kubernetes.io/rewrite-target": "/$1",
+		}),
+		ing("ns1", "app2", nil),
+		ing("ns2", "app1", map[string]string{
+			"haproxy-ingress.github.io/timeout-server": "1m",
+			"ingress.kubernetes.io/timeout-server":     "30s",
+		}),
+	).Build()
+	expected := `{
+  "prefixes": [
+    "haproxy-ingress.github.io",
+    "ingress.kubernetes.io"
+  ],
+  "ingresses": [
+    {
+      "namespace": "ns1",
+      "name": "app1",
+      "keys": [
+        {
+          "key": "rewrite-target",
+          "prefix": "ingress.kubernetes.io",
+          "difference": "replaces the matching path prefix, regex capture groups are not supported"
+        }
+      ]
+    },
+    {
+      "namespace": "ns2",
+      "name": "app1",
+      "keys": [
+        {
+          "key": "timeout-server",
+          "prefix": "haproxy-ingress.github.io",
+          "ignored": [
+            "ingress.kubernetes.io"
+          ],
+          "conflict": true
+        }
+      ]
+    }
+  ],
+  "differences": [
+    {
+      "key": "rewrite-target",
+      "difference": "replaces the matching path prefix, regex capture groups are not supported",
+      "ingresses": {
+        "ingress.kubernetes.io": 1
+      }
+    }
+  ]
+}
+`
+	out := &bytes.Buffer{}
+	prefixes := []string{"haproxy-ingress.github.io", "ingress.kubernetes.io"}
+	if err := run(context.Background(), cli, "", prefixes, out); err != nil {
+		t.Fatalf("error running migration report: %v", err)
+	}
+	if actual := out.String(); actual != expected {
+		t.Errorf("report differs - expected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
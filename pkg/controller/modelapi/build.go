@@ -0,0 +1,138 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelapi
+
+import (
+	"reflect"
+	"sort"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// buildHosts copies the hosts of the model to the API types, sorted by
+// hostname. The default host is named after hatypes.DefaultHost.
+func buildHosts(hosts *hatypes.Hosts) []Host {
+	items := make([]Host, 0, len(hosts.Items()))
+	for _, host := range hosts.Items() {
+		h := Host{
+			Hostname:       host.Hostname,
+			Paths:          make([]Path, 0, len(host.Paths)),
+			SSLPassthrough: host.SSLPassthrough(),
+		}
+		for _, path := range host.Paths {
+			h.Paths = append(h.Paths, Path{
+				Path:       path.Path(),
+				Match:      string(path.Link.Match()),
+				Backend:    path.Backend.ID,
+				RedirectTo: path.RedirTo,
+			})
+		}
+		if tls := host.TLS; tls.TLSFilename != "" {
+			h.TLS = &HostTLS{
+				CommonName: tls.TLSCommonName,
+				NotAfter:   tls.TLSNotAfter,
+			}
+		}
+		items = append(items, h)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Hostname < items[j].Hostname
+	})
+	return items
+}
+
+// buildBackends copies the backends of the model to the API types, sorted
+// by ID. Empty endpoint slots, used by dynamic updates, are not copied.
+func buildBackends(backends *hatypes.Backends) []Backend {
+	items := make([]Backend, 0, len(backends.Items()))
+	for _, backend := range backends.Items() {
+		b := Backend{
+			ID:        backend.ID,
+			Namespace: backend.Namespace,
+			Name:      backend.Name,
+			Port:      backend.Port,
+			Mode:      "http",
+			Config: BackendConfig{
+				BalanceAlgorithm: backend.BalanceAlgorithm,
+				MaxConn:          backend.Server.MaxConn,
+				Protocol:         backend.Server.Protocol,
+				Secure:           backend.Server.Secure,
+				SlowStart:        backend.Server.SlowStart,
+				Timeout: Timeouts{
+					Connect: backend.Timeout.Connect,
+					Queue:   backend.Timeout.Queue,
+					Server:  backend.Timeout.Server,
+					Tunnel:  backend.Timeout.Tunnel,
+				},
+			},
+			Endpoints: []Endpoint{},
+		}
+		if backend.ModeTCP {
+			b.Mode = "tcp"
+		}
+		if !backend.Fallback.IsEmpty() {
+			b.Config.Fallback = backend.Fallback.String()
+		}
+		for _, ep := range backend.Endpoints {
+			if !ep.Enabled {
+				continue
+			}
+			b.Endpoints = append(b.Endpoints, Endpoint{
+				Name:      ep.Name,
+				IP:        ep.IP,
+				Port:      ep.Port,
+				Weight:    ep.Weight,
+				TargetRef: ep.TargetRef,
+			})
+		}
+		sort.Slice(b.Endpoints, func(i, j int) bool {
+			return b.Endpoints[i].Name < b.Endpoints[j].Name
+		})
+		items = append(items, b)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// diff builds the change from the hosts and backends of old to the ones of new.
+func diff(old, new *Snapshot) *Change {
+	change := &Change{}
+	change.Hosts, change.RemovedHosts = diffItems(old.Hosts, new.Hosts, func(h Host) string { return h.Hostname })
+	change.Backends, change.RemovedBackends = diffItems(old.Backends, new.Backends, func(b Backend) string { return b.ID })
+	return change
+}
+
+func diffItems[T any](old, new []T, key func(T) string) (updated []T, removed []string) {
+	oldItems := make(map[string]*T, len(old))
+	for i := range old {
+		oldItems[key(old[i])] = &old[i]
+	}
+	for _, item := range new {
+		k := key(item)
+		if oldItem, found := oldItems[k]; !found || !reflect.DeepEqual(*oldItem, item) {
+			updated = append(updated, item)
+		}
+		delete(oldItems, k)
+	}
+	for k := range oldItems {
+		removed = append(removed, k)
+	}
+	sort.Strings(removed)
+	return updated, removed
+}
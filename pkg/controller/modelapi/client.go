@@ -0,0 +1,169 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// NewClient creates a client of the model API. baseURL is the scheme and
+// address of the controller's internal server, e.g. http://127.0.0.1:10255.
+// http.DefaultClient is used if httpClient is nil.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  httpClient,
+	}
+}
+
+// Client reads the model API. It is also an example of how external tools
+// should consume the API: read a snapshot from the watch stream, apply the
+// changes in the order they arrive, and start over on gaps or disconnects.
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// Snapshot reads the current snapshot.
+func (c *Client) Snapshot(ctx context.Context) (*Snapshot, error) {
+	res, err := c.get(ctx, SnapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	snapshot := &Snapshot{}
+	if err := json.NewDecoder(res.Body).Decode(snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding model snapshot: %w", err)
+	}
+	if snapshot.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version: %s", snapshot.SchemaVersion)
+	}
+	return snapshot, nil
+}
+
+// Watch calls update with an up to date snapshot every time the model
+// changes, until ctx is done, the stream ends, or update returns an error.
+// Snapshots passed to update should not be changed.
+func (c *Client) Watch(ctx context.Context, update func(*Snapshot) error) error {
+	res, err := c.get(ctx, WatchPath)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var snapshot *Snapshot
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		event := &Event{}
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			return fmt.Errorf("error decoding model event: %w", err)
+		}
+		if event.SchemaVersion != SchemaVersion {
+			return fmt.Errorf("unsupported schema version: %s", event.SchemaVersion)
+		}
+		switch event.Type {
+		case EventSnapshot:
+			snapshot = event.Snapshot
+		case EventChange:
+			if snapshot == nil || event.Generation != snapshot.Generation+1 {
+				return fmt.Errorf("missing model events before generation %d", event.Generation)
+			}
+			snapshot = Apply(snapshot, event.Generation, event.Change)
+		default:
+			// unknown event types are added in a compatible way, ignoring them
+			continue
+		}
+		if err := update(snapshot); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected status reading %s: %s: %s", path, res.Status, strings.TrimSpace(string(body)))
+	}
+	return res, nil
+}
+
+// Apply builds the snapshot of generation from the former snapshot and the
+// change. snapshot is not changed.
+func Apply(snapshot *Snapshot, generation uint64, change *Change) *Snapshot {
+	hosts := make(map[string]Host, len(snapshot.Hosts))
+	for _, host := range snapshot.Hosts {
+		hosts[host.Hostname] = host
+	}
+	for _, host := range change.Hosts {
+		hosts[host.Hostname] = host
+	}
+	for _, hostname := range change.RemovedHosts {
+		delete(hosts, hostname)
+	}
+	backends := make(map[string]Backend, len(snapshot.Backends))
+	for _, backend := range snapshot.Backends {
+		backends[backend.ID] = backend
+	}
+	for _, backend := range change.Backends {
+		backends[backend.ID] = backend
+	}
+	for _, id := range change.RemovedBackends {
+		delete(backends, id)
+	}
+	applied := &Snapshot{
+		SchemaVersion: snapshot.SchemaVersion,
+		Generation:    generation,
+		Hosts:         make([]Host, 0, len(hosts)),
+		Backends:      make([]Backend, 0, len(backends)),
+	}
+	for _, host := range hosts {
+		applied.Hosts = append(applied.Hosts, host)
+	}
+	sort.Slice(applied.Hosts, func(i, j int) bool {
+		return applied.Hosts[i].Hostname < applied.Hosts[j].Hostname
+	})
+	for _, backend := range backends {
+		applied.Backends = append(applied.Backends, backend)
+	}
+	sort.Slice(applied.Backends, func(i, j int) bool {
+		return applied.Backends[i].ID < applied.Backends[j].ID
+	})
+	return applied
+}
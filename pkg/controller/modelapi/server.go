@@ -0,0 +1,204 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+const (
+	// SnapshotPath is the path of the current snapshot, in JSON format.
+	SnapshotPath = "/api/" + SchemaVersion + "/model"
+	// WatchPath is the path of the watch stream, one JSON encoded Event per line.
+	WatchPath = "/api/" + SchemaVersion + "/model/watch"
+
+	// events buffered per watcher, a watcher that falls behind
+	// is disconnected and should reconnect to read a new snapshot.
+	watchBuffer = 16
+)
+
+// NewServer creates a read-only API of the model applied by the syncs.
+func NewServer(log logr.Logger) *Server {
+	return &Server{
+		log:      log,
+		now:      time.Now,
+		watchers: map[chan *Event]struct{}{},
+	}
+}
+
+// Server publishes the model applied by the syncs, and serves its snapshots
+// and changes. Published snapshots are never changed, so readers do not need
+// to synchronize with the syncs.
+type Server struct {
+	log      logr.Logger
+	now      func() time.Time
+	mutex    sync.Mutex
+	snapshot *Snapshot
+	watchers map[chan *Event]struct{}
+}
+
+// Publish copies the hosts and backends of the model, and notifies the
+// watchers if they changed since the last publish. It should be called
+// after a sync, while the model is locked, so the copy is consistent.
+func (s *Server) Publish(hosts *hatypes.Hosts, backends *hatypes.Backends) {
+	snapshot := &Snapshot{
+		SchemaVersion: SchemaVersion,
+		Hosts:         buildHosts(hosts),
+		Backends:      buildBackends(backends),
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var change *Change
+	if s.snapshot != nil {
+		change = diff(s.snapshot, snapshot)
+		if change.IsEmpty() {
+			return
+		}
+		snapshot.Generation = s.snapshot.Generation
+	}
+	snapshot.Generation++
+	snapshot.Timestamp = s.now()
+	s.snapshot = snapshot
+	// watchers connected before the first publish start with its snapshot
+	event := snapshotEvent(snapshot)
+	if change != nil {
+		event = &Event{
+			SchemaVersion: SchemaVersion,
+			Type:          EventChange,
+			Generation:    snapshot.Generation,
+			Change:        change,
+		}
+	}
+	for watcher := range s.watchers {
+		select {
+		case watcher <- event:
+		default:
+			s.log.Info("disconnecting model watcher that fell behind", "generation", snapshot.Generation)
+			close(watcher)
+			delete(s.watchers, watcher)
+		}
+	}
+}
+
+// Snapshot returns the last published snapshot, nil if none was published yet.
+func (s *Server) Snapshot() *Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.snapshot
+}
+
+// watch registers a watcher, returning the snapshot the changes start from.
+func (s *Server) watch() (*Snapshot, chan *Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	watcher := make(chan *Event, watchBuffer)
+	s.watchers[watcher] = struct{}{}
+	return s.snapshot, watcher
+}
+
+func (s *Server) unwatch(watcher chan *Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.watchers[watcher]; found {
+		close(watcher)
+		delete(s.watchers, watcher)
+	}
+}
+
+// SnapshotHandler serves the last published snapshot.
+func (s *Server) SnapshotHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		snapshot := s.Snapshot()
+		if snapshot == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("model was not synced yet\n"))
+			return
+		}
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(fmt.Sprintf("error encoding model snapshot: %s\n", err)))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+// WatchHandler streams a snapshot event, followed by a change event on every
+// publish that changes the model. The first snapshot is sent as soon as the
+// model is synced.
+func (s *Server) WatchHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte("streaming is not supported\n"))
+			return
+		}
+		snapshot, watcher := s.watch()
+		defer s.unwatch(watcher)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		if snapshot != nil {
+			if enc.Encode(snapshotEvent(snapshot)) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+		for {
+			select {
+			case event, ok := <-watcher:
+				if !ok {
+					return
+				}
+				if enc.Encode(event) != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+func snapshotEvent(snapshot *Snapshot) *Event {
+	return &Event{
+		SchemaVersion: SchemaVersion,
+		Type:          EventSnapshot,
+		Generation:    snapshot.Generation,
+		Snapshot:      snapshot,
+	}
+}
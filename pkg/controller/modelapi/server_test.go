@@ -0,0 +1,187 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestModelAPI(t *testing.T) {
+	hosts := hatypes.CreateHosts()
+	backends := hatypes.CreateBackends(0)
+	back1 := backends.AcquireBackend("default", "app1", "8080")
+	back1.Server.InitialWeight = 1
+	back1.AcquireEndpoint("172.17.0.11", 8080, "default/app1-xxx")
+	back1.AddEmptyEndpoint()
+	back1.BalanceAlgorithm = "roundrobin"
+	back1.Server.CrtFilename = "/var/lib/haproxy/crt/client.pem"
+	back1.Server.CrtHash = "4d3f2a"
+	back1.CustomConfig = []string{"http-request set-header Authorization Bearer-xyz"}
+	back2 := backends.AcquireBackend("default", "app2", "8080")
+	back2.ModeTCP = true
+	host1 := hosts.AcquireHost("app1.local")
+	host1.AddPath(back1, "/", hatypes.MatchBegin)
+	host1.AddPath(back2, "/api", hatypes.MatchExact)
+	host1.TLS.TLSFilename = "/var/lib/haproxy/crt/app1.pem"
+	host1.TLS.TLSHash = "a1b2c3"
+	host1.TLS.TLSCommonName = "app1.local"
+	host1.TLS.TLSNotAfter = time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	hosts.AcquireHost("app2.local").AddPath(back2, "/", hatypes.MatchBegin)
+
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	server := NewServer(logr.Discard())
+	server.now = func() time.Time { return now }
+	mux := http.NewServeMux()
+	mux.Handle(SnapshotPath, server.SnapshotHandler())
+	mux.Handle(WatchPath, server.WatchHandler())
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+	client := NewClient(httpServer.URL, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := client.Snapshot(ctx); err == nil || !strings.Contains(err.Error(), "503 Service Unavailable: model was not synced yet") {
+		t.Errorf("expected service unavailable before the first publish, found: %v", err)
+	}
+
+	// watcher connected before the first publish
+	updates := make(chan *Snapshot, 4)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- client.Watch(ctx, func(snapshot *Snapshot) error {
+			updates <- snapshot
+			return nil
+		})
+	}()
+	waitWatchers := func(count int) {
+		for i := 0; i < 100; i++ {
+			server.mutex.Lock()
+			n := len(server.watchers)
+			server.mutex.Unlock()
+			if n == count {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expected %d watcher(s)", count)
+	}
+	readUpdate := func() *Snapshot {
+		select {
+		case snapshot := <-updates:
+			return snapshot
+		case err := <-watchErr:
+			t.Fatalf("watch finished: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting model update")
+		}
+		return nil
+	}
+	waitWatchers(1)
+
+	// generation 1
+	server.Publish(hosts, backends)
+	snapshot, err := client.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(snapshot)
+	expected := `{"schemaVersion":"v1","generation":1,"timestamp":"2026-10-01T00:00:00Z",` +
+		`"hosts":[` +
+		`{"hostname":"app1.local","paths":[{"path":"/api","match":"exact","backend":"default_app2_8080"},{"path":"/","match":"begin","backend":"default_app1_8080"}],"tls":{"commonName":"app1.local","notAfter":"2027-01-01T00:00:00Z"}},` +
+		`{"hostname":"app2.local","paths":[{"path":"/","match":"begin","backend":"default_app2_8080"}]}],` +
+		`"backends":[` +
+		`{"id":"default_app1_8080","namespace":"default","name":"app1","port":"8080","mode":"http","config":{"balanceAlgorithm":"roundrobin","timeout":{}},"endpoints":[{"name":"srv001","ip":"172.17.0.11","port":8080,"weight":1,"targetRef":"default/app1-xxx"}]},` +
+		`{"id":"default_app2_8080","namespace":"default","name":"app2","port":"8080","mode":"tcp","config":{"timeout":{}},"endpoints":[]}]}`
+	if string(data) != expected {
+		t.Errorf("snapshot differs\nexpected: %s\nactual:   %s", expected, string(data))
+	}
+	for _, redacted := range []string{"client.pem", "4d3f2a", "app1.pem", "a1b2c3", "Bearer"} {
+		if strings.Contains(string(data), redacted) {
+			t.Errorf("snapshot should not expose '%s'", redacted)
+		}
+	}
+	if watched := readUpdate(); !reflect.DeepEqual(watched, snapshot) {
+		t.Errorf("watched snapshot differs from the current one: %+v", watched)
+	}
+
+	// generation 2, applied from a change event
+	back1.AcquireEndpoint("172.17.0.12", 8080, "default/app1-yyy")
+	hosts.RemoveAll([]string{"app2.local"})
+	server.Publish(hosts, backends)
+	watched := readUpdate()
+	current := server.Snapshot()
+	if watched.Generation != 2 || current.Generation != 2 {
+		t.Errorf("expected generation 2, found %d (watched) and %d (current)", watched.Generation, current.Generation)
+	}
+	if !reflect.DeepEqual(watched.Hosts, current.Hosts) || !reflect.DeepEqual(watched.Backends, current.Backends) {
+		t.Errorf("applied change differs from the current snapshot\nwatched: %+v\ncurrent: %+v", watched, current)
+	}
+
+	// unchanged model does not create a new generation
+	server.Publish(hosts, backends)
+	if generation := server.Snapshot().Generation; generation != 2 {
+		t.Errorf("expected generation 2 after an unchanged publish, found %d", generation)
+	}
+
+	// watchers connected later start with the current snapshot
+	cancel()
+	<-watchErr
+	waitWatchers(0)
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		watchErr <- client.Watch(ctx, func(snapshot *Snapshot) error {
+			updates <- snapshot
+			return nil
+		})
+	}()
+	if watched := readUpdate(); !reflect.DeepEqual(watched, server.Snapshot()) {
+		t.Errorf("watched snapshot differs from the current one: %+v", watched)
+	}
+}
+
+func TestModelAPISlowWatcher(t *testing.T) {
+	hosts := hatypes.CreateHosts()
+	backends := hatypes.CreateBackends(0)
+	server := NewServer(logr.Discard())
+	server.Publish(hosts, backends)
+	_, watcher := server.watch()
+	backend := backends.AcquireBackend("default", "app", "8080")
+	for i := 0; i <= watchBuffer; i++ {
+		backend.AcquireEndpoint("172.17.0.11", 8000+i, "")
+		server.Publish(hosts, backends)
+	}
+	var count int
+	for range watcher {
+		count++
+	}
+	if count != watchBuffer {
+		t.Errorf("expected %d buffered events before the watcher is closed, found %d", watchBuffer, count)
+	}
+	server.unwatch(watcher)
+}
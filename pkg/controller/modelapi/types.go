@@ -0,0 +1,132 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modelapi
+
+import "time"
+
+// SchemaVersion is the version of the types of this file. Fields can be
+// added to a version, but they are never renamed, removed or changed in
+// meaning, so clients should ignore unknown fields. A breaking change
+// creates a new version, served in a distinct path.
+const SchemaVersion = "v1"
+
+// EventType ...
+type EventType string
+
+// ...
+const (
+	EventSnapshot = EventType("snapshot")
+	EventChange   = EventType("change")
+)
+
+// Event is a message of the watch stream. The first event of a stream is
+// always a snapshot, followed by one change event per generation.
+type Event struct {
+	SchemaVersion string    `json:"schemaVersion"`
+	Type          EventType `json:"type"`
+	Generation    uint64    `json:"generation"`
+	Snapshot      *Snapshot `json:"snapshot,omitempty"`
+	Change        *Change   `json:"change,omitempty"`
+}
+
+// Snapshot is the model applied by a sync. Generation increments by one
+// on every sync that changes the model.
+type Snapshot struct {
+	SchemaVersion string    `json:"schemaVersion"`
+	Generation    uint64    `json:"generation"`
+	Timestamp     time.Time `json:"timestamp"`
+	Hosts         []Host    `json:"hosts"`
+	Backends      []Backend `json:"backends"`
+}
+
+// Change lists the hosts and backends added or updated, with their new
+// content, and the ones removed, since the former generation.
+type Change struct {
+	Hosts           []Host    `json:"hosts,omitempty"`
+	RemovedHosts    []string  `json:"removedHosts,omitempty"`
+	Backends        []Backend `json:"backends,omitempty"`
+	RemovedBackends []string  `json:"removedBackends,omitempty"`
+}
+
+// IsEmpty ...
+func (c *Change) IsEmpty() bool {
+	return len(c.Hosts)+len(c.RemovedHosts)+len(c.Backends)+len(c.RemovedBackends) == 0
+}
+
+// Host ...
+type Host struct {
+	Hostname       string   `json:"hostname"`
+	Paths          []Path   `json:"paths"`
+	SSLPassthrough bool     `json:"sslPassthrough,omitempty"`
+	TLS            *HostTLS `json:"tls,omitempty"`
+}
+
+// Path is a request path of a host. Backend is the ID of the backend
+// that handles the path, empty if the path redirects elsewhere.
+type Path struct {
+	Path       string `json:"path"`
+	Match      string `json:"match"`
+	Backend    string `json:"backend,omitempty"`
+	RedirectTo string `json:"redirectTo,omitempty"`
+}
+
+// HostTLS describes the certificate of a host. Only public data of the
+// certificate is exposed, neither the key pair nor file names or hashes.
+type HostTLS struct {
+	CommonName string    `json:"commonName,omitempty"`
+	NotAfter   time.Time `json:"notAfter"`
+}
+
+// Backend ...
+type Backend struct {
+	ID        string        `json:"id"`
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Port      string        `json:"port"`
+	Mode      string        `json:"mode"`
+	Config    BackendConfig `json:"config"`
+	Endpoints []Endpoint    `json:"endpoints"`
+}
+
+// BackendConfig is the effective configuration of a backend, after the
+// configuration keys of all the sources are merged and validated.
+type BackendConfig struct {
+	BalanceAlgorithm string   `json:"balanceAlgorithm,omitempty"`
+	Fallback         string   `json:"fallback,omitempty"`
+	MaxConn          int      `json:"maxConn,omitempty"`
+	Protocol         string   `json:"protocol,omitempty"`
+	Secure           bool     `json:"secure,omitempty"`
+	SlowStart        string   `json:"slowStart,omitempty"`
+	Timeout          Timeouts `json:"timeout"`
+}
+
+// Timeouts ...
+type Timeouts struct {
+	Connect string `json:"connect,omitempty"`
+	Queue   string `json:"queue,omitempty"`
+	Server  string `json:"server,omitempty"`
+	Tunnel  string `json:"tunnel,omitempty"`
+}
+
+// Endpoint is a server of a backend. Draining endpoints have weight 0.
+type Endpoint struct {
+	Name      string `json:"name"`
+	IP        string `json:"ip"`
+	Port      int    `json:"port"`
+	Weight    int    `json:"weight"`
+	TargetRef string `json:"targetRef,omitempty"`
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// syncBarrier postpones the first haproxy update until all the informers
+// have synced and the settle delay has passed, so the first configuration
+// is not built from a partially filled cache.
+type syncBarrier struct {
+	log      logr.Logger
+	settle   time.Duration
+	retry    time.Duration
+	now      func() time.Time
+	synced   []informerSynced
+	mu       sync.Mutex
+	pending  []string
+	syncedAt time.Time
+	passed   bool
+	done     bool
+}
+
+type informerSynced struct {
+	name      string
+	hasSynced func() bool
+}
+
+func createSyncBarrier(log logr.Logger, settle time.Duration) *syncBarrier {
+	return &syncBarrier{
+		log:    log,
+		settle: settle,
+		retry:  time.Second,
+		now:    time.Now,
+	}
+}
+
+func (b *syncBarrier) addInformer(typ interface{}, hasSynced func() bool) {
+	name := strings.TrimPrefix(reflect.TypeOf(typ).String(), "*")
+	b.synced = append(b.synced, informerSynced{name: name, hasSynced: hasSynced})
+}
+
+// check returns how much time the caller should wait before trying
+// again, or zero if the barrier was already passed.
+func (b *syncBarrier) check() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.passed {
+		return 0
+	}
+	var pending []string
+	for _, informer := range b.synced {
+		if !informer.hasSynced() {
+			pending = append(pending, informer.name)
+		}
+	}
+	if len(pending) > 0 {
+		if !reflect.DeepEqual(pending, b.pending) {
+			b.log.Info("waiting informers to sync before the first update", "pending", pending)
+		}
+		b.pending = pending
+		return b.retry
+	}
+	now := b.now()
+	if b.syncedAt.IsZero() {
+		b.pending = nil
+		b.syncedAt = now
+		if b.settle > 0 {
+			b.log.Info("informers synced, waiting the initial sync delay", "delay", b.settle)
+		}
+	}
+	if wait := b.syncedAt.Add(b.settle).Sub(now); wait > 0 {
+		return wait
+	}
+	b.log.Info("initial sync barrier passed, starting the first update")
+	b.passed = true
+	return 0
+}
+
+// finish flags that the first update, started after the barrier has passed,
+// has finished.
+func (b *syncBarrier) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done = b.passed
+}
+
+// readyCheck reports the controller as not ready until the first update
+// has finished.
+func (b *syncBarrier) readyCheck() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return nil
+	}
+	if len(b.pending) > 0 {
+		return fmt.Errorf("waiting informers to sync: %s", strings.Join(b.pending, ","))
+	}
+	if !b.syncedAt.IsZero() && !b.passed {
+		return fmt.Errorf("waiting the initial sync delay")
+	}
+	return fmt.Errorf("waiting the first update")
+}
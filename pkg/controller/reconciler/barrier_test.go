@@ -0,0 +1,133 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networking "k8s.io/api/networking/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestSyncBarrier(t *testing.T) {
+	type informer struct {
+		typ    interface{}
+		syncAt time.Duration
+	}
+	testCases := map[string]struct {
+		informers []informer
+		settle    time.Duration
+		expected  time.Duration
+		ready     map[time.Duration]string
+	}{
+		"all synced": {
+			informers: []informer{
+				{&networking.Ingress{}, 0},
+				{&api.Service{}, 0},
+			},
+			expected: 0,
+		},
+		"staggered": {
+			informers: []informer{
+				{&networking.Ingress{}, 0},
+				{&api.Service{}, 500 * time.Millisecond},
+				{&discoveryv1.EndpointSlice{}, 3 * time.Second},
+				{&api.Secret{}, 1500 * time.Millisecond},
+				{&api.ConfigMap{}, 0},
+				{&api.Pod{}, 2 * time.Second},
+			},
+			expected: 3 * time.Second,
+			ready: map[time.Duration]string{
+				0:                       "waiting informers to sync: v1.Service,v1.EndpointSlice,v1.Secret,v1.Pod",
+				2500 * time.Millisecond: "waiting informers to sync: v1.EndpointSlice",
+			},
+		},
+		"staggered with settle delay": {
+			informers: []informer{
+				{&networking.Ingress{}, time.Second},
+				{&discoveryv1.EndpointSlice{}, 2 * time.Second},
+			},
+			settle:   5 * time.Second,
+			expected: 7 * time.Second,
+			ready: map[time.Duration]string{
+				500 * time.Millisecond:  "waiting informers to sync: v1.Ingress,v1.EndpointSlice",
+				1500 * time.Millisecond: "waiting informers to sync: v1.EndpointSlice",
+				4 * time.Second:         "waiting the initial sync delay",
+			},
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var now time.Duration
+			start := time.Now()
+			ctx := context.Background()
+			r := &IngressReconciler{
+				barrier:  createSyncBarrier(logr.Discard(), test.settle),
+				watchers: createWatchers(ctx, &config.Config{}, nil),
+				next:     func() time.Duration { return 0 },
+			}
+			r.barrier.now = func() time.Time { return start.Add(now) }
+			r.barrier.retry = 500 * time.Millisecond
+			var updates []time.Duration
+			r.update = func(ctx context.Context, changed *convtypes.ChangedObjects) {
+				updates = append(updates, now)
+			}
+			for _, inf := range test.informers {
+				syncAt := inf.syncAt
+				r.barrier.addInformer(inf.typ, func() bool { return now >= syncAt })
+			}
+			// the informers notify new objects every 100ms during the first
+			// second, from there Reconcile is only called by the requeues.
+			var requeueAt time.Duration
+			for now = 0; now <= 10*time.Second && len(updates) == 0; now += 100 * time.Millisecond {
+				if now <= time.Second || now >= requeueAt {
+					res, err := r.Reconcile(ctx, ctrl.Request{})
+					if err != nil {
+						t.Fatal(err)
+					}
+					requeueAt = now + res.RequeueAfter
+				}
+				if msg, found := test.ready[now]; found {
+					if err := r.barrier.readyCheck(); err == nil || err.Error() != msg {
+						t.Errorf("readiness differs at %s - expected: %s - actual: %v", now, msg, err)
+					}
+				}
+			}
+			if len(updates) != 1 || updates[0] != test.expected {
+				t.Errorf("initial updates differ - expected: [%s] - actual: %v", test.expected, updates)
+			}
+			if err := r.barrier.readyCheck(); err != nil {
+				t.Errorf("expected ready after the first update, found: %v", err)
+			}
+			// barrier passed, further events update haproxy right away
+			if _, err := r.Reconcile(ctx, ctrl.Request{}); err != nil {
+				t.Fatal(err)
+			}
+			if len(updates) != 2 {
+				t.Errorf("expected 2 updates, found %d", len(updates))
+			}
+		})
+	}
+}
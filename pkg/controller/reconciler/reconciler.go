@@ -18,16 +18,19 @@ package reconciler
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/services"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 )
 
 // IngressReconciler ...
@@ -36,13 +39,25 @@ type IngressReconciler struct {
 	Config   *config.Config
 	Services *services.Services
 	//
+	barrier  *syncBarrier
+	update   func(ctx context.Context, changed *convtypes.ChangedObjects)
+	next     func() time.Duration
 	watchers *watchers
 }
 
 // Reconcile ...
 func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	if wait := r.barrier.check(); wait > 0 {
+		// changes are kept in the watchers, the first update applies all of them
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
 	changed := r.watchers.getChangedObjects()
-	r.Services.ReconcileIngress(ctx, changed)
+	r.update(ctx, changed)
+	r.barrier.finish()
+	if wait := r.next(); wait > 0 {
+		// e.g. expiring path tombstones, removing their paths from the model
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
@@ -50,13 +65,16 @@ func (r *IngressReconciler) leaderChanged(ctx context.Context, isLeader bool) {
 	if isLeader && r.watchers.running() {
 		changed := r.watchers.getChangedObjects()
 		changed.NeedFullSync = true
-		r.Services.ReconcileIngress(ctx, changed)
+		r.update(ctx, changed)
 	}
 }
 
 // SetupWithManager ...
 func (r *IngressReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	r.watchers = createWatchers(ctx, r.Config, r.Services.GetIsValidResource())
+	r.barrier = createSyncBarrier(logr.FromContextOrDiscard(ctx).WithName("barrier"), r.Config.InitialSyncDelay)
+	r.update = r.Services.ReconcileIngress
+	r.next = r.Services.NextSyncAfter
 	opt := controller.Options{
 		LogConstructor: func(*reconcile.Request) logr.Logger { return logr.FromContextOrDiscard(ctx).WithName("reconciler") },
 		RateLimiter:    createRateLimiter(r.Config),
@@ -71,7 +89,13 @@ func (r *IngressReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manag
 		if err := c.Watch(handler.getSource(mgr.GetCache())); err != nil {
 			return err
 		}
+		informer, err := mgr.GetCache().GetInformer(ctx, handler.typ, cache.BlockUntilSynced(false))
+		if err != nil {
+			return err
+		}
+		r.barrier.addInformer(handler.typ, informer.HasSynced)
 	}
 	r.Services.LeaderChangedSubscriber(r.leaderChanged)
+	r.Services.InitialSyncCheck(r.barrier.readyCheck)
 	return mgr.Add(c)
 }
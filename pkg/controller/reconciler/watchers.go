@@ -206,7 +206,10 @@ func (w *watchers) handlersCore() []*hdlr {
 				predicate.Funcs{
 					CreateFunc: func(e event.CreateEvent) bool { return false },
 					UpdateFunc: func(e event.UpdateEvent) bool {
-						return e.ObjectOld.GetDeletionTimestamp() != e.ObjectNew.GetDeletionTimestamp()
+						// labels and annotations are used by pod-maintenance-key
+						return e.ObjectOld.GetDeletionTimestamp() != e.ObjectNew.GetDeletionTimestamp() ||
+							!reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) ||
+							!reflect.DeepEqual(e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations())
 					},
 				},
 			},
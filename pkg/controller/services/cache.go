@@ -21,10 +21,12 @@ import (
 	"crypto"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/go-logr/logr"
@@ -35,6 +37,7 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
@@ -42,10 +45,12 @@ import (
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
 )
 
-func createCacheFacade(ctx context.Context, client client.Client, config *config.Config, tracker convtypes.Tracker, sslCerts *SSL, dynconfig *convtypes.DynamicConfig, status svcStatusUpdateFnc) *c {
+func createCacheFacade(ctx context.Context, client client.Client, config *config.Config, tracker convtypes.Tracker, sslCerts *SSL, dynconfig *convtypes.DynamicConfig, recorder record.EventRecorder, status svcStatusUpdateFnc) *c {
 	return &c{
 		ctx:       ctx,
 		log:       logr.FromContextOrDiscard(ctx).WithName("cache"),
@@ -54,6 +59,7 @@ func createCacheFacade(ctx context.Context, client client.Client, config *config
 		tracker:   tracker,
 		sslCerts:  sslCerts,
 		dynconfig: dynconfig,
+		recorder:  recorder,
 		status:    status,
 	}
 }
@@ -66,6 +72,7 @@ type c struct {
 	tracker   convtypes.Tracker
 	sslCerts  *SSL
 	dynconfig *convtypes.DynamicConfig
+	recorder  record.EventRecorder
 	status    svcStatusUpdateFnc
 }
 
@@ -642,6 +649,24 @@ func (c *c) GetPasswdSecretContent(defaultNamespace, secretName string, track []
 	return data, nil
 }
 
+func (c *c) GetPasswdSecretMap(defaultNamespace, secretName string, track []convtypes.TrackingRef) (map[string][]byte, error) {
+	proto, content := getContentProtocol(secretName)
+	if proto != "secret" {
+		return nil, fmt.Errorf("unsupported protocol: %s", proto)
+	}
+	namespace, name, err := buildResourceName(defaultNamespace, "secret", content, c.dynconfig.CrossNamespaceSecretPasswd)
+	if err != nil {
+		return nil, err
+	}
+	c.tracker.TrackRefName(track, convtypes.ResourceSecret, namespace+"/"+name)
+	secret := api.Secret{}
+	err = c.client.Get(c.ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
 func (c *c) SwapChangedObjects() *convtypes.ChangedObjects {
 	// deprecated func
 	// converter is adapted to not call this facade
@@ -653,6 +678,27 @@ func (c *c) UpdateStatus(obj client.Object) {
 	c.status(obj)
 }
 
+func (c *c) NotifyIngressWarning(ingressName, reason, message string) {
+	ing, err := c.GetIngress(ingressName)
+	if err != nil {
+		c.log.Error(err, "cannot read ingress to report a warning event", "ingress", ingressName, "reason", reason)
+		return
+	}
+	c.recorder.Event(ing, api.EventTypeWarning, reason, message)
+}
+
+func (c *c) NotifyGlobalConfigEvent(eventType, reason, message string) {
+	if c.config.ConfigMapName == "" {
+		return
+	}
+	cm, err := c.GetConfigMap(c.config.ConfigMapName)
+	if err != nil {
+		c.log.Error(err, "cannot read global configmap to report an event", "configmap", c.config.ConfigMapName, "reason", reason)
+		return
+	}
+	c.recorder.Event(cm, eventType, reason, message)
+}
+
 //
 // Starting acme.Cache implementation
 //
@@ -777,3 +823,137 @@ func (c *c) SetTLSSecretContent(secretName string, pemCrt, pemKey []byte) error
 	}
 	return c.createOrUpdate(&secret)
 }
+
+// backendIngresses lists the ingress resources that use a backend. The
+// tracker is read, so the model should be locked.
+func (c *c) backendIngresses(backend string) []string {
+	return c.tracker.LinkedNames(convtypes.ResourceHABackend, backend, convtypes.ResourceIngress)
+}
+
+// notifyRetryBudget reports a retry budget exceeded by a backend on the
+// ingress resources ingNames, see backendIngresses.
+func (c *c) notifyRetryBudget(budget haproxy.RetryBudgetExceeded, ingNames []string) {
+	for _, ingName := range ingNames {
+		ing, err := c.GetIngress(ingName)
+		if err != nil {
+			c.log.Error(err, "cannot read ingress to report retry budget", "ingress", ingName)
+			continue
+		}
+		c.recorder.Eventf(ing, api.EventTypeWarning, "RetryBudgetExceeded",
+			"backend '%s' retried %d connections on %d requests, above the budget of %.1f%%",
+			budget.Backend, budget.Retries, budget.Requests, budget.Budget)
+	}
+}
+
+// pauseBlueGreen adds the paused annotation, with the weights in use, to the
+// ingress resources ingNames of the backend, so the converter keeps these
+// weights until the annotation is removed, see backendIngresses.
+func (c *c) pauseBlueGreen(pause haproxy.BlueGreenPause, ingNames []string) {
+	if len(c.config.AnnPrefix) == 0 {
+		c.log.Error(fmt.Errorf("annotation prefix is empty"), "cannot pause blue/green balance", "backend", pause.Backend)
+		return
+	}
+	annName := c.config.AnnPrefix[0] + "/" + ingtypes.BackBlueGreenPaused
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				annName: pause.Balance,
+			},
+		},
+	})
+	if err != nil {
+		c.log.Error(err, "cannot pause blue/green balance", "backend", pause.Backend)
+		return
+	}
+	for _, ingName := range ingNames {
+		ing, err := c.GetIngress(ingName)
+		if err != nil {
+			c.log.Error(err, "cannot read ingress to pause blue/green balance", "ingress", ingName)
+			continue
+		}
+		if ing.Annotations[annName] == pause.Balance {
+			continue
+		}
+		// patching a new object, so the one from the informer isn't changed
+		obj := &networking.Ingress{}
+		obj.Namespace = ing.Namespace
+		obj.Name = ing.Name
+		if err := c.client.Patch(c.ctx, obj, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			c.log.Error(err, "cannot pause blue/green balance", "ingress", ingName)
+			continue
+		}
+		c.recorder.Eventf(ing, api.EventTypeWarning, "BlueGreenPaused",
+			"blue/green balance of backend '%s' was paused: canary error rate %.1f%% is above the baseline rate %.1f%% plus %.1f%%, remove the '%s' annotation to resume",
+			pause.Backend, pause.CanaryRate, pause.BaselineRate, pause.Threshold, annName)
+	}
+}
+
+func (c *c) notifyRejectedBackend(rejected haproxy.RejectedBackend) {
+	for _, ingName := range c.tracker.LinkedNames(convtypes.ResourceHABackend, rejected.Backend, convtypes.ResourceIngress) {
+		ing, err := c.GetIngress(ingName)
+		if err != nil {
+			c.log.Error(err, "cannot read ingress to report rejected configuration", "ingress", ingName)
+			continue
+		}
+		c.recorder.Eventf(ing, api.EventTypeWarning, "ConfigRejected",
+			"configuration of backend '%s' was rejected by haproxy, rolled back to the last known good state: %s",
+			rejected.Backend, rejected.Error)
+	}
+}
+
+// unhealthyIngresses lists, in sorted order, the ingress resources whose every
+// referenced backend is in the unavailable list. Ingress resources that don't
+// reference any backend, eg only redirects, are never unhealthy.
+func (c *c) unhealthyIngresses(unavailable []string) []string {
+	down := make(map[string]bool, len(unavailable))
+	for _, backend := range unavailable {
+		down[backend] = true
+	}
+	checked := map[string]bool{}
+	var ingresses []string
+	for _, backend := range unavailable {
+		for _, ingName := range c.tracker.LinkedNames(convtypes.ResourceHABackend, backend, convtypes.ResourceIngress) {
+			if checked[ingName] {
+				continue
+			}
+			checked[ingName] = true
+			unhealthy := true
+			for _, ingBackend := range c.tracker.LinkedNames(convtypes.ResourceIngress, ingName, convtypes.ResourceHABackend) {
+				if !down[ingBackend] {
+					unhealthy = false
+					break
+				}
+			}
+			if unhealthy {
+				ingresses = append(ingresses, ingName)
+			}
+		}
+	}
+	sort.Strings(ingresses)
+	return ingresses
+}
+
+// implements acme.Cache
+func (c *c) SetTLSSecretFailure(secretName string, domains []string, err error) {
+	namespace, name, errKey := cache.SplitMetaNamespaceKey(secretName)
+	if errKey != nil {
+		return
+	}
+	ingList, errList := c.GetIngressList()
+	if errList != nil {
+		c.log.Error(errList, "cannot list ingress to report acme failure", "secret", secretName)
+		return
+	}
+	for _, ing := range ingList {
+		if ing.Namespace != namespace {
+			continue
+		}
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == name {
+				c.recorder.Eventf(ing, api.EventTypeWarning, "AcmeSigningFailed",
+					"error signing certificate of secret '%s' and domain(s) %s: %v", name, strings.Join(domains, ","), err)
+				break
+			}
+		}
+	}
+}
@@ -20,15 +20,19 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/acme"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/modelapi"
 	ctrlutils "github.com/jcmoraisjr/haproxy-ingress/pkg/controller/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
@@ -44,20 +48,28 @@ type Services struct {
 	legacylogger *lfactory
 	log          logr.Logger
 	//
-	acmeClient   *svcAcmeClient
-	acmeServer   *svcAcmeServer
-	cache        *c
-	converterOpt *convtypes.ConverterOptions
-	instance     haproxy.Instance
-	metrics      *metrics
-	modelMutex   sync.Mutex
-	reloadCount  int
-	reloadQueue  utils.Queue
-	svcleader    *svcLeader
-	svchealthz   *svcHealthz
-	svcstatus    *svcStatusUpdater
-	svcstatusing *svcStatusIng
-	updateCount  int
+	acmeClient    *svcAcmeClient
+	acmeServer    *svcAcmeServer
+	annUsage      *annotations.UsageCollector
+	cache         *c
+	converterOpt  *convtypes.ConverterOptions
+	initialSync   func() error
+	instance      haproxy.Instance
+	metrics       *metrics
+	modelAPI      *modelapi.Server
+	modelMutex    sync.Mutex
+	readyMutex    sync.Mutex
+	readyErr      error
+	reloadCount   int
+	reloadQueue   utils.Queue
+	svcleader     *svcLeader
+	svcepweights  *svcEndpointWeights
+	svchealthpush *svcHealthPush
+	svchealthz    *svcHealthz
+	svctombstones *svcPathTombstones
+	svcstatus     *svcStatusUpdater
+	svcstatusing  *svcStatusIng
+	updateCount   int
 }
 
 // SetupWithManager ...
@@ -65,14 +77,14 @@ func (s *Services) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error
 	s.legacylogger = initLogFactory(ctx)
 	s.log = logr.FromContextOrDiscard(ctx).WithName("services")
 	ctx = logr.NewContext(ctx, s.log)
-	err := s.setup(ctx)
+	err := s.setup(ctx, mgr.GetEventRecorderFor("haproxy-ingress"))
 	if err != nil {
 		return err
 	}
 	return s.withManager(mgr)
 }
 
-func (s *Services) setup(ctx context.Context) error {
+func (s *Services) setup(ctx context.Context, recorder record.EventRecorder) error {
 	cfg := s.Config
 	sslCerts := CreateSSLCerts(cfg)
 	fakeCrt, fakeCA, err := sslCerts.createFakeCertAndCA()
@@ -102,13 +114,37 @@ func (s *Services) setup(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	svchealthz, err := initSvcHealthz(ctx, cfg, metrics, s.acmeExternalCallCheck)
+	var annUsage *annotations.UsageCollector
+	if cfg.AnnUsage {
+		annUsage = annotations.NewUsageCollector(cfg.AnnUsageNamespace)
+	}
+	var annMigration *annotations.MigrationCollector
+	if cfg.MigrationReportHandler {
+		annMigration = annotations.NewMigrationCollector(cfg.AnnPrefix)
+	}
+	var internalSecret svcInternalSecretFnc
+	if cfg.InternalAuthSecret != "" {
+		internalSecret = s.readInternalAuthSecret
+	}
+	var modelAPI *modelapi.Server
+	if cfg.ModelAPI {
+		modelAPI = modelapi.NewServer(s.log.WithName("model-api"))
+	}
+	svchealthz, err := initSvcHealthz(ctx, cfg, metrics, s.acmeExternalCallCheck, s.readyCheck, s.simulateIngress, annUsage, annMigration, modelAPI, internalSecret)
 	if err != nil {
 		return err
 	}
 	svcstatus := initSvcStatusUpdater(ctx, s.Client)
-	cache := createCacheFacade(ctx, s.Client, cfg, tracker, sslCerts, dynConfig, svcstatus.update)
+	cache := createCacheFacade(ctx, s.Client, cfg, tracker, sslCerts, dynConfig, recorder, svcstatus.update)
 	svcstatusing := initSvcStatusIng(ctx, cfg, s.Client, cache, svcstatus.update)
+	var svcepweights *svcEndpointWeights
+	if cfg.ReportEpWeightsPeriod > 0 {
+		svcepweights = initSvcEndpointWeights(ctx, cfg, s.Client)
+	}
+	var svchealthpush *svcHealthPush
+	if cfg.HealthPushURL != "" {
+		svchealthpush = initSvcHealthPush(ctx, cfg, metrics)
+	}
 	var acmeClient *svcAcmeClient
 	var acmeServer *svcAcmeServer
 	var acmeSigner acme.Signer
@@ -126,15 +162,20 @@ func (s *Services) setup(ctx context.Context) error {
 		LocalFSPrefix:     cfg.LocalFSPrefix,
 		HAProxyCfgDir:     cfg.LocalFSPrefix + "/etc/haproxy",
 		HAProxyMapsDir:    cfg.DefaultDirMaps,
+		OrphanFilesDirs:   []string{cfg.DefaultDirCerts, cfg.DefaultDirMaps, cfg.LocalFSPrefix + "/etc/haproxy/errorfiles"},
+		OrphanFilesKeep:   []string{fakeCrt.Filename, fakeCA.Filename},
 		IsMasterWorker:    cfg.MasterWorker,
 		IsExternal:        cfg.MasterSocket != "",
 		MasterSocket:      masterSocket,
 		AdminSocket:       adminSocket,
 		AcmeSocket:        acmeSocket,
 		BackendShards:     cfg.BackendShards,
+		PartitionBackends: cfg.PartitionBackends,
 		Metrics:           metrics,
 		ReloadQueue:       reloadQueue,
 		ReloadStrategy:    cfg.ReloadStrategy,
+		ScalingSignalsMax: cfg.StatsScalingSignalsMax,
+		MaxConfigSize:     cfg.ModelMaxConfigSize,
 		MaxOldConfigFiles: cfg.MaxOldConfigFiles,
 		SortEndpointsBy:   cfg.SortEndpointsBy,
 		StopCh:            ctx.Done(),
@@ -144,10 +185,18 @@ func (s *Services) setup(ctx context.Context) error {
 		AcmeQueue:         acmeQueue,
 		LeaderElector:     acmeLeaderElector,
 	}
+	var haproxyVersion string
+	var haproxyFeatures []string
+	if !instanceOptions.IsExternal {
+		// external haproxy is not reachable from here, assume it is up to date
+		haproxyVersion = utils.HAProxyVersion()
+		haproxyFeatures = utils.HAProxyFeatures()
+	}
 	converterOptions := &convtypes.ConverterOptions{
 		Logger:           s.legacylogger.new("converter"),
 		Cache:            cache,
 		Tracker:          tracker,
+		Metrics:          metrics,
 		DynamicConfig:    dynConfig,
 		LocalFSPrefix:    cfg.LocalFSPrefix,
 		IsExternal:       instanceOptions.IsExternal,
@@ -155,6 +204,20 @@ func (s *Services) setup(ctx context.Context) error {
 		AdminSocket:      instanceOptions.AdminSocket,
 		AcmeSocket:       instanceOptions.AcmeSocket,
 		AnnotationPrefix: cfg.AnnPrefix,
+		AnnotationLimits: convtypes.AnnotationLimits{
+			MaxValueLength:  cfg.AnnMaxValueLength,
+			MaxCIDRs:        cfg.AnnMaxCIDRs,
+			MaxHeaders:      cfg.AnnMaxHeaders,
+			MaxRewritePaths: cfg.AnnMaxRewritePaths,
+			Truncate:        cfg.AnnLimitTruncate,
+		},
+		ModelLimits: convtypes.ModelLimits{
+			MaxIngresses:  cfg.ModelMaxIngresses,
+			MaxHosts:      cfg.ModelMaxHosts,
+			MaxBackends:   cfg.ModelMaxBackends,
+			MaxEndpoints:  cfg.ModelMaxEndpoints,
+			ClassPriority: cfg.ModelLimitClassPriority,
+		},
 		DefaultBackend:   cfg.DefaultService,
 		DefaultCrtSecret: cfg.DefaultSSLCertificate,
 		FakeCrtFile:      fakeCrt,
@@ -167,6 +230,9 @@ func (s *Services) setup(ctx context.Context) error {
 		HasGatewayV1:     cfg.HasGatewayV1,
 		HasTCPRouteA2:    cfg.HasTCPRouteA2,
 		EnableEPSlices:   cfg.EnableEndpointSliceAPI,
+		PodName:          cfg.PodName,
+		HAProxyVersion:   haproxyVersion,
+		HAProxyFeatures:  haproxyFeatures,
 	}
 	instance := haproxy.CreateInstance(s.legacylogger.new("haproxy"), instanceOptions)
 	if err := instance.ParseTemplates(); err != nil {
@@ -175,13 +241,37 @@ func (s *Services) setup(ctx context.Context) error {
 	s.acmeClient = acmeClient
 	s.acmeServer = acmeServer
 	s.cache = cache
+	if annUsage != nil {
+		converterOptions.AnnotationUsage = annUsage
+	}
+	s.annUsage = annUsage
+	if annMigration != nil {
+		converterOptions.AnnotationMigration = annMigration
+	}
+	var isLeader func() bool
+	if cfg.Election {
+		isLeader = svcleader.isLeader
+	}
+	converterOptions.IsLeader = isLeader
+	if cfg.HostOwnershipConfigMap != "" {
+		converterOptions.HostOwnership = convtypes.HostOwnership{
+			Owners:             initSvcHostOwners(ctx, cfg, s.Client, isLeader),
+			WildcardSubdomains: cfg.HostOwnershipWildcard,
+		}
+	}
+	svctombstones := initSvcPathTombstones(ctx, cfg, s.Client, isLeader)
+	converterOptions.PathTombstones = svctombstones
 	s.converterOpt = converterOptions
 	s.instance = instance
 	s.metrics = metrics
+	s.modelAPI = modelAPI
 	s.modelMutex = sync.Mutex{}
 	s.reloadQueue = reloadQueue
 	s.svcleader = svcleader
+	s.svcepweights = svcepweights
+	s.svchealthpush = svchealthpush
 	s.svchealthz = svchealthz
+	s.svctombstones = svctombstones
 	s.svcstatus = svcstatus
 	s.svcstatusing = svcstatusing
 	return nil
@@ -205,6 +295,11 @@ func (s *Services) withManager(mgr ctrl.Manager) error {
 				return err
 			}
 		}
+		if s.svcepweights != nil {
+			if err := s.svcleader.addRunnable(s.svcepweights); err != nil {
+				return err
+			}
+		}
 	}
 	if s.reloadQueue != nil {
 		if err := mgr.Add(&svcReloadQueue{
@@ -221,6 +316,19 @@ func (s *Services) withManager(mgr ctrl.Manager) error {
 			return err
 		}
 	}
+	if s.Config.StatsCollectBackPeriod > 0 {
+		if err := mgr.Add(&svcBackendStats{
+			calc:   s.calcBackendStats,
+			period: s.Config.StatsCollectBackPeriod,
+		}); err != nil {
+			return err
+		}
+	}
+	if s.svchealthpush != nil {
+		if err := mgr.Add(s.svchealthpush); err != nil {
+			return err
+		}
+	}
 	if s.acmeServer != nil {
 		if err := mgr.Add(s.acmeServer); err != nil {
 			return err
@@ -247,11 +355,29 @@ func (s *Services) LeaderChangedSubscriber(f SvcLeaderChangedFnc) {
 	s.svcleader.addSubscriber(f)
 }
 
+// InitialSyncCheck configures a check that reports the controller as not
+// ready while the first haproxy update has not finished.
+func (s *Services) InitialSyncCheck(check func() error) {
+	s.readyMutex.Lock()
+	defer s.readyMutex.Unlock()
+	s.initialSync = check
+}
+
 // GetIsValidResource ...
 func (s *Services) GetIsValidResource() IsValidResource {
 	return s.cache
 }
 
+// NextSyncAfter returns how long until the model should be synced again,
+// even if no resource changes, or zero if a sync is not needed.
+func (s *Services) NextSyncAfter() time.Duration {
+	next := s.svctombstones.nextExpire()
+	if reload := s.instance.NextReloadCheck(); reload > 0 && (next == 0 || reload < next) {
+		next = reload
+	}
+	return next
+}
+
 // ReconcileIngress ...
 func (s *Services) ReconcileIngress(ctx context.Context, changed *convtypes.ChangedObjects) {
 	s.modelMutex.Lock()
@@ -260,11 +386,27 @@ func (s *Services) ReconcileIngress(ctx context.Context, changed *convtypes.Chan
 	s.log.Info("starting haproxy update", "id", s.updateCount)
 	timer := utils.NewTimer(s.metrics.ControllerProcTime)
 	converters.NewConverter(timer, s.instance.Config(), changed, s.converterOpt).Sync()
+	if s.annUsage != nil {
+		s.metrics.setAnnotationUsage(s.annUsage.Usage())
+	}
 	if s.svcleader.isLeader() {
 		s.instance.AcmeUpdate()
 	}
 	s.instance.HAProxyUpdate(timer)
+	s.checkReload()
+	if s.modelAPI != nil {
+		s.modelAPI.Publish(s.instance.Config().Hosts(), s.instance.Config().Backends())
+	}
 	s.svcstatusing.changed(ctx, changed)
+	if s.Config.StatusWithdrawUnhealthy {
+		s.svcstatusing.healthChanged(s.cache.unhealthyIngresses(s.instance.UnavailableBackends()))
+	}
+	if s.svcepweights != nil {
+		s.svcepweights.changed(s.instance.Config().Backends().Items())
+	}
+	if s.svchealthpush != nil {
+		s.svchealthpush.changed(s.instance.UnavailableHosts())
+	}
 	s.log.WithValues("id", s.updateCount).WithValues(timer.AsValues("total")...).Info("finish haproxy update")
 }
 
@@ -287,6 +429,70 @@ func (s *Services) acmeCheck(source string) (count int, err error) {
 	return count, err
 }
 
+func (s *Services) calcBackendStats() {
+	// the admin socket is read without the model lock, so a slow
+	// response does not delay the synchronization of the model
+	s.modelMutex.Lock()
+	sample := s.instance.NewStatsSample()
+	s.modelMutex.Unlock()
+	sample.Read()
+
+	s.modelMutex.Lock()
+	budgets := s.instance.CalcBackendStats(sample)
+	budgetIngs := make([][]string, len(budgets))
+	for j, budget := range budgets {
+		budgetIngs[j] = s.cache.backendIngresses(budget.Backend)
+	}
+	var pauses []haproxy.BlueGreenPause
+	var pauseIngs [][]string
+	if s.svcleader.isLeader() {
+		// all the replicas have the same ingress resources to patch
+		pauses = s.instance.BlueGreenPauses()
+		pauseIngs = make([][]string, len(pauses))
+		for j, pause := range pauses {
+			pauseIngs[j] = s.cache.backendIngresses(pause.Backend)
+		}
+	}
+	if s.svchealthpush != nil {
+		// servers that went up or down due to health checks
+		s.svchealthpush.changed(s.instance.UnavailableHosts())
+	}
+	if s.Config.StatusWithdrawUnhealthy {
+		s.svcstatusing.healthChanged(s.cache.unhealthyIngresses(s.instance.UnavailableBackends()))
+	}
+	s.modelMutex.Unlock()
+
+	for j, budget := range budgets {
+		s.cache.notifyRetryBudget(budget, budgetIngs[j])
+	}
+	for j, pause := range pauses {
+		s.cache.pauseBlueGreen(pause, pauseIngs[j])
+	}
+}
+
+func (s *Services) checkReload() {
+	for _, rejected := range s.instance.RejectedBackends() {
+		s.cache.notifyRejectedBackend(rejected)
+	}
+	s.readyMutex.Lock()
+	defer s.readyMutex.Unlock()
+	s.readyErr = s.instance.LastReloadError()
+}
+
+// readyCheck reports haproxy as not ready while the first update has not
+// finished, or if it failed to reload and also failed to roll back to the
+// last known good configuration.
+func (s *Services) readyCheck() error {
+	s.readyMutex.Lock()
+	defer s.readyMutex.Unlock()
+	if s.initialSync != nil {
+		if err := s.initialSync(); err != nil {
+			return err
+		}
+	}
+	return s.readyErr
+}
+
 func (s *Services) reloadHAProxy(interface{}) {
 	s.modelMutex.Lock()
 	defer s.modelMutex.Unlock()
@@ -294,5 +500,6 @@ func (s *Services) reloadHAProxy(interface{}) {
 	s.log.Info("starting haproxy reload", "id", s.reloadCount)
 	timer := utils.NewTimer(s.metrics.ControllerProcTime)
 	s.instance.Reload(timer)
+	s.checkReload()
 	s.log.WithValues("id", s.reloadCount).WithValues(timer.AsValues("total")...).Info("finish haproxy reload")
 }
@@ -50,6 +50,15 @@ func CreateSSLCerts(c *config.Config) *SSL {
 // SSL ...
 type SSL struct {
 	c *config.Config
+	// dryRun validates the certificates without writing them to the disk
+	dryRun bool
+}
+
+func (s *SSL) writeFile(name string, data []byte) error {
+	if s.dryRun {
+		return nil
+	}
+	return os.WriteFile(name, data, 0600)
 }
 
 type sslCert struct {
@@ -188,7 +197,7 @@ func (s *SSL) buildCertFromCrtAndKey(fileName string, crt, key, ca []byte) (*ssl
 	}
 	crt = append(crt, '\n')
 	output := append(crt, key...)
-	if err := os.WriteFile(fileName, output, 0600); err != nil {
+	if err := s.writeFile(fileName, output); err != nil {
 		return nil, err
 	}
 	pemSHA1 := sha1.Sum(output)
@@ -212,12 +221,12 @@ func (s *SSL) buildCertFromCAAndCRL(caFileName, crlFileName string, ca, crl []by
 		if _, err := s.checkValidPEM(crl, "X509 CRL"); err != nil {
 			return nil, err
 		}
-		if err := os.WriteFile(crlFileName, crl, 0600); err != nil {
+		if err := s.writeFile(crlFileName, crl); err != nil {
 			return nil, err
 		}
 		pemSHA1 = sha1.Sum(append(ca, crl...))
 	}
-	if err := os.WriteFile(caFileName, ca, 0600); err != nil {
+	if err := s.writeFile(caFileName, ca); err != nil {
 		return nil, err
 	}
 	return &sslCert{
@@ -310,7 +319,7 @@ func (s *SSL) getDHParam(secret *api.Secret) (*sslCert, error) {
 		return nil, err
 	}
 	fileName := fmt.Sprintf("%s/%s.pem", s.c.DefaultDirDHParam, pemName)
-	err := os.WriteFile(fileName, dh, 0600)
+	err := s.writeFile(fileName, dh)
 	if err != nil {
 		return nil, err
 	}
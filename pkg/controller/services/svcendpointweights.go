@@ -0,0 +1,193 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// endpointWeightsAnn is the controller owned pod annotation which reports
+// the effective weight of the pod on every backend that uses it, eg:
+// `default_app_8080=50,default_app_8443=50`
+const endpointWeightsAnn = "haproxy-ingress.github.io/endpoint-weights"
+
+func initSvcEndpointWeights(ctx context.Context, config *config.Config, client client.Client) *svcEndpointWeights {
+	s := &svcEndpointWeights{
+		log: logr.FromContextOrDiscard(ctx).WithName("endpoint-weights"),
+		cli: client,
+	}
+	s.queue = utils.NewRateLimitingQueue(float32(1/config.ReportEpWeightsPeriod.Seconds()), s.report)
+	return s
+}
+
+type svcEndpointWeights struct {
+	log   logr.Logger
+	cli   client.Client
+	queue utils.Queue
+	mutex sync.Mutex
+	ctx   context.Context
+	run   bool
+	next  map[string]string
+	curr  map[string]string
+}
+
+func (s *svcEndpointWeights) Start(ctx context.Context) error {
+	// the former leader might have reported pods that are not used by any
+	// backend anymore, so the current state is read from the pods
+	curr, err := s.readReported(ctx)
+	if err != nil {
+		s.log.Error(err, "cannot read reported endpoint weights, only pods used by a backend will be updated")
+		curr = map[string]string{}
+	}
+	s.mutex.Lock()
+	s.ctx = ctx
+	s.run = true
+	s.curr = curr
+	s.mutex.Unlock()
+	s.queue.Notify()
+	s.queue.RunWithContext(ctx)
+	s.mutex.Lock()
+	s.run = false
+	s.mutex.Unlock()
+	return nil
+}
+
+// readReported returns the endpoint weights currently found in the pod
+// annotations, indexed by the pod namespace/name.
+func (s *svcEndpointWeights) readReported(ctx context.Context) (map[string]string, error) {
+	list := api.PodList{}
+	if err := s.cli.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	reported := map[string]string{}
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if value, found := pod.Annotations[endpointWeightsAnn]; found {
+			reported[pod.Namespace+"/"+pod.Name] = value
+		}
+	}
+	return reported, nil
+}
+
+// changed should be called after every sync, while the model is locked.
+func (s *svcEndpointWeights) changed(backends map[string]*hatypes.Backend) {
+	weights := buildEndpointWeights(backends)
+	s.mutex.Lock()
+	s.next = weights
+	run := s.run
+	s.mutex.Unlock()
+	if run {
+		s.queue.Notify()
+	}
+}
+
+func buildEndpointWeights(backends map[string]*hatypes.Backend) map[string]string {
+	podWeights := map[string][]string{}
+	for _, backend := range backends {
+		for _, ep := range backend.Endpoints {
+			// TargetRef is only assigned to endpoints backed by a pod
+			if ep.TargetRef == "" {
+				continue
+			}
+			podWeights[ep.TargetRef] = append(podWeights[ep.TargetRef], fmt.Sprintf("%s=%d", backend.ID, ep.Weight))
+		}
+	}
+	weights := make(map[string]string, len(podWeights))
+	for pod, w := range podWeights {
+		sort.Strings(w)
+		weights[pod] = strings.Join(w, ",")
+	}
+	return weights
+}
+
+func (s *svcEndpointWeights) report(interface{}) {
+	s.mutex.Lock()
+	ctx := s.ctx
+	next := s.next
+	s.mutex.Unlock()
+	var updated, removed, failed int
+	for pod, value := range next {
+		if s.curr[pod] == value {
+			continue
+		}
+		if err := s.patch(ctx, pod, &value); err != nil {
+			s.log.Error(err, "cannot update endpoint weights", "pod", pod)
+			failed++
+			continue
+		}
+		s.curr[pod] = value
+		updated++
+	}
+	for pod := range s.curr {
+		if _, found := next[pod]; found {
+			continue
+		}
+		// pod is not used by any backend anymore
+		if err := s.patch(ctx, pod, nil); err != nil {
+			s.log.Error(err, "cannot remove endpoint weights", "pod", pod)
+			failed++
+			continue
+		}
+		delete(s.curr, pod)
+		removed++
+	}
+	if updated+removed+failed > 0 {
+		s.log.V(2).Info("endpoint weights reported", "updated", updated, "removed", removed, "failed", failed)
+	}
+}
+
+func (s *svcEndpointWeights) patch(ctx context.Context, podName string, value *string) error {
+	ns, name, err := cache.SplitMetaNamespaceKey(podName)
+	if err != nil {
+		return err
+	}
+	// a nil value removes the annotation on a merge patch
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{
+				endpointWeightsAnn: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	pod := &api.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	err = s.cli.Patch(ctx, pod, client.RawPatch(types.MergePatchType, patch))
+	if value == nil && apierrors.IsNotFound(err) {
+		// pod is gone, nothing to clean up
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+)
+
+func TestEndpointWeightsNewLeader(t *testing.T) {
+	ctx := context.Background()
+	createPod := func(name, weights string) *api.Pod {
+		pod := &api.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+		if weights != "" {
+			pod.Annotations = map[string]string{endpointWeightsAnn: weights}
+		}
+		return pod
+	}
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		// reported by the former leader, not used anymore
+		createPod("app-1", "default_app_8080=100"),
+		createPod("app-2", "default_app_8080=50"),
+		createPod("app-3", ""),
+		createPod("other", ""),
+	).Build()
+
+	s := initSvcEndpointWeights(ctx, &config.Config{ReportEpWeightsPeriod: time.Second}, cli)
+	curr, err := s.readReported(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ctx = ctx
+	s.curr = curr
+	s.next = map[string]string{
+		"default/app-2": "default_app_8080=50",
+		"default/app-3": "default_app_8080=50",
+	}
+	s.report(nil)
+
+	expected := map[string]string{
+		"default/app-2": "default_app_8080=50",
+		"default/app-3": "default_app_8080=50",
+	}
+	if !reflect.DeepEqual(s.curr, expected) {
+		t.Errorf("current state differs\nexpected: %v\nactual:   %v", expected, s.curr)
+	}
+	for _, name := range []string{"app-1", "app-2", "app-3", "other"} {
+		pod := api.Pod{}
+		if err := cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &pod); err != nil {
+			t.Fatal(err)
+		}
+		if value := pod.Annotations[endpointWeightsAnn]; value != expected["default/"+name] {
+			t.Errorf("annotation of pod '%s' expected as '%s', but was '%s'", name, expected["default/"+name], value)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// healthPushSignatureHeader has the hex encoded HMAC-SHA256 of the payload,
+// signed with the key from --health-push-key-file, eg `sha256=<hex>`
+const healthPushSignatureHeader = "X-HAProxy-Ingress-Signature"
+
+func initSvcHealthPush(ctx context.Context, config *config.Config, metrics *metrics) *svcHealthPush {
+	s := &svcHealthPush{
+		log:        logr.FromContextOrDiscard(ctx).WithName("health-push"),
+		url:        config.HealthPushURL,
+		key:        config.HealthPushKey,
+		controller: config.PodName,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		metrics:    metrics,
+	}
+	s.queue = utils.NewFailureRateLimitingQueue(time.Second, 2*time.Minute, s.push)
+	return s
+}
+
+type svcHealthPush struct {
+	log        logr.Logger
+	url        string
+	key        []byte
+	controller string
+	client     *http.Client
+	metrics    *metrics
+	queue      utils.Queue
+	mutex      sync.Mutex
+	ctx        context.Context
+	run        bool
+	next       []haproxy.UnavailableHost
+	curr       []haproxy.UnavailableHost
+	sent       bool
+}
+
+type healthPushPayload struct {
+	Controller string           `json:"controller,omitempty"`
+	Timestamp  string           `json:"timestamp"`
+	Hosts      []healthPushHost `json:"hosts"`
+}
+
+type healthPushHost struct {
+	Hostname string   `json:"hostname"`
+	Backends []string `json:"backends"`
+}
+
+// NeedLeaderElection is false, every controller instance pushes the summary
+// of the hosts that its own haproxy cannot serve.
+func (s *svcHealthPush) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *svcHealthPush) Start(ctx context.Context) error {
+	s.mutex.Lock()
+	s.ctx = ctx
+	s.run = true
+	s.mutex.Unlock()
+	s.queue.Notify()
+	s.queue.RunWithContext(ctx)
+	s.mutex.Lock()
+	s.run = false
+	s.mutex.Unlock()
+	return nil
+}
+
+// changed should be called after every sync and every backend stats sample,
+// while the model is locked. The summary is only pushed if it changed since
+// the last successful push.
+func (s *svcHealthPush) changed(hosts []haproxy.UnavailableHost) {
+	s.mutex.Lock()
+	s.next = hosts
+	run := s.run
+	s.mutex.Unlock()
+	if run {
+		s.queue.Notify()
+	}
+}
+
+func (s *svcHealthPush) push(interface{}) error {
+	s.mutex.Lock()
+	ctx := s.ctx
+	next := s.next
+	skip := s.sent && reflect.DeepEqual(next, s.curr)
+	s.mutex.Unlock()
+	if skip {
+		return nil
+	}
+	if err := s.post(ctx, next); err != nil {
+		s.log.Error(err, "cannot push unavailable hosts")
+		s.metrics.IncHealthPushFailure()
+		return err
+	}
+	s.mutex.Lock()
+	s.curr = next
+	s.sent = true
+	s.mutex.Unlock()
+	s.log.V(2).Info("unavailable hosts pushed", "hosts", len(next))
+	return nil
+}
+
+func (s *svcHealthPush) post(ctx context.Context, unavailable []haproxy.UnavailableHost) error {
+	hosts := make([]healthPushHost, len(unavailable))
+	for i, host := range unavailable {
+		hosts[i] = healthPushHost{
+			Hostname: host.Hostname,
+			Backends: host.Backends,
+		}
+	}
+	body, err := json.Marshal(healthPushPayload{
+		Controller: s.controller,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Hosts:      hosts,
+	})
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(healthPushSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", res.Status)
+	}
+	return nil
+}
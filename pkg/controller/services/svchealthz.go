@@ -3,9 +3,11 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,13 +15,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apiserver/pkg/server/healthz"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/modelapi"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
 )
 
-func initSvcHealthz(ctx context.Context, cfg *config.Config, metrics *metrics, acmeCheck svcAcmeCheckFnc) (*svcHealthz, error) {
-	if cfg.HealthzAddr == "" {
+type svcReadyCheckFnc func() error
+
+func initSvcHealthz(ctx context.Context, cfg *config.Config, metrics *metrics, acmeCheck svcAcmeCheckFnc, readyCheck svcReadyCheckFnc, simulate svcSimulateFnc, annUsage *annotations.UsageCollector, annMigration *annotations.MigrationCollector, modelAPI *modelapi.Server, internalSecret svcInternalSecretFnc) (*svcHealthz, error) {
+	if cfg.HealthzAddr == "" && cfg.InternalAddr == "" {
 		return nil, nil
 	}
 	s := &svcHealthz{
@@ -27,43 +35,110 @@ func initSvcHealthz(ctx context.Context, cfg *config.Config, metrics *metrics, a
 		cfg: cfg,
 	}
 	mux := http.NewServeMux()
-	healthz.InstallPathHandler(mux, cfg.HealthzURL)
-	healthz.InstallPathHandler(mux, cfg.ReadyzURL)
-	mux.Handle("/", s.createRootHealthzHandler())
-	mux.Handle("/acme/check", s.createAcmeHandler(acmeCheck))
-	mux.Handle("/build", s.createBuildHandler(cfg))
+	// internal endpoints share the healthz server unless --internal-addr is configured
+	internalMux := mux
+	if cfg.InternalAddr != "" {
+		internalMux = http.NewServeMux()
+	}
+	healthzMux := mux
+	if cfg.InternalHealthz {
+		healthzMux = internalMux
+	}
+	healthz.InstallPathHandler(healthzMux, cfg.HealthzURL)
+	healthz.InstallPathHandler(healthzMux, cfg.ReadyzURL, healthz.NamedCheck("haproxy", func(*http.Request) error {
+		return readyCheck()
+	}))
+	moved := []string{"/acme/check", "/build"}
+	internalMux.Handle("/", s.createRootHealthzHandler())
+	internalMux.Handle("/acme/check", s.createAcmeHandler(acmeCheck))
+	internalMux.Handle("/build", s.createBuildHandler(cfg))
 	if cfg.Profiling {
-		mux.HandleFunc("/debug/pprof/", pprof.Index)
-		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		internalMux.HandleFunc("/debug/pprof/", pprof.Index)
+		internalMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		internalMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		internalMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		moved = append(moved, "/debug/pprof/")
+	}
+	if cfg.SimulateHandler {
+		internalMux.Handle("/debug/simulate", s.createSimulateHandler(simulate))
+		moved = append(moved, "/debug/simulate")
 	}
-	mhandler, err := s.createMetricsHandler(metrics)
-	if err != nil {
-		return nil, fmt.Errorf("error creating metrics handler: %v", err)
+	if cfg.AnnUsageHandler && annUsage != nil {
+		internalMux.Handle("/debug/annotations", s.createAnnUsageHandler(annUsage))
+		moved = append(moved, "/debug/annotations")
+	}
+	if cfg.MigrationReportHandler && annMigration != nil {
+		internalMux.Handle("/debug/migration", s.createMigrationHandler(annMigration))
+		moved = append(moved, "/debug/migration")
+	}
+	if cfg.ModelAPI && modelAPI != nil {
+		internalMux.Handle(modelapi.SnapshotPath, modelAPI.SnapshotHandler())
+		internalMux.Handle(modelapi.WatchPath, modelAPI.WatchHandler())
+		moved = append(moved, modelapi.SnapshotPath, modelapi.WatchPath)
+	}
+	if cfg.MetricsHandler {
+		mhandler, err := s.createMetricsHandler(metrics)
+		if err != nil {
+			return nil, fmt.Errorf("error creating metrics handler: %v", err)
+		}
+		internalMux.Handle("/metrics", mhandler)
+		moved = append(moved, "/metrics")
 	}
-	mux.Handle("/metrics", mhandler)
 	if cfg.StopHandler {
-		mux.Handle("/stop", s.createStopHandler())
+		internalMux.Handle("/stop", s.createStopHandler())
+		moved = append(moved, "/stop")
+	}
+	if cfg.InternalAddr != "" {
+		if cfg.InternalHealthz {
+			moved = append(moved, cfg.HealthzURL, cfg.ReadyzURL)
+		}
+		mux.Handle("/", s.createMovedHandler(moved))
+		access := &internalAccess{
+			log:    s.log.WithName("internal"),
+			allow:  cfg.InternalAllowCIDR,
+			secret: internalSecret,
+		}
+		s.internal = &http.Server{
+			Addr:    cfg.InternalAddr,
+			Handler: access.handler(internalMux),
+		}
 	}
-	s.server = &http.Server{
-		Addr:    cfg.HealthzAddr,
-		Handler: mux,
+	if cfg.HealthzAddr != "" {
+		s.server = &http.Server{
+			Addr:    cfg.HealthzAddr,
+			Handler: mux,
+		}
 	}
 	return s, nil
 }
 
 type svcHealthz struct {
-	log    logr.Logger
-	cfg    *config.Config
-	server *http.Server
+	log      logr.Logger
+	cfg      *config.Config
+	server   *http.Server
+	internal *http.Server
 }
 
 func (s *svcHealthz) createRootHealthzHandler() http.HandlerFunc {
-	var pprofDisabled, stopDisabled string
+	var pprofDisabled, simulateDisabled, annUsageDisabled, migrationDisabled, modelAPIDisabled, metricsDisabled, stopDisabled string
 	if !s.cfg.Profiling {
 		pprofDisabled = " (DISABLED)"
 	}
+	if !s.cfg.AnnUsageHandler {
+		annUsageDisabled = " (DISABLED)"
+	}
+	if !s.cfg.MigrationReportHandler {
+		migrationDisabled = " (DISABLED)"
+	}
+	if !s.cfg.ModelAPI {
+		modelAPIDisabled = " (DISABLED)"
+	}
+	if !s.cfg.MetricsHandler {
+		metricsDisabled = " (DISABLED)"
+	}
+	if !s.cfg.SimulateHandler {
+		simulateDisabled = " (DISABLED)"
+	}
 	if !s.cfg.StopHandler {
 		stopDisabled = " (DISABLED)"
 	}
@@ -71,9 +146,14 @@ func (s *svcHealthz) createRootHealthzHandler() http.HandlerFunc {
 	// TODO build a html index
 	contentType := "text/plain"
 	page := `/acme/check (only POST): starts a new check for certificates that need to be issued
+/api/v1/model : hosts, backends and endpoints applied by the last sync` + modelAPIDisabled + `
+/api/v1/model/watch : stream of the changes of the hosts, backends and endpoints` + modelAPIDisabled + `
 /build : build info
+/debug/annotations : configuration keys in use by ingress resources` + annUsageDisabled + `
+/debug/migration : annotation prefixes used by the configuration keys of ingress resources` + migrationDisabled + `
 /debug/pprof/ : pprof index` + pprofDisabled + `
-/metrics : HAProxy Ingress metrics in Prometheus format
+/debug/simulate (only POST): simulates the outcome of the ingress resource in the request body` + simulateDisabled + `
+/metrics : HAProxy Ingress metrics in Prometheus format` + metricsDisabled + `
 /stop : stops the controller process` + stopDisabled + `
 `
 
@@ -119,6 +199,99 @@ func (s *svcHealthz) createBuildHandler(cfg *config.Config) http.HandlerFunc {
 	}
 }
 
+func (s *svcHealthz) createAnnUsageHandler(annUsage *annotations.UsageCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(annUsage.Usage())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(fmt.Sprintf("error encoding annotation usage: %s\n", err)))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+func (s *svcHealthz) createMigrationHandler(annMigration *annotations.MigrationCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.Marshal(annMigration.Report())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(fmt.Sprintf("error encoding migration report: %s\n", err)))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}
+}
+
+const (
+	simulateMaxBodySize = 1 << 20
+	simulateTimeout     = 10 * time.Second
+)
+
+func (s *svcHealthz) createSimulateHandler(simulate svcSimulateFnc) http.HandlerFunc {
+	// a timed out simulation continues to run until it finishes,
+	// the lock ensures that only one of them runs at a time
+	var running sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			handle404(w)
+			return
+		}
+		if !running.TryLock() {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("another simulation is running, try again later\n"))
+			return
+		}
+		ing := &networking.Ingress{}
+		err := yaml.NewYAMLOrJSONDecoder(http.MaxBytesReader(w, r.Body, simulateMaxBodySize), 4096).Decode(ing)
+		if err == nil && ing.Kind != "Ingress" {
+			err = fmt.Errorf("expected an Ingress resource, found '%s'", ing.Kind)
+		}
+		if err != nil {
+			running.Unlock()
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("error reading ingress: %s\n", err)))
+			return
+		}
+		if ing.Namespace == "" {
+			ing.Namespace = "default"
+		}
+		type simulateOutput struct {
+			result *simulateResult
+			err    error
+		}
+		done := make(chan simulateOutput, 1)
+		go func() {
+			defer running.Unlock()
+			result, err := simulate(ing)
+			done <- simulateOutput{result: result, err: err}
+		}()
+		select {
+		case out := <-done:
+			if out.err != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(fmt.Sprintf("error simulating ingress: %s\n", out.err)))
+				return
+			}
+			data, err := json.Marshal(out.result)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(fmt.Sprintf("error encoding simulation result: %s\n", err)))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(data)
+		case <-time.After(simulateTimeout):
+			s.log.Info("ingress simulation timed out", "ingress", ing.Namespace+"/"+ing.Name, "timeout", simulateTimeout)
+			w.WriteHeader(http.StatusGatewayTimeout)
+			_, _ = w.Write([]byte("simulation timed out\n"))
+		case <-r.Context().Done():
+		}
+	}
+}
+
 func (s *svcHealthz) createMetricsHandler(metrics *metrics) (http.Handler, error) {
 	registry := prometheus.NewRegistry()
 	if err := registry.Register(collectors.NewGoCollector()); err != nil {
@@ -150,14 +323,27 @@ func handle404(w http.ResponseWriter) {
 }
 
 func (s *svcHealthz) Start(ctx context.Context) error {
-	s.log.Info("starting", "address", s.server.Addr)
-	go func() {
-		_ = s.server.ListenAndServe()
-	}()
+	var servers []*http.Server
+	if s.server != nil {
+		s.log.Info("starting", "address", s.server.Addr)
+		servers = append(servers, s.server)
+	}
+	if s.internal != nil {
+		s.log.Info("starting internal server", "address", s.internal.Addr)
+		servers = append(servers, s.internal)
+	}
+	for _, server := range servers {
+		go func(server *http.Server) {
+			_ = server.ListenAndServe()
+		}(server)
+	}
 	<-ctx.Done()
 	stopctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	err := s.server.Shutdown(stopctx)
+	var errs []error
+	for _, server := range servers {
+		errs = append(errs, server.Shutdown(stopctx))
+	}
 	s.log.Info("stopped")
-	return err
+	return errors.Join(errs...)
 }
@@ -0,0 +1,156 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+)
+
+// hostOwnersKey is the ConfigMap key with the owners, one `<hostname> <namespace>`
+// per line. Hostnames cannot be used as keys, wildcards aren't valid ConfigMap keys.
+const hostOwnersKey = "owners"
+
+func initSvcHostOwners(ctx context.Context, cfg *config.Config, cli client.Client, isLeader func() bool) *svcHostOwners {
+	return &svcHostOwners{
+		ctx:      ctx,
+		log:      logr.FromContextOrDiscard(ctx).WithName("host-owners"),
+		cli:      cli,
+		name:     cfg.HostOwnershipConfigMap,
+		isLeader: isLeader,
+	}
+}
+
+// svcHostOwners persists the namespace that owns each hostname in a ConfigMap,
+// so host ownership survives controller restarts. Owners are read from the
+// ConfigMap once, and written only by the leader, if leader election is enabled.
+type svcHostOwners struct {
+	ctx      context.Context
+	log      logr.Logger
+	cli      client.Client
+	name     string
+	isLeader func() bool
+	mutex    sync.Mutex
+	owners   map[string]string
+	dirty    bool
+}
+
+// Owners implements convtypes.HostOwners
+func (s *svcHostOwners) Owners() (map[string]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.owners == nil {
+		owners, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+		s.owners = owners
+	}
+	if s.dirty {
+		// retrying a failed or postponed write
+		s.persist()
+	}
+	return s.owners, nil
+}
+
+// Update implements convtypes.HostOwners
+func (s *svcHostOwners) Update(owners map[string]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.owners = owners
+	s.dirty = true
+	s.persist()
+}
+
+func (s *svcHostOwners) read() (map[string]string, error) {
+	cm := api.ConfigMap{}
+	if err := s.get(&cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("error reading host owners ConfigMap '%s': %w", s.name, err)
+	}
+	owners := map[string]string{}
+	for _, line := range strings.Split(cm.Data[hostOwnersKey], "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			owners[fields[0]] = fields[1]
+		} else if len(fields) > 0 {
+			s.log.Info("ignoring invalid host owner entry", "configmap", s.name, "entry", line)
+		}
+	}
+	return owners, nil
+}
+
+func (s *svcHostOwners) persist() {
+	if s.isLeader != nil && !s.isLeader() {
+		// the leader persists the same state, followers just keep it in memory
+		s.dirty = false
+		return
+	}
+	if err := s.write(); err != nil {
+		s.log.Error(err, "error writing host owners ConfigMap, will retry on the next sync", "configmap", s.name)
+		return
+	}
+	s.dirty = false
+}
+
+func (s *svcHostOwners) write() error {
+	lines := make([]string, 0, len(s.owners))
+	for hostname, namespace := range s.owners {
+		lines = append(lines, hostname+" "+namespace)
+	}
+	sort.Strings(lines)
+	data := strings.Join(lines, "\n")
+	cm := api.ConfigMap{}
+	err := s.get(&cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && cm.Data[hostOwnersKey] == data {
+		return nil
+	}
+	if err != nil {
+		cm.Namespace, cm.Name, _ = cache.SplitMetaNamespaceKey(s.name)
+		cm.Data = map[string]string{hostOwnersKey: data}
+		return s.cli.Create(s.ctx, &cm)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[hostOwnersKey] = data
+	return s.cli.Update(s.ctx, &cm)
+}
+
+func (s *svcHostOwners) get(cm *api.ConfigMap) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(s.name)
+	if err != nil {
+		return err
+	}
+	return s.cli.Get(s.ctx, types.NamespacedName{Namespace: namespace, Name: name}, cm)
+}
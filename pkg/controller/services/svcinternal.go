@@ -0,0 +1,181 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/bcrypt"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// svcInternalSecretFnc reads the secret with the users of the internal
+// server. version changes whenever the content of the secret changes.
+type svcInternalSecretFnc func() (version string, content []byte, err error)
+
+// readInternalAuthSecret reads the `auth` key of the secret configured
+// in --internal-auth-secret.
+func (s *Services) readInternalAuthSecret() (string, []byte, error) {
+	namespace, name, _ := strings.Cut(s.Config.InternalAuthSecret, "/")
+	secret := api.Secret{}
+	err := s.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, &secret)
+	if err != nil {
+		return "", nil, err
+	}
+	keyName := "auth"
+	data, found := secret.Data[keyName]
+	if !found {
+		return "", nil, fmt.Errorf("secret '%s/%s' does not have key '%s'", namespace, name, keyName)
+	}
+	return secret.ResourceVersion, data, nil
+}
+
+// internalAccess protects the controller-internal endpoints with an optional
+// client CIDR allowlist and an optional basic authentication. Any failure
+// reading the users, or a secret without valid users, denies all the requests.
+type internalAccess struct {
+	log      logr.Logger
+	allow    []*net.IPNet
+	secret   svcInternalSecretFnc
+	mutex    sync.Mutex
+	version  string
+	users    map[string]hatypes.User
+	usersErr error
+}
+
+func (a *internalAccess) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.allowed(r.RemoteAddr) {
+			a.log.V(1).Info("client not allowed on internal server", "remote", r.RemoteAddr, "path", r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("403 forbidden\n"))
+			return
+		}
+		if a.secret != nil {
+			users, err := a.readUsers()
+			if err != nil {
+				a.log.Error(err, "denying request to internal server, error reading users", "path", r.URL.Path)
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte("403 forbidden\n"))
+				return
+			}
+			username, passwd, ok := r.BasicAuth()
+			user, found := users[username]
+			if !ok || !found || !checkPasswd(user, passwd) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="haproxy-ingress"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte("401 unauthorized\n"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *internalAccess) allowed(remoteAddr string) bool {
+	if len(a.allow) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range a.allow {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// readUsers returns the users of the basic authentication. The content of
+// the secret is parsed again only if its version changes.
+func (a *internalAccess) readUsers() (map[string]hatypes.User, error) {
+	version, content, err := a.secret()
+	if err != nil {
+		return nil, err
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if version != "" && version == a.version {
+		return a.users, a.usersErr
+	}
+	userlist, errs := annotations.ExtractUserlist(string(content))
+	for _, err := range errs {
+		a.log.Info("ignoring malformed usr/passwd on internal server secret", "error", err.Error())
+	}
+	users := make(map[string]hatypes.User, len(userlist))
+	for _, user := range userlist {
+		if user.Encrypted && !isBcrypt(user.Passwd) {
+			a.log.Info("ignoring user of internal server secret, password should be plain text or a bcrypt hash", "user", user.Name)
+			continue
+		}
+		users[user.Name] = user
+	}
+	a.version = version
+	a.users = users
+	a.usersErr = nil
+	if len(users) == 0 {
+		a.users = nil
+		a.usersErr = fmt.Errorf("internal server secret does not have valid users")
+	}
+	return a.users, a.usersErr
+}
+
+func isBcrypt(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err == nil
+}
+
+func checkPasswd(user hatypes.User, passwd string) bool {
+	if user.Encrypted {
+		return bcrypt.CompareHashAndPassword([]byte(user.Passwd), []byte(passwd)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(user.Passwd), []byte(passwd)) == 1
+}
+
+// createMovedHandler answers with 404 the requests to the endpoints moved
+// to the internal server, logging a hint once per path.
+func (s *svcHealthz) createMovedHandler(moved []string) http.HandlerFunc {
+	var logged sync.Map
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range moved {
+			if r.URL.Path == path || (strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path)) {
+				if _, found := logged.LoadOrStore(path, true); !found {
+					s.log.Info("endpoint moved to the internal server, see --internal-addr", "path", r.URL.Path, "address", s.cfg.InternalAddr)
+				}
+				break
+			}
+		}
+		handle404(w)
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+)
+
+func TestInternalAccess(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := "usr1::secret1\nusr2:" + string(hash) + "\n"
+	testCases := map[string]struct {
+		allow      []string
+		secret     string
+		secretErr  error
+		noSecret   bool
+		remoteAddr string
+		user       string
+		passwd     string
+		expected   int
+	}{
+		"no restriction": {
+			noSecret:   true,
+			remoteAddr: "10.0.0.1:12345",
+			expected:   http.StatusOK,
+		},
+		"allowed ipv4": {
+			allow:      []string{"192.168.0.0/16", "10.0.0.0/8"},
+			noSecret:   true,
+			remoteAddr: "10.0.0.1:12345",
+			expected:   http.StatusOK,
+		},
+		"allowed ipv6": {
+			allow:      []string{"fd00::/8"},
+			noSecret:   true,
+			remoteAddr: "[fd00::1]:12345",
+			expected:   http.StatusOK,
+		},
+		"denied by allowlist": {
+			allow:      []string{"192.168.0.0/16"},
+			noSecret:   true,
+			remoteAddr: "10.0.0.1:12345",
+			expected:   http.StatusForbidden,
+		},
+		"allowlist checked before auth": {
+			allow:      []string{"192.168.0.0/16"},
+			secret:     users,
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr1",
+			passwd:     "secret1",
+			expected:   http.StatusForbidden,
+		},
+		"plain text password": {
+			secret:     users,
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr1",
+			passwd:     "secret1",
+			expected:   http.StatusOK,
+		},
+		"bcrypt password": {
+			allow:      []string{"10.0.0.0/8"},
+			secret:     users,
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr2",
+			passwd:     "secret2",
+			expected:   http.StatusOK,
+		},
+		"missing credentials": {
+			secret:     users,
+			remoteAddr: "10.0.0.1:12345",
+			expected:   http.StatusUnauthorized,
+		},
+		"wrong password": {
+			secret:     users,
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr1",
+			passwd:     "secret2",
+			expected:   http.StatusUnauthorized,
+		},
+		"unknown user": {
+			secret:     users,
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr3",
+			passwd:     "secret1",
+			expected:   http.StatusUnauthorized,
+		},
+		"unsupported hash is ignored": {
+			secret:     "usr1:$6$salt$hash\nusr2::secret2",
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr1",
+			passwd:     "$6$salt$hash",
+			expected:   http.StatusUnauthorized,
+		},
+		"missing secret denies all": {
+			secretErr:  fmt.Errorf("secret not found: 'default/internal'"),
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr1",
+			passwd:     "secret1",
+			expected:   http.StatusForbidden,
+		},
+		"secret without valid users denies all": {
+			secret:     "usr1\n:secret1\n",
+			remoteAddr: "10.0.0.1:12345",
+			user:       "usr1",
+			passwd:     "secret1",
+			expected:   http.StatusForbidden,
+		},
+		"empty secret denies all": {
+			remoteAddr: "10.0.0.1:12345",
+			expected:   http.StatusForbidden,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			access := &internalAccess{log: logr.Discard()}
+			for _, allow := range test.allow {
+				_, cidr, err := net.ParseCIDR(allow)
+				if err != nil {
+					t.Fatal(err)
+				}
+				access.allow = append(access.allow, cidr)
+			}
+			if !test.noSecret {
+				access.secret = func() (string, []byte, error) {
+					return "1", []byte(test.secret), test.secretErr
+				}
+			}
+			handler := access.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			// second request reads the users from the parsed secret
+			for i := 0; i < 2; i++ {
+				r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+				r.RemoteAddr = test.remoteAddr
+				if test.user != "" {
+					r.SetBasicAuth(test.user, test.passwd)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+				if w.Code != test.expected {
+					t.Errorf("status differs on request %d - expected: %d - actual: %d", i, test.expected, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestInternalServer(t *testing.T) {
+	testCases := map[string]struct {
+		internalAddr    string
+		internalHealthz bool
+		healthz         map[string]int
+		internal        map[string]int
+	}{
+		"shared server": {
+			healthz: map[string]int{
+				"/":               http.StatusOK,
+				"/healthz":        http.StatusOK,
+				"/build":          http.StatusOK,
+				"/debug/pprof/":   http.StatusOK,
+				"/debug/simulate": http.StatusNotFound,
+			},
+		},
+		"internal server": {
+			internalAddr: "127.0.0.1:10255",
+			healthz: map[string]int{
+				"/":                 http.StatusNotFound,
+				"/healthz":          http.StatusOK,
+				"/build":            http.StatusNotFound,
+				"/debug/pprof/":     http.StatusNotFound,
+				"/debug/pprof/heap": http.StatusNotFound,
+			},
+			internal: map[string]int{
+				"/":             http.StatusOK,
+				"/healthz":      http.StatusNotFound,
+				"/build":        http.StatusOK,
+				"/debug/pprof/": http.StatusOK,
+			},
+		},
+		"internal server with healthz": {
+			internalAddr:    "127.0.0.1:10255",
+			internalHealthz: true,
+			healthz: map[string]int{
+				"/healthz": http.StatusNotFound,
+				"/readyz":  http.StatusNotFound,
+				"/build":   http.StatusNotFound,
+			},
+			internal: map[string]int{
+				"/healthz": http.StatusOK,
+				"/build":   http.StatusOK,
+			},
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{
+				HealthzAddr:     ":10254",
+				HealthzURL:      "/healthz",
+				ReadyzURL:       "/readyz",
+				InternalAddr:    test.internalAddr,
+				InternalHealthz: test.internalHealthz,
+				Profiling:       true,
+			}
+			s, err := initSvcHealthz(context.Background(), cfg, createMetrics(nil), nil, func() error { return nil }, nil, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check := func(server *http.Server, expected map[string]int) {
+				for path, status := range expected {
+					w := httptest.NewRecorder()
+					server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+					if w.Code != status {
+						t.Errorf("status of '%s' on %s differs - expected: %d - actual: %d", path, server.Addr, status, w.Code)
+					}
+				}
+			}
+			check(s.server, test.healthz)
+			if test.internal != nil {
+				check(s.internal, test.internal)
+			} else if s.internal != nil {
+				t.Errorf("internal server should not be configured")
+			}
+		})
+	}
+}
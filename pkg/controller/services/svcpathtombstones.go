@@ -0,0 +1,186 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+// pathTombstonesKey is the ConfigMap key with the tombstones, a JSON object
+// whose keys are the namespace/name of the deleted ingress resources.
+const pathTombstonesKey = "tombstones"
+
+func initSvcPathTombstones(ctx context.Context, cfg *config.Config, cli client.Client, isLeader func() bool) *svcPathTombstones {
+	return &svcPathTombstones{
+		ctx:      ctx,
+		log:      logr.FromContextOrDiscard(ctx).WithName("path-tombstones"),
+		cli:      cli,
+		name:     cfg.PathTombstonesConfigMap,
+		isLeader: isLeader,
+	}
+}
+
+// svcPathTombstones persists the tombstones of the paths of deleted ingress
+// resources in a ConfigMap, so the graceful-path-removal window survives
+// controller restarts. Tombstones are read from the ConfigMap once, and written
+// only by the leader, if leader election is enabled. Tombstones are only kept
+// in memory if a ConfigMap is not configured.
+type svcPathTombstones struct {
+	ctx        context.Context
+	log        logr.Logger
+	cli        client.Client
+	name       string
+	isLeader   func() bool
+	mutex      sync.Mutex
+	tombstones map[string]*convtypes.PathTombstone
+	dirty      bool
+}
+
+// Tombstones implements convtypes.PathTombstones
+func (s *svcPathTombstones) Tombstones() (map[string]*convtypes.PathTombstone, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.tombstones == nil {
+		tombstones, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+		s.tombstones = tombstones
+	}
+	if s.dirty {
+		// retrying a failed or postponed write
+		s.persist()
+	}
+	return s.tombstones, nil
+}
+
+// Update implements convtypes.PathTombstones
+func (s *svcPathTombstones) Update(tombstones map[string]*convtypes.PathTombstone) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tombstones = tombstones
+	s.dirty = true
+	s.persist()
+}
+
+// nextExpire returns how long until the first tombstone expires, or zero if
+// there are no tombstones. The controller syncs again at that time, so the
+// paths of the expired tombstone are removed.
+func (s *svcPathTombstones) nextExpire() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var next time.Duration
+	for _, tombstone := range s.tombstones {
+		wait := time.Until(tombstone.Expire)
+		if wait < time.Second {
+			wait = time.Second
+		}
+		if next == 0 || wait < next {
+			next = wait
+		}
+	}
+	return next
+}
+
+func (s *svcPathTombstones) read() (map[string]*convtypes.PathTombstone, error) {
+	tombstones := map[string]*convtypes.PathTombstone{}
+	if s.name == "" {
+		return tombstones, nil
+	}
+	cm := api.ConfigMap{}
+	if err := s.get(&cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return tombstones, nil
+		}
+		return nil, fmt.Errorf("error reading path tombstones ConfigMap '%s': %w", s.name, err)
+	}
+	if data := cm.Data[pathTombstonesKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &tombstones); err != nil {
+			// a broken state should not prevent the controller from syncing
+			s.log.Error(err, "ignoring invalid path tombstones", "configmap", s.name)
+			return map[string]*convtypes.PathTombstone{}, nil
+		}
+	}
+	for name, tombstone := range tombstones {
+		if tombstone == nil || tombstone.Ingress == nil {
+			s.log.Info("ignoring invalid path tombstone entry", "configmap", s.name, "entry", name)
+			delete(tombstones, name)
+		}
+	}
+	return tombstones, nil
+}
+
+func (s *svcPathTombstones) persist() {
+	if s.name == "" || (s.isLeader != nil && !s.isLeader()) {
+		// the leader persists the same state, followers just keep it in memory
+		s.dirty = false
+		return
+	}
+	if err := s.write(); err != nil {
+		s.log.Error(err, "error writing path tombstones ConfigMap, will retry on the next sync", "configmap", s.name)
+		return
+	}
+	s.dirty = false
+}
+
+func (s *svcPathTombstones) write() error {
+	out, err := json.Marshal(s.tombstones)
+	if err != nil {
+		return err
+	}
+	data := string(out)
+	cm := api.ConfigMap{}
+	err = s.get(&cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && cm.Data[pathTombstonesKey] == data {
+		return nil
+	}
+	if err != nil {
+		cm.Namespace, cm.Name, _ = cache.SplitMetaNamespaceKey(s.name)
+		cm.Data = map[string]string{pathTombstonesKey: data}
+		return s.cli.Create(s.ctx, &cm)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[pathTombstonesKey] = data
+	return s.cli.Update(s.ctx, &cm)
+}
+
+func (s *svcPathTombstones) get(cm *api.ConfigMap) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(s.name)
+	if err != nil {
+		return err
+	}
+	return s.cli.Get(s.ctx, types.NamespacedName{Namespace: namespace, Name: name}, cm)
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestPathTombstonesRestart(t *testing.T) {
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	cfg := &config.Config{PathTombstonesConfigMap: "ingress/tombstones"}
+	removed := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tombstones := map[string]*convtypes.PathTombstone{
+		"default/ing1": {
+			Ingress: &networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ing1"},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{{Host: "app.local"}},
+				},
+			},
+			Removed: removed,
+			Expire:  removed.Add(time.Hour),
+		},
+	}
+
+	follower := initSvcPathTombstones(ctx, cfg, cli, func() bool { return false })
+	follower.Update(tombstones)
+	if current, _ := initSvcPathTombstones(ctx, cfg, cli, nil).Tombstones(); len(current) > 0 {
+		t.Errorf("expected tombstones not persisted by a follower, found %+v", current)
+	}
+
+	leader := initSvcPathTombstones(ctx, cfg, cli, func() bool { return true })
+	leader.Update(tombstones)
+	restarted := initSvcPathTombstones(ctx, cfg, cli, nil)
+	current, err := restarted.Tombstones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(current, tombstones) {
+		t.Errorf("tombstones differ after restart\nexpected: %+v\nactual:   %+v", tombstones["default/ing1"], current["default/ing1"])
+	}
+
+	memory := initSvcPathTombstones(ctx, &config.Config{}, cli, nil)
+	memory.Update(tombstones)
+	if current, _ := memory.Tombstones(); !reflect.DeepEqual(current, tombstones) {
+		t.Errorf("expected tombstones kept in memory, found %+v", current)
+	}
+}
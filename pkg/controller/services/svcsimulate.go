@@ -0,0 +1,294 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	networking "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+type svcSimulateFnc func(ing *networking.Ingress) (*simulateResult, error)
+
+// simulateResult is the outcome of an ingress simulation: the hosts and
+// backends that the ingress would add, remove or change, and the warnings
+// and errors that the ingress would add to the controller logs.
+type simulateResult struct {
+	Ingress  string       `json:"ingress"`
+	Hosts    simulateDiff `json:"hosts"`
+	Backends simulateDiff `json:"backends"`
+	Warnings []string     `json:"warnings"`
+	Errors   []string     `json:"errors"`
+}
+
+type simulateDiff struct {
+	Added   map[string]simulateItem              `json:"added,omitempty"`
+	Removed []string                             `json:"removed,omitempty"`
+	Changed map[string]map[string]simulateChange `json:"changed,omitempty"`
+}
+
+type simulateItem map[string]interface{}
+
+type simulateChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// simulateIngress evaluates the outcome of an ingress resource without
+// storing it in the cluster. The converters run twice on detached models,
+// without and with the submitted ingress, so the differences are caused by
+// the submitted ingress alone. The running configuration is never changed,
+// and the simulation does not depend on being the leader.
+func (s *Services) simulateIngress(ing *networking.Ingress) (*simulateResult, error) {
+	if !s.cache.IsValidIngress(ing) {
+		return nil, fmt.Errorf("ingress '%s/%s' is not managed by this controller", ing.Namespace, ing.Name)
+	}
+	// the dynamic config is updated by the converters, so a copy of
+	// the last applied one is used on every simulation
+	s.modelMutex.Lock()
+	dynconfig := *s.converterOpt.DynamicConfig
+	s.modelMutex.Unlock()
+	curConfig, curLogger := s.simulateConverters(nil, dynconfig)
+	newConfig, newLogger := s.simulateConverters(ing, dynconfig)
+	return &simulateResult{
+		Ingress:  ing.Namespace + "/" + ing.Name,
+		Hosts:    diffSimulateItems(simulateHosts(curConfig), simulateHosts(newConfig)),
+		Backends: diffSimulateItems(simulateBackends(curConfig), simulateBackends(newConfig)),
+		Warnings: diffSimulateLogging(curLogger.warnings, newLogger.warnings),
+		Errors:   diffSimulateLogging(curLogger.errors, newLogger.errors),
+	}, nil
+}
+
+func (s *Services) simulateConverters(ing *networking.Ingress, dynconfig convtypes.DynamicConfig) (haproxy.Config, *simulateLogger) {
+	sslCerts := *s.cache.sslCerts
+	sslCerts.dryRun = true
+	cache := *s.cache
+	cache.tracker = tracker.NewTracker()
+	cache.sslCerts = &sslCerts
+	cache.dynconfig = &dynconfig
+	cache.status = func(client.Object) {}
+	logger := &simulateLogger{}
+	options := *s.converterOpt
+	options.Logger = logger
+	options.Cache = &simulateCache{c: &cache, ing: ing, logger: logger}
+	options.Tracker = cache.tracker
+	options.Metrics = createMetrics(nil)
+	options.DynamicConfig = &dynconfig
+	if options.HostOwnership.Owners != nil {
+		options.HostOwnership.Owners = &simulateHostOwners{HostOwners: options.HostOwnership.Owners}
+	}
+	if options.PathTombstones != nil {
+		options.PathTombstones = &simulateTombstones{PathTombstones: options.PathTombstones}
+	}
+	config := haproxy.CreateDetachedConfig()
+	changed := &convtypes.ChangedObjects{NeedFullSync: true}
+	converters.NewConverter(utils.NewTimer(nil), config, changed, &options).Sync()
+	return config, logger
+}
+
+// simulateCache is a read only view of the controller cache, with the
+// simulated ingress overlaying the one of the cluster with the same name.
+type simulateCache struct {
+	*c
+	ing    *networking.Ingress
+	logger *simulateLogger
+}
+
+func (c *simulateCache) GetIngress(ingressName string) (*networking.Ingress, error) {
+	if c.ing != nil && ingressName == c.ing.Namespace+"/"+c.ing.Name {
+		return c.ing, nil
+	}
+	return c.c.GetIngress(ingressName)
+}
+
+func (c *simulateCache) GetIngressList() ([]*networking.Ingress, error) {
+	list, err := c.c.GetIngressList()
+	if err != nil || c.ing == nil {
+		return list, err
+	}
+	for i, ing := range list {
+		if ing.Namespace == c.ing.Namespace && ing.Name == c.ing.Name {
+			list[i] = c.ing
+			return list, nil
+		}
+	}
+	return append(list, c.ing), nil
+}
+
+func (c *simulateCache) UpdateStatus(obj client.Object) {}
+
+func (c *simulateCache) NotifyIngressWarning(ingressName, reason, message string) {
+	c.logger.Warn("event on ingress '%s': %s: %s", ingressName, reason, message)
+}
+
+func (c *simulateCache) NotifyGlobalConfigEvent(eventType, reason, message string) {}
+
+// simulateHostOwners reads the host owners of the controller, but does
+// not persist the hostnames claimed or released by the simulation.
+type simulateHostOwners struct {
+	convtypes.HostOwners
+}
+
+func (o *simulateHostOwners) Update(owners map[string]string) {}
+
+// simulateTombstones reads the path tombstones of the controller, but
+// does not persist the ones created or expired by the simulation.
+type simulateTombstones struct {
+	convtypes.PathTombstones
+}
+
+func (t *simulateTombstones) Update(tombstones map[string]*convtypes.PathTombstone) {}
+
+// simulateLogger collects the warnings and errors of a simulation.
+type simulateLogger struct {
+	warnings []string
+	errors   []string
+}
+
+func (l *simulateLogger) InfoV(v int, msg string, args ...interface{}) {}
+
+func (l *simulateLogger) Info(msg string, args ...interface{}) {}
+
+func (l *simulateLogger) Warn(msg string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(msg, args...))
+}
+
+func (l *simulateLogger) Error(msg string, args ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(msg, args...))
+}
+
+func (l *simulateLogger) Fatal(msg string, args ...interface{}) {
+	l.Error(msg, args...)
+}
+
+func simulateHosts(config haproxy.Config) map[string]simulateItem {
+	items := map[string]simulateItem{}
+	for name, host := range config.Hosts().Items() {
+		item := toSimulateItem(host)
+		paths := make([]string, len(host.Paths))
+		for i, path := range host.Paths {
+			target := path.Backend.ID
+			if path.RedirTo != "" {
+				target = "redirect " + path.RedirTo
+			}
+			paths[i] = simulateLink(path.Link) + " -> " + target
+		}
+		item["Paths"] = paths
+		items[name] = item
+	}
+	return items
+}
+
+func simulateBackends(config haproxy.Config) map[string]simulateItem {
+	items := map[string]simulateItem{}
+	for name, backend := range config.Backends().Items() {
+		item := toSimulateItem(backend)
+		paths := make([]simulateItem, len(backend.Paths))
+		for i, path := range backend.Paths {
+			// the host of the path is already reported in the hosts diff
+			pathItem := toSimulateItem(path)
+			delete(pathItem, "Host")
+			pathItem["Link"] = simulateLink(path.Link)
+			paths[i] = pathItem
+		}
+		item["Paths"] = paths
+		items[name] = item
+	}
+	return items
+}
+
+func simulateLink(link *hatypes.PathLink) string {
+	return fmt.Sprintf("%s%s (%s)", link.Hostname(), link.Path(), link.HAMatch())
+}
+
+// toSimulateItem converts a model object to a generic map, so its fields
+// can be compared and reported as json.
+func toSimulateItem(obj interface{}) simulateItem {
+	item := simulateItem{}
+	data, err := json.Marshal(obj)
+	if err == nil {
+		err = json.Unmarshal(data, &item)
+	}
+	if err != nil {
+		return simulateItem{"error": err.Error()}
+	}
+	return item
+}
+
+func diffSimulateItems(cur, new map[string]simulateItem) simulateDiff {
+	diff := simulateDiff{
+		Added:   map[string]simulateItem{},
+		Changed: map[string]map[string]simulateChange{},
+	}
+	for name, newItem := range new {
+		curItem, found := cur[name]
+		if !found {
+			diff.Added[name] = newItem
+			continue
+		}
+		changes := map[string]simulateChange{}
+		for field, newValue := range newItem {
+			if curValue := curItem[field]; !reflect.DeepEqual(curValue, newValue) {
+				changes[field] = simulateChange{Old: curValue, New: newValue}
+			}
+		}
+		for field, curValue := range curItem {
+			if _, found := newItem[field]; !found {
+				changes[field] = simulateChange{Old: curValue}
+			}
+		}
+		if len(changes) > 0 {
+			diff.Changed[name] = changes
+		}
+	}
+	for name := range cur {
+		if _, found := new[name]; !found {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// diffSimulateLogging returns the messages of new that are missing in cur,
+// so messages caused by other resources are not reported.
+func diffSimulateLogging(cur, new []string) []string {
+	count := map[string]int{}
+	for _, msg := range cur {
+		count[msg]++
+	}
+	diff := []string{}
+	for _, msg := range new {
+		if count[msg] > 0 {
+			count[msg]--
+		} else {
+			diff = append(diff, msg)
+		}
+	}
+	return diff
+}
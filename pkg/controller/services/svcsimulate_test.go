@@ -0,0 +1,195 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestSimulateIngress(t *testing.T) {
+	testCases := map[string]struct {
+		ing      *networking.Ingress
+		expected func(t *testing.T, res *simulateResult)
+	}{
+		"conflicting ingress": {
+			ing: buildSimulateIngress("ing2", nil),
+			expected: func(t *testing.T, res *simulateResult) {
+				if len(res.Hosts.Added)+len(res.Hosts.Removed)+len(res.Hosts.Changed) > 0 {
+					t.Errorf("expected no host changes, found %+v", res.Hosts)
+				}
+				if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "skipping redeclared path '/' type 'prefix' on Ingress 'default/ing2'") {
+					t.Errorf("expected a path conflict warning, found %v", res.Warnings)
+				}
+			},
+		},
+		"changed annotation": {
+			ing: buildSimulateIngress("ing1", map[string]string{"haproxy-ingress.github.io/balance-algorithm": "leastconn"}),
+			expected: func(t *testing.T, res *simulateResult) {
+				changes := res.Backends.Changed["default_app_8080"]
+				expected := map[string]simulateChange{"BalanceAlgorithm": {Old: "roundrobin", New: "leastconn"}}
+				if !reflect.DeepEqual(changes, expected) {
+					t.Errorf("backend changes differ - expected: %+v, actual: %+v", expected, changes)
+				}
+				if len(res.Warnings) > 0 {
+					t.Errorf("expected no warnings, found %v", res.Warnings)
+				}
+			},
+		},
+		"new host on a new namespace": {
+			ing: func() *networking.Ingress {
+				ing := buildSimulateIngress("ing2", nil)
+				ing.Namespace = "other"
+				ing.Spec.Rules[0].Host = "app2.local"
+				return ing
+			}(),
+			expected: func(t *testing.T, res *simulateResult) {
+				if _, found := res.Hosts.Added["app2.local"]; !found || len(res.Hosts.Added) != 1 {
+					t.Errorf("expected app2.local added, found %+v", res.Hosts.Added)
+				}
+			},
+		},
+		"new host": {
+			ing: func() *networking.Ingress {
+				ing := buildSimulateIngress("ing2", nil)
+				ing.Spec.Rules[0].Host = "app2.local"
+				return ing
+			}(),
+			expected: func(t *testing.T, res *simulateResult) {
+				if _, found := res.Hosts.Added["app2.local"]; !found || len(res.Hosts.Added) != 1 {
+					t.Errorf("expected app2.local added, found %+v", res.Hosts.Added)
+				}
+				if len(res.Backends.Added)+len(res.Backends.Removed) > 0 {
+					t.Errorf("expected no backends added or removed, found %+v", res.Backends)
+				}
+			},
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s := setupSimulate(t)
+			res, err := s.simulateIngress(test.ing)
+			if err != nil {
+				t.Fatalf("error simulating ingress: %v", err)
+			}
+			test.expected(t, res)
+			if _, err := json.Marshal(res); err != nil {
+				t.Errorf("error encoding simulation result: %v", err)
+			}
+			// hostnames claimed by the simulation should not be persisted
+			if owners := s.converterOpt.HostOwnership.Owners.(*simulateTestOwners); owners.updates > 0 {
+				t.Errorf("expected host owners not updated, found %d update(s)", owners.updates)
+			}
+			// the cluster state and the running configuration should not change
+			list := networking.IngressList{}
+			if err := s.Client.List(context.Background(), &list); err != nil || len(list.Items) != 1 {
+				t.Errorf("expected one ingress on the cluster, found %d: %v", len(list.Items), err)
+			}
+		})
+	}
+}
+
+func buildSimulateIngress(name string, ann map[string]string) *networking.Ingress {
+	pathType := networking.PathTypePrefix
+	return &networking.Ingress{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: ann},
+		Spec: networking.IngressSpec{
+			Rules: []networking.IngressRule{{
+				Host: "app.local",
+				IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{
+					Paths: []networking.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networking.IngressBackend{Service: &networking.IngressServiceBackend{
+							Name: "app",
+							Port: networking.ServiceBackendPort{Number: 8080},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+}
+
+func setupSimulate(t *testing.T) *Services {
+	objs := []client.Object{
+		buildSimulateIngress("ing1", nil),
+		&api.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec: api.ServiceSpec{Ports: []api.ServicePort{{
+				Name:       "http",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}}},
+		},
+		&api.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Subsets: []api.EndpointSubset{{
+				Addresses: []api.EndpointAddress{{IP: "172.17.0.11"}},
+				Ports:     []api.EndpointPort{{Name: "http", Port: 8080}},
+			}},
+		},
+	}
+	cli := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objs...).Build()
+	cfg := &config.Config{WatchIngressWithoutClass: true, IngressClass: "haproxy"}
+	tracker := tracker.NewTracker()
+	dynconfig := &convtypes.DynamicConfig{}
+	cache := createCacheFacade(context.Background(), cli, cfg, tracker, CreateSSLCerts(cfg), dynconfig, nil, func(client.Object) {})
+	return &Services{
+		Client: cli,
+		Config: cfg,
+		cache:  cache,
+		converterOpt: &convtypes.ConverterOptions{
+			Cache:            cache,
+			Tracker:          tracker,
+			DynamicConfig:    dynconfig,
+			AnnotationPrefix: []string{"haproxy-ingress.github.io"},
+			HostOwnership: convtypes.HostOwnership{
+				Owners: &simulateTestOwners{owners: map[string]string{"app.local": "default"}},
+			},
+		},
+	}
+}
+
+type simulateTestOwners struct {
+	owners  map[string]string
+	updates int
+}
+
+func (o *simulateTestOwners) Owners() (map[string]string, error) {
+	return o.owners, nil
+}
+
+func (o *simulateTestOwners) Update(owners map[string]string) {
+	o.updates++
+}
@@ -71,8 +71,15 @@ func (s *svcStatusUpdater) notify(item interface{}) error {
 	name := obj.GetName()
 	log := s.log.WithValues("kind", reflect.TypeOf(obj), "namespace", namespace, "name", name)
 
+	// the patch is the difference between the current status and the new one,
+	// so fields missing in the new status, eg a withdrawn address, are removed
 	from := obj.DeepCopyObject().(client.Object)
-	reflect.ValueOf(from).Elem().FieldByName("Status").SetZero()
+	status := reflect.ValueOf(from).Elem().FieldByName("Status")
+	status.SetZero()
+	curr := obj.DeepCopyObject().(client.Object)
+	if err := s.client.Get(s.ctx, client.ObjectKeyFromObject(obj), curr); err == nil {
+		status.Set(reflect.ValueOf(curr).Elem().FieldByName("Status"))
+	}
 	if err := s.client.Status().Patch(s.ctx, obj, client.MergeFrom(from)); err != nil {
 		log.Error(err, "cannot update status")
 		return err
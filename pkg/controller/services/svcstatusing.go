@@ -28,6 +28,7 @@ import (
 	"github.com/go-logr/logr"
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/cache"
@@ -38,25 +39,31 @@ import (
 )
 
 func initSvcStatusIng(ctx context.Context, config *config.Config, client client.Client, cache *c, status svcStatusUpdateFnc) *svcStatusIng {
+	var withdraw *statusWithdraw
+	if config.StatusWithdrawUnhealthy {
+		withdraw = newStatusWithdraw(config.StatusWithdrawDelay, config.StatusRestoreDelay)
+	}
 	return &svcStatusIng{
-		log:    logr.FromContextOrDiscard(ctx).WithName("status").WithName("ingress"),
-		cfg:    config,
-		cli:    client,
-		cache:  cache,
-		status: status,
-		period: time.Minute,
+		log:      logr.FromContextOrDiscard(ctx).WithName("status").WithName("ingress"),
+		cfg:      config,
+		cli:      client,
+		cache:    cache,
+		status:   status,
+		period:   time.Minute,
+		withdraw: withdraw,
 	}
 }
 
 type svcStatusIng struct {
-	log    logr.Logger
-	cfg    *config.Config
-	cli    client.Client
-	run    bool
-	cache  *c
-	status svcStatusUpdateFnc
-	period time.Duration
-	curr   []networking.IngressLoadBalancerIngress
+	log      logr.Logger
+	cfg      *config.Config
+	cli      client.Client
+	run      bool
+	cache    *c
+	status   svcStatusUpdateFnc
+	period   time.Duration
+	curr     []networking.IngressLoadBalancerIngress
+	withdraw *statusWithdraw
 }
 
 func (s *svcStatusIng) Start(ctx context.Context) error {
@@ -96,7 +103,7 @@ func (s *svcStatusIng) changed(ctx context.Context, changed *convtypes.ChangedOb
 				errs = append(errs, err)
 				continue
 			}
-			ing.Status.LoadBalancer.Ingress = s.curr
+			ing.Status.LoadBalancer.Ingress = s.lb(fullname, s.curr)
 			s.status(&ing)
 		} else if strings.HasSuffix(obj, svcPublSuffix) {
 			s.log.Info("publish service updated, updating all ingress status", "name", s.cfg.PublishService)
@@ -120,12 +127,130 @@ func (s *svcStatusIng) update(_ context.Context, lb []networking.IngressLoadBala
 		return err
 	}
 	for _, ing := range ingList {
-		ing.Status.LoadBalancer.Ingress = lb
+		ing.Status.LoadBalancer.Ingress = s.lb(ing.Namespace+"/"+ing.Name, lb)
 		s.status(ing)
 	}
 	return nil
 }
 
+// lb returns the addresses that should be used in the status of an ingress,
+// which is none if the ingress was withdrawn due to unavailable backends.
+func (s *svcStatusIng) lb(ingName string, lb []networking.IngressLoadBalancerIngress) []networking.IngressLoadBalancerIngress {
+	if s.withdraw != nil && s.withdraw.isWithdrawn(ingName) {
+		return nil
+	}
+	return lb
+}
+
+// healthChanged should be called after every sync and every backend stats
+// sample, while the model is locked, with the ingress resources whose every
+// backend is unavailable. Their status is withdrawn, or restored when the
+// backends are available again, after the damping delays.
+func (s *svcStatusIng) healthChanged(unhealthy []string) {
+	if !s.run || s.withdraw == nil {
+		return
+	}
+	withdraw, restore := s.withdraw.update(time.Now(), unhealthy)
+	for _, ingName := range withdraw {
+		ing, err := s.cache.GetIngress(ingName)
+		if err != nil {
+			s.log.Error(err, "cannot read ingress to withdraw its status", "ingress", ingName)
+			continue
+		}
+		s.log.Info("all backends are unavailable, withdrawing ingress status", "ingress", ingName)
+		ing.Status.LoadBalancer.Ingress = nil
+		s.status(ing)
+		s.cache.recorder.Eventf(ing, api.EventTypeWarning, "StatusWithdrawn",
+			"all backends are unavailable for at least %s, address removed from the status", s.cfg.StatusWithdrawDelay)
+	}
+	for _, ingName := range restore {
+		ing, err := s.cache.GetIngress(ingName)
+		if err != nil {
+			// ingress resources removed while withdrawn don't need to be restored
+			if !apierrors.IsNotFound(err) {
+				s.log.Error(err, "cannot read ingress to restore its status", "ingress", ingName)
+			}
+			continue
+		}
+		s.log.Info("backends are available again, restoring ingress status", "ingress", ingName)
+		ing.Status.LoadBalancer.Ingress = s.curr
+		s.status(ing)
+		s.cache.recorder.Eventf(ing, api.EventTypeNormal, "StatusRestored",
+			"backends are available for at least %s, address restored in the status", s.cfg.StatusRestoreDelay)
+	}
+}
+
+// statusWithdraw tracks the ingress resources whose every backend is
+// unavailable. Transitions are damped: an ingress should be continuously
+// unhealthy for withdrawDelay before it is withdrawn, and a withdrawn ingress
+// should be continuously healthy for restoreDelay before it is restored.
+type statusWithdraw struct {
+	withdrawDelay time.Duration
+	restoreDelay  time.Duration
+	// unhealthy has the time an ingress, not withdrawn yet, was found unhealthy
+	unhealthy map[string]time.Time
+	// withdrawn has the time a withdrawn ingress was found healthy, zero if it is still unhealthy
+	withdrawn map[string]time.Time
+}
+
+func newStatusWithdraw(withdrawDelay, restoreDelay time.Duration) *statusWithdraw {
+	return &statusWithdraw{
+		withdrawDelay: withdrawDelay,
+		restoreDelay:  restoreDelay,
+		unhealthy:     map[string]time.Time{},
+		withdrawn:     map[string]time.Time{},
+	}
+}
+
+// update receives the unhealthy ingress resources found at now, and returns
+// the ones that should be withdrawn and restored, both in sorted order.
+func (w *statusWithdraw) update(now time.Time, unhealthy []string) (withdraw, restore []string) {
+	isUnhealthy := make(map[string]bool, len(unhealthy))
+	for _, ingName := range unhealthy {
+		isUnhealthy[ingName] = true
+		if _, found := w.withdrawn[ingName]; found {
+			w.withdrawn[ingName] = time.Time{}
+			continue
+		}
+		since, found := w.unhealthy[ingName]
+		if !found {
+			since = now
+			w.unhealthy[ingName] = since
+		}
+		if now.Sub(since) >= w.withdrawDelay {
+			delete(w.unhealthy, ingName)
+			w.withdrawn[ingName] = time.Time{}
+			withdraw = append(withdraw, ingName)
+		}
+	}
+	for ingName := range w.unhealthy {
+		if !isUnhealthy[ingName] {
+			delete(w.unhealthy, ingName)
+		}
+	}
+	for ingName, since := range w.withdrawn {
+		if isUnhealthy[ingName] {
+			continue
+		}
+		if since.IsZero() {
+			since = now
+			w.withdrawn[ingName] = since
+		}
+		if now.Sub(since) >= w.restoreDelay {
+			delete(w.withdrawn, ingName)
+			restore = append(restore, ingName)
+		}
+	}
+	sort.Strings(withdraw)
+	sort.Strings(restore)
+	return withdraw, restore
+}
+
+func (w *statusWithdraw) isWithdrawn(ingName string) bool {
+	_, found := w.withdrawn[ingName]
+	return found
+}
+
 func (s *svcStatusIng) shutdown(ctx context.Context) {
 	if !s.cfg.UpdateStatusOnShutdown {
 		s.log.Info("skipping status update due to --update-status-on-shutdown=false")
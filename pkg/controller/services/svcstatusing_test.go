@@ -0,0 +1,153 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestStatusWithdraw(t *testing.T) {
+	type step struct {
+		elapsed   time.Duration
+		unhealthy []string
+		withdraw  []string
+		restore   []string
+		withdrawn []string
+	}
+	testCases := map[string]struct {
+		withdrawDelay time.Duration
+		restoreDelay  time.Duration
+		steps         []step
+	}{
+		"no delay": {
+			steps: []step{
+				{unhealthy: []string{"default/ing1"}, withdraw: []string{"default/ing1"}, withdrawn: []string{"default/ing1"}},
+				{elapsed: time.Second, unhealthy: []string{"default/ing1"}, withdrawn: []string{"default/ing1"}},
+				{elapsed: time.Second, restore: []string{"default/ing1"}},
+				{elapsed: time.Second},
+			},
+		},
+		"withdraw after the delay": {
+			withdrawDelay: time.Minute,
+			restoreDelay:  30 * time.Second,
+			steps: []step{
+				{unhealthy: []string{"default/ing1", "default/ing2"}},
+				{elapsed: 30 * time.Second, unhealthy: []string{"default/ing1", "default/ing2"}},
+				{elapsed: 30 * time.Second, unhealthy: []string{"default/ing1", "default/ing2"}, withdraw: []string{"default/ing1", "default/ing2"}, withdrawn: []string{"default/ing1", "default/ing2"}},
+				{elapsed: time.Minute, unhealthy: []string{"default/ing2"}, withdrawn: []string{"default/ing1", "default/ing2"}},
+				{elapsed: 30 * time.Second, unhealthy: []string{"default/ing2"}, restore: []string{"default/ing1"}, withdrawn: []string{"default/ing2"}},
+			},
+		},
+		"unhealthy flapping is not withdrawn": {
+			withdrawDelay: time.Minute,
+			steps: []step{
+				{unhealthy: []string{"default/ing1"}},
+				{elapsed: 40 * time.Second},
+				{elapsed: 10 * time.Second, unhealthy: []string{"default/ing1"}},
+				{elapsed: 40 * time.Second, unhealthy: []string{"default/ing1"}},
+				{elapsed: 40 * time.Second},
+			},
+		},
+		"healthy flapping is not restored": {
+			restoreDelay: time.Minute,
+			steps: []step{
+				{unhealthy: []string{"default/ing1"}, withdraw: []string{"default/ing1"}, withdrawn: []string{"default/ing1"}},
+				{elapsed: 10 * time.Second, withdrawn: []string{"default/ing1"}},
+				{elapsed: 40 * time.Second, unhealthy: []string{"default/ing1"}, withdrawn: []string{"default/ing1"}},
+				{elapsed: 10 * time.Second, withdrawn: []string{"default/ing1"}},
+				{elapsed: 40 * time.Second, withdrawn: []string{"default/ing1"}},
+				{elapsed: 20 * time.Second, restore: []string{"default/ing1"}},
+			},
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			w := newStatusWithdraw(test.withdrawDelay, test.restoreDelay)
+			now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+			for i, step := range test.steps {
+				now = now.Add(step.elapsed)
+				withdraw, restore := w.update(now, step.unhealthy)
+				if !reflect.DeepEqual(withdraw, step.withdraw) {
+					t.Errorf("withdraw differs on step %d - expected: %v, actual: %v", i, step.withdraw, withdraw)
+				}
+				if !reflect.DeepEqual(restore, step.restore) {
+					t.Errorf("restore differs on step %d - expected: %v, actual: %v", i, step.restore, restore)
+				}
+				var withdrawn []string
+				for _, ingName := range []string{"default/ing1", "default/ing2"} {
+					if w.isWithdrawn(ingName) {
+						withdrawn = append(withdrawn, ingName)
+					}
+				}
+				if !reflect.DeepEqual(withdrawn, step.withdrawn) {
+					t.Errorf("withdrawn differs on step %d - expected: %v, actual: %v", i, step.withdrawn, withdrawn)
+				}
+			}
+		})
+	}
+}
+
+func TestUnhealthyIngresses(t *testing.T) {
+	trk := tracker.NewTracker()
+	track := func(ingName string, backends ...string) {
+		for _, backend := range backends {
+			trk.TrackNames(convtypes.ResourceIngress, ingName, convtypes.ResourceHABackend, backend)
+		}
+	}
+	track("default/ing1", "default_app1_8080")
+	track("default/ing2", "default_app1_8080", "default_app2_8080")
+	track("default/ing3", "default_app2_8080", "default_app3_8080")
+	track("default/ing4", "default_app4_8080")
+	cache := &c{tracker: trk}
+	testCases := []struct {
+		unavailable []string
+		expected    []string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			unavailable: []string{"default_app1_8080"},
+			expected:    []string{"default/ing1"},
+		},
+		// 2
+		{
+			unavailable: []string{"default_app1_8080", "default_app2_8080"},
+			expected:    []string{"default/ing1", "default/ing2"},
+		},
+		// 3
+		{
+			unavailable: []string{"default_app2_8080", "default_app3_8080", "default_app4_8080"},
+			expected:    []string{"default/ing3", "default/ing4"},
+		},
+		// 4
+		{
+			unavailable: []string{"default_app5_8080"},
+		},
+	}
+	for i, test := range testCases {
+		actual := cache.unhealthyIngresses(test.unavailable)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("unhealthy ingress differ on %d - expected: %v, actual: %v", i, test.expected, actual)
+		}
+	}
+}
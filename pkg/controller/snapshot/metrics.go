@@ -0,0 +1,60 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// nopMetrics discards all the metrics, a snapshot replay is a one shot
+// process whose metrics are never scraped.
+type nopMetrics struct{}
+
+var _ types.Metrics = nopMetrics{}
+
+func (nopMetrics) HAProxyShowInfoResponseTime(duration time.Duration)                {}
+func (nopMetrics) HAProxyShowStatResponseTime(duration time.Duration)                {}
+func (nopMetrics) HAProxySetServerResponseTime(duration time.Duration)               {}
+func (nopMetrics) HAProxySetSSLCertResponseTime(duration time.Duration)              {}
+func (nopMetrics) AddSocketCmdAttempted(count int)                                   {}
+func (nopMetrics) AddSocketCmdSucceeded(count int)                                   {}
+func (nopMetrics) AddSocketCmdFailed(count int)                                      {}
+func (nopMetrics) ControllerProcTime(task string, duration time.Duration)            {}
+func (nopMetrics) AddIdleFactor(idle int)                                            {}
+func (nopMetrics) AddBackendRetries(backend string, count int)                       {}
+func (nopMetrics) AddBackendRedispatches(backend string, count int)                  {}
+func (nopMetrics) IncAnnotationLimit(limit string)                                   {}
+func (nopMetrics) IncAnnotationDropped(namespace, class, key string)                 {}
+func (nopMetrics) IncHostClassConflict(class string)                                 {}
+func (nopMetrics) IncHealthPushFailure()                                             {}
+func (nopMetrics) IncUpdateNoop()                                                    {}
+func (nopMetrics) IncUpdateDynamic()                                                 {}
+func (nopMetrics) IncUpdateFull()                                                    {}
+func (nopMetrics) UpdateSuccessful(success bool)                                     {}
+func (nopMetrics) SetCertExpireDate(domain, cn string, notAfter *time.Time)          {}
+func (nopMetrics) ClearCertExpire()                                                  {}
+func (nopMetrics) SetEndpointsMaintenance(backend string, count int)                 {}
+func (nopMetrics) SetACLListsSpilled(section string, count int)                      {}
+func (nopMetrics) SetModelLimitRejected(limit string, count int)                     {}
+func (nopMetrics) SetLintFindings(rule string, count int)                            {}
+func (nopMetrics) SetPeerSessions(status string, count int)                          {}
+func (nopMetrics) SetBackendLoad(namespace, service string, load *types.BackendLoad) {}
+func (nopMetrics) IncCertSigningMissing(domains string, success bool)                {}
+func (nopMetrics) IncCertSigningExpiring(domains string, success bool)               {}
+func (nopMetrics) IncCertSigningOutdated(domains string, success bool)               {}
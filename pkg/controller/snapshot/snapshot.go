@@ -0,0 +1,197 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// Run converts the resources found in the snapshot directory, renders the
+// haproxy configuration and writes it along with the warnings and errors
+// found in the conversion. The API server is never used, so the snapshot
+// can be replayed outside of the cluster it was taken from.
+func Run(opt *config.Options) error {
+	var rootFSPrefix string
+	if opt.LocalFSPrefix != "" {
+		rootFSPrefix = "rootfs"
+	}
+	return run(opt, rootFSPrefix, os.Stdout)
+}
+
+func run(opt *config.Options, rootFSPrefix string, stdout io.Writer) error {
+	tracker := tracker.NewTracker()
+	cache := helper_test.NewCacheMock(tracker)
+	ignored, err := cache.LoadSnapshot(opt.SnapshotDir)
+	if err != nil {
+		return fmt.Errorf("error loading snapshot: %w", err)
+	}
+
+	outputDir := opt.SnapshotOutput
+	if outputDir == "" {
+		outputDir, err = os.MkdirTemp("", "haproxy-ingress-snapshot-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(outputDir)
+	}
+	for _, dir := range []string{"maps", "errorfiles", "lua"} {
+		if err := os.MkdirAll(filepath.Join(outputDir, dir), 0755); err != nil {
+			return err
+		}
+	}
+
+	logger := &reportLogger{}
+	for _, obj := range ignored {
+		logger.Info("ignoring unsupported resource: %s", obj)
+	}
+	metrics := nopMetrics{}
+	instance := haproxy.CreateInstance(logger, haproxy.InstanceOptions{
+		RootFSPrefix:   rootFSPrefix,
+		HAProxyCfgDir:  outputDir,
+		HAProxyMapsDir: filepath.Join(outputDir, "maps"),
+		Metrics:        metrics,
+		// reloads are enqueued and never started
+		ReloadQueue:     utils.NewQueue(func(interface{}) {}),
+		SortEndpointsBy: opt.SortEndpointsBy,
+		MaxConfigSize:   opt.ModelMaxConfigSize,
+	})
+	if err := instance.ParseTemplates(); err != nil {
+		return fmt.Errorf("error parsing templates: %w", err)
+	}
+
+	changed := &convtypes.ChangedObjects{NeedFullSync: true}
+	if opt.ConfigMap != "" {
+		if cm, found := cache.ConfigMapList[opt.ConfigMap]; found {
+			changed.GlobalConfigMapDataNew = cm.Data
+		} else {
+			logger.Warn("global configmap '%s' not found in the snapshot", opt.ConfigMap)
+		}
+	}
+	if opt.TCPConfigMapName != "" {
+		if cm, found := cache.ConfigMapList[opt.TCPConfigMapName]; found {
+			changed.TCPConfigMapDataNew = cm.Data
+		} else {
+			logger.Warn("tcp services configmap '%s' not found in the snapshot", opt.TCPConfigMapName)
+		}
+	}
+	var annPrefix []string
+	for _, prefix := range strings.Split(opt.AnnPrefix, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			annPrefix = append(annPrefix, prefix)
+		}
+	}
+	converterOptions := &convtypes.ConverterOptions{
+		Logger:  logger,
+		Cache:   cache,
+		Tracker: tracker,
+		Metrics: metrics,
+		DynamicConfig: &convtypes.DynamicConfig{
+			StaticCrossNamespaceSecrets: opt.AllowCrossNamespace,
+		},
+		AnnotationPrefix: annPrefix,
+		AnnotationLimits: convtypes.AnnotationLimits{
+			MaxValueLength:  opt.AnnMaxValueLength,
+			MaxCIDRs:        opt.AnnMaxCIDRs,
+			MaxHeaders:      opt.AnnMaxHeaders,
+			MaxRewritePaths: opt.AnnMaxRewritePaths,
+			Truncate:        strings.ToLower(opt.AnnLimitPolicy) == "truncate",
+		},
+		ModelLimits: convtypes.ModelLimits{
+			MaxIngresses: opt.ModelMaxIngresses,
+			MaxHosts:     opt.ModelMaxHosts,
+			MaxBackends:  opt.ModelMaxBackends,
+			MaxEndpoints: opt.ModelMaxEndpoints,
+		},
+		DefaultBackend:   opt.DefaultSvc,
+		DefaultCrtSecret: opt.DefSSLCertificate,
+		FakeCrtFile:      convtypes.CrtFile{Filename: "/tls/_fake-default.pem", SHA1Hash: "fake"},
+		FakeCAFile:       convtypes.CrtFile{Filename: "/tls/_fake-ca.pem", SHA1Hash: "fake"},
+		DisableKeywords:  utils.Split(opt.DisableConfigKeywords, ","),
+		AcmeTrackTLSAnn:  opt.AcmeTrackTLSAnn,
+		EnableEPSlices:   opt.EnableEndpointSlicesAPI,
+	}
+	if strings.ToLower(opt.ModelLimitPolicy) == "class-priority" {
+		converterOptions.ModelLimits.ClassPriority = utils.Split(opt.ModelLimitClassPriority, ",")
+	}
+	timer := utils.NewTimer(nil)
+	converters.NewConverter(timer, instance.Config(), changed, converterOptions).Sync()
+	instance.HAProxyUpdate(timer)
+
+	report := logger.report()
+	if opt.SnapshotOutput != "" {
+		return os.WriteFile(filepath.Join(outputDir, "report.txt"), []byte(report), 0644)
+	}
+	cfg, err := os.ReadFile(filepath.Join(outputDir, "haproxy.cfg"))
+	if err != nil {
+		return err
+	}
+	if _, err := stdout.Write(cfg); err != nil {
+		return err
+	}
+	// the report is written as comments, so the output is still a valid config
+	for _, line := range strings.Split(strings.TrimSuffix(report, "\n"), "\n") {
+		if line != "" {
+			if _, err := fmt.Fprintf(stdout, "# %s\n", line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reportLogger collects the messages of the conversion, except the
+// verbose ones, in the order they were logged.
+type reportLogger struct {
+	lines []string
+}
+
+func (l *reportLogger) InfoV(v int, msg string, args ...interface{}) {}
+
+func (l *reportLogger) Info(msg string, args ...interface{}) {
+	l.lines = append(l.lines, "INFO "+fmt.Sprintf(msg, args...))
+}
+
+func (l *reportLogger) Warn(msg string, args ...interface{}) {
+	l.lines = append(l.lines, "WARN "+fmt.Sprintf(msg, args...))
+}
+
+func (l *reportLogger) Error(msg string, args ...interface{}) {
+	l.lines = append(l.lines, "ERROR "+fmt.Sprintf(msg, args...))
+}
+
+func (l *reportLogger) Fatal(msg string, args ...interface{}) {
+	l.lines = append(l.lines, "FATAL "+fmt.Sprintf(msg, args...))
+}
+
+func (l *reportLogger) report() string {
+	if len(l.lines) == 0 {
+		return ""
+	}
+	return strings.Join(l.lines, "\n") + "\n"
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
+)
+
+const rootFSPrefix = "../../../rootfs"
+
+func TestSnapshot(t *testing.T) {
+	opt := config.NewOptions()
+	opt.SnapshotDir = "testdata/cluster"
+	opt.SnapshotOutput = t.TempDir()
+	opt.ConfigMap = "ingress-controller/haproxy-ingress"
+	if err := run(opt, rootFSPrefix, nil); err != nil {
+		t.Fatalf("error running snapshot: %v", err)
+	}
+
+	cfg, err := os.ReadFile(filepath.Join(opt.SnapshotOutput, "haproxy.cfg"))
+	if err != nil {
+		t.Fatalf("error reading haproxy.cfg: %v", err)
+	}
+	for _, expected := range []string{
+		"timeout client          2m",
+		"backend app_echo_8080",
+		"cookie INGRESSCOOKIE insert indirect nocache httponly dynamic",
+		"server srv001 10.0.0.11:8080 weight 1",
+	} {
+		if !strings.Contains(string(cfg), expected) {
+			t.Errorf("expected '%s' in haproxy.cfg:\n%s", expected, cfg)
+		}
+	}
+	hostMap, err := os.ReadFile(filepath.Join(opt.SnapshotOutput, "maps", "_front_https_host__prefix.map"))
+	if err != nil {
+		t.Fatalf("error reading host map: %v", err)
+	}
+	if expected := "echo.example.com#/ app_echo_8080\n"; !strings.Contains(string(hostMap), expected) {
+		t.Errorf("expected '%s' in host map:\n%s", expected, hostMap)
+	}
+
+	report, err := os.ReadFile(filepath.Join(opt.SnapshotOutput, "report.txt"))
+	if err != nil {
+		t.Fatalf("error reading report: %v", err)
+	}
+	expReport := `INFO ignoring unsupported resource: Deployment echo
+WARN skipping backend config of Ingress 'app/missing': service not found: 'app/missing'
+WARN invalid affinity cookie strategy 'unknown' on Ingress 'app/echo', using 'insert' instead
+`
+	if string(report) != expReport {
+		t.Errorf("report differs - expected:\n%s\nactual:\n%s", expReport, report)
+	}
+}
+
+func TestSnapshotStdout(t *testing.T) {
+	opt := config.NewOptions()
+	opt.SnapshotDir = "testdata/cluster"
+	stdout := &bytes.Buffer{}
+	if err := run(opt, rootFSPrefix, stdout); err != nil {
+		t.Fatalf("error running snapshot: %v", err)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "\nbackend app_echo_8080\n") {
+		t.Errorf("expected haproxy.cfg in the output:\n%s", out)
+	}
+	expReport := `
+# INFO ignoring unsupported resource: Deployment echo
+# WARN skipping backend config of Ingress 'app/missing': service not found: 'app/missing'
+# WARN invalid affinity cookie strategy 'unknown' on Ingress 'app/echo', using 'insert' instead
+`
+	if !strings.HasSuffix(out, expReport) {
+		t.Errorf("expected the report as comments in the end of the output:\n%s", out)
+	}
+}
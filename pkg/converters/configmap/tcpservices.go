@@ -18,7 +18,6 @@ package configmap
 
 import (
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 
@@ -26,6 +25,7 @@ import (
 	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
 // TCPServicesConverter ...
@@ -52,8 +52,6 @@ type tcpSvcConverter struct {
 	enableEndpointSlicesAPI bool
 }
 
-var regexValidTime = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
-
 func (c *tcpSvcConverter) Sync() {
 	c.haproxy.TCPBackends().RemoveAll()
 
@@ -117,8 +115,8 @@ func (c *tcpSvcConverter) Sync() {
 		if svc.checkInt != "" {
 			if svc.checkInt == "-" {
 				checkInterval = ""
-			} else if regexValidTime.MatchString(svc.checkInt) {
-				checkInterval = svc.checkInt
+			} else if interval, err := utils.HAProxyTime(svc.checkInt); err == nil {
+				checkInterval = interval
 			} else {
 				c.logger.Warn(
 					"using default check interval '%s' due to an invalid time config on TCP service %d: %s",
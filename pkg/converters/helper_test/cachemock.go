@@ -26,6 +26,8 @@ import (
 	api "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 	gatewayv1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
@@ -51,18 +53,21 @@ type CacheMock struct {
 	GatewayList      []*gatewayv1.Gateway
 	GatewayClassList []*gatewayv1.GatewayClass
 	//
-	NsList        map[string]*api.Namespace
-	LookupList    map[string][]net.IP
-	EpList        map[string]*api.Endpoints
-	EpsList       map[string][]*discoveryv1.EndpointSlice
-	ConfigMapList map[string]*api.ConfigMap
-	TermPodList   map[string][]*api.Pod
-	PodList       map[string]*api.Pod
-	SecretTLSPath map[string]string
-	SecretCAPath  map[string]string
-	SecretCRLPath map[string]string
-	SecretDHPath  map[string]string
-	SecretContent SecretContent
+	NsList             map[string]*api.Namespace
+	LookupList         map[string][]net.IP
+	EpList             map[string]*api.Endpoints
+	EpsList            map[string][]*discoveryv1.EndpointSlice
+	ConfigMapList      map[string]*api.ConfigMap
+	TermPodList        map[string][]*api.Pod
+	PodList            map[string]*api.Pod
+	SecretTLSPath      map[string]string
+	SecretCAPath       map[string]string
+	SecretCRLPath      map[string]string
+	SecretDHPath       map[string]string
+	SecretContent      SecretContent
+	SecretLookups      int
+	Events             []string
+	GlobalConfigEvents []string
 }
 
 // NewCacheMock ...
@@ -82,6 +87,28 @@ func NewCacheMock(tracker convtypes.Tracker) *CacheMock {
 	}
 }
 
+// secretNotFound builds a not found error, like the one returned by the
+// k8s client, keeping the message used by the mock.
+func secretNotFound(fullname string) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    404,
+		Reason:  metav1.StatusReasonNotFound,
+		Message: fmt.Sprintf("secret not found: '%s'", fullname),
+	}}
+}
+
+// serviceNotFound builds a not found error, like the one returned by the
+// k8s client, keeping the message used by the mock.
+func serviceNotFound(name string) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    404,
+		Reason:  metav1.StatusReasonNotFound,
+		Message: fmt.Sprintf("service not found: '%s'", name),
+	}}
+}
+
 func (c *CacheMock) buildResourceName(defaultNamespace, resourceName string) string {
 	if defaultNamespace == "" || strings.Contains(resourceName, "/") {
 		return resourceName
@@ -172,7 +199,7 @@ func (c *CacheMock) GetService(defaultNamespace, serviceName string) (*api.Servi
 			}
 		}
 	}
-	return nil, fmt.Errorf("service not found: '%s'", serviceName)
+	return nil, serviceNotFound(serviceName)
 }
 
 // GetEndpoints ...
@@ -226,6 +253,7 @@ func (c *CacheMock) GetPodNamespace() string {
 func (c *CacheMock) GetTLSSecretPath(defaultNamespace, secretName string, track []convtypes.TrackingRef) (convtypes.CrtFile, error) {
 	fullname := c.buildResourceName(defaultNamespace, secretName)
 	c.tracker.TrackRefName(track, convtypes.ResourceSecret, fullname)
+	c.SecretLookups++
 	if path, found := c.SecretTLSPath[fullname]; found {
 		return convtypes.CrtFile{
 			Filename:   path,
@@ -234,20 +262,21 @@ func (c *CacheMock) GetTLSSecretPath(defaultNamespace, secretName string, track
 			NotAfter:   time.Now().AddDate(0, 0, 30),
 		}, nil
 	}
-	return convtypes.CrtFile{}, fmt.Errorf("secret not found: '%s'", fullname)
+	return convtypes.CrtFile{}, secretNotFound(fullname)
 }
 
 // GetCASecretPath ...
 func (c *CacheMock) GetCASecretPath(defaultNamespace, secretName string, track []convtypes.TrackingRef) (ca, crl convtypes.File, err error) {
 	fullname := c.buildResourceName(defaultNamespace, secretName)
 	c.tracker.TrackRefName(track, convtypes.ResourceSecret, fullname)
+	c.SecretLookups++
 	if path, found := c.SecretCAPath[fullname]; found {
 		ca = convtypes.File{
 			Filename: path,
 			SHA1Hash: fmt.Sprintf("%x", sha1.Sum([]byte(path))),
 		}
 	} else {
-		return ca, crl, fmt.Errorf("secret not found: '%s'", fullname)
+		return ca, crl, secretNotFound(fullname)
 	}
 	if path, found := c.SecretCRLPath[fullname]; found {
 		crl = convtypes.File{
@@ -274,6 +303,7 @@ func (c *CacheMock) GetDHSecretPath(defaultNamespace, secretName string) (convty
 func (c *CacheMock) GetPasswdSecretContent(defaultNamespace, secretName string, track []convtypes.TrackingRef) ([]byte, error) {
 	fullname := c.buildResourceName(defaultNamespace, secretName)
 	c.tracker.TrackRefName(track, convtypes.ResourceSecret, fullname)
+	c.SecretLookups++
 	if content, found := c.SecretContent[fullname]; found {
 		keyName := "auth"
 		if val, found := content[keyName]; found {
@@ -281,12 +311,33 @@ func (c *CacheMock) GetPasswdSecretContent(defaultNamespace, secretName string,
 		}
 		return nil, fmt.Errorf("secret '%s' does not have file/key '%s'", fullname, keyName)
 	}
-	return nil, fmt.Errorf("secret not found: '%s'", fullname)
+	return nil, secretNotFound(fullname)
+}
+
+// GetPasswdSecretMap ...
+func (c *CacheMock) GetPasswdSecretMap(defaultNamespace, secretName string, track []convtypes.TrackingRef) (map[string][]byte, error) {
+	fullname := c.buildResourceName(defaultNamespace, secretName)
+	c.tracker.TrackRefName(track, convtypes.ResourceSecret, fullname)
+	c.SecretLookups++
+	if content, found := c.SecretContent[fullname]; found {
+		return content, nil
+	}
+	return nil, secretNotFound(fullname)
 }
 
 // UpdateStatus ...
 func (c *CacheMock) UpdateStatus(client.Object) {}
 
+// NotifyIngressWarning ...
+func (c *CacheMock) NotifyIngressWarning(ingressName, reason, message string) {
+	c.Events = append(c.Events, fmt.Sprintf("Warning %s %s: %s", reason, ingressName, message))
+}
+
+// NotifyGlobalConfigEvent ...
+func (c *CacheMock) NotifyGlobalConfigEvent(eventType, reason, message string) {
+	c.GlobalConfigEvents = append(c.GlobalConfigEvents, fmt.Sprintf("%s %s: %s", eventType, reason, message))
+}
+
 // SwapChangedObjects ...
 func (c *CacheMock) SwapChangedObjects() *convtypes.ChangedObjects {
 	changed := c.Changed
@@ -60,6 +60,7 @@ type (
 		Match     string        `yaml:",omitempty"`
 		Headers   []headersMock `yaml:",omitempty"`
 		BackendID string        `yaml:"backend"`
+		RedirTo   string        `yaml:"redirto,omitempty"`
 	}
 	headersMock struct {
 		Name  string
@@ -147,7 +148,7 @@ func marshalHosts(hafronts ...*hatypes.Host) []hostMock {
 					Value: h.Value,
 				})
 			}
-			paths = append(paths, pathMock{Path: p.Path(), Match: match, Headers: hmock, BackendID: p.Backend.ID})
+			paths = append(paths, pathMock{Path: p.Path(), Match: match, Headers: hmock, BackendID: p.Backend.ID, RedirTo: p.RedirTo})
 		}
 		hosts = append(hosts, hostMock{
 			Hostname:     f.Hostname,
@@ -0,0 +1,191 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// LoadSnapshot reads the yaml and json files of dir, e.g. the output of
+// `kubectl get -o yaml`, and adds the resources found to the cache. Lists
+// and multi document files are supported. Secrets are expected to have
+// their data redacted, so they are added as file paths based on the
+// secret name and keys, except the ones used as passwd files, which have
+// their content added as is. The names of the objects that the cache does
+// not store, e.g. deployments, are returned.
+func (c *CacheMock) LoadSnapshot(dir string) (ignored []string, err error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		switch filepath.Ext(file.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !file.IsDir() {
+				names = append(names, file.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", name, err)
+			}
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			skipped, err := c.addSnapshotObject(doc)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding %s: %w", name, err)
+			}
+			ignored = append(ignored, skipped...)
+		}
+	}
+	return ignored, nil
+}
+
+func (c *CacheMock) addSnapshotObject(doc []byte) (ignored []string, err error) {
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	obj, gvk, err := decode(doc, nil, nil)
+	if err != nil {
+		if gvk != nil && runtime.IsNotRegisteredError(err) {
+			// unknown types, e.g. custom resources, are not used by the converters
+			return []string{gvk.Kind}, nil
+		}
+		return nil, err
+	}
+	fullname := func(ns, name string) string {
+		return ns + "/" + name
+	}
+	switch obj := obj.(type) {
+	case *api.List:
+		for _, item := range obj.Items {
+			skipped, err := c.addSnapshotObject(item.Raw)
+			if err != nil {
+				return nil, err
+			}
+			ignored = append(ignored, skipped...)
+		}
+	case *networking.Ingress:
+		c.IngList = append(c.IngList, obj)
+	case *networking.IngressClass:
+		c.IngClassList = append(c.IngClassList, obj)
+	case *api.Service:
+		c.SvcList = append(c.SvcList, obj)
+	case *api.Endpoints:
+		c.EpList[fullname(obj.Namespace, obj.Name)] = obj
+	case *discoveryv1.EndpointSlice:
+		svcName := fullname(obj.Namespace, obj.Labels[discoveryv1.LabelServiceName])
+		if c.EpsList == nil {
+			c.EpsList = map[string][]*discoveryv1.EndpointSlice{}
+		}
+		c.EpsList[svcName] = append(c.EpsList[svcName], obj)
+	case *api.ConfigMap:
+		if c.ConfigMapList == nil {
+			c.ConfigMapList = map[string]*api.ConfigMap{}
+		}
+		c.ConfigMapList[fullname(obj.Namespace, obj.Name)] = obj
+	case *api.Namespace:
+		c.NsList[obj.Name] = obj
+	case *api.Pod:
+		if c.PodList == nil {
+			c.PodList = map[string]*api.Pod{}
+		}
+		c.PodList[fullname(obj.Namespace, obj.Name)] = obj
+	case *api.Secret:
+		c.addSnapshotSecret(obj)
+	default:
+		kind := obj.GetObjectKind().GroupVersionKind().Kind
+		if meta, ok := obj.(interface{ GetName() string }); ok {
+			kind += " " + meta.GetName()
+		}
+		ignored = append(ignored, kind)
+	}
+	return ignored, nil
+}
+
+func (c *CacheMock) addSnapshotSecret(secret *api.Secret) {
+	name := secret.Namespace + "/" + secret.Name
+	has := func(keys ...string) bool {
+		for _, key := range keys {
+			_, inData := secret.Data[key]
+			_, inStringData := secret.StringData[key]
+			if inData || inStringData {
+				return true
+			}
+		}
+		return false
+	}
+	path := "/tls/" + strings.ReplaceAll(name, "/", "_")
+	if secret.Type == api.SecretTypeTLS || has(api.TLSCertKey) {
+		c.SecretTLSPath[name] = path + ".pem"
+	}
+	if has("ca.crt") {
+		if c.SecretCAPath == nil {
+			c.SecretCAPath = map[string]string{}
+		}
+		c.SecretCAPath[name] = path + "_ca.pem"
+	}
+	if has("ca.crl") {
+		if c.SecretCRLPath == nil {
+			c.SecretCRLPath = map[string]string{}
+		}
+		c.SecretCRLPath[name] = path + "_crl.pem"
+	}
+	if has("dhparam.pem") {
+		if c.SecretDHPath == nil {
+			c.SecretDHPath = map[string]string{}
+		}
+		c.SecretDHPath[name] = path + "_dh.pem"
+	}
+	content := map[string][]byte{}
+	for key, value := range secret.Data {
+		content[key] = value
+	}
+	for key, value := range secret.StringData {
+		content[key] = []byte(value)
+	}
+	if c.SecretContent == nil {
+		c.SecretContent = SecretContent{}
+	}
+	c.SecretContent[name] = content
+}
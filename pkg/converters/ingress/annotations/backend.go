@@ -17,12 +17,26 @@ limitations under the License.
 package annotations
 
 import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"math/big"
 	"net"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
@@ -32,24 +46,91 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
+// validURLParamRegex allows the unreserved chars of a URL, see RFC 3986
+var validURLParamRegex = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+// validCookieNameRegex allows the token chars of a cookie name, see RFC 6265,
+// except the ones that start a quoted string or a comment in the haproxy config
+var validCookieNameRegex = regexp.MustCompile("^[A-Za-z0-9!$%&*+.^_`|~-]+$")
+
+// cookieLifetime reads the session-cookie-max-idle or session-cookie-max-life
+// configuration as a duration of whole seconds, or zero if missing or invalid.
+func (c *updater) cookieLifetime(d *backData, key, strategy string) time.Duration {
+	cfg := d.mapper.Get(key)
+	if cfg.Value == "" {
+		return 0
+	}
+	duration, ok := TimeToDuration(cfg.Value)
+	if !ok || duration < time.Second {
+		c.logger.Warn("ignoring invalid %s on %v: %s", key, cfg.Source, cfg.Value)
+		return 0
+	}
+	if strategy != "insert" {
+		c.logger.Warn("ignoring %s on %v: cookie strategy '%s' does not support it", key, cfg.Source, strategy)
+		return 0
+	}
+	return duration.Truncate(time.Second)
+}
+
+// a hostname or domain name, optionally starting with a dot, without scheme or port
+var validCookieDomainRegex = regexp.MustCompile(`^\.?([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// printable chars, except the ones that would need to be escaped in a quoted string
+var validCookieKeyRegex = regexp.MustCompile(`^[^"\\\x00-\x1f\x7f]+$`)
+
+func (c *updater) buildBackendAccessDebug(d *backData) {
+	config := d.mapper.Get(ingtypes.BackAccessDebugHeader)
+	if !config.Bool() {
+		return
+	}
+	if c.haproxy.Global().ProductionHardening {
+		c.logger.Warn("ignoring access debug header on %v: %s is enabled", config.Source, ingtypes.GlobalProductionHardening)
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring access debug header on %v: backend is not http", config.Source)
+		return
+	}
+	d.backend.AccessDebug = true
+}
+
 func (c *updater) buildBackendAffinity(d *backData) {
 	affinity := d.mapper.Get(ingtypes.BackAffinity)
+	urlParam := d.mapper.Get(ingtypes.BackAffinityURLParam)
 	if affinity.Source == nil {
+		if urlParam.Source != nil {
+			c.logger.Warn("ignoring affinity URL parameter on %v: cookie affinity is not configured", urlParam.Source)
+		}
+		return
+	}
+	if affinity.Value == "source-ip" || affinity.Value == "request-header" {
+		c.buildBackendStickAffinity(d, affinity)
 		return
 	}
 	if affinity.Value != "cookie" {
 		c.logger.Error("unsupported affinity type on %v: %s", affinity.Source, affinity.Value)
 		return
 	}
-	name := d.mapper.Get(ingtypes.BackSessionCookieName).Value
+	cookieName := d.mapper.Get(ingtypes.BackSessionCookieName)
+	name := cookieName.Value
+	if name != "" && !validCookieNameRegex.MatchString(name) {
+		c.logger.Warn("ignoring invalid cookie name on %v: %s", cookieName.Source, name)
+		name = ""
+	}
 	if name == "" {
 		name = "INGRESSCOOKIE"
 	}
 	strategy := d.mapper.Get(ingtypes.BackSessionCookieStrategy)
 	var strategyName string
+	var preserve bool
 	switch strategy.Value {
 	case "insert", "rewrite", "prefix":
 		strategyName = strategy.Value
+	case "preserve", "insert preserve":
+		// haproxy only supports preserve along with insert: the cookie
+		// issued by the server is kept, and inserted if missing
+		strategyName = "insert"
+		preserve = true
 	default:
 		if strategy.Source != nil {
 			c.logger.Warn("invalid affinity cookie strategy '%s' on %v, using 'insert' instead", strategy.Value, strategy.Source)
@@ -58,7 +139,35 @@ func (c *updater) buildBackendAffinity(d *backData) {
 	}
 	d.backend.Cookie.Name = name
 	d.backend.Cookie.Strategy = strategyName
-	keywords := d.mapper.Get(ingtypes.BackSessionCookieKeywords)
+	failover := d.mapper.Get(ingtypes.BackAffinityFailover)
+	switch failover.Value {
+	case "redispatch":
+		// the defaults section already redispatches, unless it is disabled
+		// by drain-support-redispatch, which is overridden only by backends
+		// that declare the failover
+		if failover.Source != nil {
+			d.backend.Cookie.Failover = failover.Value
+		}
+	case "error":
+		d.backend.Cookie.Failover = failover.Value
+	default:
+		if failover.Source != nil {
+			c.logger.Warn("invalid affinity failover '%s' on %v, using 'redispatch' instead", failover.Value, failover.Source)
+		}
+	}
+	if urlParam.Value != "" {
+		if d.backend.ModeTCP {
+			c.logger.Warn("ignoring affinity URL parameter on %v: backend is in TCP mode", urlParam.Source)
+		} else if !validURLParamRegex.MatchString(urlParam.Value) {
+			c.logger.Warn("ignoring invalid affinity URL parameter name on %v: %s", urlParam.Source, urlParam.Value)
+		} else {
+			if balance := d.backend.BalanceAlgorithm; balance != "" && balance != "roundrobin" {
+				c.logger.Warn("balance algorithm '%s' on %v is replaced by the affinity URL parameter", balance, urlParam.Source)
+			}
+			d.backend.Cookie.URLParam = urlParam.Value
+		}
+	}
+	keywords := c.getSafeValue(d.mapper, ingtypes.BackSessionCookieKeywords)
 	keywordsValue := keywords.Value
 	if strategyName == "insert" && keywordsValue == "" {
 		keywordsValue = "indirect nocache httponly"
@@ -67,12 +176,62 @@ func (c *updater) buildBackendAffinity(d *backData) {
 		// just warn, no error, for keeping backwards compatibility where "preserve" may have been used in the "keywords" section
 		c.logger.Warn("session-cookie-keywords on %s contains 'preserve'; consider using 'session-cookie-preserve' instead for better dynamic update cookie persistence", keywords.Source)
 	}
-	domain := d.mapper.Get(ingtypes.BackSessionCookieDomain).Value
+	cookieDomain := d.mapper.Get(ingtypes.BackSessionCookieDomain)
+	domain := cookieDomain.Value
+	if domain != "" && !validCookieDomainRegex.MatchString(domain) {
+		c.logger.Warn("ignoring invalid cookie domain on %v: %s", cookieDomain.Source, domain)
+		domain = ""
+	}
 	d.backend.Cookie.Keywords = keywordsValue
 	d.backend.Cookie.Domain = domain
-	d.backend.Cookie.Dynamic = d.mapper.Get(ingtypes.BackSessionCookieDynamic).Bool()
-	d.backend.Cookie.Preserve = d.mapper.Get(ingtypes.BackSessionCookiePreserve).Bool()
-	d.backend.Cookie.SameSite = d.mapper.Get(ingtypes.BackSessionCookieSameSite).Bool()
+	d.backend.Cookie.MaxIdle = c.cookieLifetime(d, ingtypes.BackSessionCookieMaxIdle, strategyName)
+	d.backend.Cookie.MaxLife = c.cookieLifetime(d, ingtypes.BackSessionCookieMaxLife, strategyName)
+	dynamic := d.mapper.Get(ingtypes.BackSessionCookieDynamic)
+	if preserve && dynamic.Bool() {
+		if dynamic.Source != nil {
+			c.logger.Warn("ignoring '%s' configuration on %v: cookie strategy '%s' does not support dynamic cookies",
+				ingtypes.BackSessionCookieDynamic, dynamic.Source, strategy.Value)
+		}
+	} else {
+		d.backend.Cookie.Dynamic = dynamic.Bool()
+	}
+	if dynamicKey := d.mapper.Get(ingtypes.BackSessionCookieDynKey); dynamicKey.Value != "" {
+		if !d.backend.Cookie.Dynamic {
+			c.logger.Warn("ignoring '%s' configuration on %v: dynamic cookies are not enabled",
+				ingtypes.BackSessionCookieDynKey, dynamicKey.Source)
+		} else if !validCookieKeyRegex.MatchString(dynamicKey.Value) {
+			c.logger.Warn("ignoring invalid dynamic cookie key on %v", dynamicKey.Source)
+		} else {
+			d.backend.Cookie.DynamicKey = dynamicKey.Value
+		}
+	}
+	d.backend.Cookie.Preserve = preserve || d.mapper.Get(ingtypes.BackSessionCookiePreserve).Bool()
+	sameSite := d.mapper.Get(ingtypes.BackSessionCookieSameSite)
+	switch strings.ToLower(sameSite.Value) {
+	case "", "false":
+	case "true", "none":
+		// browsers reject SameSite=None without Secure
+		d.backend.Cookie.SameSite = "None"
+		d.backend.Cookie.Secure = true
+	case "lax":
+		d.backend.Cookie.SameSite = "Lax"
+	case "strict":
+		d.backend.Cookie.SameSite = "Strict"
+	default:
+		c.logger.Warn("ignoring invalid cookie SameSite attribute on %v: %s", sameSite.Source, sameSite.Value)
+	}
+	if d.mapper.Get(ingtypes.BackSessionCookieSecure).Bool() {
+		d.backend.Cookie.Secure = true
+	}
+	if d.backend.Cookie.Secure && strings.Contains(keywordsValue, "secure") {
+		d.backend.Cookie.Secure = false
+	}
+	d.backend.Cookie.HTTPOnly = d.mapper.Get(ingtypes.BackSessionCookieHTTPOnly).Bool() && !strings.Contains(keywordsValue, "httponly")
+	// SameSite and Secure are added on https requests behind a fronting proxy,
+	// unless the cookie attributes were already configured
+	d.backend.Cookie.AutoSecure = d.mapper.Get(ingtypes.BackCookieAutoSecure).Bool() &&
+		d.backend.Cookie.SameSite == "" && !d.backend.Cookie.Secure &&
+		!strings.Contains(keywordsValue, "secure") && !strings.Contains(keywordsValue, "attr")
 	shared := d.mapper.Get(ingtypes.BackSessionCookieShared)
 	if domain == "" {
 		d.backend.Cookie.Shared = shared.Bool()
@@ -85,6 +244,8 @@ func (c *updater) buildBackendAffinity(d *backData) {
 	switch cookieStrategy.Value {
 	case "pod-uid":
 		d.backend.EpCookieStrategy = hatypes.EpCookiePodUID
+	case "pod-name-hash":
+		d.backend.EpCookieStrategy = hatypes.EpCookiePodNameHash
 	case "server-name":
 		d.backend.EpCookieStrategy = hatypes.EpCookieName
 	default:
@@ -93,6 +254,105 @@ func (c *updater) buildBackendAffinity(d *backData) {
 	case "":
 		d.backend.EpCookieStrategy = hatypes.EpCookieName
 	}
+	if shared.Bool() && d.backend.EpCookieStrategy == hatypes.EpCookieName {
+		// server names are reused by all the backends, a shared cookie
+		// needs values that identify the same pod on all of them
+		d.backend.EpCookieStrategy = hatypes.EpCookiePodNameHash
+	}
+}
+
+// cookieAffinityKeys are the affinity related keys that only apply
+// to the cookie based affinity.
+var cookieAffinityKeys = []string{
+	ingtypes.BackAffinityFailover,
+	ingtypes.BackAffinityURLParam,
+	ingtypes.BackSessionCookieDomain,
+	ingtypes.BackSessionCookieDynamic,
+	ingtypes.BackSessionCookieDynKey,
+	ingtypes.BackSessionCookieHTTPOnly,
+	ingtypes.BackSessionCookieKeywords,
+	ingtypes.BackSessionCookieMaxIdle,
+	ingtypes.BackSessionCookieMaxLife,
+	ingtypes.BackSessionCookieName,
+	ingtypes.BackSessionCookiePreserve,
+	ingtypes.BackSessionCookieSameSite,
+	ingtypes.BackSessionCookieSecure,
+	ingtypes.BackSessionCookieShared,
+	ingtypes.BackSessionCookieStrategy,
+	ingtypes.BackSessionCookieValue,
+}
+
+func (c *updater) buildBackendStickAffinity(d *backData, affinity *ConfigValue) {
+	for _, key := range cookieAffinityKeys {
+		if cfg := d.mapper.Get(key); cfg.Source != nil {
+			c.logger.Warn("ignoring '%s' configuration on %v: affinity type is '%s'", key, cfg.Source, affinity.Value)
+		}
+	}
+	var header string
+	if affinity.Value == "request-header" {
+		if d.backend.ModeTCP {
+			c.logger.Warn("ignoring request-header affinity on %v: backend is in TCP mode", affinity.Source)
+			return
+		}
+		headerName := d.mapper.Get(ingtypes.BackAffinityHeaderName)
+		if headerName.Value == "" {
+			c.logger.Error("ignoring request-header affinity on %v: missing '%s' configuration", affinity.Source, ingtypes.BackAffinityHeaderName)
+			return
+		}
+		if !headerNameRegex.MatchString(headerName.Value) {
+			c.logger.Warn("ignoring request-header affinity on %v: invalid header name: %s", headerName.Source, headerName.Value)
+			return
+		}
+		header = headerName.Value
+	} else if headerName := d.mapper.Get(ingtypes.BackAffinityHeaderName); headerName.Source != nil {
+		c.logger.Warn("ignoring '%s' configuration on %v: affinity type is '%s'", ingtypes.BackAffinityHeaderName, headerName.Source, affinity.Value)
+	}
+	size := d.mapper.Get(ingtypes.BackAffinityTableSize)
+	// table sizes are a number of entries, so a unit is not expected
+	value, _, err := utils.ParseSize(size.Value)
+	if err != nil || value <= 0 {
+		c.logger.Warn("ignoring %s affinity on %v: invalid table size on '%s': %s", affinity.Value, size.Source, ingtypes.BackAffinityTableSize, size.Value)
+		return
+	}
+	expire := c.validateTime(d.mapper.Get(ingtypes.BackAffinityTableExpire))
+	if expire == "" {
+		c.logger.Warn("ignoring %s affinity on backend '%s': table expire is mandatory", affinity.Value, d.backend.ID)
+		return
+	}
+	d.backend.StickAffinity = hatypes.BackendStickAffinity{
+		Header: header,
+		Size:   value,
+		Expire: expire,
+	}
+}
+
+// validAllDownLocationRegex doesn't allow chars that would break the haproxy
+// keyword, and also the percent sign, which starts a log-format expression.
+var validAllDownLocationRegex = regexp.MustCompile(`^[^\s"'\\%]+$`)
+
+func (c *updater) buildBackendAllDownResponse(d *backData) {
+	allDown := d.mapper.Get(ingtypes.BackAllDownResponse)
+	if allDown.Value == "" || allDown.Value == "default" {
+		return
+	}
+	kind, value, _ := strings.Cut(allDown.Value, ":")
+	switch kind {
+	case "page":
+		code, _ := strconv.Atoi(value)
+		if !isHAResponseCode(code) {
+			c.logger.Warn("ignoring all-down-response on %v: unsupported status code: %s", allDown.Source, value)
+			return
+		}
+		d.backend.AllDownResponse.StatusCode = code
+	case "redirect":
+		if !validAllDownLocationRegex.MatchString(value) {
+			c.logger.Warn("ignoring all-down-response on %v: invalid redirect location: %s", allDown.Source, value)
+			return
+		}
+		d.backend.AllDownResponse.Location = value
+	default:
+		c.logger.Warn("ignoring all-down-response on %v: invalid value: %s", allDown.Source, allDown.Value)
+	}
 }
 
 var authRequestSanitizeHeaderRegex = regexp.MustCompile(`[^a-zA-Z0-9]`)
@@ -112,9 +372,30 @@ var (
 	validURLRegex    = regexp.MustCompile(`^[^"' ]*$`)
 	validMethodRegex = regexp.MustCompile(`^([A-Za-z]+|\*)$`)
 	authHeaderRegex  = regexp.MustCompile(`^[A-Za-z0-9-]+(:[^:'" ]+)?$`)
+	// authHeaderGlobRegex matches a header name, `*` and `?` can be used as wildcards
+	authHeaderGlobRegex = regexp.MustCompile(`^[A-Za-z0-9*?-]+$`)
 )
 
-func (c *updater) setAuthExternal(config ConfigValueGetter, auth *hatypes.AuthExternal, url *ConfigValue) {
+// parseAuthHeaders splits a comma-separated list of headers, skipping empty
+// items and the ones that doesn't match regex.
+func (c *updater) parseAuthHeaders(h *ConfigValue, key string, regex *regexp.Regexp) []string {
+	headers := c.limits.checkHeaders(h, key, strings.Split(h.Value, ","))
+	parsed := make([]string, 0, len(headers))
+	for _, header := range headers {
+		header = strings.TrimSpace(header)
+		if len(header) == 0 {
+			continue
+		}
+		if !regex.MatchString(header) {
+			c.logger.Warn("invalid header format '%s' on %v", header, h.Source)
+			continue
+		}
+		parsed = append(parsed, header)
+	}
+	return parsed
+}
+
+func (c *updater) setAuthExternal(config ConfigValueGetter, auth *hatypes.AuthExternal, url *ConfigValue, hostname string) {
 	// auth backend should be configured or requests should be denied
 	// AlwaysDeny will be changed to false if the configuration succeed
 	auth.AlwaysDeny = true
@@ -182,10 +463,11 @@ func (c *updater) setAuthExternal(config ConfigValueGetter, auth *hatypes.AuthEx
 		backend = c.haproxy.Backends().FindBackend(namespace, name, urlPort)
 		if backend == nil {
 			// warn was already logged in the ingress if a service couldn't be found,
-			// but we still need to add a warning here because, in the current code base,
+			// but we still need to log here because, in the current code base,
 			// a valid named service can lead to a broken configuration. See ingress'
-			// counterpart code.
-			c.logger.Warn("skipping auth-url on %s: service '%s:%s' was not found", url.Source.String(), name, urlPort)
+			// counterpart code. This is an error, the same of a missing oauth path,
+			// because all the requests to this path will be denied.
+			c.logger.Error("skipping auth-url on %s: service '%s/%s:%s' was not found", url.Source.String(), namespace, name, urlPort)
 			return
 		}
 	default:
@@ -213,27 +495,32 @@ func (c *updater) setAuthExternal(config ConfigValueGetter, auth *hatypes.AuthEx
 		method = "GET"
 	}
 
-	s := config.Get(ingtypes.BackAuthSignin)
+	s := c.getSafeValue(config, ingtypes.BackAuthSignin)
 	signin := s.Value
 	if signin != "" && !validURLRegex.MatchString(signin) {
 		c.logger.Warn("ignoring invalid sign-in URL on %s: %s", s.Source.String(), signin)
 		signin = ""
 	}
 
-	annHdrRequest := config.Get(ingtypes.BackAuthHeadersRequest).Value
+	annHdrRequest := c.getSafeValue(config, ingtypes.BackAuthHeadersRequest).Value
 	if annHdrRequest == "" {
 		annHdrRequest = "-"
 	}
-	annHdrSucceed := config.Get(ingtypes.BackAuthHeadersSucceed).Value
-	if annHdrSucceed == "" {
-		annHdrSucceed = "-"
-	}
-	annHdrFail := config.Get(ingtypes.BackAuthHeadersFail).Value
+	annHdrFail := c.getSafeValue(config, ingtypes.BackAuthHeadersFail).Value
 	if annHdrFail == "" {
 		annHdrFail = "-"
 	}
 	hdrRequest := strings.Split(annHdrRequest, ",")
-	hdrSucceed := strings.Split(annHdrSucceed, ",")
+	hdrSucceed := []string{"-"}
+	if hs := c.getSafeValue(config, ingtypes.BackAuthHeadersSucceed); hs.Value != "" && hs.Value != "-" {
+		// headers copied from the auth response are removed from the client request,
+		// which cannot be done if all of them are copied, `*` overrides everything
+		if headers := c.parseAuthHeaders(hs, ingtypes.BackAuthHeadersSucceed, authHeaderGlobRegex); slices.Contains(headers, "*") {
+			hdrSucceed = []string{"*"}
+		} else if len(headers) > 0 {
+			hdrSucceed = headers
+		}
+	}
 	hdrFail := strings.Split(annHdrFail, ",")
 
 	if signin != "" {
@@ -249,14 +536,184 @@ func (c *updater) setAuthExternal(config ConfigValueGetter, auth *hatypes.AuthEx
 		urlPath = "/"
 	}
 
+	cache := c.buildAuthCache(config)
+	var hdrStrip, hdrStripRegex []string
+	if cache.Duration != "" && !reflect.DeepEqual(hdrSucceed, []string{"-"}) {
+		// a response served by the auth cache has no headers to copy, so the headers
+		// are not copied at all. Configured headers are still removed from the client
+		// request, either served by the cache or not, which avoids spoofing.
+		if !reflect.DeepEqual(hdrSucceed, []string{"*"}) {
+			c.logger.Warn("ignoring '%s' on %s due to auth-cache-duration configuration, the headers are removed from the request instead", ingtypes.BackAuthHeadersSucceed, url.Source.String())
+			hdrStrip, hdrStripRegex = c.buildAuthHeadersStrip(hdrSucceed, url)
+		}
+		hdrSucceed = []string{"-"}
+	}
+	if cache.DenyDuration != "" && !reflect.DeepEqual(hdrFail, []string{"-"}) {
+		// denied responses can only be cached if auth-request doesn't terminate
+		// the transaction, HAProxy denies the request instead.
+		if !reflect.DeepEqual(hdrFail, []string{"*"}) {
+			c.logger.Warn("ignoring '%s' on %s due to auth-cache-deny-duration configuration", ingtypes.BackAuthHeadersFail, url.Source.String())
+		}
+		hdrFail = []string{"-"}
+	}
+
 	auth.AlwaysDeny = false
 	auth.AuthBackendName = authBackendName
 	auth.AuthPath = urlPath
+	auth.Cache = cache
 	auth.Method = method
 	auth.HeadersRequest = hdrRequest
 	auth.HeadersSucceed = hdrSucceed
 	auth.HeadersFail = hdrFail
-	auth.RedirectOnFail = signin
+	auth.HeadersStrip = hdrStrip
+	auth.HeadersStripRegex = hdrStripRegex
+	auth.ProxyHeaders = c.buildAuthProxyHeaders(config)
+	if signin != "" {
+		auth.RedirectOnFail = c.buildAuthSignin(config, s, hostname)
+		auth.RedirHTMLOnly = config.Get(ingtypes.BackAuthSigninHTMLOnly).Bool()
+		auth.AllowedPath = authSigninAllowedPath(signin, hostname)
+	}
+}
+
+// buildAuthHeadersStrip splits the headers that should be removed from the client
+// request in names and regular expressions, the latter built from the wildcards.
+func (c *updater) buildAuthHeadersStrip(headers []string, url *ConfigValue) (names, regexes []string) {
+	for _, header := range headers {
+		if !strings.ContainsAny(header, "*?") {
+			names = append(names, header)
+			continue
+		}
+		// del-header matches a regex since haproxy 2.6
+		if !utils.VersionAtLeast(c.options.HAProxyVersion, 2, 6) {
+			c.logger.Warn("header '%s' on %s cannot be removed from the request: wildcards need haproxy 2.6 or newer", header, url.Source.String())
+			continue
+		}
+		// header names are stored in lower case
+		glob := strings.NewReplacer("*", ".*", "?", ".").Replace(strings.ToLower(header))
+		regexes = append(regexes, "^"+glob+"$")
+	}
+	return names, regexes
+}
+
+var (
+	// nginx style placeholders of auth-signin, translated to haproxy fetches
+	authSigninPlaceholders = strings.NewReplacer(
+		"$scheme", "%[ssl_fc,iif(https,http)]",
+		"$host", "%[req.hdr(host)]",
+		"$request_uri", "%[url]",
+		"$escaped_request_uri", "%[url,url_enc]",
+	)
+	authSigninUnknownPlaceholder = regexp.MustCompile(`\$[A-Za-z_]+`)
+	// authSigninOriginalURL is the URL requested by the client, added to the
+	// auth-signin redirect so the sign in server can redirect the user back
+	authSigninOriginalURL = "%[ssl_fc,iif(https,http)]://%[req.hdr(host)]%[url,url_enc]"
+)
+
+// buildAuthSignin builds the Location of the auth-signin redirect, translating
+// the placeholders and adding the original URL as a query param.
+func (c *updater) buildAuthSignin(config ConfigValueGetter, signin *ConfigValue, hostname string) string {
+	location := authSigninPlaceholders.Replace(signin.Value)
+	if unknown := authSigninUnknownPlaceholder.FindString(location); unknown != "" {
+		c.logger.Warn("unsupported placeholder '%s' in the sign-in URL on %s, using it verbatim", unknown, signin.Source.String())
+	}
+	redirParam := config.Get(ingtypes.BackAuthSigninRedirParam)
+	if param := redirParam.Value; param != "" {
+		if !validURLParamRegex.MatchString(param) {
+			c.logger.Warn("ignoring invalid sign-in redirect param on %s: %s", redirParam.Source.String(), param)
+		} else if !regexp.MustCompile(`[?&]` + regexp.QuoteMeta(param) + `=`).MatchString(signin.Value) {
+			sep := "?"
+			if strings.Contains(location, "?") {
+				sep = "&"
+			}
+			location += sep + param + "=" + authSigninOriginalURL
+		}
+	}
+	return location
+}
+
+// authSigninAllowedPath returns the path of the auth-signin URL if it is
+// served by the same hostname, so requests to the sign in page don't need to
+// be authenticated, which would lead to a redirect loop.
+func authSigninAllowedPath(signin, hostname string) string {
+	if _, url, found := strings.Cut(signin, "://"); found {
+		host, path, _ := strings.Cut(url, "/")
+		if host, _, _ = strings.Cut(host, ":"); host != "$host" && !strings.EqualFold(host, hostname) {
+			return ""
+		}
+		signin = "/" + path
+	}
+	path, _, _ := strings.Cut(signin, "?")
+	if !strings.HasPrefix(path, "/") || path == "/" || strings.ContainsAny(path, "$%") {
+		// the root path of the same host would allow everything
+		return ""
+	}
+	return path
+}
+
+// authProxyHeaders are the headers that haproxy can build and add to the
+// request sent to the authentication service, indexed by their lower case name.
+var authProxyHeaders = map[string]string{
+	"x-forwarded-for":   "X-Forwarded-For",
+	"x-forwarded-host":  "X-Forwarded-Host",
+	"x-forwarded-proto": "X-Forwarded-Proto",
+	"x-forwarded-uri":   "X-Forwarded-Uri",
+	"x-original-url":    "X-Original-URL",
+	"x-real-ip":         "X-Real-IP",
+}
+
+func (c *updater) buildAuthProxyHeaders(config ConfigValueGetter) []string {
+	h := config.Get(ingtypes.BackAuthProxyHeaders)
+	if h.Value == "" || h.Value == "-" {
+		return nil
+	}
+	var headers []string
+	for _, header := range strings.Split(h.Value, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		name, found := authProxyHeaders[strings.ToLower(header)]
+		if !found {
+			c.logger.Warn("ignoring unsupported auth proxy header '%s' on %v", header, h.Source)
+			continue
+		}
+		headers = append(headers, name)
+	}
+	return headers
+}
+
+const authCacheMaxSize = 1024 * 1024
+
+func (c *updater) buildAuthCache(config ConfigValueGetter) (cache hatypes.AuthCache) {
+	duration := config.Get(ingtypes.BackAuthCacheDuration)
+	if duration.Value == "" {
+		return cache
+	}
+	cache.Duration = c.validateTime(duration)
+	if cache.Duration == "" {
+		return cache
+	}
+	keyHeader := config.Get(ingtypes.BackAuthCacheKey)
+	if keyHeader.Value == "" || !headerNameRegex.MatchString(keyHeader.Value) {
+		c.logger.Warn("ignoring auth cache on %s: invalid header name on '%s': %s", duration.Source.String(), ingtypes.BackAuthCacheKey, keyHeader.Value)
+		return hatypes.AuthCache{}
+	}
+	cache.KeyHeader = keyHeader.Value
+	size := config.Get(ingtypes.BackAuthCacheSize)
+	value, _, err := utils.ParseSize(size.Value)
+	if err != nil || value <= 0 {
+		c.logger.Warn("ignoring auth cache on %s: invalid table size on '%s': %s", duration.Source.String(), ingtypes.BackAuthCacheSize, size.Value)
+		return hatypes.AuthCache{}
+	}
+	if value > authCacheMaxSize {
+		c.logger.Warn("auth cache table size on %s is %d entries, using the maximum of %d entries instead", size.Source.String(), value, authCacheMaxSize)
+		value = authCacheMaxSize
+	}
+	cache.Size = value
+	if denyDuration := config.Get(ingtypes.BackAuthCacheDenyDuration); denyDuration.Value != "" {
+		cache.DenyDuration = c.validateTime(denyDuration)
+	}
+	return cache
 }
 
 func (c *updater) buildBackendAuthExternal(d *backData) {
@@ -265,41 +722,105 @@ func (c *updater) buildBackendAuthExternal(d *backData) {
 		isBackend := config.Get(ingtypes.BackAuthExternalPlacement).ToLower() == "backend"
 		url := config.Get(ingtypes.BackAuthURL)
 		if isBackend && url.Value != "" {
-			c.setAuthExternal(config, &path.AuthExternal, url)
+			c.setAuthExternal(config, &path.AuthExternal, url, path.Hostname())
 		}
 	}
 }
 
 func (c *updater) buildBackendAuthHTTP(d *backData) {
+	noAuth := c.readBackendPathList(d, ingtypes.BackNoAuthLocations)
 	for _, path := range d.backend.Paths {
+		if noAuth[path.Path()] {
+			continue
+		}
 		config := d.mapper.GetConfig(path.Link)
 		authSecret := config.Get(ingtypes.BackAuthSecret)
-		if authSecret.Value == "" {
+		if authSecret.Value == "" || config.Get(ingtypes.BackAuthType).ToLower() == "jwt" {
+			continue
+		}
+		secretType := config.Get(ingtypes.BackAuthSecretType)
+		isMap := false
+		switch secretType.ToLower() {
+		case "", "auth-file":
+		case "auth-map":
+			isMap = true
+		default:
+			c.logger.Warn("ignoring invalid auth secret type on %v: %s", secretType.Source, secretType.Value)
+		}
+		var secretValues, secretNames, listNames []string
+		for _, secretValue := range utils.Split(authSecret.Value, ",") {
+			secretName := secretValue
+			if !strings.Contains(secretName, "/") {
+				secretName = authSecret.Source.Namespace + "/" + secretName
+			}
+			if secretValue == "" || slices.Contains(secretNames, secretName) {
+				continue
+			}
+			secretValues = append(secretValues, secretValue)
+			secretNames = append(secretNames, secretName)
+			listNames = append(listNames, strings.Replace(secretName, "/", "_", 1))
+		}
+		if len(secretNames) == 0 {
 			continue
 		}
-		secretName := authSecret.Value
-		if !strings.Contains(secretName, "/") {
-			secretName = authSecret.Source.Namespace + "/" + secretName
+		// userlists of more than one secret are named after all of them, in the declared order
+		listName := strings.Join(listNames, "__")
+		if isMap {
+			// the same secret might be used as auth-file and auth-map on distinct paths
+			listName += "_map"
 		}
-		listName := strings.Replace(secretName, "/", "_", 1)
 		userlist := c.haproxy.Userlists().Find(listName)
-		if userlist == nil {
-			userb, err := c.cache.GetPasswdSecretContent(
-				authSecret.Source.Namespace,
-				authSecret.Value,
-				[]convtypes.TrackingRef{
-					{Context: convtypes.ResourceHABackend, UniqueName: d.backend.ID},
-					{Context: convtypes.ResourceHAUserlist, UniqueName: listName},
-				},
-			)
+		if userlist != nil {
+			// userlists are shared by all the backends referencing the same
+			// secrets, the reading permission should be checked on reuse
+			var err error
+			for _, secretValue := range secretValues {
+				if err = CheckCrossNamespaceSecret(authSecret.Source.Namespace, secretValue, c.options.DynamicConfig.CrossNamespaceSecretPasswd); err != nil {
+					break
+				}
+			}
 			if err != nil {
 				c.logger.Error("error reading basic authentication on %v: %v", authSecret.Source, err)
 				continue
 			}
-			userstr := string(userb)
-			users, errs := extractUserlist(authSecret.Source.Name, secretName, userstr)
-			for _, err := range errs {
-				c.logger.Warn("ignoring malformed usr/passwd on secret '%s', declared on %v: %v", secretName, authSecret.Source, err)
+		} else {
+			track := []convtypes.TrackingRef{
+				{Context: convtypes.ResourceHABackend, UniqueName: d.backend.ID},
+				{Context: convtypes.ResourceHAUserlist, UniqueName: listName},
+			}
+			var users []hatypes.User
+			// username -> index of the user and of the secret that declared it
+			type owner struct{ user, secret int }
+			owners := map[string]owner{}
+			var found bool
+			for i, secretName := range secretNames {
+				secretUsers, err := c.readAuthSecretUsers(authSecret.Source, secretValues[i], secretName, isMap, track)
+				if err != nil {
+					if !c.secrets.ReportedMissing(authSecret.Source, err) {
+						c.logger.Error("error reading basic authentication on %v: %v", authSecret.Source, err)
+					}
+					continue
+				}
+				found = true
+				for _, user := range secretUsers {
+					if o, dup := owners[user.Name]; dup {
+						if users[o.user] != user {
+							c.logger.Warn("ignoring user '%s' of secret '%s' declared on %v: user is already declared with another password on secret '%s'",
+								user.Name, secretName, authSecret.Source, secretNames[o.secret])
+						}
+						continue
+					}
+					owners[user.Name] = owner{user: len(users), secret: i}
+					users = append(users, user)
+				}
+			}
+			if !found {
+				continue
+			}
+			if len(users) > authUserlistMaxUsers {
+				c.logger.Error("secret '%s' declared on %v has %d users, using only the first %d of them",
+					strings.Join(secretNames, ","), authSecret.Source, len(users), authUserlistMaxUsers)
+				users = users[:authUserlistMaxUsers]
 			}
 			userlist = c.haproxy.Userlists().Replace(listName, users)
 			if len(users) == 0 {
@@ -310,9 +831,11 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 		// Backends need always to be tracked because only hosts and backends tracking can properly start a partial update
 		// Tracker will take care of deduplicate trackings
 		// TODO build a stronger tracking
-		c.tracker.TrackNames(convtypes.ResourceSecret, secretName, convtypes.ResourceHABackend, d.backend.ID)
+		for _, secretName := range secretNames {
+			c.tracker.TrackNames(convtypes.ResourceSecret, secretName, convtypes.ResourceHABackend, d.backend.ID)
+		}
 		realm := "localhost" // HAProxy's backend name would be used if missing
-		authRealm := config.Get(ingtypes.BackAuthRealm)
+		authRealm := c.getSafeValue(config, ingtypes.BackAuthRealm)
 		if authRealm == nil || authRealm.Source == nil {
 			// leave default
 		} else if strings.Contains(authRealm.Value, `"`) {
@@ -325,48 +848,641 @@ func (c *updater) buildBackendAuthHTTP(d *backData) {
 	}
 }
 
-func extractUserlist(source, secret, users string) ([]hatypes.User, []error) {
-	var userlist []hatypes.User
-	var err []error
-	for i, usr := range strings.Split(users, "\n") {
-		if usr == "" {
+// readBackendPathList reads a comma-separated list of paths of the backend,
+// e.g. paths that should not request authentication. Listed paths should
+// match the path of an ingress rule that points to the backend.
+func (c *updater) readBackendPathList(d *backData, key string) map[string]bool {
+	pathList := d.mapper.Get(key)
+	if pathList.Value == "" {
+		return nil
+	}
+	paths := map[string]bool{}
+	for _, location := range utils.Split(pathList.Value, ",") {
+		if location == "" {
+			continue
+		}
+		found := false
+		for _, path := range d.backend.Paths {
+			if path.Path() == location {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.logger.Warn("ignoring %s path '%s' on %v: path not found on backend '%s'", key, location, pathList.Source, d.backend.ID)
+			continue
+		}
+		paths[location] = true
+	}
+	return paths
+}
+
+type authSecretUsers struct {
+	users []hatypes.User
+	err   error
+}
+
+// readAuthSecretUsers reads the users of a basic authentication secret,
+// warning about the malformed entries. The secret content is parsed once
+// per sync, and reused by all the userlists that reference the secret.
+func (c *updater) readAuthSecretUsers(source *Source, secretValue, secretName string, isMap bool, track []convtypes.TrackingRef) ([]hatypes.User, error) {
+	if err := CheckCrossNamespaceSecret(source.Namespace, secretValue, c.options.DynamicConfig.CrossNamespaceSecretPasswd); err != nil {
+		return nil, err
+	}
+	key := secretName
+	if isMap {
+		key += ":map"
+	}
+	if usr, found := c.authUsr[key]; found {
+		c.tracker.TrackRefName(track, convtypes.ResourceSecret, secretName)
+		return usr.users, usr.err
+	}
+	users, err := c.parseAuthSecretUsers(source, secretValue, secretName, isMap, track)
+	if c.authUsr == nil {
+		c.authUsr = map[string]*authSecretUsers{}
+	}
+	c.authUsr[key] = &authSecretUsers{users: users, err: err}
+	return users, err
+}
+
+func (c *updater) parseAuthSecretUsers(source *Source, secretValue, secretName string, isMap bool, track []convtypes.TrackingRef) ([]hatypes.User, error) {
+	var users []hatypes.User
+	var errs []error
+	if isMap {
+		usersMap, err := c.cache.GetPasswdSecretMap(source.Namespace, secretValue, track)
+		if err != nil {
+			return nil, err
+		}
+		users, errs = extractUserlistMap(usersMap, true)
+	} else {
+		userb, err := c.cache.GetPasswdSecretContent(source.Namespace, secretValue, track)
+		if err != nil {
+			return nil, err
+		}
+		users, errs = extractUserlist(string(userb), true)
+	}
+	for _, err := range errs {
+		if errors.Is(err, errUnsupportedHash) {
+			c.logger.Warn("ignoring usr/passwd on secret '%s', declared on %v: %v, use MD5-crypt ($1$), SHA-256-crypt ($5$) or SHA-512-crypt ($6$)", secretName, source, err)
+		} else {
+			c.logger.Warn("ignoring malformed usr/passwd on secret '%s', declared on %v: %v", secretName, source, err)
+		}
+	}
+	return users, nil
+}
+
+const (
+	// authJWTKeyJWKS is the secret key with a JWKS, see RFC 7517
+	authJWTKeyJWKS = "jwks.json"
+	// authJWTKeyPEM is the secret key with PEM encoded public keys or certificates
+	authJWTKeyPEM = "public.pem"
+)
+
+var (
+	// authJWTAlgs are the signing algorithms supported by haproxy's jwt_verify,
+	// indexed by the RSA key type or the EC curve name. HMAC algorithms are not
+	// supported, the secret would need to be shared with haproxy.
+	authJWTAlgs = map[string][]string{
+		"RSA":   {"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"},
+		"P-256": {"ES256"},
+		"P-384": {"ES384"},
+		"P-521": {"ES512"},
+	}
+
+	// authJWTClaimRegex matches the issuer and audience claims that can be
+	// used as arguments of the Lua action
+	authJWTClaimRegex = regexp.MustCompile(`^[^\s"\\]+$`)
+
+	// authJWTKeyIDRegex matches the key IDs that can be used as ACL values
+	authJWTKeyIDRegex = regexp.MustCompile(`^[A-Za-z0-9._~+/=:-]+$`)
+)
+
+func (c *updater) buildBackendAuthJWT(d *backData) {
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		authType := config.Get(ingtypes.BackAuthType)
+		switch authType.ToLower() {
+		case "", "basic":
+			continue
+		case "jwt":
+		default:
+			c.logger.Warn("ignoring invalid auth type on %v: %s", authType.Source, authType.Value)
+			continue
+		}
+		// requests should be denied if the configuration fails,
+		// AlwaysDeny will be changed to false if the configuration succeed
+		path.AuthJWT.AlwaysDeny = true
+		if !utils.VersionAtLeast(c.options.HAProxyVersion, 2, 5) {
+			c.logger.Error("error reading JWT authentication on %v: needs haproxy 2.5 or newer, found %s",
+				authType.Source, c.options.HAProxyVersion)
+			continue
+		}
+		external := c.haproxy.Global().External
+		if external.IsExternal && !external.HasLua {
+			c.logger.Error("error reading JWT authentication on %v: needs Lua json module, install lua-json4 and enable 'external-has-lua' global config",
+				authType.Source)
 			continue
 		}
-		sep := strings.Index(usr, ":")
-		if sep == -1 {
-			err = append(err, fmt.Errorf("missing password of user '%s' line %d", usr, i+1))
+		secret := config.Get(ingtypes.BackAuthJWTSecret)
+		if secret.Value == "" {
+			c.logger.Error("error reading JWT authentication on %v: missing auth-jwt-secret", authType.Source)
 			continue
 		}
-		username := usr[:sep]
-		if username == "" {
-			err = append(err, fmt.Errorf("missing username line %d", i+1))
+		issuer := config.Get(ingtypes.BackAuthJWTIssuer)
+		if issuer.Value != "" && !authJWTClaimRegex.MatchString(issuer.Value) {
+			c.logger.Error("error reading JWT authentication on %v: invalid issuer: %s", issuer.Source, issuer.Value)
 			continue
 		}
-		if sep == len(usr)-1 || usr[sep:] == "::" {
-			err = append(err, fmt.Errorf("missing password of user '%s' line %d", username, i+1))
+		audience := config.Get(ingtypes.BackAuthJWTAudience)
+		if audience.Value != "" && !authJWTClaimRegex.MatchString(audience.Value) {
+			c.logger.Error("error reading JWT authentication on %v: invalid audience: %s", audience.Source, audience.Value)
 			continue
 		}
-		var user hatypes.User
-		if string(usr[sep+1]) == ":" {
-			// usr::pwd
-			user = hatypes.User{
-				Name:      username,
-				Passwd:    usr[sep+2:],
-				Encrypted: false,
+		track := []convtypes.TrackingRef{{Context: convtypes.ResourceHABackend, UniqueName: d.backend.ID}}
+		keys, err := c.readAuthJWTKeys(secret.Source, secret.Value, track)
+		if err != nil {
+			if !c.secrets.ReportedMissing(secret.Source, err) {
+				c.logger.Error("error reading JWT authentication on %v: %v", secret.Source, err)
 			}
-		} else {
-			// usr:pwd
-			user = hatypes.User{
-				Name:      username,
-				Passwd:    usr[sep+1:],
-				Encrypted: true,
+			continue
+		}
+		realm := "localhost"
+		authRealm := c.getSafeValue(config, ingtypes.BackAuthRealm)
+		if authRealm == nil || authRealm.Source == nil {
+			// leave default
+		} else if strings.Contains(authRealm.Value, `"`) {
+			c.logger.Warn("ignoring auth-realm with quotes on %v", authRealm.Source)
+		} else if authRealm.Value != "" {
+			realm = authRealm.Value
+		}
+		path.AuthJWT = hatypes.AuthJWT{
+			Audience: audience.Value,
+			Issuer:   issuer.Value,
+			Keys:     keys,
+			Realm:    realm,
+		}
+	}
+}
+
+type authJWTKeys struct {
+	keys []hatypes.AuthJWTKey
+	err  error
+}
+
+// readAuthJWTKeys reads the public keys of a JWT authentication secret. The
+// secret content is parsed once per sync, and reused by all the paths that
+// reference the secret.
+func (c *updater) readAuthJWTKeys(source *Source, secretName string, track []convtypes.TrackingRef) ([]hatypes.AuthJWTKey, error) {
+	if err := CheckCrossNamespaceSecret(source.Namespace, secretName, c.options.DynamicConfig.CrossNamespaceSecretPasswd); err != nil {
+		return nil, err
+	}
+	fullName := secretName
+	if !strings.Contains(fullName, "/") {
+		fullName = source.Namespace + "/" + fullName
+	}
+	if jwt, found := c.authJWT[fullName]; found {
+		c.tracker.TrackRefName(track, convtypes.ResourceSecret, fullName)
+		return jwt.keys, jwt.err
+	}
+	keys, err := c.parseAuthJWTKeys(source, secretName, fullName, track)
+	if c.authJWT == nil {
+		c.authJWT = map[string]*authJWTKeys{}
+	}
+	c.authJWT[fullName] = &authJWTKeys{keys: keys, err: err}
+	return keys, err
+}
+
+func (c *updater) parseAuthJWTKeys(source *Source, secretName, fullName string, track []convtypes.TrackingRef) ([]hatypes.AuthJWTKey, error) {
+	data, err := c.cache.GetPasswdSecretMap(source.Namespace, secretName, track)
+	if err != nil {
+		return nil, err
+	}
+	var keys []hatypes.AuthJWTKey
+	var errs []error
+	if jwks, found := data[authJWTKeyJWKS]; found {
+		keys, errs = extractJWKS(jwks)
+	} else if pemData, found := data[authJWTKeyPEM]; found {
+		keys, errs = extractPublicKeysPEM(pemData)
+	} else {
+		return nil, fmt.Errorf("secret '%s' does not have '%s' or '%s' keys", fullName, authJWTKeyJWKS, authJWTKeyPEM)
+	}
+	for _, err := range errs {
+		c.logger.Error("ignoring JWT key of secret '%s' declared on %v: %v", fullName, source, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("secret '%s' does not have supported public keys", fullName)
+	}
+	return keys, nil
+}
+
+// extractJWKS reads the signing keys of a JWKS, re-encoding them as PEM
+// encoded public keys, which is the format haproxy's jwt_verify reads.
+func extractJWKS(data []byte) ([]hatypes.AuthJWTKey, []error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			Kid string `json:"kid"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, []error{fmt.Errorf("invalid JWKS: %w", err)}
+	}
+	var keys []hatypes.AuthJWTKey
+	var errs []error
+	for i, jwk := range jwks.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		var pub any
+		var algs []string
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			pub, err = parseJWKRSA(jwk.N, jwk.E)
+			algs = authJWTAlgs["RSA"]
+		case "EC":
+			pub, err = parseJWKEC(jwk.Crv, jwk.X, jwk.Y)
+			algs = authJWTAlgs[jwk.Crv]
+		default:
+			err = fmt.Errorf("unsupported key type '%s'", jwk.Kty)
+		}
+		if err == nil && jwk.Alg != "" {
+			if !slices.Contains(algs, jwk.Alg) {
+				err = fmt.Errorf("unsupported algorithm '%s'", jwk.Alg)
+			}
+			algs = []string{jwk.Alg}
+		}
+		if err == nil && jwk.Kid != "" && !authJWTKeyIDRegex.MatchString(jwk.Kid) {
+			err = fmt.Errorf("invalid key ID '%s'", jwk.Kid)
+		}
+		var key hatypes.AuthJWTKey
+		if err == nil {
+			key, err = buildAuthJWTKey(pub, algs, jwk.Kid)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %d of the JWKS: %w", i, err))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, errs
+}
+
+func parseJWKRSA(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil || len(nb) == 0 {
+		return nil, fmt.Errorf("invalid RSA modulus")
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil || len(eb) == 0 || len(eb) > 4 {
+		return nil, fmt.Errorf("invalid RSA exponent")
+	}
+	exp := 0
+	for _, b := range eb {
+		exp = exp<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: exp}, nil
+}
+
+func parseJWKEC(crv, x, y string) (*ecdh.PublicKey, error) {
+	var curve ecdh.Curve
+	switch crv {
+	case "P-256":
+		curve = ecdh.P256()
+	case "P-384":
+		curve = ecdh.P384()
+	case "P-521":
+		curve = ecdh.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve '%s'", crv)
+	}
+	xb, errx := base64.RawURLEncoding.DecodeString(x)
+	yb, erry := base64.RawURLEncoding.DecodeString(y)
+	if errx != nil || erry != nil || len(xb) != len(yb) {
+		return nil, fmt.Errorf("invalid EC point")
+	}
+	pub, err := curve.NewPublicKey(append(append([]byte{4}, xb...), yb...))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC point: %w", err)
+	}
+	return pub, nil
+}
+
+// extractPublicKeysPEM reads the public keys of PEM encoded public keys
+// or certificates, re-encoding them as PEM encoded public keys.
+func extractPublicKeysPEM(data []byte) ([]hatypes.AuthJWTKey, []error) {
+	var keys []hatypes.AuthJWTKey
+	var errs []error
+	for i := 0; ; i++ {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var pub any
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var crt *x509.Certificate
+			if crt, err = x509.ParseCertificate(block.Bytes); err == nil {
+				pub = crt.PublicKey
+			}
+		default:
+			err = fmt.Errorf("unsupported PEM block type '%s'", block.Type)
+		}
+		var algs []string
+		if err == nil {
+			switch k := pub.(type) {
+			case *rsa.PublicKey:
+				algs = authJWTAlgs["RSA"]
+			case *ecdsa.PublicKey:
+				algs = authJWTAlgs[k.Curve.Params().Name]
 			}
+			if len(algs) == 0 {
+				err = fmt.Errorf("unsupported key type %T", pub)
+			}
+		}
+		var key hatypes.AuthJWTKey
+		if err == nil {
+			key, err = buildAuthJWTKey(pub, algs, "")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("PEM block %d: %w", i, err))
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("PEM blocks not found"))
+	}
+	return keys, errs
+}
+
+func buildAuthJWTKey(pub any, algs []string, kid string) (hatypes.AuthJWTKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return hatypes.AuthJWTKey{}, err
+	}
+	return hatypes.AuthJWTKey{
+		Algs: algs,
+		ID:   kid,
+		PEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+	}, nil
+}
+
+func (c *updater) buildBackendAuthBruteforce(d *backData) {
+	limit := d.mapper.Get(ingtypes.BackAuthBruteforceLimit)
+	if limit.Value == "" {
+		return
+	}
+	if limit.Int() <= 0 {
+		c.logger.Warn("ignoring invalid auth bruteforce limit on %v: %s", limit.Source, limit.Value)
+		return
+	}
+	var hasAuth bool
+	for _, path := range d.backend.Paths {
+		if path.AuthHTTP.UserlistName != "" {
+			hasAuth = true
+			break
+		}
+	}
+	if !hasAuth {
+		c.logger.Warn("ignoring auth bruteforce limit on %v: backend '%s' does not have paths with basic authentication",
+			limit.Source, d.backend.ID)
+		return
+	}
+	// sc-* actions on http-after-response were introduced on haproxy 2.6
+	if !utils.VersionAtLeast(c.options.HAProxyVersion, 2, 6) {
+		c.logger.Error("ignoring auth bruteforce limit on %v: needs haproxy 2.6 or newer, found %s",
+			limit.Source, c.options.HAProxyVersion)
+		return
+	}
+	window := c.validateTime(d.mapper.Get(ingtypes.BackAuthBruteforceWindow))
+	ban := c.validateTime(d.mapper.Get(ingtypes.BackAuthBruteforceBan))
+	if window == "" || ban == "" {
+		c.logger.Warn("ignoring auth bruteforce limit on %v: window and ban durations are mandatory", limit.Source)
+		return
+	}
+	d.backend.AuthBruteforce = hatypes.BackendAuthBruteforce{
+		Limit:  limit.Int(),
+		Window: window,
+		Ban:    ban,
+	}
+}
+
+// authUserlistMaxUsers is the maximum number of users read from a
+// single basic authentication secret.
+const authUserlistMaxUsers = 5000
+
+// ExtractUserlist parses users and passwords in the format of the `auth`
+// key of a basic authentication secret, one `usr:pwd` or `usr::pwd` per line.
+// The format of encrypted passwords is not validated.
+func ExtractUserlist(users string) ([]hatypes.User, []error) {
+	return extractUserlist(users, false)
+}
+
+// extractUserlist parses users like ExtractUserlist does, optionally
+// refusing encrypted passwords whose hash algorithm is not supported by HAProxy.
+func extractUserlist(users string, checkHash bool) ([]hatypes.User, []error) {
+	var userlist []hatypes.User
+	var err []error
+	// secrets created on Windows editors might have a BOM and CRLF line endings
+	users = strings.TrimPrefix(users, "\uFEFF")
+	users = strings.ReplaceAll(users, "\r\n", "\n")
+	users = strings.ReplaceAll(users, "\r", "\n")
+	for i, usr := range strings.Split(users, "\n") {
+		usr = strings.TrimRightFunc(usr, unicode.IsSpace)
+		if usr == "" {
+			continue
+		}
+		user, e := parseUserEntry(usr, fmt.Sprintf("line %d", i+1), checkHash)
+		if e != nil {
+			err = append(err, e)
+			continue
+		}
+		userlist = append(userlist, user)
+	}
+	return userlist, err
+}
+
+// extractUserlistMap reads users from a map-style secret, where every key is
+// a username and its value the password, in the same format of the file-style
+// secret: encrypted by default, or plain text if prefixed with a colon.
+func extractUserlistMap(users map[string][]byte, checkHash bool) ([]hatypes.User, []error) {
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var userlist []hatypes.User
+	var err []error
+	for _, name := range names {
+		passwd := strings.TrimRightFunc(string(users[name]), unicode.IsSpace)
+		user, e := parseUserEntry(name+":"+passwd, fmt.Sprintf("key %q", name), checkHash)
+		if e != nil {
+			err = append(err, e)
+			continue
 		}
 		userlist = append(userlist, user)
 	}
 	return userlist, err
 }
 
+// errUnsupportedHash is wrapped by the errors of encrypted passwords whose
+// hash algorithm cannot be used by HAProxy.
+var errUnsupportedHash = errors.New("unsupported password hash")
+
+// unsupportedHashes are the prefixes of popular password hash formats that
+// the crypt(3) call used by HAProxy userlists does not understand.
+var unsupportedHashes = []struct{ prefix, name string }{
+	{"$2a$", "bcrypt"},
+	{"$2b$", "bcrypt"},
+	{"$2x$", "bcrypt"},
+	{"$2y$", "bcrypt"},
+	{"$apr1$", "Apache MD5"},
+	{"$argon2", "Argon2"},
+	{"$y$", "yescrypt"},
+	{"$7$", "scrypt"},
+	{"{SHA}", "SHA-1"},
+	{"{SSHA}", "salted SHA-1"},
+}
+
+// checkCryptHash validates the hash algorithm of an encrypted password. MD5-crypt
+// ($1$), SHA-256-crypt ($5$), SHA-512-crypt ($6$) and the traditional DES-based
+// crypt, which does not have a prefix, are supported.
+func checkCryptHash(passwd string) error {
+	for _, hash := range unsupportedHashes {
+		if strings.HasPrefix(passwd, hash.prefix) {
+			return fmt.Errorf("%w '%s' (%s)", errUnsupportedHash, hash.prefix, hash.name)
+		}
+	}
+	if strings.HasPrefix(passwd, "$") {
+		for _, prefix := range []string{"$1$", "$5$", "$6$"} {
+			if strings.HasPrefix(passwd, prefix) {
+				return nil
+			}
+		}
+		prefix := passwd
+		if end := strings.Index(passwd[1:], "$"); end >= 0 {
+			prefix = passwd[:end+2]
+		}
+		return fmt.Errorf("%w '%s'", errUnsupportedHash, prefix)
+	}
+	return nil
+}
+
+// parseUserEntry parses a single `usr:pwd` or `usr::pwd` entry. location
+// identifies the entry on error messages, eg `line 3`.
+func parseUserEntry(usr, location string, checkHash bool) (hatypes.User, error) {
+	sep := strings.Index(usr, ":")
+	if sep == -1 {
+		return hatypes.User{}, fmt.Errorf("missing password of user '%s' %s", usr, location)
+	}
+	username := usr[:sep]
+	if username == "" {
+		return hatypes.User{}, fmt.Errorf("missing username %s", location)
+	}
+	if e := validateUsername(username); e != nil {
+		return hatypes.User{}, fmt.Errorf("%w %s", e, location)
+	}
+	if sep == len(usr)-1 || usr[sep:] == "::" {
+		return hatypes.User{}, fmt.Errorf("missing password of user '%s' %s", username, location)
+	}
+	if string(usr[sep+1]) == ":" {
+		// usr::pwd
+		return hatypes.User{
+			Name:      username,
+			Passwd:    usr[sep+2:],
+			Encrypted: false,
+		}, nil
+	}
+	// usr:pwd
+	passwd := usr[sep+1:]
+	if checkHash {
+		if e := checkCryptHash(passwd); e != nil {
+			return hatypes.User{}, fmt.Errorf("%w of user '%s' %s", e, username, location)
+		}
+	}
+	return hatypes.User{
+		Name:      username,
+		Passwd:    passwd,
+		Encrypted: true,
+	}, nil
+}
+
+func validateUsername(username string) error {
+	if !utf8.ValidString(username) {
+		return fmt.Errorf("username %q is not valid UTF-8, the secret might be encoded as Windows-1252", username)
+	}
+	for _, r := range username {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return fmt.Errorf("invalid character %U on username %q", r, username)
+		}
+	}
+	return nil
+}
+
+func (c *updater) buildBackendBandwidthLimit(d *backData) {
+	download := d.mapper.Get(ingtypes.BackBandwidthLimitDownload)
+	upload := d.mapper.Get(ingtypes.BackBandwidthLimitUpload)
+	if download.Value == "" && upload.Value == "" {
+		return
+	}
+	source := download.Source
+	if download.Value == "" {
+		source = upload.Source
+	}
+	// bwlim filters were introduced on haproxy 2.7
+	if !utils.VersionAtLeast(c.options.HAProxyVersion, 2, 7) {
+		c.logger.Error("ignoring bandwidth limit on %v: bwlim filters need haproxy 2.7 or newer, found %s",
+			source, c.options.HAProxyVersion)
+		return
+	}
+	if d.backend.ModeTCP && !d.mapper.Get(ingtypes.BackBandwidthLimitAllowTCP).Bool() {
+		c.logger.Warn("ignoring bandwidth limit on %v: backend '%s' is in TCP mode, configure '%s' as true to allow",
+			source, d.backend.ID, ingtypes.BackBandwidthLimitAllowTCP)
+		return
+	}
+	limit := func(cfg *ConfigValue) int64 {
+		if cfg.Value == "" {
+			return 0
+		}
+		value, err := c.validateSize(cfg)
+		if err != nil || value <= 0 {
+			c.logger.Warn("ignoring invalid bandwidth limit on %v: %s", cfg.Source, cfg.Value)
+			return 0
+		}
+		return value
+	}
+	bwlim := hatypes.BackendBandwidthLimit{
+		Download: limit(download),
+		Upload:   limit(upload),
+	}
+	if bwlim.Download == 0 && bwlim.Upload == 0 {
+		return
+	}
+	scope := d.mapper.Get(ingtypes.BackBandwidthLimitScope)
+	switch scope.ToLower() {
+	case "connection":
+	case "backend":
+		bwlim.Shared = true
+	default:
+		c.logger.Warn("ignoring invalid bandwidth limit scope on %v, using 'connection' instead: %s", scope.Source, scope.Value)
+	}
+	d.backend.BandwidthLimit = bwlim
+}
+
 func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 	balance := d.mapper.Get(ingtypes.BackBlueGreenBalance)
 	if balance.Source == nil || balance.Value == "" {
@@ -375,6 +1491,19 @@ func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 			return
 		}
 	}
+	autoPause := d.mapper.Get(ingtypes.BackBlueGreenAutoPause).Bool()
+	if autoPause {
+		// the controller pauses the balance writing the weights in use to the
+		// paused annotation, they are used until the annotation is removed
+		if paused := d.mapper.Get(ingtypes.BackBlueGreenPaused); paused.Value != "" {
+			if paused.Value != balance.Value {
+				c.logger.Warn("blue/green balance on %v is paused, using '%s' until the '%s' annotation is removed",
+					balance.Source, paused.Value, ingtypes.BackBlueGreenPaused)
+			}
+			balance = paused
+			d.backend.BlueGreen.AutoPause.Paused = true
+		}
+	}
 	type deployWeight struct {
 		labelName  string
 		labelValue string
@@ -444,6 +1573,21 @@ func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 		}
 		dw.cl.Length = len(dw.endpoints)
 	}
+	if autoPause {
+		c.buildBackendBlueGreenAutoPause(d, balance)
+		if d.backend.BlueGreen.AutoPause.Threshold > 0 {
+			// the last declared group is the canary, the other ones are the baseline
+			for i, dw := range deployWeights {
+				group := hatypes.BlueGreenGroupBaseline
+				if i == len(deployWeights)-1 {
+					group = hatypes.BlueGreenGroupCanary
+				}
+				for _, ep := range dw.endpoints {
+					ep.Group = group
+				}
+			}
+		}
+	}
 	if mode := d.mapper.Get(ingtypes.BackBlueGreenMode); mode.Value == "pod" {
 		// mode == pod, same weight as defined on balance annotation,
 		// no need to rebalance
@@ -465,6 +1609,21 @@ func (c *updater) buildBackendBlueGreenBalance(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendBlueGreenAutoPause(d *backData, balance *ConfigValue) {
+	if strings.Count(balance.Value, ",") == 0 {
+		c.logger.Warn("ignoring blue/green auto pause on %v: balance needs a baseline and a canary group", balance.Source)
+		return
+	}
+	threshold := d.mapper.Get(ingtypes.BackBlueGreenThreshold)
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(threshold.Value, "%"), 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		c.logger.Warn("ignoring blue/green auto pause on %v: invalid threshold percentage: %s", threshold.Source, threshold.Value)
+		return
+	}
+	d.backend.BlueGreen.AutoPause.Balance = balance.Value
+	d.backend.BlueGreen.AutoPause.Threshold = pct
+}
+
 const validLabelRegexStr = "([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]"
 const bluegreenSeparator = ":"
 
@@ -527,7 +1686,7 @@ func (c *updater) buildBackendBodySize(d *backData) {
 		if bodysize == nil || bodysize.Value == "" || bodysize.Value == "unlimited" {
 			continue
 		}
-		value, err := utils.SizeSuffixToInt64(bodysize.Value)
+		value, err := c.validateSize(bodysize)
 		if err != nil {
 			c.logger.Warn("ignoring invalid body size on %v: %s", bodysize.Source, bodysize.Value)
 			continue
@@ -543,17 +1702,17 @@ func (c *updater) buildBackendCors(d *backData) {
 
 		if enabled {
 			var allowOriginRegex []string
-			if regex := config.Get(ingtypes.BackCorsAllowOriginRegex).Value; regex != "" {
+			if regex := c.getSafeValue(config, ingtypes.BackCorsAllowOriginRegex).Value; regex != "" {
 				allowOriginRegex = strings.Split(regex, " ")
 			}
 			path.Cors = hatypes.Cors{
 				Enabled:          enabled,
 				AllowCredentials: config.Get(ingtypes.BackCorsAllowCredentials).Bool(),
-				AllowHeaders:     config.Get(ingtypes.BackCorsAllowHeaders).Value,
-				AllowMethods:     config.Get(ingtypes.BackCorsAllowMethods).Value,
-				AllowOrigin:      strings.Split(config.Get(ingtypes.BackCorsAllowOrigin).Value, ","),
+				AllowHeaders:     c.getSafeValue(config, ingtypes.BackCorsAllowHeaders).Value,
+				AllowMethods:     c.getSafeValue(config, ingtypes.BackCorsAllowMethods).Value,
+				AllowOrigin:      strings.Split(c.getSafeValue(config, ingtypes.BackCorsAllowOrigin).Value, ","),
 				AllowOriginRegex: allowOriginRegex,
-				ExposeHeaders:    config.Get(ingtypes.BackCorsExposeHeaders).Value,
+				ExposeHeaders:    c.getSafeValue(config, ingtypes.BackCorsExposeHeaders).Value,
 				MaxAge:           config.Get(ingtypes.BackCorsMaxAge).Int(),
 			}
 		}
@@ -599,61 +1758,218 @@ func firstToken(s string) string {
 			break
 		}
 	}
-	end := start
-	for ; len(s) > end; end++ {
-		if asciiSpace[s[end]] == 1 {
-			break
+	end := start
+	for ; len(s) > end; end++ {
+		if asciiSpace[s[end]] == 1 {
+			break
+		}
+	}
+	return s[start:end]
+}
+
+func (c *updater) buildBackendDNS(d *backData) {
+	resolverName := d.mapper.Get(ingtypes.BackUseResolver).Value
+	if resolverName == "" {
+		return
+	}
+	var resolver *hatypes.DNSResolver
+	for _, r := range c.haproxy.Global().DNS.Resolvers {
+		if r.Name == resolverName {
+			resolver = r
+			break
+		}
+	}
+	if resolver == nil {
+		c.logger.Warn("skipping undeclared DNS resolver: %s", resolverName)
+		return
+	}
+	d.backend.Resolver = resolverName
+	prefer := d.mapper.Get(ingtypes.BackDNSResolvePrefer)
+	switch prefer.Value {
+	case "ipv4", "ipv6":
+		d.backend.DNS.ResolvePrefer = prefer.Value
+	default:
+		c.logger.Warn("ignoring invalid resolve prefer on %v: '%s', using 'ipv4' instead", prefer.Source, prefer.Value)
+		d.backend.DNS.ResolvePrefer = "ipv4"
+	}
+	if holdValid := c.validateTime(d.mapper.Get(ingtypes.BackDNSHoldValid)); holdValid != resolver.HoldValid {
+		d.backend.DNS.HoldValid = holdValid
+	}
+	slots := d.mapper.Get(ingtypes.BackExternalNameSlots)
+	if slots.Value == "" {
+		return
+	}
+	if slots.Int() <= 0 {
+		c.logger.Warn("ignoring invalid external name slots on %v: %s", slots.Source, slots.Value)
+		return
+	}
+	d.backend.DNS.Slots = slots.Int()
+	if addrs := len(d.backend.Endpoints); addrs > d.backend.DNS.Slots {
+		c.logger.Warn("backend '%s' resolves to %d addresses, but only %d external name slots are configured on %v",
+			d.backend.ID, addrs, d.backend.DNS.Slots, slots.Source)
+	}
+}
+
+func (c *updater) buildBackendDynamic(d *backData) {
+	d.backend.Dynamic = hatypes.DynBackendConfig{
+		DynUpdate:    d.mapper.Get(ingtypes.BackDynamicScaling).Bool(),
+		BlockSize:    d.mapper.Get(ingtypes.BackBackendServerSlotsInc).Int(),
+		MinFreeSlots: d.mapper.Get(ingtypes.BackSlotsMinFree).Int(),
+	}
+}
+
+func (c *updater) buildBackendAgentCheck(d *backData) {
+	d.backend.AgentCheck.Addr = c.getSafeValue(d.mapper, ingtypes.BackAgentCheckAddr).Value
+	d.backend.AgentCheck.Interval = c.validateTime(d.mapper.Get(ingtypes.BackAgentCheckInterval))
+	d.backend.AgentCheck.Port = d.mapper.Get(ingtypes.BackAgentCheckPort).Int()
+	d.backend.AgentCheck.Send = c.getSafeValue(d.mapper, ingtypes.BackAgentCheckSend).Value
+}
+
+func (c *updater) buildBackendHealthCheck(d *backData) {
+	d.backend.HealthCheck.Addr = c.getSafeValue(d.mapper, ingtypes.BackHealthCheckAddr).Value
+	d.backend.HealthCheck.FallCount = d.mapper.Get(ingtypes.BackHealthCheckFallCount).Int()
+	interval := d.mapper.Get(ingtypes.BackHealthCheckInterval)
+	if interval.Value == "" {
+		interval = d.mapper.Get(ingtypes.BackBackendCheckInterval)
+	}
+	d.backend.HealthCheck.Interval = c.validateTime(interval)
+	d.backend.HealthCheck.Port = d.mapper.Get(ingtypes.BackHealthCheckPort).Int()
+	d.backend.HealthCheck.RiseCount = d.mapper.Get(ingtypes.BackHealthCheckRiseCount).Int()
+	d.backend.HealthCheck.URI = c.getSafeValue(d.mapper, ingtypes.BackHealthCheckURI).Value
+}
+
+// validEarlyHintRegex matches a Link header value, starting with the URI reference.
+// Chars that could inject new headers or escape from the haproxy's quoted string
+// are not allowed.
+var validEarlyHintRegex = regexp.MustCompile(`^<[^<>\s"\\%]+>(\s*;\s*[^\x00-\x1f\x7f"\\%;]+)*$`)
+
+func (c *updater) buildBackendEarlyHints(d *backData) {
+	// early-hint was introduced on haproxy 2.2
+	supported := utils.VersionAtLeast(c.options.HAProxyVersion, 2, 2)
+	warned := false
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		hints := config.Get(ingtypes.BackEarlyHints)
+		if hints == nil || hints.Value == "" {
+			continue
+		}
+		if !supported {
+			if !warned {
+				c.logger.Warn("ignoring early-hints on %v: haproxy %s does not support early-hint",
+					hints.Source, c.options.HAProxyVersion)
+				warned = true
+			}
+			continue
+		}
+		for _, link := range utils.LineToSlice(hints.Value) {
+			link = strings.TrimSpace(link)
+			if link == "" {
+				continue
+			}
+			if !validEarlyHintRegex.MatchString(link) {
+				c.logger.Warn("ignoring invalid early-hints link on %v: %s", hints.Source, link)
+				continue
+			}
+			path.EarlyHints = append(path.EarlyHints, link)
+		}
+	}
+}
+
+// maxForceCloseUserAgents is the number of User-Agent patterns of a backend,
+// all of them are evaluated on every request.
+const maxForceCloseUserAgents = 20
+
+func (c *updater) buildBackendForceClose(d *backData) {
+	agents := d.mapper.Get(ingtypes.BackForceCloseUserAgents)
+	if agents.Value == "" {
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring force-close-user-agents on %v: backend is not in http mode", agents.Source)
+		return
+	}
+	var patterns []string
+	for _, pattern := range utils.LineToSlice(agents.Value) {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" || !c.checkSafeValue(agents.Source, ingtypes.BackForceCloseUserAgents, pattern) {
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			c.logger.Warn("ignoring invalid user agent pattern on %v: %s", agents.Source, pattern)
+			continue
 		}
+		patterns = append(patterns, pattern)
 	}
-	return s[start:end]
+	if len(patterns) > maxForceCloseUserAgents {
+		c.logger.Warn("force-close-user-agents on %v has %d patterns, using only the first %d",
+			agents.Source, len(patterns), maxForceCloseUserAgents)
+		patterns = patterns[:maxForceCloseUserAgents]
+	}
+	d.backend.ForceCloseUAs = patterns
 }
 
-func (c *updater) buildBackendDNS(d *backData) {
-	resolverName := d.mapper.Get(ingtypes.BackUseResolver).Value
-	if resolverName == "" {
+func (c *updater) buildBackendForwardedHeaders(d *backData) {
+	headers := d.mapper.Get(ingtypes.BackForwardedHeaders)
+	if headers.Value == "" {
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring forwarded-headers on %v: backend is not in http mode", headers.Source)
 		return
 	}
-	exists := func() bool {
-		for _, resolver := range c.haproxy.Global().DNS.Resolvers {
-			if resolver.Name == resolverName {
-				return true
+	readHeaderName := func(key, defaultValue string) string {
+		name := d.mapper.Get(key)
+		if !headerNameRegex.MatchString(name.Value) || name.Value == "" {
+			c.logger.Warn("ignoring invalid header name on %v for %s: '%s', using '%s' instead", name.Source, key, name.Value, defaultValue)
+			return defaultValue
+		}
+		return name.Value
+	}
+	fwd := &d.backend.ForwardedHeaders
+	for _, header := range utils.Split(headers.Value, ",") {
+		switch strings.ToLower(header) {
+		case "host":
+			fwd.Host = readHeaderName(ingtypes.BackForwardedHostHdr, "X-Forwarded-Host")
+		case "original-for":
+			// shares the header name with forwardfor=add, which declares it globally
+			fwd.OriginalFor = c.haproxy.Global().OriginalForwardedForHdr
+			if fwd.OriginalFor == "" {
+				fwd.OriginalFor = "X-Original-Forwarded-For"
 			}
+		case "port":
+			fwd.Port = readHeaderName(ingtypes.BackForwardedPortHdr, "X-Forwarded-Port")
+		case "":
+		default:
+			c.logger.Warn("ignoring invalid forwarded header on %v: '%s'", headers.Source, header)
 		}
-		return false
-	}()
-	if !exists {
-		c.logger.Warn("skipping undeclared DNS resolver: %s", resolverName)
-		return
 	}
-	d.backend.Resolver = resolverName
 }
 
-func (c *updater) buildBackendDynamic(d *backData) {
-	d.backend.Dynamic = hatypes.DynBackendConfig{
-		DynUpdate:    d.mapper.Get(ingtypes.BackDynamicScaling).Bool(),
-		BlockSize:    d.mapper.Get(ingtypes.BackBackendServerSlotsInc).Int(),
-		MinFreeSlots: d.mapper.Get(ingtypes.BackSlotsMinFree).Int(),
+func (c *updater) buildBackendFallback(d *backData) {
+	fallback := d.mapper.Get(ingtypes.BackFallbackBackend)
+	if fallback.Value == "" {
+		return
 	}
-}
-
-func (c *updater) buildBackendAgentCheck(d *backData) {
-	d.backend.AgentCheck.Addr = d.mapper.Get(ingtypes.BackAgentCheckAddr).Value
-	d.backend.AgentCheck.Interval = c.validateTime(d.mapper.Get(ingtypes.BackAgentCheckInterval))
-	d.backend.AgentCheck.Port = d.mapper.Get(ingtypes.BackAgentCheckPort).Int()
-	d.backend.AgentCheck.Send = d.mapper.Get(ingtypes.BackAgentCheckSend).Value
-}
-
-func (c *updater) buildBackendHealthCheck(d *backData) {
-	d.backend.HealthCheck.Addr = d.mapper.Get(ingtypes.BackHealthCheckAddr).Value
-	d.backend.HealthCheck.FallCount = d.mapper.Get(ingtypes.BackHealthCheckFallCount).Int()
-	interval := d.mapper.Get(ingtypes.BackHealthCheckInterval)
-	if interval.Value == "" {
-		interval = d.mapper.Get(ingtypes.BackBackendCheckInterval)
+	namespace, name, port, err := ingutils.ParseServicePort(fallback.Value)
+	if err != nil {
+		c.logger.Warn("ignoring fallback backend on %v: %v", fallback.Source, err)
+		return
 	}
-	d.backend.HealthCheck.Interval = c.validateTime(interval)
-	d.backend.HealthCheck.Port = d.mapper.Get(ingtypes.BackHealthCheckPort).Int()
-	d.backend.HealthCheck.RiseCount = d.mapper.Get(ingtypes.BackHealthCheckRiseCount).Int()
-	d.backend.HealthCheck.URI = d.mapper.Get(ingtypes.BackHealthCheckURI).Value
+	if namespace == "" && fallback.Source != nil {
+		namespace = fallback.Source.Namespace
+	}
+	// the fallback backend is pre-built by the ingress converter,
+	// see the auth-url counterpart regarding named ports
+	backend := c.haproxy.Backends().FindBackend(namespace, name, port)
+	if backend == nil {
+		c.logger.Warn("ignoring fallback backend on %v: service '%s/%s:%s' was not found", fallback.Source, namespace, name, port)
+		return
+	}
+	if backend.ID == d.backend.ID {
+		c.logger.Warn("ignoring fallback backend on %v: backend cannot fall back to itself", fallback.Source)
+		return
+	}
+	d.backend.Fallback = backend.BackendID()
 }
 
 func (c *updater) buildBackendHeaders(d *backData) {
@@ -661,13 +1977,15 @@ func (c *updater) buildBackendHeaders(d *backData) {
 	if headers.Value == "" {
 		return
 	}
-	for _, header := range utils.LineToSlice(headers.Value) {
+	lines := utils.LineToSlice(headers.Value)
+	lines = c.limits.checkHeaders(headers, ingtypes.BackHeaders, lines)
+	for _, header := range lines {
 		name, value, err := utils.SplitHeaderNameValue(header)
 		if err != nil {
 			c.logger.Warn("ignoring header on %s: %v", headers.Source, err)
 			continue
 		}
-		if name == "" {
+		if name == "" || !c.checkSafeValue(headers.Source, ingtypes.BackHeaders, header) {
 			continue
 		}
 		// TODO this should use a structured type and a smart match/replace if growing a bit more
@@ -680,10 +1998,59 @@ func (c *updater) buildBackendHeaders(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendCacheControl(d *backData) {
+	// off removes a value inherited from the global config
+	readHeader := func(config *ConfigValue) string {
+		if config.Value == "off" {
+			return ""
+		}
+		return config.Value
+	}
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		path.CacheControl.CacheControl = readHeader(config.Get(ingtypes.BackCacheControl))
+		path.CacheControl.SurrogateControl = readHeader(config.Get(ingtypes.BackSurrogateControl))
+		path.CacheControl.IfMissing = config.Get(ingtypes.BackCacheControlIfMissing).Bool()
+	}
+}
+
+func (c *updater) buildBackendChaos(d *backData) {
+	d.backend.Chaos = hatypes.BackendChaos{}
+	latency := d.mapper.Get(ingtypes.BackChaosLatency)
+	latencyPercent := d.mapper.Get(ingtypes.BackChaosLatencyPercent).Int()
+	abortStatus := d.mapper.Get(ingtypes.BackChaosAbortStatus)
+	abortPercent := d.mapper.Get(ingtypes.BackChaosAbortPercent).Int()
+	hasLatency := latency.Value != "" && latencyPercent > 0
+	hasAbort := abortStatus.Int() > 0 && abortPercent > 0
+	if !hasLatency && !hasAbort {
+		return
+	}
+	source := latency.Source
+	if !hasLatency {
+		source = abortStatus.Source
+	}
+	if !c.haproxy.Global().EnableChaos {
+		c.logger.Warn("ignoring chaos configuration on %v: chaos testing is disabled, see enable-chaos global config", source)
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring chaos configuration on %v: backend is not in http mode", source)
+		return
+	}
+	if hasLatency {
+		d.backend.Chaos.Latency = latency.Value
+		d.backend.Chaos.LatencyPercent = latencyPercent
+	}
+	if hasAbort {
+		d.backend.Chaos.AbortStatus = abortStatus.Int()
+		d.backend.Chaos.AbortPercent = abortPercent
+	}
+}
+
 func (c *updater) buildBackendHSTS(d *backData) {
 	for _, path := range d.backend.Paths {
 		config := d.mapper.GetConfig(path.Link)
-		path.HSTS.Enabled = config.Get(ingtypes.BackHSTS).Bool()
+		path.HSTS.Enabled = config.Get(ingtypes.BackHSTS).Bool() && (path.Host == nil || !path.Host.HTTPOnly())
 		path.HSTS.MaxAge = config.Get(ingtypes.BackHSTSMaxAge).Int()
 		path.HSTS.Subdomains = config.Get(ingtypes.BackHSTSIncludeSubdomains).Bool()
 		path.HSTS.Preload = config.Get(ingtypes.BackHSTSPreload).Bool()
@@ -693,14 +2060,27 @@ func (c *updater) buildBackendHSTS(d *backData) {
 func (c *updater) buildBackendLimit(d *backData) {
 	d.backend.Limit.RPS = d.mapper.Get(ingtypes.BackLimitRPS).Int()
 	d.backend.Limit.Connections = d.mapper.Get(ingtypes.BackLimitConnections).Int()
-	d.backend.Limit.Whitelist = c.splitCIDR(d.mapper.Get(ingtypes.BackLimitWhitelist))
+	d.backend.Limit.Whitelist = c.splitCIDR(ingtypes.BackLimitWhitelist, d.mapper.Get(ingtypes.BackLimitWhitelist))
+	d.backend.Limit.Peers = ""
+	if d.backend.Limit.RPS == 0 && d.backend.Limit.Connections == 0 {
+		return
+	}
+	if scope := d.mapper.Get(ingtypes.BackRateLimitScope); scope.Value == "global" {
+		peers := c.haproxy.Global().Peers
+		if peers.Name == "" {
+			c.logger.Warn("using local rate limit scope on %v: peers are not configured, see peers-service global config", scope.Source)
+			return
+		}
+		d.backend.Limit.Peers = peers.Name
+	}
 }
 
 func (c *updater) buildBackendOAuth(d *backData) {
+	skipPaths := c.readBackendPathList(d, ingtypes.BackOAuthSkipPaths)
 	for _, path := range d.backend.Paths {
 		config := d.mapper.GetConfig(path.Link)
 		oauth := config.Get(ingtypes.BackOAuth)
-		if oauth.Source == nil {
+		if oauth.Source == nil || skipPaths[path.Path()] {
 			continue
 		}
 
@@ -724,41 +2104,127 @@ func (c *updater) buildBackendOAuth(d *backData) {
 		}
 		uriPrefix := "/oauth2"
 		if prefix := config.Get(ingtypes.BackOAuthURIPrefix); prefix.Source != nil {
+			if !c.checkSafeValue(prefix.Source, ingtypes.BackOAuthURIPrefix, prefix.Value) {
+				continue
+			}
 			uriPrefix = prefix.Value
 		}
 		uriPrefix = strings.TrimRight(uriPrefix, "/")
+		authURI := uriPrefix + "/auth"
+		if uri := config.Get(ingtypes.BackOAuthAuthURI); uri.Value != "" {
+			authURI = uri.Value
+		}
+		startURI := uriPrefix + "/start"
+		if uri := config.Get(ingtypes.BackOAuthStartURI); uri.Value != "" {
+			startURI = uri.Value
+		}
+		sep := "?"
+		if strings.Contains(startURI, "?") {
+			sep = "&"
+		}
 		namespace := oauth.Source.Namespace
-		backend := c.findBackend(namespace, uriPrefix)
-		if backend == nil {
+		var backendID string
+		discovered := c.findBackend(namespace, uriPrefix)
+		if oauthBackend := config.Get(ingtypes.BackOAuthBackend); oauthBackend.Value != "" {
+			backend, err := c.findOAuthBackend(namespace, oauthBackend.Value)
+			if err != nil {
+				c.logger.Error("ignoring oauth backend on %v: %v", oauthBackend.Source, err)
+				continue
+			}
+			if discovered != nil && discovered.ID != backend.ID {
+				c.logger.InfoV(2, "using oauth backend '%s' declared on %v instead of '%s' found on path '%s'", oauthBackend.Value, oauthBackend.Source, discovered.ID, uriPrefix)
+			}
+			backendID = backend.ID
+		} else if discovered != nil {
+			backendID = discovered.ID
+		} else {
 			c.logger.Error("path '%s' was not found on namespace '%s'", uriPrefix, namespace)
 			continue
 		}
-		h := config.Get(ingtypes.BackOAuthHeaders)
-		headers := strings.Split(h.Value, ",")
+		headers := c.parseAuthHeaders(config.Get(ingtypes.BackOAuthHeaders), ingtypes.BackOAuthHeaders, authHeaderRegex)
 		headersMap := make(map[string]string, len(headers))
 		for _, header := range headers {
-			if len(header) == 0 {
-				continue
-			}
-			if !authHeaderRegex.MatchString(header) {
-				c.logger.Warn("invalid header format '%s' on %v", header, h.Source)
-				continue
-			}
 			h := strings.Split(header, ":")
 			headersMap[h[0]] = buildAuthRequestVarName(h[len(h)-1])
 		}
+		var bearerTokenVar string
+		if passToken := config.Get(ingtypes.BackOAuthPassAccessToken); passToken.Bool() {
+			for header := range headersMap {
+				if strings.EqualFold(header, "Authorization") {
+					c.logger.Warn("ignoring '%s' header of oauth headers on %v: oauth-pass-access-token is enabled", header, passToken.Source)
+					delete(headersMap, header)
+				}
+			}
+			bearerTokenVar = buildAuthRequestVarName(oauthAccessTokenHeader)
+		}
+		if cache := config.Get(ingtypes.BackAuthCacheDuration); cache.Source != nil && cache.Value != "" {
+			// the headers are copied from the oauth2-proxy response, a cached one would not have them
+			c.logger.Warn("ignoring '%s' on %v: oauth responses cannot be cached", ingtypes.BackAuthCacheDuration, cache.Source)
+		}
 
 		path.AuthExternal.AlwaysDeny = false
-		path.AuthExternal.AuthBackendName = backend.ID
+		path.AuthExternal.AuthBackendName = backendID
+		path.AuthExternal.SecureCookies = config.Get(ingtypes.BackCookieAutoSecure).Bool()
 		path.AuthExternal.AllowedPath = uriPrefix + "/"
-		path.AuthExternal.AuthPath = uriPrefix + "/auth"
+		path.AuthExternal.AuthPath = authURI
 		path.AuthExternal.HeadersRequest = []string{"*"}
 		path.AuthExternal.HeadersSucceed = []string{"-"}
 		path.AuthExternal.HeadersFail = []string{"-"}
 		path.AuthExternal.HeadersVars = headersMap
+		path.AuthExternal.BearerTokenVar = bearerTokenVar
 		path.AuthExternal.Method = "HEAD"
-		path.AuthExternal.RedirectOnFail = uriPrefix + "/start?rd=%[path]"
+		path.AuthExternal.ProxyHeaders = c.buildAuthProxyHeaders(config)
+		switch config.Get(ingtypes.BackOAuthErrorMode).Value {
+		case "deny":
+			// API clients: a redirect to the identity provider is useless
+			path.AuthExternal.DenyStatus = config.Get(ingtypes.BackOAuthDenyStatus).Int()
+			path.AuthExternal.DenyBody = config.Get(ingtypes.BackOAuthDenyBody).Value
+		case "negotiate":
+			// browser navigation is redirected, other requests are denied
+			path.AuthExternal.RedirectOnFail = startURI + sep + "rd=%[path]"
+			path.AuthExternal.RedirHTMLOnly = true
+			path.AuthExternal.RedirNoXHR = true
+			path.AuthExternal.DenyStatus = config.Get(ingtypes.BackOAuthDenyStatus).Int()
+			path.AuthExternal.DenyBody = config.Get(ingtypes.BackOAuthDenyBody).Value
+		default:
+			path.AuthExternal.RedirectOnFail = startURI + sep + "rd=%[path]"
+		}
+		path.AuthExternal.CookieSetSecure = config.Get(ingtypes.BackOAuthSetSecure).Bool()
+		if domain := config.Get(ingtypes.BackOAuthCookieDomain); domain.Value != "" {
+			cookieDomain := strings.ToLower(strings.TrimPrefix(domain.Value, "."))
+			hostname := path.Link.Hostname()
+			if !validDomainRegex.MatchString(cookieDomain) {
+				c.logger.Error("ignoring invalid oauth cookie domain '%s' on %v", domain.Value, domain.Source)
+			} else if hostname != cookieDomain && !strings.HasSuffix(hostname, "."+cookieDomain) {
+				c.logger.Error("ignoring oauth cookie domain on %v: host '%s' is not a subdomain of '%s'", domain.Source, hostname, cookieDomain)
+			} else {
+				path.AuthExternal.CookieDomain = cookieDomain
+			}
+		}
+	}
+}
+
+// findOAuthBackend finds the backend of a `[<namespace>/]<name>:<port>` service
+// or a `<namespace>_<name>_<port>` backend name. Backends of other namespaces are
+// only allowed if cross-namespace-services is allowed.
+func (c *updater) findOAuthBackend(namespace, oauthBackend string) (*hatypes.Backend, error) {
+	backNamespace, name, port, err := ingutils.ParseServiceOrBackend(oauthBackend)
+	if err != nil {
+		return nil, err
+	}
+	if backNamespace == "" {
+		backNamespace = namespace
+	}
+	if backNamespace != namespace && !c.options.DynamicConfig.CrossNamespaceServices {
+		return nil, fmt.Errorf("reading service '%s/%s' from namespace '%s' is denied, see cross-namespace-services configuration key", backNamespace, name, namespace)
+	}
+	// the oauth backend is pre-built by the ingress converter,
+	// see the auth-url counterpart regarding named ports
+	backend := c.haproxy.Backends().FindBackend(backNamespace, name, port)
+	if backend == nil {
+		return nil, fmt.Errorf("service '%s/%s:%s' was not found", backNamespace, name, port)
 	}
+	return backend, nil
 }
 
 func (c *updater) findBackend(namespace, uriPrefix string) *hatypes.HostBackend {
@@ -772,8 +2238,36 @@ func (c *updater) findBackend(namespace, uriPrefix string) *hatypes.HostBackend
 	return nil
 }
 
+// oauthAccessTokenHeader is the oauth2-proxy response header with the access
+// token, when it is configured with --pass-access-token and --set-xauthrequest.
+const oauthAccessTokenHeader = "X-Auth-Request-Access-Token"
+
 var validDomainRegex = regexp.MustCompile(`^([A-Za-z0-9-]{1,63}\.)+[A-Za-z]{2,6}$`)
 
+func (c *updater) buildBackendPodMaintenance(d *backData) {
+	key := d.mapper.Get(ingtypes.BackPodMaintenanceKey).Value
+	if key == "" {
+		return
+	}
+	for _, ep := range d.backend.Endpoints {
+		if ep.TargetRef == "" {
+			continue
+		}
+		// tracking the pod, so changing its labels or annotations
+		// updates the backend, even if the key is missing right now
+		c.tracker.TrackNames(convtypes.ResourcePod, ep.TargetRef, convtypes.ResourceHABackend, d.backend.ID)
+		pod, err := c.cache.GetPod(ep.TargetRef)
+		if err != nil {
+			continue
+		}
+		value, found := pod.Labels[key]
+		if !found {
+			value = pod.Annotations[key]
+		}
+		ep.Maintenance = strings.ToLower(value) == "true"
+	}
+}
+
 func (c *updater) buildBackendProtocol(d *backData) {
 	proto := d.mapper.Get(ingtypes.BackBackendProtocol)
 	var protocol string
@@ -809,7 +2303,12 @@ func (c *updater) buildBackendProtocol(d *backData) {
 	}
 	if crt := d.mapper.Get(ingtypes.BackSecureCrtSecret); crt.Value != "" {
 		var crtFile convtypes.CrtFile
+		var denied bool
 		namespace, name, err := crt.NamespacedName()
+		if err == nil {
+			err = c.checkCrossNamespaceSecret(crt, c.options.DynamicConfig.CrossNamespaceSecretCertificate)
+			denied = err != nil
+		}
 		if err == nil {
 			crtFile, err = c.cache.GetTLSSecretPath(
 				namespace,
@@ -820,7 +2319,9 @@ func (c *updater) buildBackendProtocol(d *backData) {
 		if err == nil {
 			d.backend.Server.CrtFilename = crtFile.Filename
 			d.backend.Server.CrtHash = crtFile.SHA1Hash
-		} else {
+		} else if denied {
+			c.logger.Error("skipping client certificate on %s: %v", crt.Source.String(), err)
+		} else if !c.secrets.ReportedMissing(crt.Source, err) {
 			c.logger.Warn("skipping client certificate on %s: %v", crt.Source.String(), err)
 		}
 	}
@@ -847,7 +2348,12 @@ func (c *updater) buildBackendProtocol(d *backData) {
 	}
 	if ca := d.mapper.Get(ingtypes.BackSecureVerifyCASecret); ca.Value != "" {
 		var caFile, crlFile convtypes.File
+		var denied bool
 		namespace, name, err := ca.NamespacedName()
+		if err == nil {
+			err = c.checkCrossNamespaceSecret(ca, c.options.DynamicConfig.CrossNamespaceSecretCA)
+			denied = err != nil
+		}
 		if err == nil {
 			caFile, crlFile, err = c.cache.GetCASecretPath(
 				namespace,
@@ -860,7 +2366,9 @@ func (c *updater) buildBackendProtocol(d *backData) {
 			d.backend.Server.CAHash = caFile.SHA1Hash
 			d.backend.Server.CRLFilename = crlFile.Filename
 			d.backend.Server.CRLHash = crlFile.SHA1Hash
-		} else {
+		} else if denied {
+			c.logger.Error("skipping CA on %s: %v", ca.Source.String(), err)
+		} else if !c.secrets.ReportedMissing(ca.Source, err) {
 			c.logger.Warn("skipping CA on %s: %v", ca.Source.String(), err)
 		}
 	}
@@ -885,13 +2393,40 @@ func (c *updater) buildBackendProxyProtocol(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendRetryBudget(d *backData) {
+	budget := d.mapper.Get(ingtypes.BackRetryBudgetWarn)
+	if budget.Value == "" {
+		return
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(budget.Value, "%"), 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		c.logger.Warn("ignoring invalid retry budget percentage on %v: %s", budget.Source, budget.Value)
+		return
+	}
+	d.backend.RetryBudgetWarn = pct
+}
+
 func (c *updater) buildBackendRewriteURL(d *backData) {
+	var paths []*hatypes.BackendPath
+	var rewrites []*ConfigValue
 	for _, path := range d.backend.Paths {
 		config := d.mapper.GetConfig(path.Link)
 		rewrite := config.Get(ingtypes.BackRewriteTarget)
 		if rewrite == nil || rewrite.Value == "" {
 			continue
 		}
+		paths = append(paths, path)
+		rewrites = append(rewrites, rewrite)
+	}
+	if len(paths) == 0 {
+		return
+	}
+	n := c.limits.checkRewritePaths(rewrites[0].Source, len(paths))
+	for i, path := range paths[:n] {
+		rewrite := rewrites[i]
+		if !c.checkSafeValue(rewrite.Source, ingtypes.BackRewriteTarget, rewrite.Value) {
+			continue
+		}
 		if !validURLRegex.MatchString(rewrite.Value) {
 			c.logger.Warn(
 				"rewrite-target does not allow white spaces or single/double quotes on %v: '%s'",
@@ -902,6 +2437,49 @@ func (c *updater) buildBackendRewriteURL(d *backData) {
 	}
 }
 
+var proxyRedirectPathRegex = regexp.MustCompile(`^/[^"' ]*$`)
+
+func (c *updater) buildBackendProxyRedirect(d *backData) {
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link)
+		redir := config.Get(ingtypes.BackProxyRedirect)
+		if redir.Value == "" {
+			continue
+		}
+		var from, to string
+		if redir.Value == "auto" {
+			rewrite := path.RewriteURL
+			if rewrite == "" {
+				c.logger.Warn("ignoring proxy-redirect auto mode on %v: path '%s' has no rewrite-target", redir.Source, path.Path())
+				continue
+			}
+			// reverses the replace-path of the rewrite-target, which adds
+			// a slash after the rewrite if the path ends with a slash
+			from, to = rewrite, path.Path()
+			if rewrite == "/" {
+				to = strings.TrimSuffix(to, "/") + "/"
+			} else if strings.HasSuffix(to, "/") {
+				from = strings.TrimSuffix(from, "/") + "/"
+			}
+		} else {
+			prefixes := strings.Fields(redir.Value)
+			if len(prefixes) != 2 || !proxyRedirectPathRegex.MatchString(prefixes[0]) || !proxyRedirectPathRegex.MatchString(prefixes[1]) {
+				c.logger.Warn("ignoring invalid proxy-redirect on %v: '%s', expecting 'auto' or two absolute paths", redir.Source, redir.Value)
+				continue
+			}
+			from, to = prefixes[0], prefixes[1]
+		}
+		if from == to {
+			continue
+		}
+		path.ProxyRedirect = hatypes.ProxyRedirect{
+			From: from,
+			To:   to,
+			Host: config.Get(ingtypes.BackProxyRedirectHost).Bool(),
+		}
+	}
+}
+
 var epNamingRegex = regexp.MustCompile(`^(seq(uence)?|pod|ip)$`)
 
 func (c *updater) buildBackendServerNaming(d *backData) {
@@ -955,6 +2533,107 @@ func (c *updater) buildBackendSourceAddressIntf(d *backData) {
 	d.backend.SourceIPs = sourceIPs
 }
 
+func (c *updater) buildBackendSlowStart(d *backData) {
+	slowStart := d.mapper.Get(ingtypes.BackSlowStart)
+	if slowStart.Value != "" {
+		d.backend.Server.SlowStart = c.validateTime(slowStart)
+	}
+	window := d.mapper.Get(ingtypes.BackWarmUpWindow)
+	if window.Value == "" {
+		return
+	}
+	if d.backend.Server.SlowStart == "" {
+		c.logger.Warn("ignoring warm-up window on %v: slow-start is not configured", window.Source)
+		return
+	}
+	duration, ok := TimeToDuration(window.Value)
+	if !ok || duration == 0 {
+		c.logger.Warn("ignoring invalid warm-up window on %v: %s", window.Source, window.Value)
+		return
+	}
+	percent := func(cfg *ConfigValue) (int, bool) {
+		pct, err := strconv.Atoi(strings.TrimSuffix(cfg.Value, "%"))
+		if err != nil || pct < 0 || pct > 100 {
+			c.logger.Warn("ignoring warm-up due to an invalid capacity percentage on %v: %s", cfg.Source, cfg.Value)
+			return 0, false
+		}
+		return pct, true
+	}
+	minCapacity, ok1 := percent(d.mapper.Get(ingtypes.BackWarmUpMinCapacity))
+	abortCapacity, ok2 := percent(d.mapper.Get(ingtypes.BackWarmUpAbortCapacity))
+	if !ok1 || !ok2 {
+		return
+	}
+	if abortCapacity >= minCapacity {
+		c.logger.Warn("ignoring warm-up on %v: abort capacity %d%% should be lesser than the min capacity %d%%", window.Source, abortCapacity, minCapacity)
+		return
+	}
+	d.backend.WarmUp = hatypes.BackendWarmUp{
+		Window:        duration,
+		MinCapacity:   minCapacity,
+		AbortCapacity: abortCapacity,
+	}
+}
+
+func (c *updater) buildBackendSplitBackends(d *backData) {
+	// the target backends are pre-built by the ingress converter,
+	// see the fallback backend counterpart
+	for _, path := range d.backend.Paths {
+		config := d.mapper.GetConfig(path.Link).Get(ingtypes.BackSplitBackends)
+		if config.Value == "" {
+			continue
+		}
+		var splits []hatypes.BackendSplit
+		total := 0
+		valid := true
+		for _, split := range utils.Split(config.Value, ",") {
+			svc, weight, found := strings.Cut(split, "=")
+			if !found {
+				c.logger.Warn("ignoring split backends on %v: expected [<namespace>/]<name>:<port>=<percent>: %s", config.Source, split)
+				valid = false
+				break
+			}
+			namespace, svcName, port, err := ingutils.ParseServicePort(svc)
+			if err != nil {
+				c.logger.Warn("ignoring split backends on %v: %v", config.Source, err)
+				valid = false
+				break
+			}
+			percent, err := strconv.Atoi(weight)
+			if err != nil || percent < 0 {
+				c.logger.Warn("ignoring split backends on %v: invalid percent of service '%s': %s", config.Source, svc, weight)
+				valid = false
+				break
+			}
+			total += percent
+			if namespace == "" && config.Source != nil {
+				namespace = config.Source.Namespace
+			}
+			backend := c.haproxy.Backends().FindBackend(namespace, svcName, port)
+			if backend == nil {
+				c.logger.Warn("ignoring split backends on %v: service '%s/%s:%s' was not found", config.Source, namespace, svcName, port)
+				continue
+			}
+			if backend.ID == d.backend.ID {
+				c.logger.Warn("ignoring split backends on %v: backend cannot split to itself", config.Source)
+				continue
+			}
+			splits = append(splits, hatypes.BackendSplit{
+				Target: backend.BackendID(),
+				Weight: percent,
+			})
+		}
+		if !valid {
+			continue
+		}
+		if total > 100 {
+			c.logger.Error("ignoring split backends on %v: the sum of the percents should not be greater than 100, found %d", config.Source, total)
+			continue
+		}
+		path.SplitBackends = splits
+	}
+}
+
 func (c *updater) buildBackendSSL(d *backData) {
 	d.backend.TLS.AddCertHeader = d.mapper.Get(ingtypes.BackAuthTLSCertHeader).Bool()
 	d.backend.TLS.FingerprintLower = d.mapper.Get(ingtypes.BackSSLFingerprintLower).Bool()
@@ -965,20 +2644,28 @@ func (c *updater) buildBackendSSL(d *backData) {
 	} else if sha2bits.Source != nil {
 		c.logger.Warn("ignoring SHA-2 fingerprint on %s due to an invalid number of bits: %d", sha2bits.Source, sha2bitsVal)
 	}
-	if cfg := d.mapper.Get(ingtypes.BackSSLCiphersBackend); cfg.Source != nil {
+	if cfg := c.getSafeValue(d.mapper, ingtypes.BackSSLCiphersBackend); cfg.Source != nil {
 		d.backend.Server.Ciphers = cfg.Value
 	}
-	if cfg := d.mapper.Get(ingtypes.BackSSLCipherSuitesBackend); cfg.Source != nil {
+	if cfg := c.getSafeValue(d.mapper, ingtypes.BackSSLCipherSuitesBackend); cfg.Source != nil {
 		d.backend.Server.CipherSuites = cfg.Value
 	}
-	d.backend.Server.Options = d.mapper.Get(ingtypes.BackSSLOptionsBackend).Value
+	d.backend.Server.Options = c.getSafeValue(d.mapper, ingtypes.BackSSLOptionsBackend).Value
 }
 
 func (c *updater) buildBackendSSLRedirect(d *backData) {
 	noTLSRedir := utils.Split(d.mapper.Get(ingtypes.GlobalNoTLSRedirectLocations).Value, ",")
 	for _, path := range d.backend.Paths {
-		redir := path.Host != nil && path.Host.UseTLS() &&
-			d.mapper.GetConfig(path.Link).Get(ingtypes.BackSSLRedirect).Bool()
+		config := d.mapper.GetConfig(path.Link)
+		sslRedirect := config.Get(ingtypes.BackSSLRedirect)
+		redir := sslRedirect.Bool()
+		if path.Host != nil && sslRedirect.Source == nil {
+			// not configured in the path, ssl-redirect-host overrides the global default
+			if hostRedir, found := path.Host.SSLRedirect(); found {
+				redir = hostRedir
+			}
+		}
+		redir = redir && path.Host != nil && path.Host.UseTLS()
 		if redir {
 			for _, noredir := range noTLSRedir {
 				if strings.HasPrefix(path.Path(), noredir) {
@@ -987,6 +2674,10 @@ func (c *updater) buildBackendSSLRedirect(d *backData) {
 			}
 		}
 		path.SSLRedirect = redir
+		path.SSLRedirectPort = 0
+		if redir {
+			path.SSLRedirectPort = c.validateHTTPSPort(config.Get(ingtypes.HostHTTPSRedirectPort))
+		}
 	}
 }
 
@@ -1003,9 +2694,7 @@ func (c *updater) buildBackendTimeout(d *backData) {
 	if cfg := d.mapper.Get(ingtypes.BackTimeoutQueue); cfg.Source != nil {
 		d.backend.Timeout.Queue = c.validateTime(cfg)
 	}
-	if cfg := d.mapper.Get(ingtypes.BackTimeoutServer); cfg.Source != nil {
-		d.backend.Timeout.Server = c.validateTime(cfg)
-	}
+	c.buildBackendTimeoutServer(d)
 	if cfg := d.mapper.Get(ingtypes.BackTimeoutServerFin); cfg.Source != nil {
 		d.backend.Timeout.ServerFin = c.validateTime(cfg)
 	}
@@ -1014,6 +2703,169 @@ func (c *updater) buildBackendTimeout(d *backData) {
 	}
 }
 
+func (c *updater) buildBackendTimeoutServer(d *backData) {
+	type pathTimeout struct {
+		path     *hatypes.BackendPath
+		cfg      *ConfigValue
+		duration time.Duration
+	}
+	var timeouts []*pathTimeout
+	var shortest *pathTimeout
+	invalid := map[ConfigValue]bool{}
+	durations := map[ConfigValue]time.Duration{}
+	for _, path := range d.backend.Paths {
+		cfg := d.mapper.GetConfig(path.Link).Get(ingtypes.BackTimeoutServer)
+		if cfg.Value == "" || invalid[*cfg] {
+			continue
+		}
+		duration, found := durations[*cfg]
+		if !found {
+			var ok bool
+			duration, ok = TimeToDuration(cfg.Value)
+			if !ok {
+				// just log the invalid value, once per source
+				c.validateTime(cfg)
+				invalid[*cfg] = true
+				continue
+			}
+			durations[*cfg] = duration
+		}
+		timeout := &pathTimeout{path: path, cfg: cfg, duration: duration}
+		timeouts = append(timeouts, timeout)
+		if shortest == nil || duration < shortest.duration {
+			shortest = timeout
+		}
+	}
+	if shortest == nil {
+		return
+	}
+	var overrides []*pathTimeout
+	for _, timeout := range timeouts {
+		if timeout.duration > shortest.duration {
+			overrides = append(overrides, timeout)
+		}
+	}
+	// set-timeout was introduced on haproxy 2.4
+	if len(overrides) > 0 && !utils.VersionAtLeast(c.options.HAProxyVersion, 2, 4) {
+		c.logger.Warn("per path timeout-server on %v needs set-timeout, not supported by haproxy %s, using the same timeout on all paths",
+			overrides[0].cfg.Source, c.options.HAProxyVersion)
+		if cfg := d.mapper.Get(ingtypes.BackTimeoutServer); cfg.Source != nil {
+			d.backend.Timeout.Server = c.validateTime(cfg)
+		}
+		return
+	}
+	// the shortest timeout is used as the backend default, declared only if it
+	// doesn't come from the global config, otherwise the defaults section is used
+	if shortest.cfg.Source != nil {
+		d.backend.Timeout.Server = c.validateTime(shortest.cfg)
+	}
+	for _, timeout := range overrides {
+		timeout.path.TimeoutServer = c.validateTime(timeout.cfg)
+	}
+	connect := d.mapper.Get(ingtypes.BackTimeoutConnect)
+	connectDuration, ok := TimeToDuration(connect.Value)
+	if !ok {
+		return
+	}
+	warned := map[ConfigValue]bool{}
+	for _, timeout := range timeouts {
+		if timeout.cfg.Source == nil || timeout.duration >= connectDuration {
+			continue
+		}
+		if !warned[*timeout.cfg] {
+			warned[*timeout.cfg] = true
+			c.logger.Warn("timeout-server %s on %v is lower than timeout-connect %s",
+				timeout.cfg.Value, timeout.cfg.Source, connect.Value)
+		}
+	}
+}
+
+func (c *updater) buildBackendTrafficClass(d *backData) {
+	classes := map[string]bool{}
+	for _, class := range c.haproxy.Global().TrafficClasses {
+		classes[class.Name] = true
+	}
+	readClasses := func(key string) []string {
+		config := d.mapper.Get(key)
+		var names []string
+		for _, name := range utils.Split(config.Value, ",") {
+			if !classes[name] {
+				c.logger.Error("ignoring traffic class '%s' on %v: class was not declared in %s", name, config.Source, ingtypes.GlobalTrafficClasses)
+				continue
+			}
+			names = append(names, name)
+		}
+		return names
+	}
+	d.backend.TrafficClass.Deny = readClasses(ingtypes.BackDenylistClass)
+	d.backend.TrafficClass.LimitExempt = readClasses(ingtypes.BackRateLimitExemptClass)
+
+	// the target backends are pre-built by the ingress converter,
+	// see the fallback backend counterpart
+	routes := d.mapper.Get(ingtypes.BackUseBackendClass)
+	for _, route := range utils.Split(routes.Value, ",") {
+		name, svc, found := strings.Cut(route, "=")
+		if !found {
+			c.logger.Warn("ignoring use backend class on %v: expected <class>=[<namespace>/]<name>:<port>: %s", routes.Source, route)
+			continue
+		}
+		if !classes[name] {
+			c.logger.Error("ignoring traffic class '%s' on %v: class was not declared in %s", name, routes.Source, ingtypes.GlobalTrafficClasses)
+			continue
+		}
+		namespace, svcName, port, err := ingutils.ParseServicePort(svc)
+		if err != nil {
+			c.logger.Warn("ignoring use backend class on %v: %v", routes.Source, err)
+			continue
+		}
+		if namespace == "" && routes.Source != nil {
+			namespace = routes.Source.Namespace
+		}
+		backend := c.haproxy.Backends().FindBackend(namespace, svcName, port)
+		if backend == nil {
+			c.logger.Warn("ignoring use backend class on %v: service '%s/%s:%s' was not found", routes.Source, namespace, svcName, port)
+			continue
+		}
+		if backend.ID == d.backend.ID {
+			c.logger.Warn("ignoring use backend class on %v: backend cannot route to itself", routes.Source)
+			continue
+		}
+		d.backend.TrafficClass.Routes = append(d.backend.TrafficClass.Routes, hatypes.BackendClassRoute{
+			Class:  name,
+			Target: backend.BackendID(),
+		})
+	}
+}
+
+func (c *updater) buildBackendSPOE(d *backData) {
+	agentName := d.mapper.Get(ingtypes.BackSPOEAgent)
+	if agentName.Value == "" {
+		return
+	}
+	if d.backend.ModeTCP {
+		c.logger.Warn("ignoring SPOE agent on %v: backend is in TCP mode", agentName.Source)
+		return
+	}
+	if agentName.Value == ingutils.SPOEAgentModSecurity {
+		c.logger.Warn("ignoring SPOE agent on %v: modsecurity agent is attached via %s", agentName.Source, ingtypes.BackWAF)
+		return
+	}
+	agent := c.haproxy.Global().FindSPOEAgent(agentName.Value)
+	if agent == nil {
+		c.logger.Error("ignoring SPOE agent '%s' on %v: agent was not declared in %s", agentName.Value, agentName.Source, ingtypes.GlobalSPOEAgents)
+		return
+	}
+	onError := d.mapper.Get(ingtypes.BackSPOEOnError)
+	switch onError.Value {
+	case "deny":
+		d.backend.SPOE.DenyOnError = true
+	case "continue":
+	default:
+		c.logger.Warn("ignoring invalid SPOE on error action '%s' on %v, using 'continue' instead", onError.Value, onError.Source)
+	}
+	d.backend.SPOE.Agent = agent.Name
+}
+
 func (c *updater) buildBackendWAF(d *backData) {
 	for _, path := range d.backend.Paths {
 		config := d.mapper.GetConfig(path.Link)
@@ -1022,7 +2874,7 @@ func (c *updater) buildBackendWAF(d *backData) {
 		if module == "" {
 			continue
 		}
-		if module != "modsecurity" {
+		if module != ingutils.SPOEAgentModSecurity {
 			c.logger.Warn("ignoring invalid WAF module on %s: %s", waf.Source, module)
 			continue
 		}
@@ -1042,6 +2894,10 @@ func (c *updater) buildBackendWhitelistHTTP(d *backData) {
 		for _, path := range d.backend.Paths {
 			config := d.mapper.GetConfig(path.Link)
 			path.AllowedIPHTTP, path.DeniedIPHTTP = c.readAccessConfig(config)
+			if d.backend.AccessDebug {
+				path.AllowedIPHTTP.ID = accessRuleID(ingtypes.BackAllowlistSourceRange, path.AllowedIPHTTP)
+				path.DeniedIPHTTP.ID = accessRuleID(ingtypes.BackDenylistSourceRange, path.DeniedIPHTTP)
+			}
 		}
 	}
 }
@@ -1053,19 +2909,32 @@ func (c *updater) buildBackendWhitelistTCP(d *backData) {
 	d.backend.AllowedIPTCP, d.backend.DeniedIPTCP = c.readAccessConfig(d.mapper)
 }
 
+// accessRuleID identifies the rule of an access config in the responses of
+// access-debug-header. The identifier is derived from the configuration key
+// and the CIDR group, so it does not change between syncs.
+func accessRuleID(key string, access hatypes.AccessConfig) string {
+	if len(access.Rule) == 0 && len(access.Exception) == 0 {
+		return ""
+	}
+	group := strings.Join(access.Rule, ",") + "!" + strings.Join(access.Exception, ",")
+	return fmt.Sprintf("%s-%08x", key, crc32.ChecksumIEEE([]byte(group)))
+}
+
 func (c *updater) readAccessConfig(config ConfigValueGetter) (allowed, denied hatypes.AccessConfig) {
+	allowkey := ingtypes.BackAllowlistSourceRange
 	allowcfg := config.Get(ingtypes.BackAllowlistSourceRange)
 	denycfg := config.Get(ingtypes.BackDenylistSourceRange)
 	whitecfg := config.Get(ingtypes.BackWhitelistSourceRange)
-	headercfg := config.Get(ingtypes.BackAllowlistSourceHeader)
+	headercfg := c.getSafeValue(config, ingtypes.BackAllowlistSourceHeader)
 	if allowcfg.Value == "" {
+		allowkey = ingtypes.BackWhitelistSourceRange
 		allowcfg = whitecfg
 	} else if whitecfg.Value != "" {
 		c.logger.Warn("both allowlist and whitelist were used on %s, ignoring whitelist content: %s",
 			whitecfg.Source, whitecfg.Value)
 	}
-	allowed.Rule, allowed.Exception = c.splitDualCIDR(allowcfg)
-	denied.Rule, denied.Exception = c.splitDualCIDR(denycfg)
+	allowed.Rule, allowed.Exception = c.splitDualCIDR(allowkey, allowcfg)
+	denied.Rule, denied.Exception = c.splitDualCIDR(ingtypes.BackDenylistSourceRange, denycfg)
 	allowed.SourceHeader = headercfg.Value
 	return allowed, denied
 }
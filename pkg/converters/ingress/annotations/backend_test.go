@@ -17,26 +17,111 @@ limitations under the License.
 package annotations
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math/big"
 	"net"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
+func TestAccessDebug(t *testing.T) {
+	testCases := []struct {
+		annDefault map[string]string
+		hardening  bool
+		modeTCP    bool
+		expDebug   bool
+		expAllowID string
+		expDenyID  string
+		logging    string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			annDefault: map[string]string{ingtypes.BackAccessDebugHeader: "true"},
+			expDebug:   true,
+			expAllowID: "allowlist-source-range-48b7725b",
+			expDenyID:  "denylist-source-range-e47bc60a",
+		},
+		// 2
+		{
+			annDefault: map[string]string{ingtypes.BackAccessDebugHeader: "true"},
+			hardening:  true,
+			logging:    `WARN ignoring access debug header on <global>: production-hardening is enabled`,
+		},
+		// 3
+		{
+			annDefault: map[string]string{ingtypes.BackAccessDebugHeader: "true"},
+			modeTCP:    true,
+			logging:    `WARN ignoring access debug header on <global>: backend is not http`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	cidrlist := map[string]map[string]string{
+		"/": {
+			ingtypes.BackAllowlistSourceRange: "10.0.0.0/8,192.168.0.0/16",
+		},
+		"/path": {
+			ingtypes.BackDenylistSourceRange: "192.168.95.0/24,!192.168.95.128/28",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().ProductionHardening = test.hardening
+		d := c.createBackendMappingData("default/app", source, test.annDefault, cidrlist, []string{"/", "/path"})
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.buildBackendAccessDebug(d)
+		u.buildBackendWhitelistHTTP(d)
+		var allowID, denyID string
+		for _, path := range d.backend.Paths {
+			if path.AllowedIPHTTP.ID != "" {
+				allowID = path.AllowedIPHTTP.ID
+			}
+			if path.DeniedIPHTTP.ID != "" {
+				denyID = path.DeniedIPHTTP.ID
+			}
+		}
+		c.compareObjects("access debug", i, d.backend.AccessDebug, test.expDebug)
+		c.compareObjects("allow rule id", i, allowID, test.expAllowID)
+		c.compareObjects("deny rule id", i, denyID, test.expDenyID)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestAffinity(t *testing.T) {
 	testCase := []struct {
-		annDefault map[string]string
-		ann        map[string]string
-		expCookie  hatypes.Cookie
-		expLogging string
+		annDefault  map[string]string
+		ann         map[string]string
+		modeTCP     bool
+		balance     string
+		expCookie   hatypes.Cookie
+		expEpCookie hatypes.EndpointCookieStrategy
+		expStick    hatypes.BackendStickAffinity
+		expLogging  string
 	}{
 		// 0
 		{
@@ -173,1631 +258,4966 @@ func TestAffinity(t *testing.T) {
 				ingtypes.BackSessionCookieDomain: "example.com",
 				ingtypes.BackSessionCookieShared: "true",
 			},
-			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Domain: "example.com", Keywords: "indirect nocache httponly"},
-			expLogging: "WARN ignoring 'session-cookie-shared' configuration on ingress 'default/ing1', domain is configured as 'example.com', which has precedence",
+			expCookie:   hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Domain: "example.com", Keywords: "indirect nocache httponly"},
+			expEpCookie: hatypes.EpCookiePodNameHash,
+			expLogging:  "WARN ignoring 'session-cookie-shared' configuration on ingress 'default/ing1', domain is configured as 'example.com', which has precedence",
 		},
-	}
-
-	source := &Source{
-		Namespace: "default",
-		Name:      "ing1",
-		Type:      "ingress",
-	}
-	for i, test := range testCase {
-		c := setup(t)
-		u := c.createUpdater()
-		d := c.createBackendData("default/app", source, test.ann, test.annDefault)
-		u.buildBackendAffinity(d)
-		c.compareObjects("affinity", i, d.backend.Cookie, test.expCookie)
-		c.logger.CompareLogging(test.expLogging)
-		c.teardown()
-	}
-}
-
-func TestAuthExternal(t *testing.T) {
-	testCase := []struct {
-		global     bool
-		url        string
-		signin     string
-		method     string
-		hdrReq     string
-		hdrSucceed string
-		hdrFail    string
-		hdrEmpty   bool
-		isExternal bool
-		hasLua     bool
-		expBack    hatypes.AuthExternal
-		expIP      []string
-		logging    string
-	}{
-		// 0
+		// 15
 		{
-			url:     "10.0.0.1:8080/app",
-			expBack: hatypes.AuthExternal{AlwaysDeny: true},
-			logging: `WARN ignoring URL on ingress 'default/ing1': invalid URL syntax: 10.0.0.1:8080/app`,
+			annDefault: map[string]string{
+				ingtypes.BackCookieAutoSecure: "true",
+			},
+			ann: map[string]string{
+				ingtypes.BackAffinity: "cookie",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", AutoSecure: true},
 		},
-		// 1
+		// 16
 		{
-			url:     "fail://app1.local",
-			expBack: hatypes.AuthExternal{AlwaysDeny: true},
-			logging: `WARN ignoring auth URL with an invalid protocol on ingress 'default/ing1': fail`,
+			annDefault: map[string]string{
+				ingtypes.BackCookieAutoSecure: "true",
+			},
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackCookieAutoSecure: "false",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
 		},
-		// 2
+		// 17
 		{
-			url:        "http://app1.local",
-			isExternal: true,
-			expBack:    hatypes.AuthExternal{AlwaysDeny: true},
-			logging:    `WARN external authentication on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config`,
+			annDefault: map[string]string{
+				ingtypes.BackCookieAutoSecure: "true",
+			},
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieSameSite: "true",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", SameSite: "None", Secure: true},
 		},
-		// 3
+		// 18
 		{
-			url: "http://app1.local",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
+			annDefault: map[string]string{
+				ingtypes.BackCookieAutoSecure: "true",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieKeywords: "nocache attr SameSite=Lax",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "nocache attr SameSite=Lax"},
 		},
-		// 4
+		// 19
 		{
-			url:        "http://app1.local",
-			isExternal: true,
-			hasLua:     true,
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityURLParam: "jsessionid",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			balance:   "roundrobin",
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", URLParam: "jsessionid"},
 		},
-		// 5
+		// 20
 		{
-			url: "http://app2.local/app",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/app",
+			ann: map[string]string{
+				ingtypes.BackAffinityURLParam: "jsessionid",
 			},
-			expIP: []string{"10.0.0.3:80", "10.0.0.4:80"},
+			expLogging: "WARN ignoring affinity URL parameter on ingress 'default/ing1': cookie affinity is not configured",
 		},
-		// 6
+		// 21
 		{
-			url:     "http://appfail.local/app",
-			expBack: hatypes.AuthExternal{AlwaysDeny: true},
-			logging: `WARN ignoring auth URL with an invalid domain on ingress 'default/ing1': host not found: appfail.local`,
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityURLParam: "session id",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN ignoring invalid affinity URL parameter name on ingress 'default/ing1': session id",
 		},
-		// 7
+		// 22
 		{
-			url: "http://app1.local:8080/app",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/app",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityURLParam: "jsessionid",
 			},
-			expIP: []string{"10.0.0.2:8080"},
+			modeTCP:    true,
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN ignoring affinity URL parameter on ingress 'default/ing1': backend is in TCP mode",
 		},
-		// 8
+		// 23
 		{
-			url: "http://10.0.0.200:8080/app",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/app",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityURLParam: "sid",
 			},
-			expIP: []string{"10.0.0.200:8080"},
+			balance:    "leastconn",
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", URLParam: "sid"},
+			expLogging: "WARN balance algorithm 'leastconn' on ingress 'default/ing1' is replaced by the affinity URL parameter",
 		},
-		// 9
+		// 24
 		{
-			url:    "http://10.0.0.200:8080/app",
-			signin: "http://invalid'",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/app",
+			annDefault: map[string]string{
+				ingtypes.BackCookieAutoSecure: "true",
 			},
-			expIP:   []string{"10.0.0.200:8080"},
-			logging: `WARN ignoring invalid sign-in URL on ingress 'default/ing1': http://invalid'`,
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieSameSite: "None",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", SameSite: "None", Secure: true},
 		},
-		// 10
+		// 25
 		{
-			url:    "http://app1.local/oauth2/auth",
-			signin: "http://app1.local/oauth2/start?rd=%[path]",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/oauth2/auth",
-				HeadersFail:     []string{"-"},
-				RedirectOnFail:  "http://app1.local/oauth2/start?rd=%[path]",
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieSameSite: "lax",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", SameSite: "Lax"},
 		},
-		// 11
+		// 26
 		{
-			url:        "http://app1.local/oauth2/auth",
-			signin:     "http://app1.local/oauth2/start?rd=%[path]",
-			hdrSucceed: "x-mail",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/oauth2/auth",
-				HeadersSucceed:  []string{"x-mail"},
-				HeadersFail:     []string{"-"},
-				RedirectOnFail:  "http://app1.local/oauth2/start?rd=%[path]",
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieSameSite: "Strict",
+				ingtypes.BackSessionCookieSecure:   "true",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", SameSite: "Strict", Secure: true},
 		},
-		// 12
+		// 27
 		{
-			url: "https://10.0.0.200/auth",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/auth",
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieSameSite: "Relaxed",
 			},
-			expIP: []string{"10.0.0.200:443"},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN ignoring invalid cookie SameSite attribute on ingress 'default/ing1': Relaxed",
 		},
-		// 13
+		// 28
 		{
-			url:     "svc://noservice",
-			expBack: hatypes.AuthExternal{AlwaysDeny: true},
-			logging: `WARN skipping auth-url on ingress 'default/ing1': missing service port: svc://noservice`,
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieHTTPOnly: "true",
+				ingtypes.BackSessionCookieSecure:   "true",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Secure: true},
 		},
-		// 14
+		// 29
 		{
-			url:     "svc://noservice:80",
-			expBack: hatypes.AuthExternal{AlwaysDeny: true},
-			logging: `WARN skipping auth-url on ingress 'default/ing1': service 'noservice:80' was not found`,
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieStrategy: "rewrite",
+				ingtypes.BackSessionCookieHTTPOnly: "true",
+				ingtypes.BackSessionCookieSameSite: "none",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "rewrite", SameSite: "None", Secure: true, HTTPOnly: true},
 		},
-		// 15
+		// 30
 		{
-			url:     "svc://authservice",
-			expBack: hatypes.AuthExternal{AlwaysDeny: true},
-			logging: `WARN skipping auth-url on ingress 'default/ing1': missing service port: svc://authservice`,
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieKeywords: "nocache secure",
+				ingtypes.BackSessionCookieSecure:   "true",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "nocache secure"},
 		},
-		// 16
+		// 31
 		{
-			url: "svc://authservice:80",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "cookie",
+				ingtypes.BackSessionCookieDomain: ".example.com",
 			},
-			expIP: []string{"10.0.0.11:8080"},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Domain: ".example.com", Keywords: "indirect nocache httponly"},
 		},
-		// 17
+		// 32
 		{
-			url: "svc://authservice:80/auth",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/auth",
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "cookie",
+				ingtypes.BackSessionCookieDomain: "https://example.com",
 			},
-			expIP: []string{"10.0.0.11:8080"},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN ignoring invalid cookie domain on ingress 'default/ing1': https://example.com",
 		},
-		// 18
+		// 33
 		{
-			url: "svc://default/authservice:80/auth",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/auth",
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "cookie",
+				ingtypes.BackSessionCookieDomain: "example .com",
+				ingtypes.BackSessionCookieShared: "true",
 			},
-			expIP: []string{"10.0.0.11:8080"},
+			expCookie:   hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Shared: true},
+			expEpCookie: hatypes.EpCookiePodNameHash,
+			expLogging:  "WARN ignoring invalid cookie domain on ingress 'default/ing1': example .com",
 		},
-		// 19
+		// 34
 		{
-			url:        "http://app1.local",
-			hdrSucceed: "x-mail,x-userid,x-auth",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				HeadersSucceed:  []string{"x-mail", "x-userid", "x-auth"},
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "cookie",
+				ingtypes.BackSessionCookieMaxIdle: "30m",
+				ingtypes.BackSessionCookieMaxLife: "1d",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", MaxIdle: 30 * time.Minute, MaxLife: 24 * time.Hour},
 		},
-		// 20
+		// 35
 		{
-			url:     "http://app1.local",
-			hdrFail: "x-uid,X-Message",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				HeadersFail:     []string{"x-uid", "X-Message"},
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "cookie",
+				ingtypes.BackSessionCookieMaxIdle: "30",
+				ingtypes.BackSessionCookieMaxLife: "500ms",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: `
+WARN ignoring invalid session-cookie-max-idle on ingress 'default/ing1': 30
+WARN ignoring invalid session-cookie-max-life on ingress 'default/ing1': 500ms`,
 		},
-		// 21
+		// 36
 		{
-			url:     "http://app1.local",
-			hdrFail: "x-uid,x-message",
-			signin:  "http://app1.local/oauth2/start?rd=%[path]",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				HeadersFail:     []string{"-"},
-				RedirectOnFail:  "http://app1.local/oauth2/start?rd=%[path]",
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieStrategy: "prefix",
+				ingtypes.BackSessionCookieMaxLife:  "8h",
 			},
-			expIP:   []string{"10.0.0.2:80"},
-			logging: `WARN ignoring 'auth-headers-fail' on ingress 'default/ing1' due to signin (redirect) configuration`,
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "prefix"},
+			expLogging: "WARN ignoring session-cookie-max-life on ingress 'default/ing1': cookie strategy 'prefix' does not support it",
 		},
-		// 22
+		// 37
 		{
-			url:    "http://app1.local",
-			hdrReq: "x-token",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				HeadersRequest:  []string{"x-token"},
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieName:     "JSESSIONID",
+				ingtypes.BackSessionCookieStrategy: "preserve",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			expCookie: hatypes.Cookie{Name: "JSESSIONID", Strategy: "insert", Keywords: "indirect nocache httponly", Preserve: true},
 		},
-		// 23
+		// 38
 		{
-			url:    "http://app1.local",
-			method: "HEAD",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				Method:          "HEAD",
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieStrategy: "insert preserve",
+				ingtypes.BackSessionCookieMaxIdle:  "30m",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", MaxIdle: 30 * time.Minute, Preserve: true},
 		},
-		// 24
+		// 39
 		{
-			url:    "http://app1.local",
-			method: "invalid()",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				Method:          "GET",
+			annDefault: map[string]string{
+				ingtypes.BackSessionCookieDynamic: "true",
 			},
-			expIP:   []string{"10.0.0.2:80"},
-			logging: `WARN invalid request method 'invalid()' on ingress 'default/ing1', using GET instead`,
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieStrategy: "preserve",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Preserve: true},
 		},
-		// 25
+		// 40
 		{
-			url:    "http://app1.local",
-			method: "*",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				Method:          "*",
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieStrategy: "preserve",
+				ingtypes.BackSessionCookieDynamic:  "true",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Preserve: true},
+			expLogging: "WARN ignoring 'session-cookie-dynamic' configuration on ingress 'default/ing1': cookie strategy 'preserve' does not support dynamic cookies",
 		},
-		// 26
+		// 41
 		{
-			url:    "http://app1.local",
-			method: "**",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				Method:          "GET",
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieStrategy: "prefix preserve",
 			},
-			expIP:   []string{"10.0.0.2:80"},
-			logging: `WARN invalid request method '**' on ingress 'default/ing1', using GET instead`,
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN invalid affinity cookie strategy 'prefix preserve' on ingress 'default/ing1', using 'insert' instead",
 		},
-		// 27
+		// 42
 		{
-			url:      "http://app1.local",
-			hdrEmpty: true,
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
-				HeadersRequest:  []string{"-"},
-				HeadersSucceed:  []string{"-"},
-				HeadersFail:     []string{"-"},
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			ann: map[string]string{
+				ingtypes.BackAffinity: "source-ip",
+			},
+			expStick: hatypes.BackendStickAffinity{Size: 204800, Expire: "30m"},
 		},
-		// 28
+		// 43
 		{
-			global: true,
-			url:    "http://app1.local",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/",
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
+				ingtypes.BackSessionCookieName:   "serverid",
 			},
-			expIP: []string{"10.0.0.2:80"},
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "source-ip",
+				ingtypes.BackAffinityTableSize:   "1m",
+				ingtypes.BackAffinityTableExpire: "8h",
+			},
+			expStick: hatypes.BackendStickAffinity{Size: 1048576, Expire: "8h"},
 		},
-		// 29
+		// 44
 		{
-			global:  true,
-			url:     "svc://authservice:80/auth",
-			expBack: hatypes.AuthExternal{AlwaysDeny: true},
-			logging: `WARN skipping auth-url on <global>: a globally configured auth-url is missing the namespace`,
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
+			},
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "source-ip",
+				ingtypes.BackSessionCookieName:     "serverid",
+				ingtypes.BackSessionCookieStrategy: "prefix",
+			},
+			expStick: hatypes.BackendStickAffinity{Size: 204800, Expire: "30m"},
+			expLogging: `
+WARN ignoring 'session-cookie-name' configuration on ingress 'default/ing1': affinity type is 'source-ip'
+WARN ignoring 'session-cookie-strategy' configuration on ingress 'default/ing1': affinity type is 'source-ip'`,
 		},
-		// 30
+		// 45
 		{
-			global: true,
-			url:    "svc://default/authservice:80/auth",
-			expBack: hatypes.AuthExternal{
-				AuthBackendName: "_auth_4001",
-				AuthPath:        "/auth",
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableExpire: "30m",
 			},
-			expIP: []string{"10.0.0.11:8080"},
-		},
-	}
-	defaultSource := &Source{
-		Namespace: "default",
-		Name:      "ing1",
-		Type:      "ingress",
-	}
-	lookupHost = func(host string) (addrs []string, err error) {
-		switch host {
-		case "app1.local":
-			return []string{"10.0.0.2"}, nil
-		case "app2.local":
-			return []string{"10.0.0.3", "10.0.0.4"}, nil
-		}
-		return nil, fmt.Errorf("host not found: %s", host)
-	}
-	for i, test := range testCase {
-		c := setup(t)
-		u := c.createUpdater()
-		c.haproxy.Frontend().AuthProxy.RangeStart = 4001
-		c.haproxy.Frontend().AuthProxy.RangeEnd = 4009
-		if test.isExternal {
-			c.haproxy.Global().External.IsExternal = true
-		}
-		c.haproxy.Global().External.HasLua = test.hasLua
-		// backend is used by svc protocol
-		b := c.haproxy.Backends().AcquireBackend("default", "authservice", "80")
-		b.AcquireEndpoint("10.0.0.11", 8080, "")
-		ann := map[string]map[string]string{
-			"/": {
-				ingtypes.BackAuthURL:    test.url,
-				ingtypes.BackAuthSignin: test.signin,
+			ann: map[string]string{
+				ingtypes.BackAffinity:          "source-ip",
+				ingtypes.BackAffinityTableSize: "10x",
 			},
-		}
-		if test.method != "" {
-			ann["/"][ingtypes.BackAuthMethod] = test.method
-		}
-		if test.hdrEmpty || test.hdrReq != "" {
-			ann["/"][ingtypes.BackAuthHeadersRequest] = test.hdrReq
-		}
-		if test.hdrEmpty || test.hdrSucceed != "" {
-			ann["/"][ingtypes.BackAuthHeadersSucceed] = test.hdrSucceed
-		}
-		if test.hdrEmpty || test.hdrFail != "" {
-			ann["/"][ingtypes.BackAuthHeadersFail] = test.hdrFail
-		}
-		defaults := map[string]string{
-			ingtypes.BackAuthExternalPlacement: "backend",
-			ingtypes.BackAuthHeadersRequest:    "*",
-			ingtypes.BackAuthHeadersSucceed:    "*",
-			ingtypes.BackAuthHeadersFail:       "*",
-			ingtypes.BackAuthMethod:            "GET",
-		}
-		var source *Source
-		if !test.global {
-			source = defaultSource
-		}
-		d := c.createBackendMappingData("default/app", source, defaults, ann, []string{"/"})
-		u.buildBackendAuthExternal(d)
-		back := d.backend.Paths[0].AuthExternal
-		var iplist []string
-		bindList := c.haproxy.Frontend().AuthProxy.BindList
-		if len(bindList) > 0 {
-			auth := c.haproxy.Backends().FindBackendID(bindList[0].Backend)
-			for _, ep := range auth.Endpoints {
-				iplist = append(iplist, fmt.Sprintf("%s:%d", ep.IP, ep.Port))
-			}
-		}
-		if back.AuthBackendName != "" {
-			// succeeded, config defaults
-			if test.expBack.Method == "" {
-				test.expBack.Method = "GET"
-			}
-			if test.expBack.HeadersRequest == nil {
-				test.expBack.HeadersRequest = []string{"*"}
-			}
-			if test.expBack.HeadersSucceed == nil {
-				test.expBack.HeadersSucceed = []string{"*"}
-			}
-			if test.expBack.HeadersFail == nil {
-				test.expBack.HeadersFail = []string{"*"}
-			}
-		}
-		c.compareObjects("auth external back", i, back, test.expBack)
-		c.compareObjects("auth external ip list", i, iplist, test.expIP)
-		c.logger.CompareLogging(test.logging)
-		c.teardown()
-	}
-}
-
-func TestAuthHTTP(t *testing.T) {
-	testCase := []struct {
-		paths        []string
-		source       *Source
-		annDefault   map[string]string
-		ann          map[string]map[string]string
-		secrets      conv_helper.SecretContent
-		expUserlists []*hatypes.Userlist
-		expConfig    map[string]hatypes.AuthHTTP
-		expLogging   string
-	}{
-		// 0
-		{
-			ann: map[string]map[string]string{},
+			expLogging: "WARN ignoring source-ip affinity on ingress 'default/ing1': invalid table size on 'affinity-table-size': 10x",
 		},
-		// 1
+		// 46
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackAuthSecret: "mypwd",
-				},
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize: "200k",
 			},
-			expLogging: "ERROR error reading basic authentication on ingress 'default/ing1': secret not found: 'default/mypwd'",
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "source-ip",
+				ingtypes.BackAffinityTableExpire: "1week",
+			},
+			expLogging: `
+WARN ignoring invalid time format on ingress 'default/ing1': 1week
+WARN ignoring source-ip affinity on backend 'default_app_8080': table expire is mandatory`,
 		},
-		// 2
+		// 47
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackAuthSecret: "mypwd",
-				},
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
 			},
-			secrets:    conv_helper.SecretContent{"default/mypwd": {"xx": []byte{}}},
-			expLogging: "ERROR error reading basic authentication on ingress 'default/ing1': secret 'default/mypwd' does not have file/key 'auth'",
+			ann: map[string]string{
+				ingtypes.BackAffinity:           "request-header",
+				ingtypes.BackAffinityHeaderName: "X-Tenant-Id",
+			},
+			expStick: hatypes.BackendStickAffinity{Header: "X-Tenant-Id", Size: 204800, Expire: "30m"},
 		},
-		// 3
+		// 48
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackAuthSecret: "mypwd",
-					ingtypes.BackAuthRealm:  `"a name"`,
-				},
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
 			},
-			secrets: conv_helper.SecretContent{"default/mypwd": {"auth": []byte("usr1::clear1")}},
-			expUserlists: []*hatypes.Userlist{{Name: "default_mypwd", Users: []hatypes.User{
-				{Name: "usr1", Passwd: "clear1", Encrypted: false},
-			}}},
-			expLogging: "WARN ignoring auth-realm with quotes on ingress 'default/ing1'",
+			ann: map[string]string{
+				ingtypes.BackAffinity: "request-header",
+			},
+			expLogging: "ERROR ignoring request-header affinity on ingress 'default/ing1': missing 'affinity-header-name' configuration",
 		},
-		// 4
+		// 49
 		{
-			source: &Source{Namespace: "ns1", Name: "i1", Type: "ingress"},
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackAuthSecret: "mypwd",
-				},
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
 			},
-			secrets:      conv_helper.SecretContent{"ns1/mypwd": {"auth": []byte{}}},
-			expUserlists: []*hatypes.Userlist{{Name: "ns1_mypwd"}},
-			expLogging:   "WARN userlist on ingress 'ns1/i1' for basic authentication is empty",
+			ann: map[string]string{
+				ingtypes.BackAffinity:           "request-header",
+				ingtypes.BackAffinityHeaderName: "X-Tenant-Id",
+			},
+			modeTCP:    true,
+			expLogging: "WARN ignoring request-header affinity on ingress 'default/ing1': backend is in TCP mode",
 		},
-		// 5
+		// 50
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackAuthSecret: "basicpwd",
-				},
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
 			},
-			secrets:      conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("fail")}},
-			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd"}},
+			ann: map[string]string{
+				ingtypes.BackAffinity:           "request-header",
+				ingtypes.BackAffinityHeaderName: "X-Tenant-Id)",
+			},
+			expLogging: "WARN ignoring request-header affinity on ingress 'default/ing1': invalid header name: X-Tenant-Id)",
+		},
+		// 51
+		{
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
+			},
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "request-header",
+				ingtypes.BackAffinityHeaderName:   "X-Tenant-Id",
+				ingtypes.BackSessionCookieDynamic: "false",
+				ingtypes.BackAffinityURLParam:     "tenant",
+			},
+			expStick: hatypes.BackendStickAffinity{Header: "X-Tenant-Id", Size: 204800, Expire: "30m"},
 			expLogging: `
-WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'fail' line 1
-WARN userlist on ingress 'default/ing1' for basic authentication is empty`,
+WARN ignoring 'affinity-url-param' configuration on ingress 'default/ing1': affinity type is 'request-header'
+WARN ignoring 'session-cookie-dynamic' configuration on ingress 'default/ing1': affinity type is 'request-header'`,
 		},
-		// 6
+		// 52
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackAuthSecret: "basicpwd",
-				},
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
 			},
-			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`
-usr1::clearpwd1
-nopwd`)}},
-			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
-				{Name: "usr1", Passwd: "clearpwd1", Encrypted: false},
-			}}},
-			expLogging: "WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'nopwd' line 3",
+			ann: map[string]string{
+				ingtypes.BackAffinity:           "source-ip",
+				ingtypes.BackAffinityHeaderName: "X-Tenant-Id",
+			},
+			expStick:   hatypes.BackendStickAffinity{Size: 204800, Expire: "30m"},
+			expLogging: "WARN ignoring 'affinity-header-name' configuration on ingress 'default/ing1': affinity type is 'source-ip'",
 		},
-		// 7
+		// 53
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackAuthSecret: "basicpwd",
-				},
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "cookie",
+				ingtypes.BackSessionCookieDynamic: "true",
+				ingtypes.BackSessionCookieDynKey:  "gslb-shared-key",
 			},
-			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`
-usrnopwd1:
-usrnopwd2::
-:encpwd3
-::clearpwd4`)}},
-			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd"}},
-			expLogging: `
-WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'usrnopwd1' line 2
-WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'usrnopwd2' line 3
-WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing username line 4
-WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing username line 5
-WARN userlist on ingress 'default/ing1' for basic authentication is empty`,
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Dynamic: true, DynamicKey: "gslb-shared-key"},
 		},
-		// 8
+		// 54
 		{
-			paths: []string{"/", "/admin"},
-			ann: map[string]map[string]string{
-				"/admin": {
-					ingtypes.BackAuthSecret: "basicpwd",
-				},
+			annDefault: map[string]string{
+				ingtypes.BackSessionCookieDynKey: "global-key",
 			},
-			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`
-usr1:encpwd1
-usr2::clearpwd2`)}},
-			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
-				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
-				{Name: "usr2", Passwd: "clearpwd2", Encrypted: false},
-			}}},
-			expConfig: map[string]hatypes.AuthHTTP{
-				"/": {},
-				"/admin": {
-					UserlistName: "default_basicpwd",
-					Realm:        "localhost",
-				},
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "cookie",
+				ingtypes.BackSessionCookieDynamic: "true",
+			},
+			expCookie: hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Dynamic: true, DynamicKey: "global-key"},
+		},
+		// 55
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "cookie",
+				ingtypes.BackSessionCookieDynamic: "false",
+				ingtypes.BackSessionCookieDynKey:  "gslb-shared-key",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN ignoring 'session-cookie-dynamic-key' configuration on ingress 'default/ing1': dynamic cookies are not enabled",
+		},
+		// 56
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:              "cookie",
+				ingtypes.BackSessionCookieStrategy: "preserve",
+				ingtypes.BackSessionCookieDynKey:   "gslb-shared-key",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Preserve: true},
+			expLogging: "WARN ignoring 'session-cookie-dynamic-key' configuration on ingress 'default/ing1': dynamic cookies are not enabled",
+		},
+		// 57
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:             "cookie",
+				ingtypes.BackSessionCookieDynamic: "true",
+				ingtypes.BackSessionCookieDynKey:  `key" backup`,
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Dynamic: true},
+			expLogging: "WARN ignoring invalid dynamic cookie key on ingress 'default/ing1'",
+		},
+		// 58
+		{
+			annDefault: map[string]string{
+				ingtypes.BackAffinityTableSize:   "200k",
+				ingtypes.BackAffinityTableExpire: "30m",
+			},
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "source-ip",
+				ingtypes.BackSessionCookieDynKey: "gslb-shared-key",
 			},
+			expStick:   hatypes.BackendStickAffinity{Size: 204800, Expire: "30m"},
+			expLogging: "WARN ignoring 'session-cookie-dynamic-key' configuration on ingress 'default/ing1': affinity type is 'source-ip'",
+		},
+		// 59
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "cookie",
+				ingtypes.BackSessionCookieShared: "true",
+			},
+			expCookie:   hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Shared: true},
+			expEpCookie: hatypes.EpCookiePodNameHash,
+		},
+		// 60
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "cookie",
+				ingtypes.BackSessionCookieShared: "true",
+				ingtypes.BackSessionCookieValue:  "pod-uid",
+			},
+			expCookie:   hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly", Shared: true},
+			expEpCookie: hatypes.EpCookiePodUID,
+		},
+		// 61
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:          "cookie",
+				ingtypes.BackSessionCookieName: "app.sid",
+			},
+			expCookie: hatypes.Cookie{Name: "app.sid", Strategy: "insert", Keywords: "indirect nocache httponly"},
+		},
+		// 62
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:          "cookie",
+				ingtypes.BackSessionCookieName: "app sid",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN ignoring invalid cookie name on ingress 'default/ing1': app sid",
+		},
+		// 63
+		{
+			ann: map[string]string{
+				ingtypes.BackAffinity:          "cookie",
+				ingtypes.BackSessionCookieName: "sid=1",
+			},
+			expCookie:  hatypes.Cookie{Name: "INGRESSCOOKIE", Strategy: "insert", Keywords: "indirect nocache httponly"},
+			expLogging: "WARN ignoring invalid cookie name on ingress 'default/ing1': sid=1",
 		},
 	}
 
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
 	for i, test := range testCase {
-		// TODO missing expHTTPRequests
 		c := setup(t)
 		u := c.createUpdater()
-		if test.source == nil {
-			test.source = &Source{
-				Namespace: "default",
-				Name:      "ing1",
-				Type:      "ingress",
-			}
-		}
-		c.cache.SecretContent = test.secrets
-		d := c.createBackendMappingData("default/app", test.source, test.annDefault, test.ann, test.paths)
-		u.buildBackendAuthHTTP(d)
-		userlists := u.haproxy.Userlists().BuildSortedItems()
-		c.compareObjects("userlists", i, userlists, test.expUserlists)
-		if test.expConfig != nil {
-			actual := map[string]hatypes.AuthHTTP{}
-			for _, path := range d.backend.Paths {
-				actual[path.Path()] = path.AuthHTTP
-			}
-			c.compareObjects("auth http", i, actual, test.expConfig)
-		}
+		d := c.createBackendData("default/app", source, test.ann, test.annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		d.backend.BalanceAlgorithm = test.balance
+		u.buildBackendAffinity(d)
+		c.compareObjects("affinity", i, d.backend.Cookie, test.expCookie)
+		c.compareObjects("endpoint cookie strategy", i, d.backend.EpCookieStrategy, test.expEpCookie)
+		c.compareObjects("stick affinity", i, d.backend.StickAffinity, test.expStick)
 		c.logger.CompareLogging(test.expLogging)
 		c.teardown()
 	}
 }
 
-func TestBlueGreen(t *testing.T) {
-	buildPod := func(labels string) *api.Pod {
-		l := make(map[string]string)
-		for _, label := range strings.Split(labels, ",") {
-			kv := strings.Split(label, "=")
-			l[kv[0]] = kv[1]
-		}
-		return &api.Pod{
-			ObjectMeta: meta.ObjectMeta{
-				Name:      "pod1",
-				Namespace: "default",
-				Labels:    l,
-			},
-		}
-	}
-	buildAnn := func(bal, mode string) map[string]string {
-		ann := map[string]string{}
-		ann[ingtypes.BackBlueGreenBalance] = bal
-		if mode != "" {
-			ann[ingtypes.BackBlueGreenMode] = mode
-		}
-		return ann
-	}
-	buildEndpoints := func(targets string) []*hatypes.Endpoint {
-		ep := []*hatypes.Endpoint{}
-		if targets != "" {
-			for _, targetRef := range strings.Split(targets, ",") {
-				targetWeight := strings.Split(targetRef, "=")
-				target := targetRef
-				weight := 100
-				if len(targetWeight) == 2 {
-					target = targetWeight[0]
-					if w, err := strconv.ParseInt(targetWeight[1], 10, 0); err == nil {
-						weight = int(w)
-					}
-				}
-				ep = append(ep, &hatypes.Endpoint{
-					Enabled:   true,
-					IP:        "172.17.0.11",
-					Port:      8080,
-					Weight:    weight,
-					TargetRef: target,
-				})
-			}
-		}
-		return ep
-	}
+func TestAffinityFailover(t *testing.T) {
 	pods := map[string]*api.Pod{
-		"pod0101-01": buildPod("app=d01,v=1"),
-		"pod0101-02": buildPod("app=d01,v=1"),
-		"pod0102-01": buildPod("app=d01,v=2"),
-		"pod0102-02": buildPod("app=d01,v=2"),
-		"pod0102-03": buildPod("app=d01,v=2"),
-		"pod0102-04": buildPod("app=d01,v=2"),
-		"pod0103-01": buildPod("app=d01,v=3"),
-		"pod0103-02": buildPod("app=d01,x=3"),
-		"pod0103-03": buildPod("app=d01"),
+		"pod0101-01": {ObjectMeta: meta.ObjectMeta{Name: "pod0101-01", Namespace: "default", Labels: map[string]string{"v": "1"}}},
+		"pod0102-01": {ObjectMeta: meta.ObjectMeta{Name: "pod0102-01", Namespace: "default", Labels: map[string]string{"v": "2"}}},
+		"pod0102-02": {ObjectMeta: meta.ObjectMeta{Name: "pod0102-02", Namespace: "default", Labels: map[string]string{"v": "2"}}},
 	}
-	testCase := []struct {
-		ann        map[string]string
-		endpoints  []*hatypes.Endpoint
-		expConfig  *hatypes.BlueGreenConfig
-		expLabels  []string
-		expWeights []int
-		expLogging string
+	testCases := []struct {
+		ann         map[string]string
+		expFailover string
+		expWeights  []int
+		expLogging  string
 	}{
-		//
-		// Balance test cases
-		//
 		// 0
 		{
-			ann:        buildAnn("", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAffinity: "cookie",
+			},
+			expWeights: []int{100, 100, 100},
 		},
 		// 1
 		{
-			ann:        buildAnn("", "err"),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityFailover: "redispatch",
+			},
+			expFailover: "redispatch",
+			expWeights:  []int{100, 100, 100},
 		},
 		// 2
 		{
-			ann:        buildAnn("err", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight format: err",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityFailover: "error",
+			},
+			expFailover: "error",
+			expWeights:  []int{100, 100, 100},
 		},
 		// 3
 		{
-			ann:        buildAnn("v=1=xx", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"xx\": invalid syntax",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityFailover: "fail",
+			},
+			expWeights: []int{100, 100, 100},
+			expLogging: "WARN invalid affinity failover 'fail' on ingress 'default/ing1', using 'redispatch' instead",
 		},
-		// 4
+		// 4 - redispatched requests are balanced using the blue/green weights
 		{
-			ann:        buildAnn("v=1=1.5", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"1.5\": invalid syntax",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityFailover: "redispatch",
+				ingtypes.BackBlueGreenBalance: "v=1=50,v=2=25",
+				ingtypes.BackBlueGreenMode:    "pod",
+			},
+			expFailover: "redispatch",
+			expWeights:  []int{50, 25, 25},
 		},
 		// 5
 		{
-			ann:        buildAnn("v=1=-1", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{0},
-			expLogging: "WARN invalid weight '-1' on ingress 'default/ing1', using '0' instead",
+			ann: map[string]string{
+				ingtypes.BackAffinity:         "cookie",
+				ingtypes.BackAffinityFailover: "error",
+				ingtypes.BackBlueGreenBalance: "v=1=50,v=2=25",
+				ingtypes.BackBlueGreenMode:    "pod",
+			},
+			expFailover: "error",
+			expWeights:  []int{50, 25, 25},
 		},
 		// 6
 		{
-			ann:        buildAnn("v=1=260", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "WARN invalid weight '260' on ingress 'default/ing1', using '256' instead",
+			ann: map[string]string{
+				ingtypes.BackAffinity:            "source-ip",
+				ingtypes.BackAffinityFailover:    "error",
+				ingtypes.BackAffinityTableExpire: "30m",
+				ingtypes.BackAffinityTableSize:   "200k",
+			},
+			expWeights: []int{100, 100, 100},
+			expLogging: "WARN ignoring 'affinity-failover' configuration on ingress 'default/ing1': affinity type is 'source-ip'",
 		},
-		// 7
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{ingtypes.BackInitialWeight: "100"})
+		for _, pod := range []string{"pod0101-01", "pod0102-01", "pod0102-02"} {
+			d.backend.Endpoints = append(d.backend.Endpoints, &hatypes.Endpoint{Enabled: true, Weight: 100, TargetRef: pod})
+		}
+		u := c.createUpdater()
+		u.buildBackendAffinity(d)
+		u.buildBackendBlueGreenBalance(d)
+		weights := make([]int, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			weights[j] = ep.Weight
+		}
+		c.compareObjects("failover", i, d.backend.Cookie.Failover, test.expFailover)
+		c.compareObjects("weights", i, weights, test.expWeights)
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestAllDownResponse(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected hatypes.BackendAllDownResponse
+		logging  string
+	}{
+		// 0
 		{
-			ann:        buildAnn("v=1=10", "err"),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "WARN unsupported blue/green mode 'err' on ingress 'default/ing1', falling back to 'deploy'",
+			ann: map[string]string{},
 		},
-		// 8
+		// 1
 		{
-			ann:        buildAnn("v=1=10", ""),
-			endpoints:  buildEndpoints("pod0101-01"),
-			expWeights: []int{100},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "default",
+			},
 		},
-		// 9
+		// 2
 		{
-			ann:        buildAnn("", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{100, 100},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "page:503",
+			},
+			expected: hatypes.BackendAllDownResponse{StatusCode: 503},
 		},
-		// 10
+		// 3
 		{
-			ann:        buildAnn("v=1=50,v=2=50", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{100, 100},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "page:200",
+			},
+			expected: hatypes.BackendAllDownResponse{StatusCode: 200},
 		},
-		// 11
+		// 4
 		{
-			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{200, 100},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "page:404",
+			},
+			logging: "WARN ignoring all-down-response on ingress 'default/ing1': unsupported status code: 404",
 		},
-		// 12
+		// 5
 		{
-			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02"),
-			expWeights: []int{256, 64, 64},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "page:",
+			},
+			logging: "WARN ignoring all-down-response on ingress 'default/ing1': unsupported status code: ",
 		},
-		// 13
+		// 6
 		{
-			ann:        buildAnn("v=1=50,v=2=25", "pod"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02"),
-			expWeights: []int{50, 25, 25},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "redirect:https://status.local/app?down=1",
+			},
+			expected: hatypes.BackendAllDownResponse{Location: "https://status.local/app?down=1"},
 		},
-		// 14
+		// 7
 		{
-			ann:        buildAnn("v=1=50,v=2=25", ""),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02"),
-			expWeights: []int{256, 64, 64},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "redirect:https://status.local/%[src]",
+			},
+			logging: "WARN ignoring all-down-response on ingress 'default/ing1': invalid redirect location: https://status.local/%[src]",
 		},
-		// 15
+		// 8
 		{
-			ann:        buildAnn("v=1=500,v=2=2", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{256, 2},
-			expLogging: "WARN invalid weight '500' on ingress 'default/ing1', using '256' instead",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "redirect:",
+			},
+			logging: "WARN ignoring all-down-response on ingress 'default/ing1': invalid redirect location: ",
 		},
-		// 16
+		// 9
 		{
-			ann:        buildAnn("v=1=60,v=2=3", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0102-03,pod0102-04"),
-			expWeights: []int{256, 3, 3, 3, 3},
-			expLogging: "",
+			ann: map[string]string{
+				ingtypes.BackAllDownResponse: "503",
+			},
+			logging: "WARN ignoring all-down-response on ingress 'default/ing1': invalid value: 503",
 		},
-		// 17
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendAllDownResponse(d)
+		c.compareObjects("all down response", i, d.backend.AllDownResponse, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestAuthExternal(t *testing.T) {
+	testCase := []struct {
+		global     bool
+		url        string
+		signin     string
+		htmlOnly   bool
+		redirParam string
+		method     string
+		hdrReq     string
+		hdrSucceed string
+		hdrFail    string
+		hdrEmpty   bool
+		isExternal bool
+		hasLua     bool
+		expBack    hatypes.AuthExternal
+		expIP      []string
+		logging    string
+	}{
+		// 0
 		{
-			ann:        buildAnn("v=1=70,v=2=3", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0102-03,pod0102-04"),
-			expWeights: []int{256, 2, 2, 2, 2},
-			expLogging: "",
+			url:     "10.0.0.1:8080/app",
+			expBack: hatypes.AuthExternal{AlwaysDeny: true},
+			logging: `WARN ignoring URL on ingress 'default/ing1': invalid URL syntax: 10.0.0.1:8080/app`,
 		},
-		// 18
+		// 1
 		{
-			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
-			endpoints:  buildEndpoints(",pod0102-01"),
-			expWeights: []int{0, 100},
-			expLogging: `
-WARN endpoint '172.17.0.11:8080' on ingress 'default/ing1' was removed from balance: endpoint does not reference a pod
-INFO-V(2) blue/green balance label 'v=1' on ingress 'default/ing1' does not reference any endpoint`,
+			url:     "fail://app1.local",
+			expBack: hatypes.AuthExternal{AlwaysDeny: true},
+			logging: `WARN ignoring auth URL with an invalid protocol on ingress 'default/ing1': fail`,
 		},
-		// 19
+		// 2
 		{
-			ann:        buildAnn("v=1=50,v=non=25", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{100, 0},
-			expLogging: "INFO-V(2) blue/green balance label 'v=non' on ingress 'default/ing1' does not reference any endpoint",
+			url:        "http://app1.local",
+			isExternal: true,
+			expBack:    hatypes.AuthExternal{AlwaysDeny: true},
+			logging:    `WARN external authentication on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config`,
 		},
-		// 20
+		// 3
 		{
-			ann:        buildAnn("v=1=50,v=non=25", "pod"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{50, 0},
-			expLogging: "INFO-V(2) blue/green balance label 'v=non' on ingress 'default/ing1' does not reference any endpoint",
+			url: "http://app1.local",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 21
+		// 4
 		{
-			ann:        buildAnn("v=1=50,non=2=25", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{100, 0},
-			expLogging: "INFO-V(2) blue/green balance label 'non=2' on ingress 'default/ing1' does not reference any endpoint",
+			url:        "http://app1.local",
+			isExternal: true,
+			hasLua:     true,
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 22
+		// 5
 		{
-			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-non"),
-			expWeights: []int{100, 0},
-			expLogging: `
-WARN endpoint '172.17.0.11:8080' on ingress 'default/ing1' was removed from balance: pod not found: 'pod0102-non'
-INFO-V(2) blue/green balance label 'v=2' on ingress 'default/ing1' does not reference any endpoint`,
+			url: "http://app2.local/app",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/app",
+			},
+			expIP: []string{"10.0.0.3:80", "10.0.0.4:80"},
 		},
-		// 23
+		// 6
 		{
-			ann:        buildAnn("v=1=50,v=2=25", "pod"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-non"),
-			expWeights: []int{50, 0},
-			expLogging: `
-WARN endpoint '172.17.0.11:8080' on ingress 'default/ing1' was removed from balance: pod not found: 'pod0102-non'
-INFO-V(2) blue/green balance label 'v=2' on ingress 'default/ing1' does not reference any endpoint`,
+			url:     "http://appfail.local/app",
+			expBack: hatypes.AuthExternal{AlwaysDeny: true},
+			logging: `WARN ignoring auth URL with an invalid domain on ingress 'default/ing1': host not found: appfail.local`,
 		},
-		// 24
+		// 7
 		{
-			ann:        buildAnn("v=1=50,v=2=25,v=3=25", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0103-01"),
-			expWeights: []int{256, 64, 64, 128},
-			expLogging: "",
+			url: "http://app1.local:8080/app",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/app",
+			},
+			expIP: []string{"10.0.0.2:8080"},
 		},
-		// 25
+		// 8
 		{
-			ann:        buildAnn("v=1=50,v=2=0,v=3=25", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0103-01"),
-			expWeights: []int{200, 0, 0, 100},
-			expLogging: "",
+			url: "http://10.0.0.200:8080/app",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/app",
+			},
+			expIP: []string{"10.0.0.200:8080"},
 		},
-		// 26
+		// 9
 		{
-			ann:        buildAnn("v=1=50,v=2=0,v=3=25", "deploy"),
-			endpoints:  buildEndpoints(""),
-			expWeights: []int{},
-			expLogging: `
-INFO-V(2) blue/green balance label 'v=1' on ingress 'default/ing1' does not reference any endpoint
-INFO-V(2) blue/green balance label 'v=2' on ingress 'default/ing1' does not reference any endpoint
-INFO-V(2) blue/green balance label 'v=3' on ingress 'default/ing1' does not reference any endpoint`,
+			url:    "http://10.0.0.200:8080/app",
+			signin: "http://invalid'",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/app",
+			},
+			expIP:   []string{"10.0.0.200:8080"},
+			logging: `WARN ignoring invalid sign-in URL on ingress 'default/ing1': http://invalid'`,
 		},
-		// 27
+		// 10
 		{
-			ann:        buildAnn("v=1=0,v=2=0", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
-			expWeights: []int{0, 0},
-			expLogging: "",
+			url:    "http://app1.local/oauth2/auth",
+			signin: "http://app1.local/oauth2/start?rd=%[path]",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/oauth2/auth",
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "http://app1.local/oauth2/start?rd=%[path]",
+			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 28
+		// 11
 		{
-			ann:        buildAnn("v=1=255,v=2=2", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0102-03,pod0102-04"),
-			expWeights: []int{256, 1, 1, 1, 1},
-			expLogging: "",
+			url:        "http://app1.local/oauth2/auth",
+			signin:     "http://app1.local/oauth2/start?rd=%[path]",
+			hdrSucceed: "x-mail",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/oauth2/auth",
+				HeadersSucceed:  []string{"x-mail"},
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "http://app1.local/oauth2/start?rd=%[path]",
+			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 29
+		// 12
 		{
-			ann:        buildAnn("v=1=50,v=2=50", "deploy"),
-			endpoints:  buildEndpoints("pod0101-01,pod0101-02,pod0102-01,pod0102-02=0"),
-			expWeights: []int{100, 100, 200, 0},
-			expLogging: "",
+			url: "https://10.0.0.200/auth",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/auth",
+			},
+			expIP: []string{"10.0.0.200:443"},
 		},
-		//
-		// Label test cases
-		//
-		// 30
+		// 13
 		{
-			ann:       map[string]string{ingtypes.BackBlueGreenCookie: "SetServer:v"},
-			endpoints: buildEndpoints("pod0101-01,pod0101-02,pod0102-01"),
-			expConfig: &hatypes.BlueGreenConfig{CookieName: "SetServer"},
-			expLabels: []string{"1", "1", "2"},
+			url:     "svc://noservice",
+			expBack: hatypes.AuthExternal{AlwaysDeny: true},
+			logging: `WARN skipping auth-url on ingress 'default/ing1': missing service port: svc://noservice`,
 		},
-		// 31
+		// 14
 		{
-			ann:       map[string]string{ingtypes.BackBlueGreenHeader: "X-Server:v"},
-			endpoints: buildEndpoints("pod0101-01,pod0101-02,pod0102-01"),
-			expConfig: &hatypes.BlueGreenConfig{HeaderName: "X-Server"},
-			expLabels: []string{"1", "1", "2"},
+			url:     "svc://noservice:80",
+			expBack: hatypes.AuthExternal{AlwaysDeny: true},
+			logging: `ERROR skipping auth-url on ingress 'default/ing1': service 'default/noservice:80' was not found`,
 		},
-		// 32
+		// 15
 		{
-			ann:       map[string]string{ingtypes.BackBlueGreenHeader: "X-Server:v"},
-			endpoints: buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
-			expConfig: &hatypes.BlueGreenConfig{HeaderName: "X-Server"},
-			expLabels: []string{"3", "", ""},
+			url:     "svc://authservice",
+			expBack: hatypes.AuthExternal{AlwaysDeny: true},
+			logging: `WARN skipping auth-url on ingress 'default/ing1': missing service port: svc://authservice`,
 		},
-		// 33
+		// 16
 		{
-			ann: map[string]string{
-				ingtypes.BackBlueGreenCookie: "SetServer:v",
-				ingtypes.BackBlueGreenHeader: "X-Server:v",
-			},
-			endpoints: buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
-			expConfig: &hatypes.BlueGreenConfig{
-				CookieName: "SetServer",
-				HeaderName: "X-Server",
+			url: "svc://authservice:80",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
 			},
-			expLabels: []string{"3", "", ""},
+			expIP: []string{"10.0.0.11:8080"},
 		},
-		// 34
+		// 17
 		{
-			ann: map[string]string{
-				ingtypes.BackBlueGreenCookie: "SetServer:v",
-				ingtypes.BackBlueGreenHeader: "X-Server:x",
+			url: "svc://authservice:80/auth",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/auth",
 			},
-			endpoints:  buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
-			expConfig:  &hatypes.BlueGreenConfig{},
-			expLabels:  []string{"", "", ""},
-			expLogging: `ERROR CookieName:LabelName and HeaderName:LabelName pairs, used in the same backend on ingress 'default/ing1' and ingress 'default/ing1', should have the same label name`,
+			expIP: []string{"10.0.0.11:8080"},
 		},
-		// 35
+		// 18
 		{
-			ann: map[string]string{
-				ingtypes.BackBlueGreenCookie: "SetServer:x",
-				ingtypes.BackBlueGreenHeader: "X-Server:x",
-			},
-			endpoints: buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
-			expConfig: &hatypes.BlueGreenConfig{
-				CookieName: "SetServer",
-				HeaderName: "X-Server",
+			url: "svc://default/authservice:80/auth",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/auth",
 			},
-			expLabels: []string{"", "3", ""},
+			expIP: []string{"10.0.0.11:8080"},
 		},
-		// 36
+		// 19
 		{
-			ann: map[string]string{
-				ingtypes.BackBlueGreenCookie: "SetServer",
+			url:        "http://app1.local",
+			hdrSucceed: "x-mail,x-userid,x-auth",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersSucceed:  []string{"x-mail", "x-userid", "x-auth"},
 			},
-			endpoints:  buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
-			expConfig:  &hatypes.BlueGreenConfig{},
-			expLabels:  []string{"", "", ""},
-			expLogging: `ERROR invalid CookieName:LabelName pair on ingress 'default/ing1': SetServer`,
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 37
+		// 20
 		{
-			ann: map[string]string{
-				ingtypes.BackBlueGreenHeader: "_X_Server:v",
+			url:     "http://app1.local",
+			hdrFail: "x-uid,X-Message",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersFail:     []string{"x-uid", "X-Message"},
 			},
-			endpoints:  buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
-			expConfig:  &hatypes.BlueGreenConfig{},
-			expLabels:  []string{"", "", ""},
-			expLogging: `ERROR invalid HeaderName:LabelName pair on ingress 'default/ing1': _X_Server:v`,
+			expIP: []string{"10.0.0.2:80"},
 		},
-	}
-	source := &Source{
-		Namespace: "default",
-		Name:      "ing1",
-		Type:      "ingress",
-	}
-	for i, test := range testCase {
-		c := setup(t)
-		c.cache.PodList = pods
-		d := c.createBackendData("default/app", source, test.ann, map[string]string{ingtypes.BackInitialWeight: "100"})
-		d.backend.Endpoints = test.endpoints
-		u := c.createUpdater()
-		u.buildBackendBlueGreenBalance(d)
-		u.buildBackendBlueGreenSelector(d)
-		weights := make([]int, len(d.backend.Endpoints))
-		labels := make([]string, len(d.backend.Endpoints))
-		for j, ep := range d.backend.Endpoints {
-			weights[j] = ep.Weight
-			labels[j] = ep.Label
-		}
-		if test.expConfig != nil || test.expLabels != nil {
-			c.compareObjects("blue/green configs", i, d.backend.BlueGreen, *test.expConfig)
-			c.compareObjects("blue/green labels", i, labels, test.expLabels)
-		}
-		if test.expWeights != nil {
-			c.compareObjects("blue/green weight", i, weights, test.expWeights)
-		}
-		c.logger.CompareLogging(test.expLogging)
-		c.teardown()
-	}
-}
-
-func TestBodySize(t *testing.T) {
-	testCases := []struct {
-		source     Source
-		annDefault map[string]string
-		ann        map[string]map[string]string
-		paths      []string
-		expected   map[string]int64
-		logging    string
-	}{
-		// 0
+		// 21
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10",
-				},
-			},
-			expected: map[string]int64{
-				"/": 10,
+			url:     "http://app1.local",
+			hdrFail: "x-uid,x-message",
+			signin:  "http://app1.local/oauth2/start?rd=%[path]",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "http://app1.local/oauth2/start?rd=%[path]",
 			},
+			expIP:   []string{"10.0.0.2:80"},
+			logging: `WARN ignoring 'auth-headers-fail' on ingress 'default/ing1' due to signin (redirect) configuration`,
 		},
-		// 1
+		// 22
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10k",
-				},
-				"/app": {
-					ingtypes.BackProxyBodySize: "10m",
-				},
-				"/sub": {
-					ingtypes.BackProxyBodySize: "10g",
-				},
+			url:    "http://app1.local",
+			hdrReq: "x-token",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersRequest:  []string{"x-token"},
 			},
-			expected: map[string]int64{
-				"/":    10240,
-				"/app": 10485760,
-				"/sub": 10737418240,
+			expIP: []string{"10.0.0.2:80"},
+		},
+		// 23
+		{
+			url:    "http://app1.local",
+			method: "HEAD",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				Method:          "HEAD",
 			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 2
+		// 24
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "unlimited",
-				},
+			url:    "http://app1.local",
+			method: "invalid()",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				Method:          "GET",
 			},
-			expected: map[string]int64{
-				"/": 0,
+			expIP:   []string{"10.0.0.2:80"},
+			logging: `WARN invalid request method 'invalid()' on ingress 'default/ing1', using GET instead`,
+		},
+		// 25
+		{
+			url:    "http://app1.local",
+			method: "*",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				Method:          "*",
 			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 3
+		// 26
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackProxyBodySize: "10e",
-				},
+			url:    "http://app1.local",
+			method: "**",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				Method:          "GET",
 			},
-			expected: map[string]int64{
-				"/": 0,
+			expIP:   []string{"10.0.0.2:80"},
+			logging: `WARN invalid request method '**' on ingress 'default/ing1', using GET instead`,
+		},
+		// 27
+		{
+			url:      "http://app1.local",
+			hdrEmpty: true,
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersRequest:  []string{"-"},
+				HeadersSucceed:  []string{"-"},
+				HeadersFail:     []string{"-"},
 			},
-			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
-			logging: `WARN ignoring invalid body size on ingress 'default/ing1': 10e`,
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 4
+		// 28
 		{
-			ann: map[string]map[string]string{
-				"/app": {
-					ingtypes.BackProxyBodySize: "1m",
-				},
+			global: true,
+			url:    "http://app1.local",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
 			},
-			expected: map[string]int64{
-				"/":    0,
-				"/app": 1048576,
+			expIP: []string{"10.0.0.2:80"},
+		},
+		// 29
+		{
+			global:  true,
+			url:     "svc://authservice:80/auth",
+			expBack: hatypes.AuthExternal{AlwaysDeny: true},
+			logging: `WARN skipping auth-url on <global>: a globally configured auth-url is missing the namespace`,
+		},
+		// 30
+		{
+			global: true,
+			url:    "svc://default/authservice:80/auth",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/auth",
 			},
-			paths:  []string{"/"},
-			source: Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			expIP: []string{"10.0.0.11:8080"},
 		},
-	}
-	for i, test := range testCases {
-		c := setup(t)
-		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
-		c.createUpdater().buildBackendBodySize(d)
-		actual := map[string]int64{}
-		for _, path := range d.backend.Paths {
-			actual[path.Path()] = path.MaxBodySize
-		}
-		c.compareObjects("proxy body size", i, actual, test.expected)
-		c.logger.CompareLogging(test.logging)
-		c.teardown()
-	}
-}
-
-const (
-	corsDefaultHeaders = "DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization"
-	corsDefaultMethods = "GET, PUT, POST, DELETE, PATCH, OPTIONS"
-	corsDefaultMaxAge  = 86400
-)
-
-var corsDefaultOrigin = []string{"*"}
-
-func TestCors(t *testing.T) {
-	testCases := []struct {
-		paths    []string
-		ann      map[string]map[string]string
-		expected map[string]hatypes.Cors
-		logging  string
-	}{
-		// 0
+		// 31
 		{
-			paths: []string{"/"},
-			expected: map[string]hatypes.Cors{
-				"/": {},
+			url:        "http://app1.local/oauth2/auth",
+			signin:     "http://app1.local/oauth2/start",
+			redirParam: "rd",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/oauth2/auth",
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "http://app1.local/oauth2/start?rd=%[ssl_fc,iif(https,http)]://%[req.hdr(host)]%[url,url_enc]",
 			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 1
+		// 32
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable: "true",
-				},
+			url:        "http://app1.local/oauth2/auth",
+			signin:     "http://app1.local/oauth2/start?app=1",
+			redirParam: "return_to",
+			htmlOnly:   true,
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/oauth2/auth",
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "http://app1.local/oauth2/start?app=1&return_to=%[ssl_fc,iif(https,http)]://%[req.hdr(host)]%[url,url_enc]",
+				RedirHTMLOnly:   true,
 			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
+			expIP: []string{"10.0.0.2:80"},
+		},
+		// 33
+		{
+			url:        "http://app1.local/oauth2/auth",
+			signin:     "https://$host/oauth2/start?rd=$escaped_request_uri",
+			redirParam: "rd",
+			expBack: hatypes.AuthExternal{
+				AllowedPath:     "/oauth2/start",
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/oauth2/auth",
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "https://%[req.hdr(host)]/oauth2/start?rd=%[url,url_enc]",
 			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 2
+		// 34
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable: "false",
-				},
-				"/sub": {
-					ingtypes.BackCorsEnable: "true",
-				},
+			url:        "http://app1.local/oauth2/auth",
+			signin:     "/login?from=$request_uri&user=$remote_user",
+			redirParam: "invalid/param",
+			expBack: hatypes.AuthExternal{
+				AllowedPath:     "/login",
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/oauth2/auth",
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "/login?from=%[url]&user=$remote_user",
 			},
-			expected: map[string]hatypes.Cors{
-				"/": {},
-				"/sub": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
+			expIP: []string{"10.0.0.2:80"},
+			logging: `
+WARN unsupported placeholder '$remote_user' in the sign-in URL on ingress 'default/ing1', using it verbatim
+WARN ignoring invalid sign-in redirect param on ingress 'default/ing1': invalid/param`,
+		},
+		// 35
+		{
+			url:    "http://app1.local/oauth2/auth",
+			signin: "http://host.local:8080/",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/oauth2/auth",
+				HeadersFail:     []string{"-"},
+				RedirectOnFail:  "http://host.local:8080/",
 			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 3
+		// 36
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable:      "true",
-					ingtypes.BackCorsAllowOrigin: "http://d1.local,https://d2.local,https://d3.local",
-				},
+			url:        "http://app1.local",
+			hdrSucceed: "X-User-Id, X-User-Groups,X-Tenant-*,X-Invalid:var,,X-Invalid/",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersSucceed:  []string{"X-User-Id", "X-User-Groups", "X-Tenant-*"},
 			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      []string{"http://d1.local", "https://d2.local", "https://d3.local"},
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
+			expIP: []string{"10.0.0.2:80"},
+			logging: `
+WARN invalid header format 'X-Invalid:var' on ingress 'default/ing1'
+WARN invalid header format 'X-Invalid/' on ingress 'default/ing1'`,
+		},
+		// 37
+		{
+			url:        "http://app1.local",
+			hdrSucceed: "X-User-Id,*",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersSucceed:  []string{"*"},
 			},
+			expIP: []string{"10.0.0.2:80"},
 		},
-		// 4
+		// 38
 		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable:      "true",
-					ingtypes.BackCorsAllowOrigin: "https://d1.local,https://d2.local,invalid",
-				},
-			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
-			},
-			logging: `WARN ignoring invalid cors origin on ingress 'default/ing': invalid`,
-		},
-		// 5
-		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable:           "true",
-					ingtypes.BackCorsAllowOriginRegex: `^http://d1\.local$ ^https?://d[23]\.local https://([a-z]*\.){0,3}d3\.local$`,
-				},
-			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					AllowOriginRegex: []string{`^http://d1\.local$`, `^https?://d[23]\.local`, `https://([a-z]*\.){0,3}d3\.local$`},
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
-			},
-		},
-		// 6
-		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable:           "true",
-					ingtypes.BackCorsAllowOriginRegex: "https://d1.local https://d2.local http://(unmatched",
-				},
-			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
-			},
-			logging: `WARN ignoring invalid cors origin regex on ingress 'default/ing': http://(unmatched`,
-		},
-		// 7
-		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable:           "true",
-					ingtypes.BackCorsAllowOrigin:      "http://d4.local,https://d5.local,https://d6.local",
-					ingtypes.BackCorsAllowOriginRegex: `^http://d1\.local$ ^https?://d[23]\.local https://([a-z]*\.){0,3}d3\.local$`,
-				},
-			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     corsDefaultHeaders,
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      []string{"http://d4.local", "https://d5.local", "https://d6.local"},
-					AllowOriginRegex: []string{`^http://d1\.local$`, `^https?://d[23]\.local`, `https://([a-z]*\.){0,3}d3\.local$`},
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
-			},
-		},
-		// 8
-		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackCorsEnable:       "true",
-					ingtypes.BackCorsAllowHeaders: "*",
-				},
-			},
-			expected: map[string]hatypes.Cors{
-				"/": {
-					Enabled:          true,
-					AllowCredentials: false,
-					AllowHeaders:     "*",
-					AllowMethods:     corsDefaultMethods,
-					AllowOrigin:      corsDefaultOrigin,
-					ExposeHeaders:    "",
-					MaxAge:           corsDefaultMaxAge,
-				},
+			url:        "http://app1.local",
+			hdrSucceed: "X-User Id",
+			expBack: hatypes.AuthExternal{
+				AuthBackendName: "_auth_4001",
+				AuthPath:        "/",
+				HeadersSucceed:  []string{"-"},
 			},
+			expIP:   []string{"10.0.0.2:80"},
+			logging: `WARN invalid header format 'X-User Id' on ingress 'default/ing1'`,
 		},
 	}
-	annDefault := map[string]string{
-		ingtypes.BackCorsAllowHeaders: corsDefaultHeaders,
-		ingtypes.BackCorsAllowMethods: corsDefaultMethods,
-		ingtypes.BackCorsAllowOrigin:  strings.Join(corsDefaultOrigin, ","),
-		ingtypes.BackCorsMaxAge:       strconv.Itoa(corsDefaultMaxAge),
-	}
-	source := &Source{
+	defaultSource := &Source{
 		Namespace: "default",
-		Name:      "ing",
+		Name:      "ing1",
 		Type:      "ingress",
 	}
-	for i, test := range testCases {
+	lookupHost = func(host string) (addrs []string, err error) {
+		switch host {
+		case "app1.local":
+			return []string{"10.0.0.2"}, nil
+		case "app2.local":
+			return []string{"10.0.0.3", "10.0.0.4"}, nil
+		}
+		return nil, fmt.Errorf("host not found: %s", host)
+	}
+	for i, test := range testCase {
 		c := setup(t)
-		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, test.paths)
-		c.createUpdater().buildBackendCors(d)
-		actual := map[string]hatypes.Cors{}
-		for _, path := range d.backend.Paths {
-			actual[path.Path()] = path.Cors
+		u := c.createUpdater()
+		c.haproxy.Frontend().AuthProxy.RangeStart = 4001
+		c.haproxy.Frontend().AuthProxy.RangeEnd = 4009
+		if test.isExternal {
+			c.haproxy.Global().External.IsExternal = true
 		}
-		c.compareObjects("cors", i, actual, test.expected)
+		c.haproxy.Global().External.HasLua = test.hasLua
+		// backend is used by svc protocol
+		b := c.haproxy.Backends().AcquireBackend("default", "authservice", "80")
+		b.AcquireEndpoint("10.0.0.11", 8080, "")
+		ann := map[string]map[string]string{
+			"/": {
+				ingtypes.BackAuthURL:    test.url,
+				ingtypes.BackAuthSignin: test.signin,
+			},
+		}
+		if test.htmlOnly {
+			ann["/"][ingtypes.BackAuthSigninHTMLOnly] = "true"
+		}
+		if test.redirParam != "" {
+			ann["/"][ingtypes.BackAuthSigninRedirParam] = test.redirParam
+		}
+		if test.method != "" {
+			ann["/"][ingtypes.BackAuthMethod] = test.method
+		}
+		if test.hdrEmpty || test.hdrReq != "" {
+			ann["/"][ingtypes.BackAuthHeadersRequest] = test.hdrReq
+		}
+		if test.hdrEmpty || test.hdrSucceed != "" {
+			ann["/"][ingtypes.BackAuthHeadersSucceed] = test.hdrSucceed
+		}
+		if test.hdrEmpty || test.hdrFail != "" {
+			ann["/"][ingtypes.BackAuthHeadersFail] = test.hdrFail
+		}
+		defaults := map[string]string{
+			ingtypes.BackAuthExternalPlacement: "backend",
+			ingtypes.BackAuthHeadersRequest:    "*",
+			ingtypes.BackAuthHeadersSucceed:    "*",
+			ingtypes.BackAuthHeadersFail:       "*",
+			ingtypes.BackAuthMethod:            "GET",
+		}
+		var source *Source
+		if !test.global {
+			source = defaultSource
+		}
+		d := c.createBackendMappingData("default/app", source, defaults, ann, []string{"/"})
+		u.buildBackendAuthExternal(d)
+		back := d.backend.Paths[0].AuthExternal
+		var iplist []string
+		bindList := c.haproxy.Frontend().AuthProxy.BindList
+		if len(bindList) > 0 {
+			auth := c.haproxy.Backends().FindBackendID(bindList[0].Backend)
+			for _, ep := range auth.Endpoints {
+				iplist = append(iplist, fmt.Sprintf("%s:%d", ep.IP, ep.Port))
+			}
+		}
+		if back.AuthBackendName != "" {
+			// succeeded, config defaults
+			if test.expBack.Method == "" {
+				test.expBack.Method = "GET"
+			}
+			if test.expBack.HeadersRequest == nil {
+				test.expBack.HeadersRequest = []string{"*"}
+			}
+			if test.expBack.HeadersSucceed == nil {
+				test.expBack.HeadersSucceed = []string{"*"}
+			}
+			if test.expBack.HeadersFail == nil {
+				test.expBack.HeadersFail = []string{"*"}
+			}
+		}
+		c.compareObjects("auth external back", i, back, test.expBack)
+		c.compareObjects("auth external ip list", i, iplist, test.expIP)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-func TestCustomConfig(t *testing.T) {
-	defaultSource := &Source{
-		Type:      "Ingress",
-		Namespace: "default",
-		Name:      "app",
-	}
-	testCases := []struct {
-		disabled []string
-		config   string
-		source   *Source
-		expected []string
-		logging  string
+func TestAuthExternalCache(t *testing.T) {
+	testCase := []struct {
+		ann              map[string]string
+		expCache         hatypes.AuthCache
+		expHdrFail       []string
+		expHdrSucceed    []string
+		expHdrStrip      []string
+		expHdrStripRegex []string
+		logging          string
 	}{
 		// 0
 		{
-			config:   "  server srv001 127.0.0.1:8080",
-			expected: []string{"  server srv001 127.0.0.1:8080"},
+			ann: map[string]string{},
 		},
 		// 1
 		{
-			disabled: []string{"server"},
-			config:   "  server srv001 127.0.0.1:8080",
-			source:   defaultSource,
-			logging:  `WARN skipping configuration snippet on Ingress 'default/app': keyword 'server' not allowed`,
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration: "30s",
+			},
+			expCache: hatypes.AuthCache{Duration: "30s", KeyHeader: "Authorization", Size: 10240},
 		},
 		// 2
 		{
-			disabled: []string{"*"},
-			config:   "  server srv001 127.0.0.1:8080",
-			logging:  `WARN skipping configuration snippet on global config: custom configuration is disabled`,
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration: "30x",
+			},
+			logging: `WARN ignoring invalid time format on ingress 'default/ing1': 30x`,
 		},
 		// 3
 		{
-			disabled: []string{"http-response"},
-			config: `
-  http-request set-header x-id 1 if { path / }
-`,
-			expected: []string{"", "  http-request set-header x-id 1 if { path / }"},
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration: "1m",
+				ingtypes.BackAuthCacheKey:      "X-Token",
+				ingtypes.BackAuthCacheSize:     "500",
+			},
+			expCache: hatypes.AuthCache{Duration: "1m", KeyHeader: "X-Token", Size: 500},
 		},
 		// 4
 		{
-			disabled: []string{"http-response"},
-			config: `
-  acl rootpath path /
-  http-request set-header x-id 1 if rootpath
-`,
-			expected: []string{"", "  acl rootpath path /", "  http-request set-header x-id 1 if rootpath"},
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration: "1m",
+				ingtypes.BackAuthCacheKey:      "X-Token)",
+			},
+			logging: `WARN ignoring auth cache on ingress 'default/ing1': invalid header name on 'auth-cache-key': X-Token)`,
 		},
 		// 5
 		{
-			disabled: []string{"http-response", "acl"},
-			config: `
-  acl rootpath path /
-  http-request set-header x-id 1 if rootpath
-`,
-			source:  defaultSource,
-			logging: `WARN skipping configuration snippet on Ingress 'default/app': keyword 'acl' not allowed`,
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration: "1m",
+				ingtypes.BackAuthCacheSize:     "10x",
+			},
+			logging: `WARN ignoring auth cache on ingress 'default/ing1': invalid table size on 'auth-cache-size': 10x`,
 		},
 		// 6
 		{
-			disabled: []string{"http"},
-			config:   "  http-request set-header x-id 1 if { path / }",
-			expected: []string{"  http-request set-header x-id 1 if { path / }"},
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration: "1m",
+				ingtypes.BackAuthCacheSize:     "0",
+			},
+			logging: `WARN ignoring auth cache on ingress 'default/ing1': invalid table size on 'auth-cache-size': 0`,
 		},
 		// 7
 		{
-			disabled: []string{"server", ""},
-			config: `
-  acl rootpath path /
-
-  http-request set-header x-id 1 if rootpath
-`,
-			source:   defaultSource,
-			expected: []string{"", "  acl rootpath path /", "", "  http-request set-header x-id 1 if rootpath"},
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration: "1m",
+				ingtypes.BackAuthCacheSize:     "2m",
+			},
+			expCache: hatypes.AuthCache{Duration: "1m", KeyHeader: "Authorization", Size: 1048576},
+			logging:  `WARN auth cache table size on ingress 'default/ing1' is 2097152 entries, using the maximum of 1048576 entries instead`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDenyDuration: "10s",
+			},
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration:     "1m",
+				ingtypes.BackAuthCacheDenyDuration: "10s",
+			},
+			expCache:   hatypes.AuthCache{Duration: "1m", DenyDuration: "10s", KeyHeader: "Authorization", Size: 10240},
+			expHdrFail: []string{"-"},
+		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration:     "1m",
+				ingtypes.BackAuthCacheDenyDuration: "10s",
+				ingtypes.BackAuthHeadersFail:       "x-message",
+			},
+			expCache:   hatypes.AuthCache{Duration: "1m", DenyDuration: "10s", KeyHeader: "Authorization", Size: 10240},
+			expHdrFail: []string{"-"},
+			logging:    `WARN ignoring 'auth-headers-fail' on ingress 'default/ing1' due to auth-cache-deny-duration configuration`,
+		},
+		// 11
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration:     "1m",
+				ingtypes.BackAuthCacheDenyDuration: "10x",
+			},
+			expCache: hatypes.AuthCache{Duration: "1m", KeyHeader: "Authorization", Size: 10240},
+			logging:  `WARN ignoring invalid time format on ingress 'default/ing1': 10x`,
+		},
+		// 12
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthCacheDuration:  "1m",
+				ingtypes.BackAuthHeadersSucceed: "X-Auth-User,X-Auth-Group-*",
+			},
+			expCache:         hatypes.AuthCache{Duration: "1m", KeyHeader: "Authorization", Size: 10240},
+			expHdrStrip:      []string{"X-Auth-User"},
+			expHdrStripRegex: []string{"^x-auth-group-.*$"},
+			logging:          `WARN ignoring 'auth-headers-succeed' on ingress 'default/ing1' due to auth-cache-duration configuration, the headers are removed from the request instead`,
+		},
+		// 13
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthHeadersSucceed: "X-Auth-User",
+			},
+			expHdrSucceed: []string{"X-Auth-User"},
 		},
 	}
-	for i, test := range testCases {
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCase {
 		c := setup(t)
-		ann := map[string]map[string]string{
-			"/": {ingtypes.BackConfigBackend: test.config},
+		u := c.createUpdater()
+		c.haproxy.Frontend().AuthProxy.RangeStart = 4001
+		c.haproxy.Frontend().AuthProxy.RangeEnd = 4009
+		ann := map[string]string{
+			ingtypes.BackAuthURL: "http://10.0.0.200:8080/app",
 		}
-		d := c.createBackendMappingData("default/app", test.source, map[string]string{}, ann, []string{"/"})
-		updater := c.createUpdater()
-		updater.options.DisableKeywords = test.disabled
-		updater.buildBackendCustomConfig(d)
-		c.compareObjects("custom config", i, d.backend.CustomConfig, test.expected)
+		for key, value := range test.ann {
+			ann[key] = value
+		}
+		defaults := map[string]string{
+			ingtypes.BackAuthCacheKey:          "Authorization",
+			ingtypes.BackAuthCacheSize:         "10k",
+			ingtypes.BackAuthExternalPlacement: "backend",
+			ingtypes.BackAuthHeadersRequest:    "*",
+			ingtypes.BackAuthHeadersSucceed:    "*",
+			ingtypes.BackAuthHeadersFail:       "*",
+			ingtypes.BackAuthMethod:            "GET",
+		}
+		d := c.createBackendMappingData("default/app", source, defaults, map[string]map[string]string{"/": ann}, []string{"/"})
+		u.buildBackendAuthExternal(d)
+		auth := d.backend.Paths[0].AuthExternal
+		if test.expHdrFail == nil {
+			test.expHdrFail = []string{"*"}
+		}
+		if test.expHdrSucceed == nil {
+			// headers of the auth response are not copied if the cache is enabled
+			if test.expCache.Duration != "" {
+				test.expHdrSucceed = []string{"-"}
+			} else {
+				test.expHdrSucceed = []string{"*"}
+			}
+		}
+		c.compareObjects("auth cache", i, auth.Cache, test.expCache)
+		c.compareObjects("auth headers fail", i, auth.HeadersFail, test.expHdrFail)
+		c.compareObjects("auth headers succeed", i, auth.HeadersSucceed, test.expHdrSucceed)
+		c.compareObjects("auth headers strip", i, auth.HeadersStrip, test.expHdrStrip)
+		c.compareObjects("auth headers strip regex", i, auth.HeadersStripRegex, test.expHdrStripRegex)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-func TestFirstToken(t *testing.T) {
-	testCases := []struct {
-		line     string
-		expected string
+func TestAuthExternalProxyHeaders(t *testing.T) {
+	testCase := []struct {
+		headers string
+		expHdr  []string
+		logging string
 	}{
 		// 0
 		{
-			line:     "",
-			expected: "",
+			headers: "",
 		},
 		// 1
 		{
-			line:     "server",
-			expected: "server",
+			headers: "-",
 		},
 		// 2
 		{
-			line:     "server svc",
-			expected: "server",
+			headers: "X-Forwarded-For,X-Forwarded-Proto,X-Original-URL",
+			expHdr:  []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Original-URL"},
 		},
 		// 3
 		{
-			line:     "\tserver\tsvc",
-			expected: "server",
+			headers: "x-real-ip, X-FORWARDED-HOST,,x-forwarded-uri",
+			expHdr:  []string{"X-Real-IP", "X-Forwarded-Host", "X-Forwarded-Uri"},
 		},
 		// 4
 		{
-			line:     " \tserver \tsvc",
-			expected: "server",
-		},
-		// 5
-		{
-			line:     "  server  svc",
-			expected: "server",
+			headers: "X-Forwarded-For,Authorization",
+			expHdr:  []string{"X-Forwarded-For"},
+			logging: `WARN ignoring unsupported auth proxy header 'Authorization' on ingress 'default/ing1'`,
 		},
 	}
-	for i, test := range testCases {
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCase {
 		c := setup(t)
-		c.compareObjects("token", i, firstToken(test.line), test.expected)
+		u := c.createUpdater()
+		c.haproxy.Frontend().AuthProxy.RangeStart = 4001
+		c.haproxy.Frontend().AuthProxy.RangeEnd = 4009
+		ann := map[string]string{
+			ingtypes.BackAuthURL:          "http://10.0.0.200:8080/app",
+			ingtypes.BackAuthProxyHeaders: test.headers,
+		}
+		defaults := map[string]string{
+			ingtypes.BackAuthExternalPlacement: "backend",
+			ingtypes.BackAuthHeadersRequest:    "*",
+			ingtypes.BackAuthHeadersSucceed:    "*",
+			ingtypes.BackAuthHeadersFail:       "*",
+			ingtypes.BackAuthMethod:            "GET",
+		}
+		d := c.createBackendMappingData("default/app", source, defaults, map[string]map[string]string{"/": ann}, []string{"/"})
+		u.buildBackendAuthExternal(d)
+		c.compareObjects("auth proxy headers", i, d.backend.Paths[0].AuthExternal.ProxyHeaders, test.expHdr)
+		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-func TestHeaders(t *testing.T) {
-	testCases := []struct {
-		headers  string
-		expected []*hatypes.BackendHeader
-		logging  string
+func TestAuthHTTP(t *testing.T) {
+	// 10k users, CRLF line endings
+	var manyUsers strings.Builder
+	var manyUsersExp []hatypes.User
+	for i := 1; i <= 10000; i++ {
+		fmt.Fprintf(&manyUsers, "usr%05d::clearpwd%d\r\n", i, i)
+		if i <= authUserlistMaxUsers {
+			manyUsersExp = append(manyUsersExp, hatypes.User{Name: fmt.Sprintf("usr%05d", i), Passwd: fmt.Sprintf("clearpwd%d", i)})
+		}
+	}
+	testCase := []struct {
+		paths        []string
+		source       *Source
+		annDefault   map[string]string
+		ann          map[string]map[string]string
+		secrets      conv_helper.SecretContent
+		crossNs      bool
+		expUserlists []*hatypes.Userlist
+		expConfig    map[string]hatypes.AuthHTTP
+		expLogging   string
 	}{
 		// 0
 		{
-			headers: `invalid`,
-			logging: `WARN ignoring header on ingress 'ing1/app': missing header name or value: invalid`,
+			ann: map[string]map[string]string{},
 		},
 		// 1
 		{
-			headers: `key value`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "key", Value: "value"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "mypwd",
+				},
 			},
+			expLogging: "ERROR error reading basic authentication on ingress 'default/ing1': secret not found: 'default/mypwd'",
 		},
 		// 2
 		{
-			headers: `name: content`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "name", Value: "content"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "mypwd",
+				},
 			},
+			secrets:    conv_helper.SecretContent{"default/mypwd": {"xx": []byte{}}},
+			expLogging: "ERROR error reading basic authentication on ingress 'default/ing1': secret 'default/mypwd' does not have file/key 'auth'",
 		},
 		// 3
 		{
-			headers: `k:v`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "k", Value: "v"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "mypwd",
+					ingtypes.BackAuthRealm:  `"a name"`,
+				},
 			},
+			secrets: conv_helper.SecretContent{"default/mypwd": {"auth": []byte("usr1::clear1")}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_mypwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "clear1", Encrypted: false},
+			}}},
+			expLogging: "WARN ignoring auth-realm with quotes on ingress 'default/ing1'",
 		},
 		// 4
 		{
-			headers: `host: %[service].%[namespace].svc.cluster.local`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "host", Value: "app.default.svc.cluster.local"},
+			source: &Source{Namespace: "ns1", Name: "i1", Type: "ingress"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "mypwd",
+				},
 			},
+			secrets:      conv_helper.SecretContent{"ns1/mypwd": {"auth": []byte{}}},
+			expUserlists: []*hatypes.Userlist{{Name: "ns1_mypwd"}},
+			expLogging:   "WARN userlist on ingress 'ns1/i1' for basic authentication is empty",
 		},
 		// 5
 		{
-			headers: `
-k8snamespace: %[namespace]
-k8sservice: %[service]
-host: %[service].%[namespace].svc.cluster.local
-`,
-			expected: []*hatypes.BackendHeader{
-				{Name: "k8snamespace", Value: "default"},
-				{Name: "k8sservice", Value: "app"},
-				{Name: "host", Value: "app.default.svc.cluster.local"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
 			},
+			secrets:      conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("fail")}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd"}},
+			expLogging: `
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'fail' line 1
+WARN userlist on ingress 'default/ing1' for basic authentication is empty`,
 		},
-	}
-	source := &Source{
-		Namespace: "ing1",
-		Name:      "app",
-		Type:      "ingress",
-	}
-	for i, test := range testCases {
-		c := setup(t)
-		ann := map[string]map[string]string{
-			"/": {ingtypes.BackHeaders: test.headers},
-		}
-		d := c.createBackendMappingData("default/app", source, map[string]string{}, ann, []string{"/"})
-		c.createUpdater().buildBackendHeaders(d)
-		c.compareObjects("headers", i, d.backend.Headers, test.expected)
-		c.logger.CompareLogging(test.logging)
-		c.teardown()
-	}
-}
-
-func TestHSTS(t *testing.T) {
-	testCases := []struct {
-		paths      []string
-		source     Source
-		annDefault map[string]string
-		ann        map[string]map[string]string
-		expected   map[string]hatypes.HSTS
-		logging    string
-	}{
-		// 0
+		// 6
 		{
-			paths: []string{"/", "/url"},
-			annDefault: map[string]string{
-				ingtypes.BackHSTS:       "true",
-				ingtypes.BackHSTSMaxAge: "15768000",
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`
+usr1::clearpwd1
+nopwd`)}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "clearpwd1", Encrypted: false},
+			}}},
+			expLogging: "WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'nopwd' line 3",
+		},
+		// 7
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`
+usrnopwd1:
+usrnopwd2::
+:encpwd3
+::clearpwd4`)}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd"}},
+			expLogging: `
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'usrnopwd1' line 2
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'usrnopwd2' line 3
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing username line 4
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing username line 5
+WARN userlist on ingress 'default/ing1' for basic authentication is empty`,
+		},
+		// 8
+		{
+			paths: []string{"/", "/admin"},
+			ann: map[string]map[string]string{
+				"/admin": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`
+usr1:encpwd1
+usr2::clearpwd2`)}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+				{Name: "usr2", Passwd: "clearpwd2", Encrypted: false},
+			}}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {},
+				"/admin": {
+					UserlistName: "default_basicpwd",
+					Realm:        "localhost",
+				},
+			},
+		},
+		// 9
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("\xEF\xBB\xBFusr1:encpwd1\r\nusr2::clearpwd2 \t\r\n\r\nusr3::clearpwd3\rusr4::clearpwd4\r")}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+				{Name: "usr2", Passwd: "clearpwd2", Encrypted: false},
+				{Name: "usr3", Passwd: "clearpwd3", Encrypted: false},
+				{Name: "usr4", Passwd: "clearpwd4", Encrypted: false},
+			}}},
+		},
+		// 10
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("usr 1::clearpwd1\nusr\t2::clearpwd2\nusr\u200b3::clearpwd3\nusr\x1b4::clearpwd4\nusr\xe95::clearpwd5\nusr6::clearpwd6")}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr6", Passwd: "clearpwd6", Encrypted: false},
+			}}},
+			expLogging: `
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': invalid character U+0020 on username "usr 1" line 1
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': invalid character U+0009 on username "usr\t2" line 2
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': invalid character U+200B on username "usr\u200b3" line 3
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': invalid character U+001B on username "usr\x1b4" line 4
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': username "usr\xe95" is not valid UTF-8, the secret might be encoded as Windows-1252 line 5`,
+		},
+		// 11
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets:      conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(manyUsers.String())}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: manyUsersExp}},
+			expLogging:   "ERROR secret 'default/basicpwd' declared on ingress 'default/ing1' has 10000 users, using only the first 5000 of them",
+		},
+		// 12
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret:     "basicpwd",
+					ingtypes.BackAuthSecretType: "auth-map",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {
+				"usr2": []byte(":clearpwd2\n"),
+				"usr1": []byte("encpwd1"),
+			}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd_map", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+				{Name: "usr2", Passwd: "clearpwd2", Encrypted: false},
+			}}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "default_basicpwd_map",
+					Realm:        "localhost",
+				},
+			},
+		},
+		// 13
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret:     "basicpwd",
+					ingtypes.BackAuthSecretType: "auth-map",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {
+				"usr1":   []byte(""),
+				"usr2":   []byte(":"),
+				"usr\t3": []byte("encpwd3"),
+				"usr4":   []byte("encpwd4"),
+			}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd_map", Users: []hatypes.User{
+				{Name: "usr4", Passwd: "encpwd4", Encrypted: true},
+			}}},
+			expLogging: `
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': invalid character U+0009 on username "usr\t3" key "usr\t3"
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'usr1' key "usr1"
+WARN ignoring malformed usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': missing password of user 'usr2' key "usr2"`,
+		},
+		// 14
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret:     "basicpwd",
+					ingtypes.BackAuthSecretType: "auth-list",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("usr1:encpwd1")}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+			}}},
+			expLogging: "WARN ignoring invalid auth secret type on ingress 'default/ing1': auth-list",
+		},
+		// 15
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "team-a, team-b,team-a",
+				},
+			},
+			secrets: conv_helper.SecretContent{
+				"default/team-a": {"auth": []byte("usr1:encpwd1\nusr2::clearpwd2")},
+				"default/team-b": {"auth": []byte("usr2::clearpwd2\nusr1:encpwd9\nusr3:encpwd3")},
+			},
+			expUserlists: []*hatypes.Userlist{{Name: "default_team-a__default_team-b", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+				{Name: "usr2", Passwd: "clearpwd2", Encrypted: false},
+				{Name: "usr3", Passwd: "encpwd3", Encrypted: true},
+			}}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "default_team-a__default_team-b",
+					Realm:        "localhost",
+				},
+			},
+			expLogging: "WARN ignoring user 'usr1' of secret 'default/team-b' declared on ingress 'default/ing1': user is already declared with another password on secret 'default/team-a'",
+		},
+		// 16
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "team-a,ns2/team-b",
+				},
+			},
+			secrets: conv_helper.SecretContent{
+				"ns2/team-b": {"auth": []byte("usr1:encpwd1")},
+			},
+			crossNs: true,
+			expUserlists: []*hatypes.Userlist{{Name: "default_team-a__ns2_team-b", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+			}}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "default_team-a__ns2_team-b",
+					Realm:        "localhost",
+				},
+			},
+			expLogging: "ERROR error reading basic authentication on ingress 'default/ing1': secret not found: 'default/team-a'",
+		},
+		// 17
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "team-a,team-b",
+				},
+			},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {},
+			},
+			expLogging: `
+ERROR error reading basic authentication on ingress 'default/ing1': secret not found: 'default/team-a'
+ERROR error reading basic authentication on ingress 'default/ing1': secret not found: 'default/team-b'`,
+		},
+		// 18
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte(`usr1:$1$salt$hash1
+usr2:$2y$05$hash2
+usr3:$5$salt$hash3
+usr4:{SHA}hash4
+usr5:$6$salt$hash5
+usr6:$apr1$salt$hash6
+usr7:$md5$hash7
+usr8::$2y$05$clearpwd8
+usr9:encpwd9`)}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "$1$salt$hash1", Encrypted: true},
+				{Name: "usr3", Passwd: "$5$salt$hash3", Encrypted: true},
+				{Name: "usr5", Passwd: "$6$salt$hash5", Encrypted: true},
+				{Name: "usr8", Passwd: "$2y$05$clearpwd8", Encrypted: false},
+				{Name: "usr9", Passwd: "encpwd9", Encrypted: true},
+			}}},
+			expLogging: `
+WARN ignoring usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': unsupported password hash '$2y$' (bcrypt) of user 'usr2' line 2, use MD5-crypt ($1$), SHA-256-crypt ($5$) or SHA-512-crypt ($6$)
+WARN ignoring usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': unsupported password hash '{SHA}' (SHA-1) of user 'usr4' line 4, use MD5-crypt ($1$), SHA-256-crypt ($5$) or SHA-512-crypt ($6$)
+WARN ignoring usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': unsupported password hash '$apr1$' (Apache MD5) of user 'usr6' line 6, use MD5-crypt ($1$), SHA-256-crypt ($5$) or SHA-512-crypt ($6$)
+WARN ignoring usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': unsupported password hash '$md5$' of user 'usr7' line 7, use MD5-crypt ($1$), SHA-256-crypt ($5$) or SHA-512-crypt ($6$)`,
+		},
+		// 19
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret:     "basicpwd",
+					ingtypes.BackAuthSecretType: "auth-map",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {
+				"usr1": []byte("$6$salt$hash1"),
+				"usr2": []byte("$2b$10$hash2"),
+			}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd_map", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "$6$salt$hash1", Encrypted: true},
+			}}},
+			expLogging: `WARN ignoring usr/passwd on secret 'default/basicpwd', declared on ingress 'default/ing1': unsupported password hash '$2b$' (bcrypt) of user 'usr2' key "usr2", use MD5-crypt ($1$), SHA-256-crypt ($5$) or SHA-512-crypt ($6$)`,
+		},
+		// 20
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "ns2/basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"ns2/basicpwd": {"auth": []byte("usr1:encpwd1")}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {},
+			},
+			expLogging: "ERROR error reading basic authentication on ingress 'default/ing1': reading secret 'ns2/basicpwd' from namespace 'default' is denied, see cross-namespace-secrets configuration keys",
+		},
+		// 21
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "ns2/basicpwd",
+				},
+			},
+			secrets: conv_helper.SecretContent{"ns2/basicpwd": {"auth": []byte("usr1:encpwd1")}},
+			crossNs: true,
+			expUserlists: []*hatypes.Userlist{{Name: "ns2_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+			}}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "ns2_basicpwd",
+					Realm:        "localhost",
+				},
+			},
+		},
+		// 22
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret:     "basicpwd,ns2/basicpwd",
+					ingtypes.BackAuthSecretType: "auth-map",
+				},
+			},
+			secrets: conv_helper.SecretContent{
+				"default/basicpwd": {"usr1": []byte("encpwd1")},
+				"ns2/basicpwd":     {"usr2": []byte("encpwd2")},
+			},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd__ns2_basicpwd_map", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+			}}},
+			expLogging: "ERROR error reading basic authentication on ingress 'default/ing1': reading secret 'ns2/basicpwd' from namespace 'default' is denied, see cross-namespace-secrets configuration keys",
+		},
+		// 23
+		{
+			paths: []string{"/", "/healthz", "/webhook"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret:      "basicpwd",
+					ingtypes.BackNoAuthLocations: "/healthz, /webhook",
+				},
+				"/healthz": {
+					ingtypes.BackAuthSecret:      "basicpwd",
+					ingtypes.BackNoAuthLocations: "/healthz, /webhook",
+				},
+				"/webhook": {
+					ingtypes.BackAuthSecret:      "basicpwd",
+					ingtypes.BackNoAuthLocations: "/healthz, /webhook",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("usr1:encpwd1")}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+			}}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "default_basicpwd",
+					Realm:        "localhost",
+				},
+				"/healthz": {},
+				"/webhook": {},
+			},
+		},
+		// 24
+		{
+			paths: []string{"/", "/healthz"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret:      "basicpwd",
+					ingtypes.BackNoAuthLocations: "/healthz,/status",
+				},
+				"/healthz": {
+					ingtypes.BackAuthSecret:      "basicpwd",
+					ingtypes.BackNoAuthLocations: "/healthz,/status",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("usr1:encpwd1")}},
+			expUserlists: []*hatypes.Userlist{{Name: "default_basicpwd", Users: []hatypes.User{
+				{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+			}}},
+			expConfig: map[string]hatypes.AuthHTTP{
+				"/": {
+					UserlistName: "default_basicpwd",
+					Realm:        "localhost",
+				},
+				"/healthz": {},
+			},
+			expLogging: "WARN ignoring no-auth-locations path '/status' on ingress 'default/ing1': path not found on backend 'default_app_8080'",
+		},
+		// 25
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthSecret: "basicpwd",
+					ingtypes.BackAuthType:   "jwt",
+				},
+			},
+			secrets:   conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("usr1:encpwd1")}},
+			expConfig: map[string]hatypes.AuthHTTP{"/": {}},
+		},
+	}
+
+	for i, test := range testCase {
+		// TODO missing expHTTPRequests
+		c := setup(t)
+		u := c.createUpdater()
+		if test.source == nil {
+			test.source = &Source{
+				Namespace: "default",
+				Name:      "ing1",
+				Type:      "ingress",
+			}
+		}
+		u.options.DynamicConfig.CrossNamespaceSecretPasswd = test.crossNs
+		c.cache.SecretContent = test.secrets
+		d := c.createBackendMappingData("default/app", test.source, test.annDefault, test.ann, test.paths)
+		u.buildBackendAuthHTTP(d)
+		userlists := u.haproxy.Userlists().BuildSortedItems()
+		c.compareObjects("userlists", i, userlists, test.expUserlists)
+		if test.expConfig != nil {
+			actual := map[string]hatypes.AuthHTTP{}
+			for _, path := range d.backend.Paths {
+				actual[path.Path()] = path.AuthHTTP
+			}
+			c.compareObjects("auth http", i, actual, test.expConfig)
+		}
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestAuthHTTPSharedUserlist(t *testing.T) {
+	type backend struct {
+		svc, ing, secret, realm string
+	}
+	backends := []backend{
+		{svc: "default/app1", ing: "default/ing1", secret: "corp-users", realm: "app1"},
+		{svc: "default/app2", ing: "default/ing2", secret: "default/corp-users", realm: "app2"},
+		{svc: "ns1/app3", ing: "ns1/ing3", secret: "default/corp-users"},
+	}
+	testCases := []struct {
+		crossNs    bool
+		expConfig  map[string]hatypes.AuthHTTP
+		expLogging string
+	}{
+		// 0
+		{
+			expConfig: map[string]hatypes.AuthHTTP{
+				"default_app1_8080": {UserlistName: "default_corp-users", Realm: "app1"},
+				"default_app2_8080": {UserlistName: "default_corp-users", Realm: "app2"},
+				"ns1_app3_8080":     {},
+			},
+			expLogging: `
+WARN ignoring malformed usr/passwd on secret 'default/corp-users', declared on ingress 'default/ing1': missing password of user 'usr2' line 2
+ERROR error reading basic authentication on ingress 'ns1/ing3': reading secret 'default/corp-users' from namespace 'ns1' is denied, see cross-namespace-secrets configuration keys`,
+		},
+		// 1
+		{
+			crossNs: true,
+			expConfig: map[string]hatypes.AuthHTTP{
+				"default_app1_8080": {UserlistName: "default_corp-users", Realm: "app1"},
+				"default_app2_8080": {UserlistName: "default_corp-users", Realm: "app2"},
+				"ns1_app3_8080":     {UserlistName: "default_corp-users", Realm: "localhost"},
+			},
+			expLogging: `
+WARN ignoring malformed usr/passwd on secret 'default/corp-users', declared on ingress 'default/ing1': missing password of user 'usr2' line 2`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		u := c.createUpdater()
+		u.options.DynamicConfig.CrossNamespaceSecretPasswd = test.crossNs
+		c.cache.SecretContent = conv_helper.SecretContent{
+			"default/corp-users": {"auth": []byte("usr1:encpwd1\nusr2\n")},
+		}
+		actual := map[string]hatypes.AuthHTTP{}
+		for _, b := range backends {
+			ns, name, _ := strings.Cut(b.ing, "/")
+			source := &Source{Namespace: ns, Name: name, Type: "ingress"}
+			ann := map[string]string{ingtypes.BackAuthSecret: b.secret}
+			if b.realm != "" {
+				ann[ingtypes.BackAuthRealm] = b.realm
+			}
+			d := c.createBackendMappingData(b.svc, source, map[string]string{}, map[string]map[string]string{"/": ann}, nil)
+			u.buildBackendAuthHTTP(d)
+			actual[d.backend.ID] = d.backend.Paths[0].AuthHTTP
+		}
+		expUserlists := []*hatypes.Userlist{{Name: "default_corp-users", Users: []hatypes.User{
+			{Name: "usr1", Passwd: "encpwd1", Encrypted: true},
+		}}}
+		c.compareObjects("userlists", i, u.haproxy.Userlists().BuildSortedItems(), expUserlists)
+		c.compareObjects("auth http", i, actual, test.expConfig)
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestAuthJWT(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	crtDER, _ := x509.CreateCertificate(rand.Reader, &x509.Certificate{SerialNumber: big.NewInt(1)}, &x509.Certificate{SerialNumber: big.NewInt(1)}, &ecKey.PublicKey, ecKey)
+	encodePEM := func(typ string, der []byte) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}))
+	}
+	publicPEM := func(pub any) string {
+		der, _ := x509.MarshalPKIXPublicKey(pub)
+		return encodePEM("PUBLIC KEY", der)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	ecX := make([]byte, 32)
+	ecY := make([]byte, 32)
+	ecKey.PublicKey.X.FillBytes(ecX)
+	ecKey.PublicKey.Y.FillBytes(ecY)
+	rsaJWK := fmt.Sprintf(`{"kty":"RSA","kid":"rsa1","n":"%s","e":"AQAB"}`, b64(rsaKey.PublicKey.N.Bytes()))
+	ecJWK := fmt.Sprintf(`{"kty":"EC","use":"sig","alg":"ES256","crv":"P-256","x":"%s","y":"%s"}`, b64(ecX), b64(ecY))
+	rsaPEM := publicPEM(&rsaKey.PublicKey)
+	ecPEM := publicPEM(&ecKey.PublicKey)
+	rsaAlgs := []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+
+	testCases := []struct {
+		paths      []string
+		ann        map[string]map[string]string
+		secrets    conv_helper.SecretContent
+		version    string
+		isExternal bool
+		expConfig  map[string]hatypes.AuthJWT
+		expLogging string
+	}{
+		// 0
+		{
+			paths:     []string{"/"},
+			expConfig: map[string]hatypes.AuthJWT{"/": {}},
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType: "oauth",
+				},
+			},
+			expConfig:  map[string]hatypes.AuthJWT{"/": {}},
+			expLogging: "WARN ignoring invalid auth type on ingress 'default/ing1': oauth",
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType: "jwt",
+				},
+			},
+			expConfig:  map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: "ERROR error reading JWT authentication on ingress 'default/ing1': missing auth-jwt-secret",
+		},
+		// 3
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			expConfig:  map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: "ERROR error reading JWT authentication on ingress 'default/ing1': secret not found: 'default/jwt'",
+		},
+		// 4
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			secrets:    conv_helper.SecretContent{"default/jwt": {"tls.crt": []byte(rsaPEM)}},
+			expConfig:  map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: "ERROR error reading JWT authentication on ingress 'default/ing1': secret 'default/jwt' does not have 'jwks.json' or 'public.pem' keys",
+		},
+		// 5
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/jwt": {"jwks.json": []byte(`{"keys":[` +
+				rsaJWK + `,` + ecJWK + `,{"kty":"RSA","use":"enc","n":"AQAB","e":"AQAB"}]}`)}},
+			expConfig: map[string]hatypes.AuthJWT{"/": {
+				Keys: []hatypes.AuthJWTKey{
+					{Algs: rsaAlgs, ID: "rsa1", PEM: rsaPEM},
+					{Algs: []string{"ES256"}, PEM: ecPEM},
+				},
+				Realm: "localhost",
+			}},
+		},
+		// 6
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/jwt": {"jwks.json": []byte(`{"keys":[` +
+				`{"kty":"oct","alg":"HS256","k":"c2VjcmV0"},` +
+				`{"kty":"EC","alg":"ES384","crv":"P-256","x":"` + b64(ecX) + `","y":"` + b64(ecY) + `"},` +
+				strings.Replace(rsaJWK, `"kid"`, `"alg":"RS256","kid"`, 1) + `]}`)}},
+			expConfig: map[string]hatypes.AuthJWT{"/": {
+				Keys:  []hatypes.AuthJWTKey{{Algs: []string{"RS256"}, ID: "rsa1", PEM: rsaPEM}},
+				Realm: "localhost",
+			}},
+			expLogging: `
+ERROR ignoring JWT key of secret 'default/jwt' declared on ingress 'default/ing1': key 0 of the JWKS: unsupported key type 'oct'
+ERROR ignoring JWT key of secret 'default/jwt' declared on ingress 'default/ing1': key 1 of the JWKS: unsupported algorithm 'ES384'`,
+		},
+		// 7
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			secrets:   conv_helper.SecretContent{"default/jwt": {"jwks.json": []byte(`{"keys":[{"kty":"oct","alg":"HS256","k":"c2VjcmV0"}]}`)}},
+			expConfig: map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: `
+ERROR ignoring JWT key of secret 'default/jwt' declared on ingress 'default/ing1': key 0 of the JWKS: unsupported key type 'oct'
+ERROR error reading JWT authentication on ingress 'default/ing1': secret 'default/jwt' does not have supported public keys`,
+		},
+		// 8
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/jwt": {"public.pem": []byte(
+				encodePEM("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)) +
+					encodePEM("CERTIFICATE", crtDER) +
+					publicPEM(edKey))}},
+			expConfig: map[string]hatypes.AuthJWT{"/": {
+				Keys: []hatypes.AuthJWTKey{
+					{Algs: rsaAlgs, PEM: rsaPEM},
+					{Algs: []string{"ES256"}, PEM: ecPEM},
+				},
+				Realm: "localhost",
+			}},
+			expLogging: "ERROR ignoring JWT key of secret 'default/jwt' declared on ingress 'default/ing1': PEM block 2: unsupported key type ed25519.PublicKey",
+		},
+		// 9
+		{
+			paths: []string{"/", "/api"},
+			ann: map[string]map[string]string{
+				"/api": {
+					ingtypes.BackAuthType:        "JWT",
+					ingtypes.BackAuthJWTSecret:   "jwt",
+					ingtypes.BackAuthJWTIssuer:   "https://issuer.local",
+					ingtypes.BackAuthJWTAudience: "app1",
+					ingtypes.BackAuthRealm:       "api",
+				},
+			},
+			secrets: conv_helper.SecretContent{"default/jwt": {"public.pem": []byte(ecPEM)}},
+			expConfig: map[string]hatypes.AuthJWT{
+				"/": {},
+				"/api": {
+					Audience: "app1",
+					Issuer:   "https://issuer.local",
+					Keys:     []hatypes.AuthJWTKey{{Algs: []string{"ES256"}, PEM: ecPEM}},
+					Realm:    "api",
+				},
+			},
+		},
+		// 10
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:        "jwt",
+					ingtypes.BackAuthJWTSecret:   "jwt",
+					ingtypes.BackAuthJWTAudience: "app 1",
+				},
+			},
+			secrets:    conv_helper.SecretContent{"default/jwt": {"public.pem": []byte(ecPEM)}},
+			expConfig:  map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: "ERROR error reading JWT authentication on ingress 'default/ing1': invalid audience: app 1",
+		},
+		// 11
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "ns2/jwt",
+				},
+			},
+			secrets:    conv_helper.SecretContent{"ns2/jwt": {"public.pem": []byte(ecPEM)}},
+			expConfig:  map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: "ERROR error reading JWT authentication on ingress 'default/ing1': reading secret 'ns2/jwt' from namespace 'default' is denied, see cross-namespace-secrets configuration keys",
+		},
+		// 12
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			secrets:    conv_helper.SecretContent{"default/jwt": {"public.pem": []byte(ecPEM)}},
+			version:    "2.4.24",
+			expConfig:  map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: "ERROR error reading JWT authentication on ingress 'default/ing1': needs haproxy 2.5 or newer, found 2.4.24",
+		},
+		// 13
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackAuthType:      "jwt",
+					ingtypes.BackAuthJWTSecret: "jwt",
+				},
+			},
+			secrets:    conv_helper.SecretContent{"default/jwt": {"public.pem": []byte(ecPEM)}},
+			isExternal: true,
+			expConfig:  map[string]hatypes.AuthJWT{"/": {AlwaysDeny: true}},
+			expLogging: "ERROR error reading JWT authentication on ingress 'default/ing1': needs Lua json module, install lua-json4 and enable 'external-has-lua' global config",
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		u := c.createUpdater()
+		u.options.HAProxyVersion = test.version
+		u.haproxy.Global().External.IsExternal = test.isExternal
+		c.cache.SecretContent = test.secrets
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, test.ann, test.paths)
+		u.buildBackendAuthJWT(d)
+		actual := map[string]hatypes.AuthJWT{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.AuthJWT
+		}
+		c.compareObjects("auth jwt", i, actual, test.expConfig)
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestAuthBruteforce(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		noAuth   bool
+		version  string
+		expected hatypes.BackendAuthBruteforce
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit: "10",
+			},
+			expected: hatypes.BackendAuthBruteforce{Limit: 10, Window: "1m", Ban: "10m"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit:  "5",
+				ingtypes.BackAuthBruteforceWindow: "30s",
+				ingtypes.BackAuthBruteforceBan:    "1h",
+			},
+			expected: hatypes.BackendAuthBruteforce{Limit: 5, Window: "30s", Ban: "1h"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit: "ten",
+			},
+			logging: `WARN ignoring invalid int expression on ingress 'default/ing1' key 'auth-bruteforce-limit': ten`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit: "0",
+			},
+			logging: `WARN ignoring invalid auth bruteforce limit on ingress 'default/ing1': 0`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit:  "10",
+				ingtypes.BackAuthBruteforceWindow: "1x",
+			},
+			expected: hatypes.BackendAuthBruteforce{Limit: 10, Window: "1m", Ban: "10m"},
+			logging:  `WARN ignoring invalid time format on ingress 'default/ing1' key 'auth-bruteforce-window': 1x`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit: "10",
+				ingtypes.BackAuthBruteforceBan:   "forever",
+			},
+			expected: hatypes.BackendAuthBruteforce{Limit: 10, Window: "1m", Ban: "10m"},
+			logging:  `WARN ignoring invalid time format on ingress 'default/ing1' key 'auth-bruteforce-ban': forever`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit: "10",
+			},
+			noAuth:  true,
+			logging: `WARN ignoring auth bruteforce limit on ingress 'default/ing1': backend 'default_app_8080' does not have paths with basic authentication`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackAuthBruteforceLimit: "10",
+			},
+			version: "2.4.24",
+			logging: `ERROR ignoring auth bruteforce limit on ingress 'default/ing1': needs haproxy 2.6 or newer, found 2.4.24`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackAuthBruteforceBan:    "10m",
+		ingtypes.BackAuthBruteforceWindow: "1m",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.AddBackendPath(hatypes.CreateHostPathLink("domain.local", "/", hatypes.MatchBegin))
+		admin := d.backend.AddBackendPath(hatypes.CreateHostPathLink("domain.local", "/admin", hatypes.MatchBegin))
+		if !test.noAuth {
+			admin.AuthHTTP.UserlistName = "default_auth"
+		}
+		u := c.createUpdater()
+		u.options.HAProxyVersion = test.version
+		u.buildBackendAuthBruteforce(d)
+		c.compareObjects("auth bruteforce", i, d.backend.AuthBruteforce, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		version  string
+		expected hatypes.BackendBandwidthLimit
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "10m",
+			},
+			expected: hatypes.BackendBandwidthLimit{Download: 10485760},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "10m",
+				ingtypes.BackBandwidthLimitUpload:   "512k",
+				ingtypes.BackBandwidthLimitScope:    "backend",
+			},
+			expected: hatypes.BackendBandwidthLimit{Download: 10485760, Upload: 524288, Shared: true},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitUpload: "1000",
+				ingtypes.BackBandwidthLimitScope:  "tenant",
+			},
+			expected: hatypes.BackendBandwidthLimit{Upload: 1000},
+			logging: `
+WARN size without unit on ingress 'default/ing1', assuming bytes: 1000
+WARN ignoring invalid bandwidth limit scope on ingress 'default/ing1', using 'connection' instead: tenant`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "10mbps",
+				ingtypes.BackBandwidthLimitUpload:   "1k",
+			},
+			expected: hatypes.BackendBandwidthLimit{Upload: 1024},
+			logging:  `WARN ignoring invalid bandwidth limit on ingress 'default/ing1': 10mbps`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "0",
+			},
+			logging: `WARN ignoring invalid bandwidth limit on ingress 'default/ing1': 0`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "10m",
+			},
+			version: "2.6.15",
+			logging: `ERROR ignoring bandwidth limit on ingress 'default/ing1': bwlim filters need haproxy 2.7 or newer, found 2.6.15`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "10m",
+			},
+			version:  "2.8.5",
+			expected: hatypes.BackendBandwidthLimit{Download: 10485760},
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "10m",
+			},
+			modeTCP: true,
+			logging: `WARN ignoring bandwidth limit on ingress 'default/ing1': backend 'default_app_8080' is in TCP mode, configure 'bandwidth-limit-allow-tcp' as true to allow`,
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.BackBandwidthLimitDownload: "10m",
+				ingtypes.BackBandwidthLimitAllowTCP: "true",
+			},
+			modeTCP:  true,
+			expected: hatypes.BackendBandwidthLimit{Download: 10485760},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackBandwidthLimitAllowTCP: "false",
+		ingtypes.BackBandwidthLimitScope:    "connection",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.options.HAProxyVersion = test.version
+		u.buildBackendBandwidthLimit(d)
+		c.compareObjects("bandwidth limit", i, d.backend.BandwidthLimit, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestChaos(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		disabled bool
+		modeTCP  bool
+		expected hatypes.BackendChaos
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosLatency:        "2s",
+				ingtypes.BackChaosLatencyPercent: "10",
+			},
+			expected: hatypes.BackendChaos{Latency: "2s", LatencyPercent: 10},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosAbortStatus:  "503",
+				ingtypes.BackChaosAbortPercent: "5",
+			},
+			expected: hatypes.BackendChaos{AbortStatus: 503, AbortPercent: 5},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosLatency:        "500ms",
+				ingtypes.BackChaosLatencyPercent: "100",
+				ingtypes.BackChaosAbortStatus:    "500",
+				ingtypes.BackChaosAbortPercent:   "1",
+			},
+			expected: hatypes.BackendChaos{Latency: "500ms", LatencyPercent: 100, AbortStatus: 500, AbortPercent: 1},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosLatency: "2s",
+			},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosLatency:        "2s",
+				ingtypes.BackChaosLatencyPercent: "101",
+			},
+			logging: `WARN ignoring invalid percent on ingress 'default/ing1' key 'chaos-latency-percent', should be an integer between 0 and 100: 101`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosAbortStatus:  "200",
+				ingtypes.BackChaosAbortPercent: "5",
+			},
+			logging: `WARN ignoring invalid chaos abort status on ingress 'default/ing1': 200`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosLatency:        "2x",
+				ingtypes.BackChaosLatencyPercent: "10",
+			},
+			logging: `WARN ignoring invalid time format on ingress 'default/ing1' key 'chaos-latency': 2x`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosLatency:        "2s",
+				ingtypes.BackChaosLatencyPercent: "10",
+			},
+			disabled: true,
+			logging:  `WARN ignoring chaos configuration on ingress 'default/ing1': chaos testing is disabled, see enable-chaos global config`,
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.BackChaosAbortStatus:  "503",
+				ingtypes.BackChaosAbortPercent: "5",
+			},
+			modeTCP: true,
+			logging: `WARN ignoring chaos configuration on ingress 'default/ing1': backend is not in http mode`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		d.backend.ModeTCP = test.modeTCP
+		u := c.createUpdater()
+		u.haproxy.Global().EnableChaos = !test.disabled
+		u.buildBackendChaos(d)
+		c.compareObjects("chaos", i, d.backend.Chaos, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestBlueGreen(t *testing.T) {
+	buildPod := func(labels string) *api.Pod {
+		l := make(map[string]string)
+		for _, label := range strings.Split(labels, ",") {
+			kv := strings.Split(label, "=")
+			l[kv[0]] = kv[1]
+		}
+		return &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      "pod1",
+				Namespace: "default",
+				Labels:    l,
+			},
+		}
+	}
+	buildAnn := func(bal, mode string) map[string]string {
+		ann := map[string]string{}
+		ann[ingtypes.BackBlueGreenBalance] = bal
+		if mode != "" {
+			ann[ingtypes.BackBlueGreenMode] = mode
+		}
+		return ann
+	}
+	buildEndpoints := func(targets string) []*hatypes.Endpoint {
+		ep := []*hatypes.Endpoint{}
+		if targets != "" {
+			for _, targetRef := range strings.Split(targets, ",") {
+				targetWeight := strings.Split(targetRef, "=")
+				target := targetRef
+				weight := 100
+				if len(targetWeight) == 2 {
+					target = targetWeight[0]
+					if w, err := strconv.ParseInt(targetWeight[1], 10, 0); err == nil {
+						weight = int(w)
+					}
+				}
+				ep = append(ep, &hatypes.Endpoint{
+					Enabled:   true,
+					IP:        "172.17.0.11",
+					Port:      8080,
+					Weight:    weight,
+					TargetRef: target,
+				})
+			}
+		}
+		return ep
+	}
+	pods := map[string]*api.Pod{
+		"pod0101-01": buildPod("app=d01,v=1"),
+		"pod0101-02": buildPod("app=d01,v=1"),
+		"pod0102-01": buildPod("app=d01,v=2"),
+		"pod0102-02": buildPod("app=d01,v=2"),
+		"pod0102-03": buildPod("app=d01,v=2"),
+		"pod0102-04": buildPod("app=d01,v=2"),
+		"pod0103-01": buildPod("app=d01,v=3"),
+		"pod0103-02": buildPod("app=d01,x=3"),
+		"pod0103-03": buildPod("app=d01"),
+	}
+	testCase := []struct {
+		ann        map[string]string
+		endpoints  []*hatypes.Endpoint
+		expConfig  *hatypes.BlueGreenConfig
+		expLabels  []string
+		expWeights []int
+		expLogging string
+	}{
+		//
+		// Balance test cases
+		//
+		// 0
+		{
+			ann:        buildAnn("", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "",
+		},
+		// 1
+		{
+			ann:        buildAnn("", "err"),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "",
+		},
+		// 2
+		{
+			ann:        buildAnn("err", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight format: err",
+		},
+		// 3
+		{
+			ann:        buildAnn("v=1=xx", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"xx\": invalid syntax",
+		},
+		// 4
+		{
+			ann:        buildAnn("v=1=1.5", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "ERROR blue/green config on ingress 'default/ing1' has an invalid weight value: strconv.ParseInt: parsing \"1.5\": invalid syntax",
+		},
+		// 5
+		{
+			ann:        buildAnn("v=1=-1", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{0},
+			expLogging: "WARN invalid weight '-1' on ingress 'default/ing1', using '0' instead",
+		},
+		// 6
+		{
+			ann:        buildAnn("v=1=260", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "WARN invalid weight '260' on ingress 'default/ing1', using '256' instead",
+		},
+		// 7
+		{
+			ann:        buildAnn("v=1=10", "err"),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "WARN unsupported blue/green mode 'err' on ingress 'default/ing1', falling back to 'deploy'",
+		},
+		// 8
+		{
+			ann:        buildAnn("v=1=10", ""),
+			endpoints:  buildEndpoints("pod0101-01"),
+			expWeights: []int{100},
+			expLogging: "",
+		},
+		// 9
+		{
+			ann:        buildAnn("", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{100, 100},
+			expLogging: "",
+		},
+		// 10
+		{
+			ann:        buildAnn("v=1=50,v=2=50", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{100, 100},
+			expLogging: "",
+		},
+		// 11
+		{
+			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{200, 100},
+			expLogging: "",
+		},
+		// 12
+		{
+			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02"),
+			expWeights: []int{256, 64, 64},
+			expLogging: "",
+		},
+		// 13
+		{
+			ann:        buildAnn("v=1=50,v=2=25", "pod"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02"),
+			expWeights: []int{50, 25, 25},
+			expLogging: "",
+		},
+		// 14
+		{
+			ann:        buildAnn("v=1=50,v=2=25", ""),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02"),
+			expWeights: []int{256, 64, 64},
+			expLogging: "",
+		},
+		// 15
+		{
+			ann:        buildAnn("v=1=500,v=2=2", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{256, 2},
+			expLogging: "WARN invalid weight '500' on ingress 'default/ing1', using '256' instead",
+		},
+		// 16
+		{
+			ann:        buildAnn("v=1=60,v=2=3", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0102-03,pod0102-04"),
+			expWeights: []int{256, 3, 3, 3, 3},
+			expLogging: "",
+		},
+		// 17
+		{
+			ann:        buildAnn("v=1=70,v=2=3", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0102-03,pod0102-04"),
+			expWeights: []int{256, 2, 2, 2, 2},
+			expLogging: "",
+		},
+		// 18
+		{
+			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
+			endpoints:  buildEndpoints(",pod0102-01"),
+			expWeights: []int{0, 100},
+			expLogging: `
+WARN endpoint '172.17.0.11:8080' on ingress 'default/ing1' was removed from balance: endpoint does not reference a pod
+INFO-V(2) blue/green balance label 'v=1' on ingress 'default/ing1' does not reference any endpoint`,
+		},
+		// 19
+		{
+			ann:        buildAnn("v=1=50,v=non=25", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{100, 0},
+			expLogging: "INFO-V(2) blue/green balance label 'v=non' on ingress 'default/ing1' does not reference any endpoint",
+		},
+		// 20
+		{
+			ann:        buildAnn("v=1=50,v=non=25", "pod"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{50, 0},
+			expLogging: "INFO-V(2) blue/green balance label 'v=non' on ingress 'default/ing1' does not reference any endpoint",
+		},
+		// 21
+		{
+			ann:        buildAnn("v=1=50,non=2=25", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{100, 0},
+			expLogging: "INFO-V(2) blue/green balance label 'non=2' on ingress 'default/ing1' does not reference any endpoint",
+		},
+		// 22
+		{
+			ann:        buildAnn("v=1=50,v=2=25", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-non"),
+			expWeights: []int{100, 0},
+			expLogging: `
+WARN endpoint '172.17.0.11:8080' on ingress 'default/ing1' was removed from balance: pod not found: 'pod0102-non'
+INFO-V(2) blue/green balance label 'v=2' on ingress 'default/ing1' does not reference any endpoint`,
+		},
+		// 23
+		{
+			ann:        buildAnn("v=1=50,v=2=25", "pod"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-non"),
+			expWeights: []int{50, 0},
+			expLogging: `
+WARN endpoint '172.17.0.11:8080' on ingress 'default/ing1' was removed from balance: pod not found: 'pod0102-non'
+INFO-V(2) blue/green balance label 'v=2' on ingress 'default/ing1' does not reference any endpoint`,
+		},
+		// 24
+		{
+			ann:        buildAnn("v=1=50,v=2=25,v=3=25", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0103-01"),
+			expWeights: []int{256, 64, 64, 128},
+			expLogging: "",
+		},
+		// 25
+		{
+			ann:        buildAnn("v=1=50,v=2=0,v=3=25", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0103-01"),
+			expWeights: []int{200, 0, 0, 100},
+			expLogging: "",
+		},
+		// 26
+		{
+			ann:        buildAnn("v=1=50,v=2=0,v=3=25", "deploy"),
+			endpoints:  buildEndpoints(""),
+			expWeights: []int{},
+			expLogging: `
+INFO-V(2) blue/green balance label 'v=1' on ingress 'default/ing1' does not reference any endpoint
+INFO-V(2) blue/green balance label 'v=2' on ingress 'default/ing1' does not reference any endpoint
+INFO-V(2) blue/green balance label 'v=3' on ingress 'default/ing1' does not reference any endpoint`,
+		},
+		// 27
+		{
+			ann:        buildAnn("v=1=0,v=2=0", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01"),
+			expWeights: []int{0, 0},
+			expLogging: "",
+		},
+		// 28
+		{
+			ann:        buildAnn("v=1=255,v=2=2", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0102-01,pod0102-02,pod0102-03,pod0102-04"),
+			expWeights: []int{256, 1, 1, 1, 1},
+			expLogging: "",
+		},
+		// 29
+		{
+			ann:        buildAnn("v=1=50,v=2=50", "deploy"),
+			endpoints:  buildEndpoints("pod0101-01,pod0101-02,pod0102-01,pod0102-02=0"),
+			expWeights: []int{100, 100, 200, 0},
+			expLogging: "",
+		},
+		//
+		// Label test cases
+		//
+		// 30
+		{
+			ann:       map[string]string{ingtypes.BackBlueGreenCookie: "SetServer:v"},
+			endpoints: buildEndpoints("pod0101-01,pod0101-02,pod0102-01"),
+			expConfig: &hatypes.BlueGreenConfig{CookieName: "SetServer"},
+			expLabels: []string{"1", "1", "2"},
+		},
+		// 31
+		{
+			ann:       map[string]string{ingtypes.BackBlueGreenHeader: "X-Server:v"},
+			endpoints: buildEndpoints("pod0101-01,pod0101-02,pod0102-01"),
+			expConfig: &hatypes.BlueGreenConfig{HeaderName: "X-Server"},
+			expLabels: []string{"1", "1", "2"},
+		},
+		// 32
+		{
+			ann:       map[string]string{ingtypes.BackBlueGreenHeader: "X-Server:v"},
+			endpoints: buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
+			expConfig: &hatypes.BlueGreenConfig{HeaderName: "X-Server"},
+			expLabels: []string{"3", "", ""},
+		},
+		// 33
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCookie: "SetServer:v",
+				ingtypes.BackBlueGreenHeader: "X-Server:v",
+			},
+			endpoints: buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
+			expConfig: &hatypes.BlueGreenConfig{
+				CookieName: "SetServer",
+				HeaderName: "X-Server",
+			},
+			expLabels: []string{"3", "", ""},
+		},
+		// 34
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCookie: "SetServer:v",
+				ingtypes.BackBlueGreenHeader: "X-Server:x",
+			},
+			endpoints:  buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
+			expConfig:  &hatypes.BlueGreenConfig{},
+			expLabels:  []string{"", "", ""},
+			expLogging: `ERROR CookieName:LabelName and HeaderName:LabelName pairs, used in the same backend on ingress 'default/ing1' and ingress 'default/ing1', should have the same label name`,
+		},
+		// 35
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCookie: "SetServer:x",
+				ingtypes.BackBlueGreenHeader: "X-Server:x",
+			},
+			endpoints: buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
+			expConfig: &hatypes.BlueGreenConfig{
+				CookieName: "SetServer",
+				HeaderName: "X-Server",
+			},
+			expLabels: []string{"", "3", ""},
+		},
+		// 36
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenCookie: "SetServer",
+			},
+			endpoints:  buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
+			expConfig:  &hatypes.BlueGreenConfig{},
+			expLabels:  []string{"", "", ""},
+			expLogging: `ERROR invalid CookieName:LabelName pair on ingress 'default/ing1': SetServer`,
+		},
+		// 37
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenHeader: "_X_Server:v",
+			},
+			endpoints:  buildEndpoints("pod0103-01,pod0103-02,pod0103-03"),
+			expConfig:  &hatypes.BlueGreenConfig{},
+			expLabels:  []string{"", "", ""},
+			expLogging: `ERROR invalid HeaderName:LabelName pair on ingress 'default/ing1': _X_Server:v`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCase {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{ingtypes.BackInitialWeight: "100"})
+		d.backend.Endpoints = test.endpoints
+		u := c.createUpdater()
+		u.buildBackendBlueGreenBalance(d)
+		u.buildBackendBlueGreenSelector(d)
+		weights := make([]int, len(d.backend.Endpoints))
+		labels := make([]string, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			weights[j] = ep.Weight
+			labels[j] = ep.Label
+		}
+		if test.expConfig != nil || test.expLabels != nil {
+			c.compareObjects("blue/green configs", i, d.backend.BlueGreen, *test.expConfig)
+			c.compareObjects("blue/green labels", i, labels, test.expLabels)
+		}
+		if test.expWeights != nil {
+			c.compareObjects("blue/green weight", i, weights, test.expWeights)
+		}
+		c.logger.CompareLogging(test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestBlueGreenAutoPause(t *testing.T) {
+	buildPod := func(name, version string) *api.Pod {
+		return &api.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{"v": version},
+			},
+		}
+	}
+	pods := map[string]*api.Pod{
+		"pod01": buildPod("pod01", "1"),
+		"pod02": buildPod("pod02", "1"),
+		"pod03": buildPod("pod03", "2"),
+	}
+	testCases := []struct {
+		ann        map[string]string
+		expConfig  hatypes.BlueGreenAutoPause
+		expGroups  []string
+		expWeights []int
+		logging    string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance: "v=1=90,v=2=10",
+			},
+			expGroups:  []string{"", "", ""},
+			expWeights: []int{90, 90, 10},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance:   "v=1=90,v=2=10",
+				ingtypes.BackBlueGreenAutoPause: "true",
+			},
+			expConfig:  hatypes.BlueGreenAutoPause{Balance: "v=1=90,v=2=10", Threshold: 5},
+			expGroups:  []string{"baseline", "baseline", "canary"},
+			expWeights: []int{90, 90, 10},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance:   "v=1=90,v=2=10",
+				ingtypes.BackBlueGreenAutoPause: "true",
+				ingtypes.BackBlueGreenThreshold: "2.5%",
+			},
+			expConfig:  hatypes.BlueGreenAutoPause{Balance: "v=1=90,v=2=10", Threshold: 2.5},
+			expGroups:  []string{"baseline", "baseline", "canary"},
+			expWeights: []int{90, 90, 10},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance:   "v=1=50,v=2=50",
+				ingtypes.BackBlueGreenAutoPause: "true",
+				ingtypes.BackBlueGreenPaused:    "v=1=90,v=2=10",
+			},
+			expConfig:  hatypes.BlueGreenAutoPause{Balance: "v=1=90,v=2=10", Paused: true, Threshold: 5},
+			expGroups:  []string{"baseline", "baseline", "canary"},
+			expWeights: []int{90, 90, 10},
+			logging:    `WARN blue/green balance on ingress 'default/ing1' is paused, using 'v=1=90,v=2=10' until the 'blue-green-paused' annotation is removed`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance:   "v=1=90,v=2=10",
+				ingtypes.BackBlueGreenAutoPause: "true",
+				ingtypes.BackBlueGreenPaused:    "v=1=90,v=2=10",
+			},
+			expConfig:  hatypes.BlueGreenAutoPause{Balance: "v=1=90,v=2=10", Paused: true, Threshold: 5},
+			expGroups:  []string{"baseline", "baseline", "canary"},
+			expWeights: []int{90, 90, 10},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance: "v=1=50,v=2=50",
+				ingtypes.BackBlueGreenPaused:  "v=1=90,v=2=10",
+			},
+			expGroups:  []string{"", "", ""},
+			expWeights: []int{50, 50, 50},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance:   "v=1=100",
+				ingtypes.BackBlueGreenAutoPause: "true",
+			},
+			expGroups:  []string{"", "", ""},
+			expWeights: []int{100, 100, 0},
+			logging:    `WARN ignoring blue/green auto pause on ingress 'default/ing1': balance needs a baseline and a canary group`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackBlueGreenBalance:   "v=1=90,v=2=10",
+				ingtypes.BackBlueGreenAutoPause: "true",
+				ingtypes.BackBlueGreenThreshold: "150%",
+			},
+			expGroups:  []string{"", "", ""},
+			expWeights: []int{90, 90, 10},
+			logging:    `WARN ignoring blue/green auto pause on ingress 'default/ing1': invalid threshold percentage: 150%`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackBlueGreenMode:      "pod",
+		ingtypes.BackBlueGreenThreshold: "5%",
+		ingtypes.BackInitialWeight:      "100",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		for _, pod := range []string{"pod01", "pod02", "pod03"} {
+			d.backend.Endpoints = append(d.backend.Endpoints, &hatypes.Endpoint{
+				Enabled:   true,
+				Weight:    100,
+				TargetRef: pod,
+			})
+		}
+		c.createUpdater().buildBackendBlueGreenBalance(d)
+		groups := make([]string, len(d.backend.Endpoints))
+		weights := make([]int, len(d.backend.Endpoints))
+		for j, ep := range d.backend.Endpoints {
+			groups[j] = ep.Group
+			weights[j] = ep.Weight
+		}
+		c.compareObjects("auto pause", i, d.backend.BlueGreen.AutoPause, test.expConfig)
+		c.compareObjects("groups", i, groups, test.expGroups)
+		c.compareObjects("weights", i, weights, test.expWeights)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestBodySize(t *testing.T) {
+	testCases := []struct {
+		source     Source
+		annDefault map[string]string
+		ann        map[string]map[string]string
+		paths      []string
+		expected   map[string]int64
+		logging    string
+	}{
+		// 0
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10",
+				},
+			},
+			expected: map[string]int64{
+				"/": 10,
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN size without unit on ingress 'default/ing1', assuming bytes: 10`,
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10k",
+				},
+				"/app": {
+					ingtypes.BackProxyBodySize: "10m",
+				},
+				"/sub": {
+					ingtypes.BackProxyBodySize: "10g",
+				},
+			},
+			expected: map[string]int64{
+				"/":    10240,
+				"/app": 10485760,
+				"/sub": 10737418240,
+			},
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "unlimited",
+				},
+			},
+			expected: map[string]int64{
+				"/": 0,
+			},
+		},
+		// 3
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackProxyBodySize: "10e",
+				},
+			},
+			expected: map[string]int64{
+				"/": 0,
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid body size on ingress 'default/ing1': 10e`,
+		},
+		// 4
+		{
+			ann: map[string]map[string]string{
+				"/app": {
+					ingtypes.BackProxyBodySize: "1m",
+				},
+			},
+			expected: map[string]int64{
+				"/":    0,
+				"/app": 1048576,
+			},
+			paths:  []string{"/"},
+			source: Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
+		c.createUpdater().buildBackendBodySize(d)
+		actual := map[string]int64{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.MaxBodySize
+		}
+		c.compareObjects("proxy body size", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+const (
+	corsDefaultHeaders = "DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization"
+	corsDefaultMethods = "GET, PUT, POST, DELETE, PATCH, OPTIONS"
+	corsDefaultMaxAge  = 86400
+)
+
+var corsDefaultOrigin = []string{"*"}
+
+func TestCacheControl(t *testing.T) {
+	testCases := []struct {
+		paths      []string
+		source     Source
+		annDefault map[string]string
+		ann        map[string]map[string]string
+		expected   map[string]hatypes.CacheControl
+		logging    string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: map[string]hatypes.CacheControl{
+				"/": {},
+			},
+		},
+		// 1
+		{
+			paths: []string{"/", "/static"},
+			annDefault: map[string]string{
+				ingtypes.BackCacheControl: "no-cache",
+			},
+			ann: map[string]map[string]string{
+				"/": {},
+				"/static": {
+					ingtypes.BackCacheControl:          "public, max-age=3600",
+					ingtypes.BackSurrogateControl:      "max-age=86400",
+					ingtypes.BackCacheControlIfMissing: "true",
+				},
+			},
+			expected: map[string]hatypes.CacheControl{
+				"/": {
+					CacheControl: "no-cache",
+				},
+				"/static": {
+					CacheControl:     "public, max-age=3600",
+					SurrogateControl: "max-age=86400",
+					IfMissing:        true,
+				},
+			},
+		},
+		// 2
+		{
+			paths: []string{"/", "/api"},
+			annDefault: map[string]string{
+				ingtypes.BackCacheControl: "public, max-age=60",
+			},
+			ann: map[string]map[string]string{
+				"/": {},
+				"/api": {
+					ingtypes.BackCacheControl: "off",
+				},
+			},
+			expected: map[string]hatypes.CacheControl{
+				"/":    {CacheControl: "public, max-age=60"},
+				"/api": {},
+			},
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackCacheControl: "no-store",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCacheControl: "no-cache\r\nX-Injected: 1",
+				},
+			},
+			expected: map[string]hatypes.CacheControl{
+				"/": {CacheControl: "no-store"},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: "WARN ignoring invalid header value on ingress 'default/ing1' key 'cache-control': no-cache\r\nX-Injected: 1",
+		},
+		// 4
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSurrogateControl: `max-age=10"`,
+				},
+			},
+			expected: map[string]hatypes.CacheControl{
+				"/": {},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid header value on ingress 'default/ing1' key 'surrogate-control': max-age=10"`,
+		},
+		// 5
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCacheControl: "max-age=%[src]",
+				},
+			},
+			expected: map[string]hatypes.CacheControl{
+				"/": {},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid header value on ingress 'default/ing1' key 'cache-control': max-age=%[src]`,
+		},
+		// 6
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSurrogateControl: "max-age=10 # comment",
+				},
+			},
+			expected: map[string]hatypes.CacheControl{
+				"/": {},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid header value on ingress 'default/ing1' key 'surrogate-control': max-age=10 # comment`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
+		u := c.createUpdater()
+		u.buildBackendCacheControl(d)
+		actual := map[string]hatypes.CacheControl{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.CacheControl
+		}
+		c.compareObjects("cache-control", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestCors(t *testing.T) {
+	testCases := []struct {
+		paths    []string
+		ann      map[string]map[string]string
+		expected map[string]hatypes.Cors
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: map[string]hatypes.Cors{
+				"/": {},
+			},
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable: "true",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable: "false",
+				},
+				"/sub": {
+					ingtypes.BackCorsEnable: "true",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {},
+				"/sub": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+		},
+		// 3
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:      "true",
+					ingtypes.BackCorsAllowOrigin: "http://d1.local,https://d2.local,https://d3.local",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      []string{"http://d1.local", "https://d2.local", "https://d3.local"},
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+		},
+		// 4
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:      "true",
+					ingtypes.BackCorsAllowOrigin: "https://d1.local,https://d2.local,invalid",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+			logging: `WARN ignoring invalid cors origin on ingress 'default/ing': invalid`,
+		},
+		// 5
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:           "true",
+					ingtypes.BackCorsAllowOriginRegex: `^http://d1\.local$ ^https?://d[23]\.local https://([a-z]*\.){0,3}d3\.local$`,
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					AllowOriginRegex: []string{`^http://d1\.local$`, `^https?://d[23]\.local`, `https://([a-z]*\.){0,3}d3\.local$`},
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+		},
+		// 6
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:           "true",
+					ingtypes.BackCorsAllowOriginRegex: "https://d1.local https://d2.local http://(unmatched",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+			logging: `WARN ignoring invalid cors origin regex on ingress 'default/ing': http://(unmatched`,
+		},
+		// 7
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:           "true",
+					ingtypes.BackCorsAllowOrigin:      "http://d4.local,https://d5.local,https://d6.local",
+					ingtypes.BackCorsAllowOriginRegex: `^http://d1\.local$ ^https?://d[23]\.local https://([a-z]*\.){0,3}d3\.local$`,
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      []string{"http://d4.local", "https://d5.local", "https://d6.local"},
+					AllowOriginRegex: []string{`^http://d1\.local$`, `^https?://d[23]\.local`, `https://([a-z]*\.){0,3}d3\.local$`},
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+		},
+		// 8
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:       "true",
+					ingtypes.BackCorsAllowHeaders: "*",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     "*",
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+		},
+		// 9
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackCorsEnable:       "true",
+					ingtypes.BackCorsAllowMethods: "GET\nhttp-request deny",
+				},
+			},
+			expected: map[string]hatypes.Cors{
+				"/": {
+					Enabled:          true,
+					AllowCredentials: false,
+					AllowHeaders:     corsDefaultHeaders,
+					AllowMethods:     corsDefaultMethods,
+					AllowOrigin:      corsDefaultOrigin,
+					ExposeHeaders:    "",
+					MaxAge:           corsDefaultMaxAge,
+				},
+			},
+			logging: `
+WARN ignoring invalid cors methods on ingress 'default/ing': GET
+http-request deny`,
+		},
+	}
+	annDefault := map[string]string{
+		ingtypes.BackCorsAllowHeaders: corsDefaultHeaders,
+		ingtypes.BackCorsAllowMethods: corsDefaultMethods,
+		ingtypes.BackCorsAllowOrigin:  strings.Join(corsDefaultOrigin, ","),
+		ingtypes.BackCorsMaxAge:       strconv.Itoa(corsDefaultMaxAge),
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, test.paths)
+		c.createUpdater().buildBackendCors(d)
+		actual := map[string]hatypes.Cors{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.Cors
+		}
+		c.compareObjects("cors", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+type configValueMock struct {
+	value    *ConfigValue
+	defValue string
+}
+
+func (c *configValueMock) Get(key string) *ConfigValue {
+	return c.value
+}
+
+func (c *configValueMock) GetDefault(key string) *ConfigValue {
+	return &ConfigValue{Value: c.defValue}
+}
+
+func TestGetSafeValue(t *testing.T) {
+	testCases := []struct {
+		value    string
+		defValue string
+		expected string
+		logging  string
+	}{
+		// 0
+		{
+			value:    "GET, POST",
+			defValue: corsDefaultMethods,
+			expected: "GET, POST",
+		},
+		// 1
+		{
+			value:    "GET\nhttp-request deny",
+			defValue: corsDefaultMethods,
+			expected: corsDefaultMethods,
+			logging:  `ERROR ignoring 'cors-allow-methods' configuration on ingress 'default/ing': unsafe char '\n' in the value`,
+		},
+		// 2
+		{
+			value:    "GET # comment",
+			defValue: "GET # comment",
+			expected: "",
+			logging:  `ERROR ignoring 'cors-allow-methods' configuration on ingress 'default/ing': unsafe char '#' in the value`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		config := &configValueMock{value: &ConfigValue{Source: source, Value: test.value}, defValue: test.defValue}
+		actual := c.createUpdater().getSafeValue(config, ingtypes.BackCorsAllowMethods)
+		c.compareObjects("safe value", i, actual.Value, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestCustomConfig(t *testing.T) {
+	defaultSource := &Source{
+		Type:      "Ingress",
+		Namespace: "default",
+		Name:      "app",
+	}
+	testCases := []struct {
+		disabled []string
+		config   string
+		source   *Source
+		expected []string
+		logging  string
+	}{
+		// 0
+		{
+			config:   "  server srv001 127.0.0.1:8080",
+			expected: []string{"  server srv001 127.0.0.1:8080"},
+		},
+		// 1
+		{
+			disabled: []string{"server"},
+			config:   "  server srv001 127.0.0.1:8080",
+			source:   defaultSource,
+			logging:  `WARN skipping configuration snippet on Ingress 'default/app': keyword 'server' not allowed`,
+		},
+		// 2
+		{
+			disabled: []string{"*"},
+			config:   "  server srv001 127.0.0.1:8080",
+			logging:  `WARN skipping configuration snippet on global config: custom configuration is disabled`,
+		},
+		// 3
+		{
+			disabled: []string{"http-response"},
+			config: `
+  http-request set-header x-id 1 if { path / }
+`,
+			expected: []string{"", "  http-request set-header x-id 1 if { path / }"},
+		},
+		// 4
+		{
+			disabled: []string{"http-response"},
+			config: `
+  acl rootpath path /
+  http-request set-header x-id 1 if rootpath
+`,
+			expected: []string{"", "  acl rootpath path /", "  http-request set-header x-id 1 if rootpath"},
+		},
+		// 5
+		{
+			disabled: []string{"http-response", "acl"},
+			config: `
+  acl rootpath path /
+  http-request set-header x-id 1 if rootpath
+`,
+			source:  defaultSource,
+			logging: `WARN skipping configuration snippet on Ingress 'default/app': keyword 'acl' not allowed`,
+		},
+		// 6
+		{
+			disabled: []string{"http"},
+			config:   "  http-request set-header x-id 1 if { path / }",
+			expected: []string{"  http-request set-header x-id 1 if { path / }"},
+		},
+		// 7
+		{
+			disabled: []string{"server", ""},
+			config: `
+  acl rootpath path /
+
+  http-request set-header x-id 1 if rootpath
+`,
+			source:   defaultSource,
+			expected: []string{"", "  acl rootpath path /", "", "  http-request set-header x-id 1 if rootpath"},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		ann := map[string]map[string]string{
+			"/": {ingtypes.BackConfigBackend: test.config},
+		}
+		d := c.createBackendMappingData("default/app", test.source, map[string]string{}, ann, []string{"/"})
+		updater := c.createUpdater()
+		updater.options.DisableKeywords = test.disabled
+		updater.buildBackendCustomConfig(d)
+		c.compareObjects("custom config", i, d.backend.CustomConfig, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestBackendDNS(t *testing.T) {
+	testCases := []struct {
+		ann       map[string]string
+		endpoints int
+		resolver  string
+		expected  hatypes.BackendDNS
+		logging   string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			resolver: "",
+		},
+		// 1
+		{
+			ann:      map[string]string{ingtypes.BackUseResolver: "k8s"},
+			resolver: "k8s",
+			expected: hatypes.BackendDNS{ResolvePrefer: "ipv4"},
+		},
+		// 2
+		{
+			ann:     map[string]string{ingtypes.BackUseResolver: "other"},
+			logging: `WARN skipping undeclared DNS resolver: other`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackUseResolver:      "k8s",
+				ingtypes.BackDNSResolvePrefer: "ipv6",
+				ingtypes.BackDNSHoldValid:     "30s",
+			},
+			resolver: "k8s",
+			expected: hatypes.BackendDNS{HoldValid: "30s", ResolvePrefer: "ipv6"},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackUseResolver:      "k8s",
+				ingtypes.BackDNSResolvePrefer: "ipv5",
+			},
+			resolver: "k8s",
+			expected: hatypes.BackendDNS{ResolvePrefer: "ipv4"},
+			logging:  `WARN ignoring invalid resolve prefer on ingress 'default/ing1': 'ipv5', using 'ipv4' instead`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackUseResolver:  "k8s",
+				ingtypes.BackDNSHoldValid: "10x",
+			},
+			resolver: "k8s",
+			expected: hatypes.BackendDNS{ResolvePrefer: "ipv4"},
+			logging:  `WARN ignoring invalid time format on ingress 'default/ing1' key 'dns-hold-valid': 10x`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackUseResolver:       "k8s",
+				ingtypes.BackExternalNameSlots: "16",
+			},
+			endpoints: 10,
+			resolver:  "k8s",
+			expected:  hatypes.BackendDNS{ResolvePrefer: "ipv4", Slots: 16},
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackUseResolver:       "k8s",
+				ingtypes.BackExternalNameSlots: "4",
+			},
+			endpoints: 10,
+			resolver:  "k8s",
+			expected:  hatypes.BackendDNS{ResolvePrefer: "ipv4", Slots: 4},
+			logging:   `WARN backend 'default_app_8080' resolves to 10 addresses, but only 4 external name slots are configured on ingress 'default/ing1'`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackUseResolver:       "k8s",
+				ingtypes.BackExternalNameSlots: "0",
+			},
+			resolver: "k8s",
+			expected: hatypes.BackendDNS{ResolvePrefer: "ipv4"},
+			logging:  `WARN ignoring invalid external name slots on ingress 'default/ing1': 0`,
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	annDefault := map[string]string{
+		ingtypes.BackDNSHoldValid:     "1s",
+		ingtypes.BackDNSResolvePrefer: "ipv4",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().DNS.Resolvers = []*hatypes.DNSResolver{{Name: "k8s", HoldValid: "1s"}}
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		for j := 0; j < test.endpoints; j++ {
+			d.backend.AcquireEndpoint(fmt.Sprintf("10.0.0.%d", j+1), 8080, "")
+		}
+		c.createUpdater().buildBackendDNS(d)
+		c.compareObjects("resolver", i, d.backend.Resolver, test.resolver)
+		c.compareObjects("dns", i, d.backend.DNS, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestFirstToken(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected string
+	}{
+		// 0
+		{
+			line:     "",
+			expected: "",
+		},
+		// 1
+		{
+			line:     "server",
+			expected: "server",
+		},
+		// 2
+		{
+			line:     "server svc",
+			expected: "server",
+		},
+		// 3
+		{
+			line:     "\tserver\tsvc",
+			expected: "server",
+		},
+		// 4
+		{
+			line:     " \tserver \tsvc",
+			expected: "server",
+		},
+		// 5
+		{
+			line:     "  server  svc",
+			expected: "server",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.compareObjects("token", i, firstToken(test.line), test.expected)
+		c.teardown()
+	}
+}
+
+func TestEarlyHints(t *testing.T) {
+	testCases := []struct {
+		paths    []string
+		ann      map[string]map[string]string
+		version  string
+		expected map[string][]string
+		logging  string
+	}{
+		// 0
+		{
+			paths: []string{"/"},
+			expected: map[string][]string{
+				"/": nil,
+			},
+		},
+		// 1
+		{
+			paths: []string{"/", "/app"},
+			ann: map[string]map[string]string{
+				"/app": {
+					ingtypes.BackEarlyHints: `</app/style.css>; rel=preload; as=style
+</app/script.js>; rel=preload; as=script
+`,
+				},
+			},
+			expected: map[string][]string{
+				"/":    nil,
+				"/app": {"</app/style.css>; rel=preload; as=style", "</app/script.js>; rel=preload; as=script"},
+			},
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackEarlyHints: `/style.css
+</style.css>; rel=preload"; x=1
+</style%0d%0aX-Inject: 1>; rel=preload
+</font.woff2>; rel=preload; as=font`,
+				},
+			},
+			expected: map[string][]string{
+				"/": {"</font.woff2>; rel=preload; as=font"},
+			},
+			logging: `
+WARN ignoring invalid early-hints link on ingress 'default/ing1': /style.css
+WARN ignoring invalid early-hints link on ingress 'default/ing1': </style.css>; rel=preload"; x=1
+WARN ignoring invalid early-hints link on ingress 'default/ing1': </style%0d%0aX-Inject: 1>; rel=preload`,
+		},
+		// 3
+		{
+			paths: []string{"/", "/app"},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackEarlyHints: `</style.css>; rel=preload; as=style`,
+				},
+				"/app": {
+					ingtypes.BackEarlyHints: `</app/style.css>; rel=preload; as=style`,
+				},
+			},
+			version: "2.0.33",
+			expected: map[string][]string{
+				"/":    nil,
+				"/app": nil,
+			},
+			logging: `WARN ignoring early-hints on ingress 'default/ing1': haproxy 2.0.33 does not support early-hint`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, test.ann, test.paths)
+		u := c.createUpdater()
+		u.options.HAProxyVersion = test.version
+		u.buildBackendEarlyHints(d)
+		actual := map[string][]string{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.EarlyHints
+		}
+		c.compareObjects("early hints", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestFallbackBackend(t *testing.T) {
+	testCases := []struct {
+		fallback string
+		expected hatypes.BackendID
+		logging  string
+	}{
+		// 0
+		{
+			fallback: "",
+		},
+		// 1
+		{
+			fallback: "app-fallback:8080",
+			expected: hatypes.BackendID{Namespace: "default", Name: "app-fallback", Port: "8080"},
+		},
+		// 2
+		{
+			fallback: "other/app-fallback:8080",
+			expected: hatypes.BackendID{Namespace: "other", Name: "app-fallback", Port: "8080"},
+		},
+		// 3
+		{
+			fallback: "app-fallback",
+			logging:  `WARN ignoring fallback backend on ingress 'default/ing1': invalid service syntax, expected [<namespace>/]<name>:<port>: app-fallback`,
+		},
+		// 4
+		{
+			fallback: "app-missing:8080",
+			logging:  `WARN ignoring fallback backend on ingress 'default/ing1': service 'default/app-missing:8080' was not found`,
+		},
+		// 5
+		{
+			fallback: "app:8080",
+			logging:  `WARN ignoring fallback backend on ingress 'default/ing1': backend cannot fall back to itself`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, svc := range [][]string{{"default", "app"}, {"default", "app-fallback"}, {"other", "app-fallback"}} {
+			c.haproxy.Backends().AcquireBackend(svc[0], svc[1], "8080")
+		}
+		d := c.createBackendData("default/app", source, map[string]string{ingtypes.BackFallbackBackend: test.fallback}, map[string]string{})
+		c.createUpdater().buildBackendFallback(d)
+		c.compareObjects("fallback backend", i, d.backend.Fallback, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestForceClose(t *testing.T) {
+	var agents, expAgents []string
+	for i := 0; i < 22; i++ {
+		agents = append(agents, fmt.Sprintf("LegacyClient/%d", i))
+	}
+	expAgents = agents[:20]
+	testCases := []struct {
+		agents   string
+		modeTCP  bool
+		expected []string
+		logging  string
+	}{
+		// 0
+		{
+			agents:   "",
+			expected: nil,
+		},
+		// 1
+		{
+			agents:   "LegacyClient/1.0",
+			expected: []string{"LegacyClient/1.0"},
+		},
+		// 2
+		{
+			agents: `
+EmbeddedHTTP
+^Wget/1\.1[0-9]
+
+Acme Sensor v[0-9]+`,
+			expected: []string{"EmbeddedHTTP", `^Wget/1\.1[0-9]`, "Acme Sensor v[0-9]+"},
+		},
+		// 3
+		{
+			agents: `EmbeddedHTTP
+Broken(Client
+Legacy[`,
+			expected: []string{"EmbeddedHTTP"},
+			logging: `
+WARN ignoring invalid user agent pattern on ingress 'default/ing1': Broken(Client
+WARN ignoring invalid user agent pattern on ingress 'default/ing1': Legacy[`,
+		},
+		// 4
+		{
+			agents:   strings.Join(agents, "\n"),
+			expected: expAgents,
+			logging:  `WARN force-close-user-agents on ingress 'default/ing1' has 22 patterns, using only the first 20`,
+		},
+		// 5
+		{
+			agents:   "EmbeddedHTTP\nLegacy\tClient\nLegacy #1",
+			expected: []string{"EmbeddedHTTP"},
+			logging: `
+ERROR ignoring 'force-close-user-agents' configuration on ingress 'default/ing1': unsafe char '\t' in the value
+ERROR ignoring 'force-close-user-agents' configuration on ingress 'default/ing1': unsafe char '#' in the value`,
+		},
+		// 6
+		{
+			agents:   "EmbeddedHTTP",
+			modeTCP:  true,
+			expected: nil,
+			logging:  `WARN ignoring force-close-user-agents on ingress 'default/ing1': backend is not in http mode`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, map[string]string{ingtypes.BackForceCloseUserAgents: test.agents}, map[string]string{})
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendForceClose(d)
+		c.compareObjects("force close", i, d.backend.ForceCloseUAs, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestForwardedHeaders(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		xoffHdr  string
+		expected hatypes.BackendForwardedHeaders
+		logging  string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.BackendForwardedHeaders{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackForwardedHeaders: "host,port,original-for",
+			},
+			expected: hatypes.BackendForwardedHeaders{
+				Host:        "X-Forwarded-Host",
+				OriginalFor: "X-Original-Forwarded-For",
+				Port:        "X-Forwarded-Port",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackForwardedHeaders: "original-for",
+			},
+			xoffHdr: "X-Client-Chain",
+			expected: hatypes.BackendForwardedHeaders{
+				OriginalFor: "X-Client-Chain",
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackForwardedHeaders: "Host, proto",
+				ingtypes.BackForwardedHostHdr: "X-Original-Host",
+			},
+			expected: hatypes.BackendForwardedHeaders{
+				Host: "X-Original-Host",
+			},
+			logging: `WARN ignoring invalid forwarded header on ingress 'default/ing1': 'proto'`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackForwardedHeaders: "host,port",
+				ingtypes.BackForwardedHostHdr: "X-Host:",
+				ingtypes.BackForwardedPortHdr: "X-Port",
+			},
+			expected: hatypes.BackendForwardedHeaders{
+				Host: "X-Forwarded-Host",
+				Port: "X-Port",
+			},
+			logging: `WARN ignoring invalid header name on ingress 'default/ing1' for forwarded-host-hdr: 'X-Host:', using 'X-Forwarded-Host' instead`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackForwardedHeaders: "host",
+			},
+			modeTCP:  true,
+			expected: hatypes.BackendForwardedHeaders{},
+			logging:  `WARN ignoring forwarded-headers on ingress 'default/ing1': backend is not in http mode`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackForwardedHostHdr: "X-Forwarded-Host",
+		ingtypes.BackForwardedPortHdr: "X-Forwarded-Port",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().OriginalForwardedForHdr = test.xoffHdr
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendForwardedHeaders(d)
+		c.compareObjects("forwarded headers", i, d.backend.ForwardedHeaders, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	testCases := []struct {
+		headers  string
+		expected []*hatypes.BackendHeader
+		logging  string
+	}{
+		// 0
+		{
+			headers: `invalid`,
+			logging: `WARN ignoring header on ingress 'ing1/app': missing header name or value: invalid`,
+		},
+		// 1
+		{
+			headers: `key value`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "key", Value: "value"},
+			},
+		},
+		// 2
+		{
+			headers: `name: content`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "name", Value: "content"},
+			},
+		},
+		// 3
+		{
+			headers: `k:v`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "k", Value: "v"},
+			},
+		},
+		// 4
+		{
+			headers: `host: %[service].%[namespace].svc.cluster.local`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "host", Value: "app.default.svc.cluster.local"},
+			},
+		},
+		// 5
+		{
+			headers: `
+k8snamespace: %[namespace]
+k8sservice: %[service]
+host: %[service].%[namespace].svc.cluster.local
+`,
+			expected: []*hatypes.BackendHeader{
+				{Name: "k8snamespace", Value: "default"},
+				{Name: "k8sservice", Value: "app"},
+				{Name: "host", Value: "app.default.svc.cluster.local"},
+			},
+		},
+	}
+	source := &Source{
+		Namespace: "ing1",
+		Name:      "app",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		ann := map[string]map[string]string{
+			"/": {ingtypes.BackHeaders: test.headers},
+		}
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, ann, []string{"/"})
+		c.createUpdater().buildBackendHeaders(d)
+		c.compareObjects("headers", i, d.backend.Headers, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	testCases := []struct {
+		paths      []string
+		source     Source
+		annDefault map[string]string
+		ann        map[string]map[string]string
+		httpOnly   bool
+		expected   map[string]hatypes.HSTS
+		logging    string
+	}{
+		// 0
+		{
+			paths: []string{"/", "/url"},
+			annDefault: map[string]string{
+				ingtypes.BackHSTS:       "true",
+				ingtypes.BackHSTSMaxAge: "15768000",
+			},
+			ann: map[string]map[string]string{
+				"/": {},
+				"/url": {
+					ingtypes.BackHSTSMaxAge:  "50",
+					ingtypes.BackHSTSPreload: "true",
+				},
+			},
+			expected: map[string]hatypes.HSTS{
+				"/": {
+					Enabled:    true,
+					MaxAge:     15768000,
+					Subdomains: false,
+					Preload:    false,
+				},
+				"/url": {
+					Enabled:    true,
+					MaxAge:     50,
+					Subdomains: false,
+					Preload:    true,
+				},
+			},
+		},
+		// 1
+		{
+			paths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackHSTS:       "true",
+				ingtypes.BackHSTSMaxAge: "15768000",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackHSTSMaxAge:            "50",
+					ingtypes.BackHSTSPreload:           "not-valid-bool",
+					ingtypes.BackHSTSIncludeSubdomains: "true",
+				},
+			},
+			expected: map[string]hatypes.HSTS{
+				"/": {
+					Enabled:    true,
+					MaxAge:     50,
+					Subdomains: true,
+					Preload:    false,
+				},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid bool expression on ingress 'default/ing1' key 'hsts-preload': not-valid-bool`,
+		},
+		// 2
+		{
+			paths: []string{"/"},
+			expected: map[string]hatypes.HSTS{
+				"/": {},
+			},
+		},
+		// 3
+		{
+			paths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackHSTS:       "true",
+				ingtypes.BackHSTSMaxAge: "15768000",
+			},
+			httpOnly: true,
+			expected: map[string]hatypes.HSTS{
+				"/": {MaxAge: 15768000},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
+		for _, path := range d.backend.Paths {
+			path.Host = &hostResolver{httpOnly: test.httpOnly}
+		}
+		u := c.createUpdater()
+		u.buildBackendHSTS(d)
+		actual := map[string]hatypes.HSTS{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.HSTS
+		}
+		c.compareObjects("hsts", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]map[string]string
+		external bool
+		haslua   bool
+		backend  string
+		authBack string
+		crossNS  bool
+		authExp  map[string]hatypes.AuthExternal
+		logging  string
+	}{
+		// 0
+		{
+			ann:     map[string]map[string]string{},
+			authExp: map[string]hatypes.AuthExternal{},
+		},
+		// 1
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "none",
+				},
+			},
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {AlwaysDeny: true},
+			},
+			logging: "WARN ignoring invalid oauth implementation 'none' on ingress 'default/ing1'",
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {AlwaysDeny: true},
+			},
+			logging: "ERROR path '/oauth2' was not found on namespace 'default'",
+		},
+		// 3
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 4
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthURIPrefix: "/auth",
+				},
+			},
+			backend: "default:back:/auth",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/auth/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/auth/auth",
+					RedirectOnFail:  "/auth/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 5
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "X-Auth-New:req.var_from_lua",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-New": "req.var_from_lua"},
+				},
+			},
+		},
+		// 6
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "space before:attr",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{},
+				},
+			},
+			logging: "WARN invalid header format 'space before:attr' on ingress 'default/ing1'",
+		},
+		// 7
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "x-header:x- invalid",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{},
+				},
+			},
+			logging: "WARN invalid header format 'x-header:x- invalid' on ingress 'default/ing1'",
+		},
+		// 8
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: "more:colons:unsupported",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{},
+				},
+			},
+			logging: "WARN invalid header format 'more:colons:unsupported' on ingress 'default/ing1'",
+		},
+		// 9
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthHeaders: ",,X-Auth-Request-Email,,X-Auth-New:x-header-from-auth,,,X-Auth-Other:req.other",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars: map[string]string{
+						"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email",
+						"X-Auth-New":           "req.auth_response_header.x_header_from_auth",
+						"X-Auth-Other":         "req.other",
+					},
+				},
+			},
+		},
+		// 10
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+				"/app": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			external: true,
+			backend:  "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/":    {AlwaysDeny: true},
+				"/app": {AlwaysDeny: true},
+			},
+			logging: `
+WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config
+WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config
+`,
+		},
+		// 11
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+				"/app": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			external: true,
+			haslua:   true,
+			backend:  "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+				"/app": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 12
+		{
+			ann: map[string]map[string]string{
+				"/": {},
+				"/app": {
+					ingtypes.BackOAuth: "oauth2_proxy",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {},
+				"/app": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 13
+		{
+			ann: map[string]map[string]string{
+				"/": {},
+				"/app": {
+					ingtypes.BackAuthURL: "http://10.0.0.2:8000",
+					ingtypes.BackOAuth:   "oauth2_proxy",
+				},
+			},
+			authExp: map[string]hatypes.AuthExternal{
+				"/":    {},
+				"/app": {},
+			},
+			logging: `WARN ignoring oauth configuration on ingress 'default/ing1': auth-url was configured and has precedence`,
+		},
+		// 14
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:            "oauth2_proxy",
+					ingtypes.BackCookieAutoSecure: "true",
+				},
+				"/app": {
+					ingtypes.BackOAuth:            "oauth2_proxy",
+					ingtypes.BackCookieAutoSecure: "false",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+					SecureCookies:   true,
+				},
+				"/app": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 15
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:             "oauth2_proxy",
+					ingtypes.BackOAuthCookieDomain: ".Host.Local",
+					ingtypes.BackOAuthSetSecure:    "true",
+				},
+				"/app": {
+					ingtypes.BackOAuth:             "oauth2_proxy",
+					ingtypes.BackOAuthCookieDomain: "corp.example.com",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+					CookieDomain:    "host.local",
+					CookieSetSecure: true,
+				},
+				"/app": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+			logging: `ERROR ignoring oauth cookie domain on ingress 'default/ing1': host 'host.local' is not a subdomain of 'corp.example.com'`,
+		},
+		// 16
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:             "oauth2_proxy",
+					ingtypes.BackOAuthCookieDomain: "host_local",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+			logging: `ERROR ignoring invalid oauth cookie domain 'host_local' on ingress 'default/ing1'`,
+		},
+		// 17
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthURIPrefix: "/auth\nhttp-request allow",
+				},
+			},
+			backend: "default:back:/auth",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {AlwaysDeny: true},
+			},
+			logging: `ERROR ignoring 'oauth-uri-prefix' configuration on ingress 'default/ing1': unsafe char '\n' in the value`,
+		},
+		// 18
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthBackend: "oauth2-proxy:4180",
+				},
+			},
+			authBack: "default:oauth2-proxy",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_oauth2-proxy_4180",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+		},
+		// 19
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthBackend: "auth/oauth2-proxy:4180",
+				},
+			},
+			authBack: "auth:oauth2-proxy",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {AlwaysDeny: true},
+			},
+			logging: `ERROR ignoring oauth backend on ingress 'default/ing1': reading service 'auth/oauth2-proxy' from namespace 'default' is denied, see cross-namespace-services configuration key`,
+		},
+		// 20
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthBackend: "auth/oauth2-proxy:4180",
+				},
+			},
+			authBack: "auth:oauth2-proxy",
+			crossNS:  true,
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "auth_oauth2-proxy_4180",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
 			},
+		},
+		// 21
+		{
 			ann: map[string]map[string]string{
-				"/": {},
-				"/url": {
-					ingtypes.BackHSTSMaxAge:  "50",
-					ingtypes.BackHSTSPreload: "true",
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthBackend: "auth_oauth2-proxy_4180",
 				},
 			},
-			expected: map[string]hatypes.HSTS{
+			backend:  "default:back:/oauth2",
+			authBack: "auth:oauth2-proxy",
+			crossNS:  true,
+			authExp: map[string]hatypes.AuthExternal{
 				"/": {
-					Enabled:    true,
-					MaxAge:     15768000,
-					Subdomains: false,
-					Preload:    false,
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "auth_oauth2-proxy_4180",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
-				"/url": {
-					Enabled:    true,
-					MaxAge:     50,
-					Subdomains: false,
-					Preload:    true,
+			},
+			logging: `INFO-V(2) using oauth backend 'auth_oauth2-proxy_4180' declared on ingress 'default/ing1' instead of 'default_back_8080' found on path '/oauth2'`,
+		},
+		// 22
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthBackend: "auth/oauth2-proxy:4180",
 				},
 			},
+			backend: "default:back:/oauth2",
+			crossNS: true,
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {AlwaysDeny: true},
+			},
+			logging: `ERROR ignoring oauth backend on ingress 'default/ing1': service 'auth/oauth2-proxy:4180' was not found`,
 		},
-		// 1
+		// 23
 		{
-			paths: []string{"/"},
-			annDefault: map[string]string{
-				ingtypes.BackHSTS:       "true",
-				ingtypes.BackHSTSMaxAge: "15768000",
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthBackend: "oauth2-proxy",
+				},
 			},
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {AlwaysDeny: true},
+			},
+			logging: `ERROR ignoring oauth backend on ingress 'default/ing1': invalid service syntax, expected [<namespace>/]<name>:<port> or <namespace>_<name>_<port>: oauth2-proxy`,
+		},
+		// 24
+		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackHSTSMaxAge:            "50",
-					ingtypes.BackHSTSPreload:           "not-valid-bool",
-					ingtypes.BackHSTSIncludeSubdomains: "true",
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthSkipPaths: "/healthz, /public",
+				},
+				"/healthz": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthSkipPaths: "/healthz, /public",
+				},
+				"/public": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthSkipPaths: "/healthz, /public",
 				},
 			},
-			expected: map[string]hatypes.HSTS{
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
 				"/": {
-					Enabled:    true,
-					MaxAge:     50,
-					Subdomains: true,
-					Preload:    false,
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
+				"/healthz": {},
+				"/public":  {},
 			},
-			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
-			logging: `WARN ignoring invalid bool expression on ingress 'default/ing1' key 'hsts-preload': not-valid-bool`,
 		},
-		// 2
+		// 25
 		{
-			paths: []string{"/"},
-			expected: map[string]hatypes.HSTS{
-				"/": {},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthSkipPaths: "/healthz,/status",
+				},
+				"/healthz": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthSkipPaths: "/healthz,/status",
+				},
+				"/app": {
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthSkipPaths: "/healthz,/status",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+				"/app": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+				"/healthz": {},
 			},
+			logging: "WARN ignoring oauth-skip-paths path '/status' on ingress 'default/ing1': path not found on backend 'default_app_8080'",
 		},
-	}
-	for i, test := range testCases {
-		c := setup(t)
-		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
-		u := c.createUpdater()
-		u.buildBackendHSTS(d)
-		actual := map[string]hatypes.HSTS{}
-		for _, path := range d.backend.Paths {
-			actual[path.Path()] = path.HSTS
-		}
-		c.compareObjects("hsts", i, actual, test.expected)
-		c.logger.CompareLogging(test.logging)
-		c.teardown()
-	}
-}
-
-func TestOAuth(t *testing.T) {
-	testCases := []struct {
-		ann      map[string]map[string]string
-		external bool
-		haslua   bool
-		backend  string
-		authExp  map[string]hatypes.AuthExternal
-		logging  string
-	}{
-		// 0
+		// 26
 		{
-			ann:     map[string]map[string]string{},
-			authExp: map[string]hatypes.AuthExternal{},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:         "oauth2_proxy",
+					ingtypes.BackOAuthAuthURI:  "/oauth2/auth-check",
+					ingtypes.BackOAuthStartURI: "https://auth.example.local/oauth2/start",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth-check",
+					RedirectOnFail:  "https://auth.example.local/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
 		},
-		// 1
+		// 27
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth: "none",
+					ingtypes.BackOAuth:         "oauth2_proxy",
+					ingtypes.BackOAuthStartURI: "/oauth2/sign_in?provider=github",
 				},
 			},
+			backend: "default:back:/oauth2",
 			authExp: map[string]hatypes.AuthExternal{
-				"/": {AlwaysDeny: true},
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/sign_in?provider=github&rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
 			},
-			logging: "WARN ignoring invalid oauth implementation 'none' on ingress 'default/ing1'",
 		},
-		// 2
+		// 28
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
+					ingtypes.BackOAuth:        "oauth2_proxy",
+					ingtypes.BackOAuthAuthURI: "oauth2/auth",
 				},
 			},
+			backend: "default:back:/oauth2",
 			authExp: map[string]hatypes.AuthExternal{
-				"/": {AlwaysDeny: true},
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
 			},
-			logging: "ERROR path '/oauth2' was not found on namespace 'default'",
+			logging: `WARN ignoring invalid URI on ingress 'default/ing1' key 'oauth-auth-uri': oauth2/auth`,
 		},
-		// 3
+		// 29
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
+					ingtypes.BackOAuth:         "oauth2_proxy",
+					ingtypes.BackOAuthStartURI: "/oauth2/start?rd=\"x\"",
 				},
 			},
 			backend: "default:back:/oauth2",
@@ -1810,51 +5230,91 @@ func TestOAuth(t *testing.T) {
 					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
+			logging: `WARN ignoring invalid URI on ingress 'default/ing1' key 'oauth-start-uri': /oauth2/start?rd="x"`,
 		},
-		// 4
+		// 30
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth:          "oauth2_proxy",
-					ingtypes.BackOAuthURIPrefix: "/auth",
+					ingtypes.BackOAuth:         "oauth2_proxy",
+					ingtypes.BackOAuthStartURI: "/oauth2/sign in",
 				},
 			},
-			backend: "default:back:/auth",
+			backend: "default:back:/oauth2",
 			authExp: map[string]hatypes.AuthExternal{
 				"/": {
-					AllowedPath:     "/auth/",
+					AllowedPath:     "/oauth2/",
 					AuthBackendName: "default_back_8080",
-					AuthPath:        "/auth/auth",
-					RedirectOnFail:  "/auth/start?rd=%[path]",
+					AuthPath:        "/oauth2/auth",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
 					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
+			logging: `WARN ignoring invalid URI on ingress 'default/ing1' key 'oauth-start-uri': /oauth2/sign in`,
 		},
-		// 5
+		// 31
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "X-Auth-New:req.var_from_lua",
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthErrorMode: "deny",
+					ingtypes.BackOAuthHeaders:   "X-Auth-Request-Email,X-User:X-Auth-Request-User",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					DenyStatus:      401,
+					HeadersVars: map[string]string{
+						"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email",
+						"X-User":               "req.auth_response_header.x_auth_request_user",
+					},
+				},
+			},
+		},
+		// 32
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:           "oauth2_proxy",
+					ingtypes.BackOAuthErrorMode:  "deny",
+					ingtypes.BackOAuthDenyStatus: "403",
+					ingtypes.BackOAuthDenyBody:   `{"error":"forbidden"}`,
+				},
+				"/app": {
+					ingtypes.BackOAuth: "oauth2_proxy",
 				},
 			},
 			backend: "default:back:/oauth2",
 			authExp: map[string]hatypes.AuthExternal{
 				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					DenyStatus:      403,
+					DenyBody:        `{"error":"forbidden"}`,
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+				"/app": {
 					AllowedPath:     "/oauth2/",
 					AuthBackendName: "default_back_8080",
 					AuthPath:        "/oauth2/auth",
 					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-New": "req.var_from_lua"},
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
 		},
-		// 6
+		// 33
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "space before:attr",
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthErrorMode: "negotiate",
+					ingtypes.BackOAuthDenyBody:  `{"error":"unauthorized"}`,
+					ingtypes.BackOAuthHeaders:   "X-User:X-Auth-Request-User",
 				},
 			},
 			backend: "default:back:/oauth2",
@@ -1864,17 +5324,20 @@ func TestOAuth(t *testing.T) {
 					AuthBackendName: "default_back_8080",
 					AuthPath:        "/oauth2/auth",
 					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{},
+					RedirHTMLOnly:   true,
+					RedirNoXHR:      true,
+					DenyStatus:      401,
+					DenyBody:        `{"error":"unauthorized"}`,
+					HeadersVars:     map[string]string{"X-User": "req.auth_response_header.x_auth_request_user"},
 				},
 			},
-			logging: "WARN invalid header format 'space before:attr' on ingress 'default/ing1'",
 		},
-		// 7
+		// 34
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "x-header:x- invalid",
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthErrorMode: "json",
 				},
 			},
 			backend: "default:back:/oauth2",
@@ -1884,17 +5347,39 @@ func TestOAuth(t *testing.T) {
 					AuthBackendName: "default_back_8080",
 					AuthPath:        "/oauth2/auth",
 					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{},
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
-			logging: "WARN invalid header format 'x-header:x- invalid' on ingress 'default/ing1'",
+			logging: `WARN ignoring invalid oauth error mode on ingress 'default/ing1': json`,
 		},
-		// 8
+		// 35
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackOAuth:           "oauth2_proxy",
+					ingtypes.BackOAuthErrorMode:  "deny",
+					ingtypes.BackOAuthDenyStatus: "302",
+				},
+			},
+			backend: "default:back:/oauth2",
+			authExp: map[string]hatypes.AuthExternal{
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					DenyStatus:      401,
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				},
+			},
+			logging: `WARN ignoring invalid oauth deny status on ingress 'default/ing1': 302`,
+		},
+		// 36
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: "more:colons:unsupported",
+					ingtypes.BackOAuth:          "oauth2_proxy",
+					ingtypes.BackOAuthErrorMode: "deny",
+					ingtypes.BackOAuthDenyBody:  `{'error':'unauthorized'}`,
 				},
 			},
 			backend: "default:back:/oauth2",
@@ -1903,18 +5388,18 @@ func TestOAuth(t *testing.T) {
 					AllowedPath:     "/oauth2/",
 					AuthBackendName: "default_back_8080",
 					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{},
+					DenyStatus:      401,
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
-			logging: "WARN invalid header format 'more:colons:unsupported' on ingress 'default/ing1'",
+			logging: `WARN ignoring invalid oauth deny body on ingress 'default/ing1': {'error':'unauthorized'}`,
 		},
-		// 9
+		// 37
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth:        "oauth2_proxy",
-					ingtypes.BackOAuthHeaders: ",,X-Auth-Request-Email,,X-Auth-New:x-header-from-auth,,,X-Auth-Other:req.other",
+					ingtypes.BackOAuth:                "oauth2_proxy",
+					ingtypes.BackOAuthPassAccessToken: "true",
 				},
 			},
 			backend: "default:back:/oauth2",
@@ -1923,78 +5408,65 @@ func TestOAuth(t *testing.T) {
 					AllowedPath:     "/oauth2/",
 					AuthBackendName: "default_back_8080",
 					AuthPath:        "/oauth2/auth",
+					BearerTokenVar:  "req.auth_response_header.x_auth_request_access_token",
 					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars: map[string]string{
-						"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email",
-						"X-Auth-New":           "req.auth_response_header.x_header_from_auth",
-						"X-Auth-Other":         "req.other",
-					},
+					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
 		},
-		// 10
+		// 38
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
-				},
-				"/app": {
-					ingtypes.BackOAuth: "oauth2_proxy",
+					ingtypes.BackOAuth:                "oauth2_proxy",
+					ingtypes.BackOAuthHeaders:         "X-User:X-Auth-Request-User,authorization:X-Auth-Request-Access-Token",
+					ingtypes.BackOAuthPassAccessToken: "true",
 				},
 			},
-			external: true,
-			backend:  "default:back:/oauth2",
+			backend: "default:back:/oauth2",
 			authExp: map[string]hatypes.AuthExternal{
-				"/":    {AlwaysDeny: true},
-				"/app": {AlwaysDeny: true},
+				"/": {
+					AllowedPath:     "/oauth2/",
+					AuthBackendName: "default_back_8080",
+					AuthPath:        "/oauth2/auth",
+					BearerTokenVar:  "req.auth_response_header.x_auth_request_access_token",
+					RedirectOnFail:  "/oauth2/start?rd=%[path]",
+					HeadersVars:     map[string]string{"X-User": "req.auth_response_header.x_auth_request_user"},
+				},
 			},
-			logging: `
-WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config
-WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-json4 and enable 'external-has-lua' global config
-`,
+			logging: `WARN ignoring 'authorization' header of oauth headers on ingress 'default/ing1': oauth-pass-access-token is enabled`,
 		},
-		// 11
+		// 39
 		{
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackOAuth: "oauth2_proxy",
-				},
-				"/app": {
-					ingtypes.BackOAuth: "oauth2_proxy",
+					ingtypes.BackOAuth:                "oauth2_proxy",
+					ingtypes.BackOAuthHeaders:         "Authorization:X-Auth-Request-Access-Token",
+					ingtypes.BackOAuthPassAccessToken: "false",
 				},
 			},
-			external: true,
-			haslua:   true,
-			backend:  "default:back:/oauth2",
+			backend: "default:back:/oauth2",
 			authExp: map[string]hatypes.AuthExternal{
 				"/": {
 					AllowedPath:     "/oauth2/",
 					AuthBackendName: "default_back_8080",
 					AuthPath:        "/oauth2/auth",
 					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
-				},
-				"/app": {
-					AllowedPath:     "/oauth2/",
-					AuthBackendName: "default_back_8080",
-					AuthPath:        "/oauth2/auth",
-					RedirectOnFail:  "/oauth2/start?rd=%[path]",
-					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+					HeadersVars:     map[string]string{"Authorization": "req.auth_response_header.x_auth_request_access_token"},
 				},
 			},
 		},
-		// 12
+		// 40
 		{
 			ann: map[string]map[string]string{
-				"/": {},
-				"/app": {
-					ingtypes.BackOAuth: "oauth2_proxy",
+				"/": {
+					ingtypes.BackOAuth:             "oauth2_proxy",
+					ingtypes.BackAuthCacheDuration: "1m",
 				},
 			},
 			backend: "default:back:/oauth2",
 			authExp: map[string]hatypes.AuthExternal{
-				"/": {},
-				"/app": {
+				"/": {
 					AllowedPath:     "/oauth2/",
 					AuthBackendName: "default_back_8080",
 					AuthPath:        "/oauth2/auth",
@@ -2002,21 +5474,7 @@ WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-j
 					HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
 				},
 			},
-		},
-		// 13
-		{
-			ann: map[string]map[string]string{
-				"/": {},
-				"/app": {
-					ingtypes.BackAuthURL: "http://10.0.0.2:8000",
-					ingtypes.BackOAuth:   "oauth2_proxy",
-				},
-			},
-			authExp: map[string]hatypes.AuthExternal{
-				"/":    {},
-				"/app": {},
-			},
-			logging: `WARN ignoring oauth configuration on ingress 'default/ing1': auth-url was configured and has precedence`,
+			logging: `WARN ignoring 'auth-cache-duration' on ingress 'default/ing1': oauth responses cannot be cached`,
 		},
 	}
 
@@ -2025,7 +5483,11 @@ WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-j
 		Name:      "ing1",
 		Type:      "ingress",
 	}
-	annDefault := map[string]string{ingtypes.BackOAuthHeaders: "X-Auth-Request-Email"}
+	annDefault := map[string]string{
+		ingtypes.BackOAuthDenyStatus: "401",
+		ingtypes.BackOAuthErrorMode:  "redirect",
+		ingtypes.BackOAuthHeaders:    "X-Auth-Request-Email",
+	}
 	for i, test := range testCases {
 		c := setup(t)
 		d := c.createBackendMappingData("default/app", source, annDefault, test.ann, []string{})
@@ -2038,7 +5500,13 @@ WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-j
 			backend := c.haproxy.Backends().AcquireBackend(b[0], b[1], "8080")
 			c.haproxy.Hosts().AcquireHost("app.local").AddPath(backend, b[2], hatypes.MatchBegin)
 		}
-		c.createUpdater().buildBackendOAuth(d)
+		if test.authBack != "" {
+			b := strings.Split(test.authBack, ":")
+			c.haproxy.Backends().AcquireBackend(b[0], b[1], "4180")
+		}
+		u := c.createUpdater()
+		u.options.DynamicConfig.CrossNamespaceServices = test.crossNS
+		u.buildBackendOAuth(d)
 		actual := map[string]hatypes.AuthExternal{}
 		for _, path := range d.backend.Paths {
 			actual[path.Path()] = path.AuthExternal
@@ -2058,6 +5526,207 @@ WARN oauth2_proxy on ingress 'default/ing1' needs Lua json module, install lua-j
 	}
 }
 
+func TestRetryBudget(t *testing.T) {
+	testCases := []struct {
+		budget   string
+		expected float64
+		logging  string
+	}{
+		// 0
+		{
+			budget:   "",
+			expected: 0,
+		},
+		// 1
+		{
+			budget:   "5%",
+			expected: 5,
+		},
+		// 2
+		{
+			budget:   "0.5",
+			expected: 0.5,
+		},
+		// 3
+		{
+			budget:   "150%",
+			expected: 0,
+			logging:  `WARN ignoring invalid retry budget percentage on ingress 'default/ing1': 150%`,
+		},
+		// 4
+		{
+			budget:   "five",
+			expected: 0,
+			logging:  `WARN ignoring invalid retry budget percentage on ingress 'default/ing1': five`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, map[string]string{ingtypes.BackRetryBudgetWarn: test.budget}, map[string]string{})
+		c.createUpdater().buildBackendRetryBudget(d)
+		c.compareObjects("retry budget", i, d.backend.RetryBudgetWarn, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestRateLimitScope(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		peers    string
+		expected hatypes.BackendLimit
+		logging  string
+	}{
+		// 0
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitRPS:       "10",
+				ingtypes.BackRateLimitScope: "local",
+			},
+			peers:    "ingress",
+			expected: hatypes.BackendLimit{RPS: 10},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitRPS:       "10",
+				ingtypes.BackRateLimitScope: "global",
+			},
+			peers:    "ingress",
+			expected: hatypes.BackendLimit{RPS: 10, Peers: "ingress"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitConnections: "20",
+				ingtypes.BackRateLimitScope:   "global",
+			},
+			peers:    "ingress",
+			expected: hatypes.BackendLimit{Connections: 20, Peers: "ingress"},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackRateLimitScope: "global",
+			},
+			peers:    "ingress",
+			expected: hatypes.BackendLimit{},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackLimitRPS:       "10",
+				ingtypes.BackRateLimitScope: "global",
+			},
+			expected: hatypes.BackendLimit{RPS: 10},
+			logging:  `WARN using local rate limit scope on ingress 'default/ing1': peers are not configured, see peers-service global config`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().Peers.Name = test.peers
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendLimit(d)
+		c.compareObjects("limit", i, d.backend.Limit, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSlowStart(t *testing.T) {
+	testCases := []struct {
+		ann          map[string]string
+		expSlowStart string
+		expWarmUp    hatypes.BackendWarmUp
+		logging      string
+	}{
+		// 0
+		{
+			ann: map[string]string{},
+		},
+		// 1
+		{
+			ann:          map[string]string{ingtypes.BackSlowStart: "30s"},
+			expSlowStart: "30s",
+		},
+		// 2
+		{
+			ann:     map[string]string{ingtypes.BackSlowStart: "30"},
+			logging: `WARN ignoring invalid time format on ingress 'default/ing1': 30`,
+		},
+		// 3
+		{
+			ann:     map[string]string{ingtypes.BackWarmUpWindow: "2m"},
+			logging: `WARN ignoring warm-up window on ingress 'default/ing1': slow-start is not configured`,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart:    "30s",
+				ingtypes.BackWarmUpWindow: "2m",
+			},
+			expSlowStart: "30s",
+			expWarmUp:    hatypes.BackendWarmUp{Window: 2 * time.Minute, MinCapacity: 50, AbortCapacity: 25},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart:           "30s",
+				ingtypes.BackWarmUpWindow:        "90s",
+				ingtypes.BackWarmUpMinCapacity:   "80",
+				ingtypes.BackWarmUpAbortCapacity: "0%",
+			},
+			expSlowStart: "30s",
+			expWarmUp:    hatypes.BackendWarmUp{Window: 90 * time.Second, MinCapacity: 80, AbortCapacity: 0},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart:    "30s",
+				ingtypes.BackWarmUpWindow: "2 minutes",
+			},
+			expSlowStart: "30s",
+			logging:      `WARN ignoring invalid warm-up window on ingress 'default/ing1': 2 minutes`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart:         "30s",
+				ingtypes.BackWarmUpWindow:      "2m",
+				ingtypes.BackWarmUpMinCapacity: "120%",
+			},
+			expSlowStart: "30s",
+			logging:      `WARN ignoring warm-up due to an invalid capacity percentage on ingress 'default/ing1': 120%`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.BackSlowStart:           "30s",
+				ingtypes.BackWarmUpWindow:        "2m",
+				ingtypes.BackWarmUpAbortCapacity: "50%",
+			},
+			expSlowStart: "30s",
+			logging:      `WARN ignoring warm-up on ingress 'default/ing1': abort capacity 50% should be lesser than the min capacity 50%`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.BackWarmUpMinCapacity:   "50%",
+		ingtypes.BackWarmUpAbortCapacity: "25%",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendData("default/app", source, test.ann, annDefault)
+		c.createUpdater().buildBackendSlowStart(d)
+		c.compareObjects("slow start", i, d.backend.Server.SlowStart, test.expSlowStart)
+		c.compareObjects("warm up", i, d.backend.WarmUp, test.expWarmUp)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestRewriteURL(t *testing.T) {
 	testCases := []struct {
 		source   Source
@@ -2083,23 +5752,123 @@ func TestRewriteURL(t *testing.T) {
 		},
 		// 2
 		{
-			input:    `/app`,
-			expected: `/app`,
+			input:    `/app`,
+			expected: `/app`,
+		},
+	}
+
+	for i, test := range testCases {
+		c := setup(t)
+		var ann map[string]string
+		if test.input != "" {
+			ann = map[string]string{ingtypes.BackRewriteTarget: test.input}
+		}
+		d := c.createBackendData("default/app", &test.source, map[string]string{}, map[string]string{})
+		d.backend.AddBackendPath(hatypes.CreateHostPathLink("d1.local", "/", hatypes.MatchBegin))
+		d.mapper.AddAnnotations(&test.source, hatypes.CreateHostPathLink("d1.local", "/", hatypes.MatchBegin), ann)
+		c.createUpdater().buildBackendRewriteURL(d)
+		actual := d.backend.Paths[0].RewriteURL
+		c.compareObjects("rewrite", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestProxyRedirect(t *testing.T) {
+	testCases := []struct {
+		path     string
+		ann      map[string]string
+		expected hatypes.ProxyRedirect
+		logging  string
+	}{
+		// 0
+		{
+			path: "/app",
+		},
+		// 1
+		{
+			path:     "/app",
+			ann:      map[string]string{ingtypes.BackProxyRedirect: "/other /app"},
+			expected: hatypes.ProxyRedirect{From: "/other", To: "/app"},
+		},
+		// 2
+		{
+			path:    "/app",
+			ann:     map[string]string{ingtypes.BackProxyRedirect: "/other"},
+			logging: `WARN ignoring invalid proxy-redirect on ingress 'default/ing1': '/other', expecting 'auto' or two absolute paths`,
+		},
+		// 3
+		{
+			path:    "/app",
+			ann:     map[string]string{ingtypes.BackProxyRedirect: "other/ /app/"},
+			logging: `WARN ignoring invalid proxy-redirect on ingress 'default/ing1': 'other/ /app/', expecting 'auto' or two absolute paths`,
+		},
+		// 4
+		{
+			path:    "/app",
+			ann:     map[string]string{ingtypes.BackProxyRedirect: "auto"},
+			logging: `WARN ignoring proxy-redirect auto mode on ingress 'default/ing1': path '/app' has no rewrite-target`,
+		},
+		// 5
+		{
+			path: "/app",
+			ann: map[string]string{
+				ingtypes.BackProxyRedirect: "auto",
+				ingtypes.BackRewriteTarget: "/other",
+			},
+			expected: hatypes.ProxyRedirect{From: "/other", To: "/app"},
+		},
+		// 6
+		{
+			path: "/app/",
+			ann: map[string]string{
+				ingtypes.BackProxyRedirect: "auto",
+				ingtypes.BackRewriteTarget: "/other",
+			},
+			expected: hatypes.ProxyRedirect{From: "/other/", To: "/app/"},
+		},
+		// 7
+		{
+			path: "/app",
+			ann: map[string]string{
+				ingtypes.BackProxyRedirect: "auto",
+				ingtypes.BackRewriteTarget: "/",
+			},
+			expected: hatypes.ProxyRedirect{From: "/", To: "/app/"},
+		},
+		// 8
+		{
+			path: "/app",
+			ann: map[string]string{
+				ingtypes.BackProxyRedirect: "auto",
+				ingtypes.BackRewriteTarget: "/app",
+			},
+		},
+		// 9
+		{
+			path: "/app",
+			ann: map[string]string{
+				ingtypes.BackProxyRedirect:     "/other/ /app/",
+				ingtypes.BackProxyRedirectHost: "true",
+			},
+			expected: hatypes.ProxyRedirect{From: "/other/", To: "/app/", Host: true},
 		},
 	}
-
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
 	for i, test := range testCases {
 		c := setup(t)
-		var ann map[string]string
-		if test.input != "" {
-			ann = map[string]string{ingtypes.BackRewriteTarget: test.input}
-		}
-		d := c.createBackendData("default/app", &test.source, map[string]string{}, map[string]string{})
-		d.backend.AddBackendPath(hatypes.CreateHostPathLink("d1.local", "/", hatypes.MatchBegin))
-		d.mapper.AddAnnotations(&test.source, hatypes.CreateHostPathLink("d1.local", "/", hatypes.MatchBegin), ann)
-		c.createUpdater().buildBackendRewriteURL(d)
-		actual := d.backend.Paths[0].RewriteURL
-		c.compareObjects("rewrite", i, actual, test.expected)
+		d := c.createBackendData("default/app", source, map[string]string{}, map[string]string{})
+		link := hatypes.CreateHostPathLink("d1.local", test.path, hatypes.MatchBegin)
+		d.backend.AddBackendPath(link)
+		d.mapper.AddAnnotations(source, link, test.ann)
+		u := c.createUpdater()
+		u.buildBackendRewriteURL(d)
+		u.buildBackendProxyRedirect(d)
+		c.compareObjects("proxy redirect", i, d.backend.Paths[0].ProxyRedirect, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -2147,6 +5916,67 @@ func TestBackendServerNaming(t *testing.T) {
 	}
 }
 
+func TestPodMaintenance(t *testing.T) {
+	pods := map[string]*api.Pod{
+		"default/pod1": {ObjectMeta: meta.ObjectMeta{Labels: map[string]string{"app": "app1"}}},
+		"default/pod2": {ObjectMeta: meta.ObjectMeta{Labels: map[string]string{"app": "app1", "haproxy-ingress.github.io/disabled": "true"}}},
+		"default/pod3": {ObjectMeta: meta.ObjectMeta{Annotations: map[string]string{"haproxy-ingress.github.io/disabled": "True"}}},
+		"default/pod4": {ObjectMeta: meta.ObjectMeta{Labels: map[string]string{"haproxy-ingress.github.io/disabled": "false"}}},
+	}
+	testCase := []struct {
+		ann    map[string]string
+		expEPs []bool
+		expPod []string
+	}{
+		// 0
+		{
+			ann:    map[string]string{},
+			expEPs: []bool{false, false, false, false, false},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackPodMaintenanceKey: "haproxy-ingress.github.io/disabled",
+			},
+			expEPs: []bool{false, true, true, false, false},
+			expPod: []string{"default/pod1", "default/pod2", "default/pod3", "default/pod4", "default/pod5"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackPodMaintenanceKey: "app",
+			},
+			expEPs: []bool{false, false, false, false, false},
+			expPod: []string{"default/pod1", "default/pod2", "default/pod3", "default/pod4", "default/pod5"},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCase {
+		c := setup(t)
+		c.cache.PodList = pods
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		for j := 1; j <= 5; j++ {
+			ep := d.backend.AcquireEndpoint(fmt.Sprintf("172.17.0.%d", 10+j), 8080, fmt.Sprintf("default/pod%d", j))
+			ep.Weight = 1
+		}
+		c.createUpdater().buildBackendPodMaintenance(d)
+		var maint []bool
+		for _, ep := range d.backend.Endpoints {
+			maint = append(maint, ep.Maintenance)
+		}
+		var podLinks []string
+		for j := 1; j <= 5; j++ {
+			pod := fmt.Sprintf("default/pod%d", j)
+			if links := c.tracker.LinkedNames(convtypes.ResourcePod, pod, convtypes.ResourceHABackend); len(links) > 0 {
+				podLinks = append(podLinks, pod)
+			}
+		}
+		c.compareObjects("maintenance", i, maint, test.expEPs)
+		c.compareObjects("pod tracking", i, podLinks, test.expPod)
+		c.teardown()
+	}
+}
+
 func TestBackendProtocol(t *testing.T) {
 	testCase := []struct {
 		source     *Source
@@ -2587,6 +6417,77 @@ func TestSourceAddrIntf(t *testing.T) {
 	}
 }
 
+func TestSplitBackends(t *testing.T) {
+	testCases := []struct {
+		split    string
+		expected []hatypes.BackendSplit
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			split: "app-v2:8080=20",
+			expected: []hatypes.BackendSplit{
+				{Target: hatypes.BackendID{Namespace: "default", Name: "app-v2", Port: "8080"}, Weight: 20},
+			},
+		},
+		// 2
+		{
+			split: "app-v2:8080=60,other/app-v2:8080=40",
+			expected: []hatypes.BackendSplit{
+				{Target: hatypes.BackendID{Namespace: "default", Name: "app-v2", Port: "8080"}, Weight: 60},
+				{Target: hatypes.BackendID{Namespace: "other", Name: "app-v2", Port: "8080"}, Weight: 40},
+			},
+		},
+		// 3
+		{
+			split:   "app-v2:8080=60,other/app-v2:8080=41",
+			logging: `ERROR ignoring split backends on ingress 'default/ing1': the sum of the percents should not be greater than 100, found 101`,
+		},
+		// 4
+		{
+			split:   "app-v2:8080",
+			logging: `WARN ignoring split backends on ingress 'default/ing1': expected [<namespace>/]<name>:<port>=<percent>: app-v2:8080`,
+		},
+		// 5
+		{
+			split:   "app-v2=10",
+			logging: `WARN ignoring split backends on ingress 'default/ing1': invalid service syntax, expected [<namespace>/]<name>:<port>: app-v2`,
+		},
+		// 6
+		{
+			split:   "app-v2:8080=10%",
+			logging: `WARN ignoring split backends on ingress 'default/ing1': invalid percent of service 'app-v2:8080': 10%`,
+		},
+		// 7
+		{
+			split: "app-missing:8080=10,app:8080=10,app-v2:8080=10",
+			expected: []hatypes.BackendSplit{
+				{Target: hatypes.BackendID{Namespace: "default", Name: "app-v2", Port: "8080"}, Weight: 10},
+			},
+			logging: `
+WARN ignoring split backends on ingress 'default/ing1': service 'default/app-missing:8080' was not found
+WARN ignoring split backends on ingress 'default/ing1': backend cannot split to itself`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, svc := range [][]string{{"default", "app"}, {"default", "app-v2"}, {"other", "app-v2"}} {
+			c.haproxy.Backends().AcquireBackend(svc[0], svc[1], "8080")
+		}
+		ann := map[string]map[string]string{
+			"/": {ingtypes.BackSplitBackends: test.split},
+		}
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, ann, []string{})
+		c.createUpdater().buildBackendSplitBackends(d)
+		c.compareObjects("split backends", i, d.backend.Paths[0].SplitBackends, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestSSL(t *testing.T) {
 	type sslMock struct {
 		sha2bits int
@@ -2636,10 +6537,13 @@ func TestSSL(t *testing.T) {
 }
 
 func TestSSLRedirect(t *testing.T) {
+	sslRedirectFalse := false
+	sslRedirectTrue := true
 	testCases := []struct {
 		annDefault map[string]string
 		ann        map[string]map[string]string
 		addPaths   []string
+		host       *hostResolver
 		expected   map[bool][]string
 		source     Source
 		logging    string
@@ -2658,87 +6562,239 @@ func TestSSLRedirect(t *testing.T) {
 					ingtypes.BackSSLRedirect: "true",
 				},
 			},
-			expected: map[bool][]string{
-				true: {"/"},
-			},
-		},
-		// 2
-		{
-			ann: map[string]map[string]string{
-				"/": {
-					ingtypes.BackSSLRedirect: "invalid",
-				},
-			},
-			expected: map[bool][]string{
-				false: {"/"},
-			},
-			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
-			logging: `WARN ignoring invalid bool expression on ingress 'default/ing1' key 'ssl-redirect': invalid`,
+			expected: map[bool][]string{
+				true: {"/"},
+			},
+		},
+		// 2
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSSLRedirect: "invalid",
+				},
+			},
+			expected: map[bool][]string{
+				false: {"/"},
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN ignoring invalid bool expression on ingress 'default/ing1' key 'ssl-redirect': invalid`,
+		},
+		// 3
+		{
+			addPaths: []string{"/other"},
+			annDefault: map[string]string{
+				ingtypes.BackSSLRedirect: "false",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSSLRedirect: "true",
+				},
+				"/url": {
+					ingtypes.BackSSLRedirect: "false",
+				},
+				"/path": {
+					ingtypes.BackSSLRedirect: "no-bool",
+				},
+			},
+			expected: map[bool][]string{
+				false: {"/other", "/path", "/url"},
+				true:  {"/"},
+			},
+			source:  Source{Namespace: "system1", Name: "app", Type: "service"},
+			logging: `WARN ignoring invalid bool expression on service 'system1/app' key 'ssl-redirect': no-bool`,
+		},
+		// 4
+		{
+			annDefault: map[string]string{
+				ingtypes.GlobalNoTLSRedirectLocations: "/.hidden,/app",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSSLRedirect: "false",
+				},
+				"/.hidden/api": {
+					ingtypes.BackSSLRedirect: "true",
+				},
+				"/api": {
+					ingtypes.BackSSLRedirect: "true",
+				},
+				"/app": {
+					ingtypes.BackSSLRedirect: "true",
+				},
+			},
+			expected: map[bool][]string{
+				false: {"/", "/.hidden/api", "/app"},
+				true:  {"/api"},
+			},
+		},
+		// 5
+		{
+			addPaths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackSSLRedirect: "true",
+			},
+			host: &hostResolver{sslRedirect: &sslRedirectFalse},
+			expected: map[bool][]string{
+				false: {"/"},
+			},
+		},
+		// 6
+		{
+			addPaths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackSSLRedirect: "false",
+			},
+			host: &hostResolver{sslRedirect: &sslRedirectTrue},
+			expected: map[bool][]string{
+				true: {"/"},
+			},
+		},
+		// 7
+		{
+			addPaths: []string{"/"},
+			annDefault: map[string]string{
+				ingtypes.BackSSLRedirect: "true",
+			},
+			ann: map[string]map[string]string{
+				"/api": {
+					ingtypes.BackSSLRedirect: "true",
+				},
+				"/app": {
+					ingtypes.BackSSLRedirect: "false",
+				},
+			},
+			host: &hostResolver{sslRedirect: &sslRedirectFalse},
+			expected: map[bool][]string{
+				false: {"/", "/app"},
+				true:  {"/api"},
+			},
+		},
+		// 8
+		{
+			annDefault: map[string]string{
+				ingtypes.BackSSLRedirect: "false",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSSLRedirect: "false",
+				},
+			},
+			host: &hostResolver{sslRedirect: &sslRedirectTrue},
+			expected: map[bool][]string{
+				false: {"/"},
+			},
+		},
+		// 9
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSSLRedirect: "true",
+				},
+			},
+			host: &hostResolver{httpOnly: true, sslRedirect: &sslRedirectTrue},
+			expected: map[bool][]string{
+				false: {"/"},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.addPaths)
+		if test.host != nil {
+			for _, path := range d.backend.Paths {
+				path.Host = test.host
+			}
+		}
+		c.createUpdater().buildBackendSSLRedirect(d)
+		actual := map[bool][]string{}
+		for _, path := range d.backend.Paths {
+			actual[path.SSLRedirect] = append(actual[path.SSLRedirect], path.Path())
+		}
+		if len(actual[false]) == 0 {
+			delete(actual, false)
+		}
+		if len(actual[true]) == 0 {
+			delete(actual, true)
+		}
+		c.compareObjects("sslredirect", i, actual, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSSLRedirectPort(t *testing.T) {
+	testCases := []struct {
+		annDefault map[string]string
+		ann        map[string]map[string]string
+		expected   map[string]int
+		logging    string
+	}{
+		// 0
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSSLRedirect: "true",
+				},
+			},
+			expected: map[string]int{"/": 0},
 		},
-		// 3
+		// 1
 		{
-			addPaths: []string{"/other"},
 			annDefault: map[string]string{
-				ingtypes.BackSSLRedirect: "false",
+				ingtypes.HostHTTPSRedirectPort: "8443",
 			},
 			ann: map[string]map[string]string{
 				"/": {
 					ingtypes.BackSSLRedirect: "true",
 				},
-				"/url": {
+				"/app": {
 					ingtypes.BackSSLRedirect: "false",
 				},
-				"/path": {
-					ingtypes.BackSSLRedirect: "no-bool",
-				},
-			},
-			expected: map[bool][]string{
-				false: {"/other", "/path", "/url"},
-				true:  {"/"},
 			},
-			source:  Source{Namespace: "system1", Name: "app", Type: "service"},
-			logging: `WARN ignoring invalid bool expression on service 'system1/app' key 'ssl-redirect': no-bool`,
+			expected: map[string]int{"/": 8443, "/app": 0},
 		},
-		// 4
+		// 2
 		{
 			annDefault: map[string]string{
-				ingtypes.GlobalNoTLSRedirectLocations: "/.hidden,/app",
+				ingtypes.HostHTTPSRedirectPort: "8443",
 			},
 			ann: map[string]map[string]string{
 				"/": {
-					ingtypes.BackSSLRedirect: "false",
-				},
-				"/.hidden/api": {
-					ingtypes.BackSSLRedirect: "true",
-				},
-				"/api": {
 					ingtypes.BackSSLRedirect: "true",
 				},
 				"/app": {
-					ingtypes.BackSSLRedirect: "true",
+					ingtypes.BackSSLRedirect:       "true",
+					ingtypes.HostHTTPSRedirectPort: "9443",
+				},
+				"/api": {
+					ingtypes.BackSSLRedirect:       "true",
+					ingtypes.HostHTTPSRedirectPort: "443",
 				},
 			},
-			expected: map[bool][]string{
-				false: {"/", "/.hidden/api", "/app"},
-				true:  {"/api"},
+			expected: map[string]int{"/": 8443, "/api": 0, "/app": 9443},
+		},
+		// 3
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					ingtypes.BackSSLRedirect:       "true",
+					ingtypes.HostHTTPSRedirectPort: "70000",
+				},
 			},
+			expected: map[string]int{"/": 0},
+			logging:  `WARN ignoring invalid https redirect port on ingress 'default/ing1': 70000`,
 		},
 	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
 		c := setup(t)
-		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.addPaths)
+		d := c.createBackendMappingData("default/app", source, test.annDefault, test.ann, []string{})
 		c.createUpdater().buildBackendSSLRedirect(d)
-		actual := map[bool][]string{}
+		actual := map[string]int{}
 		for _, path := range d.backend.Paths {
-			actual[path.SSLRedirect] = append(actual[path.SSLRedirect], path.Path())
+			actual[path.Path()] = path.SSLRedirectPort
 		}
-		if len(actual[false]) == 0 {
-			delete(actual, false)
-		}
-		if len(actual[true]) == 0 {
-			delete(actual, true)
-		}
-		c.compareObjects("sslredirect", i, actual, test.expected)
+		c.compareObjects("sslredirect port", i, actual, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -2749,8 +6805,10 @@ func TestTimeout(t *testing.T) {
 		annDefault map[string]string
 		ann        map[string]map[string]string
 		paths      []string
+		version    string
 		source     Source
 		expected   hatypes.BackendTimeoutConfig
+		expPaths   map[string]string
 		logging    string
 	}{
 		// 0
@@ -2783,12 +6841,258 @@ func TestTimeout(t *testing.T) {
 			// use only if declared as svc/ing annotation, otherwise defaults to HAProxy's defaults section
 			expected: hatypes.BackendTimeoutConfig{},
 		},
+		// 3
+		{
+			annDefault: map[string]string{
+				"timeout-server": "30s",
+			},
+			ann: map[string]map[string]string{
+				"/report/generate": {
+					"timeout-server": "5m",
+				},
+			},
+			paths:    []string{"/"},
+			expected: hatypes.BackendTimeoutConfig{},
+			expPaths: map[string]string{"/report/generate": "5m"},
+		},
+		// 4
+		{
+			annDefault: map[string]string{
+				"timeout-server": "30s",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					"timeout-server": "10s",
+				},
+				"/report/generate": {
+					"timeout-server": "1d",
+				},
+			},
+			expected: hatypes.BackendTimeoutConfig{
+				Server: "10s",
+			},
+			expPaths: map[string]string{"/report/generate": "1d"},
+		},
+		// 5
+		{
+			annDefault: map[string]string{
+				"timeout-server": "30s",
+			},
+			ann: map[string]map[string]string{
+				"/report/generate": {
+					"timeout-server": "5m",
+				},
+			},
+			paths:   []string{"/"},
+			version: "2.2",
+			expected: hatypes.BackendTimeoutConfig{
+				Server: "5m",
+			},
+			source:  Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			logging: `WARN per path timeout-server on ingress 'default/ing1' needs set-timeout, not supported by haproxy 2.2, using the same timeout on all paths`,
+		},
+		// 6
+		{
+			annDefault: map[string]string{
+				"timeout-connect": "5s",
+				"timeout-server":  "30s",
+			},
+			ann: map[string]map[string]string{
+				"/": {
+					"timeout-server": "2s",
+				},
+				"/report/generate": {
+					"timeout-server": "5m",
+				},
+			},
+			source: Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			expected: hatypes.BackendTimeoutConfig{
+				Server: "2s",
+			},
+			expPaths: map[string]string{"/report/generate": "5m"},
+			logging:  `WARN timeout-server 2s on ingress 'default/ing1' is lower than timeout-connect 5s`,
+		},
+		// 7
+		{
+			ann: map[string]map[string]string{
+				"/": {
+					"timeout-server": "10zz",
+				},
+				"/app": {
+					"timeout-server": "10zz",
+				},
+			},
+			source:   Source{Namespace: "default", Name: "ing1", Type: "ingress"},
+			expected: hatypes.BackendTimeoutConfig{},
+			logging:  `WARN ignoring invalid time format on ingress 'default/ing1': 10zz`,
+		},
 	}
 	for i, test := range testCase {
 		c := setup(t)
 		d := c.createBackendMappingData("default/app", &test.source, test.annDefault, test.ann, test.paths)
-		c.createUpdater().buildBackendTimeout(d)
+		u := c.createUpdater()
+		if test.version != "" {
+			u.options.HAProxyVersion = test.version
+		}
+		u.buildBackendTimeout(d)
 		c.compareObjects("backend timeout", i, d.backend.Timeout, test.expected)
+		actualPaths := map[string]string{}
+		for _, path := range d.backend.Paths {
+			if path.TimeoutServer != "" {
+				actualPaths[path.Path()] = path.TimeoutServer
+			}
+		}
+		if test.expPaths == nil {
+			test.expPaths = map[string]string{}
+		}
+		c.compareObjects("path timeout", i, actualPaths, test.expPaths)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestTrafficClass(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected hatypes.BackendTrafficClass
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackDenylistClass:        "bots",
+				ingtypes.BackRateLimitExemptClass: "internal,api",
+			},
+			expected: hatypes.BackendTrafficClass{
+				Deny:        []string{"bots"},
+				LimitExempt: []string{"internal", "api"},
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackDenylistClass:        "crawlers,bots",
+				ingtypes.BackRateLimitExemptClass: "external",
+			},
+			expected: hatypes.BackendTrafficClass{
+				Deny: []string{"bots"},
+			},
+			logging: `
+ERROR ignoring traffic class 'crawlers' on ingress 'default/ing1': class was not declared in traffic-classes
+ERROR ignoring traffic class 'external' on ingress 'default/ing1': class was not declared in traffic-classes`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackUseBackendClass: "api=app-v2:8080,internal=other/app-v2:8080",
+			},
+			expected: hatypes.BackendTrafficClass{
+				Routes: []hatypes.BackendClassRoute{
+					{Class: "api", Target: hatypes.BackendID{Namespace: "default", Name: "app-v2", Port: "8080"}},
+					{Class: "internal", Target: hatypes.BackendID{Namespace: "other", Name: "app-v2", Port: "8080"}},
+				},
+			},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackUseBackendClass: "app-v2:8080,legacy=app-v2:8080,api=app-missing:8080,api=app:8080,api=app-v2",
+			},
+			logging: `
+WARN ignoring use backend class on ingress 'default/ing1': expected <class>=[<namespace>/]<name>:<port>: app-v2:8080
+ERROR ignoring traffic class 'legacy' on ingress 'default/ing1': class was not declared in traffic-classes
+WARN ignoring use backend class on ingress 'default/ing1': service 'default/app-missing:8080' was not found
+WARN ignoring use backend class on ingress 'default/ing1': backend cannot route to itself
+WARN ignoring use backend class on ingress 'default/ing1': invalid service syntax, expected [<namespace>/]<name>:<port>: app-v2`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, name := range []string{"internal", "bots", "api"} {
+			c.haproxy.Global().TrafficClasses = append(c.haproxy.Global().TrafficClasses, &hatypes.TrafficClass{Name: name})
+		}
+		for _, svc := range [][]string{{"default", "app"}, {"default", "app-v2"}, {"other", "app-v2"}} {
+			c.haproxy.Backends().AcquireBackend(svc[0], svc[1], "8080")
+		}
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{})
+		c.createUpdater().buildBackendTrafficClass(d)
+		c.compareObjects("traffic class", i, d.backend.TrafficClass, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSPOE(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		modeTCP  bool
+		expected hatypes.BackendSPOE
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEAgent: "risk",
+			},
+			expected: hatypes.BackendSPOE{Agent: "risk"},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEAgent:   "risk",
+				ingtypes.BackSPOEOnError: "deny",
+			},
+			expected: hatypes.BackendSPOE{Agent: "risk", DenyOnError: true},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEAgent:   "risk",
+				ingtypes.BackSPOEOnError: "drop",
+			},
+			expected: hatypes.BackendSPOE{Agent: "risk"},
+			logging:  "WARN ignoring invalid SPOE on error action 'drop' on ingress 'default/ing1', using 'continue' instead",
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEAgent: "fraud",
+			},
+			logging: "ERROR ignoring SPOE agent 'fraud' on ingress 'default/ing1': agent was not declared in spoe-agents",
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEAgent: "modsecurity",
+			},
+			logging: "WARN ignoring SPOE agent on ingress 'default/ing1': modsecurity agent is attached via waf",
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.BackSPOEAgent: "risk",
+			},
+			modeTCP: true,
+			logging: "WARN ignoring SPOE agent on ingress 'default/ing1': backend is in TCP mode",
+		},
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Global().SPOEAgents = []*hatypes.SPOEAgent{{Name: "modsecurity"}, {Name: "risk"}}
+		d := c.createBackendData("default/app", source, test.ann, map[string]string{ingtypes.BackSPOEOnError: "continue"})
+		d.backend.ModeTCP = test.modeTCP
+		c.createUpdater().buildBackendSPOE(d)
+		c.compareObjects("SPOE", i, d.backend.SPOE, test.expected)
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
@@ -3134,3 +7438,136 @@ WARN skipping invalid IP or cidr on ingress 'default/ing1': 192.168.0/16`,
 		c.teardown()
 	}
 }
+
+// backendAnnotationKeys lists the values of the backend annotation keys,
+// read from the declarations of the ingtypes package.
+func backendAnnotationKeys(f *testing.F) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "../types/annotations.go", nil, 0)
+	if err != nil {
+		f.Fatalf("error parsing annotation keys: %v", err)
+	}
+	var keys []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || len(spec.Names) != 1 || len(spec.Values) != 1 || !strings.HasPrefix(spec.Names[0].Name, "Back") {
+			return true
+		}
+		if lit, ok := spec.Values[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			key, _ := strconv.Unquote(lit.Value)
+			// raw configuration snippets are not sanitized by design
+			if key != ingtypes.BackConfigBackend {
+				keys = append(keys, key)
+			}
+		}
+		return true
+	})
+	if len(keys) < 100 {
+		f.Fatalf("expected at least 100 backend annotation keys, found %d", len(keys))
+	}
+	return keys
+}
+
+// findUnsafeString walks the fields of a model object, returning the name of
+// the first string field that contains a char that could inject directives
+// in the haproxy configuration.
+func findUnsafeString(v reflect.Value, name string, visited map[uintptr]bool) string {
+	switch v.Kind() {
+	case reflect.String:
+		if _, found := unsafeValueChar(v.String()); found {
+			return name
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		if v.Kind() == reflect.Ptr {
+			if visited[v.Pointer()] {
+				return ""
+			}
+			visited[v.Pointer()] = true
+		}
+		return findUnsafeString(v.Elem(), name, visited)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				// internal state, not rendered by the templates
+				continue
+			}
+			if field := findUnsafeString(v.Field(i), name+"."+v.Type().Field(i).Name, visited); field != "" {
+				return field
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if field := findUnsafeString(v.Index(i), fmt.Sprintf("%s[%d]", name, i), visited); field != "" {
+				return field
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprintf("%s[%v]", name, iter.Key())
+			if field := findUnsafeString(iter.Key(), key, visited); field != "" {
+				return field
+			}
+			if field := findUnsafeString(iter.Value(), key, visited); field != "" {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+func FuzzBackendUnsafeValues(f *testing.F) {
+	keys := backendAnnotationKeys(f)
+	for _, value := range []string{
+		"value\nhttp-request deny",
+		"value\r\nhttp-request deny",
+		"value #comment",
+		"/app\x00",
+		"name:value\n\nuse_backend other",
+		"X-Header: value # http-request deny",
+		"cookie\tname",
+		"10.0.0.0/8\nhttp-request deny",
+		"30s\nhttp-request deny",
+		"http://auth.local/auth\nhttp-request deny",
+	} {
+		f.Add(value)
+	}
+	source := &Source{
+		Namespace: "default",
+		Name:      "ing1",
+		Type:      "ingress",
+	}
+	// features whose configuration is only read if enabled
+	base := map[string]string{
+		ingtypes.BackAffinity:              "cookie",
+		ingtypes.BackAuthSecret:            "basicpwd",
+		ingtypes.BackAuthExternalPlacement: "backend",
+		ingtypes.BackAuthURL:               "http://10.0.0.1:8080/auth",
+		ingtypes.BackBlueGreenDeploy:       "v=1=1,v=2=1",
+		ingtypes.BackCorsEnable:            "true",
+		ingtypes.BackHSTS:                  "true",
+		ingtypes.BackSecureBackends:        "true",
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		for _, key := range keys {
+			c := setup(t)
+			c.cache.SecretContent = conv_helper.SecretContent{"default/basicpwd": {"auth": []byte("usr1::clear1")}}
+			ann := make(map[string]string, len(base)+1)
+			for k, v := range base {
+				ann[k] = v
+			}
+			ann[key] = value
+			d := c.createBackendMappingData("default/app", source, map[string]string{}, map[string]map[string]string{"/": ann}, nil)
+			u := c.createUpdater()
+			u.options.HAProxyVersion = "2.8.0"
+			u.UpdateBackendConfig(d.backend, d.mapper)
+			if field := findUnsafeString(reflect.ValueOf(d.backend), "backend", map[uintptr]bool{}); field != "" {
+				t.Errorf("unsafe value of '%s' reached %s: %q", key, field, value)
+			}
+			c.logger.Logging = nil
+			c.teardown()
+		}
+	})
+}
@@ -19,11 +19,14 @@ package annotations
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
@@ -71,25 +74,62 @@ func (c *updater) buildGlobalAuthProxy(d *globalData) {
 	authproxy.RangeEnd, _ = strconv.Atoi(proxy[3])
 }
 
+func (c *updater) buildGlobalBackendNaming(d *globalData) {
+	naming := d.mapper.Get(ingtypes.GlobalBackendNaming)
+	backends := c.haproxy.Backends()
+	switch naming.Value {
+	case "", "namespace-name-port":
+		backends.Naming = hatypes.BackendNamingPort
+	case "namespace-name-portname":
+		backends.Naming = hatypes.BackendNamingPortName
+	case "hash":
+		backends.Naming = hatypes.BackendNamingHash
+	default:
+		c.logger.Warn("ignoring invalid backend naming '%s', using 'namespace-name-port' instead", naming.Value)
+		backends.Naming = hatypes.BackendNamingPort
+	}
+}
+
 func (c *updater) buildGlobalBind(d *globalData) {
 	d.global.Bind.AcceptProxy = d.mapper.Get(ingtypes.GlobalUseProxyProtocol).Bool()
+	d.global.Bind.IPv6 = d.mapper.Get(ingtypes.GlobalEnableIPv6).Bool()
 	d.global.Bind.TCPBindIP = d.mapper.Get(ingtypes.GlobalBindIPAddrTCP).Value
+	if d.global.Bind.IPv6 && !isIPv6Addr(d.global.Bind.TCPBindIP) {
+		d.global.Bind.TCPBindIPv6 = "::"
+	}
 	if bindHTTP := d.mapper.Get(ingtypes.GlobalBindHTTP).Value; bindHTTP != "" {
 		d.global.Bind.HTTPBind = bindHTTP
 	} else {
 		ip := d.mapper.Get(ingtypes.GlobalBindIPAddrHTTP).Value
 		port := d.mapper.Get(ingtypes.GlobalHTTPPort).Int()
-		d.global.Bind.HTTPBind = fmt.Sprintf("%s:%d", ip, port)
+		d.global.Bind.HTTPBind = bindAddr(ip, port, d.global.Bind.IPv6)
 	}
 	if bindHTTPS := d.mapper.Get(ingtypes.GlobalBindHTTPS).Value; bindHTTPS != "" {
 		d.global.Bind.HTTPSBind = bindHTTPS
 	} else {
 		ip := d.mapper.Get(ingtypes.GlobalBindIPAddrHTTP).Value
 		port := d.mapper.Get(ingtypes.GlobalHTTPSPort).Int()
-		d.global.Bind.HTTPSBind = fmt.Sprintf("%s:%d", ip, port)
+		d.global.Bind.HTTPSBind = bindAddr(ip, port, d.global.Bind.IPv6)
 	}
 }
 
+// bindAddr builds a haproxy's bind address list from ip and port. An IPv6
+// wildcard address in the same port is added if ipv6 is true and ip isn't
+// already an IPv6 address. The IPv6 socket is v6only, otherwise it would
+// also accept IPv4 connections and conflict with the IPv4 one, depending on
+// the net.ipv6.bindv6only sysctl. v6only is ignored by IPv4 sockets.
+func bindAddr(ip string, port int, ipv6 bool) string {
+	bind := fmt.Sprintf("%s:%d", ip, port)
+	if ipv6 && !isIPv6Addr(ip) {
+		bind += fmt.Sprintf(",:::%d v6only", port)
+	}
+	return bind
+}
+
+func isIPv6Addr(ip string) bool {
+	return strings.Contains(ip, ":")
+}
+
 func (c *updater) buildGlobalCloseSessions(d *globalData) {
 	durationCfg := d.mapper.Get(ingtypes.GlobalCloseSessionsDuration).Value
 	if durationCfg == "" {
@@ -104,7 +144,7 @@ func (c *updater) buildGlobalCloseSessions(d *globalData) {
 		c.logger.Warn("ignoring close-sessions-duration config: timeout-stop need to be configured")
 		return
 	}
-	timeout, err := time.ParseDuration(timeoutCfg)
+	timeout, err := utils.ParseDuration(timeoutCfg)
 	if err != nil {
 		c.logger.Warn("ignoring close-sessions-duration due to invalid timeout-stop config: %v", err)
 		return
@@ -118,7 +158,7 @@ func (c *updater) buildGlobalCloseSessions(d *globalData) {
 		}
 		duration = timeout * time.Duration(pct) / 100
 	} else {
-		duration, err = time.ParseDuration(durationCfg)
+		duration, err = utils.ParseDuration(durationCfg)
 		if err == nil {
 			if duration >= timeout {
 				err = fmt.Errorf("close-sessions-duration should be lower than timeout-stop")
@@ -222,6 +262,70 @@ func (c *updater) buildGlobalProc(d *globalData) {
 	d.global.Procs.CPUMap = cpumap
 }
 
+func (c *updater) buildGlobalQUIC(d *globalData) {
+	d.global.QUIC = hatypes.QUICConfig{}
+	if !d.mapper.Get(ingtypes.GlobalEnableQUIC).Bool() {
+		return
+	}
+	// QUIC listeners were introduced on haproxy 2.6, and a binary built without
+	// QUIC support refuses the quic4/quic6 binds, so the UDP bind is not added.
+	// An unknown version or feature list is assumed as supported.
+	if !utils.VersionAtLeast(c.options.HAProxyVersion, 2, 6) {
+		c.logger.Warn("ignoring '%s' config: haproxy %s does not support QUIC", ingtypes.GlobalEnableQUIC, c.options.HAProxyVersion)
+		return
+	}
+	if features := c.options.HAProxyFeatures; features != nil && !slices.Contains(features, "QUIC") {
+		c.logger.Warn("ignoring '%s' config: haproxy binary was built without QUIC support", ingtypes.GlobalEnableQUIC)
+		return
+	}
+	if tls13Disabled(d.mapper.Get(ingtypes.GlobalSSLOptions).Value) {
+		c.logger.Warn("ignoring '%s' config: QUIC needs TLS 1.3, which is disabled by '%s'", ingtypes.GlobalEnableQUIC, ingtypes.GlobalSSLOptions)
+		return
+	}
+	ip := d.mapper.Get(ingtypes.GlobalBindIPAddrHTTP).Value
+	port := d.mapper.Get(ingtypes.GlobalHTTPSPort).Int()
+	ipv6 := d.mapper.Get(ingtypes.GlobalEnableIPv6).Bool()
+	bind := fmt.Sprintf("quic4@%s:%d", ip, port)
+	if isIPv6Addr(ip) {
+		bind = fmt.Sprintf("quic6@%s:%d", ip, port)
+	} else if ipv6 {
+		bind += fmt.Sprintf(",quic6@:::%d v6only", port)
+	}
+	d.global.QUIC.Bind = bind
+	d.global.QUIC.Port = port
+}
+
+// tls13Disabled returns true if the ssl options, in the format of the bind
+// options, do not allow TLS 1.3 connections, which is mandatory on QUIC.
+func tls13Disabled(options string) bool {
+	opts := strings.Fields(options)
+	for i, opt := range opts {
+		switch opt {
+		case "no-tlsv13", "force-sslv3", "force-tlsv10", "force-tlsv11", "force-tlsv12":
+			return true
+		case "ssl-max-ver":
+			if i+1 < len(opts) && opts[i+1] != "TLSv1.3" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *updater) buildGlobalReloadDefer(d *globalData) {
+	sessions := d.mapper.Get(ingtypes.GlobalReloadDeferSessions).Int()
+	if sessions <= 0 {
+		return
+	}
+	maxWait, _ := TimeToDuration(c.validateTime(d.mapper.Get(ingtypes.GlobalReloadDeferMax)))
+	if maxWait <= 0 {
+		c.logger.Warn("ignoring reload-defer-sessions config: reload-defer-max need to be configured")
+		return
+	}
+	d.global.ReloadDefer.Sessions = sessions
+	d.global.ReloadDefer.MaxWait = maxWait
+}
+
 func (c *updater) buildGlobalStats(d *globalData) {
 	// healthz
 	d.global.Healthz.BindIP = d.mapper.Get(ingtypes.GlobalBindIPAddrHealthz).Value
@@ -267,11 +371,69 @@ func (c *updater) buildGlobalTimeout(d *globalData) {
 	d.global.Timeout.ServerFin = c.validateTime(d.mapper.Get(ingtypes.BackTimeoutServerFin))
 	d.global.Timeout.Stop = c.validateTime(d.mapper.Get(ingtypes.GlobalTimeoutStop))
 	d.global.Timeout.Tunnel = c.validateTime(d.mapper.Get(ingtypes.BackTimeoutTunnel))
-	if timeoutStop, err := time.ParseDuration(d.global.Timeout.Stop); err == nil {
+	if timeoutStop, ok := TimeToDuration(d.global.Timeout.Stop); ok {
 		d.global.TimeoutStopDuration = timeoutStop
 	}
 }
 
+func (c *updater) buildGlobalTrafficClasses(d *globalData) {
+	classes, err := ingutils.ParseTrafficClasses(d.mapper.Get(ingtypes.GlobalTrafficClasses).Value)
+	if err != nil {
+		c.logger.Error("ignoring traffic classes: %v", err)
+		return
+	}
+	d.global.TrafficClasses = classes
+}
+
+// uniqueIDFormat reads the log-format of the request unique ID. Coraza
+// crashes if the unique ID isn't set, so a random one is used if missing.
+func uniqueIDFormat(d *globalData) string {
+	format := d.mapper.Get(ingtypes.GlobalUniqueIDFormat).Value
+	if strings.ContainsAny(format, "\r\n") {
+		return ""
+	}
+	if format == "" && d.mapper.Get(ingtypes.GlobalModsecurityUseCoraza).Bool() {
+		format = "%[uuid()]"
+	}
+	return format
+}
+
+func (c *updater) buildGlobalUniqueID(d *globalData) {
+	if format := d.mapper.Get(ingtypes.GlobalUniqueIDFormat).Value; strings.ContainsAny(format, "\r\n") {
+		c.logger.Warn("ignoring '%s' on ConfigMap: line breaks are not allowed", ingtypes.GlobalUniqueIDFormat)
+	}
+	d.global.UniqueID.Format = uniqueIDFormat(d)
+	header := d.mapper.Get(ingtypes.GlobalUniqueIDResponseHeader).Value
+	if !headerNameRegex.MatchString(header) {
+		c.logger.Warn("ignoring invalid '%s' on ConfigMap: %s", ingtypes.GlobalUniqueIDResponseHeader, header)
+		header = ""
+	}
+	d.global.UniqueID.ResponseHeader = header
+}
+
+func (c *updater) buildGlobalSPOEAgents(d *globalData) {
+	agents, err := ingutils.ParseSPOEAgents(d.mapper.Get(ingtypes.GlobalSPOEAgents).Value)
+	if err != nil {
+		c.logger.Error("ignoring SPOE agents: %v", err)
+		agents = nil
+	}
+	// haproxy 3.1 moved SPOE agents to the spop mode. Only a known version
+	// changes the backend mode, an unknown one keeps the tcp mode used by
+	// the haproxy version shipped with the controller. modsecurity is a
+	// predefined agent, configured by the modsecurity-* keys and rendered
+	// in its own spoe-modsecurity.conf file.
+	version := c.options.HAProxyVersion
+	modeSPOP := version != "" && utils.VersionAtLeast(version, 3, 1)
+	for _, agent := range agents {
+		agent.ModeSPOP = modeSPOP
+	}
+	d.global.ModSecurity.ModeSPOP = modeSPOP
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].Name < agents[j].Name
+	})
+	d.global.SPOEAgents = agents
+}
+
 func (c *updater) buildSecurity(d *globalData) {
 	username := d.mapper.Get(ingtypes.GlobalUsername).Value
 	groupname := d.mapper.Get(ingtypes.GlobalGroupname).Value
@@ -317,6 +479,18 @@ func (c *updater) buildGlobalSSL(d *globalData) {
 	ssl.SSLRedirect = d.mapper.Get(ingtypes.BackSSLRedirect).Bool()
 }
 
+func (c *updater) buildGlobalHTTPProtocol(d *globalData) {
+	d.global.HTTPProtocol.RequireHostHeader = d.mapper.Get(ingtypes.HostRequireHostHeader).Bool()
+	exempt := d.mapper.Get(ingtypes.GlobalHTTPProtocolExemptPaths)
+	for _, path := range utils.Split(exempt.Value, ",") {
+		if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " '\"") {
+			c.logger.Warn("ignoring invalid path on %s config: %s", ingtypes.GlobalHTTPProtocolExemptPaths, path)
+			continue
+		}
+		d.global.HTTPProtocol.ExemptPaths = append(d.global.HTTPProtocol.ExemptPaths, path)
+	}
+}
+
 func (c *updater) buildGlobalHTTPStoHTTP(d *globalData) {
 	bind := d.mapper.Get(ingtypes.GlobalBindFrontingProxy).Value
 	if bind == "" {
@@ -327,7 +501,7 @@ func (c *updater) buildGlobalHTTPStoHTTP(d *globalData) {
 		if port == 0 {
 			return
 		}
-		bind = fmt.Sprintf("%s:%d", d.mapper.Get(ingtypes.GlobalBindIPAddrHTTP).Value, port)
+		bind = bindAddr(d.mapper.Get(ingtypes.GlobalBindIPAddrHTTP).Value, port, d.mapper.Get(ingtypes.GlobalEnableIPv6).Bool())
 	}
 	// TODO Change all `ToHTTP` naming to `FrontingProxy`
 	d.global.Bind.FrontingBind = bind
@@ -338,6 +512,17 @@ func (c *updater) buildGlobalHTTPStoHTTP(d *globalData) {
 	d.global.Bind.FrontingSockID = 10011
 }
 
+func (c *updater) buildGlobalLint(d *globalData) {
+	disabled := d.mapper.Get(ingtypes.GlobalLintDisabledRules)
+	for _, rule := range utils.Split(disabled.Value, ",") {
+		if !isLintRule(rule) {
+			c.logger.Warn("ignoring unknown lint rule on %s config: %s", ingtypes.GlobalLintDisabledRules, rule)
+			continue
+		}
+		d.global.LintDisabledRules = append(d.global.LintDisabledRules, rule)
+	}
+}
+
 func (c *updater) buildGlobalModSecurity(d *globalData) {
 	d.global.ModSecurity.Endpoints = utils.Split(d.mapper.Get(ingtypes.GlobalModsecurityEndpoints).Value, ",")
 	d.global.ModSecurity.Timeout.Connect = c.validateTime(d.mapper.Get(ingtypes.GlobalModsecurityTimeoutConnect))
@@ -396,13 +581,21 @@ func (c *updater) buildGlobalDNS(d *globalData) {
 
 func (c *updater) buildGlobalDynamic(d *globalData) {
 	// Secrets
+	// cross-namespace-secrets is used by the secret types not explicitly configured
 	staticSecrets := c.options.DynamicConfig.StaticCrossNamespaceSecrets
+	allSecrets := c.validateAllowDeny(d, ingtypes.GlobalCrossNamespaceSecrets)
+	allowSecret := func(key string) bool {
+		if d.mapper.Get(key).Value == "" {
+			return allSecrets
+		}
+		return c.validateAllowDeny(d, key)
+	}
 	c.options.DynamicConfig.CrossNamespaceSecretCA =
-		staticSecrets || c.validateAllowDeny(d, ingtypes.GlobalCrossNamespaceSecretsCA)
+		staticSecrets || allowSecret(ingtypes.GlobalCrossNamespaceSecretsCA)
 	c.options.DynamicConfig.CrossNamespaceSecretCertificate =
-		staticSecrets || c.validateAllowDeny(d, ingtypes.GlobalCrossNamespaceSecretsCrt)
+		staticSecrets || allowSecret(ingtypes.GlobalCrossNamespaceSecretsCrt)
 	c.options.DynamicConfig.CrossNamespaceSecretPasswd =
-		staticSecrets || c.validateAllowDeny(d, ingtypes.GlobalCrossNamespaceSecretsPasswd)
+		staticSecrets || allowSecret(ingtypes.GlobalCrossNamespaceSecretsPasswd)
 
 	// Services
 	c.options.DynamicConfig.CrossNamespaceServices =
@@ -563,9 +756,22 @@ var customHTTPResponses = []struct {
 	{"504", 504, "Gateway Timeout", ingtypes.GlobalHTTPResponse504, ""},
 }
 
+// isHAResponseCode checks if code is the name of one of the HAProxy based
+// responses, which can be customized via the http-response-<code> keys.
+func isHAResponseCode(code int) bool {
+	name := strconv.Itoa(code)
+	for _, data := range customHTTPResponses {
+		if data.def == "" && data.name == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *updater) buildGlobalCustomResponses(d *globalData) {
 	var haResponses []hatypes.HTTPResponse
 	var luaResponses []hatypes.HTTPResponse
+	hasUniqueID := uniqueIDFormat(d) != ""
 	for _, data := range customHTTPResponses {
 		var response *hatypes.HTTPResponse
 		var err error
@@ -573,6 +779,23 @@ func (c *updater) buildGlobalCustomResponses(d *globalData) {
 			response, err = parseHeadAndBody(content)
 			if err != nil {
 				c.logger.Warn("ignoring '%s' due to a malformed response: %v", data.key, err)
+			} else if hasUniqueIDPlaceholder(response) {
+				var stripReason string
+				if !hasUniqueID {
+					stripReason = "unique-id-format is not configured"
+				} else if data.def == "" && response.StatusCode != 0 && response.StatusCode != data.code {
+					// HAProxy based responses with the unique ID are rendered as http-error,
+					// whose status code is the one that the response overrides
+					stripReason = fmt.Sprintf("status code should be %d", data.code)
+				}
+				if stripReason != "" {
+					c.logger.Warn("removing unique ID placeholder from '%s': %s", data.key, stripReason)
+					response, err = parseHeadAndBody(strings.ReplaceAll(content, hatypes.UniqueIDPlaceholder, ""))
+				} else if data.def == "" {
+					response.LogFormat = buildResponseLogFormat(response)
+				} else {
+					response.UniqueIDBody = true
+				}
 			}
 		}
 		if data.def != "" {
@@ -609,6 +832,44 @@ func (c *updater) buildGlobalCustomResponses(d *globalData) {
 	d.global.CustomHTTPLuaResponses = luaResponses
 }
 
+func hasUniqueIDPlaceholder(response *hatypes.HTTPResponse) bool {
+	for _, line := range response.Body {
+		if strings.Contains(line, hatypes.UniqueIDPlaceholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// logFormatEscape escapes a string to be used in a double quoted log-format
+// string. The unique ID placeholder is the only evaluated expression, its
+// pair should be the first one so it takes precedence over the percent sign.
+var logFormatEscape = strings.NewReplacer(
+	hatypes.UniqueIDPlaceholder, hatypes.UniqueIDSafeFormat,
+	`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", "", "%", "%%",
+)
+
+func buildResponseLogFormat(response *hatypes.HTTPResponse) *hatypes.HTTPResponseLogFormat {
+	logFormat := &hatypes.HTTPResponseLogFormat{
+		ContentType: "text/plain",
+		Body:        logFormatEscape.Replace(strings.Join(response.Body, "\n") + "\n"),
+	}
+	for _, header := range response.Headers {
+		switch strings.ToLower(header.Name) {
+		case "content-length":
+			// computed by haproxy
+		case "content-type":
+			logFormat.ContentType = header.Value
+		default:
+			logFormat.Headers = append(logFormat.Headers, hatypes.HTTPHeader{
+				Name:  header.Name,
+				Value: logFormatEscape.Replace(header.Value),
+			})
+		}
+	}
+	return logFormat
+}
+
 var statusCodeRegex = regexp.MustCompile(`^([0-9]{3})( [A-Za-z ]+)?$`)
 
 func parseHeadAndBody(content string) (*hatypes.HTTPResponse, error) {
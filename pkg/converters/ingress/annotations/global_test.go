@@ -83,6 +83,52 @@ func TestAuthProxy(t *testing.T) {
 	}
 }
 
+func TestBackendNaming(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected hatypes.BackendNaming
+		logging  string
+	}{
+		// 0
+		{
+			input:    "",
+			expected: hatypes.BackendNamingPort,
+		},
+		// 1
+		{
+			input:    "namespace-name-port",
+			expected: hatypes.BackendNamingPort,
+		},
+		// 2
+		{
+			input:    "namespace-name-portname",
+			expected: hatypes.BackendNamingPortName,
+		},
+		// 3
+		{
+			input:    "hash",
+			expected: hatypes.BackendNamingHash,
+		},
+		// 4
+		{
+			input:    "name",
+			expected: hatypes.BackendNamingPort,
+			logging:  `WARN ignoring invalid backend naming 'name', using 'namespace-name-port' instead`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{
+			ingtypes.GlobalBackendNaming: test.input,
+		})
+		c.haproxy.Backends().Naming = hatypes.BackendNamingHash
+		c.createUpdater().buildGlobalBackendNaming(d)
+		c.compareObjects("backend naming", i, c.haproxy.Backends().Naming, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestBind(t *testing.T) {
 	testCases := []struct {
 		ann      map[string]string
@@ -137,6 +183,60 @@ func TestBind(t *testing.T) {
 				HTTPSBind: "*:8443",
 			},
 		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableIPv6: "true",
+			},
+			expected: hatypes.GlobalBindConfig{
+				HTTPBind:    "*:80,:::80 v6only",
+				HTTPSBind:   "*:443,:::443 v6only",
+				TCPBindIPv6: "::",
+				IPv6:        true,
+			},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableIPv6:     "true",
+				ingtypes.GlobalBindIPAddrHTTP: "10.0.0.1",
+				ingtypes.GlobalBindIPAddrTCP:  "10.0.0.2",
+			},
+			expected: hatypes.GlobalBindConfig{
+				HTTPBind:    "10.0.0.1:80,:::80 v6only",
+				HTTPSBind:   "10.0.0.1:443,:::443 v6only",
+				TCPBindIP:   "10.0.0.2",
+				TCPBindIPv6: "::",
+				IPv6:        true,
+			},
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableIPv6:     "true",
+				ingtypes.GlobalBindIPAddrHTTP: "fd00::1",
+				ingtypes.GlobalBindIPAddrTCP:  "::",
+			},
+			expected: hatypes.GlobalBindConfig{
+				HTTPBind:  "fd00::1:80",
+				HTTPSBind: "fd00::1:443",
+				TCPBindIP: "::",
+				IPv6:      true,
+			},
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableIPv6: "true",
+				ingtypes.GlobalBindHTTP:   ":80",
+			},
+			expected: hatypes.GlobalBindConfig{
+				HTTPBind:    ":80",
+				HTTPSBind:   "*:443,:::443 v6only",
+				TCPBindIPv6: "::",
+				IPv6:        true,
+			},
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -152,6 +252,116 @@ func TestBind(t *testing.T) {
 	}
 }
 
+func TestQUIC(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		version  string
+		features []string
+		expected hatypes.QUICConfig
+		logging  string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.QUICConfig{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+			},
+			expected: hatypes.QUICConfig{Bind: "quic4@*:443", Port: 443},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+				ingtypes.GlobalEnableIPv6: "true",
+				ingtypes.GlobalHTTPSPort:  "8443",
+			},
+			expected: hatypes.QUICConfig{Bind: "quic4@*:8443,quic6@:::8443 v6only", Port: 8443},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC:     "true",
+				ingtypes.GlobalEnableIPv6:     "true",
+				ingtypes.GlobalBindIPAddrHTTP: "fd00::1",
+			},
+			expected: hatypes.QUICConfig{Bind: "quic6@fd00::1:443", Port: 443},
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+			},
+			version:  "2.6.17",
+			features: []string{"OPENSSL", "QUIC"},
+			expected: hatypes.QUICConfig{Bind: "quic4@*:443", Port: 443},
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+			},
+			version:  "2.4.22",
+			expected: hatypes.QUICConfig{},
+			logging:  `WARN ignoring 'enable-quic' config: haproxy 2.4.22 does not support QUIC`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+			},
+			version:  "2.8.5",
+			features: []string{"OPENSSL"},
+			expected: hatypes.QUICConfig{},
+			logging:  `WARN ignoring 'enable-quic' config: haproxy binary was built without QUIC support`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+				ingtypes.GlobalSSLOptions: "no-sslv3 no-tlsv13",
+			},
+			expected: hatypes.QUICConfig{},
+			logging:  `WARN ignoring 'enable-quic' config: QUIC needs TLS 1.3, which is disabled by 'ssl-options'`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+				ingtypes.GlobalSSLOptions: "ssl-min-ver TLSv1.2 ssl-max-ver TLSv1.2",
+			},
+			expected: hatypes.QUICConfig{},
+			logging:  `WARN ignoring 'enable-quic' config: QUIC needs TLS 1.3, which is disabled by 'ssl-options'`,
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.GlobalEnableQUIC: "true",
+				ingtypes.GlobalSSLOptions: "ssl-min-ver TLSv1.2 ssl-max-ver TLSv1.3",
+			},
+			expected: hatypes.QUICConfig{Bind: "quic4@*:443", Port: 443},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{
+			ingtypes.GlobalHTTPSPort:      "443",
+			ingtypes.GlobalBindIPAddrHTTP: "*",
+		})
+		d.mapper.AddAnnotations(nil, hatypes.CreateHostPathLink("-", "-", hatypes.MatchBegin), test.ann)
+		u := c.createUpdater()
+		u.options.HAProxyVersion = test.version
+		u.options.HAProxyFeatures = test.features
+		u.buildGlobalQUIC(d)
+		c.compareObjects("quic", i, d.global.QUIC, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestCloseSessions(t *testing.T) {
 	testCases := []struct {
 		annDuration string
@@ -178,7 +388,7 @@ func TestCloseSessions(t *testing.T) {
 		{
 			annDuration: "10m",
 			annStop:     "10%",
-			logging:     `WARN ignoring close-sessions-duration due to invalid timeout-stop config: time: unknown unit "%" in duration "10%"`,
+			logging:     `WARN ignoring close-sessions-duration due to invalid timeout-stop config: invalid duration "10%"`,
 		},
 		// 4
 		{
@@ -196,7 +406,7 @@ func TestCloseSessions(t *testing.T) {
 		{
 			annDuration: "10x",
 			annStop:     "10m",
-			logging:     `WARN ignoring invalid close-sessions-duration config: time: unknown unit "x" in duration "10x"`,
+			logging:     `WARN ignoring invalid close-sessions-duration config: unknown unit "x" in duration "10x"`,
 		},
 		// 7
 		{
@@ -234,6 +444,58 @@ func TestCloseSessions(t *testing.T) {
 	}
 }
 
+func TestReloadDefer(t *testing.T) {
+	testCases := []struct {
+		sessions string
+		maxWait  string
+		expected hatypes.ReloadDeferConfig
+		logging  string
+	}{
+		// 0
+		{
+			sessions: "0",
+			maxWait:  "5m",
+		},
+		// 1
+		{
+			sessions: "10",
+			maxWait:  "",
+			logging:  `WARN ignoring reload-defer-sessions config: reload-defer-max need to be configured`,
+		},
+		// 2
+		{
+			sessions: "10",
+			maxWait:  "5x",
+			logging: `
+WARN ignoring invalid time format on global/default config: 5x
+WARN ignoring reload-defer-sessions config: reload-defer-max need to be configured`,
+		},
+		// 3
+		{
+			sessions: "10",
+			maxWait:  "5m",
+			expected: hatypes.ReloadDeferConfig{Sessions: 10, MaxWait: 5 * time.Minute},
+		},
+		// 4
+		{
+			sessions: "1",
+			maxWait:  "1d",
+			expected: hatypes.ReloadDeferConfig{Sessions: 1, MaxWait: 24 * time.Hour},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{
+			ingtypes.GlobalReloadDeferMax:      test.maxWait,
+			ingtypes.GlobalReloadDeferSessions: test.sessions,
+		})
+		c.createUpdater().buildGlobalReloadDefer(d)
+		c.compareObjects("reload defer", i, d.global.ReloadDefer, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestCustomConfigProxy(t *testing.T) {
 	testCases := []struct {
 		config   string
@@ -338,6 +600,161 @@ func TestCustomConfigFrontendLate(t *testing.T) {
 	}
 }
 
+func TestUniqueID(t *testing.T) {
+	testCases := []struct {
+		config   map[string]string
+		expected hatypes.UniqueIDConfig
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			config: map[string]string{
+				ingtypes.GlobalUniqueIDFormat:         "%{+X}o%ci:%cp",
+				ingtypes.GlobalUniqueIDResponseHeader: "X-Request-ID",
+			},
+			expected: hatypes.UniqueIDConfig{Format: "%{+X}o%ci:%cp", ResponseHeader: "X-Request-ID"},
+		},
+		// 2
+		{
+			config: map[string]string{
+				ingtypes.GlobalModsecurityUseCoraza:   "true",
+				ingtypes.GlobalUniqueIDResponseHeader: "X-Request-ID",
+			},
+			expected: hatypes.UniqueIDConfig{Format: "%[uuid()]", ResponseHeader: "X-Request-ID"},
+		},
+		// 3
+		{
+			config: map[string]string{
+				ingtypes.GlobalModsecurityUseCoraza: "true",
+				ingtypes.GlobalUniqueIDFormat:       "%[req.hdr(x-id)]",
+			},
+			expected: hatypes.UniqueIDConfig{Format: "%[req.hdr(x-id)]"},
+		},
+		// 4
+		{
+			config: map[string]string{
+				ingtypes.GlobalUniqueIDFormat: "%[uuid()]\nhttp-request deny",
+			},
+			logging: `WARN ignoring 'unique-id-format' on ConfigMap: line breaks are not allowed`,
+		},
+		// 5
+		{
+			config: map[string]string{
+				ingtypes.GlobalUniqueIDFormat:         "%[uuid()]",
+				ingtypes.GlobalUniqueIDResponseHeader: "X-Request-ID: 1",
+			},
+			expected: hatypes.UniqueIDConfig{Format: "%[uuid()]"},
+			logging:  `WARN ignoring invalid 'unique-id-response-header' on ConfigMap: X-Request-ID: 1`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.config)
+		c.createUpdater().buildGlobalUniqueID(d)
+		c.compareObjects("unique id", i, d.global.UniqueID, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestSPOEAgents(t *testing.T) {
+	timeout := hatypes.SPOETimeoutConfig{Connect: "5s", Server: "5s", Hello: "100ms", Idle: "30s", Processing: "1s"}
+	testCases := []struct {
+		ann        map[string]string
+		version    string
+		expected   []*hatypes.SPOEAgent
+		modsecSPOP bool
+		logging    string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSPOEAgents: "risk endpoints=10.0.0.10:9000 messages=score args=src,path",
+			},
+			expected: []*hatypes.SPOEAgent{
+				{Name: "risk", Endpoints: []string{"10.0.0.10:9000"}, Messages: []string{"score"}, Args: []string{"src", "path"}, VarPrefix: "risk", Timeout: timeout},
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSPOEAgents:           "risk endpoints=10.0.0.10:9000 messages=score",
+				ingtypes.GlobalModsecurityEndpoints: "10.0.0.20:12345",
+			},
+			expected: []*hatypes.SPOEAgent{
+				{Name: "risk", Endpoints: []string{"10.0.0.10:9000"}, Messages: []string{"score"}, VarPrefix: "risk", Timeout: timeout},
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalModsecurityEndpoints: "10.0.0.20:12345",
+			},
+			version:    "3.1.2",
+			modsecSPOP: true,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSPOEAgents: "risk endpoints=10.0.0.10:9000 messages=score",
+			},
+			version: "3.1.2",
+			expected: []*hatypes.SPOEAgent{
+				{Name: "risk", Endpoints: []string{"10.0.0.10:9000"}, Messages: []string{"score"}, VarPrefix: "risk", Timeout: timeout, ModeSPOP: true},
+			},
+			modsecSPOP: true,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSPOEAgents: "risk endpoints=10.0.0.10:9000 messages=score",
+			},
+			version: "2.6.17",
+			expected: []*hatypes.SPOEAgent{
+				{Name: "risk", Endpoints: []string{"10.0.0.10:9000"}, Messages: []string{"score"}, VarPrefix: "risk", Timeout: timeout},
+			},
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.GlobalSPOEAgents:           "risk endpoints=10.0.0.10 messages=score",
+				ingtypes.GlobalModsecurityEndpoints: "10.0.0.20:12345",
+			},
+			logging: "ERROR ignoring SPOE agents: invalid option on SPOE agent 'risk': invalid endpoint, expected <host>:<port>: 10.0.0.10",
+		},
+	}
+	defaults := map[string]string{
+		ingtypes.GlobalModsecurityTimeoutConnect:    "5s",
+		ingtypes.GlobalModsecurityTimeoutHello:      "100ms",
+		ingtypes.GlobalModsecurityTimeoutIdle:       "30s",
+		ingtypes.GlobalModsecurityTimeoutProcessing: "1s",
+		ingtypes.GlobalModsecurityTimeoutServer:     "5s",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		ann := map[string]string{}
+		for key, value := range defaults {
+			ann[key] = value
+		}
+		for key, value := range test.ann {
+			ann[key] = value
+		}
+		d := c.createGlobalData(ann)
+		u := c.createUpdater()
+		u.options.HAProxyVersion = test.version
+		u.buildGlobalModSecurity(d)
+		u.buildGlobalSPOEAgents(d)
+		c.compareObjects("SPOE agents", i, d.global.SPOEAgents, test.expected)
+		c.compareObjects("modsecurity spop mode", i, d.global.ModSecurity.ModeSPOP, test.modsecSPOP)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestModSecurity(t *testing.T) {
 	testCases := []struct {
 		endpoints string
@@ -536,6 +953,38 @@ WARN ignoring invalid value 'fail' on global 'cross-namespace-secrets-crt', usin
 				StaticCrossNamespaceSecrets:     true,
 			},
 		},
+		// 5
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceSecrets: "allow",
+			},
+			expected: convtypes.DynamicConfig{
+				CrossNamespaceSecretCA:          true,
+				CrossNamespaceSecretCertificate: true,
+				CrossNamespaceSecretPasswd:      true,
+			},
+		},
+		// 6
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceSecrets:       "allow",
+				ingtypes.GlobalCrossNamespaceSecretsPasswd: "deny",
+			},
+			expected: convtypes.DynamicConfig{
+				CrossNamespaceSecretCA:          true,
+				CrossNamespaceSecretCertificate: true,
+			},
+		},
+		// 7
+		{
+			config: map[string]string{
+				ingtypes.GlobalCrossNamespaceSecrets:   "deny",
+				ingtypes.GlobalCrossNamespaceSecretsCA: "allow",
+			},
+			expected: convtypes.DynamicConfig{
+				CrossNamespaceSecretCA: true,
+			},
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
@@ -551,6 +1000,158 @@ WARN ignoring invalid value 'fail' on global 'cross-namespace-secrets-crt', usin
 	}
 }
 
+func TestValidateGlobalConfig(t *testing.T) {
+	testCases := []struct {
+		applied  map[string]string
+		config   map[string]string
+		expected map[string]string
+		status   GlobalConfigStatus
+		logging  string
+	}{
+		// 0
+		{
+			config: map[string]string{
+				ingtypes.GlobalMaxConnections: "2000",
+				ingtypes.GlobalNbthread:       "4",
+				ingtypes.GlobalTimeoutClient:  "",
+			},
+			expected: map[string]string{
+				ingtypes.GlobalMaxConnections: "2000",
+				ingtypes.GlobalNbthread:       "4",
+				ingtypes.GlobalTimeoutClient:  "",
+			},
+			status: GlobalConfigStatus{
+				Applied: []string{"max-connections", "nbthread", "timeout-client"},
+			},
+		},
+		// 1
+		{
+			config: map[string]string{
+				ingtypes.GlobalMaxConnections: "many",
+				ingtypes.GlobalTimeoutClient:  "10x",
+			},
+			expected: map[string]string{},
+			status: GlobalConfigStatus{
+				Rejected: []string{"max-connections", "timeout-client"},
+			},
+			logging: `
+ERROR ignoring invalid value of global config key 'max-connections': many, using the default value
+ERROR ignoring invalid value of global config key 'timeout-client': 10x, using the default value
+`,
+		},
+		// 2
+		{
+			applied: map[string]string{
+				ingtypes.GlobalMaxConnections: "2000",
+				ingtypes.GlobalStrictHost:     "false",
+				ingtypes.GlobalTimeoutClient:  "50s",
+			},
+			config: map[string]string{
+				ingtypes.GlobalMaxConnections: "3000",
+				ingtypes.GlobalStrictHost:     "yes",
+				ingtypes.GlobalTimeoutClient:  "50s",
+			},
+			expected: map[string]string{
+				ingtypes.GlobalMaxConnections: "3000",
+				ingtypes.GlobalStrictHost:     "false",
+				ingtypes.GlobalTimeoutClient:  "50s",
+			},
+			status: GlobalConfigStatus{
+				Applied:  []string{"max-connections"},
+				Rejected: []string{"strict-host"},
+			},
+			logging: `
+ERROR ignoring invalid value of global config key 'strict-host': yes, keeping the previous value 'false'
+`,
+		},
+		// 3
+		{
+			applied: map[string]string{
+				ingtypes.GlobalCPUMap:        "auto 1/1-4 0-3",
+				ingtypes.GlobalNbthread:      "2",
+				ingtypes.GlobalTimeoutClient: "50s",
+			},
+			config: map[string]string{
+				ingtypes.GlobalNbthread:      "4",
+				ingtypes.GlobalTimeoutClient: "1m",
+			},
+			expected: map[string]string{
+				ingtypes.GlobalCPUMap:        "auto 1/1-4 0-3",
+				ingtypes.GlobalNbthread:      "2",
+				ingtypes.GlobalTimeoutClient: "1m",
+			},
+			status: GlobalConfigStatus{
+				Applied: []string{"timeout-client"},
+				Pending: []string{"cpu-map", "nbthread"},
+			},
+			logging: `
+WARN global config key 'nbthread' was changed and will be applied after the controller restarts
+WARN global config key 'cpu-map' was removed and will be applied after the controller restarts
+`,
+		},
+		// 4
+		{
+			applied: map[string]string{
+				ingtypes.GlobalMaxConnections: "2000",
+				ingtypes.GlobalNbthread:       "2",
+				ingtypes.GlobalSyslogLength:   "1024",
+				ingtypes.GlobalTimeoutStop:    "10s",
+			},
+			config: map[string]string{
+				ingtypes.GlobalMaxConnections: "3000",
+				ingtypes.GlobalNbthread:       "two",
+				ingtypes.GlobalSyslogLength:   "1k",
+				ingtypes.GlobalTimeoutClient:  "1m",
+			},
+			expected: map[string]string{
+				ingtypes.GlobalMaxConnections: "3000",
+				ingtypes.GlobalNbthread:       "2",
+				ingtypes.GlobalSyslogLength:   "1024",
+				ingtypes.GlobalTimeoutClient:  "1m",
+			},
+			status: GlobalConfigStatus{
+				Applied:  []string{"max-connections", "timeout-client", "timeout-stop"},
+				Rejected: []string{"syslog-length"},
+				Pending:  []string{"nbthread"},
+			},
+			logging: `
+WARN global config key 'nbthread' was changed and will be applied after the controller restarts
+ERROR ignoring invalid value of global config key 'syslog-length': 1k, keeping the previous value '1024'
+`,
+		},
+		// 5
+		{
+			applied: map[string]string{
+				ingtypes.GlobalTimeoutClient: "1m",
+			},
+			config: map[string]string{
+				ingtypes.GlobalTimeoutClient:    "60s",
+				ingtypes.GlobalTimeoutClientFin: "10000",
+				ingtypes.GlobalTimeoutStop:      "1m30s",
+			},
+			expected: map[string]string{
+				ingtypes.GlobalTimeoutClient: "60s",
+				ingtypes.GlobalTimeoutStop:   "90s",
+			},
+			status: GlobalConfigStatus{
+				Applied:  []string{"timeout-client", "timeout-stop"},
+				Rejected: []string{"timeout-client-fin"},
+			},
+			logging: `
+ERROR ignoring invalid value of global config key 'timeout-client-fin': 10000, using the default value
+`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		config, status := ValidateGlobalConfig(c.logger, test.applied, test.config)
+		c.compareObjects("config", i, config, test.expected)
+		c.compareObjects("status", i, *status, test.status)
+		c.logger.CompareLoggingID(fmt.Sprint(i), test.logging)
+		c.teardown()
+	}
+}
+
 func TestForwardFor(t *testing.T) {
 	testCases := []struct {
 		ffconf  string
@@ -692,6 +1293,58 @@ func TestFrontingProxy(t *testing.T) {
 	}
 }
 
+func TestHTTPProtocol(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		expected hatypes.HTTPProtocolConfig
+		logging  string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.HTTPProtocolConfig{},
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.HostRequireHostHeader: "true",
+			},
+			expected: hatypes.HTTPProtocolConfig{
+				RequireHostHeader: true,
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.GlobalHTTPProtocolExemptPaths: "/healthz, /ping",
+			},
+			expected: hatypes.HTTPProtocolConfig{
+				ExemptPaths: []string{"/healthz", "/ping"},
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.GlobalHTTPProtocolExemptPaths: "/healthz,ping,/a'b",
+			},
+			expected: hatypes.HTTPProtocolConfig{
+				ExemptPaths: []string{"/healthz"},
+			},
+			logging: `
+WARN ignoring invalid path on http-protocol-exempt-paths config: ping
+WARN ignoring invalid path on http-protocol-exempt-paths config: /a'b`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(test.ann)
+		c.createUpdater().buildGlobalHTTPProtocol(d)
+		c.compareObjects("http protocol", i, d.global.HTTPProtocol, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestDisableCpuMap(t *testing.T) {
 	testCases := []struct {
 		ann      map[string]string
@@ -1077,6 +1730,76 @@ body`,
 403 403 'Forbidden'
 [{Content-Length 5}]
 body
+`,
+		},
+		// 15
+		{
+			config: map[string]string{
+				ingtypes.GlobalHTTPResponse403: `403
+Content-Type: text/html
+
+<p>Request ID: %[unique-id]</p>`,
+			},
+			expected: `
+---
+403 403 'Forbidden'
+[{Content-Length 20} {Content-Type text/html}]
+<p>Request ID: </p>
+`,
+			logging: `WARN removing unique ID placeholder from 'http-response-403': unique-id-format is not configured`,
+		},
+		// 16
+		{
+			config: map[string]string{
+				ingtypes.GlobalUniqueIDFormat: "%[uuid()]",
+				ingtypes.GlobalHTTPResponse403: `403
+Content-Type: text/html
+X-Info: 100% $blocked
+
+<p>Request ID: %[unique-id]</p>
+<p>Cost: $10 \ 50%</p>`,
+			},
+			expected: `
+---
+403 403 'Forbidden'
+[{Content-Length 55} {Content-Type text/html} {X-Info 100% $blocked}]
+<p>Request ID: %[unique-id]</p>
+<p>Cost: $10 \ 50%</p>
+log-format: text/html
+<p>Request ID: %[unique-id,bytes(0,64),url_enc]</p>\n<p>Cost: \$10 \\ 50%%</p>\n
+[{X-Info 100%% \$blocked}]
+`,
+		},
+		// 17
+		{
+			config: map[string]string{
+				ingtypes.GlobalModsecurityUseCoraza: "true",
+				ingtypes.GlobalHTTPResponse429: `503 Try Again
+
+id=%[unique-id]`,
+			},
+			expected: `
+---
+429 503 'Try Again'
+[{Content-Length 4}]
+id=
+`,
+			logging: `WARN removing unique ID placeholder from 'http-response-429': status code should be 429`,
+		},
+		// 18
+		{
+			config: map[string]string{
+				ingtypes.GlobalUniqueIDFormat: "%[uuid()]",
+				ingtypes.GlobalHTTPResponse404: `404
+
+id=%[unique-id]`,
+			},
+			expected: `
+---
+send-404 404 'Not Found'
+[{Content-Length 16}]
+id=%[unique-id]
+unique-id-body
 `,
 		},
 	}
@@ -1102,6 +1825,13 @@ body
 			for _, l := range response.Body {
 				actual += l + "\n"
 			}
+			if response.LogFormat != nil {
+				actual += fmt.Sprintf("log-format: %s\n%s\n%v\n",
+					response.LogFormat.ContentType, response.LogFormat.Body, response.LogFormat.Headers)
+			}
+			if response.UniqueIDBody {
+				actual += "unique-id-body\n"
+			}
 		}
 		c.compareText("custom responses", i, actual, test.expected)
 		c.logger.CompareLogging(test.logging)
@@ -0,0 +1,178 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+// Hostname is the hostname used by the paths created by the builders.
+const Hostname = "host.local"
+
+// Config has the fake logger, cache, metrics and haproxy model used to test
+// annotation builders. Logger captures logging, compare it with
+// Logger.CompareLogging(). Cache can be configured via its fields, eg
+// SecretContent and PodList, and records events in Cache.Events.
+type Config struct {
+	T       *testing.T
+	Haproxy haproxy.Config
+	Cache   *conv_helper.CacheMock
+	Tracker convtypes.Tracker
+	Logger  *types_helper.LoggerMock
+	Metrics *types_helper.MetricsMock
+}
+
+// Setup ...
+func Setup(t *testing.T) *Config {
+	logger := &types_helper.LoggerMock{T: t}
+	tracker := tracker.NewTracker()
+	return &Config{
+		T:       t,
+		Haproxy: haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config(),
+		Cache:   conv_helper.NewCacheMock(tracker),
+		Tracker: tracker,
+		Logger:  logger,
+		Metrics: types_helper.NewMetricsMock(),
+	}
+}
+
+// Teardown fails the test if there is logging that wasn't compared.
+func (c *Config) Teardown() {
+	c.Logger.CompareLogging("")
+}
+
+// ConverterOptions returns converter options using the fakes of this config.
+func (c *Config) ConverterOptions() *convtypes.ConverterOptions {
+	return &convtypes.ConverterOptions{
+		Logger:        c.Logger,
+		Cache:         c.Cache,
+		Tracker:       c.Tracker,
+		Metrics:       c.Metrics,
+		DynamicConfig: &convtypes.DynamicConfig{},
+		DefaultConfig: ingress.CreateDefaults,
+	}
+}
+
+// CreateUpdater ...
+func (c *Config) CreateUpdater() annotations.Updater {
+	return annotations.NewUpdater(c.Haproxy, c.ConverterOptions())
+}
+
+// CreateSource creates the source of an annotation, fullName should be in
+// the namespace/name format.
+func CreateSource(resourceType convtypes.ResourceType, fullName string) *annotations.Source {
+	namespace, name := splitFullName(fullName)
+	return &annotations.Source{
+		Namespace: namespace,
+		Name:      name,
+		Type:      resourceType,
+	}
+}
+
+// CreateBackendData creates a backend of the svcFullName service, and a mapper
+// with ann annotations added from source to the root path of Hostname, and
+// annDefault as the default values. Use Defaults() as annDefault to test a
+// builder against the whole updater.
+func (c *Config) CreateBackendData(svcFullName string, source *annotations.Source, ann, annDefault map[string]string) (*hatypes.Backend, *annotations.Mapper) {
+	mapper := annotations.NewMapBuilder(c.Logger, nil, annDefault).NewMapper()
+	mapper.AddAnnotations(source, hatypes.CreateHostPathLink(Hostname, "/", hatypes.MatchBegin), ann)
+	namespace, name := splitFullName(svcFullName)
+	return &hatypes.Backend{
+		ID:        fmt.Sprintf("%s_%s_%d", namespace, name, 8080),
+		Namespace: namespace,
+		Name:      name,
+	}, mapper
+}
+
+// CreateBackendMappingData creates a backend with one path for every key of
+// urlAnnValue and every item of addPaths, and a mapper with the annotations of
+// every path of urlAnnValue added from source. Paths use Hostname as their
+// hostname.
+func (c *Config) CreateBackendMappingData(
+	svcFullName string,
+	source *annotations.Source,
+	annDefault map[string]string,
+	urlAnnValue map[string]map[string]string,
+	addPaths []string,
+) (*hatypes.Backend, *annotations.Mapper) {
+	backend, mapper := c.CreateBackendData(svcFullName, source, map[string]string{}, annDefault)
+	paths := make(map[string]struct{}, len(urlAnnValue)+len(addPaths))
+	for path := range urlAnnValue {
+		paths[path] = struct{}{}
+	}
+	for _, path := range addPaths {
+		paths[path] = struct{}{}
+	}
+	for path := range paths {
+		b := backend.AddBackendPath(hatypes.CreateHostPathLink(Hostname, path, hatypes.MatchBegin))
+		b.Host = &hostResolver{}
+	}
+	for uri, ann := range urlAnnValue {
+		mapper.AddAnnotations(source, hatypes.CreateHostPathLink(Hostname, uri, hatypes.MatchBegin), ann)
+	}
+	return backend, mapper
+}
+
+// Defaults returns the default value of the configuration keys, as used by
+// the converter.
+func Defaults() map[string]string {
+	return ingress.CreateDefaults()
+}
+
+// AddEndpoints adds one enabled endpoint with weight 1 for every IP, listening
+// on port.
+func AddEndpoints(backend *hatypes.Backend, port int, ips ...string) []*hatypes.Endpoint {
+	endpoints := make([]*hatypes.Endpoint, len(ips))
+	for i, ip := range ips {
+		ep := backend.AcquireEndpoint(ip, port, "")
+		ep.Weight = 1
+		endpoints[i] = ep
+	}
+	return endpoints
+}
+
+type hostResolver struct{}
+
+func (h *hostResolver) UseTLS() bool {
+	return true
+}
+
+func (h *hostResolver) HTTPOnly() bool {
+	return false
+}
+
+func (h *hostResolver) SSLRedirect() (redirect, found bool) {
+	return false, false
+}
+
+func splitFullName(fullName string) (namespace, name string) {
+	if i := strings.Index(fullName, "/"); i >= 0 {
+		return fullName[:i], fullName[i+1:]
+	}
+	return "", fullName
+}
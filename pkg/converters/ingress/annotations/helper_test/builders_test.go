@@ -0,0 +1,75 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper_test
+
+import (
+	"reflect"
+	"testing"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+)
+
+func TestCreateBackendMappingData(t *testing.T) {
+	testCases := []struct {
+		annPaths map[string]map[string]string
+		addPaths []string
+		expected map[string][]string
+		logging  string
+	}{
+		// 0
+		{
+			addPaths: []string{"/"},
+			expected: map[string][]string{"/": nil},
+		},
+		// 1
+		{
+			annPaths: map[string]map[string]string{
+				"/app": {ingtypes.BackAllowlistSourceRange: "10.0.0.0/8"},
+			},
+			addPaths: []string{"/"},
+			expected: map[string][]string{"/": nil, "/app": {"10.0.0.0/8"}},
+		},
+		// 2
+		{
+			annPaths: map[string]map[string]string{
+				"/": {ingtypes.BackAllowlistSourceRange: "10.0.0.0/8,10.0.0.0/64"},
+			},
+			expected: map[string][]string{"/": {"10.0.0.0/8"}},
+			logging:  `WARN skipping invalid IP or cidr on Ingress 'default/ing1': 10.0.0.0/64`,
+		},
+	}
+	source := CreateSource(convtypes.ResourceIngress, "default/ing1")
+	for i, test := range testCases {
+		c := Setup(t)
+		backend, mapper := c.CreateBackendMappingData("default/app", source, Defaults(), test.annPaths, test.addPaths)
+		AddEndpoints(backend, 8080, "172.17.0.11", "172.17.0.12")
+		c.CreateUpdater().UpdateBackendConfig(backend, mapper)
+		actual := map[string][]string{}
+		for _, path := range backend.Paths {
+			actual[path.Path()] = path.AllowedIPHTTP.Rule
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("allow list differs on %d - expected: %v - actual: %v", i, test.expected, actual)
+		}
+		if len(backend.Endpoints) != 2 {
+			t.Errorf("expected 2 endpoints on %d, found %d", i, len(backend.Endpoints))
+		}
+		c.Logger.CompareLogging(test.logging)
+		c.Teardown()
+	}
+}
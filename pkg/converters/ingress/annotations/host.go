@@ -17,43 +17,108 @@ limitations under the License.
 package annotations
 
 import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
+func (c *updater) buildHostAppRoot(d *hostData) {
+	appRoot := d.mapper.Get(ingtypes.HostAppRoot)
+	if appRoot.Value != "" {
+		d.host.AddRedirectIntent(types.RedirectAppRoot, nil, appRoot.Value, appRoot.Source.String())
+	}
+}
+
+func (c *updater) buildHostAudit(d *hostData) {
+	auditBackend := d.mapper.Get(ingtypes.HostAuditBackend)
+	if auditBackend.Value == "" {
+		return
+	}
+	percent := d.mapper.Get(ingtypes.HostAuditSamplePercent)
+	samplePercent, err := strconv.Atoi(percent.Value)
+	if err != nil || samplePercent < 0 || samplePercent > 100 {
+		c.logger.Warn("ignoring audit on %v: sample percent should be a number between 0 and 100: '%s'", percent.Source, percent.Value)
+		return
+	}
+	if samplePercent == 0 {
+		return
+	}
+	namespace, name, port, err := ingutils.ParseServicePort(auditBackend.Value)
+	if err != nil {
+		c.logger.Warn("ignoring audit on %v: %v", auditBackend.Source, err)
+		return
+	}
+	if namespace == "" && auditBackend.Source != nil {
+		namespace = auditBackend.Source.Namespace
+	}
+	// the audit backend is pre-built by the ingress converter
+	backend := c.haproxy.Backends().FindBackend(namespace, name, port)
+	if backend == nil {
+		c.logger.Warn("ignoring audit on %v: service '%s/%s:%s' was not found", auditBackend.Source, namespace, name, port)
+		return
+	}
+	// SPOE agents speak SPOP over plain TCP
+	backend.ModeTCP = true
+	d.host.Audit.Backend = backend.BackendID()
+	d.host.Audit.SamplePercent = samplePercent
+}
+
 func (c *updater) buildHostAuthExternal(d *hostData) {
 	isFrontend := d.mapper.Get(ingtypes.BackAuthExternalPlacement).ToLower() == "frontend"
 	url := d.mapper.Get(ingtypes.BackAuthURL)
 	if isFrontend && url.Value != "" {
 		for _, path := range d.host.Paths {
 			path.AuthExt = &types.AuthExternal{}
-			c.setAuthExternal(d.mapper, path.AuthExt, url)
+			c.setAuthExternal(d.mapper, path.AuthExt, url, d.host.Hostname)
 		}
 	}
 }
 
 func (c *updater) setAuthTLSConfig(mapper *Mapper, target *types.TLSConfig, hostname string) bool {
 	tlsSecret := mapper.Get(ingtypes.HostAuthTLSSecret)
-	if tlsSecret.Source == nil || tlsSecret.Value == "" {
-		return false
-	}
 	verify := mapper.Get(ingtypes.HostAuthTLSVerifyClient)
 	if verify.Value == "off" {
 		return false
 	}
+	hasSecret := tlsSecret.Source != nil && tlsSecret.Value != ""
+	// optional_no_ca and on are only considered without a CA secret if
+	// explicitly configured, they can also be a global default
+	noCA := !hasSecret && verify.Source != nil && verify.Value == "optional_no_ca"
+	if !hasSecret && verify.Source != nil && verify.Value == "on" {
+		c.logger.Error("client certificate verification on %v needs a CA secret, configure it with auth-tls-secret", verify.Source)
+	}
+	if !hasSecret && !noCA {
+		return false
+	}
 	tls := target
-	if cafile, crlfile, err := c.cache.GetCASecretPath(
-		tlsSecret.Source.Namespace,
-		tlsSecret.Value,
-		[]convtypes.TrackingRef{{Context: convtypes.ResourceHAHostname, UniqueName: hostname}},
-	); err == nil {
-		tls.CAFilename = cafile.Filename
-		tls.CAHash = cafile.SHA1Hash
-		tls.CRLFilename = crlfile.Filename
-		tls.CRLHash = crlfile.SHA1Hash
-	} else {
-		c.logger.Error("error building TLS auth config on %s: %v", tlsSecret.Source, err)
+	if hasSecret {
+		if err := c.checkCrossNamespaceSecret(tlsSecret, c.options.DynamicConfig.CrossNamespaceSecretCA); err != nil {
+			c.logger.Error("error building TLS auth config on %s: %v", tlsSecret.Source, err)
+		} else if cafile, crlfile, err := c.cache.GetCASecretPath(
+			tlsSecret.Source.Namespace,
+			tlsSecret.Value,
+			[]convtypes.TrackingRef{{Context: convtypes.ResourceHAHostname, UniqueName: hostname}},
+		); err == nil {
+			tls.CAFilename = cafile.Filename
+			tls.CAHash = cafile.SHA1Hash
+			tls.CRLFilename = crlfile.Filename
+			tls.CRLHash = crlfile.SHA1Hash
+		} else if !c.secrets.ReportedMissing(tlsSecret.Source, err) {
+			c.logger.Error("error building TLS auth config on %s: %v", tlsSecret.Source, err)
+		}
+	}
+	if noCA {
+		// haproxy only asks for the client certificate if a CA is configured,
+		// the fake CA never validates but the verification result is ignored.
+		tls.CAFilename = c.fakeCA.Filename
+		tls.CAHash = c.fakeCA.SHA1Hash
 	}
 	if tls.CAFilename == "" && mapper.Get(ingtypes.HostAuthTLSStrict).Bool() {
 		// Here we have a misconfigured auth-tls and auth-tls-strict as `true`.
@@ -74,6 +139,13 @@ func (c *updater) setAuthTLSConfig(mapper *Mapper, target *types.TLSConfig, host
 			tls.CAVerify = types.CAVerifyAlways
 		}
 	}
+	depth := mapper.Get(ingtypes.HostAuthTLSVerifyDepth)
+	tls.CAVerifyDepth = depth.Int()
+	if depth.Source != nil && tls.CAVerifyDepth != 1 {
+		// haproxy has no option to limit the verification depth on a bind,
+		// the whole chain presented by the client is verified against the CA
+		c.logger.Warn("verify depth %d on %v is not enforced, client certificate chain is verified up to a trusted CA", tls.CAVerifyDepth, depth.Source)
+	}
 	return true
 }
 
@@ -81,6 +153,49 @@ func (c *updater) buildTCPAuthTLS(d *tcpData) {
 	_ = c.setAuthTLSConfig(d.mapper, &d.tcpPort.TLS, d.tcpHost.Hostname())
 }
 
+func (c *updater) buildTCPServiceALPN(tcp *types.TCPServicePort, mapper *Mapper) {
+	tcp.ALPNRoutes = nil
+	tcp.TLS.ALPN = ""
+	alpn := mapper.Get(ingtypes.TCPTCPServiceALPN)
+	if alpn.Value == "" {
+		return
+	}
+	if tcp.TLS.TLSFilename == "" {
+		c.logger.Warn("ignoring alpn routes on %v: tcp service port '%d' does not terminate TLS", alpn.Source, tcp.Port())
+		return
+	}
+	var routes []*types.TCPServiceALPNRoute
+	var protocols []string
+	for _, route := range utils.LineToSlice(alpn.Value) {
+		if strings.TrimSpace(route) == "" {
+			continue
+		}
+		protocol, namespace, name, port, err := ingutils.ParseALPNRoute(route)
+		if err != nil {
+			c.logger.Warn("ignoring alpn route on %v: %v", alpn.Source, err)
+			continue
+		}
+		if namespace == "" && alpn.Source != nil {
+			namespace = alpn.Source.Namespace
+		}
+		// the alpn backend is pre-built by the ingress converter
+		backend := c.haproxy.Backends().FindBackend(namespace, name, port)
+		if backend == nil {
+			c.logger.Warn("ignoring alpn route on %v: service '%s/%s:%s' was not found", alpn.Source, namespace, name, port)
+			continue
+		}
+		routes = append(routes, &types.TCPServiceALPNRoute{
+			Protocol: protocol,
+			Backend:  backend.BackendID(),
+		})
+		protocols = append(protocols, protocol)
+	}
+	tcp.ALPNRoutes = routes
+	// haproxy needs to advertise the protocols, otherwise
+	// the client would never negotiate one of them
+	tcp.TLS.ALPN = strings.Join(protocols, ",")
+}
+
 func (c *updater) buildHostAuthTLS(d *hostData) {
 	if c.setAuthTLSConfig(d.mapper, &d.host.TLS.TLSConfig, d.host.Hostname) {
 		d.host.TLS.CAErrorPage = d.mapper.Get(ingtypes.HostAuthTLSErrorPage).Value
@@ -104,14 +219,64 @@ func (c *updater) buildHostCertSigner(d *hostData) {
 	// just the warnings, ingress.syncIngress() has already added the domains
 }
 
+func (c *updater) buildHostPathNormalization(d *hostData) {
+	normalize := d.mapper.Get(ingtypes.HostPathNormalization)
+	var mode types.PathNormalization
+	switch normalize.ToLower() {
+	case "", "off":
+		return
+	case "strict":
+		mode = types.PathNormalizationStrict
+	case "lowercase":
+		mode = types.PathNormalizationLowercase
+	default:
+		c.logger.Warn("ignoring invalid path-normalization on %v: %s", normalize.Source, normalize.Value)
+		return
+	}
+	// normalize-uri was introduced on haproxy 2.4 as an experimental
+	// directive, it is available without expose-experimental-directives
+	// since 2.5
+	if !utils.VersionAtLeast(c.options.HAProxyVersion, 2, 5) {
+		c.logger.Warn("ignoring path-normalization on %v: haproxy %s does not support normalize-uri",
+			normalize.Source, c.options.HAProxyVersion)
+		return
+	}
+	d.host.PathNormalization = mode
+}
+
+func (c *updater) buildHostQUIC(d *hostData) {
+	d.host.QUIC.AltSvcMaxAge = 0
+	if c.haproxy.Global().QUIC.Bind == "" || d.mapper.Get(ingtypes.HostDisableQUIC).Bool() {
+		return
+	}
+	// the crt-list is shared with the QUIC bind, so the TLS options of the
+	// host also apply on QUIC connections
+	if options := d.mapper.Get(ingtypes.HostSSLOptionsHost); tls13Disabled(options.Value) {
+		c.logger.Warn("disabling QUIC on host '%s' on %v: QUIC needs TLS 1.3, which is disabled by '%s'",
+			d.host.Hostname, options.Source, ingtypes.HostSSLOptionsHost)
+		return
+	}
+	if alpn := d.mapper.Get(ingtypes.HostTLSALPN); alpn.Source != nil && !slices.Contains(utils.Split(alpn.Value, ","), "h3") {
+		c.logger.Warn("disabling QUIC on host '%s' on %v: '%s' does not include h3",
+			d.host.Hostname, alpn.Source, ingtypes.HostTLSALPN)
+		return
+	}
+	maxAge := d.mapper.Get(ingtypes.HostQUICAltSvcMaxAge)
+	if maxAge.Int() <= 0 {
+		c.logger.Warn("ignoring invalid QUIC alt-svc max age on %v: %s", maxAge.Source, maxAge.Value)
+		return
+	}
+	d.host.QUIC.AltSvcMaxAge = maxAge.Int()
+}
+
 func (c *updater) buildHostRedirect(d *hostData) {
 	// TODO need a host<->host tracking if a target is found
 	redir := d.mapper.Get(ingtypes.HostRedirectFrom)
 	if target := c.haproxy.Hosts().FindTargetRedirect(redir.Value, false); target != nil {
 		c.logger.Warn("ignoring redirect from '%s' on %v, it's already targeting to '%s'",
 			redir.Value, redir.Source, target.Hostname)
-	} else if len(d.host.Paths) > 0 {
-		d.host.Redirect.RedirectHost = redir.Value
+	} else if len(d.host.Paths) > 0 && redir.Value != "" {
+		d.host.AddRedirectIntent(types.RedirectFrom, nil, redir.Value, redir.Source.String())
 	}
 	redirRegex := d.mapper.Get(ingtypes.HostRedirectFromRegex)
 	if target := c.haproxy.Hosts().FindTargetRedirect(redirRegex.Value, true); target != nil {
@@ -120,6 +285,7 @@ func (c *updater) buildHostRedirect(d *hostData) {
 	} else if len(d.host.Paths) > 0 {
 		d.host.Redirect.RedirectHostRegex = redirRegex.Value
 	}
+	d.host.Redirect.HTTPSPort = c.validateHTTPSPort(d.mapper.Get(ingtypes.HostHTTPSRedirectPort))
 }
 
 func (c *updater) buildHostSSLPassthrough(d *hostData) {
@@ -156,4 +322,19 @@ func (c *updater) buildHostTLSConfig(d *hostData) {
 		d.host.TLS.ALPN = cfg.Value
 	}
 	d.host.TLS.Options = d.mapper.Get(ingtypes.HostSSLOptionsHost).Value
+	if sslRedirect := d.mapper.Get(ingtypes.HostSSLRedirectHost); sslRedirect.Source != nil {
+		redir := sslRedirect.Bool()
+		d.host.TLS.SSLRedirect = &redir
+	}
+	if httpOnly := d.mapper.Get(ingtypes.HostHTTPOnly); httpOnly.Bool() {
+		if d.host.TLS.TLSHash != "" {
+			c.logger.Warn("ignoring TLS secret of host '%s' on %v: host is configured as http-only", d.host.Hostname, httpOnly.Source)
+		}
+		d.host.TLS.HTTPOnly = true
+		d.host.TLS.UseDefaultCrt = false
+		d.host.TLS.TLSCommonName = ""
+		d.host.TLS.TLSFilename = ""
+		d.host.TLS.TLSHash = ""
+		d.host.TLS.TLSNotAfter = time.Time{}
+	}
 }
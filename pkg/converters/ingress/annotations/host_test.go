@@ -23,6 +23,100 @@ import (
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
+func TestAudit(t *testing.T) {
+	audit := hatypes.BackendID{Namespace: "default", Name: "spoa", Port: "12345"}
+	testCases := []struct {
+		ann      map[string]string
+		expected hatypes.HostAuditConfig
+		modeTCP  bool
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditSamplePercent: "10",
+			},
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend: "spoa:12345",
+			},
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend:       "spoa:12345",
+				ingtypes.HostAuditSamplePercent: "1",
+			},
+			expected: hatypes.HostAuditConfig{Backend: audit, SamplePercent: 1},
+			modeTCP:  true,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend:       "default/spoa:12345",
+				ingtypes.HostAuditSamplePercent: "100",
+			},
+			expected: hatypes.HostAuditConfig{Backend: audit, SamplePercent: 100},
+			modeTCP:  true,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend:       "spoa:12345",
+				ingtypes.HostAuditSamplePercent: "101",
+			},
+			logging: `WARN ignoring audit on ingress 'default/ing1': sample percent should be a number between 0 and 100: '101'`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend:       "spoa:12345",
+				ingtypes.HostAuditSamplePercent: "-1",
+			},
+			logging: `WARN ignoring audit on ingress 'default/ing1': sample percent should be a number between 0 and 100: '-1'`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend:       "spoa:12345",
+				ingtypes.HostAuditSamplePercent: "1%",
+			},
+			logging: `WARN ignoring audit on ingress 'default/ing1': sample percent should be a number between 0 and 100: '1%'`,
+		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend:       "missing:12345",
+				ingtypes.HostAuditSamplePercent: "1",
+			},
+			logging: `WARN ignoring audit on ingress 'default/ing1': service 'default/missing:12345' was not found`,
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.HostAuditBackend:       "spoa",
+				ingtypes.HostAuditSamplePercent: "1",
+			},
+			logging: `WARN ignoring audit on ingress 'default/ing1': invalid service syntax, expected [<namespace>/]<name>:<port>: spoa`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		backend := c.haproxy.Backends().AcquireBackend("default", "spoa", "12345")
+		d := c.createHostData(source, test.ann, map[string]string{ingtypes.HostAuditSamplePercent: "0"})
+		c.createUpdater().buildHostAudit(d)
+		c.compareObjects("audit", i, d.host.Audit, test.expected)
+		c.compareObjects("mode tcp", i, backend.ModeTCP, test.modeTCP)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestBuildHostRedirect(t *testing.T) {
 	testCases := []struct {
 		annPrev    map[string]string
@@ -104,6 +198,47 @@ func TestBuildHostRedirect(t *testing.T) {
 			},
 			nopath: true,
 		},
+		// 8
+		{
+			ann: map[string]string{
+				ingtypes.HostRedirectFrom: "www.d.local",
+			},
+			annDefault: map[string]string{
+				ingtypes.HostHTTPSRedirectPort: "8443",
+			},
+			expected: hatypes.HostRedirectConfig{RedirectHost: "www.d.local", HTTPSPort: 8443},
+		},
+		// 9
+		{
+			ann: map[string]string{
+				ingtypes.HostRedirectFrom:      "www.d.local",
+				ingtypes.HostHTTPSRedirectPort: "9443",
+			},
+			annDefault: map[string]string{
+				ingtypes.HostHTTPSRedirectPort: "8443",
+			},
+			expected: hatypes.HostRedirectConfig{RedirectHost: "www.d.local", HTTPSPort: 9443},
+		},
+		// 10
+		{
+			ann: map[string]string{
+				ingtypes.HostRedirectFrom:      "www.d.local",
+				ingtypes.HostHTTPSRedirectPort: "443",
+			},
+			annDefault: map[string]string{
+				ingtypes.HostHTTPSRedirectPort: "8443",
+			},
+			expected: hatypes.HostRedirectConfig{RedirectHost: "www.d.local"},
+		},
+		// 11
+		{
+			ann: map[string]string{
+				ingtypes.HostRedirectFrom:      "www.d.local",
+				ingtypes.HostHTTPSRedirectPort: "https",
+			},
+			expected: hatypes.HostRedirectConfig{RedirectHost: "www.d.local"},
+			logging:  `WARN ignoring invalid https redirect port on ingress 'default/ing1': https`,
+		},
 	}
 	sprev := &Source{Namespace: "prev", Name: "ingprev", Type: "ingress"}
 	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
@@ -130,10 +265,235 @@ func TestBuildHostRedirect(t *testing.T) {
 	}
 }
 
+func TestTCPServiceALPN(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		notls    bool
+		expected []*hatypes.TCPServiceALPNRoute
+		expALPN  string
+		logging  string
+	}{
+		// 0
+		{},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.TCPTCPServiceALPN: "xmpp-client xmpp:5222",
+			},
+			expected: []*hatypes.TCPServiceALPNRoute{
+				{Protocol: "xmpp-client", Backend: hatypes.BackendID{Namespace: "default", Name: "xmpp", Port: "5222"}},
+			},
+			expALPN: "xmpp-client",
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.TCPTCPServiceALPN: "xmpp-client xmpp:5222\nh2 other/app:8080\nhttp/1.1 missing:8080\nh2;x app:8080",
+			},
+			expected: []*hatypes.TCPServiceALPNRoute{
+				{Protocol: "xmpp-client", Backend: hatypes.BackendID{Namespace: "default", Name: "xmpp", Port: "5222"}},
+				{Protocol: "h2", Backend: hatypes.BackendID{Namespace: "other", Name: "app", Port: "8080"}},
+			},
+			expALPN: "xmpp-client,h2",
+			logging: `
+WARN ignoring alpn route on ingress 'default/ing1': service 'default/missing:8080' was not found
+WARN ignoring alpn route on ingress 'default/ing1': invalid alpn protocol: h2;x`,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.TCPTCPServiceALPN: "xmpp-client xmpp:5222",
+			},
+			notls:   true,
+			logging: `WARN ignoring alpn routes on ingress 'default/ing1': tcp service port '7001' does not terminate TLS`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		c.haproxy.Backends().AcquireBackend("default", "xmpp", "5222")
+		c.haproxy.Backends().AcquireBackend("other", "app", "8080")
+		tcpPort, _ := c.haproxy.TCPServices().AcquireTCPService("tcp.local:7001")
+		if !test.notls {
+			tcpPort.TLS.TLSFilename = "/var/haproxy/ssl/tcp.pem"
+		}
+		d := c.createHostData(source, test.ann, map[string]string{})
+		c.createUpdater().buildTCPServiceALPN(tcpPort, d.mapper)
+		c.compareObjects("alpn routes", i, tcpPort.ALPNRoutes, test.expected)
+		c.compareObjects("alpn", i, tcpPort.TLS.ALPN, test.expALPN)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestPathNormalization(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		version  string
+		expected hatypes.PathNormalization
+		logging  string
+	}{
+		// 0
+		{
+			ann:      map[string]string{},
+			expected: hatypes.PathNormalizationOff,
+		},
+		// 1
+		{
+			ann: map[string]string{
+				ingtypes.HostPathNormalization: "off",
+			},
+			expected: hatypes.PathNormalizationOff,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.HostPathNormalization: "strict",
+			},
+			expected: hatypes.PathNormalizationStrict,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.HostPathNormalization: "Lowercase",
+			},
+			expected: hatypes.PathNormalizationLowercase,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.HostPathNormalization: "lower",
+			},
+			expected: hatypes.PathNormalizationOff,
+			logging:  `WARN ignoring invalid path-normalization on ingress 'default/ing1': lower`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.HostPathNormalization: "strict",
+			},
+			version:  "2.5.14",
+			expected: hatypes.PathNormalizationStrict,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.HostPathNormalization: "strict",
+			},
+			version:  "2.4.22",
+			expected: hatypes.PathNormalizationOff,
+			logging:  `WARN ignoring path-normalization on ingress 'default/ing1': haproxy 2.4.22 does not support normalize-uri`,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.HostPathNormalization: "strict",
+			},
+			version:  "2.2.30",
+			expected: hatypes.PathNormalizationOff,
+			logging:  `WARN ignoring path-normalization on ingress 'default/ing1': haproxy 2.2.30 does not support normalize-uri`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createHostData(source, test.ann, map[string]string{})
+		u := c.createUpdater()
+		u.options.HAProxyVersion = test.version
+		u.buildHostPathNormalization(d)
+		c.compareObjects("path normalization", i, d.host.PathNormalization, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestHostQUIC(t *testing.T) {
+	testCases := []struct {
+		ann      map[string]string
+		disabled bool
+		expected int
+		logging  string
+	}{
+		// 0
+		{
+			disabled: true,
+			expected: 0,
+		},
+		// 1
+		{
+			expected: 86400,
+		},
+		// 2
+		{
+			ann: map[string]string{
+				ingtypes.HostQUICAltSvcMaxAge: "3600",
+			},
+			expected: 3600,
+		},
+		// 3
+		{
+			ann: map[string]string{
+				ingtypes.HostDisableQUIC: "true",
+			},
+			expected: 0,
+		},
+		// 4
+		{
+			ann: map[string]string{
+				ingtypes.HostSSLOptionsHost: "ssl-max-ver TLSv1.2",
+			},
+			expected: 0,
+			logging:  `WARN disabling QUIC on host 'd1.local' on ingress 'default/ing1': QUIC needs TLS 1.3, which is disabled by 'ssl-options-host'`,
+		},
+		// 5
+		{
+			ann: map[string]string{
+				ingtypes.HostTLSALPN: "h2,http/1.1",
+			},
+			expected: 0,
+			logging:  `WARN disabling QUIC on host 'd1.local' on ingress 'default/ing1': 'tls-alpn' does not include h3`,
+		},
+		// 6
+		{
+			ann: map[string]string{
+				ingtypes.HostTLSALPN: "h3,h2,http/1.1",
+			},
+			expected: 86400,
+		},
+		// 7
+		{
+			ann: map[string]string{
+				ingtypes.HostQUICAltSvcMaxAge: "0",
+			},
+			expected: 0,
+			logging:  `WARN ignoring invalid QUIC alt-svc max age on ingress 'default/ing1': 0`,
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	annDefault := map[string]string{
+		ingtypes.HostQUICAltSvcMaxAge: "86400",
+		ingtypes.HostTLSALPN:          "h2,http/1.1",
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		if !test.disabled {
+			c.haproxy.Global().QUIC = hatypes.QUICConfig{Bind: "quic4@:443", Port: 443}
+		}
+		d := c.createHostData(source, test.ann, annDefault)
+		d.host.Hostname = "d1.local"
+		c.createUpdater().buildHostQUIC(d)
+		c.compareObjects("quic", i, d.host.QUIC.AltSvcMaxAge, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestTLSConfig(t *testing.T) {
+	sslRedirectFalse := false
 	testCases := []struct {
 		annDefault map[string]string
 		ann        map[string]string
+		tlsHash    string
 		expected   hatypes.HostTLSConfig
 		logging    string
 	}{
@@ -332,6 +692,139 @@ func TestTLSConfig(t *testing.T) {
 					Options: "ssl-min-ver TLSv1.0 ssl-max-ver TLSv1.2",
 				}},
 		},
+		// 19
+		{
+			ann: map[string]string{
+				ingtypes.HostSSLRedirectHost: "false",
+			},
+			expected: hatypes.HostTLSConfig{
+				SSLRedirect: &sslRedirectFalse,
+			},
+		},
+		// 20
+		{
+			ann: map[string]string{
+				ingtypes.HostHTTPOnly: "true",
+			},
+			expected: hatypes.HostTLSConfig{
+				HTTPOnly: true,
+			},
+		},
+		// 21
+		{
+			ann: map[string]string{
+				ingtypes.HostHTTPOnly: "true",
+			},
+			tlsHash: "1",
+			expected: hatypes.HostTLSConfig{
+				HTTPOnly: true,
+			},
+			logging: "WARN ignoring TLS secret of host 'domain.local' on ingress 'system/ing1': host is configured as http-only",
+		},
+		// 22
+		{
+			ann: map[string]string{
+				ingtypes.HostHTTPOnly: "false",
+			},
+			tlsHash: "1",
+			expected: hatypes.HostTLSConfig{
+				TLSConfig: hatypes.TLSConfig{
+					TLSFilename: "/tls/domain.local.pem",
+					TLSHash:     "1",
+				},
+				UseDefaultCrt: true,
+			},
+		},
+		// 23
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSVerifyClient: "optional_no_ca",
+			},
+			expected: hatypes.HostTLSConfig{
+				TLSConfig: hatypes.TLSConfig{
+					CAFilename: fakeCAFilename,
+					CAHash:     fakeCAHash,
+					CAVerify:   hatypes.CAVerifySkipCheck,
+				}},
+		},
+		// 24
+		{
+			annDefault: map[string]string{
+				ingtypes.HostAuthTLSVerifyClient: "optional_no_ca",
+			},
+		},
+		// 25
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSVerifyClient: "on",
+			},
+			logging: "ERROR client certificate verification on ingress 'system/ing1' needs a CA secret, configure it with auth-tls-secret",
+		},
+		// 26
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSStrict:       "true",
+				ingtypes.HostAuthTLSVerifyClient: "on",
+			},
+			logging: "ERROR client certificate verification on ingress 'system/ing1' needs a CA secret, configure it with auth-tls-secret",
+		},
+		// 27
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:       "cafile",
+				ingtypes.HostAuthTLSVerifyClient: "off",
+			},
+		},
+		// 28
+		{
+			annDefault: map[string]string{
+				ingtypes.HostAuthTLSVerifyClient: "on",
+			},
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:       "cafile",
+				ingtypes.HostAuthTLSVerifyClient: "invalid",
+			},
+			expected: hatypes.HostTLSConfig{
+				TLSConfig: hatypes.TLSConfig{
+					CAFilename: "/path/ca.crt",
+					CAHash:     "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+					CAVerify:   hatypes.CAVerifyAlways,
+				}},
+			logging: "WARN ignoring invalid verify client option on ingress 'system/ing1' key 'auth-tls-verify-client': invalid",
+		},
+		// 29
+		{
+			annDefault: map[string]string{
+				ingtypes.HostAuthTLSVerifyDepth: "1",
+			},
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:      "cafile",
+				ingtypes.HostAuthTLSVerifyDepth: "0",
+			},
+			expected: hatypes.HostTLSConfig{
+				TLSConfig: hatypes.TLSConfig{
+					CAFilename:    "/path/ca.crt",
+					CAHash:        "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+					CAVerify:      hatypes.CAVerifyAlways,
+					CAVerifyDepth: 1,
+				}},
+			logging: "WARN ignoring invalid verify depth on ingress 'system/ing1' key 'auth-tls-verify-depth': 0",
+		},
+		// 30
+		{
+			ann: map[string]string{
+				ingtypes.HostAuthTLSSecret:      "cafile",
+				ingtypes.HostAuthTLSVerifyDepth: "3",
+			},
+			expected: hatypes.HostTLSConfig{
+				TLSConfig: hatypes.TLSConfig{
+					CAFilename:    "/path/ca.crt",
+					CAHash:        "c0e1bf73caf75d7353cf3ecdd20ceb2f6fa1cab1",
+					CAVerify:      hatypes.CAVerifyAlways,
+					CAVerifyDepth: 3,
+				}},
+			logging: "WARN verify depth 3 on ingress 'system/ing1' is not enforced, client certificate chain is verified up to a trusted CA",
+		},
 	}
 	source := &Source{Namespace: "system", Name: "ing1", Type: "ingress"}
 	for i, test := range testCases {
@@ -340,6 +833,12 @@ func TestTLSConfig(t *testing.T) {
 			"system/cafile": "/path/ca.crt",
 		}
 		d := c.createHostData(source, test.ann, test.annDefault)
+		d.host.Hostname = "domain.local"
+		if test.tlsHash != "" {
+			d.host.TLS.TLSFilename = "/tls/domain.local.pem"
+			d.host.TLS.TLSHash = test.tlsHash
+			d.host.TLS.UseDefaultCrt = true
+		}
 		updater := c.createUpdater()
 		updater.buildHostAuthTLS(d)
 		updater.buildHostTLSConfig(d)
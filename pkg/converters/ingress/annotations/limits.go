@@ -0,0 +1,152 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"fmt"
+	"strings"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// names of the annotation limits, used on logging, events and metrics
+const (
+	limitValueLength  = "value-length"
+	limitCIDRs        = "cidrs"
+	limitHeaders      = "headers"
+	limitRewritePaths = "rewrite-paths"
+)
+
+// limits protects the controller and the haproxy configuration from
+// pathological annotation sizes. A nil *limits doesn't limit anything.
+type limits struct {
+	logger    types.Logger
+	cache     convtypes.Cache
+	metrics   types.Metrics
+	conf      convtypes.AnnotationLimits
+	dynconfig *convtypes.DynamicConfig
+}
+
+func newLimits(options *convtypes.ConverterOptions) *limits {
+	dynconfig := options.DynamicConfig
+	if dynconfig == nil {
+		dynconfig = &convtypes.DynamicConfig{}
+	}
+	return &limits{
+		logger:    options.Logger,
+		cache:     options.Cache,
+		metrics:   options.Metrics,
+		conf:      options.AnnotationLimits,
+		dynconfig: dynconfig,
+	}
+}
+
+// check verifies if size is within max, returning the number of items that
+// should be used. Zero means that the whole configuration should be rejected.
+// truncate is false if the configuration cannot be partially used, despite
+// the configured policy. A max of zero disables the limit.
+func (l *limits) check(source *Source, key, limit string, size, max int, truncate bool) int {
+	if l == nil || max <= 0 || size <= max {
+		return size
+	}
+	truncate = truncate && l.conf.Truncate
+	policy := "rejected"
+	if truncate {
+		policy = "truncated"
+	}
+	// the same config is checked once per path and again on every sync,
+	// report only once, or again if its size changes
+	if l.report(fmt.Sprintf("%v/%s/%s/%d", source, key, limit, size)) {
+		msg := fmt.Sprintf("%s of '%s' exceeds the limit: %d > %d, %s", limit, key, size, max, policy)
+		l.logger.Error("%s on %v", msg, source)
+		if source != nil && source.Type == convtypes.ResourceIngress && source.Namespace != "" {
+			l.cache.NotifyIngressWarning(source.FullName(), "AnnotationLimit", msg)
+		}
+		l.metrics.IncAnnotationLimit(limit)
+	}
+	if truncate {
+		return max
+	}
+	return 0
+}
+
+// report returns true if id wasn't reported yet, remembering it for the
+// controller lifetime. The map is replaced instead of updated, so a copy of
+// the dynamic config, eg the one of a simulation, doesn't change the
+// reports of the controller.
+func (l *limits) report(id string) bool {
+	last := l.dynconfig.AnnotationLimits
+	if last[id] {
+		return false
+	}
+	reported := make(map[string]bool, len(last)+1)
+	for reportedID := range last {
+		reported[reportedID] = true
+	}
+	reported[id] = true
+	l.dynconfig.AnnotationLimits = reported
+	return true
+}
+
+// checkValue verifies the length of an annotation value, returning false if
+// the value should be rejected. Values are always rejected: truncating them
+// on a byte boundary could change their meaning, eg a CIDR or a regex, and
+// lists are limited by their number of items instead.
+func (l *limits) checkValue(source *Source, key, value string) (string, bool) {
+	if l == nil || value == "" {
+		return value, true
+	}
+	n := l.check(source, key, limitValueLength, len(value), l.conf.MaxValueLength, false)
+	return value, n > 0
+}
+
+// checkCIDRs verifies the number of IPs or CIDRs of an access list. Deny
+// lists and lists with `!` exceptions are always rejected: truncating them
+// would drop denied IPs or exceptions, allowing requests that the whole
+// list would deny.
+func (l *limits) checkCIDRs(cfg *ConfigValue, key string, cidrs []string) []string {
+	if l == nil {
+		return cidrs
+	}
+	truncate := key != ingtypes.BackDenylistSourceRange
+	for _, cidr := range cidrs {
+		if strings.HasPrefix(cidr, "!") {
+			truncate = false
+			break
+		}
+	}
+	return cidrs[:l.check(cfg.Source, key, limitCIDRs, len(cidrs), l.conf.MaxCIDRs, truncate)]
+}
+
+// checkHeaders verifies the number of headers of a header list.
+func (l *limits) checkHeaders(cfg *ConfigValue, key string, headers []string) []string {
+	if l == nil {
+		return headers
+	}
+	return headers[:l.check(cfg.Source, key, limitHeaders, len(headers), l.conf.MaxHeaders, true)]
+}
+
+// checkRewritePaths verifies the number of paths of a backend configured
+// with rewrite-target, returning how many of them should be rewritten.
+func (l *limits) checkRewritePaths(source *Source, count int) int {
+	if l == nil {
+		return count
+	}
+	return l.check(source, ingtypes.BackRewriteTarget, limitRewritePaths, count, l.conf.MaxRewritePaths, true)
+}
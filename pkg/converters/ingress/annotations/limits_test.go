@@ -0,0 +1,351 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func (c *testConfig) createLimits(conf convtypes.AnnotationLimits) (*limits, *types_helper.MetricsMock) {
+	metrics := types_helper.NewMetricsMock()
+	return newLimits(&convtypes.ConverterOptions{
+		Logger:           c.logger,
+		Cache:            c.cache,
+		Metrics:          metrics,
+		AnnotationLimits: conf,
+	}), metrics
+}
+
+func (c *testConfig) compareLimits(i int, metrics *types_helper.MetricsMock, limit, event string) {
+	var expMetrics = map[string]int{}
+	var expEvents []string
+	if event != "" {
+		expMetrics[limit] = 1
+		expEvents = []string{event}
+	}
+	c.compareObjects("limit metrics", i, metrics.AnnotationLimits, expMetrics)
+	c.compareObjects("limit events", i, c.cache.Events, expEvents)
+}
+
+func TestLimitValueLength(t *testing.T) {
+	testCases := []struct {
+		value    string
+		truncate bool
+		expected string
+		logging  string
+		event    string
+	}{
+		// 0
+		{
+			value:    "10.0.0.0/8",
+			expected: "10.0.0.0/8",
+		},
+		// 1
+		{
+			value:    "10.0.0.0/16",
+			expected: "10.0.0.0/16",
+		},
+		// 2
+		{
+			value:   "10.0.0.0/16,",
+			logging: "ERROR value-length of 'allowlist-source-range' exceeds the limit: 12 > 11, rejected on Ingress 'default/ing1'",
+			event:   "Warning AnnotationLimit default/ing1: value-length of 'allowlist-source-range' exceeds the limit: 12 > 11, rejected",
+		},
+		// 3
+		{
+			value:    "10.0.0.0/16,",
+			truncate: true,
+			logging:  "ERROR value-length of 'allowlist-source-range' exceeds the limit: 12 > 11, rejected on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: value-length of 'allowlist-source-range' exceeds the limit: 12 > 11, rejected",
+		},
+		// 4
+		{
+			value:    "10.0.0.0/8,192.168.0.0/16",
+			truncate: true,
+			logging:  "ERROR value-length of 'allowlist-source-range' exceeds the limit: 25 > 11, rejected on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: value-length of 'allowlist-source-range' exceeds the limit: 25 > 11, rejected",
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: convtypes.ResourceIngress}
+	for i, test := range testCases {
+		c := setup(t)
+		limits, metrics := c.createLimits(convtypes.AnnotationLimits{MaxValueLength: 11, Truncate: test.truncate})
+		builder := NewMapBuilder(c.logger, nil, map[string]string{})
+		builder.limits = limits
+		mapper := builder.NewMapper()
+		ann := map[string]string{ingtypes.BackAllowlistSourceRange: test.value}
+		// the same source on distinct paths should be reported only once
+		mapper.AddAnnotations(source, hatypes.CreateHostPathLink(testingHostname, "/", hatypes.MatchBegin), ann)
+		mapper.AddAnnotations(source, hatypes.CreateHostPathLink(testingHostname, "/app", hatypes.MatchBegin), ann)
+		c.compareObjects("value length", i, mapper.Get(ingtypes.BackAllowlistSourceRange).Value, test.expected)
+		c.compareLimits(i, metrics, limitValueLength, test.event)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestLimitCIDRs(t *testing.T) {
+	testCases := []struct {
+		key      string
+		value    string
+		truncate bool
+		expected []string
+		expExc   []string
+		expDeny  []string
+		logging  string
+		event    string
+	}{
+		// 0
+		{
+			key:      ingtypes.BackAllowlistSourceRange,
+			value:    "10.0.0.1,10.0.0.2",
+			expected: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		// 1
+		{
+			key:     ingtypes.BackAllowlistSourceRange,
+			value:   "10.0.0.1,10.0.0.2,10.0.0.3",
+			logging: "ERROR cidrs of 'allowlist-source-range' exceeds the limit: 3 > 2, rejected on Ingress 'default/ing1'",
+			event:   "Warning AnnotationLimit default/ing1: cidrs of 'allowlist-source-range' exceeds the limit: 3 > 2, rejected",
+		},
+		// 2
+		{
+			key:      ingtypes.BackAllowlistSourceRange,
+			value:    "10.0.0.1,10.0.0.2,10.0.0.3",
+			truncate: true,
+			expected: []string{"10.0.0.1", "10.0.0.2"},
+			logging:  "ERROR cidrs of 'allowlist-source-range' exceeds the limit: 3 > 2, truncated on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: cidrs of 'allowlist-source-range' exceeds the limit: 3 > 2, truncated",
+		},
+		// 3
+		{
+			key:     ingtypes.BackWhitelistSourceRange,
+			value:   "10.0.0.1,10.0.0.2,10.0.0.3",
+			logging: "ERROR cidrs of 'whitelist-source-range' exceeds the limit: 3 > 2, rejected on Ingress 'default/ing1'",
+			event:   "Warning AnnotationLimit default/ing1: cidrs of 'whitelist-source-range' exceeds the limit: 3 > 2, rejected",
+		},
+		// 4
+		{
+			key:      ingtypes.BackAllowlistSourceRange,
+			value:    "10.0.0.0/8,!10.0.0.1",
+			truncate: true,
+			expected: []string{"10.0.0.0/8"},
+			expExc:   []string{"10.0.0.1"},
+		},
+		// 5
+		{
+			key:      ingtypes.BackAllowlistSourceRange,
+			value:    "10.0.0.0/8,192.168.0.0/16,!10.0.0.1",
+			truncate: true,
+			logging:  "ERROR cidrs of 'allowlist-source-range' exceeds the limit: 3 > 2, rejected on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: cidrs of 'allowlist-source-range' exceeds the limit: 3 > 2, rejected",
+		},
+		// 6
+		{
+			key:      ingtypes.BackDenylistSourceRange,
+			value:    "10.0.0.1,10.0.0.2",
+			truncate: true,
+			expDeny:  []string{"10.0.0.1", "10.0.0.2"},
+		},
+		// 7
+		{
+			key:      ingtypes.BackDenylistSourceRange,
+			value:    "10.0.0.1,10.0.0.2,10.0.0.3",
+			truncate: true,
+			logging:  "ERROR cidrs of 'denylist-source-range' exceeds the limit: 3 > 2, rejected on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: cidrs of 'denylist-source-range' exceeds the limit: 3 > 2, rejected",
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: convtypes.ResourceIngress}
+	for i, test := range testCases {
+		c := setup(t)
+		u := c.createUpdater()
+		var metrics *types_helper.MetricsMock
+		u.limits, metrics = c.createLimits(convtypes.AnnotationLimits{MaxCIDRs: 2, Truncate: test.truncate})
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, map[string]map[string]string{
+			"/":    {test.key: test.value},
+			"/app": {test.key: test.value},
+		}, nil)
+		u.buildBackendWhitelistHTTP(d)
+		for _, path := range d.backend.Paths {
+			c.compareObjects("cidrs", i, path.AllowedIPHTTP.Rule, test.expected)
+			c.compareObjects("cidr exceptions", i, path.AllowedIPHTTP.Exception, test.expExc)
+			c.compareObjects("denied cidrs", i, path.DeniedIPHTTP.Rule, test.expDeny)
+		}
+		c.compareLimits(i, metrics, limitCIDRs, test.event)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestLimitHeaders(t *testing.T) {
+	testCases := []struct {
+		value    string
+		truncate bool
+		expected []string
+		logging  string
+		event    string
+	}{
+		// 0
+		{
+			value:    "x-h1: v1\nx-h2: v2",
+			expected: []string{"x-h1", "x-h2"},
+		},
+		// 1
+		{
+			value:   "x-h1: v1\nx-h2: v2\nx-h3: v3",
+			logging: "ERROR headers of 'headers' exceeds the limit: 3 > 2, rejected on Ingress 'default/ing1'",
+			event:   "Warning AnnotationLimit default/ing1: headers of 'headers' exceeds the limit: 3 > 2, rejected",
+		},
+		// 2
+		{
+			value:    "x-h1: v1\nx-h2: v2\nx-h3: v3",
+			truncate: true,
+			expected: []string{"x-h1", "x-h2"},
+			logging:  "ERROR headers of 'headers' exceeds the limit: 3 > 2, truncated on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: headers of 'headers' exceeds the limit: 3 > 2, truncated",
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: convtypes.ResourceIngress}
+	for i, test := range testCases {
+		c := setup(t)
+		u := c.createUpdater()
+		var metrics *types_helper.MetricsMock
+		u.limits, metrics = c.createLimits(convtypes.AnnotationLimits{MaxHeaders: 2, Truncate: test.truncate})
+		d := c.createBackendData("default/app", source, map[string]string{ingtypes.BackHeaders: test.value}, map[string]string{})
+		u.buildBackendHeaders(d)
+		var actual []string
+		for _, header := range d.backend.Headers {
+			actual = append(actual, header.Name)
+		}
+		c.compareObjects("headers", i, actual, test.expected)
+		c.compareLimits(i, metrics, limitHeaders, test.event)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestLimitRewritePaths(t *testing.T) {
+	testCases := []struct {
+		paths    []string
+		truncate bool
+		expected map[string]string
+		logging  string
+		event    string
+	}{
+		// 0
+		{
+			paths:    []string{"/app1", "/app2"},
+			expected: map[string]string{"/app1": "/", "/app2": "/"},
+		},
+		// 1
+		{
+			paths:    []string{"/app1", "/app2", "/app3"},
+			expected: map[string]string{"/app1": "", "/app2": "", "/app3": ""},
+			logging:  "ERROR rewrite-paths of 'rewrite-target' exceeds the limit: 3 > 2, rejected on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: rewrite-paths of 'rewrite-target' exceeds the limit: 3 > 2, rejected",
+		},
+		// 2
+		{
+			paths:    []string{"/app1", "/app2", "/app3"},
+			truncate: true,
+			expected: map[string]string{"/app1": "/", "/app2": "/", "/app3": ""},
+			logging:  "ERROR rewrite-paths of 'rewrite-target' exceeds the limit: 3 > 2, truncated on Ingress 'default/ing1'",
+			event:    "Warning AnnotationLimit default/ing1: rewrite-paths of 'rewrite-target' exceeds the limit: 3 > 2, truncated",
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: convtypes.ResourceIngress}
+	for i, test := range testCases {
+		c := setup(t)
+		u := c.createUpdater()
+		var metrics *types_helper.MetricsMock
+		u.limits, metrics = c.createLimits(convtypes.AnnotationLimits{MaxRewritePaths: 2, Truncate: test.truncate})
+		ann := map[string]map[string]string{}
+		for _, path := range test.paths {
+			ann[path] = map[string]string{ingtypes.BackRewriteTarget: "/"}
+		}
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, ann, nil)
+		u.buildBackendRewriteURL(d)
+		actual := map[string]string{}
+		for _, path := range d.backend.Paths {
+			actual[path.Path()] = path.RewriteURL
+		}
+		c.compareObjects("rewrite paths", i, actual, test.expected)
+		c.compareLimits(i, metrics, limitRewritePaths, test.event)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestLimitReportOnce(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	source := &Source{Namespace: "default", Name: "ing1", Type: convtypes.ResourceIngress}
+	dynconfig := &convtypes.DynamicConfig{}
+	metrics := types_helper.NewMetricsMock()
+	newSyncLimits := func() *limits {
+		return newLimits(&convtypes.ConverterOptions{
+			Logger:           c.logger,
+			Cache:            c.cache,
+			Metrics:          metrics,
+			DynamicConfig:    dynconfig,
+			AnnotationLimits: convtypes.AnnotationLimits{MaxHeaders: 2},
+		})
+	}
+	cfg := &ConfigValue{Source: source}
+
+	// the first sync reports the limit, the next one doesn't
+	newSyncLimits().checkHeaders(cfg, ingtypes.BackHeaders, []string{"h1", "h2", "h3"})
+	newSyncLimits().checkHeaders(cfg, ingtypes.BackHeaders, []string{"h1", "h2", "h3"})
+	c.compareObjects("limit metrics", 0, metrics.AnnotationLimits, map[string]int{limitHeaders: 1})
+	c.logger.CompareLogging("ERROR headers of 'headers' exceeds the limit: 3 > 2, rejected on Ingress 'default/ing1'")
+
+	// a simulation copies the dynamic config and doesn't change the controller reports
+	last := dynconfig.AnnotationLimits
+	simulation := *dynconfig
+	simLimits := newSyncLimits()
+	simLimits.dynconfig = &simulation
+	simLimits.checkHeaders(cfg, ingtypes.BackHeaders, []string{"h1", "h2", "h3", "h4"})
+	c.compareObjects("reported", 1, dynconfig.AnnotationLimits, last)
+	c.logger.CompareLogging("ERROR headers of 'headers' exceeds the limit: 4 > 2, rejected on Ingress 'default/ing1'")
+
+	// a distinct size is reported again
+	newSyncLimits().checkHeaders(cfg, ingtypes.BackHeaders, []string{"h1", "h2", "h3", "h4"})
+	c.compareObjects("limit metrics", 2, metrics.AnnotationLimits, map[string]int{limitHeaders: 3})
+	c.logger.CompareLogging("ERROR headers of 'headers' exceeds the limit: 4 > 2, rejected on Ingress 'default/ing1'")
+}
+
+func TestLimitDisabled(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+	limits, metrics := c.createLimits(convtypes.AnnotationLimits{})
+	value := strings.Repeat("10.0.0.1,", 100000)
+	actual, ok := limits.checkValue(&Source{Namespace: "default", Name: "ing1", Type: convtypes.ResourceIngress}, ingtypes.BackAllowlistSourceRange, value)
+	if !ok || actual != value {
+		t.Errorf("expected value not to be changed when limits are disabled")
+	}
+	if !reflect.DeepEqual(metrics.AnnotationLimits, map[string]int{}) {
+		t.Errorf("expected no limit metric, but found %v", metrics.AnnotationLimits)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"fmt"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// LintFinding is a legal but risky configuration found in a backend.
+type LintFinding struct {
+	Rule    string
+	Backend *hatypes.Backend
+	Message string
+}
+
+// String ...
+func (f *LintFinding) String() string {
+	return fmt.Sprintf("%s on backend '%s': %s", f.Rule, f.Backend.ID, f.Message)
+}
+
+// hstsPreloadMinMaxAge is the minimum max-age, in seconds, accepted by the
+// HSTS preload list.
+const hstsPreloadMinMaxAge = 31536000
+
+type lintRule struct {
+	name    string
+	backend func(backend *hatypes.Backend) []string
+	path    func(path *hatypes.BackendPath) []string
+}
+
+// lintRules is the registry of the rules evaluated by Lint(). A rule can be
+// disabled using its name in the lint-disabled-rules global config key.
+var lintRules = []lintRule{
+	{
+		name: "allowlist-any-address",
+		backend: func(backend *hatypes.Backend) []string {
+			if hasAnyAddress(backend.AllowedIPTCP.Rule) {
+				return []string{"tcp allowlist allows any address"}
+			}
+			return nil
+		},
+		path: func(path *hatypes.BackendPath) []string {
+			if hasAnyAddress(path.AllowedIPHTTP.Rule) {
+				return []string{fmt.Sprintf("allowlist of path '%s' allows any address", lintPath(path))}
+			}
+			return nil
+		},
+	},
+	{
+		name: "auth-basic-without-ssl-redirect",
+		path: func(path *hatypes.BackendPath) []string {
+			if path.AuthHTTP.UserlistName != "" && !path.SSLRedirect {
+				return []string{fmt.Sprintf("path '%s' accepts basic authentication credentials over plain http", lintPath(path))}
+			}
+			return nil
+		},
+	},
+	{
+		name: "auth-external-without-ssl-redirect",
+		path: func(path *hatypes.BackendPath) []string {
+			if path.AuthExternal.AuthBackendName != "" && !path.SSLRedirect {
+				return []string{fmt.Sprintf("path '%s' sends external authentication data over plain http", lintPath(path))}
+			}
+			return nil
+		},
+	},
+	{
+		name: "cors-any-origin-with-credentials",
+		path: func(path *hatypes.BackendPath) []string {
+			if !path.Cors.Enabled || !path.Cors.AllowCredentials {
+				return nil
+			}
+			for _, origin := range path.Cors.AllowOrigin {
+				if origin == "*" {
+					return []string{fmt.Sprintf("path '%s' allows credentials from any origin", lintPath(path))}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name: "hsts-preload-short-max-age",
+		path: func(path *hatypes.BackendPath) []string {
+			if path.HSTS.Enabled && path.HSTS.Preload && path.HSTS.MaxAge < hstsPreloadMinMaxAge {
+				return []string{fmt.Sprintf("path '%s' asks for hsts preload with max-age %d, below %d",
+					lintPath(path), path.HSTS.MaxAge, hstsPreloadMinMaxAge)}
+			}
+			return nil
+		},
+	},
+	{
+		name: "hsts-preload-without-subdomains",
+		path: func(path *hatypes.BackendPath) []string {
+			if path.HSTS.Enabled && path.HSTS.Preload && !path.HSTS.Subdomains {
+				return []string{fmt.Sprintf("path '%s' asks for hsts preload without including subdomains", lintPath(path))}
+			}
+			return nil
+		},
+	},
+	{
+		name: "maxconn-server-below-replicas",
+		backend: func(backend *hatypes.Backend) []string {
+			maxconn := backend.Server.MaxConn
+			if maxconn > 0 && maxconn < len(backend.Endpoints) {
+				return []string{fmt.Sprintf("maxconn-server %d is below the number of replicas %d",
+					maxconn, len(backend.Endpoints))}
+			}
+			return nil
+		},
+	},
+	{
+		name: "secure-backend-without-ca",
+		backend: func(backend *hatypes.Backend) []string {
+			if backend.Server.Secure && backend.Server.CAFilename == "" {
+				return []string{"secure backend does not verify the server certificate"}
+			}
+			return nil
+		},
+	},
+}
+
+func hasAnyAddress(rules []string) bool {
+	for _, rule := range rules {
+		if rule == "0.0.0.0/0" || rule == "::/0" {
+			return true
+		}
+	}
+	return false
+}
+
+func lintPath(path *hatypes.BackendPath) string {
+	return path.Hostname() + path.Path()
+}
+
+func isLintRule(name string) bool {
+	for _, rule := range lintRules {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint evaluates the enabled lint rules against the built backends.
+func (c *updater) Lint(backends []*hatypes.Backend) []*LintFinding {
+	disabled := make(map[string]bool, len(c.haproxy.Global().LintDisabledRules))
+	for _, name := range c.haproxy.Global().LintDisabledRules {
+		disabled[name] = true
+	}
+	var findings []*LintFinding
+	for _, backend := range backends {
+		for _, rule := range lintRules {
+			if disabled[rule.name] {
+				continue
+			}
+			var messages []string
+			if rule.backend != nil {
+				messages = append(messages, rule.backend(backend)...)
+			}
+			if rule.path != nil {
+				for _, path := range backend.Paths {
+					messages = append(messages, rule.path(path)...)
+				}
+			}
+			for _, msg := range messages {
+				findings = append(findings, &LintFinding{
+					Rule:    rule.name,
+					Backend: backend,
+					Message: msg,
+				})
+			}
+		}
+	}
+	return findings
+}
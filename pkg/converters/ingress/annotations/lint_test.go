@@ -0,0 +1,212 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"testing"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestLint(t *testing.T) {
+	testCases := []struct {
+		backend  func(b *hatypes.Backend)
+		path     func(p *hatypes.BackendPath)
+		disabled []string
+		expected []string
+	}{
+		// 0
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+			},
+		},
+		// 1
+		{
+			backend: func(b *hatypes.Backend) {
+				b.AllowedIPTCP.Rule = []string{"10.0.0.0/8", "0.0.0.0/0"}
+			},
+			path: func(p *hatypes.BackendPath) {
+				p.AllowedIPHTTP.Rule = []string{"::/0"}
+			},
+			expected: []string{
+				"allowlist-any-address on backend 'default_app_8080': tcp allowlist allows any address",
+				"allowlist-any-address on backend 'default_app_8080': allowlist of path 'host.local/' allows any address",
+			},
+		},
+		// 2
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.AuthHTTP.UserlistName = "default_basic1"
+			},
+			expected: []string{
+				"auth-basic-without-ssl-redirect on backend 'default_app_8080': path 'host.local/' accepts basic authentication credentials over plain http",
+			},
+		},
+		// 3
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.AuthHTTP.UserlistName = "default_basic1"
+				p.SSLRedirect = true
+			},
+		},
+		// 4
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.AuthExternal.AuthBackendName = "_auth_4001"
+			},
+			expected: []string{
+				"auth-external-without-ssl-redirect on backend 'default_app_8080': path 'host.local/' sends external authentication data over plain http",
+			},
+		},
+		// 5
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+				p.Cors = hatypes.Cors{Enabled: true, AllowCredentials: true, AllowOrigin: []string{"*"}}
+			},
+			expected: []string{
+				"cors-any-origin-with-credentials on backend 'default_app_8080': path 'host.local/' allows credentials from any origin",
+			},
+		},
+		// 6
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+				p.Cors = hatypes.Cors{Enabled: true, AllowCredentials: true, AllowOrigin: []string{"https://example.com"}}
+			},
+		},
+		// 7
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+				p.HSTS = hatypes.HSTS{Enabled: true, MaxAge: 15768000, Subdomains: true, Preload: true}
+			},
+			expected: []string{
+				"hsts-preload-short-max-age on backend 'default_app_8080': path 'host.local/' asks for hsts preload with max-age 15768000, below 31536000",
+			},
+		},
+		// 8
+		{
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+				p.HSTS = hatypes.HSTS{Enabled: true, MaxAge: 31536000, Preload: true}
+			},
+			expected: []string{
+				"hsts-preload-without-subdomains on backend 'default_app_8080': path 'host.local/' asks for hsts preload without including subdomains",
+			},
+		},
+		// 9
+		{
+			backend: func(b *hatypes.Backend) {
+				b.Server.MaxConn = 1
+				b.Endpoints = []*hatypes.Endpoint{{IP: "172.17.0.11"}, {IP: "172.17.0.12"}}
+			},
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+			},
+			expected: []string{
+				"maxconn-server-below-replicas on backend 'default_app_8080': maxconn-server 1 is below the number of replicas 2",
+			},
+		},
+		// 10
+		{
+			backend: func(b *hatypes.Backend) {
+				b.Server.Secure = true
+			},
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+			},
+			expected: []string{
+				"secure-backend-without-ca on backend 'default_app_8080': secure backend does not verify the server certificate",
+			},
+		},
+		// 11
+		{
+			backend: func(b *hatypes.Backend) {
+				b.Server.Secure = true
+				b.Server.CAFilename = "/var/haproxy/ssl/ca.pem"
+			},
+			path: func(p *hatypes.BackendPath) {
+				p.SSLRedirect = true
+			},
+		},
+		// 12
+		{
+			backend: func(b *hatypes.Backend) {
+				b.Server.Secure = true
+			},
+			path: func(p *hatypes.BackendPath) {
+				p.AuthHTTP.UserlistName = "default_basic1"
+			},
+			disabled: []string{"auth-basic-without-ssl-redirect", "secure-backend-without-ca"},
+		},
+	}
+	source := &Source{Namespace: "default", Name: "ing1", Type: "ingress"}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createBackendMappingData("default/app", source, map[string]string{}, nil, []string{"/"})
+		if test.backend != nil {
+			test.backend(d.backend)
+		}
+		if test.path != nil {
+			for _, path := range d.backend.Paths {
+				test.path(path)
+			}
+		}
+		c.haproxy.Global().LintDisabledRules = test.disabled
+		var actual []string
+		for _, finding := range c.createUpdater().Lint([]*hatypes.Backend{d.backend}) {
+			actual = append(actual, finding.String())
+		}
+		c.compareObjects("lint", i, actual, test.expected)
+		c.teardown()
+	}
+}
+
+func TestLintDisabledRules(t *testing.T) {
+	testCases := []struct {
+		disabled string
+		expected []string
+		logging  string
+	}{
+		// 0
+		{
+			disabled: "",
+		},
+		// 1
+		{
+			disabled: "hsts-preload-short-max-age, maxconn-server-below-replicas",
+			expected: []string{"hsts-preload-short-max-age", "maxconn-server-below-replicas"},
+		},
+		// 2
+		{
+			disabled: "secure-backend-without-ca,no-such-rule",
+			expected: []string{"secure-backend-without-ca"},
+			logging:  `WARN ignoring unknown lint rule on lint-disabled-rules config: no-such-rule`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		d := c.createGlobalData(map[string]string{ingtypes.GlobalLintDisabledRules: test.disabled})
+		c.createUpdater().buildGlobalLint(d)
+		c.compareObjects("lint disabled rules", i, d.global.LintDisabledRules, test.expected)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
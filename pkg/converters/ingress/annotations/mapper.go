@@ -28,12 +28,17 @@ import (
 
 type ConfigValueGetter interface {
 	Get(key string) *ConfigValue
+	GetDefault(key string) *ConfigValue
 }
 
 // MapBuilder ...
 type MapBuilder struct {
 	logger      types.Logger
+	metrics     types.Metrics
 	annDefaults map[string]string
+	class       string
+	limits      *limits
+	usage       convtypes.AnnotationUsage
 }
 
 // Mapper ...
@@ -57,9 +62,11 @@ type PathConfig struct {
 
 // Source ...
 type Source struct {
-	Namespace string
-	Name      string
-	Type      convtypes.ResourceType
+	Namespace  string
+	Name       string
+	Type       convtypes.ResourceType
+	UID        string
+	Generation int64
 }
 
 // ConfigValue ...
@@ -69,13 +76,56 @@ type ConfigValue struct {
 }
 
 // NewMapBuilder ...
-func NewMapBuilder(logger types.Logger, annDefaults map[string]string) *MapBuilder {
+//
+// metrics is optional, if not nil it counts the configuration keys that
+// were dropped due to a conflict, an override, a scope mismatch or an
+// annotation limit.
+func NewMapBuilder(logger types.Logger, metrics types.Metrics, annDefaults map[string]string) *MapBuilder {
 	return &MapBuilder{
 		logger:      logger,
+		metrics:     metrics,
 		annDefaults: annDefaults,
 	}
 }
 
+// WithLimits enforces the annotation limits of the converter options on
+// the annotations added to the mappers created by this builder.
+func (b *MapBuilder) WithLimits(options *convtypes.ConverterOptions) *MapBuilder {
+	b.limits = newLimits(options)
+	return b
+}
+
+// WithUsage counts the configuration keys declared by ingress resources on
+// the mappers created by this builder. Default values are not counted.
+func (b *MapBuilder) WithUsage(usage convtypes.AnnotationUsage) *MapBuilder {
+	b.usage = usage
+	return b
+}
+
+// WithDefaults returns a copy of this builder whose default values are
+// overridden by the ones found in ann. The current builder is not changed.
+func (b *MapBuilder) WithDefaults(ann map[string]string) *MapBuilder {
+	annDefaults := make(map[string]string, len(b.annDefaults)+len(ann))
+	for key, value := range b.annDefaults {
+		annDefaults[key] = value
+	}
+	for key, value := range ann {
+		annDefaults[key] = value
+	}
+	builder := *b
+	builder.annDefaults = annDefaults
+	return &builder
+}
+
+// WithClass returns a copy of this builder whose mappers configure the
+// hosts of the IngressClass named class, used to label the dropped keys.
+// The current builder is not changed.
+func (b *MapBuilder) WithClass(class string) *MapBuilder {
+	builder := *b
+	builder.class = class
+	return &builder
+}
+
 // NewMapper ...
 func (b *MapBuilder) NewMapper() *Mapper {
 	return &Mapper{
@@ -100,6 +150,16 @@ func (c *Mapper) addAnnotation(source *Source, path *hatypes.PathLink, key, valu
 		// empty means default value, cannot register as an annotation
 		panic("path link cannot be empty")
 	}
+	value, ok := c.limits.checkValue(source, key, value)
+	if !ok {
+		c.countDropped(source, key)
+		return false
+	}
+	if source != nil && scopes[key] == scopeGlobal {
+		c.logger.Warn("ignoring global configuration key '%s' on %s: global keys are only read from the global config", key, source)
+		c.countDropped(source, key)
+		return false
+	}
 	// check overlap
 	config, configfound := c.configByPath[path.Hash()]
 	if !configfound {
@@ -108,7 +168,11 @@ func (c *Mapper) addAnnotation(source *Source, path *hatypes.PathLink, key, valu
 	}
 	if cv, found := config.keys[key]; found {
 		// there is a conflict only if values differ
-		return cv.Value != value
+		conflict := cv.Value != value
+		if conflict {
+			c.dropped("conflict", key, source, cv.Source)
+		}
+		return conflict
 	}
 	// validate (bool; int; ...) and normalize (int "01" => "1"; ...)
 	realValue := value
@@ -123,6 +187,22 @@ func (c *Mapper) addAnnotation(source *Source, path *hatypes.PathLink, key, valu
 		Source: source,
 		Value:  realValue,
 	}
+	if scopes[key] == scopeBackend {
+		// backend scoped keys cannot differ between paths, the first valid one wins
+		if configs := c.configByKey[key]; len(configs) > 0 && configs[0].value.Value != realValue {
+			winner := configs[0].value
+			c.logger.Warn("ignoring configuration key '%s' on %s: backend scoped key cannot be declared per path, using the value from %s",
+				key, source, winner.Source)
+			c.dropped("scope", key, source, winner.Source)
+			configValue = winner
+		}
+	} else if scopes[key] == "" {
+		// keys without a scope, eg the tcp service ones, are read once
+		// from the mapper and the first value wins, see Get()
+		if configs := c.configByKey[key]; len(configs) > 0 && configs[0].value.Value != realValue {
+			c.dropped("override", key, source, configs[0].value.Source)
+		}
+	}
 	config.keys[key] = configValue
 	pathConfigs := c.configByKey[key]
 	pathConfigs = append(pathConfigs, &PathConfig{
@@ -136,7 +216,11 @@ func (c *Mapper) addAnnotation(source *Source, path *hatypes.PathLink, key, valu
 // AddAnnotations ...
 func (c *Mapper) AddAnnotations(source *Source, path *hatypes.PathLink, ann map[string]string) (conflicts []string) {
 	conflicts = make([]string, 0, len(ann))
+	countUsage := c.usage != nil && source != nil && source.Type == convtypes.ResourceIngress
 	for key, value := range ann {
+		if countUsage {
+			c.usage.Add(source.Namespace, source.Name, key)
+		}
 		if conflict := c.addAnnotation(source, path, key, value); conflict {
 			conflicts = append(conflicts, key)
 		}
@@ -144,6 +228,15 @@ func (c *Mapper) AddAnnotations(source *Source, path *hatypes.PathLink, ann map[
 	return conflicts
 }
 
+// AddParameters adds the configuration keys of an IngressClass Parameters
+// on behalf of the ingress resource in source. Conflicts are ignored, and
+// the keys are not counted as annotation usage of the ingress resource.
+func (c *Mapper) AddParameters(source *Source, path *hatypes.PathLink, params map[string]string) {
+	for key, value := range params {
+		_ = c.addAnnotation(source, path, key, value)
+	}
+}
+
 func (c *Mapper) findPathConfig(key string) ([]*PathConfig, bool) {
 	configs, found := c.configByKey[key]
 	if found && len(configs) > 0 {
@@ -156,6 +249,25 @@ func (c *Mapper) findPathConfig(key string) ([]*PathConfig, bool) {
 	return nil, false
 }
 
+// dropped reports a configuration key from source that wasn't applied
+// because a distinct value from winner was used instead. Both sources are
+// logged with their UID and generation, see VerboseString().
+func (c *Mapper) dropped(reason, key string, source, winner *Source) {
+	var class string
+	if c.class != "" {
+		class = " class=" + c.class
+	}
+	c.logger.InfoV(2, "dropped configuration key: reason=%s key=%s namespace=%s%s source=%q winner=%q",
+		reason, key, source.namespace(), class, source.VerboseString(), winner.VerboseString())
+	c.countDropped(source, key)
+}
+
+func (c *Mapper) countDropped(source *Source, key string) {
+	if c.metrics != nil && source.namespace() != "" {
+		c.metrics.IncAnnotationDropped(source.namespace(), c.class, key)
+	}
+}
+
 // GetConfig ...
 func (c *Mapper) GetConfig(path *hatypes.PathLink) *KeyConfig {
 	if config, found := c.configByPath[path.Hash()]; found {
@@ -189,15 +301,26 @@ func (c *Mapper) Get(key string) *ConfigValue {
 	return value
 }
 
+// GetDefault returns the default value of a configuration key, ignoring
+// the annotations.
+func (c *Mapper) GetDefault(key string) *ConfigValue {
+	if value, found := c.annDefaults[key]; found {
+		return &ConfigValue{Value: value}
+	}
+	return &ConfigValue{}
+}
+
 // Get ...
 func (c *KeyConfig) Get(key string) *ConfigValue {
 	if value, found := c.keys[key]; found {
 		return value
 	}
-	if value, found := c.mapper.annDefaults[key]; found {
-		return &ConfigValue{Value: value}
-	}
-	return &ConfigValue{}
+	return c.mapper.GetDefault(key)
+}
+
+// GetDefault ...
+func (c *KeyConfig) GetDefault(key string) *ConfigValue {
+	return c.mapper.GetDefault(key)
 }
 
 // String ...
@@ -260,3 +383,23 @@ func (s *Source) String() string {
 	}
 	return fmt.Sprintf("%s '%s'", s.Type, s.FullName())
 }
+
+// VerboseString adds the UID and the generation of the source object, which
+// distinguishes two objects with the same name, e.g. a deleted and recreated one.
+func (s *Source) VerboseString() string {
+	if !s.hasObjectRef() {
+		return s.String()
+	}
+	return fmt.Sprintf("%s (uid '%s', generation %d)", s.String(), s.UID, s.Generation)
+}
+
+func (s *Source) namespace() string {
+	if s == nil {
+		return ""
+	}
+	return s.Namespace
+}
+
+func (s *Source) hasObjectRef() bool {
+	return s != nil && s.UID != ""
+}
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 )
 
 type ann struct {
@@ -52,6 +53,20 @@ var (
 		Namespace: "default",
 		Name:      "ing4",
 	}
+	srcing1uid = &Source{
+		Type:       "ingress",
+		Namespace:  "default",
+		Name:       "ing1",
+		UID:        "7d5f3a1e",
+		Generation: 1,
+	}
+	srcing2uid = &Source{
+		Type:       "ingress",
+		Namespace:  "default",
+		Name:       "ing2",
+		UID:        "c2b7e9f0",
+		Generation: 3,
+	}
 )
 
 func TestAddAnnotation(t *testing.T) {
@@ -60,11 +75,13 @@ func TestAddAnnotation(t *testing.T) {
 	pathPath := hatypes.CreateHostPathLink("domain.local", "/path", hatypes.MatchBegin)
 	pathURL := hatypes.CreateHostPathLink("domain.local", "/url", hatypes.MatchBegin)
 	testCases := []struct {
-		ann     []ann
-		getKey  string
-		expMiss bool
-		expVal  string
-		expLog  string
+		ann        []ann
+		getKey     string
+		expMiss    bool
+		expVal     string
+		expURLVal  string
+		expDropped map[string]int
+		expLog     string
 	}{
 		// 0
 		{
@@ -72,9 +89,12 @@ func TestAddAnnotation(t *testing.T) {
 				{srcing1, pathRoot, "auth-basic", "default/basic1", false},
 				{srcing2, pathURL, "auth-basic", "default/basic2", false},
 			},
-			getKey: "auth-basic",
-			expVal: "default/basic1",
-			expLog: "WARN configuration key 'auth-basic' from ingress 'default/ing1' overrides the same key with distinct value from [ingress 'default/ing2']",
+			getKey:     "auth-basic",
+			expVal:     "default/basic1",
+			expDropped: map[string]int{"default/auth-basic": 1},
+			expLog: `
+INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"
+WARN configuration key 'auth-basic' from ingress 'default/ing1' overrides the same key with distinct value from [ingress 'default/ing2']`,
 		},
 		// 1
 		{
@@ -84,9 +104,14 @@ func TestAddAnnotation(t *testing.T) {
 				{srcing3, pathPath, "auth-basic", "default/basic3", false},
 				{srcing4, pathApp, "auth-basic", "default/basic4", false},
 			},
-			getKey: "auth-basic",
-			expVal: "default/basic1",
-			expLog: "WARN configuration key 'auth-basic' from ingress 'default/ing1' overrides the same key with distinct value from [ingress 'default/ing2' ingress 'default/ing3' ingress 'default/ing4']",
+			getKey:     "auth-basic",
+			expVal:     "default/basic1",
+			expDropped: map[string]int{"default/auth-basic": 3},
+			expLog: `
+INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"
+INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing3'" winner="ingress 'default/ing1'"
+INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing4'" winner="ingress 'default/ing1'"
+WARN configuration key 'auth-basic' from ingress 'default/ing1' overrides the same key with distinct value from [ingress 'default/ing2' ingress 'default/ing3' ingress 'default/ing4']`,
 		},
 		// 2
 		{
@@ -96,9 +121,12 @@ func TestAddAnnotation(t *testing.T) {
 				{srcing3, pathPath, "auth-basic", "default/basic1", false},
 				{srcing4, pathApp, "auth-basic", "default/basic2", false},
 			},
-			getKey: "auth-basic",
-			expVal: "default/basic1",
-			expLog: "WARN configuration key 'auth-basic' from ingress 'default/ing1' overrides the same key with distinct value from [ingress 'default/ing4']",
+			getKey:     "auth-basic",
+			expVal:     "default/basic1",
+			expDropped: map[string]int{"default/auth-basic": 1},
+			expLog: `
+INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing4'" winner="ingress 'default/ing1'"
+WARN configuration key 'auth-basic' from ingress 'default/ing1' overrides the same key with distinct value from [ingress 'default/ing4']`,
 		},
 		// 3
 		{
@@ -106,8 +134,10 @@ func TestAddAnnotation(t *testing.T) {
 				{srcing1, pathRoot, "auth-basic", "default/basic1", false},
 				{srcing2, pathRoot, "auth-basic", "default/basic2", true},
 			},
-			getKey: "auth-basic",
-			expVal: "default/basic1",
+			getKey:     "auth-basic",
+			expVal:     "default/basic1",
+			expDropped: map[string]int{"default/auth-basic": 1},
+			expLog:     `INFO-V(2) dropped configuration key: reason=conflict key=auth-basic namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"`,
 		},
 		// 4
 		{
@@ -124,10 +154,95 @@ func TestAddAnnotation(t *testing.T) {
 			getKey:  "auth-basic",
 			expMiss: true,
 		},
+		// 6
+		{
+			ann: []ann{
+				{srcing1uid, pathRoot, "auth-basic", "default/basic1", false},
+				{srcing2uid, pathURL, "auth-basic", "default/basic2", false},
+			},
+			getKey:     "auth-basic",
+			expVal:     "default/basic1",
+			expDropped: map[string]int{"default/auth-basic": 1},
+			expLog: `
+INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing2' (uid 'c2b7e9f0', generation 3)" winner="ingress 'default/ing1' (uid '7d5f3a1e', generation 1)"
+WARN configuration key 'auth-basic' from ingress 'default/ing1' overrides the same key with distinct value from [ingress 'default/ing2']`,
+		},
+		// 7
+		{
+			ann: []ann{
+				{srcing1uid, pathRoot, "auth-basic", "default/basic1", false},
+				{srcing2uid, pathRoot, "auth-basic", "default/basic2", true},
+			},
+			getKey:     "auth-basic",
+			expVal:     "default/basic1",
+			expDropped: map[string]int{"default/auth-basic": 1},
+			expLog:     `INFO-V(2) dropped configuration key: reason=conflict key=auth-basic namespace=default source="ingress 'default/ing2' (uid 'c2b7e9f0', generation 3)" winner="ingress 'default/ing1' (uid '7d5f3a1e', generation 1)"`,
+		},
+		// 8
+		{
+			ann: []ann{
+				{srcing1, pathRoot, "balance-algorithm", "roundrobin", false},
+				{srcing2, pathURL, "balance-algorithm", "leastconn", false},
+			},
+			getKey:     "balance-algorithm",
+			expVal:     "roundrobin",
+			expURLVal:  "roundrobin",
+			expDropped: map[string]int{"default/balance-algorithm": 1},
+			expLog: `
+WARN ignoring configuration key 'balance-algorithm' on ingress 'default/ing2': backend scoped key cannot be declared per path, using the value from ingress 'default/ing1'
+INFO-V(2) dropped configuration key: reason=scope key=balance-algorithm namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"`,
+		},
+		// 9
+		{
+			ann: []ann{
+				{srcing1, pathRoot, "auth-bruteforce-limit", "ten", false},
+				{srcing2, pathURL, "auth-bruteforce-limit", "10", false},
+				{srcing3, pathPath, "auth-bruteforce-limit", "20", false},
+			},
+			getKey:     "auth-bruteforce-limit",
+			expVal:     "10",
+			expURLVal:  "10",
+			expDropped: map[string]int{"default/auth-bruteforce-limit": 1},
+			expLog: `
+WARN ignoring invalid int expression on ingress 'default/ing1' key 'auth-bruteforce-limit': ten
+WARN ignoring configuration key 'auth-bruteforce-limit' on ingress 'default/ing3': backend scoped key cannot be declared per path, using the value from ingress 'default/ing2'
+INFO-V(2) dropped configuration key: reason=scope key=auth-bruteforce-limit namespace=default source="ingress 'default/ing3'" winner="ingress 'default/ing2'"`,
+		},
+		// 10
+		{
+			ann: []ann{
+				{srcing1, pathRoot, "balance-algorithm", "roundrobin", false},
+				{srcing2, pathURL, "balance-algorithm", "roundrobin", false},
+			},
+			getKey: "balance-algorithm",
+			expVal: "roundrobin",
+		},
+		// 11
+		{
+			ann: []ann{
+				{srcing1, pathRoot, "timeout-client", "10s", false},
+			},
+			getKey:     "timeout-client",
+			expMiss:    true,
+			expDropped: map[string]int{"default/timeout-client": 1},
+			expLog:     `WARN ignoring global configuration key 'timeout-client' on ingress 'default/ing1': global keys are only read from the global config`,
+		},
+		// 12
+		{
+			ann: []ann{
+				{srcing1, pathRoot, "auth-basic", "default/basic1", false},
+				{srcing2, pathURL, "auth-basic", "default/basic2", false},
+			},
+			getKey:     "balance-algorithm",
+			expMiss:    true,
+			expDropped: map[string]int{"default/auth-basic": 1},
+			expLog:     `INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"`,
+		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
-		mapper := NewMapBuilder(c.logger, map[string]string{}).NewMapper()
+		metrics := types_helper.NewMetricsMock()
+		mapper := NewMapBuilder(c.logger, metrics, map[string]string{}).NewMapper()
 		for j, ann := range test.ann {
 			if conflict := mapper.addAnnotation(ann.src, ann.path, ann.key, ann.val); conflict != ann.expConflict {
 				t.Errorf("expect conflict '%t' on '// %d (%d)', but was '%t'", ann.expConflict, i, j, conflict)
@@ -144,6 +259,15 @@ func TestAddAnnotation(t *testing.T) {
 		} else if v.Value != test.expVal {
 			t.Errorf("expect '%s' on '%d', but was '%s'", test.expVal, i, v)
 		}
+		if test.expURLVal != "" {
+			if v := mapper.GetConfig(pathURL).Get(test.getKey); v.Value != test.expURLVal {
+				t.Errorf("expect '%s' on path '%s' on '%d', but was '%s'", test.expURLVal, pathURL.Path(), i, v)
+			}
+		}
+		if test.expDropped == nil {
+			test.expDropped = map[string]int{}
+		}
+		c.compareObjects("dropped", i, metrics.AnnotationsDropped, test.expDropped)
 		c.logger.CompareLogging(test.expLog)
 		c.teardown()
 	}
@@ -157,6 +281,7 @@ func TestGetAnnotation(t *testing.T) {
 		getKey    string
 		expMiss   bool
 		expConfig []*PathConfig
+		expLog    string
 	}{
 		// 0
 		{
@@ -169,6 +294,7 @@ func TestGetAnnotation(t *testing.T) {
 				{path: pathRoot, value: &ConfigValue{Source: srcing1, Value: "default/basic1"}},
 				{path: pathURL, value: &ConfigValue{Source: srcing2, Value: "default/basic2"}},
 			},
+			expLog: `INFO-V(2) dropped configuration key: reason=override key=auth-basic namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"`,
 		},
 		// 1
 		{
@@ -181,6 +307,7 @@ func TestGetAnnotation(t *testing.T) {
 			expConfig: []*PathConfig{
 				{path: pathRoot, value: &ConfigValue{Source: srcing1, Value: "default/basic1"}},
 			},
+			expLog: `INFO-V(2) dropped configuration key: reason=conflict key=auth-basic namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"`,
 		},
 		// 2
 		{
@@ -193,11 +320,12 @@ func TestGetAnnotation(t *testing.T) {
 			expConfig: []*PathConfig{
 				{path: pathRoot, value: &ConfigValue{Source: srcing1, Value: "basic"}},
 			},
+			expLog: `INFO-V(2) dropped configuration key: reason=conflict key=auth-basic namespace=default source="ingress 'default/ing2'" winner="ingress 'default/ing1'"`,
 		},
 	}
 	for i, test := range testCases {
 		c := setup(t)
-		mapper := NewMapBuilder(c.logger, map[string]string{}).NewMapper()
+		mapper := NewMapBuilder(c.logger, nil, map[string]string{}).NewMapper()
 		for j, ann := range test.ann {
 			if conflict := mapper.addAnnotation(ann.src, ann.path, ann.key, ann.val); conflict != ann.expConflict {
 				t.Errorf("expect conflict '%t' on '// %d (%d)', but was '%t'", ann.expConflict, i, j, conflict)
@@ -211,6 +339,7 @@ func TestGetAnnotation(t *testing.T) {
 		} else if !reflect.DeepEqual(pathConfig, test.expConfig) {
 			t.Errorf("expected and actual differ on '%d' - expected: %+v - actual: %+v", i, test.expConfig, pathConfig)
 		}
+		c.logger.CompareLogging(test.expLog)
 		c.teardown()
 	}
 }
@@ -224,68 +353,68 @@ func TestGetDefault(t *testing.T) {
 		// 0
 		{
 			expAnn: map[string]string{
-				"timeout-client": "",
+				"timeout-queue": "",
 			},
 		},
 		// 1
 		{
 			annDefaults: map[string]string{
-				"timeout-client": "10s",
-				"balance":        "roundrobin",
+				"timeout-queue": "10s",
+				"balance":       "roundrobin",
 			},
 			expAnn: map[string]string{
-				"timeout-client": "10s",
-				"balance":        "roundrobin",
+				"timeout-queue": "10s",
+				"balance":       "roundrobin",
 			},
 		},
 		// 2
 		{
 			annDefaults: map[string]string{
-				"timeout-client": "10s",
-				"balance":        "roundrobin",
+				"timeout-queue": "10s",
+				"balance":       "roundrobin",
 			},
 			ann: map[string]string{
 				"balance": "leastconn",
 			},
 			expAnn: map[string]string{
-				"timeout-client": "10s",
-				"balance":        "leastconn",
+				"timeout-queue": "10s",
+				"balance":       "leastconn",
 			},
 		},
 		// 3
 		{
 			annDefaults: map[string]string{
-				"timeout-client": "10s",
-				"balance":        "roundrobin",
+				"timeout-queue": "10s",
+				"balance":       "roundrobin",
 			},
 			ann: map[string]string{
-				"timeout-client": "20s",
+				"timeout-queue": "20s",
 			},
 			expAnn: map[string]string{
-				"timeout-client": "20s",
-				"balance":        "roundrobin",
+				"timeout-queue": "20s",
+				"balance":       "roundrobin",
 			},
 		},
 		// 4
 		{
 			annDefaults: map[string]string{
-				"timeout-client": "10s",
-				"balance":        "roundrobin",
+				"timeout-queue": "10s",
+				"balance":       "roundrobin",
 			},
 			ann: map[string]string{
-				"timeout-client": "30s",
-				"balance":        "leastconn",
+				"timeout-queue": "30s",
+				"balance":       "leastconn",
 			},
 			expAnn: map[string]string{
-				"timeout-client": "30s",
-				"balance":        "leastconn",
+				"timeout-queue": "30s",
+				"balance":       "leastconn",
 			},
 		},
 	}
 	pathRoot := hatypes.CreateHostPathLink("domain.local", "/", hatypes.MatchBegin)
 	for i, test := range testCases {
 		c := setup(t)
-		mapper := NewMapBuilder(c.logger, test.annDefaults).NewMapper()
+		mapper := NewMapBuilder(c.logger, nil, test.annDefaults).NewMapper()
 		mapper.AddAnnotations(&Source{}, pathRoot, test.ann)
 		for key, exp := range test.expAnn {
 			value := mapper.Get(key).Value
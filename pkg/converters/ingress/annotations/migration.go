@@ -0,0 +1,207 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+)
+
+// semanticDiffs are the configuration keys that exist with the same name in
+// other ingress controllers, usually declared with the ingress.kubernetes.io
+// prefix, but whose semantics differ in HAProxy Ingress. Ingress resources
+// migrated from another controller should have these keys reviewed.
+var semanticDiffs = map[string]string{
+	ingtypes.BackAuthURL:              "headers of the auth response are only copied to the request if declared in auth-headers-succeed",
+	ingtypes.BackProxyBodySize:        "defaults to unlimited, a size without suffix is in bytes",
+	ingtypes.BackRewriteTarget:        "replaces the matching path prefix, regex capture groups are not supported",
+	ingtypes.BackWhitelistSourceRange: "an alias of allowlist-source-range",
+}
+
+// MigrationCollector records, per ingress resource, the annotation prefixes
+// declaring each configuration key, so ingress resources can be migrated
+// from one prefix to another, or from another ingress controller. The
+// prefix that wins is the first one in the precedence order. It is safe
+// for concurrent use.
+type MigrationCollector struct {
+	mutex     sync.Mutex
+	prefixes  []string
+	ingresses map[string]*ingressMigration
+}
+
+type ingressMigration struct {
+	namespace string
+	name      string
+	keys      []MigrationKey
+}
+
+// MigrationReport lists how the configuration keys of all the ingress
+// resources are declared. Prefixes is the precedence order, and Differences
+// lists the keys in use whose semantics differ from other controllers.
+type MigrationReport struct {
+	Prefixes    []string            `json:"prefixes"`
+	Ingresses   []MigrationIngress  `json:"ingresses"`
+	Differences []MigrationDiffUsed `json:"differences"`
+}
+
+// MigrationIngress lists the configuration keys of an ingress resource.
+type MigrationIngress struct {
+	Namespace string         `json:"namespace"`
+	Name      string         `json:"name"`
+	Keys      []MigrationKey `json:"keys"`
+}
+
+// MigrationKey is a configuration key declared by an ingress resource.
+// Prefix is the annotation prefix whose value is used, Ignored are other
+// prefixes declaring the same key, and Conflict is true if at least one of
+// the ignored prefixes has a distinct value. Difference describes how the
+// key semantics differ from other controllers, if it differs.
+type MigrationKey struct {
+	Key        string   `json:"key"`
+	Prefix     string   `json:"prefix"`
+	Ignored    []string `json:"ignored,omitempty"`
+	Conflict   bool     `json:"conflict,omitempty"`
+	Difference string   `json:"difference,omitempty"`
+}
+
+// MigrationDiffUsed is a configuration key with a semantic difference, and
+// the number of ingress resources declaring it, per annotation prefix.
+type MigrationDiffUsed struct {
+	Key        string         `json:"key"`
+	Difference string         `json:"difference"`
+	Ingresses  map[string]int `json:"ingresses"`
+}
+
+// NewMigrationCollector creates a collector of annotation prefixes. prefixes
+// is the list of annotation prefixes in the precedence order.
+func NewMigrationCollector(prefixes []string) *MigrationCollector {
+	return &MigrationCollector{
+		prefixes:  prefixes,
+		ingresses: map[string]*ingressMigration{},
+	}
+}
+
+// Add ...
+func (m *MigrationCollector) Add(namespace, name string, ann map[string]string) {
+	keys := m.readKeys(ann)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ingName := namespace + "/" + name
+	if len(keys) == 0 {
+		delete(m.ingresses, ingName)
+		return
+	}
+	m.ingresses[ingName] = &ingressMigration{
+		namespace: namespace,
+		name:      name,
+		keys:      keys,
+	}
+}
+
+func (m *MigrationCollector) readKeys(ann map[string]string) []MigrationKey {
+	keyMap := map[string]*MigrationKey{}
+	values := map[string]string{}
+	for _, prefix := range m.prefixes {
+		for annKey, annValue := range ann {
+			key := strings.TrimPrefix(annKey, prefix+"/")
+			if key == annKey {
+				continue
+			}
+			if k, found := keyMap[key]; found {
+				k.Ignored = append(k.Ignored, prefix)
+				k.Conflict = k.Conflict || values[key] != annValue
+				continue
+			}
+			keyMap[key] = &MigrationKey{
+				Key:        key,
+				Prefix:     prefix,
+				Difference: semanticDiffs[key],
+			}
+			values[key] = annValue
+		}
+	}
+	keys := make([]MigrationKey, 0, len(keyMap))
+	for _, k := range keyMap {
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Key < keys[j].Key
+	})
+	return keys
+}
+
+// Forget ...
+func (m *MigrationCollector) Forget(namespace, name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.ingresses, namespace+"/"+name)
+}
+
+// Reset ...
+func (m *MigrationCollector) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ingresses = map[string]*ingressMigration{}
+}
+
+// Report lists the ingress resources sorted by namespace and name, and the
+// keys with semantic differences sorted by key.
+func (m *MigrationCollector) Report() *MigrationReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	report := &MigrationReport{
+		Prefixes:    m.prefixes,
+		Ingresses:   make([]MigrationIngress, 0, len(m.ingresses)),
+		Differences: []MigrationDiffUsed{},
+	}
+	diffs := map[string]*MigrationDiffUsed{}
+	for _, ing := range m.ingresses {
+		report.Ingresses = append(report.Ingresses, MigrationIngress{
+			Namespace: ing.namespace,
+			Name:      ing.name,
+			Keys:      ing.keys,
+		})
+		for _, k := range ing.keys {
+			if k.Difference == "" {
+				continue
+			}
+			diff, found := diffs[k.Key]
+			if !found {
+				diff = &MigrationDiffUsed{Key: k.Key, Difference: k.Difference, Ingresses: map[string]int{}}
+				diffs[k.Key] = diff
+			}
+			diff.Ingresses[k.Prefix]++
+		}
+	}
+	sort.Slice(report.Ingresses, func(i, j int) bool {
+		ing1, ing2 := &report.Ingresses[i], &report.Ingresses[j]
+		if ing1.Namespace != ing2.Namespace {
+			return ing1.Namespace < ing2.Namespace
+		}
+		return ing1.Name < ing2.Name
+	})
+	for _, diff := range diffs {
+		report.Differences = append(report.Differences, *diff)
+	}
+	sort.Slice(report.Differences, func(i, j int) bool {
+		return report.Differences[i].Key < report.Differences[j].Key
+	})
+	return report
+}
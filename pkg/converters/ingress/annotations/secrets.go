@@ -0,0 +1,216 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// missingSecretSample is the number of resources listed, and notified via
+// events, when reporting a secret that was not found.
+const missingSecretSample = 10
+
+// SecretCache is a convtypes.Cache that memoizes the secret lookups of a
+// single sync, so a secret referenced by several resources is read once.
+// It also aggregates the references to secrets that were not found, so a
+// widely shared secret that goes missing is reported once per sync instead
+// of once per resource. A SecretCache should not be reused between syncs.
+type SecretCache struct {
+	convtypes.Cache
+	logger  types.Logger
+	tracker convtypes.Tracker
+	lookups map[string]*secretLookup
+	missing map[string]*missingSecret
+}
+
+type secretLookup struct {
+	name    string
+	crt     convtypes.CrtFile
+	ca      convtypes.File
+	crl     convtypes.File
+	content []byte
+	data    map[string][]byte
+	err     error
+}
+
+type missingSecret struct {
+	sources []*Source
+	known   map[string]bool
+}
+
+// missingSecretError is the error of a lookup whose secret was not found.
+type missingSecretError struct {
+	name string
+	err  error
+}
+
+func (e *missingSecretError) Error() string {
+	return e.err.Error()
+}
+
+func (e *missingSecretError) Unwrap() error {
+	return e.err
+}
+
+// CheckCrossNamespaceSecret returns an error if a resource declared on
+// namespace references a secret of another namespace, and allow is false.
+// Secrets referenced by global configurations, which do not have a namespace,
+// and secrets read via another protocol, like file://, are not checked.
+func CheckCrossNamespaceSecret(namespace, secretName string, allow bool) error {
+	if allow || namespace == "" || strings.Contains(secretName, "://") {
+		return nil
+	}
+	if secretNamespace, _, found := strings.Cut(secretName, "/"); !found || secretNamespace == namespace {
+		return nil
+	}
+	return fmt.Errorf("reading secret '%s' from namespace '%s' is denied, see cross-namespace-secrets configuration keys", secretName, namespace)
+}
+
+// NewSecretCache ...
+func NewSecretCache(options *convtypes.ConverterOptions) *SecretCache {
+	return &SecretCache{
+		Cache:   options.Cache,
+		logger:  options.Logger,
+		tracker: options.Tracker,
+		lookups: map[string]*secretLookup{},
+		missing: map[string]*missingSecret{},
+	}
+}
+
+// lookup returns the memoized result of a secret lookup, calling read only
+// on the first lookup of the secret. Subsequent lookups only add the
+// tracking that read would add. Secrets read via another protocol, like
+// file://, are not memoized.
+func (c *SecretCache) lookup(kind, defaultNamespace, secretName string, track []convtypes.TrackingRef, read func(l *secretLookup)) *secretLookup {
+	if strings.Contains(secretName, "://") {
+		l := &secretLookup{}
+		read(l)
+		return l
+	}
+	key := kind + ":" + defaultNamespace + ":" + secretName
+	if l, found := c.lookups[key]; found {
+		c.tracker.TrackRefName(track, convtypes.ResourceSecret, l.name)
+		return l
+	}
+	l := &secretLookup{name: secretName}
+	if !strings.Contains(secretName, "/") && defaultNamespace != "" {
+		l.name = defaultNamespace + "/" + secretName
+	}
+	read(l)
+	if apierrors.IsNotFound(l.err) {
+		l.err = &missingSecretError{name: l.name, err: l.err}
+	}
+	c.lookups[key] = l
+	return l
+}
+
+// GetTLSSecretPath ...
+func (c *SecretCache) GetTLSSecretPath(defaultNamespace, secretName string, track []convtypes.TrackingRef) (convtypes.CrtFile, error) {
+	l := c.lookup("tls", defaultNamespace, secretName, track, func(l *secretLookup) {
+		l.crt, l.err = c.Cache.GetTLSSecretPath(defaultNamespace, secretName, track)
+	})
+	return l.crt, l.err
+}
+
+// GetCASecretPath ...
+func (c *SecretCache) GetCASecretPath(defaultNamespace, secretName string, track []convtypes.TrackingRef) (ca, crl convtypes.File, err error) {
+	l := c.lookup("ca", defaultNamespace, secretName, track, func(l *secretLookup) {
+		l.ca, l.crl, l.err = c.Cache.GetCASecretPath(defaultNamespace, secretName, track)
+	})
+	return l.ca, l.crl, l.err
+}
+
+// GetPasswdSecretContent ...
+func (c *SecretCache) GetPasswdSecretContent(defaultNamespace, secretName string, track []convtypes.TrackingRef) ([]byte, error) {
+	l := c.lookup("passwd", defaultNamespace, secretName, track, func(l *secretLookup) {
+		l.content, l.err = c.Cache.GetPasswdSecretContent(defaultNamespace, secretName, track)
+	})
+	return l.content, l.err
+}
+
+// GetPasswdSecretMap ...
+func (c *SecretCache) GetPasswdSecretMap(defaultNamespace, secretName string, track []convtypes.TrackingRef) (map[string][]byte, error) {
+	l := c.lookup("passwdmap", defaultNamespace, secretName, track, func(l *secretLookup) {
+		l.data, l.err = c.Cache.GetPasswdSecretMap(defaultNamespace, secretName, track)
+	})
+	return l.data, l.err
+}
+
+// ReportedMissing registers source as a resource referencing a secret that
+// was not found, if err was caused by a missing secret. It returns true if
+// the missing secret was already reported in this sync, so the caller
+// should not log err again. A nil SecretCache doesn't report anything.
+func (c *SecretCache) ReportedMissing(source *Source, err error) bool {
+	var missingErr *missingSecretError
+	if c == nil || !errors.As(err, &missingErr) {
+		return false
+	}
+	missing := c.missing[missingErr.name]
+	if missing == nil {
+		missing = &missingSecret{known: map[string]bool{}}
+		c.missing[missingErr.name] = missing
+	}
+	reported := len(missing.sources) > 0
+	if id := source.String(); !missing.known[id] {
+		missing.known[id] = true
+		missing.sources = append(missing.sources, source)
+	}
+	return reported
+}
+
+// FlushMissing logs the secrets that were not found and are referenced by
+// more than one resource, and notifies a sample of the ingress resources
+// referencing each of them.
+func (c *SecretCache) FlushMissing() {
+	names := make([]string, 0, len(c.missing))
+	for name := range c.missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sources := c.missing[name].sources
+		count := len(sources)
+		if count > missingSecretSample {
+			sources = sources[:missingSecretSample]
+		}
+		msg := fmt.Sprintf("secret '%s' was not found and is referenced by %d resource(s)", name, count)
+		if count > 1 {
+			sample := make([]string, len(sources))
+			for i, source := range sources {
+				sample[i] = source.String()
+			}
+			if count > len(sources) {
+				sample = append(sample, fmt.Sprintf("and %d more", count-len(sources)))
+			}
+			c.logger.Error("%s: %s", msg, strings.Join(sample, ", "))
+		}
+		for _, source := range sources {
+			if source.Type == convtypes.ResourceIngress && source.Namespace != "" {
+				c.Cache.NotifyIngressWarning(source.FullName(), "SecretNotFound", msg)
+			}
+		}
+	}
+	c.missing = map[string]*missingSecret{}
+}
@@ -18,8 +18,9 @@ package annotations
 
 import (
 	"net"
-	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
@@ -36,17 +37,21 @@ type Updater interface {
 	UpdateTCPHostConfig(tcpPort *hatypes.TCPServicePort, tcpHost *hatypes.TCPServiceHost, mapper *Mapper)
 	UpdateHostConfig(host *hatypes.Host, mapper *Mapper)
 	UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
+	Lint(backends []*hatypes.Backend) []*LintFinding
 }
 
 // NewUpdater ...
 func NewUpdater(haproxy haproxy.Config, options *convtypes.ConverterOptions) Updater {
+	secrets, _ := options.Cache.(*SecretCache)
 	return &updater{
 		haproxy: haproxy,
 		options: options,
 		logger:  options.Logger,
 		cache:   options.Cache,
+		secrets: secrets,
 		tracker: options.Tracker,
 		fakeCA:  options.FakeCAFile,
+		limits:  newLimits(options),
 	}
 }
 
@@ -55,9 +60,13 @@ type updater struct {
 	options *convtypes.ConverterOptions
 	logger  types.Logger
 	cache   convtypes.Cache
+	secrets *SecretCache
 	tracker convtypes.Tracker
 	fakeCA  convtypes.CrtFile
+	limits  *limits
 	srcIPs  map[string][]net.IP
+	authUsr map[string]*authSecretUsers
+	authJWT map[string]*authJWTKeys
 }
 
 type globalData struct {
@@ -82,10 +91,9 @@ type backData struct {
 	mapper  *Mapper
 }
 
-var regexValidTime = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
-
 func (c *updater) validateTime(cfg *ConfigValue) string {
-	if !regexValidTime.MatchString(cfg.Value) {
+	value, err := utils.HAProxyTime(cfg.Value)
+	if err != nil {
 		if cfg.Source != nil {
 			c.logger.Warn("ignoring invalid time format on %v: %s", cfg.Source, cfg.Value)
 		} else if cfg.Value != "" {
@@ -93,7 +101,52 @@ func (c *updater) validateTime(cfg *ConfigValue) string {
 		}
 		return ""
 	}
-	return cfg.Value
+	return value
+}
+
+// validateSize converts a size, see utils.ParseSize, to a number of bytes,
+// warning if the unit was assumed. Invalid sizes are not logged.
+func (c *updater) validateSize(cfg *ConfigValue) (int64, error) {
+	size, unitless, err := utils.ParseSize(cfg.Value)
+	if err != nil {
+		return 0, err
+	}
+	if unitless && size > 0 {
+		if cfg.Source != nil {
+			c.logger.Warn("size without unit on %v, assuming bytes: %s", cfg.Source, cfg.Value)
+		} else {
+			c.logger.Warn("size without unit on global/default config, assuming bytes: %s", cfg.Value)
+		}
+	}
+	return size, nil
+}
+
+// TimeToDuration converts a valid time, see utils.ParseDuration, to a
+// time.Duration.
+func TimeToDuration(value string) (time.Duration, bool) {
+	duration, err := utils.ParseDuration(value)
+	return duration, err == nil
+}
+
+// validateHTTPSPort returns the port number that should be added to https
+// redirects, or zero if redirects should not declare a port.
+func (c *updater) validateHTTPSPort(cfg *ConfigValue) int {
+	if cfg.Value == "" {
+		return 0
+	}
+	port, err := strconv.Atoi(cfg.Value)
+	if err != nil || port <= 0 || port > 65535 {
+		if cfg.Source != nil {
+			c.logger.Warn("ignoring invalid https redirect port on %v: %s", cfg.Source, cfg.Value)
+		} else {
+			c.logger.Warn("ignoring invalid https redirect port on global/default config: %s", cfg.Value)
+		}
+		return 0
+	}
+	if port == 443 {
+		return 0
+	}
+	return port
 }
 
 func (c *updater) validateAllowDeny(d *globalData, key string) (allow bool) {
@@ -106,16 +159,27 @@ func (c *updater) validateAllowDeny(d *globalData, key string) (allow bool) {
 	return allow
 }
 
-func (c *updater) splitCIDR(cidrlist *ConfigValue) []string {
-	allow, deny := c.splitDualCIDR(cidrlist)
+// checkCrossNamespaceSecret checks if the secret referenced by cfg can be
+// read by the resource that declared it.
+func (c *updater) checkCrossNamespaceSecret(cfg *ConfigValue, allow bool) error {
+	if cfg.Source == nil {
+		return nil
+	}
+	return CheckCrossNamespaceSecret(cfg.Source.Namespace, cfg.Value, allow)
+}
+
+func (c *updater) splitCIDR(key string, cidrlist *ConfigValue) []string {
+	allow, deny := c.splitDualCIDR(key, cidrlist)
 	if len(deny) > 0 {
 		c.logger.Warn("ignored deny list of IPs or CIDRs: %v", deny)
 	}
 	return allow
 }
 
-func (c *updater) splitDualCIDR(cidrlist *ConfigValue) (allow, deny []string) {
-	for _, cidr := range utils.Split(cidrlist.Value, ",") {
+func (c *updater) splitDualCIDR(key string, cidrlist *ConfigValue) (allow, deny []string) {
+	cidrs := utils.Split(cidrlist.Value, ",")
+	cidrs = c.limits.checkCIDRs(cidrlist, key, cidrs)
+	for _, cidr := range cidrs {
 		if cidr == "" {
 			continue
 		}
@@ -156,13 +220,15 @@ func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mappe
 	d.global.NoRedirects = utils.Split(mapper.Get(ingtypes.GlobalNoRedirectLocations).String(), ",")
 	d.global.DrainSupport.Drain = mapper.Get(ingtypes.GlobalDrainSupport).Bool()
 	d.global.DrainSupport.Redispatch = mapper.Get(ingtypes.GlobalDrainSupportRedispatch).Bool()
+	d.global.EnableChaos = mapper.Get(ingtypes.GlobalEnableChaos).Bool()
 	d.global.Cookie.Key = mapper.Get(ingtypes.GlobalCookieKey).Value
 	d.global.External.HasLua = mapper.Get(ingtypes.GlobalExternalHasLua).Bool()
 	d.global.External.IsExternal = c.options.IsExternal
-	d.global.LoadServerState = mapper.Get(ingtypes.GlobalLoadServerState).Bool()
+	d.global.LoadServerState = mapper.Get(ingtypes.BackLoadServerState).Bool()
 	d.global.Master.ExitOnFailure = mapper.Get(ingtypes.GlobalMasterExitOnFailure).Bool()
 	d.global.Master.IsMasterWorker = c.options.MasterSocket != ""
 	d.global.Master.WorkerMaxReloads = mapper.Get(ingtypes.GlobalWorkerMaxReloads).Int()
+	d.global.ProductionHardening = mapper.Get(ingtypes.GlobalProductionHardening).Bool()
 	d.global.StrictHost = mapper.Get(ingtypes.GlobalStrictHost).Bool()
 	d.global.UseHTX = mapper.Get(ingtypes.GlobalUseHTX).Bool()
 	//
@@ -171,6 +237,7 @@ func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mappe
 	//
 	c.buildGlobalAcme(d)
 	c.buildGlobalAuthProxy(d)
+	c.buildGlobalBackendNaming(d)
 	c.buildGlobalBind(d)
 	c.buildGlobalCloseSessions(d)
 	c.buildGlobalCustomConfig(d)
@@ -178,21 +245,29 @@ func (c *updater) UpdateGlobalConfig(haproxyConfig haproxy.Config, mapper *Mappe
 	c.buildGlobalDNS(d)
 	c.buildGlobalDynamic(d)
 	c.buildGlobalForwardFor(d)
+	c.buildGlobalHTTPProtocol(d)
 	c.buildGlobalHTTPStoHTTP(d)
+	c.buildGlobalLint(d)
 	c.buildGlobalModSecurity(d)
 	c.buildGlobalPathTypeOrder(d)
 	c.buildGlobalProc(d)
+	c.buildGlobalQUIC(d)
+	c.buildGlobalReloadDefer(d)
 	c.buildSecurity(d)
+	c.buildGlobalSPOEAgents(d)
 	c.buildGlobalSSL(d)
 	c.buildGlobalStats(d)
 	c.buildGlobalSyslog(d)
 	c.buildGlobalTimeout(d)
+	c.buildGlobalTrafficClasses(d)
+	c.buildGlobalUniqueID(d)
 }
 
 func (c *updater) UpdateTCPPortConfig(tcp *hatypes.TCPServicePort, mapper *Mapper) {
 	tcp.CustomConfig = utils.LineToSlice(mapper.Get(ingtypes.TCPConfigTCPService).Value)
 	tcp.LogFormat = mapper.Get(ingtypes.TCPTCPServiceLogFormat).Value
 	tcp.ProxyProt = mapper.Get(ingtypes.TCPTCPServiceProxyProto).Bool()
+	c.buildTCPServiceALPN(tcp, mapper)
 }
 
 func (c *updater) UpdateTCPHostConfig(tcpPort *hatypes.TCPServicePort, tcpHost *hatypes.TCPServiceHost, mapper *Mapper) {
@@ -209,15 +284,20 @@ func (c *updater) UpdateHostConfig(host *hatypes.Host, mapper *Mapper) {
 		host:   host,
 		mapper: mapper,
 	}
-	host.RootRedirect = mapper.Get(ingtypes.HostAppRoot).Value
 	host.Alias.AliasName = mapper.Get(ingtypes.HostServerAlias).Value
 	host.Alias.AliasRegex = mapper.Get(ingtypes.HostServerAliasRegex).Value
 	host.TLS.UseDefaultCrt = mapper.Get(ingtypes.HostSSLAlwaysAddHTTPS).Bool()
 	host.TLS.FollowRedirect = mapper.Get(ingtypes.HostSSLAlwaysFollowRedirect).Bool()
 	host.VarNamespace = mapper.Get(ingtypes.HostVarNamespace).Bool()
+	host.HTTPProtocol.BlockHTTP10 = mapper.Get(ingtypes.HostBlockHTTP10).Bool()
+	host.HTTPProtocol.RequireHostHeader = mapper.Get(ingtypes.HostRequireHostHeader).Bool()
+	c.buildHostAppRoot(data)
+	c.buildHostAudit(data)
 	c.buildHostAuthExternal(data)
 	c.buildHostAuthTLS(data)
 	c.buildHostCertSigner(data)
+	c.buildHostPathNormalization(data)
+	c.buildHostQUIC(data)
 	c.buildHostRedirect(data)
 	c.buildHostSSLPassthrough(data)
 	c.buildHostTLSConfig(data)
@@ -229,33 +309,53 @@ func (c *updater) UpdateBackendConfig(backend *hatypes.Backend, mapper *Mapper)
 		mapper:  mapper,
 	}
 	// TODO check ModeTCP with HTTP annotations
-	backend.BalanceAlgorithm = mapper.Get(ingtypes.BackBalanceAlgorithm).Value
+	backend.BalanceAlgorithm = c.getSafeValue(mapper, ingtypes.BackBalanceAlgorithm).Value
 	backend.Server.MaxConn = mapper.Get(ingtypes.BackMaxconnServer).Int()
 	backend.Server.MaxQueue = mapper.Get(ingtypes.BackMaxQueueServer).Int()
+	backend.LoadServerState = mapper.Get(ingtypes.BackLoadServerState).Bool()
+	backend.ReloadSensitive = mapper.Get(ingtypes.BackReloadSensitive).Bool()
+	c.buildBackendAccessDebug(data)
 	c.buildBackendAffinity(data)
+	c.buildBackendAllDownResponse(data)
 	c.buildBackendAuthExternal(data)
 	c.buildBackendAuthHTTP(data)
+	c.buildBackendAuthJWT(data)
+	c.buildBackendAuthBruteforce(data)
+	c.buildBackendBandwidthLimit(data)
 	c.buildBackendBlueGreenBalance(data)
 	c.buildBackendBlueGreenSelector(data)
 	c.buildBackendBodySize(data)
+	c.buildBackendCacheControl(data)
+	c.buildBackendChaos(data)
 	c.buildBackendCors(data)
 	c.buildBackendCustomConfig(data)
 	c.buildBackendDNS(data)
 	c.buildBackendDynamic(data)
 	c.buildBackendAgentCheck(data)
+	c.buildBackendEarlyHints(data)
+	c.buildBackendFallback(data)
+	c.buildBackendForceClose(data)
+	c.buildBackendForwardedHeaders(data)
 	c.buildBackendHeaders(data)
 	c.buildBackendHealthCheck(data)
 	c.buildBackendHSTS(data)
 	c.buildBackendLimit(data)
 	c.buildBackendOAuth(data)
+	c.buildBackendPodMaintenance(data)
 	c.buildBackendProtocol(data)
 	c.buildBackendProxyProtocol(data)
+	c.buildBackendRetryBudget(data)
 	c.buildBackendRewriteURL(data)
+	c.buildBackendProxyRedirect(data)
 	c.buildBackendServerNaming(data)
 	c.buildBackendSourceAddressIntf(data)
+	c.buildBackendSlowStart(data)
+	c.buildBackendSplitBackends(data)
+	c.buildBackendSPOE(data)
 	c.buildBackendSSL(data)
 	c.buildBackendSSLRedirect(data)
 	c.buildBackendTimeout(data)
+	c.buildBackendTrafficClass(data)
 	c.buildBackendWAF(data)
 	c.buildBackendWhitelistHTTP(data)
 	c.buildBackendWhitelistTCP(data)
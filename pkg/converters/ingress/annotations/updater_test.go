@@ -102,7 +102,7 @@ func TestSplitDualCIDR(t *testing.T) {
 			Source: source,
 			Value:  test.list,
 		}
-		allow, deny := c.createUpdater().splitDualCIDR(cv)
+		allow, deny := c.createUpdater().splitDualCIDR("cidr", cv)
 		c.compareObjects("allow list", i, allow, test.expAllow)
 		c.compareObjects("deny list", i, deny, test.expDeny)
 		c.logger.CompareLogging(test.logging)
@@ -151,7 +151,7 @@ func (c *testConfig) createUpdater() *updater {
 }
 
 func (c *testConfig) createBackendData(svcFullName string, source *Source, ann, annDefault map[string]string) *backData {
-	mapper := NewMapBuilder(c.logger, annDefault).NewMapper()
+	mapper := NewMapBuilder(c.logger, nil, annDefault).NewMapper()
 	mapper.AddAnnotations(source, hatypes.CreateHostPathLink("domain.local", "/", hatypes.MatchBegin), ann)
 	svcName := strings.Split(svcFullName, "/")
 	namespace := svcName[0]
@@ -168,10 +168,24 @@ func (c *testConfig) createBackendData(svcFullName string, source *Source, ann,
 
 const testingHostname = "host.local"
 
-type hostResolver struct{}
+type hostResolver struct {
+	httpOnly    bool
+	sslRedirect *bool
+}
 
 func (h *hostResolver) UseTLS() bool {
-	return true
+	return !h.httpOnly
+}
+
+func (h *hostResolver) HTTPOnly() bool {
+	return h.httpOnly
+}
+
+func (h *hostResolver) SSLRedirect() (redirect, found bool) {
+	if h.sslRedirect == nil {
+		return false, false
+	}
+	return *h.sslRedirect, true
 }
 
 func (c *testConfig) createBackendMappingData(
@@ -203,7 +217,7 @@ func (c *testConfig) createBackendMappingData(
 }
 
 func (c *testConfig) createHostData(source *Source, ann, annDefault map[string]string) *hostData {
-	mapper := NewMapBuilder(c.logger, annDefault).NewMapper()
+	mapper := NewMapBuilder(c.logger, nil, annDefault).NewMapper()
 	mapper.AddAnnotations(source, hatypes.CreateHostPathLink("domain.local", "/", hatypes.MatchBegin), ann)
 	return &hostData{
 		host:   &hatypes.Host{},
@@ -228,6 +242,6 @@ func (c *testConfig) compareText(name string, index int, actual, expected string
 func (c *testConfig) createGlobalData(config map[string]string) *globalData {
 	return &globalData{
 		global: &hatypes.Global{},
-		mapper: NewMapBuilder(c.logger, config).NewMapper(),
+		mapper: NewMapBuilder(c.logger, nil, config).NewMapper(),
 	}
 }
@@ -0,0 +1,109 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"sort"
+	"sync"
+)
+
+// UsageCollector counts the distinct ingress resources declaring each
+// configuration key. Keys are counted after the annotation prefix is
+// removed, so all the prefixes of a key are attributed to the same name.
+// It is safe for concurrent use.
+type UsageCollector struct {
+	mutex      sync.Mutex
+	namespaces bool
+	ingresses  map[string]*ingressUsage
+}
+
+type ingressUsage struct {
+	namespace string
+	keys      map[string]struct{}
+}
+
+// KeyUsage is the number of ingress resources declaring a configuration key.
+// Namespace is only filled if the collector has namespace granularity.
+type KeyUsage struct {
+	Key       string `json:"key"`
+	Namespace string `json:"namespace,omitempty"`
+	Ingresses int    `json:"ingresses"`
+}
+
+// NewUsageCollector creates a collector of configuration keys. If namespaces
+// is true, the keys are counted per namespace of the ingress resources.
+func NewUsageCollector(namespaces bool) *UsageCollector {
+	return &UsageCollector{
+		namespaces: namespaces,
+		ingresses:  map[string]*ingressUsage{},
+	}
+}
+
+// Add ...
+func (u *UsageCollector) Add(namespace, name, key string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	ingName := namespace + "/" + name
+	ing, found := u.ingresses[ingName]
+	if !found {
+		ing = &ingressUsage{namespace: namespace, keys: map[string]struct{}{}}
+		u.ingresses[ingName] = ing
+	}
+	ing.keys[key] = struct{}{}
+}
+
+// Forget ...
+func (u *UsageCollector) Forget(namespace, name string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	delete(u.ingresses, namespace+"/"+name)
+}
+
+// Reset ...
+func (u *UsageCollector) Reset() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.ingresses = map[string]*ingressUsage{}
+}
+
+// Usage lists the configuration keys in use, sorted by key and namespace.
+func (u *UsageCollector) Usage() []KeyUsage {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	type usageKey struct{ key, namespace string }
+	count := map[usageKey]int{}
+	for _, ing := range u.ingresses {
+		var namespace string
+		if u.namespaces {
+			namespace = ing.namespace
+		}
+		for key := range ing.keys {
+			count[usageKey{key: key, namespace: namespace}]++
+		}
+	}
+	usage := make([]KeyUsage, 0, len(count))
+	for k, ingresses := range count {
+		usage = append(usage, KeyUsage{Key: k.key, Namespace: k.namespace, Ingresses: ingresses})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Key != usage[j].Key {
+			return usage[i].Key < usage[j].Key
+		}
+		return usage[i].Namespace < usage[j].Namespace
+	})
+	return usage
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"reflect"
+	"testing"
+
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	types_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestUsageCollector(t *testing.T) {
+	testCases := []struct {
+		namespaces bool
+		forget     []string
+		expected   []KeyUsage
+	}{
+		// 0
+		{
+			expected: []KeyUsage{
+				{Key: "balance-algorithm", Ingresses: 3},
+				{Key: "maxconn-server", Ingresses: 1},
+			},
+		},
+		// 1
+		{
+			namespaces: true,
+			expected: []KeyUsage{
+				{Key: "balance-algorithm", Namespace: "ns1", Ingresses: 2},
+				{Key: "balance-algorithm", Namespace: "ns2", Ingresses: 1},
+				{Key: "maxconn-server", Namespace: "ns2", Ingresses: 1},
+			},
+		},
+		// 2
+		{
+			namespaces: true,
+			forget:     []string{"ing1", "ing3"},
+			expected: []KeyUsage{
+				{Key: "balance-algorithm", Namespace: "ns1", Ingresses: 1},
+			},
+		},
+	}
+	for i, test := range testCases {
+		logger := types_helper.NewLoggerMock(t)
+		usage := NewUsageCollector(test.namespaces)
+		mapper := NewMapBuilder(logger, nil, map[string]string{"initial-weight": "100"}).WithUsage(usage).NewMapper()
+		add := func(namespace, name string, kind convtypes.ResourceType, ann map[string]string) {
+			source := &Source{Namespace: namespace, Name: name, Type: kind}
+			mapper.AddAnnotations(source, hatypes.CreatePathLink("/"+name, hatypes.MatchBegin), ann)
+		}
+		add("ns1", "ing1", convtypes.ResourceIngress, map[string]string{"balance-algorithm": "leastconn"})
+		add("ns1", "ing2", convtypes.ResourceIngress, map[string]string{"balance-algorithm": "leastconn"})
+		add("ns1", "ing2", convtypes.ResourceIngress, map[string]string{"balance-algorithm": "leastconn"})
+		add("ns2", "ing3", convtypes.ResourceIngress, map[string]string{"balance-algorithm": "leastconn", "maxconn-server": "10"})
+		add("ns2", "svc1", convtypes.ResourceService, map[string]string{"maxconn-server": "10"})
+		for _, name := range test.forget {
+			ns := "ns1"
+			if name == "ing3" {
+				ns = "ns2"
+			}
+			usage.Forget(ns, name)
+		}
+		actual := usage.Usage()
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("usage differs on %d - expected: %+v - actual: %+v", i, test.expected, actual)
+		}
+		logger.CompareLogging("")
+	}
+}
@@ -17,12 +17,15 @@ limitations under the License.
 package annotations
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
 type validate struct {
@@ -36,9 +39,28 @@ var (
 	corsOriginRegex  = regexp.MustCompile(`^(https?://[A-Za-z0-9\-\.]*(:[0-9]+)?|\*)$`)
 	corsMethodsRegex = regexp.MustCompile(`^([A-Za-z]+,?\s?)+$`)
 	corsHeadersRegex = regexp.MustCompile(`^([A-Za-z0-9\-\_]+,?\s?)|\*+$`)
+	// printable ascii, except the ones that would break the quoted haproxy value
+	headerValueRegex = regexp.MustCompile(`^[\x20-\x7e]+$`)
 )
 
 var validators = map[string]func(v validate) (string, bool){
+	ingtypes.BackAccessDebugHeader:     validateBool,
+	ingtypes.BackAuthBruteforceBan:     validateTime,
+	ingtypes.BackAuthBruteforceLimit:   validateInt,
+	ingtypes.BackAuthBruteforceWindow:  validateTime,
+	ingtypes.BackBlueGreenAutoPause:    validateBool,
+	ingtypes.BackCacheControl:          validateHeaderValue,
+	ingtypes.BackCacheControlIfMissing: validateBool,
+	ingtypes.BackChaosAbortPercent:     validatePercent,
+	ingtypes.BackChaosAbortStatus: func(v validate) (string, bool) {
+		if status, err := strconv.Atoi(v.value); err == nil && status >= 400 && status <= 599 {
+			return strconv.Itoa(status), true
+		}
+		v.logger.Warn("ignoring invalid chaos abort status on %s: %s", v.source, v.value)
+		return "", false
+	},
+	ingtypes.BackChaosLatency:         validateTime,
+	ingtypes.BackChaosLatencyPercent:  validatePercent,
 	ingtypes.BackCorsAllowCredentials: validateBool,
 	ingtypes.BackCorsAllowHeaders: func(v validate) (string, bool) {
 		if corsHeadersRegex.MatchString(v.value) {
@@ -90,11 +112,505 @@ var validators = map[string]func(v validate) (string, bool){
 		v.logger.Warn("ignoring invalid cors max age on %s: %s", v.source, v.value)
 		return "", false
 	},
+	ingtypes.BackExternalNameSlots:     validateInt,
 	ingtypes.BackHSTS:                  validateBool,
 	ingtypes.BackHSTSMaxAge:            validateInt,
 	ingtypes.BackHSTSPreload:           validateBool,
 	ingtypes.BackHSTSIncludeSubdomains: validateBool,
+	ingtypes.BackOAuthAuthURI: func(v validate) (string, bool) {
+		if strings.HasPrefix(v.value, "/") {
+			return validateURI(v)
+		}
+		v.logger.Warn("ignoring invalid URI on %s key '%s': %s", v.source, v.key, v.value)
+		return "", false
+	},
+	ingtypes.BackOAuthDenyBody: func(v validate) (string, bool) {
+		// the body is declared in a single quoted haproxy string
+		if len(v.value) <= 1024 && headerValueRegex.MatchString(v.value) && !strings.Contains(v.value, "'") {
+			return v.value, true
+		}
+		v.logger.Warn("ignoring invalid oauth deny body on %s: %s", v.source, v.value)
+		return "", false
+	},
+	ingtypes.BackOAuthDenyStatus: func(v validate) (string, bool) {
+		if status, err := strconv.Atoi(v.value); err == nil && status >= 400 && status <= 599 {
+			return strconv.Itoa(status), true
+		}
+		v.logger.Warn("ignoring invalid oauth deny status on %s: %s", v.source, v.value)
+		return "", false
+	},
+	ingtypes.BackOAuthErrorMode: func(v validate) (string, bool) {
+		switch v.value {
+		case "redirect", "deny", "negotiate":
+			return v.value, true
+		}
+		v.logger.Warn("ignoring invalid oauth error mode on %s: %s", v.source, v.value)
+		return "", false
+	},
+	ingtypes.BackOAuthPassAccessToken: validateBool,
+	ingtypes.BackOAuthStartURI:        validateURI,
+	ingtypes.BackProxyRedirectHost:    validateBool,
+	ingtypes.BackRateLimitScope: func(v validate) (string, bool) {
+		switch v.value {
+		case "local", "global":
+			return v.value, true
+		}
+		v.logger.Warn("ignoring invalid rate limit scope on %s key '%s': %s", v.source, v.key, v.value)
+		return "", false
+	},
+	ingtypes.BackReloadSensitive:       validateBool,
+	ingtypes.BackSessionCookieHTTPOnly: validateBool,
+	ingtypes.BackSessionCookieSecure:   validateBool,
 	ingtypes.BackSSLRedirect:           validateBool,
+	ingtypes.BackSurrogateControl:      validateHeaderValue,
+	ingtypes.HostAuthTLSVerifyClient: func(v validate) (string, bool) {
+		switch v.value {
+		case "on", "off", "optional", "optional_no_ca":
+			return v.value, true
+		}
+		v.logger.Warn("ignoring invalid verify client option on %s key '%s': %s", v.source, v.key, v.value)
+		return "", false
+	},
+	ingtypes.HostAuthTLSVerifyDepth: func(v validate) (string, bool) {
+		if res, err := strconv.Atoi(v.value); err == nil && res > 0 {
+			return strconv.Itoa(res), true
+		}
+		v.logger.Warn("ignoring invalid verify depth on %s key '%s': %s", v.source, v.key, v.value)
+		return "", false
+	},
+	ingtypes.HostBlockHTTP10:       validateBool,
+	ingtypes.HostDisableQUIC:       validateBool,
+	ingtypes.HostQUICAltSvcMaxAge:  validateInt,
+	ingtypes.HostRequireHostHeader: validateBool,
+	//
+	ingtypes.GlobalAcmeExpiring:                 validateInt,
+	ingtypes.GlobalAcmeShared:                   validateBool,
+	ingtypes.GlobalAcmeTermsAgreed:              validateBool,
+	ingtypes.GlobalDNSAcceptedPayloadSize:       validateInt,
+	ingtypes.GlobalDNSHoldObsolete:              validateTime,
+	ingtypes.GlobalDNSHoldValid:                 validateTime,
+	ingtypes.GlobalDNSTimeoutRetry:              validateTime,
+	ingtypes.GlobalDrainSupport:                 validateBool,
+	ingtypes.GlobalDrainSupportRedispatch:       validateBool,
+	ingtypes.GlobalEnableChaos:                  validateBool,
+	ingtypes.GlobalEnableIPv6:                   validateBool,
+	ingtypes.GlobalEnableQUIC:                   validateBool,
+	ingtypes.GlobalGracefulPathRemoval:          validateTime,
+	ingtypes.GlobalExternalHasLua:               validateBool,
+	ingtypes.GlobalHealthzPort:                  validateInt,
+	ingtypes.GlobalHTTPPort:                     validateInt,
+	ingtypes.GlobalHTTPSPort:                    validateInt,
+	ingtypes.GlobalMasterExitOnFailure:          validateBool,
+	ingtypes.GlobalMaxConnections:               validateInt,
+	ingtypes.GlobalMissingService:               validateMissingService,
+	ingtypes.GlobalModsecurityTimeoutConnect:    validateTime,
+	ingtypes.GlobalModsecurityTimeoutHello:      validateTime,
+	ingtypes.GlobalModsecurityTimeoutIdle:       validateTime,
+	ingtypes.GlobalModsecurityTimeoutProcessing: validateTime,
+	ingtypes.GlobalModsecurityTimeoutServer:     validateTime,
+	ingtypes.GlobalModsecurityUseCoraza:         validateBool,
+	ingtypes.GlobalNbthread:                     validateInt,
+	ingtypes.GlobalProductionHardening:          validateBool,
+	ingtypes.GlobalPrometheusPort:               validateInt,
+	ingtypes.GlobalRedirectMaxDepth:             validateInt,
+	ingtypes.GlobalReloadDeferMax:               validateTime,
+	ingtypes.GlobalReloadDeferSessions:          validateInt,
+	ingtypes.GlobalSSLModeAsync:                 validateBool,
+	ingtypes.GlobalStatsPort:                    validateInt,
+	ingtypes.GlobalStatsProxyProtocol:           validateBool,
+	ingtypes.GlobalStrictHost:                   validateBool,
+	ingtypes.GlobalSyslogLength:                 validateInt,
+	ingtypes.GlobalTimeoutClient:                validateTime,
+	ingtypes.GlobalTimeoutClientFin:             validateTime,
+	ingtypes.GlobalTimeoutStop:                  validateTime,
+	ingtypes.GlobalUseChroot:                    validateBool,
+	ingtypes.GlobalUseCPUMap:                    validateBool,
+	ingtypes.GlobalUseForwardedProto:            validateBool,
+	ingtypes.GlobalUseHAProxyUser:               validateBool,
+	ingtypes.GlobalUseHTX:                       validateBool,
+	ingtypes.GlobalUseProxyProtocol:             validateBool,
+	ingtypes.GlobalWorkerMaxReloads:             validateInt,
+}
+
+// keyScope is the resource a configuration key is applied to.
+type keyScope string
+
+const (
+	scopeGlobal  keyScope = "global"
+	scopeHost    keyScope = "host"
+	scopeBackend keyScope = "backend"
+	scopePath    keyScope = "path"
+)
+
+// IsPathScoped returns true if key is a configuration key of the Path scope.
+func IsPathScoped(key string) bool {
+	return scopes[key] == scopePath
+}
+
+// scopes declares the scope of the configuration keys. Backend scoped keys
+// declared with distinct values on paths of the same backend use the first
+// valid one, and global scoped keys are only read from the global config.
+// Keys not declared here are not checked.
+var scopes = map[string]keyScope{
+	ingtypes.BackAccessDebugHeader:      scopeBackend,
+	ingtypes.BackAffinity:               scopeBackend,
+	ingtypes.BackAffinityFailover:       scopeBackend,
+	ingtypes.BackAffinityHeaderName:     scopeBackend,
+	ingtypes.BackAffinityTableExpire:    scopeBackend,
+	ingtypes.BackAffinityTableSize:      scopeBackend,
+	ingtypes.BackAffinityURLParam:       scopeBackend,
+	ingtypes.BackAgentCheckAddr:         scopeBackend,
+	ingtypes.BackAgentCheckInterval:     scopeBackend,
+	ingtypes.BackAgentCheckPort:         scopeBackend,
+	ingtypes.BackAgentCheckSend:         scopeBackend,
+	ingtypes.BackAllDownResponse:        scopeBackend,
+	ingtypes.BackAssignBackendServerID:  scopeBackend,
+	ingtypes.BackAuthBruteforceBan:      scopeBackend,
+	ingtypes.BackAuthBruteforceLimit:    scopeBackend,
+	ingtypes.BackAuthBruteforceWindow:   scopeBackend,
+	ingtypes.BackAuthTLSCertHeader:      scopeBackend,
+	ingtypes.BackBackendCheckInterval:   scopeBackend,
+	ingtypes.BackBackendProtocol:        scopeBackend,
+	ingtypes.BackBackendServerNaming:    scopeBackend,
+	ingtypes.BackBackendServerSlotsInc:  scopeBackend,
+	ingtypes.BackBalanceAlgorithm:       scopeBackend,
+	ingtypes.BackBandwidthLimitAllowTCP: scopeBackend,
+	ingtypes.BackBandwidthLimitDownload: scopeBackend,
+	ingtypes.BackBandwidthLimitScope:    scopeBackend,
+	ingtypes.BackBandwidthLimitUpload:   scopeBackend,
+	ingtypes.BackBlueGreenAutoPause:     scopeBackend,
+	ingtypes.BackBlueGreenBalance:       scopeBackend,
+	ingtypes.BackBlueGreenCookie:        scopeBackend,
+	ingtypes.BackBlueGreenDeploy:        scopeBackend,
+	ingtypes.BackBlueGreenHeader:        scopeBackend,
+	ingtypes.BackBlueGreenMode:          scopeBackend,
+	ingtypes.BackBlueGreenPaused:        scopeBackend,
+	ingtypes.BackBlueGreenThreshold:     scopeBackend,
+	ingtypes.BackChaosAbortPercent:      scopeBackend,
+	ingtypes.BackChaosAbortStatus:       scopeBackend,
+	ingtypes.BackChaosLatency:           scopeBackend,
+	ingtypes.BackChaosLatencyPercent:    scopeBackend,
+	ingtypes.BackConfigBackend:          scopeBackend,
+	ingtypes.BackDenylistClass:          scopeBackend,
+	ingtypes.BackDNSHoldValid:           scopeBackend,
+	ingtypes.BackDNSResolvePrefer:       scopeBackend,
+	ingtypes.BackDynamicScaling:         scopeBackend,
+	ingtypes.BackExternalNameSlots:      scopeBackend,
+	ingtypes.BackFallbackBackend:        scopeBackend,
+	ingtypes.BackFallbackToTerminating:  scopeBackend,
+	ingtypes.BackForceCloseUserAgents:   scopeBackend,
+	ingtypes.BackForwardedHeaders:       scopeBackend,
+	ingtypes.BackForwardedHostHdr:       scopeBackend,
+	ingtypes.BackForwardedPortHdr:       scopeBackend,
+	ingtypes.BackHeaders:                scopeBackend,
+	ingtypes.BackHealthCheckAddr:        scopeBackend,
+	ingtypes.BackHealthCheckFallCount:   scopeBackend,
+	ingtypes.BackHealthCheckInterval:    scopeBackend,
+	ingtypes.BackHealthCheckPort:        scopeBackend,
+	ingtypes.BackHealthCheckRiseCount:   scopeBackend,
+	ingtypes.BackHealthCheckURI:         scopeBackend,
+	ingtypes.BackInitialWeight:          scopeBackend,
+	ingtypes.BackLimitConnections:       scopeBackend,
+	ingtypes.BackLimitRPS:               scopeBackend,
+	ingtypes.BackLimitWhitelist:         scopeBackend,
+	ingtypes.BackLoadServerState:        scopeBackend,
+	ingtypes.BackMaxconnServer:          scopeBackend,
+	ingtypes.BackMaxQueueServer:         scopeBackend,
+	ingtypes.BackNoAuthLocations:        scopeBackend,
+	ingtypes.BackOAuthSkipPaths:         scopeBackend,
+	ingtypes.BackPodMaintenanceKey:      scopeBackend,
+	ingtypes.BackProxyProtocol:          scopeBackend,
+	ingtypes.BackRateLimitExemptClass:   scopeBackend,
+	ingtypes.BackRateLimitScope:         scopeBackend,
+	ingtypes.BackReloadSensitive:        scopeBackend,
+	ingtypes.BackRetryBudgetWarn:        scopeBackend,
+	ingtypes.BackSecureBackends:         scopeBackend,
+	ingtypes.BackSecureCrtSecret:        scopeBackend,
+	ingtypes.BackSecureSNI:              scopeBackend,
+	ingtypes.BackSecureVerifyCASecret:   scopeBackend,
+	ingtypes.BackSecureVerifyHostname:   scopeBackend,
+	ingtypes.BackServiceUpstream:        scopeBackend,
+	ingtypes.BackSessionCookieDomain:    scopeBackend,
+	ingtypes.BackSessionCookieDynamic:   scopeBackend,
+	ingtypes.BackSessionCookieDynKey:    scopeBackend,
+	ingtypes.BackSessionCookieHTTPOnly:  scopeBackend,
+	ingtypes.BackSessionCookieKeywords:  scopeBackend,
+	ingtypes.BackSessionCookieMaxIdle:   scopeBackend,
+	ingtypes.BackSessionCookieMaxLife:   scopeBackend,
+	ingtypes.BackSessionCookieName:      scopeBackend,
+	ingtypes.BackSessionCookiePreserve:  scopeBackend,
+	ingtypes.BackSessionCookieSameSite:  scopeBackend,
+	ingtypes.BackSessionCookieSecure:    scopeBackend,
+	ingtypes.BackSessionCookieShared:    scopeBackend,
+	ingtypes.BackSessionCookieStrategy:  scopeBackend,
+	ingtypes.BackSessionCookieValue:     scopeBackend,
+	ingtypes.BackSlotsMinFree:           scopeBackend,
+	ingtypes.BackSlowStart:              scopeBackend,
+	ingtypes.BackSourceAddressIntf:      scopeBackend,
+	ingtypes.BackSPOEAgent:              scopeBackend,
+	ingtypes.BackSPOEOnError:            scopeBackend,
+	ingtypes.BackSSLCiphersBackend:      scopeBackend,
+	ingtypes.BackSSLCipherSuitesBackend: scopeBackend,
+	ingtypes.BackSSLFingerprintLower:    scopeBackend,
+	ingtypes.BackSSLFingerprintSha2Bits: scopeBackend,
+	ingtypes.BackSSLOptionsBackend:      scopeBackend,
+	ingtypes.BackTimeoutConnect:         scopeBackend,
+	ingtypes.BackTimeoutHTTPRequest:     scopeBackend,
+	ingtypes.BackTimeoutKeepAlive:       scopeBackend,
+	ingtypes.BackTimeoutQueue:           scopeBackend,
+	ingtypes.BackTimeoutServerFin:       scopeBackend,
+	ingtypes.BackTimeoutTunnel:          scopeBackend,
+	ingtypes.BackUseBackendClass:        scopeBackend,
+	ingtypes.BackUseResolver:            scopeBackend,
+	ingtypes.BackWarmUpAbortCapacity:    scopeBackend,
+	ingtypes.BackWarmUpMinCapacity:      scopeBackend,
+	ingtypes.BackWarmUpWindow:           scopeBackend,
+
+	ingtypes.BackAllowlistSourceHeader: scopePath,
+	ingtypes.BackAllowlistSourceRange:  scopePath,
+	ingtypes.BackAuthCacheDenyDuration: scopePath,
+	ingtypes.BackAuthCacheDuration:     scopePath,
+	ingtypes.BackAuthCacheKey:          scopePath,
+	ingtypes.BackAuthCacheSize:         scopePath,
+	ingtypes.BackAuthExternalPlacement: scopePath,
+	ingtypes.BackAuthHeadersFail:       scopePath,
+	ingtypes.BackAuthHeadersRequest:    scopePath,
+	ingtypes.BackAuthHeadersSucceed:    scopePath,
+	ingtypes.BackAuthJWTAudience:       scopePath,
+	ingtypes.BackAuthJWTIssuer:         scopePath,
+	ingtypes.BackAuthJWTSecret:         scopePath,
+	ingtypes.BackAuthMethod:            scopePath,
+	ingtypes.BackAuthProxyHeaders:      scopePath,
+	ingtypes.BackAuthRealm:             scopePath,
+	ingtypes.BackAuthSecret:            scopePath,
+	ingtypes.BackAuthSecretType:        scopePath,
+	ingtypes.BackAuthSignin:            scopePath,
+	ingtypes.BackAuthSigninHTMLOnly:    scopePath,
+	ingtypes.BackAuthSigninRedirParam:  scopePath,
+	ingtypes.BackAuthType:              scopePath,
+	ingtypes.BackAuthURL:               scopePath,
+	ingtypes.BackCacheControl:          scopePath,
+	ingtypes.BackCacheControlIfMissing: scopePath,
+	ingtypes.BackCookieAutoSecure:      scopePath,
+	ingtypes.BackCorsAllowCredentials:  scopePath,
+	ingtypes.BackCorsAllowHeaders:      scopePath,
+	ingtypes.BackCorsAllowMethods:      scopePath,
+	ingtypes.BackCorsAllowOrigin:       scopePath,
+	ingtypes.BackCorsAllowOriginRegex:  scopePath,
+	ingtypes.BackCorsEnable:            scopePath,
+	ingtypes.BackCorsExposeHeaders:     scopePath,
+	ingtypes.BackCorsMaxAge:            scopePath,
+	ingtypes.BackDenylistSourceRange:   scopePath,
+	ingtypes.BackEarlyHints:            scopePath,
+	ingtypes.BackHSTS:                  scopePath,
+	ingtypes.BackHSTSIncludeSubdomains: scopePath,
+	ingtypes.BackHSTSMaxAge:            scopePath,
+	ingtypes.BackHSTSPreload:           scopePath,
+	ingtypes.BackHTTPHeaderMatch:       scopePath,
+	ingtypes.BackHTTPHeaderMatchRegex:  scopePath,
+	ingtypes.BackOAuth:                 scopePath,
+	ingtypes.BackOAuthAuthURI:          scopePath,
+	ingtypes.BackOAuthBackend:          scopePath,
+	ingtypes.BackOAuthCookieDomain:     scopePath,
+	ingtypes.BackOAuthDenyBody:         scopePath,
+	ingtypes.BackOAuthDenyStatus:       scopePath,
+	ingtypes.BackOAuthErrorMode:        scopePath,
+	ingtypes.BackOAuthHeaders:          scopePath,
+	ingtypes.BackOAuthPassAccessToken:  scopePath,
+	ingtypes.BackOAuthSetSecure:        scopePath,
+	ingtypes.BackOAuthStartURI:         scopePath,
+	ingtypes.BackOAuthURIPrefix:        scopePath,
+	ingtypes.BackPathType:              scopePath,
+	ingtypes.BackProxyBodySize:         scopePath,
+	ingtypes.BackProxyRedirect:         scopePath,
+	ingtypes.BackProxyRedirectHost:     scopePath,
+	ingtypes.BackRedirectTo:            scopePath,
+	ingtypes.BackRewriteTarget:         scopePath,
+	ingtypes.BackSplitBackends:         scopePath,
+	ingtypes.BackSSLRedirect:           scopePath,
+	ingtypes.BackSurrogateControl:      scopePath,
+	ingtypes.BackTimeoutServer:         scopePath,
+	ingtypes.BackWAF:                   scopePath,
+	ingtypes.BackWAFMode:               scopePath,
+	ingtypes.BackWhitelistSourceRange:  scopePath,
+
+	ingtypes.HostAcmePreferredChain:      scopeHost,
+	ingtypes.HostAppRoot:                 scopeHost,
+	ingtypes.HostAuditBackend:            scopeHost,
+	ingtypes.HostAuditSamplePercent:      scopeHost,
+	ingtypes.HostAuthTLSErrorPage:        scopeHost,
+	ingtypes.HostAuthTLSSecret:           scopeHost,
+	ingtypes.HostAuthTLSStrict:           scopeHost,
+	ingtypes.HostAuthTLSVerifyClient:     scopeHost,
+	ingtypes.HostAuthTLSVerifyDepth:      scopeHost,
+	ingtypes.HostBlockHTTP10:             scopeHost,
+	ingtypes.HostCertSigner:              scopeHost,
+	ingtypes.HostDisableQUIC:             scopeHost,
+	ingtypes.HostHTTPOnly:                scopeHost,
+	ingtypes.HostHTTPSRedirectPort:       scopeHost,
+	ingtypes.HostPathNormalization:       scopeHost,
+	ingtypes.HostQUICAltSvcMaxAge:        scopeHost,
+	ingtypes.HostRedirectFrom:            scopeHost,
+	ingtypes.HostRedirectFromRegex:       scopeHost,
+	ingtypes.HostRequireHostHeader:       scopeHost,
+	ingtypes.HostServerAlias:             scopeHost,
+	ingtypes.HostServerAliasRegex:        scopeHost,
+	ingtypes.HostSSLAlwaysAddHTTPS:       scopeHost,
+	ingtypes.HostSSLAlwaysFollowRedirect: scopeHost,
+	ingtypes.HostSSLCiphers:              scopeHost,
+	ingtypes.HostSSLCipherSuites:         scopeHost,
+	ingtypes.HostSSLOptionsHost:          scopeHost,
+	ingtypes.HostSSLPassthrough:          scopeHost,
+	ingtypes.HostSSLPassthroughHTTPPort:  scopeHost,
+	ingtypes.HostSSLRedirectHost:         scopeHost,
+	ingtypes.HostTLSALPN:                 scopeHost,
+	ingtypes.HostVarNamespace:            scopeHost,
+
+	ingtypes.GlobalAcmeEmails:                   scopeGlobal,
+	ingtypes.GlobalAcmeEndpoint:                 scopeGlobal,
+	ingtypes.GlobalAcmeExpiring:                 scopeGlobal,
+	ingtypes.GlobalAcmeShared:                   scopeGlobal,
+	ingtypes.GlobalAcmeTermsAgreed:              scopeGlobal,
+	ingtypes.GlobalAuthLogFormat:                scopeGlobal,
+	ingtypes.GlobalAuthProxy:                    scopeGlobal,
+	ingtypes.GlobalBackendNaming:                scopeGlobal,
+	ingtypes.GlobalBindFrontingProxy:            scopeGlobal,
+	ingtypes.GlobalBindHTTP:                     scopeGlobal,
+	ingtypes.GlobalBindHTTPS:                    scopeGlobal,
+	ingtypes.GlobalBindIPAddrHealthz:            scopeGlobal,
+	ingtypes.GlobalBindIPAddrHTTP:               scopeGlobal,
+	ingtypes.GlobalBindIPAddrPrometheus:         scopeGlobal,
+	ingtypes.GlobalBindIPAddrStats:              scopeGlobal,
+	ingtypes.GlobalBindIPAddrTCP:                scopeGlobal,
+	ingtypes.GlobalCloseSessionsDuration:        scopeGlobal,
+	ingtypes.GlobalConfigDefaults:               scopeGlobal,
+	ingtypes.GlobalConfigFrontend:               scopeGlobal,
+	ingtypes.GlobalConfigFrontendEarly:          scopeGlobal,
+	ingtypes.GlobalConfigFrontendLate:           scopeGlobal,
+	ingtypes.GlobalConfigGlobal:                 scopeGlobal,
+	ingtypes.GlobalConfigProxy:                  scopeGlobal,
+	ingtypes.GlobalConfigSections:               scopeGlobal,
+	ingtypes.GlobalConfigTCP:                    scopeGlobal,
+	ingtypes.GlobalCookieKey:                    scopeGlobal,
+	ingtypes.GlobalCPUMap:                       scopeGlobal,
+	ingtypes.GlobalCrossNamespaceSecrets:        scopeGlobal,
+	ingtypes.GlobalCrossNamespaceSecretsCA:      scopeGlobal,
+	ingtypes.GlobalCrossNamespaceSecretsCrt:     scopeGlobal,
+	ingtypes.GlobalCrossNamespaceSecretsPasswd:  scopeGlobal,
+	ingtypes.GlobalCrossNamespaceServices:       scopeGlobal,
+	ingtypes.GlobalDefaultBackendRedirect:       scopeGlobal,
+	ingtypes.GlobalDefaultBackendRedirectCode:   scopeGlobal,
+	ingtypes.GlobalDNSAcceptedPayloadSize:       scopeGlobal,
+	ingtypes.GlobalDNSClusterDomain:             scopeGlobal,
+	ingtypes.GlobalDNSHoldObsolete:              scopeGlobal,
+	ingtypes.GlobalDNSResolvers:                 scopeGlobal,
+	ingtypes.GlobalDNSTimeoutRetry:              scopeGlobal,
+	ingtypes.GlobalDrainSupport:                 scopeGlobal,
+	ingtypes.GlobalDrainSupportRedispatch:       scopeGlobal,
+	ingtypes.GlobalEnableChaos:                  scopeGlobal,
+	ingtypes.GlobalEnableIPv6:                   scopeGlobal,
+	ingtypes.GlobalEnableQUIC:                   scopeGlobal,
+	ingtypes.GlobalExternalHasLua:               scopeGlobal,
+	ingtypes.GlobalForwardfor:                   scopeGlobal,
+	ingtypes.GlobalFrontingProxyPort:            scopeGlobal,
+	ingtypes.GlobalGracefulPathRemoval:          scopeGlobal,
+	ingtypes.GlobalGroupname:                    scopeGlobal,
+	ingtypes.GlobalHealthzPort:                  scopeGlobal,
+	ingtypes.GlobalHTTPLogFormat:                scopeGlobal,
+	ingtypes.GlobalHTTPPort:                     scopeGlobal,
+	ingtypes.GlobalHTTPProtocolExemptPaths:      scopeGlobal,
+	ingtypes.GlobalHTTPResponse200:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse400:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse401:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse403:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse404:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse405:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse407:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse408:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse410:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse413:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse421:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse425:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse429:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse495:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse496:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse500:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse501:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse502:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse503:              scopeGlobal,
+	ingtypes.GlobalHTTPResponse504:              scopeGlobal,
+	ingtypes.GlobalHTTPResponsePrometheusRoot:   scopeGlobal,
+	ingtypes.GlobalHTTPSLogFormat:               scopeGlobal,
+	ingtypes.GlobalHTTPSPort:                    scopeGlobal,
+	ingtypes.GlobalHTTPStoHTTPPort:              scopeGlobal,
+	ingtypes.GlobalLintDisabledRules:            scopeGlobal,
+	ingtypes.GlobalMasterExitOnFailure:          scopeGlobal,
+	ingtypes.GlobalMaxConnections:               scopeGlobal,
+	ingtypes.GlobalMissingService:               scopeGlobal,
+	ingtypes.GlobalModsecurityArgs:              scopeGlobal,
+	ingtypes.GlobalModsecurityEndpoints:         scopeGlobal,
+	ingtypes.GlobalModsecurityTimeoutConnect:    scopeGlobal,
+	ingtypes.GlobalModsecurityTimeoutHello:      scopeGlobal,
+	ingtypes.GlobalModsecurityTimeoutIdle:       scopeGlobal,
+	ingtypes.GlobalModsecurityTimeoutProcessing: scopeGlobal,
+	ingtypes.GlobalModsecurityTimeoutServer:     scopeGlobal,
+	ingtypes.GlobalModsecurityUseCoraza:         scopeGlobal,
+	ingtypes.GlobalNbprocBalance:                scopeGlobal,
+	ingtypes.GlobalNbprocSSL:                    scopeGlobal,
+	ingtypes.GlobalNbthread:                     scopeGlobal,
+	ingtypes.GlobalNoRedirectLocations:          scopeGlobal,
+	ingtypes.GlobalNoTLSRedirectLocations:       scopeGlobal,
+	ingtypes.GlobalOriginalForwardedForHdr:      scopeGlobal,
+	ingtypes.GlobalPathTypeOrder:                scopeGlobal,
+	ingtypes.GlobalPeersService:                 scopeGlobal,
+	ingtypes.GlobalProductionHardening:          scopeGlobal,
+	ingtypes.GlobalPrometheusPort:               scopeGlobal,
+	ingtypes.GlobalRealIPHdr:                    scopeGlobal,
+	ingtypes.GlobalRedirectFromCode:             scopeGlobal,
+	ingtypes.GlobalRedirectMaxDepth:             scopeGlobal,
+	ingtypes.GlobalRedirectToCode:               scopeGlobal,
+	ingtypes.GlobalReloadDeferMax:               scopeGlobal,
+	ingtypes.GlobalReloadDeferSessions:          scopeGlobal,
+	ingtypes.GlobalSPOEAgents:                   scopeGlobal,
+	ingtypes.GlobalSSLDHDefaultMaxSize:          scopeGlobal,
+	ingtypes.GlobalSSLDHParam:                   scopeGlobal,
+	ingtypes.GlobalSSLEngine:                    scopeGlobal,
+	ingtypes.GlobalSSLHeadersPrefix:             scopeGlobal,
+	ingtypes.GlobalSSLModeAsync:                 scopeGlobal,
+	ingtypes.GlobalSSLOptions:                   scopeGlobal,
+	ingtypes.GlobalSSLRedirectCode:              scopeGlobal,
+	ingtypes.GlobalStatsAuth:                    scopeGlobal,
+	ingtypes.GlobalStatsPort:                    scopeGlobal,
+	ingtypes.GlobalStatsProxyProtocol:           scopeGlobal,
+	ingtypes.GlobalStatsSSLCert:                 scopeGlobal,
+	ingtypes.GlobalStrictHost:                   scopeGlobal,
+	ingtypes.GlobalSyslogEndpoint:               scopeGlobal,
+	ingtypes.GlobalSyslogFormat:                 scopeGlobal,
+	ingtypes.GlobalSyslogLength:                 scopeGlobal,
+	ingtypes.GlobalSyslogTag:                    scopeGlobal,
+	ingtypes.GlobalTCPLogFormat:                 scopeGlobal,
+	ingtypes.GlobalTimeoutClient:                scopeGlobal,
+	ingtypes.GlobalTimeoutClientFin:             scopeGlobal,
+	ingtypes.GlobalTimeoutStop:                  scopeGlobal,
+	ingtypes.GlobalTrafficClasses:               scopeGlobal,
+	ingtypes.GlobalUniqueIDFormat:               scopeGlobal,
+	ingtypes.GlobalUniqueIDResponseHeader:       scopeGlobal,
+	ingtypes.GlobalUseChroot:                    scopeGlobal,
+	ingtypes.GlobalUseCPUMap:                    scopeGlobal,
+	ingtypes.GlobalUseForwardedProto:            scopeGlobal,
+	ingtypes.GlobalUseHAProxyUser:               scopeGlobal,
+	ingtypes.GlobalUseHTX:                       scopeGlobal,
+	ingtypes.GlobalUseProxyProtocol:             scopeGlobal,
+	ingtypes.GlobalUsername:                     scopeGlobal,
+	ingtypes.GlobalWorkerMaxReloads:             scopeGlobal,
+}
+
+// restartKeys are the global config keys whose changes are only applied
+// after the controller restarts.
+var restartKeys = map[string]bool{
+	ingtypes.GlobalCPUMap:    true,
+	ingtypes.GlobalNbthread:  true,
+	ingtypes.GlobalUseCPUMap: true,
 }
 
 func validateBool(v validate) (string, bool) {
@@ -105,6 +621,45 @@ func validateBool(v validate) (string, bool) {
 	return "", false
 }
 
+func validateTime(v validate) (string, bool) {
+	value, err := utils.HAProxyTime(v.value)
+	if err != nil {
+		v.logger.Warn("ignoring invalid time format on %s key '%s': %s", v.source, v.key, v.value)
+		return "", false
+	}
+	return value, true
+}
+
+func validateMissingService(v validate) (string, bool) {
+	switch v.value {
+	case "drop", "serve-503", "retry-fast":
+		return v.value, true
+	}
+	v.logger.Warn("ignoring invalid missing service policy on %s key '%s': %s", v.source, v.key, v.value)
+	return "", false
+}
+
+// validateHeaderValue accepts the value of a header rendered between double
+// quotes in the haproxy configuration: printable chars, except the ones that
+// would terminate the value, start a comment or a log-format expression.
+func validateHeaderValue(v validate) (string, bool) {
+	if headerValueRegex.MatchString(v.value) && !strings.ContainsAny(v.value, "\"\\%#") {
+		return v.value, true
+	}
+	v.logger.Warn("ignoring invalid header value on %s key '%s': %s", v.source, v.key, v.value)
+	return "", false
+}
+
+// validateURI accepts a path or an URL, which is rendered unquoted in the
+// haproxy configuration, so it cannot have spaces or quotes.
+func validateURI(v validate) (string, bool) {
+	if headerValueRegex.MatchString(v.value) && !strings.ContainsAny(v.value, " \"'\\#") {
+		return v.value, true
+	}
+	v.logger.Warn("ignoring invalid URI on %s key '%s': %s", v.source, v.key, v.value)
+	return "", false
+}
+
 func validateInt(v validate) (string, bool) {
 	if res, err := strconv.Atoi(v.value); err == nil {
 		return strconv.Itoa(res), true
@@ -112,3 +667,156 @@ func validateInt(v validate) (string, bool) {
 	v.logger.Warn("ignoring invalid int expression on %s key '%s': %s", v.source, v.key, v.value)
 	return "", false
 }
+
+func validatePercent(v validate) (string, bool) {
+	if res, err := strconv.Atoi(v.value); err == nil && res >= 0 && res <= 100 {
+		return strconv.Itoa(res), true
+	}
+	v.logger.Warn("ignoring invalid percent on %s key '%s', should be an integer between 0 and 100: %s", v.source, v.key, v.value)
+	return "", false
+}
+
+// unsafeValueChar returns the first char of a free-form value that could
+// terminate the line of the haproxy configuration where the value is rendered,
+// or start a comment, so the remaining of the value would be parsed as new
+// directives: control chars, which include line breaks, and the hash sign.
+func unsafeValueChar(value string) (rune, bool) {
+	for _, r := range value {
+		if r < 0x20 || r == 0x7f || r == '#' {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// checkSafeValue verifies if a free-form value, or an item of a list of
+// values, can be rendered in the haproxy configuration. An unsafe value is
+// logged as an error and should be ignored by the caller.
+func (c *updater) checkSafeValue(source *Source, key, value string) bool {
+	if r, found := unsafeValueChar(value); found {
+		c.logger.Error("ignoring '%s' configuration on %v: unsafe char %q in the value", key, source, r)
+		return false
+	}
+	return true
+}
+
+// getSafeValue reads a free-form configuration, which is rendered in the
+// haproxy configuration, returning the default value if it is not safe.
+func (c *updater) getSafeValue(config ConfigValueGetter, key string) *ConfigValue {
+	cfg := config.Get(key)
+	if cfg == nil || c.checkSafeValue(cfg.Source, key, cfg.Value) {
+		return cfg
+	}
+	if def := config.GetDefault(key); def.Value != cfg.Value {
+		if _, found := unsafeValueChar(def.Value); !found {
+			return def
+		}
+	}
+	return &ConfigValue{}
+}
+
+// GlobalConfigStatus lists the global config keys, changed since the last
+// applied global config, by the way they were handled.
+type GlobalConfigStatus struct {
+	Applied  []string
+	Rejected []string
+	Pending  []string
+}
+
+// HasChanges ...
+func (s *GlobalConfigStatus) HasChanges() bool {
+	return len(s.Applied)+len(s.Rejected)+len(s.Pending) > 0
+}
+
+// String ...
+func (s *GlobalConfigStatus) String() string {
+	var status []string
+	add := func(keys []string, state string) {
+		if len(keys) > 0 {
+			status = append(status, fmt.Sprintf("%d key(s) %s: %s", len(keys), state, strings.Join(keys, ", ")))
+		}
+	}
+	add(s.Applied, "applied")
+	add(s.Rejected, "rejected")
+	add(s.Pending, "pending a controller restart")
+	return strings.Join(status, "; ")
+}
+
+// ValidateGlobalConfig validates every key of a global config using the same
+// validators of the annotations, and returns the config that should be
+// applied. applied is the last applied config, or nil if there isn't any,
+// e.g. just after the controller starts. Keys with an invalid value keep the
+// value of the last applied config, or the default value if not found.
+// Changes on keys that need a restart are not applied, they keep the last
+// applied value as well, and are reported as pending.
+func ValidateGlobalConfig(logger types.Logger, applied, config map[string]string) (map[string]string, *GlobalConfigStatus) {
+	result := make(map[string]string, len(config))
+	status := &GlobalConfigStatus{}
+	keep := func(key string) {
+		if prev, found := applied[key]; found {
+			result[key] = prev
+		}
+	}
+	for _, key := range sortedKeys(config) {
+		value := config[key]
+		prev, hasPrev := applied[key]
+		if applied != nil && restartKeys[key] && value != prev {
+			logger.Warn("global config key '%s' was changed and will be applied after the controller restarts", key)
+			keep(key)
+			status.Pending = append(status.Pending, key)
+			continue
+		}
+		if validator, found := validators[key]; found && value != "" {
+			// the validators log a warning, the rejection is logged as an error instead
+			realValue, ok := validator(validate{logger: discardLogger{}, key: key, value: value})
+			if !ok {
+				if hasPrev {
+					logger.Error("ignoring invalid value of global config key '%s': %s, keeping the previous value '%s'", key, value, prev)
+				} else {
+					logger.Error("ignoring invalid value of global config key '%s': %s, using the default value", key, value)
+				}
+				keep(key)
+				status.Rejected = append(status.Rejected, key)
+				continue
+			}
+			value = realValue
+		}
+		result[key] = value
+		if !hasPrev || value != prev {
+			status.Applied = append(status.Applied, key)
+		}
+	}
+	for _, key := range sortedKeys(applied) {
+		if _, found := config[key]; !found {
+			// removed keys, which fall back to the default value
+			if restartKeys[key] {
+				logger.Warn("global config key '%s' was removed and will be applied after the controller restarts", key)
+				keep(key)
+				status.Pending = append(status.Pending, key)
+			} else {
+				status.Applied = append(status.Applied, key)
+			}
+		}
+	}
+	sort.Strings(status.Applied)
+	sort.Strings(status.Rejected)
+	sort.Strings(status.Pending)
+	return result, status
+}
+
+func sortedKeys(config map[string]string) []string {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type discardLogger struct{}
+
+func (discardLogger) InfoV(v int, msg string, args ...interface{}) {}
+func (discardLogger) Info(msg string, args ...interface{})         {}
+func (discardLogger) Warn(msg string, args ...interface{})         {}
+func (discardLogger) Error(msg string, args ...interface{})        {}
+func (discardLogger) Fatal(msg string, args ...interface{})        {}
@@ -28,11 +28,20 @@ const (
 	defaultSSLCipherSuites = "TLS_AES_128_GCM_SHA256:TLS_AES_256_GCM_SHA384:TLS_CHACHA20_POLY1305_SHA256"
 )
 
-func createDefaults() map[string]string {
+// CreateDefaults returns the default value of the configuration keys.
+func CreateDefaults() map[string]string {
 	return map[string]string{
 		types.TCPTCPServiceLogFormat: "default",
 		//
+		types.HostAuditSamplePercent:      "0",
 		types.HostAuthTLSStrict:           "true",
+		types.HostAuthTLSVerifyDepth:      "1",
+		types.HostBlockHTTP10:             "false",
+		types.HostDisableQUIC:             "false",
+		types.HostHTTPOnly:                "false",
+		types.HostPathNormalization:       "off",
+		types.HostQUICAltSvcMaxAge:        "86400",
+		types.HostRequireHostHeader:       "false",
 		types.HostSSLAlwaysAddHTTPS:       "false",
 		types.HostSSLAlwaysFollowRedirect: "true",
 		types.HostSSLCiphers:              defaultSSLCiphers,
@@ -40,30 +49,60 @@ func createDefaults() map[string]string {
 		types.HostSSLOptionsHost:          "",
 		types.HostTLSALPN:                 "h2,http/1.1",
 		//
+		types.BackAccessDebugHeader:      "false",
+		types.BackAffinityFailover:       "redispatch",
+		types.BackAffinityTableExpire:    "30m",
+		types.BackAffinityTableSize:      "200k",
+		types.BackAuthBruteforceBan:      "10m",
+		types.BackAuthBruteforceWindow:   "1m",
+		types.BackAuthCacheKey:           "Authorization",
+		types.BackAuthCacheSize:          "10k",
 		types.BackAuthExternalPlacement:  "backend",
 		types.BackAuthHeadersFail:        "*",
 		types.BackAuthHeadersRequest:     "*",
 		types.BackAuthHeadersSucceed:     "*",
 		types.BackAuthMethod:             "GET",
+		types.BackAuthProxyHeaders:       "X-Forwarded-For,X-Forwarded-Proto,X-Original-URL",
+		types.BackAuthSecretType:         "auth-file",
+		types.BackAuthSigninHTMLOnly:     "true",
+		types.BackAuthSigninRedirParam:   "rd",
 		types.BackBackendServerNaming:    "sequence",
 		types.BackBackendServerSlotsInc:  "1",
 		types.BackSlotsMinFree:           "6",
 		types.BackBalanceAlgorithm:       "roundrobin",
+		types.BackBandwidthLimitAllowTCP: "false",
+		types.BackBandwidthLimitScope:    "connection",
+		types.BackBlueGreenAutoPause:     "false",
+		types.BackBlueGreenThreshold:     "5%",
+		types.BackCacheControlIfMissing:  "false",
+		types.BackCookieAutoSecure:       "true",
 		types.BackCorsAllowHeaders:       "DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Authorization",
 		types.BackCorsAllowMethods:       "GET, PUT, POST, DELETE, PATCH, OPTIONS",
 		types.BackCorsAllowOrigin:        "*",
 		types.BackCorsMaxAge:             "86400",
+		types.BackDNSResolvePrefer:       "ipv4",
 		types.BackDynamicScaling:         "true",
+		types.BackFallbackToTerminating:  "false",
+		types.BackForwardedHostHdr:       "X-Forwarded-Host",
+		types.BackForwardedPortHdr:       "X-Forwarded-Port",
 		types.BackHealthCheckInterval:    "2s",
 		types.BackHSTS:                   "true",
 		types.BackHSTSIncludeSubdomains:  "false",
 		types.BackHSTSMaxAge:             "15768000",
 		types.BackHSTSPreload:            "false",
 		types.BackInitialWeight:          "1",
+		types.BackOAuthDenyStatus:        "401",
+		types.BackOAuthErrorMode:         "redirect",
 		types.BackOAuthHeaders:           "X-Auth-Request-Email",
+		types.BackOAuthPassAccessToken:   "false",
+		types.BackOAuthSetSecure:         "false",
+		types.BackRateLimitScope:         "local",
 		types.BackSessionCookieDynamic:   "true",
+		types.BackSessionCookieHTTPOnly:  "false",
 		types.BackSessionCookiePreserve:  "false",
+		types.BackSessionCookieSecure:    "false",
 		types.BackSessionCookieValue:     "server-name",
+		types.BackSPOEOnError:            "continue",
 		types.BackSSLRedirect:            "true",
 		types.BackSSLCipherSuitesBackend: defaultSSLCipherSuites,
 		types.BackSSLCiphersBackend:      defaultSSLCiphers,
@@ -76,9 +115,12 @@ func createDefaults() map[string]string {
 		types.BackTimeoutServerFin:       "50s",
 		types.BackTimeoutTunnel:          "1h",
 		types.BackWAFMode:                "deny",
+		types.BackWarmUpAbortCapacity:    "25%",
+		types.BackWarmUpMinCapacity:      "50%",
 		//
 		types.GlobalAcmeExpiring:                 "30",
 		types.GlobalAuthProxy:                    "_front__auth__local:14415-14499",
+		types.GlobalBackendNaming:                "namespace-name-port",
 		types.GlobalCookieKey:                    "Ingress",
 		types.GlobalDNSAcceptedPayloadSize:       "8192",
 		types.GlobalDNSClusterDomain:             "cluster.local",
@@ -92,6 +134,7 @@ func createDefaults() map[string]string {
 		types.GlobalHTTPSPort:                    "443",
 		types.GlobalMasterExitOnFailure:          "true",
 		types.GlobalMaxConnections:               "2000",
+		types.GlobalMissingService:               "retry-fast",
 		types.GlobalModsecurityArgs:              "unique-id method path query req.ver req.hdrs_bin req.body_size req.body", // Ref: https://github.com/haproxy/spoa-modsecurity/blob/3c895f3e7dd291dba19d57ba054b277e6fb80ca4/README#L70
 		types.GlobalModsecurityTimeoutConnect:    "5s",
 		types.GlobalModsecurityTimeoutHello:      "100ms",
@@ -103,9 +146,13 @@ func createDefaults() map[string]string {
 		types.GlobalNoTLSRedirectLocations:       "/.well-known/acme-challenge",
 		types.GlobalOriginalForwardedForHdr:      "X-Original-Forwarded-For",
 		types.GlobalPathTypeOrder:                "exact,prefix,begin,regex",
+		types.GlobalProductionHardening:          "false",
 		types.GlobalRealIPHdr:                    "X-Real-IP",
 		types.GlobalRedirectFromCode:             "302",
+		types.GlobalRedirectMaxDepth:             "5",
 		types.GlobalRedirectToCode:               "302",
+		types.GlobalReloadDeferMax:               "5m",
+		types.GlobalReloadDeferSessions:          "0",
 		types.GlobalSSLDHDefaultMaxSize:          "2048",
 		types.GlobalSSLHeadersPrefix:             "X-SSL",
 		types.GlobalSSLOptions:                   defaultSSLOptions,
@@ -116,6 +163,7 @@ func createDefaults() map[string]string {
 		types.GlobalTimeoutClient:                "50s",
 		types.GlobalTimeoutClientFin:             "50s",
 		types.GlobalTimeoutStop:                  "10m",
+		types.GlobalUniqueIDResponseHeader:       "X-Request-ID",
 		types.GlobalUseCPUMap:                    "true",
 		types.GlobalUseForwardedProto:            "true",
 		types.GlobalUseHTX:                       "true",
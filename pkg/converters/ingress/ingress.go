@@ -19,14 +19,17 @@ package ingress
 import (
 	"fmt"
 	"hash/fnv"
+	"net/url"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
@@ -50,45 +53,77 @@ type Config interface {
 // NewIngressConverter ...
 func NewIngressConverter(options *convtypes.ConverterOptions, haproxy haproxy.Config, changed *convtypes.ChangedObjects) Config {
 	if options.DefaultConfig == nil {
-		options.DefaultConfig = createDefaults
+		options.DefaultConfig = CreateDefaults
 	}
 	// IMPLEMENT
 	// config option to allow partial parsing
 	// cache also need to know if partial parsing is enabled
-	globalConfig := changed.GlobalConfigMapDataNew
-	if globalConfig == nil {
-		globalConfig = changed.GlobalConfigMapDataCur
-	}
+	globalConfig := readGlobalConfig(options, changed)
 	defaultConfig := options.DefaultConfig()
 	for key, value := range globalConfig {
 		defaultConfig[key] = value
 	}
+	// secret lookups are memoized during a single sync
+	secrets := annotations.NewSecretCache(options)
+	updaterOptions := *options
+	updaterOptions.Cache = secrets
 	c := &converter{
 		options:            options,
 		haproxy:            haproxy,
 		changed:            changed,
 		logger:             options.Logger,
-		cache:              options.Cache,
+		cache:              secrets,
+		secrets:            secrets,
 		tracker:            options.Tracker,
 		defaultBackSource:  annotations.Source{Name: "<default-backend>", Type: convtypes.ResourceIngress},
-		mapBuilder:         annotations.NewMapBuilder(options.Logger, defaultConfig),
-		updater:            annotations.NewUpdater(haproxy, options),
-		globalConfig:       annotations.NewMapBuilder(options.Logger, defaultConfig).NewMapper(),
+		mapBuilder:         annotations.NewMapBuilder(options.Logger, options.Metrics, defaultConfig).WithLimits(options).WithUsage(options.AnnotationUsage),
+		updater:            annotations.NewUpdater(haproxy, &updaterOptions),
+		globalConfig:       annotations.NewMapBuilder(options.Logger, options.Metrics, defaultConfig).NewMapper(),
 		tcpsvcAnnotations:  map[*hatypes.TCPServicePort]*annotations.Mapper{},
 		hostAnnotations:    map[*hatypes.Host]*annotations.Mapper{},
 		backendAnnotations: map[*hatypes.Backend]*annotations.Mapper{},
 		ingressClasses:     map[string]*ingressClassConfig{},
+		hostClasses:        map[string]*hostClassClaim{},
 	}
+	c.limits = newModelLimits(c)
+	c.ownership = newHostOwnership(c)
+	c.tombstones = newPathTombstones(c)
 	c.readDefaultCertificate()
 	return c
 }
 
+// readGlobalConfig returns the global config that should be applied,
+// validating its keys against the last applied one. Invalid keys, and
+// changes on keys that need a restart, keep their last applied value.
+// The outcome of a changed global config is notified via events.
+func readGlobalConfig(options *convtypes.ConverterOptions, changed *convtypes.ChangedObjects) map[string]string {
+	dynconfig := options.DynamicConfig
+	if changed.GlobalConfigMapDataNew == nil && dynconfig.GlobalConfig != nil {
+		return dynconfig.GlobalConfig
+	}
+	globalConfig := changed.GlobalConfigMapDataNew
+	if globalConfig == nil {
+		globalConfig = changed.GlobalConfigMapDataCur
+	}
+	globalConfig, status := annotations.ValidateGlobalConfig(options.Logger, dynconfig.GlobalConfig, globalConfig)
+	dynconfig.GlobalConfig = globalConfig
+	if changed.GlobalConfigMapDataNew != nil && status.HasChanges() {
+		eventType, reason := api.EventTypeNormal, "GlobalConfigApplied"
+		if len(status.Rejected)+len(status.Pending) > 0 {
+			eventType, reason = api.EventTypeWarning, "GlobalConfigPartiallyApplied"
+		}
+		options.Cache.NotifyGlobalConfigEvent(eventType, reason, status.String())
+	}
+	return globalConfig
+}
+
 type converter struct {
 	options            *convtypes.ConverterOptions
 	haproxy            haproxy.Config
 	changed            *convtypes.ChangedObjects
 	logger             types.Logger
 	cache              convtypes.Cache
+	secrets            *annotations.SecretCache
 	tracker            convtypes.Tracker
 	defaultCrt         convtypes.CrtFile
 	defaultBackSource  annotations.Source
@@ -99,15 +134,21 @@ type converter struct {
 	hostAnnotations    map[*hatypes.Host]*annotations.Mapper
 	backendAnnotations map[*hatypes.Backend]*annotations.Mapper
 	ingressClasses     map[string]*ingressClassConfig
+	hostClasses        map[string]*hostClassClaim
+	limits             *modelLimits
+	ownership          *hostOwnership
+	tombstones         *pathTombstones
 }
 
 func (c *converter) ReadAnnotations(backend *hatypes.Backend, services []*api.Service, pathLinks []*hatypes.PathLink) {
 	mapper := c.mapBuilder.NewMapper()
 	for _, service := range services {
 		source := &annotations.Source{
-			Namespace: service.Namespace,
-			Name:      service.Name,
-			Type:      convtypes.ResourceService,
+			Namespace:  service.Namespace,
+			Name:       service.Name,
+			Type:       convtypes.ResourceService,
+			UID:        string(service.UID),
+			Generation: service.Generation,
 		}
 		_, _, ann := c.readAnnotations(source, service.Annotations)
 		for _, pathLink := range pathLinks {
@@ -120,10 +161,16 @@ func (c *converter) ReadAnnotations(backend *hatypes.Backend, services []*api.Se
 	c.updater.UpdateBackendConfig(backend, mapper)
 }
 
+type hostClassClaim struct {
+	className string
+	source    *annotations.Source
+}
+
 type ingressClassConfig struct {
 	resourceType convtypes.ResourceType
 	resourceName string
 	config       map[string]string
+	mapBuilder   *annotations.MapBuilder
 }
 
 func (c *converter) NeedFullSync() bool {
@@ -136,6 +183,7 @@ func (c *converter) NeedFullSync() bool {
 
 func (c *converter) Sync(full bool) {
 	c.syncDefaultCrt()
+	c.syncPeers()
 	if full {
 		c.syncFull()
 	} else {
@@ -204,18 +252,56 @@ func (c *converter) syncFull() {
 		c.logger.Error("error reading ingress list: %v", err)
 		return
 	}
+	// full syncs rebuild the model, expired tombstones are just not synced
+	_ = c.tombstones.start(ingList)
+	ingList = append(ingList, c.tombstones.ingresses()...)
 	sortIngress(ingList)
+	if c.options.AnnotationUsage != nil {
+		c.options.AnnotationUsage.Reset()
+	}
+	if c.options.AnnotationMigration != nil {
+		c.options.AnnotationMigration.Reset()
+	}
+	c.limits.reset()
+	c.limits.sort(ingList)
+	c.tombstones.sort(ingList)
 	c.updater.UpdateGlobalConfig(c.haproxy, c.globalConfig)
 	c.syncDefaultBackend()
+	c.limits.start()
+	c.ownership.start(ingList, true, nil)
 	for _, ing := range ingList {
 		c.syncIngress(ing)
 	}
+	c.limits.finish()
+	c.ownership.finish()
+	c.tombstones.finish()
 	c.fullSyncAnnotations()
+	c.checkRedirectLoops()
 	c.syncEndpoints()
+	c.lintConfig(true)
+	c.secrets.FlushMissing()
 }
 
 func (c *converter) syncPartial() {
-	c.trackAddedIngress()
+	// paths of expired tombstones are removed like the ones of deleted ingress objects
+	expired := c.tombstones.start(c.changed.IngressesAdd)
+	removed := make([]*networking.Ingress, 0, len(c.changed.IngressesDel)+len(expired))
+	removed = append(removed, c.changed.IngressesDel...)
+	removed = append(removed, expired...)
+	if len(expired) > 0 {
+		if c.changed.Links == nil {
+			c.changed.Links = convtypes.TrackingLinks{}
+		}
+		for _, ing := range expired {
+			c.changed.Links[convtypes.ResourceIngress] = append(c.changed.Links[convtypes.ResourceIngress], ing.Namespace+"/"+ing.Name)
+		}
+	}
+
+	// ingress objects rejected by a model limit are retried,
+	// there might be room for them after the changes
+	c.limits.remove(removed)
+	pendingIngs := c.limits.pending()
+	c.trackAddedIngress(pendingIngs)
 	trackedLinks := c.tracker.QueryLinks(c.changed.Links, true)
 
 	dirtyIngs := trackedLinks[convtypes.ResourceIngress]
@@ -238,8 +324,22 @@ func (c *converter) syncPartial() {
 	for _, ing := range dirtyIngs {
 		ingMap[ing] = nil
 	}
-	for _, ing := range c.changed.IngressesDel {
-		delete(ingMap, ing.Namespace+"/"+ing.Name)
+	for _, ing := range pendingIngs {
+		ingMap[ing.Namespace+"/"+ing.Name] = ing
+	}
+	for _, ing := range removed {
+		name := ing.Namespace + "/" + ing.Name
+		delete(ingMap, name)
+		if c.options.AnnotationUsage != nil {
+			c.options.AnnotationUsage.Forget(ing.Namespace, ing.Name)
+		}
+		if c.options.AnnotationMigration != nil {
+			c.options.AnnotationMigration.Forget(ing.Namespace, ing.Name)
+		}
+		if tombstone := c.tombstones.ingress(name); tombstone != nil {
+			// deleted on this sync, its paths are still routed
+			ingMap[name] = tombstone
+		}
 	}
 	for _, ing := range c.changed.IngressesAdd {
 		ingMap[ing.Namespace+"/"+ing.Name] = ing
@@ -250,12 +350,13 @@ func (c *converter) syncPartial() {
 			var err error
 			ing, err = c.cache.GetIngress(name)
 			if err != nil {
-				if name == c.defaultBackSource.FullName() {
+				// deleted ingress objects whose paths are still routed have a tombstone
+				ing = c.tombstones.ingress(name)
+				if ing == nil && name == c.defaultBackSource.FullName() {
 					c.syncDefaultBackend()
-				} else {
+				} else if ing == nil {
 					c.logger.Warn("ignoring ingress '%s': %v", name, err)
 				}
-				ing = nil
 			}
 		}
 		if ing != nil {
@@ -265,11 +366,21 @@ func (c *converter) syncPartial() {
 
 	// reinclude changed/added data
 	sortIngress(ingList)
+	c.limits.sort(ingList)
+	c.tombstones.sort(ingList)
+	c.limits.start()
+	c.ownership.start(ingList, false, dirtyHosts)
 	for _, ing := range ingList {
 		c.syncIngress(ing)
 	}
+	c.limits.finish()
+	c.ownership.finish()
+	c.tombstones.finish()
 	c.partialSyncAnnotations()
+	c.checkRedirectLoops()
 	c.syncChangedEndpoints()
+	c.lintConfig(false)
+	c.secrets.FlushMissing()
 }
 
 // trackAddedIngress add tracking hostnames and backends to new ingress objects
@@ -283,9 +394,14 @@ func (c *converter) syncPartial() {
 // before real sync starts and just before calculate dirty objects - if an
 // existent host or back is tracked only by an added ingress, it is tracked
 // here and removed before parse the added ingress which will readd such hosts
-// and backs
-func (c *converter) trackAddedIngress() {
-	for _, ing := range append(c.changed.IngressesAdd, c.changed.IngressesUpd...) {
+// and backs. Ingress objects that were rejected by a model limit and are
+// being retried are tracked the same way.
+func (c *converter) trackAddedIngress(pending []*networking.Ingress) {
+	ingList := make([]*networking.Ingress, 0, len(c.changed.IngressesAdd)+len(c.changed.IngressesUpd)+len(pending))
+	ingList = append(ingList, c.changed.IngressesAdd...)
+	ingList = append(ingList, c.changed.IngressesUpd...)
+	ingList = append(ingList, pending...)
+	for _, ing := range ingList {
 		name := ing.Namespace + "/" + ing.Name
 		if ing.Spec.DefaultBackend != nil {
 			backend := c.findBackend(ing.Namespace, ing.Spec.DefaultBackend)
@@ -325,7 +441,7 @@ func (c *converter) findBackend(namespace string, backend *networking.IngressBac
 	if port == nil {
 		return nil
 	}
-	return c.haproxy.Backends().FindBackend(namespace, svcName, port.TargetPort.String())
+	return c.haproxy.Backends().FindBackend(namespace, svcName, c.backendPort(port))
 }
 
 // normalizeHostname adjusts the hostname according to the following rules:
@@ -358,11 +474,35 @@ func sortIngress(ingress []*networking.Ingress) {
 
 func (c *converter) syncIngress(ing *networking.Ingress) {
 	source := &annotations.Source{
-		Namespace: ing.Namespace,
-		Name:      ing.Name,
-		Type:      convtypes.ResourceIngress,
+		Namespace:  ing.Namespace,
+		Name:       ing.Name,
+		Type:       convtypes.ResourceIngress,
+		UID:        string(ing.UID),
+		Generation: ing.Generation,
+	}
+	if c.options.AnnotationUsage != nil {
+		// counted again from scratch, keys might have been removed
+		c.options.AnnotationUsage.Forget(ing.Namespace, ing.Name)
+	}
+	if c.options.AnnotationMigration != nil {
+		// collected even if the ingress is rejected, all the declared keys should be migrated
+		c.options.AnnotationMigration.Add(ing.Namespace, ing.Name, ing.Annotations)
+	}
+	if !c.limits.admit(ing) {
+		// rejected before anything is acquired, so
+		// the ingress doesn't change the model at all
+		return
 	}
 	annTCP, annHost, annBack := c.readAnnotations(source, ing.Annotations)
+	if resources, total := countResourceBackends(ing, annBack[ingtypes.BackRedirectTo] != ""); resources > 0 {
+		c.logger.Error("skipping %d resource backend(s) of %v: %v", resources, source, errResourceBackend)
+		c.cache.NotifyIngressWarning(source.FullName(), "UnsupportedBackend", errResourceBackend.Error())
+		if resources == total {
+			// nothing to be configured, skipping the ingress so it
+			// does not create hosts or tcp services without backend
+			return
+		}
+	}
 	tcpServicePort, _ := strconv.Atoi(annTCP[ingtypes.TCPTCPServicePort])
 	if tcpServicePort == 0 {
 		c.syncIngressHTTP(source, ing, annHost, annBack)
@@ -372,12 +512,29 @@ func (c *converter) syncIngress(ing *networking.Ingress) {
 }
 
 func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.Ingress, annHost, annBack map[string]string) {
+	// a hostname can be declared by several rules and tls entries of the
+	// same ingress, its class is checked once so a conflict is reported once
+	var ingClass *networking.IngressClass
+	var ingClassRead bool
+	hostClaims := map[string]bool{}
+	claimHostClass := func(hostname string) (*networking.IngressClass, bool) {
+		if !ingClassRead {
+			ingClass = c.readIngressClass(source, ing.Spec.IngressClassName)
+			ingClassRead = true
+		}
+		claimed, found := hostClaims[hostname]
+		if !found {
+			claimed = c.claimHostClass(hostname, source, ingClass)
+			hostClaims[hostname] = claimed
+		}
+		return ingClass, claimed
+	}
 	if ing.Spec.DefaultBackend != nil {
 		svcName, svcPort, err := readServiceNamePort(ing.Spec.DefaultBackend)
 		if err == nil {
 			err = c.addDefaultHostBackend(source, ing.Namespace+"/"+svcName, svcPort, annHost, annBack)
 		}
-		if err != nil {
+		if err != nil && err != errResourceBackend {
 			c.logger.Warn("skipping default backend of %v: %v", source, err)
 		}
 	}
@@ -386,9 +543,15 @@ func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.
 			continue
 		}
 		hostname := normalizeHostname(rule.Host, 0)
-		ingressClass := c.readIngressClass(source, ing.Spec.IngressClassName)
+		if !c.ownership.claim(hostname, source) {
+			continue
+		}
+		ingressClass, claimed := claimHostClass(hostname)
+		if !claimed {
+			continue
+		}
 		sslpassthrough, _ := strconv.ParseBool(annHost[ingtypes.HostSSLPassthrough])
-		host := c.addHost(hostname, source, annHost)
+		host := c.addHost(hostname, source, ingressClass, annHost)
 		for _, path := range rule.HTTP.Paths {
 			uri := path.Path
 			if uri == "" {
@@ -408,14 +571,21 @@ func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.
 					continue
 				}
 			} else if host.FindPathWithLink(pathLink) != nil {
-				c.logger.Warn("skipping redeclared path '%s' type '%s' on %v", uri, match, source)
+				if !c.tombstones.claimed(source, hostname, uri) {
+					c.logger.Warn("skipping redeclared path '%s' type '%s' on %v", uri, match, source)
+				}
 				continue
 			}
 			if redirectTo := annBack[ingtypes.BackRedirectTo]; redirectTo != "" {
 				host.AddRedirect(uri, match, redirectTo)
+				host.AddRedirectIntent(hatypes.RedirectTo, hatypes.CreateHostPathLink(hostname, uri, match), redirectTo, source.String())
 				continue
 			}
 			svcName, svcPort, err := readServiceNamePort(&path.Backend)
+			if err == errResourceBackend {
+				// already reported by syncIngress()
+				continue
+			}
 			if err != nil {
 				c.logger.Warn("skipping backend config of %v: %v", source, err)
 				continue
@@ -423,10 +593,14 @@ func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.
 			fullSvcName := ing.Namespace + "/" + svcName
 			backend, err := c.addBackendWithClass(source, pathLink, fullSvcName, svcPort, annBack, ingressClass)
 			if err != nil {
-				c.logger.Warn("skipping backend config of %v: %v", source, err)
-				continue
+				backend = c.addMissingServiceBackend(source, pathLink, fullSvcName, svcPort, err)
+				if backend == nil {
+					c.logger.Warn("skipping backend config of %v: %v", source, err)
+					continue
+				}
 			}
 			host.AddLink(backend, pathLink)
+			c.tombstones.deprecate(source, backend, pathLink)
 			sslpasshttpport := annHost[ingtypes.HostSSLPassthroughHTTPPort]
 			if sslpassthrough && sslpasshttpport != "" {
 				if _, err := c.addBackend(source, pathLink, fullSvcName, sslpasshttpport, annBack); err != nil {
@@ -453,12 +627,91 @@ func (c *converter) syncIngressHTTP(source *annotations.Source, ing *networking.
 					}
 				}
 			}
+			// pre-building the oauth backend, see the fallback counterpart
+			if oauth := annBack[ingtypes.BackOAuthBackend]; oauth != "" {
+				if namespace, name, port, err := ingutils.ParseServiceOrBackend(oauth); err == nil {
+					if namespace == "" {
+						namespace = ing.Namespace
+					}
+					_, err := c.addBackend(source, pathLink, namespace+"/"+name, port, map[string]string{})
+					if err != nil {
+						c.logger.Warn("skipping oauth-backend on %v: %v", source, err)
+					}
+				}
+			}
+			// pre-building the audit backend, see the fallback counterpart
+			if audit := annHost[ingtypes.HostAuditBackend]; audit != "" {
+				if namespace, name, port, err := ingutils.ParseServicePort(audit); err == nil {
+					if namespace == "" {
+						namespace = ing.Namespace
+					}
+					_, err := c.addBackend(source, pathLink, namespace+"/"+name, port, map[string]string{})
+					if err != nil {
+						c.logger.Warn("skipping audit-backend on %v: %v", source, err)
+					}
+				}
+			}
+			// pre-building the fallback backend, it should exist even if
+			// no ingress exposes it directly
+			if fallback := annBack[ingtypes.BackFallbackBackend]; fallback != "" {
+				if namespace, name, port, err := ingutils.ParseServicePort(fallback); err == nil {
+					if namespace == "" {
+						namespace = ing.Namespace
+					}
+					_, err := c.addBackend(source, pathLink, namespace+"/"+name, port, map[string]string{})
+					if err != nil {
+						c.logger.Warn("skipping fallback-backend on %v: %v", source, err)
+					}
+				}
+			}
+			// pre-building the backends of the traffic class routes,
+			// see the fallback counterpart
+			for _, route := range utils.Split(annBack[ingtypes.BackUseBackendClass], ",") {
+				_, svc, _ := strings.Cut(route, "=")
+				if namespace, name, port, err := ingutils.ParseServicePort(svc); err == nil {
+					if namespace == "" {
+						namespace = ing.Namespace
+					}
+					_, err := c.addBackend(source, pathLink, namespace+"/"+name, port, map[string]string{})
+					if err != nil {
+						c.logger.Warn("skipping use-backend-class on %v: %v", source, err)
+					}
+				}
+			}
+			// pre-building the split backends, see the fallback counterpart
+			var annSplit map[string]string
+			for _, split := range utils.Split(annBack[ingtypes.BackSplitBackends], ",") {
+				svc, _, _ := strings.Cut(split, "=")
+				if namespace, name, port, err := ingutils.ParseServicePort(svc); err == nil {
+					if namespace == "" {
+						namespace = ing.Namespace
+					}
+					if annSplit == nil {
+						annSplit = splitBackendAnnotations(annBack)
+					}
+					backend, err := c.addBackend(source, pathLink, namespace+"/"+name, port, annSplit)
+					if err != nil {
+						c.logger.Warn("skipping split-backends on %v: %v", source, err)
+					} else {
+						// the split path is also a path of the split backend,
+						// so the path scoped config is applied there as well
+						backend.AddBackendPath(pathLink)
+					}
+				}
+			}
 		}
 	}
 	for _, tls := range ing.Spec.TLS {
 		// tls secret
 		for _, hostname := range tls.Hosts {
-			host := c.addHost(hostname, source, annHost)
+			if !c.ownership.claim(hostname, source) {
+				continue
+			}
+			ingressClass, claimed := claimHostClass(hostname)
+			if !claimed {
+				continue
+			}
+			host := c.addHost(hostname, source, ingressClass, annHost)
 			tlsPath := c.addTLS(source, tls.SecretName)
 			if host.TLS.TLSHash == "" {
 				host.TLS.TLSFilename = tlsPath.Filename
@@ -533,7 +786,7 @@ func (c *converter) syncIngressTCP(source *annotations.Source, ing *networking.I
 		backend.ModeTCP = true
 		return nil
 	}
-	if ing.Spec.DefaultBackend != nil {
+	if ing.Spec.DefaultBackend != nil && !isResourceBackend(ing.Spec.DefaultBackend) {
 		err := addIngressBackend("", ing.Spec.DefaultBackend)
 		if err != nil {
 			c.logger.Warn("skipping default backend on %v: %v", source, err)
@@ -544,6 +797,10 @@ func (c *converter) syncIngressTCP(source *annotations.Source, ing *networking.I
 			continue
 		}
 		for _, path := range rule.HTTP.Paths {
+			if isResourceBackend(&path.Backend) {
+				// already reported by syncIngress()
+				continue
+			}
 			if path.Path != "" && path.Path != "/" {
 				c.logger.Warn("skipping backend declaration on path '%s' of %v: tcp services do not support path", path.Path, source)
 				continue
@@ -554,6 +811,26 @@ func (c *converter) syncIngressTCP(source *annotations.Source, ing *networking.I
 			}
 		}
 	}
+	// pre-building alpn backends, they should exist even if
+	// no ingress exposes them directly
+	if alpn := annTCP[ingtypes.TCPTCPServiceALPN]; alpn != "" {
+		pathLink := hatypes.CreateHostPathLink(normalizeHostname("", tcpServicePort), "/", hatypes.MatchExact)
+		for _, route := range utils.LineToSlice(alpn) {
+			_, namespace, name, port, err := ingutils.ParseALPNRoute(route)
+			if err != nil {
+				continue
+			}
+			if namespace == "" {
+				namespace = ing.Namespace
+			}
+			backend, err := c.addBackend(source, pathLink, namespace+"/"+name, port, map[string]string{})
+			if err != nil {
+				c.logger.Warn("skipping alpn route on %v: %v", source, err)
+				continue
+			}
+			backend.ModeTCP = true
+		}
+	}
 	for _, tls := range ing.Spec.TLS {
 		secretName := tls.SecretName
 		tcpPort := c.haproxy.TCPServices().FindTCPPort(tcpServicePort)
@@ -586,6 +863,7 @@ func (c *converter) syncEndpoints() {
 }
 
 func (c *converter) syncChangedEndpoints() {
+	c.limits.checkEndpoints()
 	for _, backend := range c.haproxy.Backends().ItemsAdd() {
 		c.syncBackendEndpointCookies(backend)
 		c.syncBackendEndpointHashes(backend)
@@ -618,6 +896,8 @@ func (c *converter) fullSyncAnnotations() {
 			c.updater.UpdateBackendConfig(backend, ann)
 		}
 	}
+	c.checkBackendFallback(c.haproxy.Backends().Items())
+	c.checkSharedCookies()
 }
 
 func (c *converter) partialSyncAnnotations() {
@@ -632,6 +912,360 @@ func (c *converter) partialSyncAnnotations() {
 			c.updater.UpdateBackendConfig(backend, ann)
 		}
 	}
+	c.checkBackendFallback(c.haproxy.Backends().ItemsAdd())
+	c.checkSharedCookies()
+}
+
+// checkBackendFallback walks the fallback chain of the updated backends,
+// removing the fallback of any backend that leads back to itself.
+func (c *converter) checkBackendFallback(items map[string]*hatypes.Backend) {
+	backends := c.haproxy.Backends()
+	ids := make([]string, 0, len(items))
+	for id, backend := range items {
+		if !backend.Fallback.IsEmpty() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		backend := items[id]
+		chain := []string{backend.ID}
+		visited := map[string]bool{backend.ID: true}
+		for fallback := backends.FindBackendID(backend.Fallback); fallback != nil; fallback = backends.FindBackendID(fallback.Fallback) {
+			chain = append(chain, fallback.ID)
+			if fallback == backend {
+				c.logger.Error("circular fallback backend reference, ignoring fallback of backend '%s': %s",
+					backend.ID, strings.Join(chain, " -> "))
+				backend.Fallback = hatypes.BackendID{}
+				break
+			}
+			if visited[fallback.ID] {
+				// a loop that doesn't include this backend, it will be
+				// handled when its own backend is checked
+				break
+			}
+			visited[fallback.ID] = true
+		}
+	}
+}
+
+// checkSharedCookies looks for hosts whose cookie affinity backends share
+// the same cookie via session-cookie-shared, and warns if they do not agree
+// on the cookie name. A host is checked only if all of its cookie affinity
+// backends are configured as shared. Unchanged hosts are also checked, since
+// a conflict can be caused by a backend shared with another host.
+func (c *converter) checkSharedCookies() {
+	backends := c.haproxy.Backends()
+	for _, host := range c.haproxy.Hosts().BuildSortedItems() {
+		var shared []*hatypes.Backend
+		visited := map[*hatypes.Backend]bool{}
+		allShared := true
+		for _, path := range host.Paths {
+			backend := backends.FindBackend(path.Backend.Namespace, path.Backend.Name, path.Backend.Port)
+			if backend == nil || visited[backend] || backend.ModeTCP || backend.Cookie.Name == "" {
+				continue
+			}
+			visited[backend] = true
+			mapper := c.backendAnnotations[backend]
+			if mapper == nil || !mapper.Get(ingtypes.BackSessionCookieShared).Bool() {
+				allShared = false
+				break
+			}
+			shared = append(shared, backend)
+		}
+		if !allShared || len(shared) < 2 {
+			continue
+		}
+		first := shared[0]
+		for _, backend := range shared[1:] {
+			if backend.Cookie.Name != first.Cookie.Name {
+				c.logger.Warn("configuration key '%s' from %s conflicts with the same key with distinct value from %s on the shared cookie of host '%s'",
+					ingtypes.BackSessionCookieName, c.backendAnnotations[backend].Get(ingtypes.BackSessionCookieName).Source,
+					c.backendAnnotations[first].Get(ingtypes.BackSessionCookieName).Source, host.Hostname)
+			}
+		}
+	}
+}
+
+// redirectHop is a node of the redirect graph: the scheme, the hostname
+// and the path of a request.
+type redirectHop struct {
+	https    bool
+	hostname string
+	path     string
+}
+
+func (h redirectHop) String() string {
+	scheme := "http"
+	if h.https {
+		scheme = "https"
+	}
+	return scheme + "://" + h.hostname + h.path
+}
+
+// redirectEdge is a redirect rule that moves a request from one hop to
+// another. intent is nil on ssl-redirect, which is a backend configuration
+// and is never disabled.
+type redirectEdge struct {
+	host   *hatypes.Host
+	intent *hatypes.HostRedirectIntent
+	source string
+}
+
+func (e *redirectEdge) String() string {
+	if e.intent == nil {
+		return "ssl-redirect on " + e.source
+	}
+	return string(e.intent.Kind) + " on " + e.source
+}
+
+// checkRedirectLoops walks the redirect graph built from the redirect intents
+// of all the hosts, and disables the redirects that lead to a loop or to a
+// chain longer than redirect-max-depth hops. Intents disabled in a previous
+// sync are enabled again before the walk, so a fixed loop is emitted again
+// even if some of its hosts weren't changed.
+func (c *converter) checkRedirectLoops() {
+	hosts := c.haproxy.Hosts().BuildSortedItems()
+	redirFrom := make(map[string]*redirectEdge)
+	for _, host := range hosts {
+		for _, intent := range host.RedirectIntents {
+			host.EnableRedirect(intent)
+			if intent.Kind == hatypes.RedirectFrom {
+				redirFrom[intent.Target] = &redirectEdge{host: host, intent: intent, source: intent.Source}
+			}
+		}
+	}
+	maxDepth := c.globalConfig.Get(ingtypes.GlobalRedirectMaxDepth).Int()
+	for _, host := range hosts {
+		for _, intent := range host.RedirectIntents {
+			var start redirectHop
+			switch intent.Kind {
+			case hatypes.RedirectAppRoot:
+				start = redirectHop{hostname: host.Hostname, path: "/"}
+			case hatypes.RedirectFrom:
+				start = redirectHop{hostname: intent.Target, path: "/"}
+			case hatypes.RedirectTo:
+				start = redirectHop{hostname: host.Hostname, path: intent.Link.Path()}
+			}
+			for _, https := range []bool{false, true} {
+				start.https = https
+				c.walkRedirect(start, redirFrom, maxDepth)
+			}
+		}
+	}
+}
+
+func (c *converter) walkRedirect(hop redirectHop, redirFrom map[string]*redirectEdge, maxDepth int) {
+	chain := []redirectHop{hop}
+	var edges []*redirectEdge
+	visited := map[redirectHop]int{hop: 0}
+	for {
+		edge, next := c.nextRedirectHop(hop, redirFrom)
+		if edge == nil {
+			return
+		}
+		edges = append(edges, edge)
+		chain = append(chain, next)
+		if i, found := visited[next]; found {
+			c.disableRedirects("redirect loop", chain[i:], edges[i:])
+			return
+		}
+		if maxDepth > 0 && len(edges) > maxDepth {
+			c.disableRedirects(fmt.Sprintf("redirect chain longer than %d hops", maxDepth), chain, edges)
+			return
+		}
+		visited[next] = len(chain) - 1
+		hop = next
+	}
+}
+
+func (c *converter) disableRedirects(reason string, chain []redirectHop, edges []*redirectEdge) {
+	hops := make([]string, len(chain))
+	for i, hop := range chain {
+		hops[i] = hop.String()
+	}
+	sources := make([]string, len(edges))
+	for i, edge := range edges {
+		sources[i] = edge.String()
+	}
+	c.logger.Error("%s, ignoring the redirects of %s: %s", reason, strings.Join(sources, ", "), strings.Join(hops, " -> "))
+	for _, edge := range edges {
+		if edge.intent != nil {
+			edge.host.DisableRedirect(edge.intent)
+		}
+	}
+}
+
+// nextRedirectHop finds the redirect rule that applies to a request, following
+// the same precedence of the frontend: app-root, redirect-to, redirect-from and
+// finally ssl-redirect. It returns a nil edge if the request isn't redirected.
+func (c *converter) nextRedirectHop(hop redirectHop, redirFrom map[string]*redirectEdge) (*redirectEdge, redirectHop) {
+	host := c.haproxy.Hosts().FindHost(hop.hostname)
+	var path *hatypes.HostPath
+	if host != nil {
+		path = findRedirectPath(host, hop.path)
+		if hop.path == "/" && host.RootRedirect != "" {
+			if edge := c.sslRedirectEdge(host, path, hop); edge != nil {
+				return edge, redirectHop{https: true, hostname: hop.hostname, path: hop.path}
+			}
+			if intent := findRedirectIntent(host, hatypes.RedirectAppRoot, nil); intent != nil {
+				return &redirectEdge{host: host, intent: intent, source: intent.Source}, parseRedirectTarget(hop, host.RootRedirect)
+			}
+		}
+		if path != nil && path.RedirTo != "" {
+			if intent := findRedirectIntent(host, hatypes.RedirectTo, path.Link); intent != nil {
+				return &redirectEdge{host: host, intent: intent, source: intent.Source}, parseRedirectTarget(hop, path.RedirTo)
+			}
+		}
+	}
+	if edge, found := redirFrom[hop.hostname]; found && edge.host.Redirect.RedirectHost == hop.hostname {
+		return edge, redirectHop{https: hop.https, hostname: edge.host.Hostname, path: hop.path}
+	}
+	if host != nil {
+		if edge := c.sslRedirectEdge(host, path, hop); edge != nil {
+			return edge, redirectHop{https: true, hostname: hop.hostname, path: hop.path}
+		}
+	}
+	return nil, hop
+}
+
+func (c *converter) sslRedirectEdge(host *hatypes.Host, path *hatypes.HostPath, hop redirectHop) *redirectEdge {
+	if hop.https || path == nil {
+		return nil
+	}
+	backend := c.haproxy.Backends().Items()[path.Backend.ID]
+	if backend == nil {
+		return nil
+	}
+	if bpath := backend.FindBackendPath(path.Link); bpath == nil || !bpath.SSLRedirect {
+		return nil
+	}
+	var source *annotations.Source
+	if mapper, found := c.backendAnnotations[backend]; found {
+		source = mapper.GetConfig(path.Link).Get(ingtypes.BackSSLRedirect).Source
+	}
+	return &redirectEdge{host: host, source: source.String()}
+}
+
+// findRedirectPath returns the path of a host that handles a request,
+// or nil if no path matches. Paths with header match and regex paths
+// are not evaluated.
+func findRedirectPath(host *hatypes.Host, uri string) *hatypes.HostPath {
+	var found *hatypes.HostPath
+	// host paths are sorted in reverse order, so the longest match is found first
+	for _, path := range host.Paths {
+		if path.Link.ComposeMatch() {
+			continue
+		}
+		p := path.Path()
+		switch path.Match() {
+		case hatypes.MatchExact:
+			if uri == p {
+				return path
+			}
+		case hatypes.MatchPrefix:
+			if found == nil && (uri == p || strings.HasPrefix(uri, strings.TrimSuffix(p, "/")+"/")) {
+				found = path
+			}
+		case hatypes.MatchBegin:
+			if found == nil && strings.HasPrefix(uri, p) {
+				found = path
+			}
+		}
+	}
+	return found
+}
+
+func findRedirectIntent(host *hatypes.Host, kind hatypes.RedirectKind, link *hatypes.PathLink) *hatypes.HostRedirectIntent {
+	for _, intent := range host.RedirectIntents {
+		if intent.Kind == kind && intent.Link.Equals(link) {
+			return intent
+		}
+	}
+	return nil
+}
+
+// parseRedirectTarget builds the hop of a redirect location, which can be
+// an absolute URL or a path on the same scheme and hostname of the request.
+func parseRedirectTarget(hop redirectHop, location string) redirectHop {
+	u, err := url.Parse(location)
+	if err != nil {
+		return hop
+	}
+	next := hop
+	if u.Scheme != "" {
+		next.https = u.Scheme == "https"
+	}
+	if u.Host != "" {
+		next.hostname = strings.ToLower(u.Hostname())
+	}
+	next.path = u.Path
+	if next.path == "" {
+		next.path = "/"
+	}
+	return next
+}
+
+// lintConfig reports the risky configurations found in the updated backends
+// as warnings and, if this controller is the leader, as events of the ingress
+// resources that use them. A finding is reported once, when it is found in a
+// backend, and is counted by the metrics while it is found in the config.
+func (c *converter) lintConfig(fullSync bool) {
+	dynconfig := c.options.DynamicConfig
+	last := dynconfig.LintFindings
+	current := make(map[string][]convtypes.LintFinding, len(last))
+	items := c.haproxy.Backends().Items()
+	if !fullSync {
+		// findings of the backends that were not changed are preserved
+		added := c.haproxy.Backends().ItemsAdd()
+		for id, findings := range last {
+			if _, changed := added[id]; !changed && items[id] != nil {
+				current[id] = findings
+			}
+		}
+		items = added
+	}
+	backends := make([]*hatypes.Backend, 0, len(items))
+	for _, backend := range items {
+		backends = append(backends, backend)
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		return backends[i].ID < backends[j].ID
+	})
+	isLeader := c.options.IsLeader == nil || c.options.IsLeader()
+	for _, finding := range c.updater.Lint(backends) {
+		id := finding.Backend.ID
+		f := convtypes.LintFinding{Rule: finding.Rule, Message: finding.Message}
+		current[id] = append(current[id], f)
+		if slices.Contains(last[id], f) {
+			continue
+		}
+		c.logger.Warn("lint rule %v", finding)
+		if !isLeader {
+			continue
+		}
+		ingNames := c.tracker.LinkedNames(convtypes.ResourceHABackend, id, convtypes.ResourceIngress)
+		for _, ingName := range ingNames {
+			c.cache.NotifyIngressWarning(ingName, "ConfigLint", fmt.Sprintf("%s: %s", finding.Rule, finding.Message))
+		}
+	}
+	dynconfig.LintFindings = current
+
+	// rules without findings anymore are zeroed
+	count := map[string]int{}
+	for _, findings := range last {
+		for _, f := range findings {
+			count[f.Rule] = 0
+		}
+	}
+	for _, findings := range current {
+		for _, f := range findings {
+			count[f.Rule]++
+		}
+	}
+	for rule, n := range count {
+		c.options.Metrics.SetLintFindings(rule, n)
+	}
 }
 
 func (c *converter) readPathType(path networking.HTTPIngressPath, ann string) hatypes.MatchType {
@@ -691,10 +1325,15 @@ func (c *converter) addDefaultHostBackend(source *annotations.Source, fullSvcNam
 	pathLink := hatypes.CreateHostPathLink(hostname, uri, match)
 	backend, err := c.addBackend(source, pathLink, fullSvcName, svcPort, annBack)
 	if err != nil {
-		c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceService, fullSvcName)
-		return err
+		backend = c.addMissingServiceBackend(source, pathLink, fullSvcName, svcPort, err)
+		if backend == nil {
+			if !c.dropMissingService(err) {
+				c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceService, fullSvcName)
+			}
+			return err
+		}
 	}
-	host := c.addHost(hostname, source, annHost)
+	host := c.addHost(hostname, source, nil, annHost)
 	host.AddPath(backend, uri, match)
 	return nil
 }
@@ -718,18 +1357,48 @@ func (c *converter) addTCPService(source *annotations.Source, hostname string, p
 	return tcpHost, nil
 }
 
-func (c *converter) addHost(hostname string, source *annotations.Source, ann map[string]string) *hatypes.Host {
+// claimHostClass ensures that a hostname is configured only by ingress
+// resources of the same IngressClass, so class scoped Parameters do not
+// leak to hosts of another class. The first ingress claiming a hostname
+// wins; ingress resources without an IngressClass do not claim hostnames.
+func (c *converter) claimHostClass(hostname string, source *annotations.Source, ingressClass *networking.IngressClass) bool {
+	if ingressClass == nil {
+		return true
+	}
+	claim, found := c.hostClasses[hostname]
+	if !found {
+		c.hostClasses[hostname] = &hostClassClaim{className: ingressClass.Name, source: source}
+		return true
+	}
+	if claim.className == ingressClass.Name {
+		return true
+	}
+	// tracking the skipped ingress, so it is resynced if the winner is removed
+	c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceHAHostname, hostname)
+	c.logger.Error("skipping host '%s' of %v on class '%s': host was already claimed by %v on class '%s'",
+		hostname, source, ingressClass.Name, claim.source, claim.className)
+	c.options.Metrics.IncHostClassConflict(ingressClass.Name)
+	return false
+}
+
+func (c *converter) addHost(hostname string, source *annotations.Source, ingressClass *networking.IngressClass, ann map[string]string) *hatypes.Host {
 	// TODO build a stronger tracking
 	host := c.haproxy.Hosts().AcquireHost(hostname)
 	c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceHAHostname, hostname)
 	mapper, found := c.hostAnnotations[host]
 	if !found {
-		mapper = c.mapBuilder.NewMapper()
+		// hosts are claimed by a single IngressClass, so its Parameters
+		// can be used as the default values of the host configuration
+		mapper = c.classMapBuilder(ingressClass).NewMapper()
 		c.hostAnnotations[host] = mapper
 	}
 	conflict := mapper.AddAnnotations(source, hatypes.CreateHostPathLink(hostname, "/", hatypes.MatchExact), ann)
 	if len(conflict) > 0 {
-		c.logger.Warn("skipping host annotation(s) from %v due to conflict: %v", source, conflict)
+		if ingressClass != nil {
+			c.logger.Warn("skipping host annotation(s) from %v on class '%s' due to conflict: %v", source, ingressClass.Name, conflict)
+		} else {
+			c.logger.Warn("skipping host annotation(s) from %v due to conflict: %v", source, conflict)
+		}
 	}
 	return host
 }
@@ -761,13 +1430,77 @@ func (c *converter) addHeaderMatch(source *annotations.Source, pathLink *hatypes
 	}
 }
 
+// splitBackendAnnotations returns the path scoped configuration keys of
+// ann. Requests of a split path are sent straight to the split backends, so
+// they are configured like the path itself, e.g. allowlist and auth, except
+// the split itself.
+func splitBackendAnnotations(ann map[string]string) map[string]string {
+	annSplit := make(map[string]string, len(ann))
+	for key, value := range ann {
+		if key != ingtypes.BackSplitBackends && annotations.IsPathScoped(key) {
+			annSplit[key] = value
+		}
+	}
+	return annSplit
+}
+
 func (c *converter) addBackend(source *annotations.Source, pathLink *hatypes.PathLink, fullSvcName, svcPort string, ann map[string]string) (*hatypes.Backend, error) {
 	return c.addBackendWithClass(source, pathLink, fullSvcName, svcPort, ann, nil)
 }
 
+// addMissingServiceBackend applies the missing-service policy on a path whose
+// service was not found. The ingress is notified in all the policies. It
+// returns the backend the path should be linked to, or nil if the path should
+// be skipped, which is also the case if err was not caused by a missing service.
+func (c *converter) addMissingServiceBackend(source *annotations.Source, pathLink *hatypes.PathLink, fullSvcName, svcPort string, err error) *hatypes.Backend {
+	if !apierrors.IsNotFound(err) {
+		return nil
+	}
+	policy := c.globalConfig.Get(ingtypes.GlobalMissingService).Value
+	var msg string
+	switch policy {
+	case "drop":
+		msg = fmt.Sprintf("service '%s' was not found, path '%s' was dropped", fullSvcName, pathLink.Path())
+	case "serve-503":
+		msg = fmt.Sprintf("service '%s' was not found, path '%s' answers 503 until the service is created", fullSvcName, pathLink.Path())
+	default:
+		msg = fmt.Sprintf("service '%s' was not found, path '%s' will be configured as soon as the service is created", fullSvcName, pathLink.Path())
+	}
+	if source.Type == convtypes.ResourceIngress {
+		c.cache.NotifyIngressWarning(source.FullName(), "ServiceNotFound", msg)
+	}
+	if policy == "drop" {
+		return nil
+	}
+	// tracking the unresolved reference, so the ingress is synced
+	// again as soon as the service is created
+	c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceService, fullSvcName)
+	if policy != "serve-503" {
+		return nil
+	}
+	// a backend without servers, so haproxy answers 503 and
+	// the path is still reserved to this ingress
+	ssvcName := strings.Split(fullSvcName, "/")
+	backend := c.haproxy.Backends().AcquireBackend("_missing", ssvcName[0]+"_"+ssvcName[1], svcPort)
+	c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceHABackend, backend.ID)
+	c.logger.Warn("serving 503 on path '%s' of %v: %v", pathLink.Path(), source, err)
+	return backend
+}
+
+// dropMissingService returns true if err was caused by a missing service and
+// the missing-service policy drops its paths without waiting for the service.
+func (c *converter) dropMissingService(err error) bool {
+	return apierrors.IsNotFound(err) && c.globalConfig.Get(ingtypes.GlobalMissingService).Value == "drop"
+}
+
 func (c *converter) addBackendWithClass(source *annotations.Source, pathLink *hatypes.PathLink, fullSvcName, svcPort string, ann map[string]string, ingressClass *networking.IngressClass) (*hatypes.Backend, error) {
 	// TODO build a stronger tracking
 	svc, err := c.cache.GetService(source.Namespace, fullSvcName)
+	if c.dropMissingService(err) {
+		// missing services aren't tracked, the ingress
+		// is only synced again on the next full sync
+		return nil, err
+	}
 	hostname := pathLink.Hostname()
 	ctx := convtypes.ResourceHAHostname
 	if strings.Contains(hostname, ":") {
@@ -807,7 +1540,7 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink *ha
 			TargetPort: intstr.FromInt(portNumber),
 		}
 	}
-	backend := c.haproxy.Backends().AcquireBackend(namespace, svcName, port.TargetPort.String())
+	backend := c.haproxy.Backends().AcquireBackend(namespace, svcName, c.backendPort(port))
 	c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceHABackend, backend.ID)
 	// TODO converg backend Port and DNSPort; see also tmpl's server-template
 	backend.DNSPort = readDNSPort(svc.Spec.ClusterIP == api.ClusterIPNone, port)
@@ -819,9 +1552,11 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink *ha
 	// Starting with service annotations, giving precedence
 	_, _, svcann := c.readAnnotations(source, svc.Annotations)
 	mapper.AddAnnotations(&annotations.Source{
-		Namespace: namespace,
-		Name:      svcName,
-		Type:      convtypes.ResourceService,
+		Namespace:  namespace,
+		Name:       svcName,
+		Type:       convtypes.ResourceService,
+		UID:        string(svc.UID),
+		Generation: svc.Generation,
 	}, pathLink, svcann)
 	// Merging Ingress annotations
 	conflict := mapper.AddAnnotations(source, pathLink, ann)
@@ -837,7 +1572,7 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink *ha
 			// ignoring conflicts. This would really conflict with other Parameters
 			// only if the same host+path is declared twice, but such duplication is
 			// already filtered out in the ingress parsing.
-			_ = mapper.AddAnnotations(source, pathLink, cfg)
+			mapper.AddParameters(source, pathLink, cfg)
 		}
 	}
 	// Configure endpoints
@@ -858,7 +1593,8 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink *ha
 				c.logger.Error("error adding IP of service '%s': %v", fullSvcName, err)
 			}
 		} else {
-			if err := c.addEndpoints(svc, port, backend); err != nil {
+			fallbackTerminating := mapper.Get(ingtypes.BackFallbackToTerminating).Bool()
+			if err := c.addEndpoints(source, svc, port, backend, fallbackTerminating); err != nil {
 				c.logger.Error("error adding endpoints of service '%s': %v", fullSvcName, err)
 			}
 		}
@@ -866,6 +1602,16 @@ func (c *converter) addBackendWithClass(source *annotations.Source, pathLink *ha
 	return backend, nil
 }
 
+// backendPort is the port used to identify the backend of a service port.
+// The portname naming uses the name of the service port when available, so
+// changing the port number doesn't rename the backend.
+func (c *converter) backendPort(port *api.ServicePort) string {
+	if c.haproxy.Backends().Naming == hatypes.BackendNamingPortName && port.Name != "" {
+		return port.Name
+	}
+	return port.TargetPort.String()
+}
+
 func readDNSPort(headlessService bool, port *api.ServicePort) string {
 	targetPort := port.TargetPort.String()
 	targetPortNum, _ := strconv.Atoi(targetPort)
@@ -883,6 +1629,10 @@ func readDNSPort(headlessService bool, port *api.ServicePort) string {
 
 func (c *converter) syncBackendEndpointCookies(backend *hatypes.Backend) {
 	cookieAffinity := backend.CookieAffinity()
+	if cookieAffinity && backend.EpCookieStrategy == hatypes.EpCookiePodNameHash {
+		syncBackendEndpointCookieHashes(backend)
+		return
+	}
 	for _, ep := range backend.Endpoints {
 		if cookieAffinity {
 			switch backend.EpCookieStrategy {
@@ -903,6 +1653,36 @@ func (c *converter) syncBackendEndpointCookies(backend *hatypes.Backend) {
 	}
 }
 
+// syncBackendEndpointCookieHashes uses a hash of the pod name as the cookie
+// value, so the value of a pod does not change when other pods of the
+// backend are added or removed. Colliding hashes are resolved in the
+// TargetRef order, the same way the server IDs are.
+func syncBackendEndpointCookieHashes(backend *hatypes.Backend) {
+	eps := make([]*hatypes.Endpoint, len(backend.Endpoints))
+	copy(eps, backend.Endpoints)
+	sort.SliceStable(eps, func(i, j int) bool {
+		return eps[i].TargetRef < eps[j].TargetRef
+	})
+	usedHashes := map[uint32]struct{}{}
+	for _, ep := range eps {
+		if ep.TargetRef == "" {
+			ep.CookieValue = ep.Name
+			continue
+		}
+		hasher := fnv.New32a()
+		hasher.Write([]byte(ep.TargetRef))
+		hash := hasher.Sum32()
+		for {
+			if _, exists := usedHashes[hash]; !exists {
+				break
+			}
+			hash++
+		}
+		usedHashes[hash] = struct{}{}
+		ep.CookieValue = fmt.Sprintf("%08x", hash)
+	}
+}
+
 func (c *converter) syncBackendEndpointHashes(backend *hatypes.Backend) {
 	mapper := c.backendAnnotations[backend]
 	if mapper == nil || !mapper.Get(ingtypes.BackAssignBackendServerID).Bool() {
@@ -952,6 +1732,10 @@ func (c *converter) syncBackendEndpointHashes(backend *hatypes.Backend) {
 
 func (c *converter) addTLS(source *annotations.Source, secretName string) convtypes.CrtFile {
 	if secretName != "" {
+		if err := annotations.CheckCrossNamespaceSecret(source.Namespace, secretName, c.options.DynamicConfig.CrossNamespaceSecretCertificate); err != nil {
+			c.logger.Error("using default certificate on %s: %v", source, err)
+			return c.defaultCrt
+		}
 		tlsFile, err := c.cache.GetTLSSecretPath(
 			source.Namespace,
 			secretName,
@@ -960,12 +1744,14 @@ func (c *converter) addTLS(source *annotations.Source, secretName string) convty
 		if err == nil {
 			return tlsFile
 		}
-		c.logger.Warn("using default certificate due to an error reading secret '%s' on %s: %v", secretName, source, err)
+		if !c.secrets.ReportedMissing(source, err) {
+			c.logger.Warn("using default certificate due to an error reading secret '%s' on %s: %v", secretName, source, err)
+		}
 	}
 	return c.defaultCrt
 }
 
-func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, backend *hatypes.Backend) error {
+func (c *converter) addEndpoints(source *annotations.Source, svc *api.Service, svcPort *api.ServicePort, backend *hatypes.Backend, fallbackTerminating bool) error {
 	ready, notReady, err := convutils.CreateEndpoints(c.cache, svc, svcPort, c.options.EnableEPSlices)
 	if err != nil {
 		return err
@@ -973,8 +1759,32 @@ func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, bac
 	for _, addr := range ready {
 		backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
 	}
+	// terminating endpoints that are still serving requests are used only
+	// while the service doesn't have any ready endpoint, e.g. during a
+	// rollout whose new pods never get ready. They are removed as soon as
+	// a ready endpoint shows up, since endpoints are rebuilt on every change.
+	serving := map[string]bool{}
+	if fallbackTerminating && len(ready) == 0 {
+		for _, addr := range notReady {
+			if addr.Serving {
+				backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
+				serving[addr.Target] = true
+			}
+		}
+		if len(serving) > 0 {
+			msg := fmt.Sprintf("service '%s/%s' does not have ready endpoints, using %d terminating endpoint(s)",
+				svc.Namespace, svc.Name, len(serving))
+			c.logger.Warn("%s on backend '%s'", msg, backend.ID)
+			if source.Type == convtypes.ResourceIngress {
+				c.cache.NotifyIngressWarning(source.FullName(), "FallbackToTerminating", msg)
+			}
+		}
+	}
 	if c.globalConfig.Get(ingtypes.GlobalDrainSupport).Bool() {
 		for _, addr := range notReady {
+			if serving[addr.Target] {
+				continue
+			}
 			ep := backend.AcquireEndpoint(addr.IP, addr.Port, addr.TargetRef)
 			ep.Weight = 0
 		}
@@ -985,6 +1795,9 @@ func (c *converter) addEndpoints(svc *api.Service, svcPort *api.ServicePort, bac
 		}
 		for _, pod := range pods {
 			targetPort := convutils.FindContainerPort(pod, svcPort)
+			if serving[pod.Status.PodIP+":"+strconv.Itoa(targetPort)] {
+				continue
+			}
 			if targetPort > 0 {
 				ep := backend.AcquireEndpoint(pod.Status.PodIP, targetPort, pod.Namespace+"/"+pod.Name)
 				ep.Weight = 0
@@ -1065,6 +1878,24 @@ func (c *converter) readParameters(ingressClass *networking.IngressClass) map[st
 	return ingClassConfig.config
 }
 
+// classMapBuilder returns a builder whose default values are the global
+// config overridden by the Parameters of the IngressClass, if any.
+func (c *converter) classMapBuilder(ingressClass *networking.IngressClass) *annotations.MapBuilder {
+	if ingressClass == nil {
+		return c.mapBuilder
+	}
+	cfg := c.readParameters(ingressClass)
+	ingClassConfig := c.ingressClasses[ingressClass.Name]
+	if ingClassConfig.mapBuilder == nil {
+		mapBuilder := c.mapBuilder
+		if len(cfg) > 0 {
+			mapBuilder = mapBuilder.WithDefaults(cfg)
+		}
+		ingClassConfig.mapBuilder = mapBuilder.WithClass(ingressClass.Name)
+	}
+	return ingClassConfig.mapBuilder
+}
+
 func (c *converter) parseParameters(ingressClass *networking.IngressClass) *ingressClassConfig {
 	parameters := ingressClass.Spec.Parameters
 	if parameters == nil {
@@ -1098,9 +1929,46 @@ func (c *converter) parseParameters(ingressClass *networking.IngressClass) *ingr
 	}
 }
 
+var errResourceBackend = fmt.Errorf("resource backends are not supported")
+
+func isResourceBackend(backend *networking.IngressBackend) bool {
+	return backend.Service == nil && backend.Resource != nil
+}
+
+// countResourceBackends returns the number of resource backends, and also the
+// total number of backends, of an ingress resource. Path backends are never
+// counted as resource backends if they are replaced by a redirect.
+func countResourceBackends(ing *networking.Ingress, redirect bool) (resources, total int) {
+	count := func(backend *networking.IngressBackend) {
+		total++
+		if isResourceBackend(backend) {
+			resources++
+		}
+	}
+	if ing.Spec.DefaultBackend != nil {
+		count(ing.Spec.DefaultBackend)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			if redirect {
+				total++
+			} else {
+				count(&rule.HTTP.Paths[i].Backend)
+			}
+		}
+	}
+	return resources, total
+}
+
 func readServiceNamePort(backend *networking.IngressBackend) (string, string, error) {
+	if isResourceBackend(backend) {
+		return "", "", errResourceBackend
+	}
 	if backend.Service == nil {
-		return "", "", fmt.Errorf("resource backend is not supported yet")
+		return "", "", fmt.Errorf("missing service backend")
 	}
 	serviceName := backend.Service.Name
 	servicePort := backend.Service.Port.Name
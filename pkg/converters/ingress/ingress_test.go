@@ -20,12 +20,15 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/diff"
 	api "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networking "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -36,6 +39,7 @@ import (
 	conv_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/helper_test"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
 	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	ingutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/utils"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/tracker"
 	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy"
@@ -65,6 +69,94 @@ func TestSyncSvcNotFound(t *testing.T) {
 WARN skipping backend config of Ingress 'default/echo': service not found: 'default/notfound'`)
 }
 
+func TestSyncMissingService(t *testing.T) {
+	expFrontEmpty := `
+- hostname: echo.example.com
+  paths: []`
+	expFrontSvc := `
+- hostname: echo.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080`
+	expBackSvc := `
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080` + defaultBackendConfig
+	testCases := []struct {
+		policy      string
+		expFront    string
+		expBack     string
+		expEvent    string
+		logging     string
+		expFrontSvc string
+		expBackSvc  string
+		loggingSvc  string
+	}{
+		// 0
+		{
+			policy:      "drop",
+			expFront:    expFrontEmpty,
+			expBack:     defaultBackendConfig,
+			expEvent:    `Warning ServiceNotFound default/echo: service 'default/echo' was not found, path '/' was dropped`,
+			logging:     `WARN skipping backend config of Ingress 'default/echo': service not found: 'default/echo'`,
+			expFrontSvc: expFrontEmpty,
+			expBackSvc:  defaultBackendConfig,
+			loggingSvc:  `INFO-V(2) syncing 0 host(s) and 0 backend(s)`,
+		},
+		// 1
+		{
+			policy:      "retry-fast",
+			expFront:    expFrontEmpty,
+			expBack:     defaultBackendConfig,
+			expEvent:    `Warning ServiceNotFound default/echo: service 'default/echo' was not found, path '/' will be configured as soon as the service is created`,
+			logging:     `WARN skipping backend config of Ingress 'default/echo': service not found: 'default/echo'`,
+			expFrontSvc: expFrontSvc,
+			expBackSvc:  expBackSvc,
+			loggingSvc:  `INFO-V(2) syncing 1 host(s) and 0 backend(s)`,
+		},
+		// 2
+		{
+			policy: "serve-503",
+			expFront: `
+- hostname: echo.example.com
+  paths:
+  - path: /
+    backend: _missing_default_echo_8080`,
+			expBack: `
+- id: _missing_default_echo_8080` + defaultBackendConfig,
+			expEvent:    `Warning ServiceNotFound default/echo: service 'default/echo' was not found, path '/' answers 503 until the service is created`,
+			logging:     `WARN serving 503 on path '/' of Ingress 'default/echo': service not found: 'default/echo'`,
+			expFrontSvc: expFrontSvc,
+			expBackSvc:  expBackSvc,
+			loggingSvc:  `INFO-V(2) syncing 1 host(s) and 1 backend(s)`,
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+
+		c.cache.Changed.GlobalConfigMapDataNew = map[string]string{"missing-service": test.policy}
+		c.Sync(c.createIng1("default/echo", "echo.example.com", "/", "echo:8080"))
+
+		c.compareConfigFront(test.expFront)
+		c.compareConfigBack(test.expBack)
+		c.compareText(strings.Join(c.cache.Events, "\n"), test.expEvent)
+		c.logger.CompareLogging(test.logging)
+
+		// the service is created after the ingress, only a partial sync is made
+		c.hconfig.Commit()
+		svc, _ := c.createSvc1("default/echo", "8080", "172.17.0.11")
+		c.cache.Changed.ServicesAdd = []*api.Service{svc}
+		c.Sync()
+
+		c.compareConfigFront(test.expFrontSvc)
+		c.compareConfigBack(test.expBackSvc)
+		c.logger.CompareLogging(test.loggingSvc)
+
+		c.teardown()
+	}
+}
+
 func TestSyncDefaultSvcNotFound(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -158,6 +250,67 @@ WARN skipping backend config of Ingress 'default/echo4': port not found: '9000'
 `)
 }
 
+func TestSyncSvcBackendNaming(t *testing.T) {
+	testCases := []struct {
+		naming   hatypes.BackendNaming
+		port     string
+		svcRef   string
+		expected string
+	}{
+		// 0
+		{
+			naming:   hatypes.BackendNamingPort,
+			port:     "svcport:8080:8080",
+			svcRef:   "echo:8080",
+			expected: "default_echo_8080",
+		},
+		// 1
+		{
+			naming:   hatypes.BackendNamingPortName,
+			port:     "svcport:8080:8080",
+			svcRef:   "echo:8080",
+			expected: "default_echo_svcport",
+		},
+		// 2
+		{
+			naming:   hatypes.BackendNamingPortName,
+			port:     "svcport:8081:8081",
+			svcRef:   "echo:8081",
+			expected: "default_echo_svcport",
+		},
+		// 3
+		{
+			naming:   hatypes.BackendNamingPortName,
+			port:     "8080",
+			svcRef:   "echo:8080",
+			expected: "default_echo_8080",
+		},
+		// 4
+		{
+			naming:   hatypes.BackendNamingHash,
+			port:     "svcport:8080:8080",
+			svcRef:   "echo:8080",
+			expected: "b_0f7c7d403c67fafd",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.hconfig.Backends().Naming = test.naming
+		c.createSvc1("default/echo", test.port, "172.17.1.101")
+		c.Sync(c.createIng1("default/echo", "echo.example.com", "/", test.svcRef))
+		var actual []string
+		for _, backend := range c.hconfig.Backends().BuildSortedItems() {
+			if backend.Namespace == "default" {
+				actual = append(actual, backend.ID)
+			}
+		}
+		if !reflect.DeepEqual(actual, []string{test.expected}) {
+			t.Errorf("%d: expected backend %s but was %v", i, test.expected, actual)
+		}
+		c.teardown()
+	}
+}
+
 func TestSyncSvcUpstream(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -339,6 +492,129 @@ func TestSyncInvalidEndpoint(t *testing.T) {
 ERROR error adding endpoints of service 'default/echo': could not find endpoints for service 'default/echo'`)
 }
 
+func TestSyncGlobalConfigValidation(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	options := &convtypes.ConverterOptions{
+		Cache:         c.cache,
+		Logger:        c.logger,
+		DynamicConfig: &convtypes.DynamicConfig{},
+	}
+	read := func(config map[string]string, expected map[string]string) {
+		c.cache.Changed.GlobalConfigMapDataNew = config
+		actual := readGlobalConfig(options, c.cache.SwapChangedObjects())
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("global config differs - expected: %v - actual: %v", expected, actual)
+		}
+	}
+
+	// controller starts, every key is applied
+	read(map[string]string{"nbthread": "2", "timeout-client": "50s"},
+		map[string]string{"nbthread": "2", "timeout-client": "50s"})
+
+	// mixed valid, invalid and restart required keys
+	read(map[string]string{"max-connections": "many", "nbthread": "4", "timeout-client": "1m"},
+		map[string]string{"nbthread": "2", "timeout-client": "1m"})
+
+	// configmap not changed, last applied config is reused
+	read(nil,
+		map[string]string{"nbthread": "2", "timeout-client": "1m"})
+
+	c.compareText(strings.Join(c.cache.GlobalConfigEvents, "\n"), `
+Normal GlobalConfigApplied: 2 key(s) applied: nbthread, timeout-client
+Warning GlobalConfigPartiallyApplied: 1 key(s) applied: timeout-client; 1 key(s) rejected: max-connections; 1 key(s) pending a controller restart: nbthread`)
+
+	c.logger.CompareLogging(`
+ERROR ignoring invalid value of global config key 'max-connections': many, using the default value
+WARN global config key 'nbthread' was changed and will be applied after the controller restarts
+`)
+}
+
+func TestSyncPeers(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	svc, ep := c.createSvc1("ingress-controller/haproxy-peers", "peers:10000", "10.0.0.1,10.0.0.2,10.0.0.3")
+	ss := &ep.Subsets[0]
+	for i, name := range []string{"haproxy-a", "haproxy-b", "haproxy-c"} {
+		ss.Addresses[i].TargetRef.Name = name
+	}
+	ss.NotReadyAddresses = []api.EndpointAddress{ss.Addresses[2]}
+	ss.Addresses = ss.Addresses[:2]
+
+	comparePeers := func(expected hatypes.PeersConfig) {
+		t.Helper()
+		actual := c.hconfig.Global().Peers
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("peers differ - expected: %+v - actual: %+v", expected, actual)
+		}
+	}
+	peers := hatypes.PeersConfig{
+		Name:      "ingress",
+		LocalPeer: "haproxy-a",
+		Port:      10000,
+		Servers: []hatypes.PeerServer{
+			{Name: "haproxy-b", Endpoint: "10.0.0.2:10000"},
+			{Name: "haproxy-c", Endpoint: "10.0.0.3:10000"},
+		},
+	}
+
+	// peers not configured
+	c.Sync()
+	comparePeers(hatypes.PeersConfig{})
+
+	// missing POD_NAME
+	c.cache.Changed.GlobalConfigMapDataNew = map[string]string{"peers-service": "haproxy-peers:peers"}
+	c.Sync()
+	comparePeers(hatypes.PeersConfig{})
+	c.logger.CompareLogging("ERROR ignoring peers-service: POD_NAME envvar should be configured")
+
+	// missing port
+	c.podName = "haproxy-a"
+	c.cache.Changed.GlobalConfigMapDataNew = map[string]string{"peers-service": "haproxy-peers"}
+	c.Sync()
+	comparePeers(hatypes.PeersConfig{})
+	c.logger.CompareLogging("ERROR cannot configure peers: missing port on peers-service 'haproxy-peers'")
+
+	// local and not ready replicas, named port
+	c.cache.Changed.GlobalConfigMapDataNew = map[string]string{"peers-service": "haproxy-peers:peers"}
+	c.Sync()
+	comparePeers(peers)
+
+	// local replica isn't an endpoint yet, port is read from the remote ones
+	c.podName = "haproxy-d"
+	c.Sync()
+	comparePeers(hatypes.PeersConfig{
+		Name:      "ingress",
+		LocalPeer: "haproxy-d",
+		Port:      10000,
+		Servers: []hatypes.PeerServer{
+			{Name: "haproxy-a", Endpoint: "10.0.0.1:10000"},
+			{Name: "haproxy-b", Endpoint: "10.0.0.2:10000"},
+			{Name: "haproxy-c", Endpoint: "10.0.0.3:10000"},
+		},
+	})
+
+	// peers service removed, current peers are kept
+	c.podName = "haproxy-a"
+	c.cache.Changed.GlobalConfigMapDataNew = map[string]string{"peers-service": "haproxy-peers:peers", "timeout-client": "1m"}
+	c.Sync()
+	comparePeers(peers)
+	c.cache.Changed.ServicesDel = []*api.Service{svc}
+	c.Sync()
+	comparePeers(peers)
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 0 host(s) and 0 backend(s)
+WARN keeping the current peers list: error reading peers service: service not found: 'haproxy-peers'
+INFO-V(2) syncing 0 host(s) and 0 backend(s)`)
+
+	// peers-service removed from the global config
+	c.cache.Changed.GlobalConfigMapDataNew = map[string]string{}
+	c.Sync()
+	comparePeers(hatypes.PeersConfig{})
+}
+
 func TestSyncDrainSupport(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -384,6 +660,93 @@ func TestSyncDrainSupport(t *testing.T) {
 	c.logger.CompareLogging("WARN skipping endpoint 172.17.1.104 of service default/echo: port 'http' was not found")
 }
 
+func TestSyncFallbackToTerminating(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	svc, ep := c.createSvc1Ann("default/echo", "http:8080:http", "", map[string]string{
+		"ingress.kubernetes.io/" + ingtypes.BackFallbackToTerminating: "true",
+	})
+	_, _, defaultEps := conv_helper.CreateService("system/default", "8080", "172.17.0.99")
+	c.cache.EpsList = map[string][]*discoveryv1.EndpointSlice{"system/default": defaultEps}
+	// updateEps configures 172.17.1.101 as ready if withReady is true, and
+	// everything else as terminating, only 172.17.1.102 still serving.
+	updateEps := func(withReady bool) {
+		_, _, eps := conv_helper.CreateService("default/echo", "http:8080:http", "172.17.1.101,172.17.1.102,172.17.1.103")
+		endpoints := eps[0].Endpoints
+		for i := range endpoints {
+			ready := i == 0 && withReady
+			serving := ready || i == 1
+			terminating := !ready
+			endpoints[i].Conditions = discoveryv1.EndpointConditions{
+				Ready:       &ready,
+				Serving:     &serving,
+				Terminating: &terminating,
+			}
+		}
+		c.cache.EpsList[svc.Namespace+"/"+svc.Name] = eps
+		c.cache.Changed.EndpointsNew = []*api.Endpoints{ep}
+	}
+	sync := func(ing ...*networking.Ingress) {
+		c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
+		conv := c.createConverter()
+		conv.options.EnableEPSlices = true
+		// createConverter() already swapped the changed objects, preserve
+		// the global config so only the first sync is a full one
+		c.cache.Changed.GlobalConfigMapDataNew = c.cache.Changed.GlobalConfigMapDataCur
+		c.SyncConverter(conv, ing...)
+	}
+	endpointsNoReady := `
+- id: default_echo_http
+  endpoints:
+  - ip: 172.17.1.102
+    port: 8080
+- id: system_default_8080
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`
+	endpointsReady := `
+- id: default_echo_http
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+- id: system_default_8080
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`
+	warnNoReady := `
+WARN service 'default/echo' does not have ready endpoints, using 1 terminating endpoint(s) on backend 'default_echo_http'`
+
+	// no ready endpoint, fallback to the serving one
+	updateEps(false)
+	sync(c.createIng1("default/echo", "echo.example.com", "/", "echo:8080"))
+	c.compareConfigBack(endpointsNoReady)
+	c.logger.CompareLogging(warnNoReady)
+	c.compareText(strings.Join(c.cache.Events, "\n"), `
+Warning FallbackToTerminating default/echo: service 'default/echo' does not have ready endpoints, using 1 terminating endpoint(s)`)
+
+	// flapping between empty and non empty ready sets, terminating
+	// endpoints should be used only while ready set is empty
+	for i, ready := range []bool{true, false, true} {
+		c.hconfig.Commit()
+		c.cache.Events = nil
+		updateEps(ready)
+		sync()
+		if ready {
+			c.compareConfigBack(endpointsReady)
+			c.logger.CompareLogging(`INFO-V(2) syncing 1 host(s) and 1 backend(s)`)
+		} else {
+			c.compareConfigBack(endpointsNoReady)
+			c.logger.CompareLogging(`INFO-V(2) syncing 1 host(s) and 1 backend(s)` + warnNoReady)
+		}
+		if len(c.hconfig.Backends().ItemsAdd()) != 1 {
+			t.Errorf("%d: expected one changed backend but was %d", i, len(c.hconfig.Backends().ItemsAdd()))
+		}
+	}
+}
+
 func TestSyncServerIDs(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -428,6 +791,53 @@ func TestSyncServerIDs(t *testing.T) {
 `)
 }
 
+func TestSyncCookieValueHash(t *testing.T) {
+	testCases := []struct {
+		pods     []string
+		expected []string
+	}{
+		// 0
+		{
+			pods:     []string{"echo-1", "echo-2"},
+			expected: []string{"e1b60c0c", "e4b610c5"},
+		},
+		// 1 - echo-112789 and echo-349192 share the same hash, the latter
+		// receives the next value since it comes last in the TargetRef order
+		{
+			pods:     []string{"echo-349192", "echo-1", "echo-112789"},
+			expected: []string{"df77be8c", "e1b60c0c", "df77be8b"},
+		},
+		// 2 - the value of echo-1 is preserved when other pods are removed
+		{
+			pods:     []string{"echo-1"},
+			expected: []string{"e1b60c0c"},
+		},
+		// 3 - empty slots use the server name
+		{
+			pods:     []string{"echo-1", ""},
+			expected: []string{"e1b60c0c", "srv002"},
+		},
+	}
+	for i, test := range testCases {
+		backend := &hatypes.Backend{}
+		for j, pod := range test.pods {
+			ep := &hatypes.Endpoint{Name: fmt.Sprintf("srv%03d", j+1)}
+			if pod != "" {
+				ep.TargetRef = "default/" + pod
+			}
+			backend.Endpoints = append(backend.Endpoints, ep)
+		}
+		syncBackendEndpointCookieHashes(backend)
+		var actual []string
+		for _, ep := range backend.Endpoints {
+			actual = append(actual, ep.CookieValue)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("cookie values differ on %d - expected: %v - actual: %v", i, test.expected, actual)
+		}
+	}
+}
+
 func TestSyncRootPathLast(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -582,6 +992,51 @@ func TestSyncTLSCustom(t *testing.T) {
     tlsfilename: /tls/default/tls-echo.pem`)
 }
 
+func TestSyncTLSMissingShared(t *testing.T) {
+	testCases := []struct {
+		ingCount   int
+		expLogging string
+		expEvents  int
+	}{
+		// 0
+		{
+			ingCount: 3,
+			expLogging: `
+WARN using default certificate due to an error reading secret 'tls-shared' on Ingress 'default/echo000': secret not found: 'default/tls-shared'
+ERROR secret 'default/tls-shared' was not found and is referenced by 3 resource(s): Ingress 'default/echo000', Ingress 'default/echo001', Ingress 'default/echo002'`,
+			expEvents: 3,
+		},
+		// 1
+		{
+			ingCount: 300,
+			expLogging: `
+WARN using default certificate due to an error reading secret 'tls-shared' on Ingress 'default/echo000': secret not found: 'default/tls-shared'
+ERROR secret 'default/tls-shared' was not found and is referenced by 300 resource(s): Ingress 'default/echo000', Ingress 'default/echo001', Ingress 'default/echo002', Ingress 'default/echo003', Ingress 'default/echo004', Ingress 'default/echo005', Ingress 'default/echo006', Ingress 'default/echo007', Ingress 'default/echo008', Ingress 'default/echo009', and 290 more`,
+			expEvents: 10,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1Auto()
+		ings := make([]*networking.Ingress, test.ingCount)
+		for j := range ings {
+			ings[j] = c.createIngTLS1(fmt.Sprintf("default/echo%03d", j), fmt.Sprintf("echo%03d.example.com", j), "/", "echo:8080", "tls-shared")
+		}
+		c.Sync(ings...)
+		// the default certificate and the missing secret, once
+		if c.cache.SecretLookups != 2 {
+			t.Errorf("expected 2 secret lookups on %d, but found %d", i, c.cache.SecretLookups)
+		}
+		if len(c.cache.Events) != test.expEvents {
+			t.Errorf("expected %d events on %d, but found %d", test.expEvents, i, len(c.cache.Events))
+		} else {
+			c.compareText(c.cache.Events[0], `Warning SecretNotFound default/echo000: secret 'default/tls-shared' was not found and is referenced by `+strconv.Itoa(test.ingCount)+` resource(s)`)
+		}
+		c.logger.CompareLoggingID(strconv.Itoa(i), test.expLogging)
+		c.teardown()
+	}
+}
+
 func TestSyncRedeclareTLS(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -769,6 +1224,73 @@ func TestSyncIngressClass(t *testing.T) {
 	}
 }
 
+func TestSyncIngressClassHosts(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.cache.ConfigMapList = map[string]*api.ConfigMap{
+		"ingress-controller/public":   {Data: map[string]string{"app-root": "/public"}},
+		"ingress-controller/internal": {Data: map[string]string{"app-root": "/internal"}},
+	}
+	for _, name := range []string{"public", "internal"} {
+		c.cache.IngClassList = append(c.cache.IngClassList, &networking.IngressClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: networking.IngressClassSpec{
+				Parameters: &networking.IngressClassParametersReference{Kind: "ConfigMap", Name: name},
+			},
+		})
+	}
+	withClass := func(ing *networking.Ingress, className string) *networking.Ingress {
+		ing.Spec.IngressClassName = &className
+		return ing
+	}
+	withTLS := func(ing *networking.Ingress) *networking.Ingress {
+		ing.Spec.TLS = []networking.IngressTLS{{Hosts: []string{ing.Spec.Rules[0].Host}}}
+		return ing
+	}
+
+	c.createSvc1Auto()
+	c.Sync(
+		withClass(c.createIng1("default/echo1", "echo.example.com", "/", "echo:8080"), "public"),
+		withClass(withTLS(c.createIng1("default/echo2", "echo.example.com", "/app", "echo:8080")), "internal"),
+		withClass(c.createIng1("default/echo3", "echo-int.example.com", "/", "echo:8080"), "internal"),
+		withClass(c.createIng1Ann("default/echo4", "echo-int.example.com", "/app", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/app-root": "/app",
+		}), "internal"),
+		withClass(c.createIng1Ann("default/echo5", "echo-int.example.com", "/app5", "echo:8080", map[string]string{
+			"ingress.kubernetes.io/app-root": "/app5",
+		}), "internal"),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo-int.example.com
+  paths:
+  - path: /app5
+    backend: default_echo_8080
+  - path: /app
+    backend: default_echo_8080
+  - path: /
+    backend: default_echo_8080
+  rootredirect: /app
+- hostname: echo.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+  rootredirect: /public`)
+
+	c.logger.CompareLogging(`
+ERROR skipping host 'echo.example.com' of Ingress 'default/echo2' on class 'internal': host was already claimed by Ingress 'default/echo1' on class 'public'
+INFO-V(2) dropped configuration key: reason=conflict key=app-root namespace=default class=internal source="Ingress 'default/echo5'" winner="Ingress 'default/echo4'"
+WARN skipping host annotation(s) from Ingress 'default/echo5' on class 'internal' due to conflict: [app-root]`)
+
+	if count := c.metrics.HostClassConflicts["internal"]; count != 1 {
+		t.Errorf("expected 1 host class conflict on internal class, but found %d", count)
+	}
+	if count := c.metrics.AnnotationsDropped["default/app-root@internal"]; count != 1 {
+		t.Errorf("expected 1 dropped app-root key on internal class, but found %d", count)
+	}
+}
+
 func TestSyncRootPathDefault(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -939,6 +1461,54 @@ func TestSyncBackendSvcNotFound(t *testing.T) {
 WARN skipping default backend of Ingress 'default/echo': service not found: 'default/notfound'`)
 }
 
+func TestSyncResourceBackend(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	resource := networking.IngressBackend{
+		Resource: &api.TypedLocalObjectReference{Kind: "StorageBucket", Name: "static"},
+	}
+	c.createSvc1Auto()
+	ing1 := c.createIng1("default/echo1", "echo1.example.com", "/", "echo:8080")
+	ing1.Spec.Rules[0].HTTP.Paths[0].Backend = resource
+	ing2 := c.createIng2("default/echo2", "echo:8080")
+	ing2.Spec.DefaultBackend = &resource
+	ing3 := c.createIng1("default/echo3", "echo3.example.com", "/", "echo:8080")
+	ing3.Spec.Rules[0].HTTP.Paths = append(ing3.Spec.Rules[0].HTTP.Paths, networking.HTTPIngressPath{
+		Path:    "/static",
+		Backend: resource,
+	})
+	c.Sync(ing1, ing2, ing3, c.createIng1("default/echo4", "echo4.example.com", "/", "echo:8080"))
+
+	c.compareConfigFront(`
+- hostname: echo3.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+- hostname: echo4.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080`)
+
+	c.compareConfigDefaultFront(`[]`)
+
+	c.compareConfigBack(`
+- id: default_echo_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080` + defaultBackendConfig)
+
+	c.logger.CompareLogging(`
+ERROR skipping 1 resource backend(s) of Ingress 'default/echo1': resource backends are not supported
+ERROR skipping 1 resource backend(s) of Ingress 'default/echo2': resource backends are not supported
+ERROR skipping 1 resource backend(s) of Ingress 'default/echo3': resource backends are not supported`)
+
+	c.compareText(strings.Join(c.cache.Events, "\n"), `
+Warning UnsupportedBackend default/echo1: resource backends are not supported
+Warning UnsupportedBackend default/echo2: resource backends are not supported
+Warning UnsupportedBackend default/echo3: resource backends are not supported`)
+}
+
 func TestSyncBackendReuseDefaultSvc(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1347,7 +1917,7 @@ WARN skipping backend config of Ingress 'default/echo2': service not found: 'def
 			secDel: secTLSDefault,
 			logging: explogging + `
 WARN using default certificate due to an error reading secret 'tls1' on Ingress 'default/echo1': secret not found: 'default/tls1'
-WARN using default certificate due to an error reading secret 'default/tls1' on Ingress 'default/echo2': secret not found: 'default/tls1'`,
+ERROR secret 'default/tls1' was not found and is referenced by 2 resource(s): Ingress 'default/echo1', Ingress 'default/echo2'`,
 			expFront: expFrontDefault + `
   tls:
     tlsfilename: /tls/tls-default.pem`,
@@ -1486,17 +2056,83 @@ WARN using default certificate due to an error reading secret 'default/tls1' on
   - ip: 172.17.0.99
     port: 8080`,
 		},
-	}
-
-	for _, test := range testCases {
-		c := setup(t)
-
-		for _, svc := range test.svc {
-			c.createSvc1(svc[0], svc[1], svc[2])
-		}
-		for _, ing := range test.ing {
-			var item *networking.Ingress
-			switch len(ing) {
+		// 15
+		{
+			svc: [][]string{
+				{"default/echo1", "web:8080", "172.17.0.11"},
+			},
+			ing: [][]string{
+				{"default/echo1", "echo1:web"},
+			},
+			svcUpd: [][]string{
+				{"default/echo1", "web:9090", "172.17.0.11"},
+			},
+			logging: `INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+			expDefaultFront: `
+hostname: <default>
+paths:
+- path: /
+  backend: default_echo1_9090`,
+			expBack: `
+- id: default_echo1_9090
+  endpoints:
+  - ip: 172.17.0.11
+    port: 9090
+- id: system_default_8080
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080`,
+		},
+		// 16
+		{
+			svc: [][]string{
+				{"default/echo1", "web:8080", "172.17.0.11"},
+			},
+			ing: [][]string{
+				{"default/echo1", "echo1:web"},
+			},
+			svcUpd: [][]string{
+				{"default/echo1", "http:8080", "172.17.0.11"},
+			},
+			logging: `
+INFO-V(2) syncing 1 host(s) and 2 backend(s)
+WARN skipping default backend of Ingress 'default/echo1': port not found: 'web'`,
+			expBack: defaultBackendConfig,
+		},
+		// 17
+		{
+			svc: [][]string{
+				{"default/echo1", "http:8080", "172.17.0.11"},
+			},
+			ing: [][]string{
+				{"default/echo1", "echo1:web"},
+			},
+			svcUpd: [][]string{
+				{"default/echo1", "web:8080", "172.17.0.11"},
+			},
+			logging: `INFO-V(2) syncing 1 host(s) and 1 backend(s)`,
+			expDefaultFront: `
+hostname: <default>
+paths:
+- path: /
+  backend: default_echo1_8080`,
+			expBack: `
+- id: default_echo1_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080` + defaultBackendConfig,
+		},
+	}
+
+	for _, test := range testCases {
+		c := setup(t)
+
+		for _, svc := range test.svc {
+			c.createSvc1(svc[0], svc[1], svc[2])
+		}
+		for _, ing := range test.ing {
+			var item *networking.Ingress
+			switch len(ing) {
 			case 2:
 				item = c.createIng2(ing[0], ing[1])
 			case 4:
@@ -1613,6 +2249,41 @@ func TestSyncPartialTCPService(t *testing.T) {
 	c.logger.CompareLogging(`INFO-V(2) syncing 0 host(s) and 1 backend(s)`)
 }
 
+func TestSyncTCPServiceALPN(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo1", "8080", "172.17.0.11")
+	c.createSvc1("default/xmpp", "5222", "172.17.0.21")
+	ing := c.createIng2("default/echo1", "echo1:8080")
+	ing.SetAnnotations(map[string]string{
+		"ingress.kubernetes.io/" + ingtypes.TCPTCPServicePort: "7001",
+		"ingress.kubernetes.io/" + ingtypes.TCPTCPServiceALPN: `
+xmpp-client xmpp:5222
+h2 missing:8080
+invalid`,
+	})
+	c.Sync(ing)
+
+	c.compareConfigBack(`
+- id: default_echo1_8080
+  endpoints:
+  - ip: 172.17.0.11
+    port: 8080
+  modetcp: true
+- id: default_xmpp_5222
+  endpoints:
+  - ip: 172.17.0.21
+    port: 5222
+  modetcp: true
+- id: system_default_8080
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`)
+	c.logger.CompareLogging(`WARN skipping alpn route on Ingress 'default/echo1': service not found: 'default/missing'`)
+}
+
 func TestSyncPartialDefaultBackend(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -1637,12 +2308,742 @@ func TestSyncPartialDefaultBackend(t *testing.T) {
 	c.logger.CompareLogging(`INFO-V(2) syncing 1 host(s) and 1 backend(s)`)
 }
 
-/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
- *
- *  ANNOTATIONS
- *
- * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
-
+/* * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * *
+ *
+ *  ANNOTATIONS
+ *
+ * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * * */
+
+func TestSyncModelLimits(t *testing.T) {
+	type ing struct {
+		name, host, path, svc, class string
+		age                          int
+	}
+	testCases := []struct {
+		limits    convtypes.ModelLimits
+		ings      []ing
+		ingAdd    []ing
+		ingUpd    []ing
+		ingDel    []string
+		epUpd     string
+		expHosts  []string
+		expEPs    int
+		expEvents string
+		expMetric map[string]int
+		logging   string
+	}{
+		// 0
+		{
+			limits: convtypes.ModelLimits{MaxIngresses: 2},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 20},
+				{name: "echo2", host: "d2.local", svc: "echo1", age: 30},
+				{name: "echo3", host: "d3.local", svc: "echo1", age: 10},
+			},
+			expHosts:  []string{"d1.local", "d3.local"},
+			expEvents: `Warning ModelLimit default/echo2: number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+			expMetric: map[string]int{"ingresses": 1},
+			logging:   `ERROR rejecting Ingress 'default/echo2': number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+		},
+		// 1
+		{
+			limits: convtypes.ModelLimits{MaxIngresses: 2},
+			ings: []ing{
+				{name: "echo3", host: "d3.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo1", age: 10},
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+			},
+			expHosts:  []string{"d1.local", "d2.local"},
+			expEvents: `Warning ModelLimit default/echo3: number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+			expMetric: map[string]int{"ingresses": 1},
+			logging:   `ERROR rejecting Ingress 'default/echo3': number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+		},
+		// 2
+		{
+			limits: convtypes.ModelLimits{MaxHosts: 1},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo1", age: 20},
+				{name: "echo3", host: "d1.local", path: "/app", svc: "echo2", age: 30},
+			},
+			expHosts:  []string{"d1.local"},
+			expEvents: `Warning ModelLimit default/echo2: number of hosts exceeds the model limit: 2 > 1, ingress was not added to the configuration`,
+			expMetric: map[string]int{"hosts": 1},
+			logging:   `ERROR rejecting Ingress 'default/echo2': number of hosts exceeds the model limit: 2 > 1, ingress was not added to the configuration`,
+		},
+		// 3
+		{
+			limits: convtypes.ModelLimits{MaxBackends: 2},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo2", age: 20},
+				{name: "echo3", host: "d3.local", svc: "echo1", age: 30},
+			},
+			expHosts:  []string{"d1.local", "d3.local"},
+			expEvents: `Warning ModelLimit default/echo2: number of backends exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+			expMetric: map[string]int{"backends": 1},
+			logging:   `ERROR rejecting Ingress 'default/echo2': number of backends exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+		},
+		// 4
+		{
+			limits: convtypes.ModelLimits{MaxEndpoints: 3},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo2", age: 20},
+			},
+			expHosts:  []string{"d1.local"},
+			expEvents: `Warning ModelLimit default/echo2: number of endpoints exceeds the model limit: 6 > 3, ingress was not added to the configuration`,
+			expMetric: map[string]int{"endpoints": 1},
+			logging:   `ERROR rejecting Ingress 'default/echo2': number of endpoints exceeds the model limit: 6 > 3, ingress was not added to the configuration`,
+		},
+		// 5
+		{
+			limits: convtypes.ModelLimits{MaxIngresses: 2, ClassPriority: []string{"high", "low"}},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo1", class: "low", age: 20},
+				{name: "echo3", host: "d3.local", svc: "echo1", class: "high", age: 30},
+			},
+			expHosts:  []string{"d2.local", "d3.local"},
+			expEvents: `Warning ModelLimit default/echo1: number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+			expMetric: map[string]int{"ingresses": 1},
+			logging:   `ERROR rejecting Ingress 'default/echo1': number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+		},
+		// 6
+		{
+			limits: convtypes.ModelLimits{MaxIngresses: 2},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo1", age: 20},
+			},
+			ingAdd: []ing{
+				{name: "echo3", host: "d3.local", svc: "echo1", age: 5},
+			},
+			expHosts:  []string{"d1.local", "d2.local"},
+			expEvents: `Warning ModelLimit default/echo3: number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+			expMetric: map[string]int{"ingresses": 1},
+			logging: `
+INFO-V(2) syncing 3 host(s) and 1 backend(s)
+ERROR rejecting Ingress 'default/echo3': number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+		},
+		// 7
+		{
+			limits: convtypes.ModelLimits{MaxIngresses: 2},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo1", age: 20},
+				{name: "echo3", host: "d3.local", svc: "echo1", age: 30},
+				{name: "echo4", host: "d4.local", svc: "echo1", age: 40},
+			},
+			ingDel:    []string{"echo1"},
+			expHosts:  []string{"d2.local", "d3.local"},
+			expMetric: map[string]int{"ingresses": 1},
+			logging:   `INFO-V(2) syncing 4 host(s) and 1 backend(s)`,
+		},
+		// 8
+		{
+			limits: convtypes.ModelLimits{MaxIngresses: 2},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+				{name: "echo2", host: "d2.local", svc: "echo1", age: 20},
+				{name: "echo3", host: "d3.local", svc: "echo1", age: 30},
+			},
+			ingAdd: []ing{
+				{name: "echo0", host: "d0.local", svc: "echo1", age: 5},
+			},
+			ingUpd: []ing{
+				{name: "echo2", host: "d2.local", path: "/app", svc: "echo1", age: 20},
+			},
+			expHosts:  []string{"d1.local", "d2.local"},
+			expEvents: `Warning ModelLimit default/echo0: number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+			expMetric: map[string]int{"ingresses": 2},
+			logging: `
+INFO-V(2) syncing 4 host(s) and 1 backend(s)
+ERROR rejecting Ingress 'default/echo0': number of ingresses exceeds the model limit: 3 > 2, ingress was not added to the configuration`,
+		},
+		// 9
+		{
+			limits: convtypes.ModelLimits{MaxEndpoints: 4},
+			ings: []ing{
+				{name: "echo1", host: "d1.local", svc: "echo1", age: 10},
+			},
+			epUpd:     "172.17.0.11,172.17.0.12,172.17.0.13,172.17.0.14,172.17.0.15",
+			expHosts:  []string{"d1.local"},
+			expEPs:    3,
+			expMetric: map[string]int{},
+			logging: `
+INFO-V(2) syncing 1 host(s) and 1 backend(s)
+ERROR limiting endpoints of backend 'default_echo1_8080': number of endpoints exceeds the model limit: 6 > 4, 2 endpoint(s) were not added to the configuration`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.createSvc1("default/echo1", "8080", "172.17.0.11")
+		c.createSvc1("default/echo2", "8080", "172.17.0.21,172.17.0.22,172.17.0.23,172.17.0.24")
+		c.dynconfig = &convtypes.DynamicConfig{}
+		c.modelLimits = test.limits
+		createIng := func(ing ing) *networking.Ingress {
+			path := ing.path
+			if path == "" {
+				path = "/"
+			}
+			item := c.createIng1("default/"+ing.name, ing.host, path, ing.svc+":8080")
+			item.CreationTimestamp = metav1.Unix(int64(ing.age), 0)
+			if ing.class != "" {
+				item.SetAnnotations(map[string]string{"kubernetes.io/ingress.class": ing.class})
+			}
+			return item
+		}
+		var ingList []*networking.Ingress
+		for _, ing := range test.ings {
+			ingList = append(ingList, createIng(ing))
+		}
+		c.Sync(ingList...)
+		if len(test.ingAdd)+len(test.ingUpd)+len(test.ingDel) > 0 || test.epUpd != "" {
+			c.hconfig.Commit()
+			c.logger.Logging = []string{}
+			c.cache.Events = nil
+			// the cache mock updates its ingress list from the changed objects
+			for _, ing := range test.ingAdd {
+				c.cache.Changed.IngressesAdd = append(c.cache.Changed.IngressesAdd, createIng(ing))
+			}
+			for _, ing := range test.ingUpd {
+				c.cache.Changed.IngressesUpd = append(c.cache.Changed.IngressesUpd, createIng(ing))
+			}
+			if test.epUpd != "" {
+				_, ep, _ := conv_helper.CreateService("default/echo1", "8080", test.epUpd)
+				c.cache.Changed.EndpointsNew = []*api.Endpoints{ep}
+			}
+			for _, name := range test.ingDel {
+				c.cache.Changed.IngressesDel = append(c.cache.Changed.IngressesDel, c.createIng1("default/"+name, "", "/", "echo1:8080"))
+			}
+			c.Sync()
+		}
+		var hosts []string
+		for _, host := range c.hconfig.Hosts().BuildSortedItems() {
+			hosts = append(hosts, host.Hostname)
+		}
+		c.compareText(strings.Join(hosts, ","), strings.Join(test.expHosts, ","))
+		if test.expEPs > 0 {
+			c.compareText(strconv.Itoa(len(c.hconfig.Backends().FindBackend("default", "echo1", "8080").Endpoints)), strconv.Itoa(test.expEPs))
+		}
+		c.compareText(strings.Join(c.cache.Events, "\n"), test.expEvents)
+		if !reflect.DeepEqual(c.metrics.ModelLimitRejected, test.expMetric) {
+			t.Errorf("metrics differ on %d - expected: %v - actual: %v", i, test.expMetric, c.metrics.ModelLimitRejected)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+type hostOwnersMock struct {
+	owners map[string]string
+	err    error
+}
+
+func (o *hostOwnersMock) Owners() (map[string]string, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	return o.owners, nil
+}
+
+func (o *hostOwnersMock) Update(owners map[string]string) {
+	o.owners = owners
+}
+
+func TestSyncHostOwnership(t *testing.T) {
+	type ing struct {
+		name, host, path string
+		tls              bool
+		age              int
+	}
+	testCases := []struct {
+		owners      map[string]string
+		ownersErr   bool
+		wildcard    bool
+		delegations map[string]string
+		ings        []ing
+		ingDel      []string
+		ingAdd      []ing
+		ingAddErr   bool
+		restart     []ing
+		expPaths    []string
+		expOwners   map[string]string
+		expEvents   string
+		logging     string
+	}{
+		// 0
+		{
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 20},
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 10},
+				{name: "ns1/echo3", host: "d2.local", path: "/app3", age: 30},
+			},
+			expPaths:  []string{"d1.local/app2", "d2.local/app3"},
+			expOwners: map[string]string{"d1.local": "ns2", "d2.local": "ns1"},
+			expEvents: `Warning HostOwnership ns1/echo1: host 'd1.local' is owned by namespace 'ns2'`,
+			logging:   `ERROR skipping host 'd1.local' of Ingress 'ns1/echo1': host 'd1.local' is owned by namespace 'ns2'`,
+		},
+		// 1
+		{
+			owners: map[string]string{"d1.local": "ns1"},
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", tls: true, age: 20},
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", tls: true, age: 10},
+			},
+			expPaths:  []string{"d1.local/app1"},
+			expOwners: map[string]string{"d1.local": "ns1"},
+			expEvents: `Warning HostOwnership ns2/echo2: host 'd1.local' is owned by namespace 'ns1'`,
+			logging:   `ERROR skipping host 'd1.local' of Ingress 'ns2/echo2': host 'd1.local' is owned by namespace 'ns1'`,
+		},
+		// 2
+		{
+			owners:      map[string]string{"d1.local": "ns1"},
+			delegations: map[string]string{"ns1": "d2.local=ns3\nd1.local=ns3,ns2"},
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 20},
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 10},
+			},
+			expPaths:  []string{"d1.local/app2", "d1.local/app1"},
+			expOwners: map[string]string{"d1.local": "ns1"},
+		},
+		// 3
+		{
+			owners:      map[string]string{"d1.local": "ns1"},
+			delegations: map[string]string{"ns1": "d1.local=*\ninvalid"},
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 20},
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 10},
+			},
+			expPaths:  []string{"d1.local/app2", "d1.local/app1"},
+			expOwners: map[string]string{"d1.local": "ns1"},
+			logging:   `WARN ignoring invalid host delegation on namespace 'ns1': invalid`,
+		},
+		// 4
+		{
+			owners: map[string]string{"d1.local": "ns1"},
+			ings: []ing{
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 10},
+			},
+			expPaths:  []string{"d1.local/app2"},
+			expOwners: map[string]string{"d1.local": "ns2"},
+			logging:   `INFO releasing host 'd1.local' from namespace 'ns1': namespace does not have ingress resources using it`,
+		},
+		// 5
+		{
+			owners:   map[string]string{"*.example.com": "ns1"},
+			wildcard: true,
+			ings: []ing{
+				{name: "ns1/echo1", host: "*.example.com", path: "/app1", age: 20},
+				{name: "ns1/echo3", host: "d1.example.com", path: "/app3", age: 30},
+				{name: "ns2/echo2", host: "d2.example.com", path: "/app2", age: 10},
+			},
+			expPaths:  []string{"*.example.com/app1", "d1.example.com/app3"},
+			expOwners: map[string]string{"*.example.com": "ns1", "d1.example.com": "ns1"},
+			expEvents: `Warning HostOwnership ns2/echo2: host '*.example.com' is owned by namespace 'ns1'`,
+			logging:   `ERROR skipping host 'd2.example.com' of Ingress 'ns2/echo2': host '*.example.com' is owned by namespace 'ns1'`,
+		},
+		// 6
+		{
+			owners: map[string]string{"*.example.com": "ns1"},
+			ings: []ing{
+				{name: "ns1/echo1", host: "*.example.com", path: "/app1", age: 20},
+				{name: "ns2/echo2", host: "d2.example.com", path: "/app2", age: 10},
+			},
+			expPaths:  []string{"*.example.com/app1", "d2.example.com/app2"},
+			expOwners: map[string]string{"*.example.com": "ns1", "d2.example.com": "ns2"},
+		},
+		// 7
+		{
+			owners:      map[string]string{"*.example.com": "ns1"},
+			wildcard:    true,
+			delegations: map[string]string{"ns1": "*.example.com=ns2"},
+			ings: []ing{
+				{name: "ns1/echo1", host: "*.example.com", path: "/app1", age: 20},
+				{name: "ns2/echo2", host: "d2.example.com", path: "/app2", age: 10},
+			},
+			expPaths:  []string{"*.example.com/app1", "d2.example.com/app2"},
+			expOwners: map[string]string{"*.example.com": "ns1"},
+		},
+		// 8
+		{
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 10},
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 20},
+			},
+			ingDel:    []string{"ns1/echo1"},
+			expPaths:  []string{"d1.local/app2"},
+			expOwners: map[string]string{"d1.local": "ns2"},
+			logging: `
+INFO-V(2) syncing 1 host(s) and 1 backend(s)
+INFO releasing host 'd1.local' from namespace 'ns1': namespace does not have ingress resources using it`,
+		},
+		// 9
+		{
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 20},
+			},
+			restart: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 20},
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 10},
+			},
+			expPaths:  []string{"d1.local/app1"},
+			expOwners: map[string]string{"d1.local": "ns1"},
+			expEvents: `Warning HostOwnership ns2/echo2: host 'd1.local' is owned by namespace 'ns1'`,
+			logging:   `ERROR skipping host 'd1.local' of Ingress 'ns2/echo2': host 'd1.local' is owned by namespace 'ns1'`,
+		},
+		// 10
+		{
+			owners:    map[string]string{"d1.local": "ns2"},
+			ownersErr: true,
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 20},
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 10},
+				{name: "ns1/echo3", host: "d2.local", path: "/app3", age: 30},
+			},
+			expPaths:  []string{"d2.local/app3"},
+			expOwners: map[string]string{"d1.local": "ns2"},
+			expEvents: `
+Warning HostOwnership ns2/echo2: host 'd1.local' is used by more than one namespace and its owner could not be read
+Warning HostOwnership ns1/echo1: host 'd1.local' is used by more than one namespace and its owner could not be read`,
+			logging: `
+ERROR error reading host owners, skipping hosts used by more than one namespace on this sync: configmap not found
+ERROR skipping host 'd1.local' of Ingress 'ns2/echo2': host 'd1.local' is used by more than one namespace and its owner could not be read
+ERROR skipping host 'd1.local' of Ingress 'ns1/echo1': host 'd1.local' is used by more than one namespace and its owner could not be read`,
+		},
+		// 11
+		{
+			ings: []ing{
+				{name: "ns1/echo1", host: "d1.local", path: "/app1", age: 20},
+			},
+			ingAdd: []ing{
+				{name: "ns2/echo2", host: "d1.local", path: "/app2", age: 10},
+			},
+			ingAddErr: true,
+			expPaths:  []string{"d1.local/app1"},
+			expOwners: map[string]string{"d1.local": "ns1"},
+			expEvents: `Warning HostOwnership ns2/echo2: host 'd1.local' is owned by namespace 'ns1'`,
+			logging: `
+INFO-V(2) syncing 1 host(s) and 1 backend(s)
+ERROR error reading host owners, using the owners of the last sync: configmap not found
+ERROR skipping host 'd1.local' of Ingress 'ns2/echo2': host 'd1.local' is owned by namespace 'ns1'`,
+		},
+	}
+	for i, test := range testCases {
+		store := &hostOwnersMock{owners: test.owners}
+		if test.ownersErr {
+			store.err = fmt.Errorf("configmap not found")
+		}
+		if store.owners == nil {
+			store.owners = map[string]string{}
+		}
+		setupOwnership := func() *testConfig {
+			c := setup(t)
+			c.createSvc1("ns1/echo", "8080", "172.17.0.11")
+			c.createSvc1("ns2/echo", "8080", "172.17.0.21")
+			for _, ns := range []string{"ns1", "ns2"} {
+				c.cache.NsList[ns] = &api.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}
+			}
+			for ns, delegation := range test.delegations {
+				c.cache.NsList[ns].Annotations = map[string]string{"ingress.kubernetes.io/delegate-hosts": delegation}
+			}
+			c.hostOwnership = convtypes.HostOwnership{Owners: store, WildcardSubdomains: test.wildcard}
+			c.dynconfig = &convtypes.DynamicConfig{}
+			return c
+		}
+		createIng := func(c *testConfig, ing ing) *networking.Ingress {
+			item := c.createIng1(ing.name, "", ing.path, "echo:8080")
+			item.Spec.Rules[0].Host = ing.host
+			item.CreationTimestamp = metav1.Unix(int64(ing.age), 0)
+			if ing.tls {
+				item.Spec.TLS = []networking.IngressTLS{{Hosts: []string{ing.host}}}
+			}
+			return item
+		}
+		createIngs := func(c *testConfig, ings []ing) []*networking.Ingress {
+			var ingList []*networking.Ingress
+			for _, ing := range ings {
+				ingList = append(ingList, createIng(c, ing))
+			}
+			return ingList
+		}
+		c := setupOwnership()
+		c.Sync(createIngs(c, test.ings)...)
+		if len(test.ingDel) > 0 {
+			c.hconfig.Commit()
+			c.logger.Logging = []string{}
+			c.cache.Events = nil
+			for _, name := range test.ingDel {
+				c.cache.Changed.IngressesDel = append(c.cache.Changed.IngressesDel, c.createIng1(name, "", "/", "echo:8080"))
+			}
+			c.Sync()
+		}
+		if len(test.ingAdd) > 0 {
+			c.hconfig.Commit()
+			c.logger.CompareLogging("")
+			if test.ingAddErr {
+				store.err = fmt.Errorf("configmap not found")
+			}
+			c.cache.Changed.IngressesAdd = createIngs(c, test.ingAdd)
+			c.Sync(createIngs(c, append(test.ings, test.ingAdd...))...)
+		}
+		if test.restart != nil {
+			c.logger.CompareLogging("")
+			// a new controller instance, sharing only the persisted owners
+			c = setupOwnership()
+			c.Sync(createIngs(c, test.restart)...)
+		}
+		var paths []string
+		for _, host := range c.hconfig.Hosts().BuildSortedItems() {
+			for _, path := range host.Paths {
+				paths = append(paths, host.Hostname+path.Path())
+			}
+		}
+		c.compareText(strings.Join(paths, ","), strings.Join(test.expPaths, ","))
+		if !reflect.DeepEqual(store.owners, test.expOwners) {
+			t.Errorf("owners differ on %d - expected: %v - actual: %v", i, test.expOwners, store.owners)
+		}
+		c.compareText(strings.Join(c.cache.Events, "\n"), test.expEvents)
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+type pathTombstonesMock struct {
+	tombstones map[string]*convtypes.PathTombstone
+}
+
+func (t *pathTombstonesMock) Tombstones() (map[string]*convtypes.PathTombstone, error) {
+	return t.tombstones, nil
+}
+
+func (t *pathTombstonesMock) Update(tombstones map[string]*convtypes.PathTombstone) {
+	t.tombstones = tombstones
+}
+
+func TestSyncPathTombstones(t *testing.T) {
+	type ing struct {
+		name, path, svc string
+	}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		window     string
+		ings       []ing
+		ingDel     []string
+		ingAdd     []ing
+		elapsed    time.Duration
+		restart    []ing
+		expPaths   []string
+		expSunset  []string
+		expTombs   []string
+		logging    string
+		logging2nd string
+	}{
+		// 0
+		{
+			ings: []ing{
+				{name: "ing1", path: "/app1", svc: "echo1"},
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			ingDel:   []string{"ing1"},
+			expPaths: []string{"d1.local/app2:default_echo2_8080"},
+			logging:  `INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+		},
+		// 1
+		{
+			window: "1h",
+			ings: []ing{
+				{name: "ing1", path: "/app1", svc: "echo1"},
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			ingDel:    []string{"ing1"},
+			expPaths:  []string{"d1.local/app2:default_echo2_8080", "d1.local/app1:default_echo1_8080"},
+			expSunset: []string{`default_echo1_8080/app1:{Deprecation:@1790856000 Sunset:Thu, 01 Oct 2026 13:00:00 GMT}`},
+			expTombs:  []string{"default/ing1"},
+			logging: `
+INFO keeping the paths of the deleted ingress 'default/ing1' until 2026-10-01T13:00:00Z, see graceful-path-removal
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+		},
+		// 2
+		{
+			window: "1h",
+			ings: []ing{
+				{name: "ing1", path: "/app1", svc: "echo1"},
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			ingDel:   []string{"ing1"},
+			elapsed:  time.Hour,
+			expPaths: []string{"d1.local/app2:default_echo2_8080"},
+			logging: `
+INFO keeping the paths of the deleted ingress 'default/ing1' until 2026-10-01T13:00:00Z, see graceful-path-removal
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+			logging2nd: `
+INFO removing the paths of the deleted ingress 'default/ing1': graceful-path-removal window expired
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+		},
+		// 3
+		{
+			window: "1h",
+			ings: []ing{
+				{name: "ing1", path: "/app1", svc: "echo1"},
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			ingDel: []string{"ing1"},
+			ingAdd: []ing{
+				{name: "ing3", path: "/app1", svc: "echo2"},
+			},
+			expPaths: []string{"d1.local/app2:default_echo2_8080", "d1.local/app1:default_echo2_8080"},
+			logging: `
+INFO keeping the paths of the deleted ingress 'default/ing1' until 2026-10-01T13:00:00Z, see graceful-path-removal
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+			logging2nd: `
+INFO-V(2) syncing 1 host(s) and 2 backend(s)
+INFO cancelling the path tombstone of 'd1.local/app1' of the deleted ingress 'default/ing1': path was declared by another resource`,
+		},
+		// 4
+		{
+			window: "1h",
+			ings: []ing{
+				{name: "ing1", path: "/app1", svc: "echo1"},
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			ingDel: []string{"ing1"},
+			ingAdd: []ing{
+				{name: "ing1", path: "/app1", svc: "echo2"},
+			},
+			expPaths: []string{"d1.local/app2:default_echo2_8080", "d1.local/app1:default_echo2_8080"},
+			logging: `
+INFO keeping the paths of the deleted ingress 'default/ing1' until 2026-10-01T13:00:00Z, see graceful-path-removal
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+			logging2nd: `
+INFO cancelling the path tombstone of ingress 'default/ing1': ingress was created again
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+		},
+		// 5
+		{
+			window: "1h",
+			ings: []ing{
+				{name: "ing1", path: "/app1", svc: "echo1"},
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			ingDel: []string{"ing1"},
+			restart: []ing{
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			expPaths:  []string{"d1.local/app2:default_echo2_8080", "d1.local/app1:default_echo1_8080"},
+			expSunset: []string{`default_echo1_8080/app1:{Deprecation:@1790856000 Sunset:Thu, 01 Oct 2026 13:00:00 GMT}`},
+			expTombs:  []string{"default/ing1"},
+			logging: `
+INFO keeping the paths of the deleted ingress 'default/ing1' until 2026-10-01T13:00:00Z, see graceful-path-removal
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+		},
+		// 6
+		{
+			window: "1h",
+			ings: []ing{
+				{name: "ing1", path: "/app1", svc: "echo1"},
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			ingDel:  []string{"ing1"},
+			elapsed: 2 * time.Hour,
+			restart: []ing{
+				{name: "ing2", path: "/app2", svc: "echo2"},
+			},
+			expPaths: []string{"d1.local/app2:default_echo2_8080"},
+			logging: `
+INFO keeping the paths of the deleted ingress 'default/ing1' until 2026-10-01T13:00:00Z, see graceful-path-removal
+INFO-V(2) syncing 1 host(s) and 2 backend(s)`,
+			logging2nd: `
+INFO removing the paths of the deleted ingress 'default/ing1': graceful-path-removal window expired`,
+		},
+	}
+	for i, test := range testCases {
+		store := &pathTombstonesMock{tombstones: map[string]*convtypes.PathTombstone{}}
+		createIng := func(c *testConfig, ing ing) *networking.Ingress {
+			return c.createIng1("default/"+ing.name, "d1.local", ing.path, ing.svc+":8080")
+		}
+		createIngs := func(c *testConfig, ings []ing) []*networking.Ingress {
+			var ingList []*networking.Ingress
+			for _, ing := range ings {
+				ingList = append(ingList, createIng(c, ing))
+			}
+			return ingList
+		}
+		setupTombstones := func() *testConfig {
+			c := setup(t)
+			c.createSvc1("default/echo1", "8080", "172.17.0.11")
+			c.createSvc1("default/echo2", "8080", "172.17.0.21")
+			c.tombstones = store
+			c.cache.Changed.GlobalConfigMapDataNew = map[string]string{}
+			if test.window != "" {
+				c.cache.Changed.GlobalConfigMapDataNew[ingtypes.GlobalGracefulPathRemoval] = test.window
+			}
+			return c
+		}
+		sync := func(c *testConfig, elapsed time.Duration, full bool, ing ...*networking.Ingress) {
+			if ing != nil {
+				c.cache.IngList = ing
+			}
+			c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
+			conv := c.createConverter()
+			if conv.tombstones != nil {
+				conv.tombstones.now = func() time.Time { return now.Add(elapsed) }
+			}
+			conv.updater = c.updater
+			conv.Sync(full)
+		}
+		c := setupTombstones()
+		sync(c, 0, true, createIngs(c, test.ings)...)
+		c.hconfig.Commit()
+		c.logger.Logging = []string{}
+		for _, name := range test.ingDel {
+			c.cache.Changed.IngressesDel = append(c.cache.Changed.IngressesDel, c.createIng1("default/"+name, "d1.local", "/"+strings.Replace(name, "ing", "app", 1), "echo1:8080"))
+		}
+		sync(c, 0, false)
+		if test.restart != nil {
+			c.logger.CompareLogging(test.logging)
+			// a new controller instance, sharing only the persisted tombstones
+			c = setupTombstones()
+			sync(c, test.elapsed, true, createIngs(c, test.restart)...)
+			test.logging = test.logging2nd
+		} else if test.elapsed > 0 || test.ingAdd != nil {
+			c.logger.CompareLogging(test.logging)
+			c.hconfig.Commit()
+			for _, ing := range test.ingAdd {
+				c.cache.Changed.IngressesAdd = append(c.cache.Changed.IngressesAdd, createIng(c, ing))
+			}
+			sync(c, test.elapsed, false)
+			test.logging = test.logging2nd
+		}
+		var paths, sunset []string
+		for _, host := range c.hconfig.Hosts().BuildSortedItems() {
+			for _, path := range host.Paths {
+				paths = append(paths, host.Hostname+path.Path()+":"+path.Backend.ID)
+			}
+		}
+		for _, backend := range c.hconfig.Backends().BuildSortedItems() {
+			for _, path := range backend.Paths {
+				if path.Sunset.Sunset != "" {
+					sunset = append(sunset, fmt.Sprintf("%s%s:%+v", backend.ID, path.Path(), path.Sunset))
+				}
+			}
+		}
+		var tombs []string
+		for name := range store.tombstones {
+			tombs = append(tombs, name)
+		}
+		sort.Strings(tombs)
+		c.compareText(strings.Join(paths, ","), strings.Join(test.expPaths, ","))
+		c.compareText(strings.Join(sunset, ","), strings.Join(test.expSunset, ","))
+		if !reflect.DeepEqual(tombs, test.expTombs) {
+			t.Errorf("tombstones differ on %d - expected: %v - actual: %v", i, test.expTombs, tombs)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
 func TestSyncTCPServicePort(t *testing.T) {
 	testCases := []struct {
 		ing     [][]string
@@ -2024,6 +3425,127 @@ func TestAnnPrefix(t *testing.T) {
 	c.logger.CompareLogging(`WARN annotation 'ingress.kubernetes.io/balance-algorithm' on Ingress 'default/app1' was ignored due to conflict with another annotation(s) for the same 'balance-algorithm' configuration key`)
 }
 
+func TestAnnotationUsage(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	prefix1 := "haproxy-ingress.github.io"
+	prefix2 := "ingress.kubernetes.io"
+
+	usage := annotations.NewUsageCollector(false)
+	c.annUsage = usage
+	c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
+	sync := func(ing ...*networking.Ingress) {
+		conv := c.createConverter()
+		conv.options.AnnotationPrefix = []string{prefix1, prefix2}
+		// only the first sync is a full one
+		c.cache.Changed.GlobalConfigMapDataNew = c.cache.Changed.GlobalConfigMapDataCur
+		c.SyncConverter(conv, ing...)
+	}
+
+	c.createSvc1Auto()
+	ing1 := c.createIng1Ann("default/app1", "app1.local", "/", "echo:8080", map[string]string{
+		prefix1 + "/" + ingtypes.BackBalanceAlgorithm: "leastconn",
+		prefix2 + "/" + ingtypes.BackMaxconnServer:    "1000",
+		prefix2 + "/" + ingtypes.HostAppRoot:          "/app",
+	})
+	ing2 := c.createIng1Ann("default/app2", "app2.local", "/", "echo:8080", map[string]string{
+		prefix2 + "/" + ingtypes.BackBalanceAlgorithm: "leastconn",
+		prefix1 + "/" + ingtypes.BackMaxconnServer:    "1000",
+		prefix2 + "/" + ingtypes.BackMaxconnServer:    "1000",
+		"kubernetes.io/ingress.class":                 "haproxy",
+	})
+	sync(ing1, ing2)
+
+	// aliases are attributed to the canonical name, defaults
+	// (initial-weight) and annotations of other prefixes are not counted
+	expected := []annotations.KeyUsage{
+		{Key: ingtypes.HostAppRoot, Ingresses: 1},
+		{Key: ingtypes.BackBalanceAlgorithm, Ingresses: 2},
+		{Key: ingtypes.BackMaxconnServer, Ingresses: 2},
+	}
+	if actual := usage.Usage(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("usage differs - expected: %+v - actual: %+v", expected, actual)
+	}
+
+	c.hconfig.Commit()
+	c.cache.Changed.IngressesDel = []*networking.Ingress{ing2}
+	sync()
+	c.logger.CompareLogging(`INFO-V(2) syncing 2 host(s) and 1 backend(s)`)
+
+	expected = []annotations.KeyUsage{
+		{Key: ingtypes.HostAppRoot, Ingresses: 1},
+		{Key: ingtypes.BackBalanceAlgorithm, Ingresses: 1},
+		{Key: ingtypes.BackMaxconnServer, Ingresses: 1},
+	}
+	if actual := usage.Usage(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("usage after removing app2 differs - expected: %+v - actual: %+v", expected, actual)
+	}
+}
+
+func TestAnnotationMigration(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	prefix1 := "haproxy-ingress.github.io"
+	prefix2 := "ingress.kubernetes.io"
+
+	migration := annotations.NewMigrationCollector([]string{prefix1, prefix2})
+	c.annMigration = migration
+	c.cache.SecretTLSPath["system/default"] = "/tls/tls-default.pem"
+	sync := func(ing ...*networking.Ingress) {
+		conv := c.createConverter()
+		conv.options.AnnotationPrefix = []string{prefix1, prefix2}
+		c.cache.Changed.GlobalConfigMapDataNew = c.cache.Changed.GlobalConfigMapDataCur
+		c.SyncConverter(conv, ing...)
+	}
+
+	c.createSvc1Auto()
+	ing1 := c.createIng1Ann("default/app1", "app1.local", "/", "echo:8080", map[string]string{
+		prefix1 + "/" + ingtypes.BackBalanceAlgorithm: "leastconn",
+		prefix2 + "/" + ingtypes.BackBalanceAlgorithm: "roundrobin",
+		prefix2 + "/" + ingtypes.BackProxyBodySize:    "10m",
+	})
+	ing2 := c.createIng1Ann("default/app2", "app2.local", "/", "echo:8080", map[string]string{
+		prefix1 + "/" + ingtypes.BackMaxconnServer: "1000",
+		prefix2 + "/" + ingtypes.BackMaxconnServer: "1000",
+		"kubernetes.io/ingress.class":              "haproxy",
+	})
+	sync(ing1, ing2)
+
+	bodySizeDiff := "defaults to unlimited, a size without suffix is in bytes"
+	expected := &annotations.MigrationReport{
+		Prefixes: []string{prefix1, prefix2},
+		Ingresses: []annotations.MigrationIngress{
+			{Namespace: "default", Name: "app1", Keys: []annotations.MigrationKey{
+				{Key: ingtypes.BackBalanceAlgorithm, Prefix: prefix1, Ignored: []string{prefix2}, Conflict: true},
+				{Key: ingtypes.BackProxyBodySize, Prefix: prefix2, Difference: bodySizeDiff},
+			}},
+			{Namespace: "default", Name: "app2", Keys: []annotations.MigrationKey{
+				{Key: ingtypes.BackMaxconnServer, Prefix: prefix1, Ignored: []string{prefix2}},
+			}},
+		},
+		Differences: []annotations.MigrationDiffUsed{
+			{Key: ingtypes.BackProxyBodySize, Difference: bodySizeDiff, Ingresses: map[string]int{prefix2: 1}},
+		},
+	}
+	if actual := migration.Report(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("migration report differs - expected: %+v - actual: %+v", expected, actual)
+	}
+	c.logger.CompareLogging(`WARN annotation 'ingress.kubernetes.io/balance-algorithm' on Ingress 'default/app1' was ignored due to conflict with another annotation(s) for the same 'balance-algorithm' configuration key`)
+
+	c.hconfig.Commit()
+	c.cache.Changed.IngressesDel = []*networking.Ingress{ing1}
+	sync()
+	c.logger.CompareLogging(`INFO-V(2) syncing 2 host(s) and 1 backend(s)`)
+
+	expected.Ingresses = expected.Ingresses[1:]
+	expected.Differences = []annotations.MigrationDiffUsed{}
+	if actual := migration.Report(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("migration report after removing app1 differs - expected: %+v - actual: %+v", expected, actual)
+	}
+}
+
 func TestSyncAnnFront(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -2064,6 +3586,10 @@ func TestSyncAnnFrontsConflict(t *testing.T) {
     backend: default_echo_8080
   - path: /
     backend: default_echo_8080`)
+
+	c.logger.CompareLogging(`
+WARN ignoring global configuration key 'timeout-client' on Ingress 'default/echo1': global keys are only read from the global config
+WARN ignoring global configuration key 'timeout-client' on Ingress 'default/echo2': global keys are only read from the global config`)
 }
 
 func TestSyncAnnFronts(t *testing.T) {
@@ -2089,6 +3615,10 @@ func TestSyncAnnFronts(t *testing.T) {
   paths:
   - path: /app2
     backend: default_echo_8080`)
+
+	c.logger.CompareLogging(`
+WARN ignoring global configuration key 'timeout-client' on Ingress 'default/echo1': global keys are only read from the global config
+WARN ignoring global configuration key 'timeout-client' on Ingress 'default/echo2': global keys are only read from the global config`)
 }
 
 func TestSyncAnnFrontDefault(t *testing.T) {
@@ -2120,6 +3650,10 @@ func TestSyncAnnFrontDefault(t *testing.T) {
   paths:
   - path: /app
     backend: default_echo_8080`)
+
+	c.logger.CompareLogging(`
+WARN ignoring global configuration key 'timeout-client' on Ingress 'default/echo1': global keys are only read from the global config
+WARN ignoring global configuration key 'timeout-client' on Ingress 'default/echo2': global keys are only read from the global config`)
 }
 
 func TestSyncAnnBack(t *testing.T) {
@@ -2160,12 +3694,17 @@ func TestSyncAnnBackSvcIngConflict(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	c.createSvc1AutoAnn(map[string]string{
+	svc, _ := c.createSvc1AutoAnn(map[string]string{
 		"ingress.kubernetes.io/balance-algorithm": "leastconn",
 	})
-	c.Sync(c.createIng1Ann("default/echo", "echo.example.com", "/", "echo:8080", map[string]string{
+	svc.UID = "4f1c2a9e"
+	svc.Generation = 1
+	ing := c.createIng1Ann("default/echo", "echo.example.com", "/", "echo:8080", map[string]string{
 		"ingress.kubernetes.io/balance-algorithm": "first",
-	}))
+	})
+	ing.UID = "b83d7e05"
+	ing.Generation = 2
+	c.Sync(ing)
 
 	c.compareConfigBack(`
 - id: default_echo_8080
@@ -2175,7 +3714,11 @@ func TestSyncAnnBackSvcIngConflict(t *testing.T) {
   balancealgorithm: leastconn` + defaultBackendConfig)
 
 	c.logger.CompareLogging(`
+INFO-V(2) dropped configuration key: reason=conflict key=balance-algorithm namespace=default source="Ingress 'default/echo' (uid 'b83d7e05', generation 2)" winner="Service 'default/echo' (uid '4f1c2a9e', generation 1)"
 WARN skipping backend 'echo:8080' annotation(s) from Ingress 'default/echo' due to conflict: [balance-algorithm]`)
+	if count := c.metrics.AnnotationsDropped["default/balance-algorithm"]; count != 1 {
+		t.Errorf("expected 1 dropped annotation metric, but was %d", count)
+	}
 }
 
 func TestSyncAnnBacksSvcIng(t *testing.T) {
@@ -2308,7 +3851,9 @@ func TestSyncAnnBackDefault(t *testing.T) {
   balancealgorithm: roundrobin`)
 
 	c.logger.CompareLogging(`
+INFO-V(2) dropped configuration key: reason=conflict key=balance-algorithm namespace=default source="Ingress 'default/echo5'" winner="Service 'default/echo5'"
 WARN skipping backend 'echo5:8080' annotation(s) from Ingress 'default/echo5' due to conflict: [balance-algorithm]
+INFO-V(2) dropped configuration key: reason=conflict key=balance-algorithm namespace=default source="Ingress 'default/echo7'" winner="Service 'default/echo7'"
 WARN skipping backend 'echo7:8080' annotation(s) from Ingress 'default/echo7' due to conflict: [balance-algorithm]`)
 }
 
@@ -2371,6 +3916,326 @@ func TestSyncAnnAuthURL(t *testing.T) {
 	c.logger.CompareLogging(`WARN skipping auth-url on Ingress 'default/echo2': service not found: 'default/authsvc2'`)
 }
 
+func TestSyncAnnFallbackBackend(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo1", "http:8080", "172.17.1.101")
+	c.createSvc1("default/echo2", "http:8080", "172.17.1.102")
+	c.createSvc1("default/fallback", "http:8080", "172.17.1.110")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo1:8080",
+			map[string]string{
+				"ingress.kubernetes.io/fallback-backend": "fallback:8080",
+			}),
+		c.createIng1Ann("default/echo2", "echo2.example.com", "/", "echo2:8080",
+			map[string]string{
+				"ingress.kubernetes.io/fallback-backend": "missing:8080",
+			}),
+	)
+
+	c.compareConfigBack(`
+- id: default_echo1_8080
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+- id: default_echo2_8080
+  endpoints:
+  - ip: 172.17.1.102
+    port: 8080
+- id: default_fallback_8080
+  endpoints:
+  - ip: 172.17.1.110
+    port: 8080
+- id: system_default_8080
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`)
+	expected := hatypes.BackendID{Namespace: "default", Name: "fallback", Port: "8080"}
+	if actual := c.hconfig.Backends().FindBackend("default", "echo1", "8080").Fallback; actual != expected {
+		c.t.Errorf("fallback differs, expected %v but was %v", expected, actual)
+	}
+	c.logger.CompareLogging(`WARN skipping fallback-backend on Ingress 'default/echo2': service not found: 'default/missing'`)
+}
+
+func TestSyncAnnUseBackendClass(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo1", "http:8080", "172.17.1.101")
+	c.createSvc1("default/echo1-api", "http:8080", "172.17.1.110")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo1:8080",
+			map[string]string{
+				"ingress.kubernetes.io/use-backend-class": "api=echo1-api:8080,bots=missing:8080",
+			}),
+	)
+
+	c.compareConfigBack(`
+- id: default_echo1-api_8080
+  endpoints:
+  - ip: 172.17.1.110
+    port: 8080
+- id: default_echo1_8080
+  endpoints:
+  - ip: 172.17.1.101
+    port: 8080
+- id: system_default_8080
+  endpoints:
+  - ip: 172.17.0.99
+    port: 8080
+`)
+	c.logger.CompareLogging(`WARN skipping use-backend-class on Ingress 'default/echo1': service not found: 'default/missing'`)
+}
+
+func TestSyncAnnSplitBackends(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo1", "http:8080", "172.17.1.101")
+	c.createSvc1("default/echo1-v2", "http:8080", "172.17.1.102")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo1:8080",
+			map[string]string{
+				"ingress.kubernetes.io/allowlist-source-range": "10.0.0.0/8",
+				"ingress.kubernetes.io/balance-algorithm":      "leastconn",
+				"ingress.kubernetes.io/split-backends":         "echo1-v2:8080=10,missing:8080=10",
+			}),
+	)
+
+	for _, name := range []string{"echo1", "echo1-v2"} {
+		backend := c.hconfig.Backends().FindBackend("default", name, "8080")
+		if len(backend.Paths) != 1 {
+			c.t.Errorf("expected one path on backend '%s', found %d", backend.ID, len(backend.Paths))
+			continue
+		}
+		// split backends are only configured with the path scoped keys
+		if expected := []string{"10.0.0.0/8"}; !reflect.DeepEqual(backend.Paths[0].AllowedIPHTTP.Rule, expected) {
+			c.t.Errorf("allowlist of backend '%s' differs, expected %v but was %v", backend.ID, expected, backend.Paths[0].AllowedIPHTTP.Rule)
+		}
+	}
+	if balance := c.hconfig.Backends().FindBackend("default", "echo1-v2", "8080").BalanceAlgorithm; balance == "leastconn" {
+		c.t.Errorf("expected the balance algorithm of the split backend not copied from the primary backend")
+	}
+	c.logger.CompareLogging(`WARN skipping split-backends on Ingress 'default/echo1': service not found: 'default/missing'`)
+}
+
+func TestSyncAnnFallbackBackendCircular(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo1", "http:8080", "172.17.1.101")
+	c.createSvc1("default/echo2", "http:8080", "172.17.1.102")
+	c.createSvc1("default/echo3", "http:8080", "172.17.1.103")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo1:8080",
+			map[string]string{
+				"ingress.kubernetes.io/fallback-backend": "echo2:8080",
+			}),
+		c.createIng1Ann("default/echo2", "echo2.example.com", "/", "echo2:8080",
+			map[string]string{
+				"ingress.kubernetes.io/fallback-backend": "echo3:8080",
+			}),
+		c.createIng1Ann("default/echo3", "echo3.example.com", "/", "echo3:8080",
+			map[string]string{
+				"ingress.kubernetes.io/fallback-backend": "echo1:8080",
+			}),
+	)
+
+	for name, expected := range map[string]hatypes.BackendID{
+		"echo1": {},
+		"echo2": {Namespace: "default", Name: "echo3", Port: "8080"},
+		"echo3": {Namespace: "default", Name: "echo1", Port: "8080"},
+	} {
+		if actual := c.hconfig.Backends().FindBackend("default", name, "8080").Fallback; actual != expected {
+			c.t.Errorf("fallback of %s differs, expected %v but was %v", name, expected, actual)
+		}
+	}
+	c.logger.CompareLogging(`ERROR circular fallback backend reference, ignoring fallback of backend 'default_echo1_8080': default_echo1_8080 -> default_echo2_8080 -> default_echo3_8080 -> default_echo1_8080`)
+}
+
+func TestSyncAnnSharedCookie(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo1", "http:8080", "172.17.1.101")
+	c.createSvc1("default/echo2", "http:8080", "172.17.1.102")
+	c.createSvc1("default/echo3", "http:8080", "172.17.1.103")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo1:8080",
+			map[string]string{
+				"ingress.kubernetes.io/session-cookie-name":   "serverId",
+				"ingress.kubernetes.io/session-cookie-shared": "true",
+			}),
+		c.createIng1Ann("default/echo2", "echo1.example.com", "/api", "echo2:8080",
+			map[string]string{
+				"ingress.kubernetes.io/session-cookie-name":   "apiServerId",
+				"ingress.kubernetes.io/session-cookie-shared": "true",
+			}),
+		// echo3 does not share its cookie, so echo2.example.com is not checked
+		c.createIng1Ann("default/echo3", "echo2.example.com", "/", "echo3:8080",
+			map[string]string{
+				"ingress.kubernetes.io/session-cookie-name": "echo3",
+			}),
+		c.createIng1Ann("default/echo4", "echo2.example.com", "/api", "echo2:8080",
+			map[string]string{}),
+	)
+
+	c.logger.CompareLogging(`WARN configuration key 'session-cookie-name' from Ingress 'default/echo1' conflicts with the same key with distinct value from Ingress 'default/echo2' on the shared cookie of host 'echo1.example.com'`)
+}
+
+func TestSyncAnnRedirectLoop(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo", "http:8080", "172.17.1.101")
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/app", "echo:8080",
+			map[string]string{
+				"ingress.kubernetes.io/app-root":    "/app",
+				"ingress.kubernetes.io/redirect-to": "https://echo2.example.com/login",
+			}),
+		c.createIng1Ann("default/echo2", "echo2.example.com", "/login", "echo:8080",
+			map[string]string{
+				"ingress.kubernetes.io/redirect-to": "https://echo1.example.com/",
+			}),
+		c.createIng1Ann("default/echo3", "echo3.example.com", "/", "echo:8080",
+			map[string]string{
+				"ingress.kubernetes.io/app-root": "/login",
+			}),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo1.example.com
+  paths:
+  - path: /app
+    backend: _error404
+- hostname: echo2.example.com
+  paths:
+  - path: /login
+    backend: _error404
+- hostname: echo3.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+  rootredirect: /login
+`)
+	c.logger.CompareLogging(`ERROR redirect loop, ignoring the redirects of redirect-to on Ingress 'default/echo2', app-root on Ingress 'default/echo1', redirect-to on Ingress 'default/echo1': https://echo2.example.com/login -> https://echo1.example.com/ -> https://echo1.example.com/app -> https://echo2.example.com/login`)
+}
+
+func TestSyncAnnRedirectMaxDepth(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.createSvc1("default/echo", "http:8080", "172.17.1.101")
+	c.cache.Changed.GlobalConfigMapDataNew = map[string]string{
+		ingtypes.GlobalRedirectMaxDepth: "2",
+	}
+	c.Sync(
+		c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo:8080",
+			map[string]string{
+				"ingress.kubernetes.io/app-root":     "/app",
+				"ingress.kubernetes.io/ssl-redirect": "true",
+			}),
+		c.createIng1Ann("default/echo1app", "echo1.example.com", "/app", "echo:8080",
+			map[string]string{
+				"ingress.kubernetes.io/redirect-to": "https://echo2.example.com/",
+			}),
+		c.createIng1Ann("default/echo3", "echo3.example.com", "/", "echo:8080",
+			map[string]string{
+				"ingress.kubernetes.io/redirect-from": "www.echo3.example.com",
+				"ingress.kubernetes.io/ssl-redirect":  "true",
+			}),
+	)
+
+	c.compareConfigFront(`
+- hostname: echo1.example.com
+  paths:
+  - path: /app
+    backend: _error404
+  - path: /
+    backend: default_echo_8080
+- hostname: echo3.example.com
+  paths:
+  - path: /
+    backend: default_echo_8080
+`)
+	if redir := c.hconfig.Hosts().FindHost("echo3.example.com").Redirect.RedirectHost; redir != "www.echo3.example.com" {
+		c.t.Errorf("expected redirect from 'www.echo3.example.com', but was '%s'", redir)
+	}
+	c.logger.CompareLogging(`ERROR redirect chain longer than 2 hops, ignoring the redirects of ssl-redirect on Ingress 'default/echo1', app-root on Ingress 'default/echo1', redirect-to on Ingress 'default/echo1app': http://echo1.example.com/ -> https://echo1.example.com/ -> https://echo1.example.com/app -> https://echo2.example.com/`)
+}
+
+func TestSyncLint(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.dynconfig = &convtypes.DynamicConfig{}
+	c.createSvc1("default/echo1", "http:8080", "172.17.1.101,172.17.1.102")
+	c.createSvc1("default/echo2", "http:8080", "172.17.1.103,172.17.1.104")
+	createIng := func(name string, maxconn string) *networking.Ingress {
+		return c.createIng1Ann("default/"+name, name+".example.com", "/", name+":8080",
+			map[string]string{
+				"ingress.kubernetes.io/maxconn-server": maxconn,
+			})
+	}
+	c.Sync(createIng("echo1", "1"), createIng("echo2", "2"))
+
+	c.logger.CompareLogging(`WARN lint rule maxconn-server-below-replicas on backend 'default_echo1_8080': maxconn-server 1 is below the number of replicas 2`)
+	c.compareText(strings.Join(c.cache.Events, "\n"), `Warning ConfigLint default/echo1: maxconn-server-below-replicas: maxconn-server 1 is below the number of replicas 2`)
+	if count := c.metrics.LintFindings["maxconn-server-below-replicas"]; count != 1 {
+		t.Errorf("expected 1 lint finding metric, but was %d", count)
+	}
+
+	syncPartial := func(ingUpd ...*networking.Ingress) {
+		c.hconfig.Commit()
+		c.cache.Events = nil
+		// an unchanged global config leads to a partial sync
+		c.cache.Changed.GlobalConfigMapDataCur = map[string]string{}
+		c.cache.Changed.IngressesUpd = ingUpd
+		c.Sync()
+	}
+
+	// findings already reported are not reported again
+	syncPartial(createIng("echo1", "1"), createIng("echo2", "1"))
+
+	c.logger.CompareLogging(`
+INFO-V(2) syncing 2 host(s) and 2 backend(s)
+WARN lint rule maxconn-server-below-replicas on backend 'default_echo2_8080': maxconn-server 1 is below the number of replicas 2`)
+	c.compareText(strings.Join(c.cache.Events, "\n"), `Warning ConfigLint default/echo2: maxconn-server-below-replicas: maxconn-server 1 is below the number of replicas 2`)
+	if count := c.metrics.LintFindings["maxconn-server-below-replicas"]; count != 2 {
+		t.Errorf("expected 2 lint finding metric, but was %d", count)
+	}
+
+	// findings of the unchanged backends are preserved, fixed ones are not counted anymore
+	syncPartial(createIng("echo1", "2"))
+
+	c.logger.CompareLogging(`INFO-V(2) syncing 1 host(s) and 1 backend(s)`)
+	c.compareText(strings.Join(c.cache.Events, "\n"), ``)
+	if count := c.metrics.LintFindings["maxconn-server-below-replicas"]; count != 1 {
+		t.Errorf("expected 1 lint finding metric, but was %d", count)
+	}
+}
+
+func TestSyncLintFollower(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.isLeader = func() bool { return false }
+	c.createSvc1("default/echo1", "http:8080", "172.17.1.101,172.17.1.102")
+	c.Sync(c.createIng1Ann("default/echo1", "echo1.example.com", "/", "echo1:8080",
+		map[string]string{
+			"ingress.kubernetes.io/maxconn-server": "1",
+		}))
+
+	c.logger.CompareLogging(`WARN lint rule maxconn-server-below-replicas on backend 'default_echo1_8080': maxconn-server 1 is below the number of replicas 2`)
+	c.compareText(strings.Join(c.cache.Events, "\n"), ``)
+	if count := c.metrics.LintFindings["maxconn-server-below-replicas"]; count != 1 {
+		t.Errorf("expected 1 lint finding metric, but was %d", count)
+	}
+}
+
 func TestSyncAnnPassthrough(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -2469,9 +4334,19 @@ type testConfig struct {
 	decode  func(data []byte, defaults *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error)
 	hconfig haproxy.Config
 	logger  *types_helper.LoggerMock
+	metrics *types_helper.MetricsMock
 	cache   *conv_helper.CacheMock
 	tracker convtypes.Tracker
 	updater *updaterMock
+	// dynamic config and model limits shared by all the converters, if assigned
+	dynconfig     *convtypes.DynamicConfig
+	isLeader      func() bool
+	modelLimits   convtypes.ModelLimits
+	hostOwnership convtypes.HostOwnership
+	tombstones    convtypes.PathTombstones
+	podName       string
+	annUsage      convtypes.AnnotationUsage
+	annMigration  convtypes.AnnotationMigration
 }
 
 func setup(t *testing.T) *testConfig {
@@ -2483,6 +4358,7 @@ func setup(t *testing.T) *testConfig {
 		hconfig: haproxy.CreateInstance(logger, haproxy.InstanceOptions{}).Config(),
 		cache:   conv_helper.NewCacheMock(tracker),
 		logger:  logger,
+		metrics: types_helper.NewMetricsMock(),
 		tracker: tracker,
 		updater: &updaterMock{},
 	}
@@ -2533,16 +4409,28 @@ func (c *testConfig) createConverter() *converter {
 			ingtypes.BackInitialWeight: "100",
 		}
 	}
+	dynconfig := c.dynconfig
+	if dynconfig == nil {
+		dynconfig = &convtypes.DynamicConfig{}
+	}
 	return NewIngressConverter(
 		&convtypes.ConverterOptions{
-			Cache:            c.cache,
-			Logger:           c.logger,
-			Tracker:          c.tracker,
-			DynamicConfig:    &convtypes.DynamicConfig{},
-			DefaultConfig:    defaultConfig,
-			DefaultBackend:   "system/default",
-			DefaultCrtSecret: "system/default",
-			AnnotationPrefix: []string{"ingress.kubernetes.io"},
+			Cache:               c.cache,
+			Logger:              c.logger,
+			Tracker:             c.tracker,
+			Metrics:             c.metrics,
+			DynamicConfig:       dynconfig,
+			ModelLimits:         c.modelLimits,
+			HostOwnership:       c.hostOwnership,
+			PathTombstones:      c.tombstones,
+			PodName:             c.podName,
+			IsLeader:            c.isLeader,
+			AnnotationUsage:     c.annUsage,
+			AnnotationMigration: c.annMigration,
+			DefaultConfig:       defaultConfig,
+			DefaultBackend:      "system/default",
+			DefaultCrtSecret:    "system/default",
+			AnnotationPrefix:    []string{"ingress.kubernetes.io"},
 		},
 		c.hconfig,
 		c.cache.SwapChangedObjects(),
@@ -2761,16 +4649,46 @@ func (u *updaterMock) UpdateTCPHostConfig(tcpPort *hatypes.TCPServicePort, tcpHo
 }
 
 func (u *updaterMock) UpdateHostConfig(host *hatypes.Host, mapper *annotations.Mapper) {
-	host.RootRedirect = mapper.Get(ingtypes.HostAppRoot).Value
+	if appRoot := mapper.Get(ingtypes.HostAppRoot); appRoot.Value != "" {
+		host.AddRedirectIntent(hatypes.RedirectAppRoot, nil, appRoot.Value, appRoot.Source.String())
+	}
+	if redir := mapper.Get(ingtypes.HostRedirectFrom); redir.Value != "" {
+		host.AddRedirectIntent(hatypes.RedirectFrom, nil, redir.Value, redir.Source.String())
+	}
 }
 
 func (u *updaterMock) UpdateBackendConfig(backend *hatypes.Backend, mapper *annotations.Mapper) {
 	backend.Server.MaxConn = mapper.Get(ingtypes.BackMaxconnServer).Int()
 	backend.BalanceAlgorithm = mapper.Get(ingtypes.BackBalanceAlgorithm).Value
+	backend.Cookie.Name = mapper.Get(ingtypes.BackSessionCookieName).Value
+	if namespace, name, port, err := ingutils.ParseServicePort(mapper.Get(ingtypes.BackFallbackBackend).Value); err == nil {
+		if namespace == "" {
+			namespace = backend.Namespace
+		}
+		backend.Fallback = hatypes.BackendID{Namespace: namespace, Name: name, Port: port}
+	}
 	for _, path := range backend.Paths {
 		config := mapper.GetConfig(path.Link)
 		path.MaxBodySize = config.Get(ingtypes.BackProxyBodySize).Int64()
+		path.SSLRedirect = config.Get(ingtypes.BackSSLRedirect).Bool()
+		if allowlist := config.Get(ingtypes.BackAllowlistSourceRange).Value; allowlist != "" {
+			path.AllowedIPHTTP.Rule = strings.Split(allowlist, ",")
+		}
+	}
+}
+
+func (u *updaterMock) Lint(backends []*hatypes.Backend) []*annotations.LintFinding {
+	var findings []*annotations.LintFinding
+	for _, backend := range backends {
+		if maxconn := backend.Server.MaxConn; maxconn > 0 && maxconn < len(backend.Endpoints) {
+			findings = append(findings, &annotations.LintFinding{
+				Rule:    "maxconn-server-below-replicas",
+				Backend: backend,
+				Message: fmt.Sprintf("maxconn-server %d is below the number of replicas %d", maxconn, len(backend.Endpoints)),
+			})
+		}
 	}
+	return findings
 }
 
 func (c *testConfig) compareConfigTCPService(expected string) {
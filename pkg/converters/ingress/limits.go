@@ -0,0 +1,364 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// names of the model limits, used on logging, events and metrics
+const (
+	limitIngresses = "ingresses"
+	limitHosts     = "hosts"
+	limitBackends  = "backends"
+	limitEndpoints = "endpoints"
+)
+
+// modelLimits protects the controller from a runaway number of ingress
+// resources. Ingress resources are admitted into the model, as a whole,
+// while the number of ingresses, hosts, backends and endpoints are within
+// the configured limits. Admitted ingress resources are never evicted on
+// partial syncs, even if they are changed, so the running configuration is
+// kept stable, and rejected ones are retried when room might have been
+// made. Endpoints added to the backends of admitted ingress resources are
+// limited as well. A nil *modelLimits admits everything.
+type modelLimits struct {
+	c         *converter
+	conf      convtypes.ModelLimits
+	dynconfig *convtypes.DynamicConfig
+	rejected  map[string]string
+	retry     bool
+	hosts     int
+	backends  int
+	endpoints int
+}
+
+func newModelLimits(c *converter) *modelLimits {
+	conf := c.options.ModelLimits
+	if conf.MaxIngresses <= 0 && conf.MaxHosts <= 0 && conf.MaxBackends <= 0 && conf.MaxEndpoints <= 0 {
+		return nil
+	}
+	l := &modelLimits{
+		c:         c,
+		conf:      conf,
+		dynconfig: c.options.DynamicConfig,
+	}
+	if l.dynconfig.AdmittedIngresses == nil || l.dynconfig.RejectedIngresses == nil {
+		l.reset()
+	}
+	// rejections already notified, so events aren't repeated on every sync
+	l.rejected = l.dynconfig.RejectedIngresses
+	return l
+}
+
+// reset starts the admission from scratch, should be called on full syncs.
+// New maps are assigned so copies of the dynamic config aren't changed.
+func (l *modelLimits) reset() {
+	if l == nil {
+		return
+	}
+	l.dynconfig.AdmittedIngresses = map[string]bool{}
+	l.dynconfig.RejectedIngresses = map[string]string{}
+	l.retry = true
+}
+
+// remove removes deleted ingress resources from the admission state.
+func (l *modelLimits) remove(ingList []*networking.Ingress) {
+	if l == nil || len(ingList) == 0 {
+		return
+	}
+	admitted := make(map[string]bool, len(l.dynconfig.AdmittedIngresses))
+	for name := range l.dynconfig.AdmittedIngresses {
+		admitted[name] = true
+	}
+	rejected := make(map[string]string, len(l.dynconfig.RejectedIngresses))
+	for name, limit := range l.dynconfig.RejectedIngresses {
+		rejected[name] = limit
+	}
+	for _, ing := range ingList {
+		name := ing.Namespace + "/" + ing.Name
+		delete(admitted, name)
+		delete(rejected, name)
+	}
+	l.dynconfig.AdmittedIngresses = admitted
+	l.dynconfig.RejectedIngresses = rejected
+	l.rejected = rejected
+}
+
+// pending returns the ingress resources rejected by a limit, they should be
+// synced again in the case the model has room for them. Room is only made
+// when an ingress resource is removed or changed, so they are retried only
+// in such case.
+func (l *modelLimits) pending() []*networking.Ingress {
+	if l == nil || len(l.dynconfig.RejectedIngresses) == 0 {
+		return nil
+	}
+	changed := l.c.changed
+	if len(changed.IngressesDel)+len(changed.IngressesUpd) == 0 {
+		return nil
+	}
+	l.retry = true
+	ingList := make([]*networking.Ingress, 0, len(l.dynconfig.RejectedIngresses))
+	for name := range l.dynconfig.RejectedIngresses {
+		if ing, err := l.c.cache.GetIngress(name); err == nil {
+			ingList = append(ingList, ing)
+		}
+	}
+	return ingList
+}
+
+// sort moves ingress resources of higher priority classes to the start of
+// an already sorted list, so they are admitted first. Ingress resources
+// whose class isn't listed in the class priority are moved to the end.
+func (l *modelLimits) sort(ingList []*networking.Ingress) {
+	if l == nil || len(l.conf.ClassPriority) == 0 {
+		return
+	}
+	priority := make(map[string]int, len(l.conf.ClassPriority))
+	for i, class := range l.conf.ClassPriority {
+		if _, found := priority[class]; !found {
+			priority[class] = i
+		}
+	}
+	rank := func(ing *networking.Ingress) int {
+		if p, found := priority[readIngressClassName(ing)]; found {
+			return p
+		}
+		return len(l.conf.ClassPriority)
+	}
+	sort.SliceStable(ingList, func(i, j int) bool {
+		return rank(ingList[i]) < rank(ingList[j])
+	})
+}
+
+// start counts the objects already in the model, should be called just
+// before the first ingress resource is admitted.
+func (l *modelLimits) start() {
+	if l == nil {
+		return
+	}
+	haproxy := l.c.haproxy
+	l.hosts = len(haproxy.Hosts().Items())
+	l.backends = len(haproxy.Backends().Items())
+	l.endpoints = 0
+	for _, backend := range haproxy.Backends().Items() {
+		l.endpoints += len(backend.Endpoints)
+	}
+	admitted := make(map[string]bool, len(l.dynconfig.AdmittedIngresses))
+	for name := range l.dynconfig.AdmittedIngresses {
+		admitted[name] = true
+	}
+	l.dynconfig.AdmittedIngresses = admitted
+	// rejected ingress resources that aren't retried continue rejected
+	rejected := map[string]string{}
+	if !l.retry {
+		for name, limit := range l.rejected {
+			rejected[name] = limit
+		}
+	}
+	l.dynconfig.RejectedIngresses = rejected
+}
+
+// admit checks if an ingress resource fits into the model, returning false
+// if it should be skipped. Hosts, backends and endpoints are estimated from
+// the ingress spec just before they are acquired by the sync.
+func (l *modelLimits) admit(ing *networking.Ingress) bool {
+	if l == nil {
+		return true
+	}
+	name := ing.Namespace + "/" + ing.Name
+	admitted := l.dynconfig.AdmittedIngresses
+	// all the hosts and backends of an ingress being synced again were
+	// already removed from the model. An admitted one continues admitted
+	// on partial syncs, its hosts and backends are only counted again,
+	// admitted is empty on full syncs.
+	hosts, backends, endpoints := l.estimate(ing)
+	if admitted[name] {
+		l.hosts += hosts
+		l.backends += backends
+		l.endpoints += endpoints
+		return true
+	}
+	var limit string
+	var size, max int
+	switch {
+	case l.exceeds(len(admitted)+1, l.conf.MaxIngresses):
+		limit, size, max = limitIngresses, len(admitted)+1, l.conf.MaxIngresses
+	case l.exceeds(l.hosts+hosts, l.conf.MaxHosts):
+		limit, size, max = limitHosts, l.hosts+hosts, l.conf.MaxHosts
+	case l.exceeds(l.backends+backends, l.conf.MaxBackends):
+		limit, size, max = limitBackends, l.backends+backends, l.conf.MaxBackends
+	case l.exceeds(l.endpoints+endpoints, l.conf.MaxEndpoints):
+		limit, size, max = limitEndpoints, l.endpoints+endpoints, l.conf.MaxEndpoints
+	}
+	if limit != "" {
+		l.dynconfig.RejectedIngresses[name] = limit
+		if _, notified := l.rejected[name]; !notified {
+			msg := fmt.Sprintf("number of %s exceeds the model limit: %d > %d, ingress was not added to the configuration", limit, size, max)
+			l.c.logger.Error("rejecting Ingress '%s': %s", name, msg)
+			l.c.cache.NotifyIngressWarning(name, "ModelLimit", msg)
+		}
+		return false
+	}
+	admitted[name] = true
+	l.hosts += hosts
+	l.backends += backends
+	l.endpoints += endpoints
+	return true
+}
+
+func (l *modelLimits) exceeds(size, max int) bool {
+	return max > 0 && size > max
+}
+
+// estimate returns the number of hosts, backends and endpoints that an
+// ingress resource would add to the model.
+func (l *modelLimits) estimate(ing *networking.Ingress) (hosts, backends, endpoints int) {
+	c := l.c
+	port, _ := strconv.Atoi(c.readConfigKey(ing.Annotations, ingtypes.TCPTCPServicePort))
+	hostnames := map[string]bool{}
+	backendIDs := map[string]bool{}
+	addHost := func(hostname string) {
+		if port > 0 || hostnames[hostname] {
+			// tcp services don't add hosts
+			return
+		}
+		hostnames[hostname] = true
+		if c.haproxy.Hosts().FindHost(hostname) == nil {
+			hosts++
+		}
+	}
+	addBackend := func(backend *networking.IngressBackend) {
+		svcName, svcPort, err := readServiceNamePort(backend)
+		if err != nil {
+			return
+		}
+		svc, err := c.cache.GetService(ing.Namespace, svcName)
+		if err != nil || len(svc.Spec.Ports) == 0 {
+			return
+		}
+		if svcPort == "" {
+			svcPort = svc.Spec.Ports[0].TargetPort.String()
+		}
+		svcport := convutils.FindServicePort(svc, svcPort)
+		if svcport == nil {
+			return
+		}
+		backendPort := c.backendPort(svcport)
+		id := ing.Namespace + "/" + svcName + ":" + backendPort
+		if backendIDs[id] || c.haproxy.Backends().FindBackend(ing.Namespace, svcName, backendPort) != nil {
+			return
+		}
+		backendIDs[id] = true
+		backends++
+		// external names are resolved only on the sync
+		if svc.Spec.Type != api.ServiceTypeExternalName {
+			ready, _, _ := convutils.CreateEndpoints(c.cache, svc, svcport, c.options.EnableEPSlices)
+			endpoints += len(ready)
+		}
+	}
+	if ing.Spec.DefaultBackend != nil {
+		addHost(hatypes.DefaultHost)
+		addBackend(ing.Spec.DefaultBackend)
+	}
+	for _, rule := range ing.Spec.Rules {
+		addHost(normalizeHostname(rule.Host, 0))
+		if rule.HTTP != nil {
+			for i := range rule.HTTP.Paths {
+				addBackend(&rule.HTTP.Paths[i].Backend)
+			}
+		}
+	}
+	return hosts, backends, endpoints
+}
+
+// checkEndpoints enforces the endpoints limit on the backends changed by a
+// partial sync, eg the scale out of a service of an admitted ingress
+// resource. Endpoints over the limit aren't added to the configuration,
+// but a backend doesn't lose the number of endpoints it already had.
+func (l *modelLimits) checkEndpoints() {
+	if l == nil || l.conf.MaxEndpoints <= 0 {
+		return
+	}
+	backends := l.c.haproxy.Backends()
+	count := 0
+	for _, backend := range backends.Items() {
+		count += len(backend.Endpoints)
+	}
+	if count <= l.conf.MaxEndpoints {
+		return
+	}
+	changed := make([]*hatypes.Backend, 0, len(backends.ItemsAdd()))
+	for _, backend := range backends.ItemsAdd() {
+		changed = append(changed, backend)
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].ID < changed[j].ID
+	})
+	for _, backend := range changed {
+		excess := count - l.conf.MaxEndpoints
+		if excess <= 0 {
+			break
+		}
+		size := len(backend.Endpoints) - excess
+		if old := backends.ItemsDel()[backend.ID]; old != nil && size < len(old.Endpoints) {
+			size = len(old.Endpoints)
+		}
+		if size < 0 {
+			size = 0
+		}
+		if size >= len(backend.Endpoints) {
+			continue
+		}
+		l.c.logger.Error("limiting endpoints of backend '%s': number of endpoints exceeds the model limit: %d > %d, %d endpoint(s) were not added to the configuration",
+			backend.ID, count, l.conf.MaxEndpoints, len(backend.Endpoints)-size)
+		count -= len(backend.Endpoints) - size
+		backend.Endpoints = backend.Endpoints[:size]
+	}
+}
+
+// finish updates the metrics with the number of ingress resources
+// rejected by every limit.
+func (l *modelLimits) finish() {
+	if l == nil {
+		return
+	}
+	count := map[string]int{}
+	for _, limit := range l.dynconfig.RejectedIngresses {
+		count[limit]++
+	}
+	for _, limit := range []string{limitIngresses, limitHosts, limitBackends, limitEndpoints} {
+		l.c.options.Metrics.SetModelLimitRejected(limit, count[limit])
+	}
+}
+
+func readIngressClassName(ing *networking.Ingress) string {
+	if ing.Spec.IngressClassName != nil {
+		return *ing.Spec.IngressClassName
+	}
+	return ing.Annotations["kubernetes.io/ingress.class"]
+}
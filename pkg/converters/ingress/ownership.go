@@ -0,0 +1,269 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// annDelegateHosts is the namespace annotation, without the prefix, used by
+// the owner of a hostname to delegate it to other namespaces.
+const annDelegateHosts = "delegate-hosts"
+
+// hostOwnership ensures that a hostname is only used by ingress resources
+// of the namespace that owns it, or of the namespaces the owner delegates it
+// to. The first namespace to claim a hostname owns it, until the namespace
+// does not have ingress resources using the hostname anymore. Owners are
+// persisted, so a controller restart does not give a hostname to another
+// namespace whose ingress resources are older. A nil *hostOwnership allows
+// everything.
+type hostOwnership struct {
+	c           *converter
+	conf        convtypes.HostOwnership
+	owners      map[string]string
+	contested   map[string]bool
+	delegations map[string]map[string][]string
+	notified    map[string]bool
+	persist     bool
+	changed     bool
+}
+
+func newHostOwnership(c *converter) *hostOwnership {
+	conf := c.options.HostOwnership
+	if conf.Owners == nil {
+		return nil
+	}
+	return &hostOwnership{
+		c:    c,
+		conf: conf,
+	}
+}
+
+// start reads the persisted owners and releases the hostnames whose owner
+// does not have ingress resources using them anymore. ingList should have
+// all the ingress resources being synced. The owners of all the hostnames
+// are checked on full syncs, otherwise only the ones of hostnames.
+//
+// Ownership is still enforced if the persisted owners cannot be read: the
+// owners of the last sync are used, or, if they were never read, hostnames
+// used by more than one namespace are skipped. Owners are not persisted on
+// such syncs.
+func (o *hostOwnership) start(ingList []*networking.Ingress, full bool, hostnames []string) {
+	if o == nil {
+		return
+	}
+	o.contested = nil
+	o.delegations = map[string]map[string][]string{}
+	o.notified = map[string]bool{}
+	o.persist = true
+	o.changed = false
+	owners, err := o.conf.Owners.Owners()
+	if err != nil {
+		o.persist = false
+		if last := o.c.options.DynamicConfig.HostOwners; last != nil {
+			o.c.logger.Error("error reading host owners, using the owners of the last sync: %v", err)
+			owners = last
+		} else {
+			o.c.logger.Error("error reading host owners, skipping hosts used by more than one namespace on this sync: %v", err)
+			owners, o.contested = o.ingressOwners(ingList)
+		}
+	}
+	o.owners = make(map[string]string, len(owners))
+	for hostname, namespace := range owners {
+		o.owners[hostname] = namespace
+	}
+	used := map[string]bool{}
+	for _, ing := range ingList {
+		for _, hostname := range o.ingressHostnames(ing) {
+			used[ing.Namespace+" "+hostname] = true
+		}
+	}
+	if full {
+		hostnames = make([]string, 0, len(o.owners))
+		for hostname := range o.owners {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	for _, hostname := range hostnames {
+		if owner, found := o.owners[hostname]; found && !used[owner+" "+hostname] {
+			o.c.logger.Info("releasing host '%s' from namespace '%s': namespace does not have ingress resources using it", hostname, owner)
+			delete(o.owners, hostname)
+			o.changed = true
+		}
+	}
+}
+
+// ingressOwners assigns the hostnames of ingList used by a single namespace
+// to that namespace, and lists the ones used by more than one namespace.
+func (o *hostOwnership) ingressOwners(ingList []*networking.Ingress) (owners map[string]string, contested map[string]bool) {
+	owners = map[string]string{}
+	contested = map[string]bool{}
+	for _, ing := range ingList {
+		for _, hostname := range o.ingressHostnames(ing) {
+			if owner, found := owners[hostname]; !found {
+				owners[hostname] = ing.Namespace
+			} else if owner != ing.Namespace {
+				contested[hostname] = true
+			}
+		}
+	}
+	for hostname := range contested {
+		delete(owners, hostname)
+	}
+	return owners, contested
+}
+
+// ingressHostnames lists the hostnames an ingress resource would claim.
+func (o *hostOwnership) ingressHostnames(ing *networking.Ingress) []string {
+	if port, _ := strconv.Atoi(o.c.readConfigKey(ing.Annotations, ingtypes.TCPTCPServicePort)); port > 0 {
+		// tcp services do not claim hostnames
+		return nil
+	}
+	var hostnames []string
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP != nil {
+			hostnames = append(hostnames, normalizeHostname(rule.Host, 0))
+		}
+	}
+	for _, tls := range ing.Spec.TLS {
+		hostnames = append(hostnames, tls.Hosts...)
+	}
+	return hostnames
+}
+
+// claim checks if the namespace of source can use hostname, claiming the
+// hostname if it does not have an owner yet. The default host does not
+// have an owner.
+func (o *hostOwnership) claim(hostname string, source *annotations.Source) bool {
+	if o == nil || o.owners == nil || hostname == hatypes.DefaultHost {
+		return true
+	}
+	if o.contested[hostname] {
+		o.skip(source, hostname, fmt.Sprintf("host '%s' is used by more than one namespace and its owner could not be read", hostname))
+		return false
+	}
+	owned := hostname
+	owner, found := o.owners[hostname]
+	if !found && o.conf.WildcardSubdomains {
+		if wildcard := wildcardHostname(hostname); wildcard != "" {
+			owner, found = o.owners[wildcard]
+			owned = wildcard
+		}
+	}
+	if !found || owner == source.Namespace {
+		if _, claimed := o.owners[hostname]; !claimed {
+			o.owners[hostname] = source.Namespace
+			o.changed = true
+		}
+		return true
+	}
+	if o.delegated(owner, source.Namespace, hostname, owned) {
+		return true
+	}
+	o.skip(source, hostname, fmt.Sprintf("host '%s' is owned by namespace '%s'", owned, owner))
+	return false
+}
+
+func (o *hostOwnership) skip(source *annotations.Source, hostname, msg string) {
+	// tracking the skipped ingress, so it is resynced if the owner releases the host
+	o.c.tracker.TrackNames(source.Type, source.FullName(), convtypes.ResourceHAHostname, hostname)
+	key := source.FullName() + " " + hostname
+	if !o.notified[key] {
+		o.notified[key] = true
+		o.c.logger.Error("skipping host '%s' of %v: %s", hostname, source, msg)
+		o.c.cache.NotifyIngressWarning(source.FullName(), "HostOwnership", msg)
+	}
+}
+
+// delegated checks if the owner namespace delegates one of the hostnames to
+// namespace. Delegations are declared in the owner's Namespace resource, one
+// `<hostname>=<namespace>[,<namespace>...]` per line, `*` delegates to all
+// the namespaces.
+func (o *hostOwnership) delegated(owner, namespace string, hostnames ...string) bool {
+	delegations, found := o.delegations[owner]
+	if !found {
+		delegations = o.readDelegations(owner)
+		o.delegations[owner] = delegations
+	}
+	for _, hostname := range hostnames {
+		for _, ns := range delegations[hostname] {
+			if ns == namespace || ns == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (o *hostOwnership) readDelegations(owner string) map[string][]string {
+	delegations := map[string][]string{}
+	ns, err := o.c.cache.GetNamespace(owner)
+	if err != nil {
+		o.c.logger.Warn("error reading host delegations of namespace '%s': %v", owner, err)
+		return delegations
+	}
+	for _, entry := range utils.LineToSlice(o.c.readConfigKey(ns.Annotations, annDelegateHosts)) {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		hostname, namespaces, _ := strings.Cut(entry, "=")
+		hostname = strings.TrimSpace(hostname)
+		nsList := utils.Split(namespaces, ",")
+		if hostname == "" || len(nsList) == 0 {
+			o.c.logger.Warn("ignoring invalid host delegation on namespace '%s': %s", owner, entry)
+			continue
+		}
+		delegations[hostname] = append(delegations[hostname], nsList...)
+	}
+	return delegations
+}
+
+// finish persists the owners if they have changed during the sync, and
+// keeps them in the dynamic config in case the next read fails.
+func (o *hostOwnership) finish() {
+	if o == nil || o.owners == nil || o.contested != nil {
+		return
+	}
+	o.c.options.DynamicConfig.HostOwners = o.owners
+	if o.persist && o.changed {
+		o.conf.Owners.Update(o.owners)
+	}
+}
+
+// wildcardHostname returns the wildcard hostname that matches hostname,
+// or an empty string if hostname does not have a parent domain or is
+// already a wildcard.
+func wildcardHostname(hostname string) string {
+	if strings.HasPrefix(hostname, "*.") {
+		return ""
+	}
+	i := strings.Index(hostname, ".")
+	if i <= 0 || i == len(hostname)-1 {
+		return ""
+	}
+	return "*" + hostname[i:]
+}
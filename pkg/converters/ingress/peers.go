@@ -0,0 +1,116 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convutils "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/utils"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// peersSectionName is the name of the peers section, referenced by the
+// stick tables that should be shared between the controller replicas.
+const peersSectionName = "ingress"
+
+// syncPeers updates the peers section with the pods of the peers service.
+// It runs on every sync, so replicas that were added or removed are updated
+// on the next sync, changes on endpoints of the peers service trigger one.
+// Once configured, the section is kept on partial syncs even if the peers
+// cannot be read, so the stick tables of backends that weren't synced
+// continue valid.
+func (c *converter) syncPeers() {
+	peers := &c.haproxy.Global().Peers
+	svcName := c.globalConfig.Get(ingtypes.GlobalPeersService).Value
+	if svcName == "" {
+		*peers = hatypes.PeersConfig{}
+		return
+	}
+	if c.options.PodName == "" {
+		if c.changed.GlobalConfigMapDataNew != nil {
+			// logging only when the global config changes
+			c.logger.Error("ignoring peers-service: POD_NAME envvar should be configured")
+		}
+		*peers = hatypes.PeersConfig{}
+		return
+	}
+	servers, port, err := c.readPeers(svcName)
+	if err != nil {
+		if peers.Name == "" {
+			c.logger.Error("cannot configure peers: %v", err)
+			return
+		}
+		// the section is kept, so stick tables referencing it continue valid
+		c.logger.Warn("keeping the current peers list: %v", err)
+		return
+	}
+	peers.Name = peersSectionName
+	peers.LocalPeer = c.options.PodName
+	peers.Port = port
+	peers.Servers = servers
+}
+
+func (c *converter) readPeers(svcName string) (servers []hatypes.PeerServer, port int, err error) {
+	name, svcPort, found := strings.Cut(svcName, ":")
+	if !found || svcPort == "" {
+		return nil, 0, fmt.Errorf("missing port on peers-service '%s'", svcName)
+	}
+	svc, err := c.cache.GetService(c.cache.GetPodNamespace(), name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading peers service: %w", err)
+	}
+	svcport := convutils.FindServicePort(svc, svcPort)
+	if svcport == nil {
+		return nil, 0, fmt.Errorf("port '%s' not found on peers service '%s/%s'", svcPort, svc.Namespace, svc.Name)
+	}
+	ready, notReady, err := convutils.CreateEndpoints(c.cache, svc, svcport, c.options.EnableEPSlices)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading peers endpoints: %w", err)
+	}
+	port = svcport.TargetPort.IntValue()
+	var remotePort int
+	// replicas are peers regardless of their readiness, a starting replica
+	// need to learn the current state of the tables from the others
+	for _, ep := range append(ready, notReady...) {
+		if ep.TargetRef == "" {
+			continue
+		}
+		peerName := path.Base(ep.TargetRef)
+		if peerName == c.options.PodName {
+			// the local peer is always declared, and uses the port of its own endpoint
+			port = ep.Port
+			continue
+		}
+		servers = append(servers, hatypes.PeerServer{Name: peerName, Endpoint: ep.Target})
+		remotePort = ep.Port
+	}
+	if port == 0 {
+		// named target port and the local pod isn't an endpoint yet
+		port = remotePort
+	}
+	if port == 0 {
+		return nil, 0, fmt.Errorf("cannot find the port number of peers service '%s/%s'", svc.Namespace, svc.Name)
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Name < servers[j].Name
+	})
+	return servers, port, nil
+}
@@ -0,0 +1,263 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/annotations"
+	ingtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/ingress/types"
+	convtypes "github.com/jcmoraisjr/haproxy-ingress/pkg/converters/types"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// annLastApplied is the annotation added by kubectl apply, it is not
+// copied to the tombstones.
+const annLastApplied = "kubectl.kubernetes.io/last-applied-configuration"
+
+// pathTombstones keeps the paths of deleted ingress resources routed to their
+// former backends, with the same configuration, during the graceful-path-removal
+// window. Tombstoned ingress resources are synced after the other ones, so a
+// path claimed by another resource is cancelled from its tombstone. Tombstones
+// are persisted, so the window survives a controller restart. A nil
+// *pathTombstones disables tombstones.
+type pathTombstones struct {
+	c          *converter
+	store      convtypes.PathTombstones
+	now        func() time.Time
+	tombstones map[string]*convtypes.PathTombstone
+	changed    bool
+}
+
+func newPathTombstones(c *converter) *pathTombstones {
+	store := c.options.PathTombstones
+	if store == nil {
+		return nil
+	}
+	return &pathTombstones{
+		c:     c,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// start reads the persisted tombstones and creates the ones of the ingress
+// resources deleted since the last sync. Tombstones of ingress resources in
+// created, which were created again, are cancelled. Expired tombstones are
+// removed and their ingress resources returned, so their paths are removed
+// from the model like the ones of a deleted ingress.
+func (t *pathTombstones) start(created []*networking.Ingress) (expired []*networking.Ingress) {
+	if t == nil {
+		return nil
+	}
+	tombstones, err := t.store.Tombstones()
+	if err != nil {
+		t.c.logger.Error("error reading path tombstones, paths of deleted ingress resources are removed on this sync: %v", err)
+		t.tombstones = nil
+		return nil
+	}
+	t.tombstones = make(map[string]*convtypes.PathTombstone, len(tombstones))
+	for name, tombstone := range tombstones {
+		t.tombstones[name] = tombstone
+	}
+	t.changed = false
+	now := t.now()
+	window, _ := annotations.TimeToDuration(t.c.globalConfig.Get(ingtypes.GlobalGracefulPathRemoval).Value)
+	for _, ing := range t.c.changed.IngressesDel {
+		if window <= 0 || !t.hasPaths(ing) {
+			continue
+		}
+		name := ing.Namespace + "/" + ing.Name
+		tombstone := &convtypes.PathTombstone{
+			Ingress: tombstoneIngress(ing),
+			Removed: now,
+			Expire:  now.Add(window),
+		}
+		t.tombstones[name] = tombstone
+		t.changed = true
+		t.c.logger.Info("keeping the paths of the deleted ingress '%s' until %s, see graceful-path-removal", name, tombstone.Expire.UTC().Format(time.RFC3339))
+	}
+	for _, ing := range created {
+		name := ing.Namespace + "/" + ing.Name
+		if _, found := t.tombstones[name]; found {
+			delete(t.tombstones, name)
+			t.changed = true
+			t.c.logger.Info("cancelling the path tombstone of ingress '%s': ingress was created again", name)
+		}
+	}
+	for name, tombstone := range t.tombstones {
+		if !now.Before(tombstone.Expire) {
+			delete(t.tombstones, name)
+			t.changed = true
+			expired = append(expired, tombstone.Ingress)
+			t.c.logger.Info("removing the paths of the deleted ingress '%s': graceful-path-removal window expired", name)
+		}
+	}
+	sortIngress(expired)
+	return expired
+}
+
+// hasPaths checks if the ingress resource has http paths that should be tombstoned.
+func (t *pathTombstones) hasPaths(ing *networking.Ingress) bool {
+	if port, _ := strconv.Atoi(t.c.readConfigKey(ing.Annotations, ingtypes.TCPTCPServicePort)); port > 0 {
+		// tcp services do not have paths
+		return false
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// tombstoneIngress copies the fields of a deleted ingress resource that
+// are used to sync its paths. The default backend is not copied, it is not
+// a path of the ingress resource.
+func tombstoneIngress(ing *networking.Ingress) *networking.Ingress {
+	tombstone := &networking.Ingress{}
+	tombstone.Namespace = ing.Namespace
+	tombstone.Name = ing.Name
+	tombstone.UID = ing.UID
+	tombstone.Generation = ing.Generation
+	tombstone.CreationTimestamp = ing.CreationTimestamp
+	tombstone.Annotations = make(map[string]string, len(ing.Annotations))
+	for key, value := range ing.Annotations {
+		if key != annLastApplied {
+			tombstone.Annotations[key] = value
+		}
+	}
+	spec := ing.Spec.DeepCopy()
+	tombstone.Spec.IngressClassName = spec.IngressClassName
+	tombstone.Spec.TLS = spec.TLS
+	tombstone.Spec.Rules = spec.Rules
+	return tombstone
+}
+
+// ingress returns the tombstoned ingress resource of name, or nil if name
+// does not have a tombstone.
+func (t *pathTombstones) ingress(name string) *networking.Ingress {
+	if t == nil {
+		return nil
+	}
+	if tombstone, found := t.tombstones[name]; found {
+		return tombstone.Ingress
+	}
+	return nil
+}
+
+// ingresses returns all the tombstoned ingress resources.
+func (t *pathTombstones) ingresses() []*networking.Ingress {
+	if t == nil {
+		return nil
+	}
+	ingList := make([]*networking.Ingress, 0, len(t.tombstones))
+	for _, tombstone := range t.tombstones {
+		ingList = append(ingList, tombstone.Ingress)
+	}
+	return ingList
+}
+
+func (t *pathTombstones) has(namespace, name string) bool {
+	if t == nil {
+		return false
+	}
+	_, found := t.tombstones[namespace+"/"+name]
+	return found
+}
+
+// sort moves the tombstoned ingress resources to the end of ingList, so the
+// paths declared by other resources have precedence.
+func (t *pathTombstones) sort(ingList []*networking.Ingress) {
+	if t == nil || len(t.tombstones) == 0 {
+		return
+	}
+	sort.SliceStable(ingList, func(i, j int) bool {
+		return !t.has(ingList[i].Namespace, ingList[i].Name) && t.has(ingList[j].Namespace, ingList[j].Name)
+	})
+}
+
+// claimed cancels the tombstone of a path that was already declared by another
+// resource. It returns false if source is not a tombstoned ingress resource.
+func (t *pathTombstones) claimed(source *annotations.Source, hostname, uri string) bool {
+	if !t.has(source.Namespace, source.Name) {
+		return false
+	}
+	name := source.FullName()
+	tombstone := t.tombstones[name]
+	ing := tombstone.Ingress.DeepCopy()
+	var hasPaths bool
+	for i := range ing.Spec.Rules {
+		rule := &ing.Spec.Rules[i]
+		if rule.HTTP == nil {
+			continue
+		}
+		if normalizeHostname(rule.Host, 0) == hostname {
+			paths := rule.HTTP.Paths[:0]
+			for _, path := range rule.HTTP.Paths {
+				if path.Path != uri && (path.Path != "" || uri != "/") {
+					paths = append(paths, path)
+				}
+			}
+			rule.HTTP.Paths = paths
+		}
+		hasPaths = hasPaths || len(rule.HTTP.Paths) > 0
+	}
+	t.c.logger.Info("cancelling the path tombstone of '%s%s' of the deleted ingress '%s': path was declared by another resource", hostname, uri, name)
+	if hasPaths {
+		t.tombstones[name] = &convtypes.PathTombstone{
+			Ingress: ing,
+			Removed: tombstone.Removed,
+			Expire:  tombstone.Expire,
+		}
+	} else {
+		delete(t.tombstones, name)
+	}
+	t.changed = true
+	return true
+}
+
+// deprecate adds the Deprecation and Sunset headers to the responses of a
+// path of a tombstoned ingress resource.
+func (t *pathTombstones) deprecate(source *annotations.Source, backend *hatypes.Backend, pathLink *hatypes.PathLink) {
+	if !t.has(source.Namespace, source.Name) {
+		return
+	}
+	path := backend.FindBackendPath(pathLink)
+	if path == nil {
+		return
+	}
+	tombstone := t.tombstones[source.FullName()]
+	path.Sunset = hatypes.PathSunset{
+		Deprecation: fmt.Sprintf("@%d", tombstone.Removed.Unix()),
+		Sunset:      tombstone.Expire.UTC().Format(http.TimeFormat),
+	}
+}
+
+// finish persists the tombstones if they have changed during the sync.
+func (t *pathTombstones) finish() {
+	if t == nil || t.tombstones == nil || !t.changed {
+		return
+	}
+	t.store.Update(t.tombstones)
+}
@@ -19,6 +19,7 @@ package types
 // TCP Service Annotations
 const (
 	TCPConfigTCPService     = "config-tcp-service"
+	TCPTCPServiceALPN       = "tcp-service-alpn"
 	TCPTCPServiceLogFormat  = "tcp-service-log-format"
 	TCPTCPServicePort       = "tcp-service-port"
 	TCPTCPServiceProxyProto = "tcp-service-proxy-protocol"
@@ -28,6 +29,7 @@ var (
 	// AnnTCP ...
 	AnnTCP = map[string]struct{}{
 		TCPConfigTCPService:     {},
+		TCPTCPServiceALPN:       {},
 		TCPTCPServiceLogFormat:  {},
 		TCPTCPServicePort:       {},
 		TCPTCPServiceProxyProto: {},
@@ -38,13 +40,23 @@ var (
 const (
 	HostAcmePreferredChain      = "acme-preferred-chain"
 	HostAppRoot                 = "app-root"
+	HostAuditBackend            = "audit-backend"
+	HostAuditSamplePercent      = "audit-sample-percent"
 	HostAuthTLSErrorPage        = "auth-tls-error-page"
 	HostAuthTLSSecret           = "auth-tls-secret"
 	HostAuthTLSStrict           = "auth-tls-strict"
 	HostAuthTLSVerifyClient     = "auth-tls-verify-client"
+	HostAuthTLSVerifyDepth      = "auth-tls-verify-depth"
+	HostBlockHTTP10             = "block-http10"
 	HostCertSigner              = "cert-signer"
+	HostDisableQUIC             = "disable-quic"
+	HostHTTPOnly                = "http-only"
+	HostHTTPSRedirectPort       = "https-redirect-port"
+	HostPathNormalization       = "path-normalization"
+	HostQUICAltSvcMaxAge        = "quic-alt-svc-max-age"
 	HostRedirectFrom            = "redirect-from"
 	HostRedirectFromRegex       = "redirect-from-regex"
+	HostRequireHostHeader       = "require-host-header"
 	HostServerAlias             = "server-alias"
 	HostServerAliasRegex        = "server-alias-regex"
 	HostSSLAlwaysAddHTTPS       = "ssl-always-add-https"
@@ -54,6 +66,7 @@ const (
 	HostSSLOptionsHost          = "ssl-options-host"
 	HostSSLPassthrough          = "ssl-passthrough"
 	HostSSLPassthroughHTTPPort  = "ssl-passthrough-http-port"
+	HostSSLRedirectHost         = "ssl-redirect-host"
 	HostTLSALPN                 = "tls-alpn"
 	HostVarNamespace            = "var-namespace"
 )
@@ -63,14 +76,23 @@ var (
 	AnnHost = map[string]struct{}{
 		HostAcmePreferredChain:     {},
 		HostAppRoot:                {},
+		HostAuditBackend:           {},
+		HostAuditSamplePercent:     {},
 		HostAuthTLSErrorPage:       {},
 		HostAuthTLSSecret:          {},
 		HostAuthTLSStrict:          {},
 		HostAuthTLSVerifyClient:    {},
+		HostAuthTLSVerifyDepth:     {},
+		HostBlockHTTP10:            {},
 		HostCertSigner:             {},
+		HostDisableQUIC:            {},
+		HostHTTPOnly:               {},
+		HostPathNormalization:      {},
+		HostQUICAltSvcMaxAge:       {},
 		HostServerAlias:            {},
 		HostRedirectFrom:           {},
 		HostRedirectFromRegex:      {},
+		HostRequireHostHeader:      {},
 		HostServerAliasRegex:       {},
 		HostSSLAlwaysAddHTTPS:      {},
 		HostSSLCiphers:             {},
@@ -78,6 +100,7 @@ var (
 		HostSSLOptionsHost:         {},
 		HostSSLPassthrough:         {},
 		HostSSLPassthroughHTTPPort: {},
+		HostSSLRedirectHost:        {},
 		HostTLSALPN:                {},
 		HostVarNamespace:           {},
 	}
@@ -86,47 +109,91 @@ var (
 	// on both host and backend annotations list.
 	// TODO: merge tcp, host and backend config keys into a single list?
 	AnnDuo = map[string]struct{}{
+		BackAuthCacheDenyDuration: {},
+		BackAuthCacheDuration:     {},
+		BackAuthCacheKey:          {},
+		BackAuthCacheSize:         {},
 		BackAuthExternalPlacement: {},
 		BackAuthHeadersFail:       {},
 		BackAuthHeadersRequest:    {},
 		BackAuthHeadersSucceed:    {},
 		BackAuthMethod:            {},
+		BackAuthProxyHeaders:      {},
 		BackAuthSignin:            {},
+		BackAuthSigninHTMLOnly:    {},
+		BackAuthSigninRedirParam:  {},
 		BackAuthURL:               {},
+		HostHTTPSRedirectPort:     {},
 	}
 )
 
 // Backend Annotations
 const (
+	BackAccessDebugHeader      = "access-debug-header"
 	BackAffinity               = "affinity"
+	BackAffinityFailover       = "affinity-failover"
+	BackAffinityHeaderName     = "affinity-header-name"
+	BackAffinityTableExpire    = "affinity-table-expire"
+	BackAffinityTableSize      = "affinity-table-size"
+	BackAffinityURLParam       = "affinity-url-param"
 	BackAgentCheckAddr         = "agent-check-addr"
 	BackAgentCheckInterval     = "agent-check-interval"
 	BackAgentCheckPort         = "agent-check-port"
 	BackAgentCheckSend         = "agent-check-send"
+	BackAllDownResponse        = "all-down-response"
 	BackAllowlistSourceRange   = "allowlist-source-range"
 	BackAllowlistSourceHeader  = "allowlist-source-header"
 	BackAssignBackendServerID  = "assign-backend-server-id"
+	BackAuthBruteforceBan      = "auth-bruteforce-ban"
+	BackAuthBruteforceLimit    = "auth-bruteforce-limit"
+	BackAuthBruteforceWindow   = "auth-bruteforce-window"
+	BackAuthCacheDenyDuration  = "auth-cache-deny-duration"
+	BackAuthCacheDuration      = "auth-cache-duration"
+	BackAuthCacheKey           = "auth-cache-key"
+	BackAuthCacheSize          = "auth-cache-size"
 	BackAuthExternalPlacement  = "auth-external-placement"
 	BackAuthHeadersFail        = "auth-headers-fail"
 	BackAuthHeadersRequest     = "auth-headers-request"
 	BackAuthHeadersSucceed     = "auth-headers-succeed"
+	BackAuthJWTAudience        = "auth-jwt-audience"
+	BackAuthJWTIssuer          = "auth-jwt-issuer"
+	BackAuthJWTSecret          = "auth-jwt-secret"
 	BackAuthMethod             = "auth-method"
+	BackAuthProxyHeaders       = "auth-proxy-headers"
 	BackAuthRealm              = "auth-realm"
 	BackAuthSecret             = "auth-secret"
+	BackAuthSecretType         = "auth-secret-type"
 	BackAuthSignin             = "auth-signin"
+	BackAuthSigninHTMLOnly     = "auth-signin-html-only"
+	BackAuthSigninRedirParam   = "auth-signin-redirect-param"
 	BackAuthTLSCertHeader      = "auth-tls-cert-header"
+	BackAuthType               = "auth-type"
 	BackAuthURL                = "auth-url"
 	BackBackendCheckInterval   = "backend-check-interval"
 	BackBackendProtocol        = "backend-protocol"
 	BackBackendServerNaming    = "backend-server-naming"
 	BackBackendServerSlotsInc  = "backend-server-slots-increment"
 	BackBalanceAlgorithm       = "balance-algorithm"
+	BackBandwidthLimitAllowTCP = "bandwidth-limit-allow-tcp"
+	BackBandwidthLimitDownload = "bandwidth-limit-download"
+	BackBandwidthLimitScope    = "bandwidth-limit-scope"
+	BackBandwidthLimitUpload   = "bandwidth-limit-upload"
+	BackBlueGreenAutoPause     = "blue-green-auto-pause"
+	BackBlueGreenThreshold     = "blue-green-auto-pause-threshold"
 	BackBlueGreenBalance       = "blue-green-balance"
 	BackBlueGreenCookie        = "blue-green-cookie"
 	BackBlueGreenDeploy        = "blue-green-deploy"
 	BackBlueGreenHeader        = "blue-green-header"
 	BackBlueGreenMode          = "blue-green-mode"
+	BackBlueGreenPaused        = "blue-green-paused"
+	BackCacheControl           = "cache-control"
+	BackCacheControlIfMissing  = "cache-control-if-missing"
+	BackChaosAbortPercent      = "chaos-abort-percent"
+	BackChaosAbortStatus       = "chaos-abort-status"
+	BackChaosLatency           = "chaos-latency"
+	BackChaosLatencyPercent    = "chaos-latency-percent"
 	BackConfigBackend          = "config-backend"
+	BackCookieAutoSecure       = "cookie-auto-secure"
 	BackCorsAllowCredentials   = "cors-allow-credentials"
 	BackCorsAllowHeaders       = "cors-allow-headers"
 	BackCorsAllowMethods       = "cors-allow-methods"
@@ -135,8 +202,19 @@ const (
 	BackCorsEnable             = "cors-enable"
 	BackCorsExposeHeaders      = "cors-expose-headers"
 	BackCorsMaxAge             = "cors-max-age"
+	BackDenylistClass          = "denylist-class"
 	BackDenylistSourceRange    = "denylist-source-range"
+	BackDNSHoldValid           = "dns-hold-valid"
+	BackDNSResolvePrefer       = "dns-resolve-prefer"
 	BackDynamicScaling         = "dynamic-scaling"
+	BackEarlyHints             = "early-hints"
+	BackExternalNameSlots      = "external-name-slots"
+	BackFallbackBackend        = "fallback-backend"
+	BackFallbackToTerminating  = "fallback-to-terminating"
+	BackForceCloseUserAgents   = "force-close-user-agents"
+	BackForwardedHeaders       = "forwarded-headers"
+	BackForwardedHostHdr       = "forwarded-host-hdr"
+	BackForwardedPortHdr       = "forwarded-port-hdr"
 	BackHeaders                = "headers"
 	BackHealthCheckAddr        = "health-check-addr"
 	BackHealthCheckFallCount   = "health-check-fall-count"
@@ -154,15 +232,34 @@ const (
 	BackLimitConnections       = "limit-connections"
 	BackLimitRPS               = "limit-rps"
 	BackLimitWhitelist         = "limit-whitelist"
+	BackLoadServerState        = "load-server-state"
 	BackMaxconnServer          = "maxconn-server"
 	BackMaxQueueServer         = "maxqueue-server"
+	BackNoAuthLocations        = "no-auth-locations"
 	BackOAuth                  = "oauth"
+	BackOAuthAuthURI           = "oauth-auth-uri"
+	BackOAuthBackend           = "oauth-backend"
+	BackOAuthCookieDomain      = "oauth-cookie-domain"
+	BackOAuthDenyBody          = "oauth-deny-body"
+	BackOAuthDenyStatus        = "oauth-deny-status"
+	BackOAuthErrorMode         = "oauth-error-mode"
 	BackOAuthHeaders           = "oauth-headers"
+	BackOAuthPassAccessToken   = "oauth-pass-access-token"
+	BackOAuthSetSecure         = "oauth-set-secure"
+	BackOAuthSkipPaths         = "oauth-skip-paths"
+	BackOAuthStartURI          = "oauth-start-uri"
 	BackOAuthURIPrefix         = "oauth-uri-prefix"
 	BackPathType               = "path-type"
+	BackPodMaintenanceKey      = "pod-maintenance-key"
 	BackProxyBodySize          = "proxy-body-size"
 	BackProxyProtocol          = "proxy-protocol"
+	BackProxyRedirect          = "proxy-redirect"
+	BackProxyRedirectHost      = "proxy-redirect-host"
+	BackRateLimitExemptClass   = "rate-limit-exempt-class"
+	BackRateLimitScope         = "rate-limit-scope"
 	BackRedirectTo             = "redirect-to"
+	BackReloadSensitive        = "reload-sensitive"
+	BackRetryBudgetWarn        = "retry-budget-warn"
 	BackRewriteTarget          = "rewrite-target"
 	BackSlotsMinFree           = "slots-min-free"
 	BackSecureBackends         = "secure-backends"
@@ -173,20 +270,30 @@ const (
 	BackServiceUpstream        = "service-upstream"
 	BackSessionCookieDomain    = "session-cookie-domain"
 	BackSessionCookieDynamic   = "session-cookie-dynamic"
+	BackSessionCookieDynKey    = "session-cookie-dynamic-key"
+	BackSessionCookieHTTPOnly  = "session-cookie-httponly"
 	BackSessionCookieKeywords  = "session-cookie-keywords"
+	BackSessionCookieMaxIdle   = "session-cookie-max-idle"
+	BackSessionCookieMaxLife   = "session-cookie-max-life"
 	BackSessionCookieName      = "session-cookie-name"
 	BackSessionCookiePreserve  = "session-cookie-preserve"
 	BackSessionCookieSameSite  = "session-cookie-same-site"
+	BackSessionCookieSecure    = "session-cookie-secure"
 	BackSessionCookieShared    = "session-cookie-shared"
 	BackSessionCookieStrategy  = "session-cookie-strategy"
 	BackSessionCookieValue     = "session-cookie-value-strategy"
+	BackSlowStart              = "slow-start"
 	BackSourceAddressIntf      = "source-address-intf"
+	BackSplitBackends          = "split-backends"
+	BackSPOEAgent              = "spoe-agent"
+	BackSPOEOnError            = "spoe-on-error"
 	BackSSLCipherSuitesBackend = "ssl-cipher-suites-backend"
 	BackSSLCiphersBackend      = "ssl-ciphers-backend"
 	BackSSLFingerprintLower    = "ssl-fingerprint-lower"
 	BackSSLFingerprintSha2Bits = "ssl-fingerprint-sha2-bits"
 	BackSSLOptionsBackend      = "ssl-options-backend"
 	BackSSLRedirect            = "ssl-redirect"
+	BackSurrogateControl       = "surrogate-control"
 	BackTimeoutConnect         = "timeout-connect"
 	BackTimeoutHTTPRequest     = "timeout-http-request"
 	BackTimeoutKeepAlive       = "timeout-keep-alive"
@@ -194,9 +301,13 @@ const (
 	BackTimeoutServer          = "timeout-server"
 	BackTimeoutServerFin       = "timeout-server-fin"
 	BackTimeoutTunnel          = "timeout-tunnel"
+	BackUseBackendClass        = "use-backend-class"
 	BackUseResolver            = "use-resolver"
 	BackWAF                    = "waf"
 	BackWAFMode                = "waf-mode"
+	BackWarmUpAbortCapacity    = "warm-up-abort-capacity"
+	BackWarmUpMinCapacity      = "warm-up-min-capacity"
+	BackWarmUpWindow           = "warm-up-window"
 	BackWhitelistSourceRange   = "whitelist-source-range"
 )
 
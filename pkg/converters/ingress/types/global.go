@@ -25,6 +25,7 @@ const (
 	GlobalAcmeTermsAgreed              = "acme-terms-agreed"
 	GlobalAuthLogFormat                = "auth-log-format"
 	GlobalAuthProxy                    = "auth-proxy"
+	GlobalBackendNaming                = "backend-naming"
 	GlobalBindFrontingProxy            = "bind-fronting-proxy"
 	GlobalBindHTTP                     = "bind-http"
 	GlobalBindHTTPS                    = "bind-https"
@@ -44,6 +45,7 @@ const (
 	GlobalConfigTCP                    = "config-tcp"
 	GlobalCookieKey                    = "cookie-key"
 	GlobalCPUMap                       = "cpu-map"
+	GlobalCrossNamespaceSecrets        = "cross-namespace-secrets"
 	GlobalCrossNamespaceSecretsCA      = "cross-namespace-secrets-ca"
 	GlobalCrossNamespaceSecretsCrt     = "cross-namespace-secrets-crt"
 	GlobalCrossNamespaceSecretsPasswd  = "cross-namespace-secrets-passwd"
@@ -58,13 +60,18 @@ const (
 	GlobalDNSTimeoutRetry              = "dns-timeout-retry"
 	GlobalDrainSupport                 = "drain-support"
 	GlobalDrainSupportRedispatch       = "drain-support-redispatch"
+	GlobalEnableChaos                  = "enable-chaos"
+	GlobalEnableIPv6                   = "enable-ipv6"
+	GlobalEnableQUIC                   = "enable-quic"
 	GlobalExternalHasLua               = "external-has-lua"
 	GlobalForwardfor                   = "forwardfor"
 	GlobalFrontingProxyPort            = "fronting-proxy-port"
+	GlobalGracefulPathRemoval          = "graceful-path-removal"
 	GlobalGroupname                    = "groupname"
 	GlobalHealthzPort                  = "healthz-port"
 	GlobalHTTPLogFormat                = "http-log-format"
 	GlobalHTTPPort                     = "http-port"
+	GlobalHTTPProtocolExemptPaths      = "http-protocol-exempt-paths"
 	GlobalHTTPResponse200              = "http-response-200"
 	GlobalHTTPResponse400              = "http-response-400"
 	GlobalHTTPResponse401              = "http-response-401"
@@ -89,9 +96,10 @@ const (
 	GlobalHTTPSLogFormat               = "https-log-format"
 	GlobalHTTPSPort                    = "https-port"
 	GlobalHTTPStoHTTPPort              = "https-to-http-port"
-	GlobalLoadServerState              = "load-server-state"
+	GlobalLintDisabledRules            = "lint-disabled-rules"
 	GlobalMasterExitOnFailure          = "master-exit-on-failure"
 	GlobalMaxConnections               = "max-connections"
+	GlobalMissingService               = "missing-service"
 	GlobalModsecurityArgs              = "modsecurity-args"
 	GlobalModsecurityEndpoints         = "modsecurity-endpoints"
 	GlobalModsecurityTimeoutConnect    = "modsecurity-timeout-connect"
@@ -107,10 +115,16 @@ const (
 	GlobalNoTLSRedirectLocations       = "no-tls-redirect-locations"
 	GlobalOriginalForwardedForHdr      = "original-forwarded-for-hdr"
 	GlobalPathTypeOrder                = "path-type-order"
+	GlobalPeersService                 = "peers-service"
+	GlobalProductionHardening          = "production-hardening"
 	GlobalPrometheusPort               = "prometheus-port"
 	GlobalRealIPHdr                    = "real-ip-hdr"
 	GlobalRedirectFromCode             = "redirect-from-code"
+	GlobalRedirectMaxDepth             = "redirect-max-depth"
 	GlobalRedirectToCode               = "redirect-to-code"
+	GlobalReloadDeferMax               = "reload-defer-max"
+	GlobalReloadDeferSessions          = "reload-defer-sessions"
+	GlobalSPOEAgents                   = "spoe-agents"
 	GlobalSSLDHDefaultMaxSize          = "ssl-dh-default-max-size"
 	GlobalSSLDHParam                   = "ssl-dh-param"
 	GlobalSSLEngine                    = "ssl-engine"
@@ -131,6 +145,9 @@ const (
 	GlobalTimeoutClient                = "timeout-client"
 	GlobalTimeoutClientFin             = "timeout-client-fin"
 	GlobalTimeoutStop                  = "timeout-stop"
+	GlobalTrafficClasses               = "traffic-classes"
+	GlobalUniqueIDFormat               = "unique-id-format"
+	GlobalUniqueIDResponseHeader       = "unique-id-response-header"
 	GlobalUseChroot                    = "use-chroot"
 	GlobalUseCPUMap                    = "use-cpu-map"
 	GlobalUseForwardedProto            = "use-forwarded-proto"
@@ -18,8 +18,12 @@ package utils
 
 import (
 	"fmt"
+	"net"
 	"regexp"
 	"strings"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
 )
 
 var parseURLRegex = regexp.MustCompile(`^([a-z]+)://([-a-z0-9]+/)?([^][/: ]+)(:[-a-z0-9]+)?(/[^"' ]*)?$`)
@@ -54,3 +58,233 @@ func ParseURL(url string) (urlProto, urlHost, urlPort, urlPath string, err error
 	}
 	return
 }
+
+var parseServicePortRegex = regexp.MustCompile(`^(([-a-z0-9]+)/)?([-a-z0-9]+):([-a-z0-9]+)$`)
+
+// ParseServicePort parses a `[<namespace>/]<name>:<port>` service reference.
+// namespace is an empty string if not declared.
+func ParseServicePort(svc string) (namespace, name, port string, err error) {
+	svcParse := parseServicePortRegex.FindStringSubmatch(svc)
+	if len(svcParse) < 5 {
+		err = fmt.Errorf("invalid service syntax, expected [<namespace>/]<name>:<port>: %s", svc)
+		return
+	}
+	return svcParse[2], svcParse[3], svcParse[4], nil
+}
+
+var parseBackendNameRegex = regexp.MustCompile(`^([-a-z0-9]+)_([-a-z0-9]+)_([-a-z0-9]+)$`)
+
+// ParseServiceOrBackend parses either a `[<namespace>/]<name>:<port>` service
+// reference, or a `<namespace>_<name>_<port>` backend name. namespace is an
+// empty string if not declared in the service reference.
+func ParseServiceOrBackend(svc string) (namespace, name, port string, err error) {
+	if backParse := parseBackendNameRegex.FindStringSubmatch(svc); len(backParse) == 4 {
+		return backParse[1], backParse[2], backParse[3], nil
+	}
+	if svcParse := parseServicePortRegex.FindStringSubmatch(svc); len(svcParse) == 5 {
+		return svcParse[2], svcParse[3], svcParse[4], nil
+	}
+	err = fmt.Errorf("invalid service syntax, expected [<namespace>/]<name>:<port> or <namespace>_<name>_<port>: %s", svc)
+	return
+}
+
+// protocol IDs registered by IANA, eg h2, http/1.1, xmpp-client, acme-tls/1
+var alpnProtocolRegex = regexp.MustCompile(`^[A-Za-z0-9][-A-Za-z0-9._/+]*$`)
+
+// ParseALPNRoute parses a `<protocol> [<namespace>/]<name>:<port>` ALPN route.
+// namespace is an empty string if not declared.
+func ParseALPNRoute(route string) (protocol, namespace, name, port string, err error) {
+	fields := strings.Fields(route)
+	if len(fields) != 2 {
+		err = fmt.Errorf("invalid alpn route syntax, expected <protocol> [<namespace>/]<name>:<port>: %s", route)
+		return
+	}
+	if !alpnProtocolRegex.MatchString(fields[0]) {
+		err = fmt.Errorf("invalid alpn protocol: %s", fields[0])
+		return
+	}
+	namespace, name, port, err = ParseServicePort(fields[1])
+	if err != nil {
+		return "", "", "", "", err
+	}
+	return fields[0], namespace, name, port, nil
+}
+
+var (
+	trafficClassNameRegex   = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	trafficClassHeaderRegex = regexp.MustCompile(`^hdr\(([-A-Za-z0-9_]+)\)$`)
+)
+
+// ParseTrafficClasses parses the traffic class declarations, one class per
+// line: `<name> <criterion> [<criterion>...]`. A request belongs to the class
+// if it matches all the criteria: `src=<cidr>[,<cidr>...]`,
+// `hdr(<name>)=<regex>` or `path=<prefix>[,<prefix>...]`. Empty lines and
+// lines starting with `#` are ignored.
+func ParseTrafficClasses(config string) ([]*hatypes.TrafficClass, error) {
+	var classes []*hatypes.TrafficClass
+	names := map[string]bool{}
+	for _, line := range utils.LineToSlice(config) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		name := fields[0]
+		if !trafficClassNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid traffic class name, expected lowercase letters, numbers and underscore: %s", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("traffic class '%s' was already declared", name)
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("traffic class '%s' does not declare any criterion", name)
+		}
+		class := &hatypes.TrafficClass{Name: name}
+		for _, field := range fields[1:] {
+			criterion, err := parseTrafficClassCriterion(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid criterion on traffic class '%s': %w", name, err)
+			}
+			class.Criteria = append(class.Criteria, criterion)
+		}
+		names[name] = true
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func parseTrafficClassCriterion(field string) (hatypes.TrafficClassCriterion, error) {
+	key, value, found := strings.Cut(field, "=")
+	if !found || value == "" {
+		return hatypes.TrafficClassCriterion{}, fmt.Errorf("expected <key>=<value>: %s", field)
+	}
+	switch key {
+	case "src":
+		values := utils.Split(value, ",")
+		for _, src := range values {
+			if _, _, err := net.ParseCIDR(src); err != nil && net.ParseIP(src) == nil {
+				return hatypes.TrafficClassCriterion{}, fmt.Errorf("invalid IP or CIDR: %s", src)
+			}
+		}
+		return hatypes.TrafficClassCriterion{Fetch: "src", Values: values}, nil
+	case "path":
+		values := utils.Split(value, ",")
+		for _, path := range values {
+			if !strings.HasPrefix(path, "/") {
+				return hatypes.TrafficClassCriterion{}, fmt.Errorf("path should start with a slash: %s", path)
+			}
+		}
+		return hatypes.TrafficClassCriterion{Fetch: "path", Match: "beg", Values: values}, nil
+	}
+	if hdr := trafficClassHeaderRegex.FindStringSubmatch(key); len(hdr) == 2 {
+		if _, err := regexp.Compile(value); err != nil {
+			return hatypes.TrafficClassCriterion{}, fmt.Errorf("invalid regex on header '%s': %w", hdr[1], err)
+		}
+		return hatypes.TrafficClassCriterion{Fetch: "req.hdr(" + hdr[1] + ")", Match: "reg", Values: []string{value}}, nil
+	}
+	return hatypes.TrafficClassCriterion{}, fmt.Errorf("unsupported key '%s', expected src, path or hdr(<name>)", key)
+}
+
+var (
+	spoeAgentNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	spoeMessageRegex   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	spoeTimeRegex      = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
+)
+
+// SPOEAgentModSecurity is the name of the predefined agent used by the
+// modsecurity WAF module, configured via the modsecurity-* global keys.
+const SPOEAgentModSecurity = "modsecurity"
+
+// ParseSPOEAgents parses the SPOE agent declarations, one agent per line:
+// `<name> endpoints=<host>:<port>[,...] messages=<name>[,...] [args=<arg>[,...]]
+// [timeout-<connect|server|hello|idle|processing>=<time>...]`. Empty lines and
+// lines starting with `#` are ignored.
+func ParseSPOEAgents(config string) ([]*hatypes.SPOEAgent, error) {
+	var agents []*hatypes.SPOEAgent
+	names := map[string]bool{}
+	for _, line := range utils.LineToSlice(config) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		name := fields[0]
+		if !spoeAgentNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid SPOE agent name, expected lowercase letters, numbers and underscore: %s", name)
+		}
+		if name == SPOEAgentModSecurity {
+			return nil, fmt.Errorf("SPOE agent name '%s' is reserved, use modsecurity-endpoints instead", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("SPOE agent '%s' was already declared", name)
+		}
+		agent := &hatypes.SPOEAgent{
+			Name:      name,
+			VarPrefix: name,
+			Timeout: hatypes.SPOETimeoutConfig{
+				Connect:    "5s",
+				Server:     "5s",
+				Hello:      "100ms",
+				Idle:       "30s",
+				Processing: "1s",
+			},
+		}
+		for _, field := range fields[1:] {
+			if err := parseSPOEAgentOption(agent, field); err != nil {
+				return nil, fmt.Errorf("invalid option on SPOE agent '%s': %w", name, err)
+			}
+		}
+		if len(agent.Endpoints) == 0 {
+			return nil, fmt.Errorf("SPOE agent '%s' does not declare endpoints", name)
+		}
+		if len(agent.Messages) == 0 {
+			return nil, fmt.Errorf("SPOE agent '%s' does not declare messages", name)
+		}
+		names[name] = true
+		agents = append(agents, agent)
+	}
+	return agents, nil
+}
+
+func parseSPOEAgentOption(agent *hatypes.SPOEAgent, field string) error {
+	key, value, found := strings.Cut(field, "=")
+	if !found || value == "" {
+		return fmt.Errorf("expected <key>=<value>: %s", field)
+	}
+	switch key {
+	case "endpoints":
+		agent.Endpoints = utils.Split(value, ",")
+		for _, endpoint := range agent.Endpoints {
+			if _, port, err := net.SplitHostPort(endpoint); err != nil || port == "" {
+				return fmt.Errorf("invalid endpoint, expected <host>:<port>: %s", endpoint)
+			}
+		}
+	case "messages":
+		agent.Messages = utils.Split(value, ",")
+		for _, message := range agent.Messages {
+			if !spoeMessageRegex.MatchString(message) {
+				return fmt.Errorf("invalid message name: %s", message)
+			}
+		}
+	case "args":
+		agent.Args = utils.Split(value, ",")
+	case "timeout-connect", "timeout-server", "timeout-hello", "timeout-idle", "timeout-processing":
+		if !spoeTimeRegex.MatchString(value) {
+			return fmt.Errorf("invalid time format on %s: %s", key, value)
+		}
+		timeout := &agent.Timeout
+		switch key {
+		case "timeout-connect":
+			timeout.Connect = value
+		case "timeout-server":
+			timeout.Server = value
+		case "timeout-hello":
+			timeout.Hello = value
+		case "timeout-idle":
+			timeout.Idle = value
+		case "timeout-processing":
+			timeout.Processing = value
+		}
+	default:
+		return fmt.Errorf("unsupported key '%s', expected endpoints, messages, args or timeout-<name>", key)
+	}
+	return nil
+}
@@ -18,7 +18,10 @@ package utils
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
 func TestParseURL(t *testing.T) {
@@ -126,3 +129,347 @@ func TestParseURL(t *testing.T) {
 		}
 	}
 }
+
+func TestParseServicePort(t *testing.T) {
+	testCases := []struct {
+		svc string
+		exp string
+		err string
+	}{
+		// 0
+		{
+			svc: "app",
+			err: "invalid service syntax, expected [<namespace>/]<name>:<port>: app",
+		},
+		// 1
+		{
+			svc: "app:8080",
+			exp: " | app | 8080",
+		},
+		// 2
+		{
+			svc: "default/app:http",
+			exp: "default | app | http",
+		},
+		// 3
+		{
+			svc: "default/app",
+			err: "invalid service syntax, expected [<namespace>/]<name>:<port>: default/app",
+		},
+		// 4
+		{
+			svc: "default/app:8080/path",
+			err: "invalid service syntax, expected [<namespace>/]<name>:<port>: default/app:8080/path",
+		},
+	}
+	for i, test := range testCases {
+		namespace, name, port, err := ParseServicePort(test.svc)
+		actual := fmt.Sprintf("%s | %s | %s", namespace, name, port)
+		if test.exp == "" {
+			test.exp = " |  | "
+		}
+		if actual != test.exp {
+			t.Errorf("expected '%s' on %d, but was '%s'", test.exp, i, actual)
+		}
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("expected error '%s' on %d, but was '%s'", test.err, i, err.Error())
+			}
+		} else if test.err != "" {
+			t.Errorf("expected error '%s' on %d, but there was no error", test.err, i)
+		}
+	}
+}
+
+func TestParseServiceOrBackend(t *testing.T) {
+	testCases := []struct {
+		svc string
+		exp string
+		err string
+	}{
+		// 0
+		{
+			svc: "app",
+			err: "invalid service syntax, expected [<namespace>/]<name>:<port> or <namespace>_<name>_<port>: app",
+		},
+		// 1
+		{
+			svc: "app:8080",
+			exp: " | app | 8080",
+		},
+		// 2
+		{
+			svc: "auth/oauth2-proxy:http",
+			exp: "auth | oauth2-proxy | http",
+		},
+		// 3
+		{
+			svc: "auth_oauth2-proxy_4180",
+			exp: "auth | oauth2-proxy | 4180",
+		},
+		// 4
+		{
+			svc: "oauth2-proxy_4180",
+			err: "invalid service syntax, expected [<namespace>/]<name>:<port> or <namespace>_<name>_<port>: oauth2-proxy_4180",
+		},
+		// 5
+		{
+			svc: "auth_oauth2_proxy_4180",
+			err: "invalid service syntax, expected [<namespace>/]<name>:<port> or <namespace>_<name>_<port>: auth_oauth2_proxy_4180",
+		},
+	}
+	for i, test := range testCases {
+		namespace, name, port, err := ParseServiceOrBackend(test.svc)
+		actual := fmt.Sprintf("%s | %s | %s", namespace, name, port)
+		if test.exp == "" {
+			test.exp = " |  | "
+		}
+		if actual != test.exp {
+			t.Errorf("expected '%s' on %d, but was '%s'", test.exp, i, actual)
+		}
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("expected error '%s' on %d, but was '%s'", test.err, i, err.Error())
+			}
+		} else if test.err != "" {
+			t.Errorf("expected error '%s' on %d, but there was no error", test.err, i)
+		}
+	}
+}
+
+func TestParseALPNRoute(t *testing.T) {
+	testCases := []struct {
+		route string
+		exp   string
+		err   string
+	}{
+		// 0
+		{
+			route: "xmpp-client chat:5222",
+			exp:   "xmpp-client |  | chat | 5222",
+		},
+		// 1
+		{
+			route: "  http/1.1   default/app:http ",
+			exp:   "http/1.1 | default | app | http",
+		},
+		// 2
+		{
+			route: "h2",
+			err:   "invalid alpn route syntax, expected <protocol> [<namespace>/]<name>:<port>: h2",
+		},
+		// 3
+		{
+			route: "h2,http/1.1 app:8080",
+			err:   "invalid alpn protocol: h2,http/1.1",
+		},
+		// 4
+		{
+			route: "h2 app",
+			err:   "invalid service syntax, expected [<namespace>/]<name>:<port>: app",
+		},
+	}
+	for i, test := range testCases {
+		protocol, namespace, name, port, err := ParseALPNRoute(test.route)
+		actual := fmt.Sprintf("%s | %s | %s | %s", protocol, namespace, name, port)
+		if test.exp == "" {
+			test.exp = " |  |  | "
+		}
+		if actual != test.exp {
+			t.Errorf("expected '%s' on %d, but was '%s'", test.exp, i, actual)
+		}
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("expected error '%s' on %d, but was '%s'", test.err, i, err.Error())
+			}
+		} else if test.err != "" {
+			t.Errorf("expected error '%s' on %d, but there was no error", test.err, i)
+		}
+	}
+}
+
+func TestParseTrafficClasses(t *testing.T) {
+	testCases := []struct {
+		config string
+		exp    []*hatypes.TrafficClass
+		err    string
+	}{
+		// 0
+		{
+			config: "",
+		},
+		// 1
+		{
+			config: `
+# comment
+internal src=10.0.0.0/8,192.168.1.10
+bots hdr(User-Agent)=(?i)(bot|crawler)
+
+api path=/api/,/v2/ src=10.0.0.0/8
+`,
+			exp: []*hatypes.TrafficClass{
+				{Name: "internal", Criteria: []hatypes.TrafficClassCriterion{
+					{Fetch: "src", Values: []string{"10.0.0.0/8", "192.168.1.10"}},
+				}},
+				{Name: "bots", Criteria: []hatypes.TrafficClassCriterion{
+					{Fetch: "req.hdr(User-Agent)", Match: "reg", Values: []string{"(?i)(bot|crawler)"}},
+				}},
+				{Name: "api", Criteria: []hatypes.TrafficClassCriterion{
+					{Fetch: "path", Match: "beg", Values: []string{"/api/", "/v2/"}},
+					{Fetch: "src", Values: []string{"10.0.0.0/8"}},
+				}},
+			},
+		},
+		// 2
+		{
+			config: "Internal src=10.0.0.0/8",
+			err:    "invalid traffic class name, expected lowercase letters, numbers and underscore: Internal",
+		},
+		// 3
+		{
+			config: "internal src=10.0.0.0/8\ninternal src=192.168.0.0/16",
+			err:    "traffic class 'internal' was already declared",
+		},
+		// 4
+		{
+			config: "internal",
+			err:    "traffic class 'internal' does not declare any criterion",
+		},
+		// 5
+		{
+			config: "internal src=10.0.0.0/33",
+			err:    "invalid criterion on traffic class 'internal': invalid IP or CIDR: 10.0.0.0/33",
+		},
+		// 6
+		{
+			config: "api path=api",
+			err:    "invalid criterion on traffic class 'api': path should start with a slash: api",
+		},
+		// 7
+		{
+			config: "bots hdr(User-Agent)=(bot",
+			err:    "invalid criterion on traffic class 'bots': invalid regex on header 'User-Agent': error parsing regexp: missing closing ): `(bot`",
+		},
+		// 8
+		{
+			config: "bots user-agent=bot",
+			err:    "invalid criterion on traffic class 'bots': unsupported key 'user-agent', expected src, path or hdr(<name>)",
+		},
+		// 9
+		{
+			config: "internal src",
+			err:    "invalid criterion on traffic class 'internal': expected <key>=<value>: src",
+		},
+	}
+	for i, test := range testCases {
+		classes, err := ParseTrafficClasses(test.config)
+		if !reflect.DeepEqual(classes, test.exp) {
+			t.Errorf("traffic classes differ on %d - expected: %+v, actual: %+v", i, test.exp, classes)
+		}
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("expected error '%s' on %d, but was '%s'", test.err, i, err.Error())
+			}
+		} else if test.err != "" {
+			t.Errorf("expected error '%s' on %d, but there was no error", test.err, i)
+		}
+	}
+}
+
+func TestParseSPOEAgents(t *testing.T) {
+	timeout := hatypes.SPOETimeoutConfig{Connect: "5s", Server: "5s", Hello: "100ms", Idle: "30s", Processing: "1s"}
+	testCases := []struct {
+		config string
+		exp    []*hatypes.SPOEAgent
+		err    string
+	}{
+		// 0
+		{
+			config: "",
+		},
+		// 1
+		{
+			config: `
+# comment
+risk endpoints=10.0.0.10:9000,risk.default.svc:9000 messages=score-req,score-ip args=src,path,ua=req.hdr(user-agent)
+
+ipcheck endpoints=10.0.0.20:9000 messages=check timeout-processing=200ms timeout-server=10s
+`,
+			exp: []*hatypes.SPOEAgent{
+				{
+					Name:      "risk",
+					Endpoints: []string{"10.0.0.10:9000", "risk.default.svc:9000"},
+					Messages:  []string{"score-req", "score-ip"},
+					Args:      []string{"src", "path", "ua=req.hdr(user-agent)"},
+					VarPrefix: "risk",
+					Timeout:   timeout,
+				},
+				{
+					Name:      "ipcheck",
+					Endpoints: []string{"10.0.0.20:9000"},
+					Messages:  []string{"check"},
+					VarPrefix: "ipcheck",
+					Timeout:   hatypes.SPOETimeoutConfig{Connect: "5s", Server: "10s", Hello: "100ms", Idle: "30s", Processing: "200ms"},
+				},
+			},
+		},
+		// 2
+		{
+			config: "Risk endpoints=10.0.0.10:9000 messages=score",
+			err:    "invalid SPOE agent name, expected lowercase letters, numbers and underscore: Risk",
+		},
+		// 3
+		{
+			config: "modsecurity endpoints=10.0.0.10:9000 messages=check-request",
+			err:    "SPOE agent name 'modsecurity' is reserved, use modsecurity-endpoints instead",
+		},
+		// 4
+		{
+			config: "risk endpoints=10.0.0.10:9000 messages=score\nrisk endpoints=10.0.0.11:9000 messages=score",
+			err:    "SPOE agent 'risk' was already declared",
+		},
+		// 5
+		{
+			config: "risk messages=score",
+			err:    "SPOE agent 'risk' does not declare endpoints",
+		},
+		// 6
+		{
+			config: "risk endpoints=10.0.0.10:9000",
+			err:    "SPOE agent 'risk' does not declare messages",
+		},
+		// 7
+		{
+			config: "risk endpoints=10.0.0.10 messages=score",
+			err:    "invalid option on SPOE agent 'risk': invalid endpoint, expected <host>:<port>: 10.0.0.10",
+		},
+		// 8
+		{
+			config: "risk endpoints=10.0.0.10:9000 messages=score timeout-idle=30",
+			err:    "invalid option on SPOE agent 'risk': invalid time format on timeout-idle: 30",
+		},
+		// 9
+		{
+			config: "risk endpoints=10.0.0.10:9000 messages=score backend=spoe",
+			err:    "invalid option on SPOE agent 'risk': unsupported key 'backend', expected endpoints, messages, args or timeout-<name>",
+		},
+		// 10
+		{
+			config: "risk endpoints=10.0.0.10:9000 messages=score;deny",
+			err:    "invalid option on SPOE agent 'risk': invalid message name: score;deny",
+		},
+	}
+	for i, test := range testCases {
+		agents, err := ParseSPOEAgents(test.config)
+		if !reflect.DeepEqual(agents, test.exp) {
+			t.Errorf("SPOE agents differ on %d - expected: %+v, actual: %+v", i, test.exp, agents)
+		}
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("expected error '%s' on %d, but was '%s'", test.err, i, err.Error())
+			}
+		} else if test.err != "" {
+			t.Errorf("expected error '%s' on %d, but there was no error", test.err, i)
+		}
+	}
+}
@@ -113,6 +113,20 @@ func (t *tracker) QueryLinks(input convtypes.TrackingLinks, removeMatches bool)
 	return output
 }
 
+// LinkedNames lists, in sorted order, the names of the rightContext resources
+// directly linked to the leftContext/leftName resource. Differently from
+// QueryLinks, links are not followed recursively and nothing is removed.
+func (t *tracker) LinkedNames(leftContext convtypes.ResourceType, leftName string, rightContext convtypes.ResourceType) []string {
+	var names []string
+	for ref := range t.tracking[leftContext][leftName] {
+		if ref.Context == rightContext {
+			names = append(names, ref.UniqueName)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (t *tracker) ClearLinks() {
 	t.tracking = trackingMap{}
 }
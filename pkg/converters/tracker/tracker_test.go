@@ -346,6 +346,65 @@ ingress
 	}
 }
 
+func TestLinkedNames(t *testing.T) {
+	ing1 := convtypes.TrackingRef{Context: "ingress", UniqueName: "default/ing1"}
+	ing2 := convtypes.TrackingRef{Context: "ingress", UniqueName: "default/ing2"}
+	ing3 := convtypes.TrackingRef{Context: "ingress", UniqueName: "default/ing3"}
+	host1 := convtypes.TrackingRef{Context: "hostname", UniqueName: "d1.local"}
+	back1 := convtypes.TrackingRef{Context: "backend", UniqueName: "default_echo1_8080"}
+	back2 := convtypes.TrackingRef{Context: "backend", UniqueName: "default_echo2_8080"}
+	type refs struct {
+		left  convtypes.TrackingRef
+		right convtypes.TrackingRef
+	}
+	testCases := []struct {
+		trackingRefs []refs
+		leftRef      convtypes.TrackingRef
+		rightContext convtypes.ResourceType
+		expected     []string
+	}{
+		// 0
+		{
+			leftRef:      back1,
+			rightContext: ing1.Context,
+		},
+		// 1
+		{
+			trackingRefs: []refs{
+				{ing2, back1},
+				{ing1, back1},
+				{ing1, host1},
+			},
+			leftRef:      back1,
+			rightContext: ing1.Context,
+			expected:     []string{"default/ing1", "default/ing2"},
+		},
+		// 2
+		{
+			trackingRefs: []refs{
+				{ing1, back1},
+				{ing1, host1},
+				{ing3, host1},
+				{ing3, back2},
+			},
+			leftRef:      back1,
+			rightContext: ing1.Context,
+			expected:     []string{"default/ing1"},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		for _, t := range test.trackingRefs {
+			c.tracker.TrackRefs(t.left, t.right)
+		}
+		before := trackingMap2string(c.tracker)
+		actual := c.tracker.LinkedNames(test.leftRef.Context, test.leftRef.UniqueName, test.rightContext)
+		c.compareText(i, strings.Join(actual, "\n"), strings.Join(test.expected, "\n"))
+		c.compareTrackingMap(i, before)
+		c.teardown()
+	}
+}
+
 type testConfig struct {
 	t       *testing.T
 	tracker *tracker
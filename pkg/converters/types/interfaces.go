@@ -55,8 +55,11 @@ type Cache interface {
 	GetCASecretPath(defaultNamespace, secretName string, track []TrackingRef) (ca, crl File, err error)
 	GetDHSecretPath(defaultNamespace, secretName string) (File, error)
 	GetPasswdSecretContent(defaultNamespace, secretName string, track []TrackingRef) ([]byte, error)
+	GetPasswdSecretMap(defaultNamespace, secretName string, track []TrackingRef) (map[string][]byte, error)
 	SwapChangedObjects() *ChangedObjects
 	UpdateStatus(obj client.Object)
+	NotifyIngressWarning(ingressName, reason, message string)
+	NotifyGlobalConfigEvent(eventType, reason, message string)
 	GetEndpointSlices(service *api.Service) ([]*discoveryv1.EndpointSlice, error)
 }
 
@@ -149,6 +152,7 @@ type Tracker interface {
 	TrackRefName(left []TrackingRef, rightContext ResourceType, rightName string)
 	TrackRefs(left, right TrackingRef)
 	QueryLinks(input TrackingLinks, removeMatches bool) TrackingLinks
+	LinkedNames(leftContext ResourceType, leftName string, rightContext ResourceType) []string
 	ClearLinks()
 }
 
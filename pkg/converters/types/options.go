@@ -17,34 +17,118 @@ limitations under the License.
 package types
 
 import (
+	"time"
+
+	networking "k8s.io/api/networking/v1"
+
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 // ConverterOptions ...
 type ConverterOptions struct {
-	Logger           types.Logger
-	Cache            Cache
-	Tracker          Tracker
-	DynamicConfig    *DynamicConfig
-	LocalFSPrefix    string
-	IsExternal       bool
-	MasterSocket     string
-	AdminSocket      string
-	AcmeSocket       string
-	DefaultConfig    func() map[string]string
-	DefaultBackend   string
-	DefaultCrtSecret string
-	FakeCrtFile      CrtFile
-	FakeCAFile       CrtFile
-	AnnotationPrefix []string
-	DisableKeywords  []string
-	AcmeTrackTLSAnn  bool
-	TrackInstances   bool
-	HasGatewayA2     bool
-	HasGatewayB1     bool
-	HasGatewayV1     bool
-	HasTCPRouteA2    bool
-	EnableEPSlices   bool
+	Logger              types.Logger
+	Cache               Cache
+	Tracker             Tracker
+	Metrics             types.Metrics
+	DynamicConfig       *DynamicConfig
+	LocalFSPrefix       string
+	IsExternal          bool
+	MasterSocket        string
+	AdminSocket         string
+	AcmeSocket          string
+	DefaultConfig       func() map[string]string
+	DefaultBackend      string
+	DefaultCrtSecret    string
+	FakeCrtFile         CrtFile
+	FakeCAFile          CrtFile
+	AnnotationPrefix    []string
+	AnnotationLimits    AnnotationLimits
+	AnnotationUsage     AnnotationUsage
+	AnnotationMigration AnnotationMigration
+	HostOwnership       HostOwnership
+	PathTombstones      PathTombstones
+	ModelLimits         ModelLimits
+	DisableKeywords     []string
+	AcmeTrackTLSAnn     bool
+	TrackInstances      bool
+	HasGatewayA2        bool
+	HasGatewayB1        bool
+	HasGatewayV1        bool
+	HasTCPRouteA2       bool
+	EnableEPSlices      bool
+	PodName             string
+	IsLeader            func() bool
+	HAProxyVersion      string
+	HAProxyFeatures     []string
+}
+
+// AnnotationLimits ...
+type AnnotationLimits struct {
+	MaxValueLength  int
+	MaxCIDRs        int
+	MaxHeaders      int
+	MaxRewritePaths int
+	Truncate        bool
+}
+
+// AnnotationUsage collects the configuration keys declared by the ingress
+// resources. Ingress resources are identified by their namespace and name.
+type AnnotationUsage interface {
+	Add(namespace, name, key string)
+	Forget(namespace, name string)
+	Reset()
+}
+
+// AnnotationMigration collects the annotation prefixes used to declare the
+// configuration keys of the ingress resources. Ingress resources are
+// identified by their namespace and name.
+type AnnotationMigration interface {
+	Add(namespace, name string, ann map[string]string)
+	Forget(namespace, name string)
+	Reset()
+}
+
+// HostOwnership configures the ownership of hostnames by namespaces. The
+// first namespace to claim a hostname owns it, and other namespaces can only
+// use the hostname if the owner delegates it. A nil Owners disables ownership.
+type HostOwnership struct {
+	Owners HostOwners
+	// WildcardSubdomains defines if the owner of a wildcard hostname, like
+	// *.example.com, also owns the hostnames it matches that were not claimed.
+	WildcardSubdomains bool
+}
+
+// HostOwners persists the namespace that owns each hostname, so ownership
+// survives controller restarts.
+type HostOwners interface {
+	Owners() (map[string]string, error)
+	Update(owners map[string]string)
+}
+
+// PathTombstones persists the paths of the deleted ingress resources that
+// are still routed during the graceful-path-removal window, so the window
+// survives controller restarts. Tombstones are identified by the namespace
+// and name of the deleted ingress resource.
+type PathTombstones interface {
+	Tombstones() (map[string]*PathTombstone, error)
+	Update(tombstones map[string]*PathTombstone)
+}
+
+// PathTombstone is a deleted ingress resource, with the paths that are still
+// routed to their former backends until Expire.
+type PathTombstone struct {
+	Ingress *networking.Ingress `json:"ingress"`
+	Removed time.Time           `json:"removed"`
+	Expire  time.Time           `json:"expire"`
+}
+
+// ModelLimits ...
+type ModelLimits struct {
+	MaxIngresses  int
+	MaxHosts      int
+	MaxBackends   int
+	MaxEndpoints  int
+	ClassPriority []string
 }
 
 // DynamicConfig ...
@@ -55,4 +139,22 @@ type DynamicConfig struct {
 	CrossNamespaceServices          bool
 	// config from the command-line for backward compatibility
 	StaticCrossNamespaceSecrets bool
+	// last applied and validated global config
+	GlobalConfig map[string]string
+	// ingress resources, by namespace/name, admitted into the model
+	// and rejected by a model limit, along with the limit name
+	AdmittedIngresses map[string]bool
+	RejectedIngresses map[string]string
+	// lint findings of the last applied config, by backend ID
+	LintFindings map[string][]LintFinding
+	// host owners of the last sync, used if the persisted ones cannot be read
+	HostOwners map[string]string
+	// annotation limits already reported, by source, key, limit and size
+	AnnotationLimits map[string]bool
+}
+
+// LintFinding is a legal but risky configuration found in a backend.
+type LintFinding struct {
+	Rule    string
+	Message string
 }
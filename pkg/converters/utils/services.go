@@ -73,6 +73,9 @@ type Endpoint struct {
 	Port      int
 	Target    string
 	TargetRef string
+	// Serving is true on not ready endpoints that are terminating but
+	// still serving requests. Only filled from EndpointSlices.
+	Serving bool
 }
 
 func createEndpoints(endpoints *api.Endpoints, svcPort *api.ServicePort) (ready, notReady []*Endpoint, err error) {
@@ -146,6 +149,8 @@ func createEndpointSlices(endpointSlices []*discoveryv1.EndpointSlice, svcPort *
 					// https://kubernetes.io/docs/reference/command-line-tools-reference/feature-gates/
 					// Default EndpointSliceTerminatingCondition is false in 1.21
 					// Default EndpointSliceTerminatingCondition is true in 1.22
+					cond := endpoint.Conditions
+					domainEndpoint.Serving = cond.Serving != nil && *cond.Serving && cond.Terminating != nil && *cond.Terminating
 					notReady = append(notReady, domainEndpoint)
 				}
 			}
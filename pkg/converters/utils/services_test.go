@@ -134,6 +134,41 @@ func TestCreateEndpoints(t *testing.T) {
 	}
 }
 
+func TestCreateEndpointSlicesTerminating(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	svc, _, eps := helper_test.CreateService("default/echo", "8080", "172.17.0.11,172.17.0.12,172.17.0.13")
+	isFalse := false
+	isTrue := true
+	endpoints := eps[0].Endpoints
+	for i := range endpoints {
+		endpoints[i].TargetRef = nil
+	}
+	endpoints[1].Conditions = discoveryv1.EndpointConditions{Ready: &isFalse, Serving: &isTrue, Terminating: &isTrue}
+	endpoints[2].Conditions = discoveryv1.EndpointConditions{Ready: &isFalse, Serving: &isFalse, Terminating: &isTrue}
+	cache := &helper_test.CacheMock{
+		EpsList: map[string][]*discoveryv1.EndpointSlice{"default/echo": eps},
+	}
+	ready, notReady, err := CreateEndpoints(cache, svc, FindServicePort(svc, "8080"), true)
+	expectedReady := []*Endpoint{
+		{IP: "172.17.0.11", Port: 8080, Target: "172.17.0.11:8080"},
+	}
+	expectedNotReady := []*Endpoint{
+		{IP: "172.17.0.12", Port: 8080, Target: "172.17.0.12:8080", Serving: true},
+		{IP: "172.17.0.13", Port: 8080, Target: "172.17.0.13:8080"},
+	}
+	if !reflect.DeepEqual(ready, expectedReady) {
+		t.Errorf("'ready' endpoints differ -- expected: %+v -- actual: %+v", expectedReady, ready)
+	}
+	if !reflect.DeepEqual(notReady, expectedNotReady) {
+		t.Errorf("'notReady' endpoints differ -- expected: %+v -- actual: %+v", expectedNotReady, notReady)
+	}
+	if err != nil {
+		t.Errorf("CreateEndpoints raised an unexpected error: %v", err)
+	}
+}
+
 type config struct {
 	t *testing.T
 }
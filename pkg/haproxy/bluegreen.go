@@ -0,0 +1,221 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+// blueGreenMinRequests is the minimum number of requests the canary group
+// should receive before its error rate is compared with the baseline one.
+// Samples are accumulated until this number is reached.
+const blueGreenMinRequests = 20
+
+// BlueGreenPause describes a backend whose blue/green balance should be
+// paused, because the error rate of its canary group went above the rate
+// of the baseline group plus the configured threshold.
+type BlueGreenPause struct {
+	Backend      string
+	Balance      string
+	CanaryRate   float64
+	BaselineRate float64
+	Threshold    float64
+}
+
+type serverStat struct {
+	targetRef string
+	requests  int
+	errors    int
+}
+
+type groupStat struct {
+	requests int
+	errors   int
+}
+
+// BlueGreenPauses lists, sorted by the backend name, the blue/green balances
+// that should be paused on the last stats sample.
+func (i *instance) BlueGreenPauses() []BlueGreenPause {
+	return i.blueGreenPauses
+}
+
+// updateBlueGreenAnalysis samples the servers of the backends whose blue/green
+// balance has auto pause enabled and isn't paused yet, comparing the 5xx rate
+// of the canary group with the baseline one. The sample of a backend is kept
+// as the start of the window until the canary group has enough requests.
+func (i *instance) updateBlueGreenAnalysis(sample *StatsSample) {
+	i.blueGreenPauses = nil
+	backends := i.blueGreenBackends()
+	if len(backends) == 0 {
+		i.serverStats = nil
+		return
+	}
+	if !sample.servers {
+		// auto pause was enabled after the sample was created
+		return
+	}
+	if sample.serversErr != nil {
+		i.logger.Error("error reading servers from admin socket: %v", sample.serversErr)
+		return
+	}
+	i.blueGreenPauses = i.analyzeBlueGreen(backends, sample.serverStats)
+}
+
+// blueGreenBackends lists the backends whose blue/green balance has auto
+// pause enabled and isn't paused yet.
+func (i *instance) blueGreenBackends() []*hatypes.Backend {
+	var backends []*hatypes.Backend
+	for _, backend := range i.config.Backends().Items() {
+		if autoPause := backend.BlueGreen.AutoPause; autoPause.Threshold > 0 && !autoPause.Paused {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+func (i *instance) analyzeBlueGreen(backends []*hatypes.Backend, current map[string]serverStat) []BlueGreenPause {
+	var pauses []BlueGreenPause
+	last := i.serverStats
+	next := make(map[string]serverStat, len(current))
+	for _, backend := range backends {
+		var canary, baseline groupStat
+		sample := map[string]serverStat{}
+		for _, ep := range backend.Endpoints {
+			if ep.Group == "" {
+				continue
+			}
+			key := backend.ID + "/" + ep.Name
+			cur, found := current[key]
+			if !found {
+				continue
+			}
+			cur.targetRef = ep.TargetRef
+			sample[key] = cur
+			prev, found := last[key]
+			if !found || prev.targetRef != cur.targetRef {
+				// first sample, or the server slot was assigned to another pod
+				continue
+			}
+			group := &baseline
+			if ep.Group == hatypes.BlueGreenGroupCanary {
+				group = &canary
+			}
+			if cur.requests < prev.requests {
+				// counters were reset on a reload
+				group.requests += cur.requests
+				group.errors += cur.errors
+			} else {
+				group.requests += cur.requests - prev.requests
+				group.errors += cur.errors - prev.errors
+			}
+		}
+		threshold := backend.BlueGreen.AutoPause.Threshold
+		canaryRate, baselineRate, pause, done := checkBlueGreenCanary(canary, baseline, threshold)
+		if !done {
+			// window isn't finished, the last sample continues as its start
+			for key := range sample {
+				if prev, found := last[key]; found && prev.targetRef == sample[key].targetRef {
+					sample[key] = prev
+				}
+			}
+		}
+		for key, stat := range sample {
+			next[key] = stat
+		}
+		if pause {
+			i.logger.Warn("pausing blue/green balance of backend '%s': canary error rate %.1f%% is above the baseline rate %.1f%% plus %.1f%%",
+				backend.ID, canaryRate, baselineRate, threshold)
+			pauses = append(pauses, BlueGreenPause{
+				Backend:      backend.ID,
+				Balance:      backend.BlueGreen.AutoPause.Balance,
+				CanaryRate:   canaryRate,
+				BaselineRate: baselineRate,
+				Threshold:    threshold,
+			})
+		}
+	}
+	sort.Slice(pauses, func(j, k int) bool {
+		return pauses[j].Backend < pauses[k].Backend
+	})
+	i.serverStats = next
+	return pauses
+}
+
+// checkBlueGreenCanary compares the error rate, in percent, of the canary and
+// the baseline groups. done is false if the canary group doesn't have enough
+// requests yet, and pause is true if the canary rate is above the baseline
+// rate plus the threshold.
+func checkBlueGreenCanary(canary, baseline groupStat, threshold float64) (canaryRate, baselineRate float64, pause, done bool) {
+	if canary.requests < blueGreenMinRequests {
+		return 0, 0, false, false
+	}
+	canaryRate = float64(canary.errors) * 100 / float64(canary.requests)
+	if baseline.requests > 0 {
+		baselineRate = float64(baseline.errors) * 100 / float64(baseline.requests)
+	}
+	return canaryRate, baselineRate, canaryRate > baselineRate+threshold, true
+}
+
+// parseServerStats reads the CSV output of haproxy's `show stat` command and
+// returns the request and 5xx response counters of all the servers, indexed
+// by `<backend>/<server>`.
+//
+//	# pxname,svname,...,stot,...,hrsp_5xx,...,req_tot,...
+//	default_app_8080,srv001,...,250,...,3,...,250,...
+func parseServerStats(csv string) map[string]serverStat {
+	lines := strings.Split(csv, "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "# ") {
+		return nil
+	}
+	fields := map[string]int{}
+	for j, field := range strings.Split(strings.TrimPrefix(lines[0], "# "), ",") {
+		fields[field] = j
+	}
+	value := func(row []string, field string) int {
+		j, found := fields[field]
+		if !found || j >= len(row) {
+			return 0
+		}
+		v, _ := strconv.Atoi(row[j])
+		return v
+	}
+	pxname, found1 := fields["pxname"]
+	svname, found2 := fields["svname"]
+	if !found1 || !found2 {
+		return nil
+	}
+	stats := map[string]serverStat{}
+	for _, line := range lines[1:] {
+		row := strings.Split(line, ",")
+		if len(row) <= svname || row[svname] == "BACKEND" || row[svname] == "FRONTEND" {
+			continue
+		}
+		requests := value(row, "req_tot")
+		if requests == 0 {
+			requests = value(row, "stot")
+		}
+		stats[row[pxname]+"/"+row[svname]] = serverStat{
+			requests: requests,
+			errors:   value(row, "hrsp_5xx"),
+		}
+	}
+	return stats
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestParseServerStats(t *testing.T) {
+	testCases := []struct {
+		csv      string
+		expected map[string]serverStat
+	}{
+		// 0
+		{
+			csv:      "",
+			expected: nil,
+		},
+		// 1
+		{
+			csv: `# pxname,svname,stot,hrsp_5xx,req_tot
+d1_app_8080,srv001,120,3,250
+d1_app_8080,srv002,40,,
+d1_app_8080,BACKEND,160,3,250
+`,
+			expected: map[string]serverStat{
+				"d1_app_8080/srv001": {requests: 250, errors: 3},
+				"d1_app_8080/srv002": {requests: 40},
+			},
+		},
+	}
+	for i, test := range testCases {
+		actual := parseServerStats(test.csv)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("stats differ on %d - expected: %+v - actual: %+v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestCheckBlueGreenCanary(t *testing.T) {
+	testCases := []struct {
+		canary       groupStat
+		baseline     groupStat
+		threshold    float64
+		canaryRate   float64
+		baselineRate float64
+		pause        bool
+		done         bool
+	}{
+		// 0
+		{
+			canary:    groupStat{requests: 19, errors: 19},
+			threshold: 5,
+		},
+		// 1
+		{
+			canary:     groupStat{requests: 20, errors: 1},
+			threshold:  5,
+			canaryRate: 5,
+			done:       true,
+		},
+		// 2
+		{
+			canary:     groupStat{requests: 20, errors: 2},
+			threshold:  5,
+			canaryRate: 10,
+			pause:      true,
+			done:       true,
+		},
+		// 3
+		{
+			canary:       groupStat{requests: 100, errors: 20},
+			baseline:     groupStat{requests: 1000, errors: 180},
+			threshold:    5,
+			canaryRate:   20,
+			baselineRate: 18,
+			done:         true,
+		},
+		// 4
+		{
+			canary:       groupStat{requests: 100, errors: 30},
+			baseline:     groupStat{requests: 1000, errors: 180},
+			threshold:    5,
+			canaryRate:   30,
+			baselineRate: 18,
+			pause:        true,
+			done:         true,
+		},
+		// 5
+		{
+			canary:       groupStat{requests: 100, errors: 1},
+			baseline:     groupStat{requests: 1000, errors: 0},
+			threshold:    0.5,
+			canaryRate:   1,
+			baselineRate: 0,
+			pause:        true,
+			done:         true,
+		},
+	}
+	for i, test := range testCases {
+		canaryRate, baselineRate, pause, done := checkBlueGreenCanary(test.canary, test.baseline, test.threshold)
+		if canaryRate != test.canaryRate || baselineRate != test.baselineRate || pause != test.pause || done != test.done {
+			t.Errorf("canary check differs on %d - expected: %.1f/%.1f/%t/%t - actual: %.1f/%.1f/%t/%t", i,
+				test.canaryRate, test.baselineRate, test.pause, test.done,
+				canaryRate, baselineRate, pause, done)
+		}
+	}
+}
+
+func TestAnalyzeBlueGreen(t *testing.T) {
+	type sample map[string]serverStat
+	testCases := []struct {
+		samples  []sample
+		retarget bool
+		expected []BlueGreenPause
+		logging  string
+	}{
+		// 0
+		{
+			samples: []sample{
+				{"srv001": {requests: 100, errors: 0}, "srv002": {requests: 100, errors: 0}, "srv003": {requests: 100, errors: 100}},
+			},
+		},
+		// 1
+		{
+			samples: []sample{
+				{"srv001": {requests: 0}, "srv002": {requests: 0}, "srv003": {requests: 0}},
+				{"srv001": {requests: 225}, "srv002": {requests: 225}, "srv003": {requests: 50, errors: 1}},
+			},
+		},
+		// 2
+		{
+			samples: []sample{
+				{"srv001": {requests: 0}, "srv002": {requests: 0}, "srv003": {requests: 0}},
+				{"srv001": {requests: 225}, "srv002": {requests: 225}, "srv003": {requests: 50, errors: 10}},
+			},
+			expected: []BlueGreenPause{
+				{Backend: "d1_app_8080", Balance: "group=blue=90,group=green=10", CanaryRate: 20, Threshold: 5},
+			},
+			logging: `WARN pausing blue/green balance of backend 'd1_app_8080': canary error rate 20.0% is above the baseline rate 0.0% plus 5.0%`,
+		},
+		// 3
+		{
+			samples: []sample{
+				{"srv001": {requests: 0}, "srv002": {requests: 0}, "srv003": {requests: 0}},
+				{"srv001": {requests: 225, errors: 45}, "srv002": {requests: 225, errors: 45}, "srv003": {requests: 50, errors: 12}},
+			},
+		},
+		// 4
+		{
+			samples: []sample{
+				{"srv001": {requests: 0}, "srv002": {requests: 0}, "srv003": {requests: 0}},
+				{"srv001": {requests: 45}, "srv002": {requests: 45}, "srv003": {requests: 10, errors: 5}},
+				{"srv001": {requests: 110}, "srv002": {requests: 110}, "srv003": {requests: 25, errors: 5}},
+			},
+			expected: []BlueGreenPause{
+				{Backend: "d1_app_8080", Balance: "group=blue=90,group=green=10", CanaryRate: 20, Threshold: 5},
+			},
+			logging: `WARN pausing blue/green balance of backend 'd1_app_8080': canary error rate 20.0% is above the baseline rate 0.0% plus 5.0%`,
+		},
+		// 5
+		{
+			samples: []sample{
+				{"srv001": {requests: 0}, "srv002": {requests: 0}, "srv003": {requests: 0}},
+				{"srv001": {requests: 45}, "srv002": {requests: 45}, "srv003": {requests: 20, errors: 0}},
+				{"srv001": {requests: 110}, "srv002": {requests: 110}, "srv003": {requests: 35, errors: 5}},
+			},
+		},
+		// 6
+		{
+			samples: []sample{
+				{"srv001": {requests: 9000}, "srv002": {requests: 9000}, "srv003": {requests: 1000}},
+				{"srv001": {requests: 180}, "srv002": {requests: 180}, "srv003": {requests: 40, errors: 20}},
+			},
+			expected: []BlueGreenPause{
+				{Backend: "d1_app_8080", Balance: "group=blue=90,group=green=10", CanaryRate: 50, Threshold: 5},
+			},
+			logging: `WARN pausing blue/green balance of backend 'd1_app_8080': canary error rate 50.0% is above the baseline rate 0.0% plus 5.0%`,
+		},
+		// 7
+		{
+			samples: []sample{
+				{"srv001": {requests: 0}, "srv002": {requests: 0}, "srv003": {requests: 0}},
+				{"srv001": {requests: 225}, "srv002": {requests: 225}, "srv003": {requests: 50, errors: 10}},
+			},
+			retarget: true,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.BlueGreen.AutoPause = hatypes.BlueGreenAutoPause{
+			Balance:   "group=blue=90,group=green=10",
+			Threshold: 5,
+		}
+		for _, ep := range []struct{ name, targetRef, group string }{
+			{"srv001", "d1/app-blue-1", hatypes.BlueGreenGroupBaseline},
+			{"srv002", "d1/app-blue-2", hatypes.BlueGreenGroupBaseline},
+			{"srv003", "d1/app-green-1", hatypes.BlueGreenGroupCanary},
+			{"srv004", "", ""},
+		} {
+			endpoint := b.AddEmptyEndpoint()
+			endpoint.Name = ep.name
+			endpoint.TargetRef = ep.targetRef
+			endpoint.Group = ep.group
+		}
+		var actual []BlueGreenPause
+		for j, s := range test.samples {
+			if test.retarget && j == len(test.samples)-1 {
+				// canary slot reassigned to another pod
+				b.Endpoints[2].TargetRef = "d1/app-green-2"
+			}
+			current := map[string]serverStat{}
+			for server, stat := range s {
+				current[b.ID+"/"+server] = stat
+			}
+			actual = c.instance.analyzeBlueGreen([]*hatypes.Backend{b}, current)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("blue/green pauses differ on %d - expected: %+v - actual: %+v", i, test.expected, actual)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
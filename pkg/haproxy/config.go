@@ -18,7 +18,10 @@ package haproxy
 
 import (
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/jinzhu/copier"
@@ -27,6 +30,18 @@ import (
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 )
 
+const (
+	// maxInlineACLValues is the number of values of a named ACL, above which
+	// the values are written to a file instead of the configuration file.
+	// Shorter lists are split in lines of 10 values.
+	maxInlineACLValues = 100
+
+	// maxInlineCriterionValues is the number of values of an anonymous ACL,
+	// above which the values are written to a file. Anonymous ACLs cannot
+	// be split, so this is the same limit used to split named ACLs.
+	maxInlineCriterionValues = 10
+)
+
 // Config ...
 type Config interface {
 	Frontend() *hatypes.Frontend
@@ -65,19 +80,29 @@ type options struct {
 	mapsTemplate *template.Config
 	mapsDir      string
 	shardCount   int
+	partitioned  bool
+}
+
+// CreateDetachedConfig creates a configuration model that is not bound to
+// a haproxy instance, e.g. to evaluate the outcome of the converters without
+// changing the running configuration.
+func CreateDetachedConfig() Config {
+	return createConfig(options{})
 }
 
 func createConfig(options options) *config {
 	if options.mapsTemplate == nil {
 		options.mapsTemplate = template.CreateConfig()
 	}
+	backends := hatypes.CreateBackends(options.shardCount)
+	backends.ShardByNamespace = options.partitioned
 	return &config{
 		options:     options,
 		acmeData:    &hatypes.AcmeData{},
 		global:      &hatypes.Global{},
 		frontend:    &hatypes.Frontend{},
 		hosts:       hatypes.CreateHosts(),
-		backends:    hatypes.CreateBackends(options.shardCount),
+		backends:    backends,
 		tcpbackends: hatypes.CreateTCPBackends(),
 		tcpservices: hatypes.CreateTCPServices(),
 		userlists:   hatypes.CreateUserlists(),
@@ -139,6 +164,92 @@ func (c *config) SyncConfig() {
 			host.AddPath(back, "/", hatypes.MatchBegin)
 		}
 	}
+	// the state file is dumped and loaded if at least one backend, or the
+	// defaults section, needs the servers state from the former instance
+	c.global.ServerStateFile = c.global.LoadServerState || c.backends.HasLoadServerState()
+	if c.hosts.Changed() || c.backends.Changed() {
+		c.syncAuthCookies()
+		c.syncAuthCacheTables()
+	}
+	c.syncACLLists()
+	c.syncAuthJWTFiles()
+}
+
+// syncACLLists moves long lists of ACL values to files, so haproxy doesn't
+// need to parse huge configuration lines. Lists of unchanged backends were
+// already moved in a former sync.
+func (c *config) syncACLLists() {
+	for _, backend := range c.backends.ItemsAdd() {
+		backend.SpillACLLists(c.options.mapsDir+"/_back_"+backend.ID+"_src", maxInlineACLValues)
+	}
+	for _, class := range c.global.TrafficClasses {
+		class.SpillACLLists(c.options.mapsDir+"/_front_class", maxInlineCriterionValues)
+	}
+}
+
+// syncAuthJWTFiles assigns a file to the public keys used to validate JWT
+// tokens, haproxy's jwt_verify only reads keys from files. Keys of unchanged
+// backends were already assigned in a former sync.
+func (c *config) syncAuthJWTFiles() {
+	for _, backend := range c.backends.ItemsAdd() {
+		backend.AssignAuthJWTFiles(c.options.mapsDir + "/_back_" + backend.ID + "_jwt")
+	}
+}
+
+// syncAuthCookies flags the auth backends, eg oauth2-proxy, whose cookies
+// should be changed to secure ones or have their domain changed. The auth
+// backend is not the one configured with cookie-auto-secure or the oauth
+// cookie keys, so it is only known after all the backends are synchronized.
+// Unchanged backends might need to be rendered again.
+func (c *config) syncAuthCookies() {
+	secure := c.backends.BuildSecureCookieAuthBackends()
+	setSecure := c.backends.BuildSetSecureCookieAuthBackends()
+	domains := c.backends.BuildCookieDomainAuthBackends()
+	for _, backend := range c.backends.Items() {
+		if backend.AuthCookieSecure != secure[backend.ID] ||
+			backend.AuthCookieSetSecure != setSecure[backend.ID] ||
+			!reflect.DeepEqual(backend.AuthCookieDomains, domains[backend.ID]) {
+			backend.AuthCookieSecure = secure[backend.ID]
+			backend.AuthCookieSetSecure = setSecure[backend.ID]
+			backend.AuthCookieDomains = domains[backend.ID]
+			c.backends.BackendChanged(backend)
+		}
+	}
+}
+
+// syncAuthCacheTables lists the stick tables used to cache the responses of
+// auth services, from auth configured in the backends and in the frontend.
+// Tables are declared only once even if shared by distinct paths.
+func (c *config) syncAuthCacheTables() {
+	tables := map[string]*hatypes.AuthCacheTable{}
+	addTables := func(auth *hatypes.AuthExternal) {
+		if name := auth.CacheTableName(); name != "" {
+			tables[name] = &hatypes.AuthCacheTable{Name: name, Expire: auth.Cache.Duration, Size: auth.Cache.Size}
+		}
+		if name := auth.CacheDenyTableName(); name != "" {
+			tables[name] = &hatypes.AuthCacheTable{Name: name, Expire: auth.Cache.DenyDuration, Size: auth.Cache.Size}
+		}
+	}
+	for _, backend := range c.backends.Items() {
+		for _, path := range backend.Paths {
+			addTables(&path.AuthExternal)
+		}
+	}
+	for _, host := range c.hosts.Items() {
+		for _, path := range host.Paths {
+			if path.AuthExt != nil {
+				addTables(path.AuthExt)
+			}
+		}
+	}
+	cacheTables := make([]*hatypes.AuthCacheTable, 0, len(tables))
+	for _, table := range tables {
+		cacheTables = append(cacheTables, table)
+	}
+	sort.Slice(cacheTables, func(i, j int) bool {
+		return cacheTables[i].Name < cacheTables[j].Name
+	})
+	c.frontend.AuthProxy.CacheTables = cacheTables
 }
 
 // WriteTCPServicesMaps reads the model and writes haproxy's maps
@@ -166,6 +277,13 @@ func (c *config) WriteTCPServicesMaps() error {
 // config file. This func doesn't change model state, except the
 // link to the frontend maps.
 func (c *config) WriteFrontendMaps() error {
+	if c.globalOld == nil || !reflect.DeepEqual(c.globalOld.TrafficClasses, c.global.TrafficClasses) {
+		for _, class := range c.global.TrafficClasses {
+			if err := writeACLLists(class.ACLLists(), c.options.mapsTemplate); err != nil {
+				return err
+			}
+		}
+	}
 	if c.frontend.Maps != nil && !c.hosts.Changed() {
 		// TODO Maps!=nil just to preserve the current behavior. Check if this can be removed.
 		// hosts are clean, maps are updated
@@ -179,10 +297,17 @@ func (c *config) WriteFrontendMaps() error {
 		//
 		RedirFromRootMap:  mapBuilder.AddMap(mapsDir + "/_front_redir_fromroot.map"),
 		RedirFromMap:      mapBuilder.AddMap(mapsDir + "/_front_redir_from.map"),
+		RedirFromPortMap:  mapBuilder.AddMap(mapsDir + "/_front_redir_from_port.map"),
 		RedirRootSSLMap:   mapBuilder.AddMap(mapsDir + "/_front_redir_root_ssl.map"),
 		RedirToMap:        mapBuilder.AddMap(mapsDir + "/_front_redir_to.map"),
 		SSLPassthroughMap: mapBuilder.AddMap(mapsDir + "/_front_sslpassthrough.map"),
 		VarNamespaceMap:   mapBuilder.AddMap(mapsDir + "/_front_namespace.map"),
+		PathNormalizeMap:  mapBuilder.AddMap(mapsDir + "/_front_path_normalize.map"),
+		AuditBackendMap:   mapBuilder.AddMap(mapsDir + "/_front_audit_backend.map"),
+		AuditPercentMap:   mapBuilder.AddMap(mapsDir + "/_front_audit_percent.map"),
+		SplitPathMap:      mapBuilder.AddMap(mapsDir + "/_front_split_path.map"),
+		BlockHTTP10Map:    mapBuilder.AddMap(mapsDir + "/_front_block_http10.map"),
+		QUICAltSvcMap:     mapBuilder.AddMap(mapsDir + "/_front_quic_altsvc.map"),
 		//
 		TLSAuthList:           mapBuilder.AddMap(mapsDir + "/_front_tls_auth.list"),
 		TLSNeedCrtList:        mapBuilder.AddMap(mapsDir + "/_front_tls_needcrt.list"),
@@ -200,11 +325,18 @@ func (c *config) WriteFrontendMaps() error {
 	if defaultHost != nil && !defaultHost.SSLPassthrough() {
 		for _, path := range defaultHost.Paths {
 			// using DefaultHost ID as hostname, see types.maps.go/buildMapKey()
-			fmaps.DefaultHostMap.AddHostnamePathMapping(hatypes.DefaultHost, path, path.Backend.ID)
+			fmaps.DefaultHostMap.AddHostnamePathMapping(hatypes.DefaultHost, defaultHost.MapPath(path), path.Backend.ID)
 		}
 	}
+	// requests without a Host header are served by the default host, so its
+	// config overrides the global one
+	c.frontend.RequireHostHeader = c.global.HTTPProtocol.RequireHostHeader
+	if defaultHost != nil {
+		c.frontend.RequireHostHeader = defaultHost.HTTPProtocol.RequireHostHeader
+	}
 	for _, host := range c.hosts.BuildSortedItems() {
-		for _, path := range host.Paths {
+		for _, hostPath := range host.Paths {
+			path := host.MapPath(hostPath)
 			backendID := path.Backend.ID
 			// IMPLEMENT check if host.Alias.AliasName was already used as a hostname
 			if backendID != "" {
@@ -245,12 +377,47 @@ func (c *config) WriteFrontendMaps() error {
 		if host.SSLPassthrough() {
 			continue
 		}
+		splitIDs := make([]string, len(host.Paths))
+		hasSplit := false
+		for i, path := range host.Paths {
+			splitIDs[i] = c.backends.SplitID(path)
+			hasSplit = hasSplit || splitIDs[i] != ""
+		}
+		if hasSplit {
+			// add "-" on paths not being split, otherwise a longer path of
+			// the same host would be wrongly split by a shorter one
+			for i, path := range host.Paths {
+				splitID := splitIDs[i]
+				if splitID == "" {
+					splitID = "-"
+				}
+				path = host.MapPath(path)
+				fmaps.SplitPathMap.AddHostnamePathMapping(host.Hostname, path, splitID)
+				fmaps.SplitPathMap.AddAliasPathMapping(host.Alias, path, splitID)
+			}
+		}
+		if host.PathNormalization != hatypes.PathNormalizationOff {
+			fmaps.PathNormalizeMap.AddHostnameMapping(host.Hostname, string(host.PathNormalization))
+		}
+		if host.HTTPProtocol.BlockHTTP10 {
+			fmaps.BlockHTTP10Map.AddHostnameMapping(host.Hostname, "true")
+		}
+		if c.global.QUIC.Bind != "" && host.QUIC.AltSvcMaxAge > 0 && host.HasTLS() {
+			fmaps.QUICAltSvcMap.AddHostnameMapping(host.Hostname, strconv.Itoa(host.QUIC.AltSvcMaxAge))
+		}
+		if host.HasAudit() {
+			fmaps.AuditBackendMap.AddHostnameMapping(host.Hostname, host.Audit.Backend.String())
+			fmaps.AuditPercentMap.AddHostnameMapping(host.Hostname, strconv.Itoa(host.Audit.SamplePercent))
+		}
 		if host.Redirect.RedirectHost != "" {
 			fmaps.RedirFromMap.AddHostnameMapping(host.Redirect.RedirectHost, host.Hostname)
 		}
 		if host.Redirect.RedirectHostRegex != "" {
 			fmaps.RedirFromMap.AddHostnameMappingRegex(host.Redirect.RedirectHostRegex, host.Hostname)
 		}
+		if host.Redirect.HTTPSPort > 0 && (host.Redirect.RedirectHost != "" || host.Redirect.RedirectHostRegex != "") {
+			fmaps.RedirFromPortMap.AddHostnameMapping(host.Hostname, strconv.Itoa(host.Redirect.HTTPSPort))
+		}
 		if host.HasTLSAuth() {
 			if host.TLS.CAVerify != hatypes.CAVerifySkipCheck {
 				fmaps.TLSAuthList.AddHostnameMapping(host.Hostname, "")
@@ -273,6 +440,9 @@ func (c *config) WriteFrontendMaps() error {
 			// we need to redirect to https before redirect the path.
 			redirectssl := func() bool {
 				redir := c.global.SSL.SSLRedirect
+				if host.TLS.SSLRedirect != nil {
+					redir = *host.TLS.SSLRedirect
+				}
 				for _, path := range host.FindPath("/") {
 					if backend := c.backends.Items()[path.Backend.ID]; backend != nil {
 						if bpath := backend.FindBackendPath(path.Link); bpath != nil {
@@ -286,7 +456,7 @@ func (c *config) WriteFrontendMaps() error {
 				}
 				return redir
 			}
-			if redirectssl() {
+			if !host.TLS.HTTPOnly && redirectssl() {
 				fmaps.RedirRootSSLMap.AddHostnameMapping(host.Hostname, "")
 			}
 			fmaps.RedirFromRootMap.AddHostnameMapping(host.Hostname, host.RootRedirect)
@@ -359,6 +529,12 @@ func (c *config) WriteBackendMaps() error {
 	}
 	mapBuilder := hatypes.CreateMaps(c.global.MatchOrder)
 	for _, backend := range c.backends.ItemsAdd() {
+		if err := writeACLLists(backend.ACLLists(), c.options.mapsTemplate); err != nil {
+			return err
+		}
+		if err := writeAuthJWTFiles(backend.AuthJWTFiles()); err != nil {
+			return err
+		}
 		if backend.NeedACL() {
 			mapsPrefix := c.options.mapsDir + "/_back_" + backend.ID
 			pathsMap := mapBuilder.AddMap(mapsPrefix + "_idpath.map")
@@ -373,6 +549,7 @@ func (c *config) WriteBackendMaps() error {
 				if p == nil {
 					continue
 				}
+				p = h.MapPath(p)
 				if path.IsDefaultHost() {
 					// using DefaultHost ID as hostname, see types.maps.go/buildMapKey()
 					pathsDefaultHostMap.AddHostnamePathMapping(hatypes.DefaultHost, p, path.ID)
@@ -399,6 +576,28 @@ func writeMaps(maps *hatypes.HostsMaps, template *template.Config) error {
 	return nil
 }
 
+func writeACLLists(lists map[string][]string, template *template.Config) error {
+	for filename, values := range lists {
+		entries := make([]*hatypes.HostsMapEntry, len(values))
+		for i, value := range values {
+			entries[i] = &hatypes.HostsMapEntry{Key: value}
+		}
+		if err := template.WriteOutput(entries, filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAuthJWTFiles(files map[string]string) error {
+	for filename, content := range files {
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *config) AcmeData() *hatypes.AcmeData {
 	return c.acmeData
 }
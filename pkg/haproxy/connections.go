@@ -41,6 +41,8 @@ type connections struct {
 	master       socket.HAProxySocket
 	dynUpdate    socket.HAProxySocket
 	idleChk      socket.HAProxySocket
+	statsChk     socket.HAProxySocket
+	warmUp       socket.HAProxySocket
 }
 
 func (c *connections) TrackCurrentInstance(timeoutStopDur, closeSessDur time.Duration) error {
@@ -159,3 +161,19 @@ func (c *connections) IdleChk() socket.HAProxySocket {
 	}
 	return c.idleChk
 }
+
+func (c *connections) StatsChk() socket.HAProxySocket {
+	if c.statsChk == nil {
+		// also used without the model lock, see StatsSample
+		c.statsChk = socket.NewSocketConcurrent(c.adminSock, false)
+	}
+	return c.statsChk
+}
+
+func (c *connections) WarmUp() socket.HAProxySocket {
+	if c.warmUp == nil {
+		// non persistent, commands should reach the current instance
+		c.warmUp = socket.NewSocket(c.adminSock, false)
+	}
+	return c.warmUp
+}
@@ -31,11 +31,14 @@ import (
 )
 
 type dynUpdater struct {
-	logger  types.Logger
-	config  *config
-	socket  socket.HAProxySocket
-	cmdCnt  int
-	metrics types.Metrics
+	logger    types.Logger
+	config    *config
+	socket    socket.HAProxySocket
+	cmdCnt    int
+	cmdFailed int
+	sockErr   bool
+	urgent    bool
+	metrics   types.Metrics
 }
 
 type hostPair struct {
@@ -63,6 +66,10 @@ func (i *instance) newDynUpdater() *dynUpdater {
 }
 
 func (d *dynUpdater) update() bool {
+	if !d.config.hasCommittedData() {
+		// first configuration, haproxy is started
+		d.urgent = true
+	}
 	updated := d.config.hasCommittedData() && d.checkConfigChange()
 	if !updated {
 		// Need to reload, time to adjust empty slots according to config
@@ -100,6 +107,26 @@ func (d *dynUpdater) checkConfigChange() bool {
 	if !d.backendUpdated() {
 		diff = append(diff, "backends")
 	}
+	if d.backendRenamed() {
+		diff = append(diff, "backend names")
+	}
+	if d.backendSlotsChanged() {
+		diff = append(diff, "external name slots")
+	}
+	for _, reason := range diff {
+		// endpoint only and external name slot changes can have their reload
+		// deferred, urgent backend changes are flagged by checkBackendPair()
+		if reason != "backends" && reason != "external name slots" {
+			d.urgent = true
+		}
+	}
+	if d.cmdFailed > 0 {
+		d.urgent = true
+		// haproxy state might have diverged from the model, which
+		// is fixed by a full reload based on the current model
+		d.logger.Warn("need to reload: dynamic update failed, %d of %d socket commands failed", d.cmdFailed, d.cmdCnt)
+		return false
+	}
 	if len(diff) > 0 {
 		d.logger.InfoV(2, "need to reload due to config changes: %v", diff)
 		return false
@@ -151,6 +178,7 @@ func (d *dynUpdater) backendUpdated() bool {
 		back, found := backends[id]
 		if !found {
 			d.logger.InfoV(2, "added backend '%s'", id)
+			d.urgent = true
 			updated = false
 		} else {
 			back.cur = backend
@@ -169,6 +197,43 @@ func (d *dynUpdater) backendUpdated() bool {
 	return updated
 }
 
+// backendRenamed returns true if a removed backend was added back with
+// another name, eg due to a port rename or a change in the naming scheme.
+// Such backends are already handled as remove+add by backendUpdated, this
+// only records the reason of the reload.
+func (d *dynUpdater) backendRenamed() bool {
+	renamed := false
+	removed := make(map[string]string, len(d.config.backends.ItemsDel()))
+	for id, backend := range d.config.backends.ItemsDel() {
+		if _, found := d.config.backends.ItemsAdd()[id]; !found {
+			removed[backend.Namespace+"/"+backend.Name] = id
+		}
+	}
+	for id, backend := range d.config.backends.ItemsAdd() {
+		if _, found := d.config.backends.ItemsDel()[id]; found {
+			continue
+		}
+		if oldID, found := removed[backend.Namespace+"/"+backend.Name]; found {
+			d.logger.InfoV(2, "renamed backend '%s' to '%s'", oldID, id)
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// backendSlotsChanged returns true if the number of server-template slots of
+// a backend was changed. Such backends are already handled by checkBackendPair,
+// this only records the reason of the reload.
+func (d *dynUpdater) backendSlotsChanged() bool {
+	changed := false
+	for id, backend := range d.config.backends.ItemsAdd() {
+		if oldBackend, found := d.config.backends.ItemsDel()[id]; found && oldBackend.DNS.Slots != backend.DNS.Slots {
+			changed = true
+		}
+	}
+	return changed
+}
+
 func (d *dynUpdater) checkHostPair(pair *hostPair) bool {
 	oldHost := pair.old
 	curHost := pair.cur
@@ -195,6 +260,22 @@ func (d *dynUpdater) checkHostPair(pair *hostPair) bool {
 	return updated
 }
 
+// backendCosmeticDiff returns true if old and cur differ only in the config
+// that changes how requests are balanced, checked and timed out, but not how
+// they are routed, secured or authenticated. The reload of such a change is
+// not urgent and can be deferred.
+func backendCosmeticDiff(old, cur *hatypes.Backend) bool {
+	oldCopy := *old
+	oldCopy.AgentCheck = cur.AgentCheck
+	oldCopy.BalanceAlgorithm = cur.BalanceAlgorithm
+	oldCopy.HealthCheck = cur.HealthCheck
+	oldCopy.ReloadSensitive = cur.ReloadSensitive
+	oldCopy.RetryBudgetWarn = cur.RetryBudgetWarn
+	oldCopy.Timeout = cur.Timeout
+	oldCopy.WarmUp = cur.WarmUp
+	return reflect.DeepEqual(&oldCopy, cur)
+}
+
 func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	oldBack := pair.old
 	curBack := pair.cur
@@ -211,8 +292,17 @@ func (d *dynUpdater) checkBackendPair(pair *backendPair) bool {
 	oldBackCopy.ID = curBack.ID
 	oldBackCopy.Dynamic = curBack.Dynamic
 	oldBackCopy.Endpoints = curBack.Endpoints
+	if oldBack.DNS.Slots != curBack.DNS.Slots {
+		// server-template slots can only be changed on a reload
+		d.logger.InfoV(2, "changed external name slots of backend '%s' from %d to %d", curBack.ID, oldBack.DNS.Slots, curBack.DNS.Slots)
+		oldBackCopy.DNS.Slots = curBack.DNS.Slots
+		updated = false
+	}
 	if !reflect.DeepEqual(&oldBackCopy, curBack) {
 		d.logger.InfoV(2, "diff outside endpoints of backend '%s'", curBack.ID)
+		if !backendCosmeticDiff(&oldBackCopy, curBack) {
+			d.urgent = true
+		}
 		updated = false
 	}
 
@@ -382,6 +472,9 @@ func (d *dynUpdater) alignSlots() {
 var readFile = os.ReadFile
 
 func (d *dynUpdater) execUpdateCert(hostname, filename string) bool {
+	if d.sockErr {
+		return false
+	}
 	// TODO read from the internal storage
 	payload, err := readFile(filename)
 	if err != nil {
@@ -408,13 +501,18 @@ func (d *dynUpdater) execUpdateCert(hostname, filename string) bool {
 	}
 	if !cmdResponseOK("commit ssl cert", msg[1]) {
 		d.logger.Warn("cannot update certificate for %s", hostname)
+		d.cmdFailure(len(cmd))
 		return false
 	}
+	d.metrics.AddSocketCmdSucceeded(len(cmd))
 	d.logger.Info("certificate updated for %s", hostname)
 	return true
 }
 
 func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint) bool {
+	if d.sockErr {
+		return false
+	}
 	server := fmt.Sprintf("set server %s/%s ", backname, ep.Name)
 	cmd := []string{
 		server + "state maint",
@@ -430,17 +528,25 @@ func (d *dynUpdater) execDisableEndpoint(backname string, ep *hatypes.Endpoint)
 		if m != "" {
 			if !cmdResponseOK("set server", m) {
 				d.logger.Warn("unrecognized response disabling endpoint %s/%s: %s", backname, ep.Name, m)
+				d.cmdFailure(len(cmd))
 				return false
 			}
 			d.logger.InfoV(2, "response from server: %s", m)
 		}
 	}
+	d.metrics.AddSocketCmdSucceeded(len(cmd))
 	d.logger.InfoV(2, "disabled endpoint '%s' on backend/server '%s/%s'", ep.Target, backname, ep.Name)
 	return true
 }
 
 func (d *dynUpdater) execEnableEndpoint(backname string, oldEP, curEP *hatypes.Endpoint) bool {
+	if d.sockErr {
+		return false
+	}
 	state := map[bool]string{true: "ready", false: "drain"}[curEP.Weight > 0]
+	if curEP.Maintenance {
+		state = "maint"
+	}
 	server := fmt.Sprintf("set server %s/%s ", backname, curEP.Name)
 	cmd := []string{
 		server + "addr " + curEP.IP + " port " + strconv.Itoa(curEP.Port),
@@ -456,11 +562,13 @@ func (d *dynUpdater) execEnableEndpoint(backname string, oldEP, curEP *hatypes.E
 		if m != "" {
 			if !cmdResponseOK("set server", m) {
 				d.logger.Warn("unrecognized response adding/updating endpoint %s/%s: %s", backname, curEP.Name, m)
+				d.cmdFailure(len(cmd))
 				return false
 			}
 			d.logger.InfoV(2, "response from server: %s", m)
 		}
 	}
+	d.metrics.AddSocketCmdSucceeded(len(cmd))
 	event := map[bool]string{true: "updated", false: "added"}[oldEP != nil]
 	d.logger.InfoV(2, "%s endpoint '%s' weight '%d' state '%s' on backend/server '%s/%s'",
 		event, curEP.Target, curEP.Weight, state, backname, curEP.Name)
@@ -470,9 +578,21 @@ func (d *dynUpdater) execEnableEndpoint(backname string, oldEP, curEP *hatypes.E
 func (d *dynUpdater) execCommand(observer func(duration time.Duration), cmd []string) ([]string, error) {
 	msg, err := d.socket.Send(observer, cmd...)
 	d.cmdCnt = d.cmdCnt + len(cmd)
+	d.metrics.AddSocketCmdAttempted(len(cmd))
+	if err != nil {
+		// the socket is not reliable anymore, skip the remaining commands
+		// of this sync, a full reload is going to be scheduled instead
+		d.sockErr = true
+		d.cmdFailure(len(cmd))
+	}
 	return msg, err
 }
 
+func (d *dynUpdater) cmdFailure(count int) {
+	d.cmdFailed = d.cmdFailed + count
+	d.metrics.AddSocketCmdFailed(count)
+}
+
 func cmdResponseOK(cmd, response string) bool {
 	switch cmd {
 	case "set server":
@@ -21,9 +21,12 @@ import (
 	"reflect"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/kylelemons/godebug/diff"
+
+	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 )
 
 func TestDynUpdate(t *testing.T) {
@@ -34,6 +37,8 @@ func TestDynUpdate(t *testing.T) {
 		dynamic   bool
 		cmd       string
 		cmdOutput []string
+		cmdFailAt int
+		sockCmds  []int
 		logging   string
 	}{
 		// 0
@@ -825,7 +830,7 @@ set server default_app_8080/srv003 weight 1
 			logging: `
 WARN unrecognized response adding/updating endpoint default_app_8080/srv002: No such server.
 WARN unrecognized response adding/updating endpoint default_app_8080/srv003: No such server.
-INFO-V(2) need to reload due to config changes: [backends]
+WARN need to reload: dynamic update failed, 6 of 6 socket commands failed
 `,
 		},
 		// 29
@@ -859,7 +864,7 @@ set server default_app_8080/srv003 weight 0
 			logging: `
 WARN unrecognized response disabling endpoint default_app_8080/srv002: No such server.
 WARN unrecognized response disabling endpoint default_app_8080/srv003: No such server.
-INFO-V(2) need to reload due to config changes: [backends]
+WARN need to reload: dynamic update failed, 6 of 6 socket commands failed
 `,
 		},
 		// 28
@@ -977,7 +982,7 @@ commit ssl cert /tmp/domain1.pem
 INFO-V(2) response from server: Can't replace a certificate which is not referenced by the configuration! \\ Can't update /tmp/domain1.pem!
 INFO-V(2) response from server: No ongoing transaction! ! \\ Can't commit /tmp/domain1.pem!
 WARN cannot update certificate for domain1.local
-INFO-V(2) need to reload due to config changes: [hosts]
+WARN need to reload: dynamic update failed, 2 of 2 socket commands failed
 `,
 		},
 		// 32
@@ -999,6 +1004,276 @@ INFO-V(2) removed host 'domain2.local'
 INFO-V(2) need to reload due to config changes: [hosts]
 `,
 		},
+		// 33
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "").Name = "srv002"
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Name = "srv003"
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.4", 8080, "")
+				b.AcquireEndpoint("172.17.0.5", 8080, "")
+			},
+			expected: []string{
+				"srv002:172.17.0.4:8080:1",
+				"srv003:172.17.0.5:8080:1",
+			},
+			dynamic: false,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.4 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1
+set server default_app_8080/srv003 addr 172.17.0.5 port 8080
+set server default_app_8080/srv003 state ready
+set server default_app_8080/srv003 weight 1
+`,
+			cmdFailAt: 4,
+			sockCmds:  []int{6, 3, 3},
+			logging: `
+INFO-V(2) updated endpoint '172.17.0.4:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'
+ERROR error adding/updating endpoint default_app_8080/srv003: socket timeout
+WARN need to reload: dynamic update failed, 3 of 6 socket commands failed
+`,
+		},
+		// 34
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "").Name = "srv002"
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Name = "srv003"
+				b.AcquireEndpoint("172.17.0.4", 8080, "").Name = "srv004"
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.5", 8080, "")
+			},
+			expected: []string{
+				"srv002:172.17.0.5:8080:1",
+				"srv003:127.0.0.1:1023:1",
+				"srv004:127.0.0.1:1023:1",
+			},
+			dynamic: false,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.5 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1
+`,
+			cmdFailAt: 1,
+			sockCmds:  []int{3, 0, 3},
+			logging: `
+ERROR error adding/updating endpoint default_app_8080/srv002: socket timeout
+WARN need to reload: dynamic update failed, 3 of 3 socket commands failed
+`,
+		},
+		// 35
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AllDownResponse.StatusCode = 503
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AllDownResponse.StatusCode = 503
+				b.Dynamic.DynUpdate = true
+			},
+			expected: []string{
+				"srv001:127.0.0.1:1023:1",
+				"srv002:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv001 state maint
+set server default_app_8080/srv001 addr 127.0.0.1 port 1023
+set server default_app_8080/srv001 weight 0
+set server default_app_8080/srv002 state maint
+set server default_app_8080/srv002 addr 127.0.0.1 port 1023
+set server default_app_8080/srv002 weight 0
+`,
+			logging: `
+INFO-V(2) disabled endpoint '172.17.0.2:8080' on backend/server 'default_app_8080/srv001'
+INFO-V(2) disabled endpoint '172.17.0.3:8080' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 36
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AllDownResponse.Location = "https://status.local"
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+			},
+			dynamic: false,
+			logging: `
+INFO-V(2) diff outside endpoints of backend 'default_app_8080'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
+		// 37
+		{
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				c.config.Backends().Naming = hatypes.BackendNamingHash
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+			},
+			dynamic: false,
+			logging: `
+INFO-V(2) added backend 'b_6643a9fcfcc95470'
+INFO-V(2) renamed backend 'default_app_8080' to 'b_6643a9fcfcc95470'
+INFO-V(2) need to reload due to config changes: [backends backend names]`,
+		},
+		// 38
+		{
+			// ready endpoint replaced by terminating ones, see fallback-to-terminating
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AddEmptyEndpoint()
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+				b.AcquireEndpoint("172.17.0.4", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.3:8080:1",
+				"srv002:172.17.0.4:8080:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv001 addr 172.17.0.3 port 8080
+set server default_app_8080/srv001 state ready
+set server default_app_8080/srv001 weight 1
+set server default_app_8080/srv002 addr 172.17.0.4 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1`,
+			logging: `
+INFO-V(2) updated endpoint '172.17.0.3:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv001'
+INFO-V(2) added endpoint '172.17.0.4:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 39
+		{
+			// terminating endpoints replaced by a ready one
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+				b.AcquireEndpoint("172.17.0.4", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:127.0.0.1:1023:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv001 addr 172.17.0.2 port 8080
+set server default_app_8080/srv001 state ready
+set server default_app_8080/srv001 weight 1
+set server default_app_8080/srv002 state maint
+set server default_app_8080/srv002 addr 127.0.0.1 port 1023
+set server default_app_8080/srv002 weight 0`,
+			logging: `
+INFO-V(2) updated endpoint '172.17.0.2:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv001'
+INFO-V(2) disabled endpoint '172.17.0.4:8080' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 40
+		{
+			// pod in maintenance
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Maintenance = true
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.3 port 8080
+set server default_app_8080/srv002 state maint
+set server default_app_8080/srv002 weight 1`,
+			logging: `
+INFO-V(2) updated endpoint '172.17.0.3:8080' weight '1' state 'maint' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 41
+		{
+			// pod back from maintenance
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "").Maintenance = true
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Dynamic.DynUpdate = true
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+				"srv002:172.17.0.3:8080:1",
+			},
+			dynamic: true,
+			cmd: `
+set server default_app_8080/srv002 addr 172.17.0.3 port 8080
+set server default_app_8080/srv002 state ready
+set server default_app_8080/srv002 weight 1`,
+			logging: `
+INFO-V(2) updated endpoint '172.17.0.3:8080' weight '1' state 'ready' on backend/server 'default_app_8080/srv002'`,
+		},
+		// 42
+		{
+			// only the server-template slots changed
+			doconfig1: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Resolver = "k8s"
+				b.DNS.Slots = 4
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			doconfig2: func(c *testConfig) {
+				b := c.config.Backends().AcquireBackend("default", "app", "8080")
+				b.Resolver = "k8s"
+				b.DNS.Slots = 8
+				b.AcquireEndpoint("172.17.0.2", 8080, "")
+			},
+			expected: []string{
+				"srv001:172.17.0.2:8080:1",
+			},
+			dynamic: false,
+			logging: `
+INFO-V(2) changed external name slots of backend 'default_app_8080' from 4 to 8
+INFO-V(2) need to reload due to config changes: [backends external name slots]`,
+		},
 	}
 	readFile = func(_ string) ([]byte, error) {
 		return []byte("<content>"), nil
@@ -1022,11 +1297,12 @@ INFO-V(2) need to reload due to config changes: [hosts]
 		if test.doconfig2 != nil {
 			test.doconfig2(c)
 		}
-		clientMock := &clientMock{
-			cmdOutput: test.cmdOutput,
+		socketMock := &ha_helper.SocketMock{
+			Output: test.cmdOutput,
+			FailAt: test.cmdFailAt,
 		}
 		dynUpdater := c.instance.newDynUpdater()
-		dynUpdater.socket = clientMock
+		dynUpdater.socket = socketMock
 		dynamic := dynUpdater.update()
 		var actual []string
 		for _, ep := range c.config.Backends().AcquireBackend("default", "app", "8080").Endpoints {
@@ -1039,40 +1315,113 @@ INFO-V(2) need to reload due to config changes: [hosts]
 		if dynamic != test.dynamic {
 			t.Errorf("dynamic expected as '%t' on %d, but was '%t'", test.dynamic, i, dynamic)
 		}
-		cmd := strings.TrimSpace(clientMock.cmd)
+		cmd := strings.TrimSpace(socketMock.Commands())
 		test.cmd = strings.TrimSpace(test.cmd)
 		if cmd != test.cmd {
 			t.Errorf("cmd differs on %d:\n%s", i, diff.Diff(test.cmd, cmd))
 		}
+		if test.sockCmds != nil {
+			metrics := c.instance.metrics.(*helper_test.MetricsMock)
+			sockCmds := []int{metrics.SocketCmdAttempted, metrics.SocketCmdSucceeded, metrics.SocketCmdFailed}
+			if !reflect.DeepEqual(sockCmds, test.sockCmds) {
+				t.Errorf("socket commands attempted/succeeded/failed differs on %d -- expected: %v -- actual: %v",
+					i, test.sockCmds, sockCmds)
+			}
+		}
 		c.logger.CompareLogging(test.logging)
 		c.teardown()
 	}
 }
 
-type clientMock struct {
-	cmd       string
-	cmdOutput []string
-}
-
-func (cli *clientMock) Address() string {
-	return ""
-}
-
-func (cli *clientMock) HasConn() bool {
-	return true
-}
-
-func (cli *clientMock) Send(observer func(duration time.Duration), command ...string) ([]string, error) {
-	for _, c := range command {
-		cli.cmd = cli.cmd + c + "\n"
+func TestDynUpdateUrgent(t *testing.T) {
+	testCases := []struct {
+		doconfig2 func(c *testConfig, b *hatypes.Backend)
+		urgent    bool
+		logging   string
+	}{
+		// 0
+		{
+			doconfig2: func(c *testConfig, b *hatypes.Backend) {
+				b.Dynamic.DynUpdate = false
+				b.AcquireEndpoint("172.17.0.3", 8080, "")
+			},
+			urgent: false,
+			logging: `
+INFO-V(2) added endpoints on backend 'default_app_8080'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
+		// 1
+		{
+			doconfig2: func(c *testConfig, b *hatypes.Backend) {
+				b.BalanceAlgorithm = "leastconn"
+				b.Timeout.Server = "1h"
+			},
+			urgent: false,
+			logging: `
+INFO-V(2) diff outside endpoints of backend 'default_app_8080'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
+		// 2
+		{
+			doconfig2: func(c *testConfig, b *hatypes.Backend) {
+				b.Cookie.Name = "serverId"
+			},
+			urgent: true,
+			logging: `
+INFO-V(2) diff outside endpoints of backend 'default_app_8080'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
+		// 3
+		{
+			doconfig2: func(c *testConfig, b *hatypes.Backend) {
+				b.BalanceAlgorithm = "leastconn"
+				b.AllowedIPTCP.Rule = []string{"10.0.0.0/8"}
+			},
+			urgent: true,
+			logging: `
+INFO-V(2) diff outside endpoints of backend 'default_app_8080'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
+		// 4
+		{
+			doconfig2: func(c *testConfig, b *hatypes.Backend) {
+				c.config.Backends().AcquireBackend("default", "app2", "8080")
+			},
+			urgent: true,
+			logging: `
+INFO-V(2) added backend 'default_app2_8080'
+INFO-V(2) need to reload due to config changes: [backends]`,
+		},
+		// 5
+		{
+			doconfig2: func(c *testConfig, b *hatypes.Backend) {
+				c.config.Global().MaxConn = 1000
+			},
+			urgent: true,
+			logging: `
+INFO-V(2) need to reload due to config changes: [global]`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		b := c.config.Backends().AcquireBackend("default", "app", "8080")
+		b.Dynamic.DynUpdate = true
+		b.AcquireEndpoint("172.17.0.2", 8080, "")
+		c.instance.config.Commit()
+		c.config.Backends().RemoveAll([]string{b.ID})
+		b = c.config.Backends().AcquireBackend("default", "app", "8080")
+		b.Dynamic.DynUpdate = true
+		b.AcquireEndpoint("172.17.0.2", 8080, "")
+		test.doconfig2(c, b)
+		dynUpdater := c.instance.newDynUpdater()
+		dynUpdater.socket = &ha_helper.SocketMock{}
+		if dynUpdater.update() {
+			t.Errorf("expected a reload on %d", i)
+		}
+		if dynUpdater.urgent != test.urgent {
+			t.Errorf("urgent expected as '%t' on %d, but was '%t'", test.urgent, i, dynUpdater.urgent)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
 	}
-	return cli.cmdOutput, nil
-}
-
-func (cli *clientMock) Unlistening() error {
-	return nil
-}
-
-func (cli *clientMock) Close() error {
-	return nil
 }
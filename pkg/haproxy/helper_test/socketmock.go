@@ -0,0 +1,104 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SocketMock is a fake haproxy admin or master socket which implements
+// socket.HAProxySocket. Sent commands are recorded, and failures can be
+// injected either on every call, via Err, or from the Nth command on, via
+// FailAt. Output is returned on calls that succeed, unless CmdOutput has
+// an output for the first command of the call.
+type SocketMock struct {
+	Addr      string
+	Output    []string
+	CmdOutput map[string][]string
+	Err       error
+	FailAt    int
+	//
+	mutex    sync.Mutex
+	commands []string
+	calls    int
+}
+
+// Address ...
+func (s *SocketMock) Address() string {
+	return s.Addr
+}
+
+// HasConn ...
+func (s *SocketMock) HasConn() bool {
+	return true
+}
+
+// Send ...
+func (s *SocketMock) Send(observer func(duration time.Duration), command ...string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls++
+	s.commands = append(s.commands, command...)
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	if s.FailAt > 0 && len(s.commands) >= s.FailAt {
+		// fails the batch with the Nth command, and all the following ones
+		return nil, fmt.Errorf("socket timeout")
+	}
+	if len(command) > 0 {
+		if out, found := s.CmdOutput[command[0]]; found {
+			return out, nil
+		}
+	}
+	return s.Output, nil
+}
+
+// Unlistening ...
+func (s *SocketMock) Unlistening() error {
+	return nil
+}
+
+// Close ...
+func (s *SocketMock) Close() error {
+	return nil
+}
+
+// SetErr changes the error returned by all the following calls, it is safe
+// to call while the socket is in use.
+func (s *SocketMock) SetErr(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Err = err
+}
+
+// Calls returns the number of calls to Send.
+func (s *SocketMock) Calls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.calls
+}
+
+// Commands returns all the sent commands, one per line.
+func (s *SocketMock) Commands() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return strings.Join(s.commands, "\n")
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package haproxy
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -44,6 +45,7 @@ type InstanceOptions struct {
 	RootFSPrefix      string
 	LocalFSPrefix     string
 	BackendShards     int
+	PartitionBackends bool
 	HAProxyCfgDir     string
 	HAProxyMapsDir    string
 	LeaderElector     types.LeaderElector
@@ -52,10 +54,14 @@ type InstanceOptions struct {
 	MasterSocket      string
 	AdminSocket       string
 	AcmeSocket        string
+	MaxConfigSize     int
 	MaxOldConfigFiles int
 	Metrics           types.Metrics
+	OrphanFilesDirs   []string
+	OrphanFilesKeep   []string
 	ReloadQueue       utils.Queue
 	ReloadStrategy    string
+	ScalingSignalsMax int
 	SortEndpointsBy   string
 	StopCh            <-chan struct{}
 	TrackInstances    bool
@@ -70,6 +76,14 @@ type Instance interface {
 	ParseTemplates() error
 	Config() Config
 	CalcIdleMetric()
+	NewStatsSample() *StatsSample
+	CalcBackendStats(sample *StatsSample) []RetryBudgetExceeded
+	UnavailableHosts() []UnavailableHost
+	BlueGreenPauses() []BlueGreenPause
+	UnavailableBackends() []string
+	RejectedBackends() []RejectedBackend
+	LastReloadError() error
+	NextReloadCheck() time.Duration
 	AcmeUpdate()
 	HAProxyUpdate(timer *utils.Timer)
 	Reload(timer *utils.Timer)
@@ -78,34 +92,72 @@ type Instance interface {
 
 // CreateInstance ...
 func CreateInstance(logger types.Logger, options InstanceOptions) Instance {
-	return &instance{
-		waitProc: make(chan struct{}),
-		logger:   logger,
-		options:  &options,
-		conns:    newConnections(options.MasterSocket, options.AdminSocket),
-		metrics:  options.Metrics,
+	i := &instance{
+		waitProc:         make(chan struct{}),
+		logger:           logger,
+		options:          &options,
+		conns:            newConnections(options.MasterSocket, options.AdminSocket),
+		metrics:          options.Metrics,
+		aclLists:         map[string]int{},
+		changedBackends:  map[string]*hatypes.Backend{},
+		rejectedBackends: map[string]*hatypes.Backend{},
+		changedHosts:     map[string]*hatypes.Host{},
+		rejectedHosts:    map[string]*hatypes.Host{},
 		//
 		haproxyTmpl:     template.CreateConfig(),
 		mapsTmpl:        template.CreateConfig(),
 		modsecTmpl:      template.CreateConfig(),
+		spoeTmpl:        template.CreateConfig(),
+		auditTmpl:       template.CreateConfig(),
 		haResponseTmpl:  template.CreateConfig(),
 		luaResponseTmpl: template.CreateConfig(),
 	}
+	i.reloadFnc = i.reloadHAProxy
+	i.checkFnc = i.checkDir
+	i.reloadDefer = &reloadDefer{
+		logger: logger,
+		stats:  i.readBackendStats,
+		now:    time.Now,
+	}
+	return i
 }
 
 type instance struct {
-	up          bool
-	waitProc    chan struct{}
-	failedSince *time.Time
-	logger      types.Logger
-	options     *InstanceOptions
-	config      Config
-	conns       *connections
-	metrics     types.Metrics
+	up           bool
+	waitProc     chan struct{}
+	failedSince  *time.Time
+	logger       types.Logger
+	options      *InstanceOptions
+	config       Config
+	conns        *connections
+	metrics      types.Metrics
+	backendStats map[string]backendStat
+	overBudget   map[string]bool
+	aclLists     map[string]int
+	loadSignals  map[string]bool
+	loadDropped  int
+	serverStats  map[string]serverStat
+	//
+	blueGreenPauses []BlueGreenPause
+	//
+	reloadFnc        func() error
+	checkFnc         func(cfgDir string) error
+	lastGood         configSnapshot
+	changedBackends  map[string]*hatypes.Backend
+	rejectedBackends map[string]*hatypes.Backend
+	changedHosts     map[string]*hatypes.Host
+	rejectedHosts    map[string]*hatypes.Host
+	rejected         []RejectedBackend
+	reloadDefer      *reloadDefer
+	reloadErr        error
+	orphansListed    bool
+	warmUp           *warmUp
 	//
 	haproxyTmpl     *template.Config
 	mapsTmpl        *template.Config
 	modsecTmpl      *template.Config
+	spoeTmpl        *template.Config
+	auditTmpl       *template.Config
 	haResponseTmpl  *template.Config
 	luaResponseTmpl *template.Config
 }
@@ -168,6 +220,8 @@ func (i *instance) ParseTemplates() error {
 	i.haproxyTmpl.ClearTemplates()
 	i.mapsTmpl.ClearTemplates()
 	i.modsecTmpl.ClearTemplates()
+	i.spoeTmpl.ClearTemplates()
+	i.auditTmpl.ClearTemplates()
 	i.haResponseTmpl.ClearTemplates()
 	i.luaResponseTmpl.ClearTemplates()
 	templatesDir := i.options.RootFSPrefix + "/etc/templates"
@@ -180,6 +234,25 @@ func (i *instance) ParseTemplates() error {
 	); err != nil {
 		return err
 	}
+	if err := i.spoeTmpl.NewTemplate(
+		"spoe.tmpl",
+		templatesDir+"/spoe/spoe.tmpl",
+		i.options.HAProxyCfgDir+"/spoe-agents.conf",
+		0,
+		1024,
+	); err != nil {
+		return err
+	}
+	if err := i.auditTmpl.NewTemplate(
+		"audit.tmpl",
+		templatesDir+"/audit/audit.tmpl",
+		i.options.HAProxyCfgDir+"/spoe-audit.conf",
+		0,
+		1024,
+	); err != nil {
+		return err
+	}
+	i.haproxyTmpl.SetMaxSize(i.options.MaxConfigSize)
 	if err := i.haproxyTmpl.NewTemplate(
 		"haproxy.tmpl",
 		templatesDir+"/haproxy/haproxy.tmpl",
@@ -225,6 +298,7 @@ func (i *instance) Config() Config {
 			mapsTemplate: i.mapsTmpl,
 			mapsDir:      i.options.HAProxyMapsDir,
 			shardCount:   i.options.BackendShards,
+			partitioned:  i.options.PartitionBackends,
 		})
 		i.config = config
 	}
@@ -290,12 +364,17 @@ func (i *instance) HAProxyUpdate(timer *utils.Timer) {
 	defer i.config.Commit()
 	i.config.SyncConfig()
 	i.config.Shrink()
+	if i.config.Backends().Changed() {
+		// servers in maintenance due to the warm-up would conflict with dynamic updates
+		i.finishWarmUp()
+	}
 	if err := i.config.WriteTCPServicesMaps(); err != nil {
 		i.logger.Error("error building tcp services maps: %v", err)
 		i.metrics.IncUpdateNoop()
 		return
 	}
-	if err := i.config.WriteFrontendMaps(); err != nil {
+	i.trackChangedHosts()
+	if err := i.writeFrontendMaps(false); err != nil {
 		i.logger.Error("error building frontend maps: %v", err)
 		i.metrics.IncUpdateNoop()
 		return
@@ -320,12 +399,21 @@ func (i *instance) HAProxyUpdate(timer *utils.Timer) {
 		timer.Tick("shuffle_endpoints")
 	}
 	i.config.Backends().FillSourceIPs()
+	i.trackChangedBackends()
 	if !updated || updater.cmdCnt > 0 {
 		// only need to rewrite config files if:
 		//   - !updated           - there are changes that cannot be dynamically applied
 		//   - updater.cmdCnt > 0 - there are changes that was dynamically applied
 		err := i.writeConfig()
 		timer.Tick("write_config")
+		if i.options.MaxConfigSize > 0 {
+			// nothing is written if the limit is exceeded, so haproxy continues with the current config
+			var rejected int
+			if errors.Is(err, template.ErrMaxSize) {
+				rejected = 1
+			}
+			i.metrics.SetModelLimitRejected("config-size", rejected)
+		}
 		if err != nil {
 			i.logger.Error("error writing configuration: %v", err)
 			i.metrics.IncUpdateNoop()
@@ -333,12 +421,14 @@ func (i *instance) HAProxyUpdate(timer *utils.Timer) {
 		}
 	}
 	i.updateCertExpiring()
+	i.updateEndpointsMaintenance()
+	i.updateACLListsSpilled()
 	defer func() {
 		if i.failedSince != nil {
 			i.logger.Error("haproxy failed to reload, first occurrence at %s", i.failedSince.Format("2006-01-02 15:04:05.999999 -0700 MST"))
 		}
 	}()
-	if updated {
+	if updated && !i.reloadDefer.pending() {
 		if updater.cmdCnt > 0 {
 			if i.options.ValidateConfig {
 				var err error
@@ -356,6 +446,15 @@ func (i *instance) HAProxyUpdate(timer *utils.Timer) {
 		}
 		return
 	}
+	// a pending deferred reload is checked again even if nothing has changed
+	if i.reloadDefer.check(!updated && updater.urgent, i.config.Global().ReloadDefer, reloadSensitiveBackends(i.config.Backends())) {
+		if updater.cmdCnt > 0 {
+			i.metrics.IncUpdateDynamic()
+		} else {
+			i.metrics.IncUpdateNoop()
+		}
+		return
+	}
 	if i.options.ReloadQueue != nil {
 		i.options.ReloadQueue.Notify()
 		i.logger.InfoV(2, "haproxy reload enqueued")
@@ -366,6 +465,7 @@ func (i *instance) HAProxyUpdate(timer *utils.Timer) {
 
 func (i *instance) Reload(timer *utils.Timer) {
 	i.metrics.IncUpdateFull()
+	i.finishWarmUp()
 	if i.options.TrackInstances {
 		timeoutStopDur := i.config.Global().TimeoutStopDuration
 		closeSessDur := i.config.Global().CloseSessionsDuration
@@ -373,7 +473,7 @@ func (i *instance) Reload(timer *utils.Timer) {
 			i.logger.Error("error tracking instance: %v", err)
 		}
 	}
-	err := i.reloadHAProxy()
+	err := i.reloadFnc()
 	timer.Tick("reload_haproxy")
 	if err != nil {
 		i.logger.Error("error reloading server: %v", err)
@@ -381,10 +481,18 @@ func (i *instance) Reload(timer *utils.Timer) {
 		if i.options.TrackInstances {
 			i.conns.ReleaseLastInstance()
 		}
+		i.rollback(timer, err)
 		return
 	}
 	i.up = true
 	i.updateSuccessful(true)
+	i.removeSupersededACLLists()
+	i.saveLastGood()
+	i.startWarmUp()
+	if len(i.options.OrphanFilesDirs) > 0 {
+		i.collectOrphanFiles()
+		timer.Tick("collect_orphans")
+	}
 	message := "haproxy successfully reloaded"
 	if i.options.IsExternal {
 		message += " (external)"
@@ -399,6 +507,34 @@ func (i *instance) Reload(timer *utils.Timer) {
 	i.logger.Info(message)
 }
 
+// startWarmUp starts the warm-up of the backends configured with slow-start
+// and a warm-up window, see warmUp.
+func (i *instance) startWarmUp() {
+	if i.options.fake {
+		return
+	}
+	w := newWarmUp(i.logger, i.conns.WarmUp(), i.config.Backends().BuildSortedItems())
+	if w == nil {
+		return
+	}
+	if err := w.start(time.Now()); err != nil {
+		i.logger.Error("error starting warm-up of the backends: %v", err)
+		// some servers might be in maintenance
+		w.finish()
+		return
+	}
+	i.warmUp = w
+	go w.run()
+}
+
+// finishWarmUp enables all the servers of a running warm-up, if any.
+func (i *instance) finishWarmUp() {
+	if i.warmUp != nil {
+		i.warmUp.finish()
+		i.warmUp = nil
+	}
+}
+
 func (i *instance) Shutdown() {
 	if !i.up || i.options.IsExternal {
 		// lifecycle isn't controlled by HAProxy Ingress
@@ -460,6 +596,12 @@ func (i *instance) logChanged() {
 }
 
 func (i *instance) writeConfig() (err error) {
+	// backends and hosts rejected by haproxy are rendered with their
+	// last loaded state, until their configuration changes
+	restore := i.config.Backends().ReplaceBackends(i.rejectedBackends)
+	defer restore()
+	restoreHosts := i.config.Hosts().ReplaceHosts(i.rejectedHosts)
+	defer restoreHosts()
 	//
 	// modsec template execution
 	//
@@ -468,9 +610,27 @@ func (i *instance) writeConfig() (err error) {
 		return err
 	}
 	//
+	// spoe template execution
+	//
+	err = i.spoeTmpl.Write(i.config)
+	if err != nil {
+		return err
+	}
+	//
+	// audit template execution
+	//
+	err = i.auditTmpl.Write(i.config)
+	if err != nil {
+		return err
+	}
+	//
 	// custom responses template execution, raw HTTP HAProxy based
 	//
 	for _, response := range i.config.Global().CustomHTTPHAResponses {
+		if response.LogFormat != nil {
+			// rendered inline as a http-error in the defaults section
+			continue
+		}
 		err = i.haResponseTmpl.WriteOutput(
 			response, fmt.Sprintf("%s/errorfiles/%s.http", i.options.HAProxyCfgDir, response.Name))
 		if err != nil {
@@ -504,16 +664,26 @@ func (i *instance) writeConfig() (err error) {
 	}
 	// backend shards -- fills the .Global and .Backends attributes
 	if i.options.BackendShards > 0 {
-		shards := i.config.Backends().ChangedShards()
+		// backends changed since the last successful reload are rewritten
+		// as well, they might be reverted or restored by a rollback
+		backends := i.config.Backends()
+		for id, backend := range i.changedBackends {
+			if backend != nil {
+				backends.BackendChanged(backend)
+			}
+			if backend = backends.Items()[id]; backend != nil {
+				backends.BackendChanged(backend)
+			}
+		}
+		shards := backends.ChangedShards()
 		if len(shards) > 0 {
 			strshards := make([]string, len(shards))
 			for n, j := range shards {
 				str := fmt.Sprintf("%03d", j)
-				configFile := filepath.Join(i.options.HAProxyCfgDir, "haproxy5-backend"+str+".cfg")
 				if err = i.haproxyTmpl.WriteOutput(datatype{
 					Global:   i.config.Global(),
 					Backends: i.config.Backends().BuildSortedShard(j),
-				}, configFile); err != nil {
+				}, i.backendShardFile(j)); err != nil {
 					return err
 				}
 				strshards[n] = str
@@ -524,6 +694,10 @@ func (i *instance) writeConfig() (err error) {
 	return err
 }
 
+func (i *instance) backendShardFile(shard int) string {
+	return filepath.Join(i.options.HAProxyCfgDir, fmt.Sprintf("haproxy5-backend%03d.cfg", shard))
+}
+
 func (i *instance) updateSuccessful(success bool) {
 	if success {
 		i.failedSince = nil
@@ -561,6 +735,92 @@ func (i *instance) updateCertExpiring() {
 	}
 }
 
+func (i *instance) updateEndpointsMaintenance() {
+	backendsAdd := i.config.Backends().ItemsAdd()
+	for id := range i.config.Backends().ItemsDel() {
+		if _, found := backendsAdd[id]; !found {
+			i.metrics.SetEndpointsMaintenance(id, 0)
+		}
+	}
+	for id, backend := range backendsAdd {
+		var count int
+		for _, ep := range backend.Endpoints {
+			if ep.Maintenance {
+				count++
+			}
+		}
+		i.metrics.SetEndpointsMaintenance(id, count)
+	}
+}
+
+// updateACLListsSpilled counts the ACL lists written to a file, see
+// syncACLLists. Backends are counted only if changed, and aggregated
+// in the metric, so it doesn't have a label per backend.
+func (i *instance) updateACLListsSpilled() {
+	for id := range i.config.Backends().ItemsDel() {
+		delete(i.aclLists, id)
+	}
+	for id, backend := range i.config.Backends().ItemsAdd() {
+		if count := len(backend.ACLLists()); count > 0 {
+			i.aclLists[id] = count
+		}
+	}
+	var backendCount int
+	for _, count := range i.aclLists {
+		backendCount += count
+	}
+	i.metrics.SetACLListsSpilled("backend", backendCount)
+	var classCount int
+	for _, class := range i.config.Global().TrafficClasses {
+		classCount += len(class.ACLLists())
+	}
+	i.metrics.SetACLListsSpilled("traffic-class", classCount)
+}
+
+// removeSupersededACLLists removes the ACL list files not referenced by the
+// loaded configuration. List files are named after their content, so a
+// changed list is written to a new file and the former one is superseded.
+func (i *instance) removeSupersededACLLists() {
+	referenced := map[string]bool{}
+	addLists := func(lists map[string][]string) {
+		for file := range lists {
+			referenced[filepath.Clean(file)] = true
+		}
+	}
+	backends := i.config.Backends().Items()
+	for id := range i.aclLists {
+		if backend, found := backends[id]; found {
+			addLists(backend.ACLLists())
+		}
+	}
+	// rejected backends are rendered with their last loaded state
+	for _, backend := range i.rejectedBackends {
+		if backend != nil {
+			addLists(backend.ACLLists())
+		}
+	}
+	for _, class := range i.config.Global().TrafficClasses {
+		addLists(class.ACLLists())
+	}
+	for _, pattern := range []string{"_back_*_src_*.list", "_front_class_*.list"} {
+		files, err := filepath.Glob(filepath.Join(i.options.HAProxyMapsDir, pattern))
+		if err != nil {
+			i.logger.Warn("error listing acl list files: %v", err)
+			continue
+		}
+		for _, file := range files {
+			if referenced[file] {
+				continue
+			}
+			if err := os.Remove(file); err != nil {
+				i.logger.Warn("error removing superseded acl list file: %v", err)
+				continue
+			}
+			i.logger.InfoV(2, "removed superseded acl list file: %s", file)
+		}
+	}
+}
+
 func (i *instance) check() error {
 	if i.options.fake {
 		i.logger.Info("(test) check was skipped")
@@ -569,12 +829,17 @@ func (i *instance) check() error {
 	if i.options.IsExternal {
 		// TODO check config on remote haproxy
 	} else {
-		// TODO Move all magic strings to a single place
-		out, err := exec.Command("haproxy", "-c", "-f", i.options.HAProxyCfgDir).CombinedOutput()
-		outstr := string(out)
-		if err != nil {
-			return fmt.Errorf(outstr)
-		}
+		return i.checkDir(i.options.HAProxyCfgDir)
+	}
+	return nil
+}
+
+func (i *instance) checkDir(cfgDir string) error {
+	// TODO Move all magic strings to a single place
+	out, err := exec.Command("haproxy", "-c", "-f", cfgDir).CombinedOutput()
+	outstr := string(out)
+	if err != nil {
+		return fmt.Errorf(outstr)
 	}
 	return nil
 }
@@ -594,7 +859,7 @@ func (i *instance) reloadHAProxy() error {
 
 func (i *instance) reloadEmbeddedDaemon() error {
 	state := "0"
-	if i.config.Global().LoadServerState {
+	if i.config.Global().ServerStateFile {
 		state = "1"
 	}
 	// TODO Move all magic strings to a single place
@@ -705,7 +970,7 @@ func (i *instance) waitMaster() error {
 }
 
 func (i *instance) reloadWorker() error {
-	if i.config.Global().LoadServerState {
+	if i.config.Global().ServerStateFile {
 		if err := i.persistServersState(); err != nil {
 			i.logger.Warn("failed to persist servers state before worker reload: %w", err)
 		}
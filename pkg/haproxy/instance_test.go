@@ -22,11 +22,14 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kylelemons/godebug/diff"
 
+	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
 	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
@@ -71,6 +74,37 @@ func TestBackends(t *testing.T) {
 			expected: `
     cookie Ingress prefix dynamic
     dynamic-cookie-key "Ingress"`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Dynamic = true
+				b.Cookie.DynamicKey = "gslb-shared-key"
+			},
+			expected: `
+    cookie Ingress insert dynamic
+    dynamic-cookie-key "gslb-shared-key"`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Failover = "error"
+			},
+			expected: `
+    cookie Ingress insert
+    option persist
+    no option redispatch`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Failover = "redispatch"
+			},
+			expected: `
+    cookie Ingress insert`,
 		},
 		{
 			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
@@ -100,10 +134,81 @@ func TestBackends(t *testing.T) {
 				b.Cookie.Name = "Ingress"
 				b.Cookie.Strategy = "insert"
 				b.Cookie.Keywords = "indirect nocache httponly"
-				b.Cookie.SameSite = true
+				b.Cookie.SameSite = "None"
+				b.Cookie.Secure = true
 			},
 			expected: `
     cookie Ingress insert attr SameSite=None secure indirect nocache httponly`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "rewrite"
+				b.Cookie.SameSite = "Lax"
+				b.Cookie.HTTPOnly = true
+			},
+			expected: `
+    cookie Ingress rewrite attr SameSite=Lax httponly`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Keywords = "indirect nocache httponly"
+				b.Cookie.MaxIdle = 30 * time.Minute
+			},
+			expected: `
+    cookie Ingress insert indirect nocache httponly maxidle 1800s`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Keywords = "httponly"
+				b.Cookie.MaxIdle = time.Hour
+				b.Cookie.MaxLife = 8 * time.Hour
+			},
+			expected: `
+    cookie Ingress insert httponly indirect nocache maxidle 3600s maxlife 28800s`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.BalanceAlgorithm = "roundrobin"
+				b.Cookie.Name = "Ingress"
+				b.Cookie.Strategy = "insert"
+				b.Cookie.Keywords = "indirect nocache httponly"
+				b.Cookie.Dynamic = true
+				b.Cookie.URLParam = "jsessionid"
+			},
+			expected: `
+    balance url_param jsessionid
+    cookie Ingress insert indirect nocache httponly dynamic
+    dynamic-cookie-key "Ingress"`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.StickAffinity = hatypes.BackendStickAffinity{Size: 204800, Expire: "30m"}
+			},
+			expected: `
+    stick-table type ip size 204800 expire 30m
+    stick on src`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				c.global.Bind.IPv6 = true
+				b.StickAffinity = hatypes.BackendStickAffinity{Size: 1024, Expire: "8h"}
+			},
+			expected: `
+    stick-table type ipv6 size 1024 expire 8h
+    stick on src`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.StickAffinity = hatypes.BackendStickAffinity{Header: "X-Tenant-Id", Size: 204800, Expire: "30m"}
+			},
+			expected: `
+    stick-table type string size 204800 expire 30m
+    stick on req.hdr(X-Tenant-Id)`,
 		},
 		{
 			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
@@ -394,6 +499,70 @@ d1.local#/ path01`,
 				"_back_d1_app_8080_idpath__begin.map": `
 d1.local#/uri path03
 d1.local#/path path02
+d1.local#/ path01`,
+			},
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				for _, path := range b.Paths {
+					path.CacheControl.CacheControl = "no-cache"
+				}
+			},
+			path: []string{"/", "/app"},
+			expected: `
+    http-response set-header Cache-Control "no-cache"`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/")[0].Link).CacheControl = hatypes.CacheControl{
+					CacheControl: "no-cache",
+				}
+				b.FindBackendPath(h.FindPath("/static")[0].Link).CacheControl = hatypes.CacheControl{
+					CacheControl:     "public, max-age=3600",
+					SurrogateControl: "max-age=86400",
+					IfMissing:        true,
+				}
+				b.FindBackendPath(h.FindPath("/assets")[0].Link).CacheControl = hatypes.CacheControl{
+					CacheControl:     "public, max-age=3600",
+					SurrogateControl: "max-age=86400",
+					IfMissing:        true,
+				}
+			},
+			path: []string{"/", "/static", "/assets", "/api"},
+			expected: `
+    # path01 = d1.local/
+    # path04 = d1.local/api
+    # path03 = d1.local/assets
+    # path02 = d1.local/static
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-response set-header Cache-Control "no-cache" if { var(txn.pathID) -m str path01 }
+    http-response set-header Cache-Control "public, max-age=3600" if !{ res.hdr(Cache-Control) -m found } { var(txn.pathID) -m str path02 path03 }
+    http-response set-header Surrogate-Control "max-age=86400" if !{ res.hdr(Surrogate-Control) -m found } { var(txn.pathID) -m str path02 path03 }`,
+			expCheck: map[string]string{
+				"_back_d1_app_8080_idpath__begin.map": `
+d1.local#/static path02
+d1.local#/assets path03
+d1.local#/api path04
+d1.local#/ path01`,
+			},
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/old")[0].Link).Sunset = hatypes.PathSunset{
+					Deprecation: "@1790000000",
+					Sunset:      "Sat, 21 Sep 2026 14:13:20 GMT",
+				}
+			},
+			path: []string{"/", "/old"},
+			expected: `
+    # path01 = d1.local/
+    # path02 = d1.local/old
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-response set-header Deprecation "@1790000000" if { var(txn.pathID) -m str path02 }
+    http-response set-header Sunset "Sat, 21 Sep 2026 14:13:20 GMT" if { var(txn.pathID) -m str path02 }`,
+			expCheck: map[string]string{
+				"_back_d1_app_8080_idpath__begin.map": `
+d1.local#/old path02
 d1.local#/ path01`,
 			},
 		},
@@ -423,6 +592,38 @@ d1.local#/ path01`,
     http-request replace-path ^/app(.*)$       /other/\1
     http-request replace-path ^/app/sub(.*)$       /other/\1`,
 		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				path := b.FindBackendPath(h.FindPath("/app")[0].Link)
+				path.RewriteURL = "/other"
+				path.ProxyRedirect = hatypes.ProxyRedirect{From: "/other", To: "/app"}
+			},
+			path: []string{"/app"},
+			expected: `
+    http-request replace-path ^/app(.*)$       /other\1
+    http-response replace-header Location ^/other(.*)$ /app\1`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/path1")[0].Link).ProxyRedirect = hatypes.ProxyRedirect{From: "/", To: "/path1/"}
+				b.FindBackendPath(h.FindPath("/path2")[0].Link).ProxyRedirect = hatypes.ProxyRedirect{From: "/sub2/", To: "/path2/", Host: true}
+			},
+			path: []string{"/path1", "/path2", "/path3"},
+			expected: `
+    # path01 = d1.local/path1
+    # path02 = d1.local/path2
+    # path03 = d1.local/path3
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-response replace-header Location ^/(.*)$ /path1/\1 if { var(txn.pathID) -m str path01 }
+    http-response replace-header Location ^/sub2/(.*)$ /path2/\1 if { var(txn.pathID) -m str path02 }
+    http-response replace-header Location ^([a-z]+://[^/]+)/sub2/(.*)$ \1/path2/\2 if { var(txn.pathID) -m str path02 }`,
+			expCheck: map[string]string{
+				"_back_d1_app_8080_idpath__begin.map": `
+d1.local#/path3 path03
+d1.local#/path2 path02
+d1.local#/path1 path01`,
+			},
+		},
 		{
 			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
 				b.FindBackendPath(h.FindPath("/path1")[0].Link).RewriteURL = "/sub1"
@@ -468,6 +669,49 @@ d1.local#/path1 path01`,
     # path01 = d1.local/app
     # path02 = d1.local/path
     http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request redirect scheme https code 301 if !https-request { var(txn.pathID) -m str path01 }`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/report")[0].Link).TimeoutServer = "5m"
+			},
+			path: []string{"/app", "/report"},
+			expected: `
+    # path01 = d1.local/app
+    # path02 = d1.local/report
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-timeout server 5m if { var(txn.pathID) -m str path02 }`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/app")[0].Link).SSLRedirect = true
+				b.FindBackendPath(h.FindPath("/path")[0].Link).SSLRedirect = true
+				b.FindBackendPath(h.FindPath("/path")[0].Link).SSLRedirectPort = 8443
+			},
+			path: []string{"/app", "/path"},
+			expected: `
+    acl https-request ssl_fc
+    # path01 = d1.local/app
+    # path02 = d1.local/path
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-var(txn.sslredirport) int(8443) if { var(txn.pathID) -m str path02 }
+    http-request redirect location https://%[var(req.host)]:%[var(txn.sslredirport)]%[capture.req.uri] if !https-request { var(txn.sslredirport) -m found }
+    http-request redirect scheme https if !https-request`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.FindBackendPath(h.FindPath("/app")[0].Link).SSLRedirect = true
+				b.FindBackendPath(h.FindPath("/app")[0].Link).SSLRedirectPort = 8443
+				c.global.SSL.RedirectCode = 301
+			},
+			path: []string{"/app", "/path"},
+			expected: `
+    acl https-request ssl_fc
+    # path01 = d1.local/app
+    # path02 = d1.local/path
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request set-var(txn.sslredirport) int(8443) if { var(txn.pathID) -m str path01 }
+    http-request redirect location https://%[var(req.host)]:%[var(txn.sslredirport)]%[capture.req.uri] code 301 if !https-request { var(txn.sslredirport) -m found } { var(txn.pathID) -m str path01 }
     http-request redirect scheme https code 301 if !https-request { var(txn.pathID) -m str path01 }`,
 		},
 		{
@@ -587,6 +831,38 @@ d1.local#/app1 path01`,
 d1.local#/app4 path04
 d1.local#/app3 path03
 d1.local#/app2 path02
+d1.local#/app1 path01`,
+			},
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.AccessDebug = true
+				b.Limit.RPS = 20
+				allowed := &b.FindBackendPath(h.FindPath("/app1")[0].Link).AllowedIPHTTP
+				allowed.ID = "allowlist-source-range-0a1b2c3d"
+				allowed.Rule = []string{"10.0.0.0/8"}
+				denied := &b.FindBackendPath(h.FindPath("/app2")[0].Link).DeniedIPHTTP
+				denied.ID = "denylist-source-range-4e5f6a7b"
+				denied.Rule = []string{"192.168.95.0/24"}
+			},
+			path: []string{"/app1", "/app2"},
+			expected: `
+    stick-table type ip size 200k expire 5m store conn_cur,conn_rate(1s)
+    # path01 = d1.local/app1
+    # path02 = d1.local/app2
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request track-sc1 src
+    http-request deny deny_status 429 content-type text/plain string "access denied, rule limit-rps" hdr X-Access-Decision "deny; rule=limit-rps" if { sc1_conn_rate gt 20 }
+    acl allow_rule_src0 src 10.0.0.0/8
+    http-request deny content-type text/plain string "access denied, rule allowlist-source-range-0a1b2c3d" hdr X-Access-Decision "deny; rule=allowlist-source-range-0a1b2c3d" if { var(txn.pathID) -m str path01 } !allow_rule_src0
+    http-request set-var(txn.access_rule) str(allowlist-source-range-0a1b2c3d) if { var(txn.pathID) -m str path01 }
+    acl deny_rule_src1 src 192.168.95.0/24
+    http-request deny content-type text/plain string "access denied, rule denylist-source-range-4e5f6a7b" hdr X-Access-Decision "deny; rule=denylist-source-range-4e5f6a7b" if { var(txn.pathID) -m str path02 } deny_rule_src1
+    http-response set-header X-Access-Decision "allow; rule=%[var(txn.access_rule)]" if { var(txn.access_rule) -m found }
+    http-response set-header X-Access-Decision allow unless { var(txn.access_rule) -m found }`,
+			expCheck: map[string]string{
+				"_back_d1_app_8080_idpath__begin.map": `
+d1.local#/app2 path02
 d1.local#/app1 path01`,
 			},
 		},
@@ -748,6 +1024,29 @@ d1.local#/ path01`,
     http-request lua.auth-intercept _auth_4001 /oauth2/auth HEAD '*' '-' '-' if { var(txn.pathID) -m str path01 }
     http-request redirect location http://auth.local/login if !{ var(txn.auth_response_successful) -m bool } { var(txn.pathID) -m str path01 }
     http-request set-header X-Auth-Request-Email %[var(req.auth_response_header.x_auth_request_email)] if { var(req.auth_response_header.x_auth_request_email) -m found } { var(txn.pathID) -m str path01 }`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				auth := &b.FindBackendPath(h.FindPath("/app1")[0].Link).AuthExternal
+				auth.AuthBackendName = "_auth_4001"
+				auth.AuthPath = "/oauth2/auth"
+				auth.BearerTokenVar = "req.auth_response_header.x_auth_request_access_token"
+				auth.HeadersRequest = []string{"*"}
+				auth.HeadersSucceed = []string{"-"}
+				auth.HeadersFail = []string{"-"}
+				auth.HeadersVars = map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"}
+				auth.Method = "HEAD"
+				auth.RedirectOnFail = "http://auth.local/login"
+			},
+			path: []string{"/app1", "/app2"},
+			expected: `
+    # path01 = d1.local/app1
+    # path02 = d1.local/app2
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request lua.auth-intercept _auth_4001 /oauth2/auth HEAD '*' '-' '-' if { var(txn.pathID) -m str path01 }
+    http-request redirect location http://auth.local/login if !{ var(txn.auth_response_successful) -m bool } { var(txn.pathID) -m str path01 }
+    http-request set-header X-Auth-Request-Email %[var(req.auth_response_header.x_auth_request_email)] if { var(req.auth_response_header.x_auth_request_email) -m found } { var(txn.pathID) -m str path01 }
+    http-request set-header Authorization "Bearer %[var(req.auth_response_header.x_auth_request_access_token)]" if { var(req.auth_response_header.x_auth_request_access_token),length gt 0 } { var(txn.pathID) -m str path01 }`,
 		},
 		{
 			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
@@ -911,6 +1210,20 @@ d1.local#/ path01`,
     stick-table type ip size 200k expire 5m store conn_cur,conn_rate(1s)
     http-request track-sc1 src
     http-request deny deny_status 429 if { sc1_conn_cur gt 200 }`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.AllDownResponse.StatusCode = 503
+			},
+			expected: `
+    http-request return status 503 default-errorfiles if { nbsrv eq 0 }`,
+		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.AllDownResponse.Location = "https://status.local/app"
+			},
+			expected: `
+    http-request redirect location https://status.local/app code 302 if { nbsrv eq 0 }`,
 		},
 		{
 			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
@@ -932,6 +1245,13 @@ d1.local#/ path01`,
 			},
 			srvsuffix: "send-proxy-v2",
 		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Server.SendProxy = "send-proxy"
+				b.Server.SlowStart = "30s"
+			},
+			srvsuffix: "send-proxy slowstart 30s",
+		},
 		{
 			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
 				b.BlueGreen.CookieName = "ServerName"
@@ -1015,6 +1335,14 @@ d1.local#/ path01`,
 			},
 			srvsuffix: "id 1234567",
 		},
+		{
+			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
+				b.Endpoints[0].Maintenance = true
+			},
+			skipSrv: true,
+			expected: `
+    server s1 172.17.0.11:8080 disabled weight 100`,
+		},
 		{
 			doconfig: func(c *config, h *hatypes.Host, b *hatypes.Backend) {
 				link1 := hatypes.CreatePathLink("/app1", hatypes.MatchPrefix).
@@ -1964,49 +2292,349 @@ frontend _front_https
 	}
 }
 
-func TestInstanceTCPServices(t *testing.T) {
-	c := setup(t)
-	defer c.teardown()
-
-	var h *hatypes.Host
-	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	b.ModeTCP = true
-	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-	h = c.config.Hosts().AcquireHost("d1.local")
-	h.AddPath(b, "/", hatypes.MatchBegin)
-
-	b2 := c.config.Backends().AcquireBackend("d2", "app", "8080")
-	b2.ModeTCP = true
-	b2.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
-	b3 := c.config.Backends().AcquireBackend("d3", "app", "8080")
-	b3.ModeTCP = true
-	b3.Endpoints = []*hatypes.Endpoint{endpointS31, endpointS32}
-
-	services := []struct {
-		port      int
-		hostname  string
-		backend   hatypes.BackendID
-		proxyProt bool
-		tls       hatypes.TLSConfig
-		custom    []string
-	}{
-		{
-			port: 7000,
-		},
-		{
-			port:    7001,
-			backend: b.BackendID(),
-		},
-		{
-			port:      7002,
-			backend:   b.BackendID(),
-			proxyProt: true,
-		},
-		{
-			port:      7003,
-			backend:   b.BackendID(),
-			proxyProt: true,
-			tls: hatypes.TLSConfig{
+func TestInstanceCookieAutoSecure(t *testing.T) {
+	var (
+		frontDirect = `
+<<frontends-default>>`
+		frontUseProto = `
+frontend _front_http
+    mode http
+    bind :80
+    bind :8000 id 11
+    acl fronting-proxy so_id 11
+    <<set-req-base>>
+    http-request set-header X-Forwarded-Proto http if !fronting-proxy
+    http-request del-header X-SSL-Client-CN if !fronting-proxy
+    http-request del-header X-SSL-Client-DN if !fronting-proxy
+    http-request del-header X-SSL-Client-SHA1 if !fronting-proxy
+    http-request del-header X-SSL-Client-SHA2 if !fronting-proxy
+    http-request del-header X-SSL-Client-Cert if !fronting-proxy
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404`
+		frontIgnoreProto = `
+frontend _front_http
+    mode http
+    bind :80
+    bind :8000 id 11
+    <<set-req-base>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404`
+		authExternal = `
+    http-request lua.auth-intercept d1_oauth_4180 /oauth2/auth HEAD '*' '-' '-' if !{ path_beg /oauth2/ }
+    http-request redirect location /oauth2/start?rd=%[path] if !{ var(txn.auth_response_successful) -m bool } !{ path_beg /oauth2/ }`
+	)
+	testCases := []struct {
+		frontingBind string
+		useProto     bool
+		oauth        bool
+		expBackends  string
+		expFronts    string
+	}{
+		// 0
+		{
+			expBackends: `
+backend d1_app_8080
+    mode http
+    cookie INGRESSCOOKIE insert indirect nocache httponly
+    server s1 172.17.0.11:8080 weight 100`,
+			expFronts: frontDirect,
+		},
+		// 1
+		{
+			frontingBind: ":8000",
+			useProto:     true,
+			expBackends: `
+backend d1_app_8080
+    mode http
+    acl fronting-proxy so_id 11
+    acl https-request ssl_fc
+    acl https-request var(txn.proto) -m str https
+    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }
+    cookie INGRESSCOOKIE insert indirect nocache httponly
+    http-after-response replace-header Set-Cookie ^(INGRESSCOOKIE=.*)$ "\1; Secure; SameSite=None" if https-request
+    server s1 172.17.0.11:8080 weight 100`,
+			expFronts: frontUseProto,
+		},
+		// 2
+		{
+			frontingBind: ":8000",
+			useProto:     false,
+			expBackends: `
+backend d1_app_8080
+    mode http
+    cookie INGRESSCOOKIE insert indirect nocache httponly
+    http-after-response replace-header Set-Cookie ^(INGRESSCOOKIE=.*)$ "\1; Secure; SameSite=None"
+    server s1 172.17.0.11:8080 weight 100`,
+			expFronts: frontIgnoreProto,
+		},
+		// 3
+		{
+			oauth: true,
+			expBackends: `
+backend d1_app_8080
+    mode http` + authExternal + `
+    server s1 172.17.0.11:8080 weight 100
+backend d1_oauth_4180
+    mode http
+    server s1 172.17.0.12:4180 weight 100`,
+			expFronts: frontDirect,
+		},
+		// 4
+		{
+			frontingBind: ":8000",
+			useProto:     true,
+			oauth:        true,
+			expBackends: `
+backend d1_app_8080
+    mode http
+    acl fronting-proxy so_id 11
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }` + authExternal + `
+    server s1 172.17.0.11:8080 weight 100
+backend d1_oauth_4180
+    mode http
+    acl fronting-proxy so_id 11
+    acl https-request ssl_fc
+    acl https-request var(txn.proto) -m str https
+    http-request set-var(txn.proto) hdr(X-Forwarded-Proto)
+    http-request redirect scheme https if fronting-proxy !{ hdr(X-Forwarded-Proto) https }
+    http-after-response replace-header Set-Cookie (?i)^((?!.*;\s*samesite=).*)$ "\1; Secure; SameSite=None" if https-request
+    server s1 172.17.0.12:4180 weight 100`,
+			expFronts: frontUseProto,
+		},
+		// 5
+		{
+			frontingBind: ":8000",
+			useProto:     false,
+			oauth:        true,
+			expBackends: `
+backend d1_app_8080
+    mode http` + authExternal + `
+    server s1 172.17.0.11:8080 weight 100
+backend d1_oauth_4180
+    mode http
+    http-after-response replace-header Set-Cookie (?i)^((?!.*;\s*samesite=).*)$ "\1; Secure; SameSite=None"
+    server s1 172.17.0.12:4180 weight 100`,
+			expFronts: frontIgnoreProto,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+
+		b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h := c.config.Hosts().AcquireHost("d1.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		if test.oauth {
+			oauth := c.config.Backends().AcquireBackend("d1", "oauth", "4180")
+			oauth.Endpoints = []*hatypes.Endpoint{{Name: "s1", IP: "172.17.0.12", Port: 4180, Enabled: true, Weight: 100}}
+			h.AddPath(oauth, "/oauth2", hatypes.MatchBegin)
+			b.FindBackendPath(h.FindPath("/")[0].Link).AuthExternal = hatypes.AuthExternal{
+				AllowedPath:     "/oauth2/",
+				AuthBackendName: oauth.ID,
+				AuthPath:        "/oauth2/auth",
+				HeadersFail:     []string{"-"},
+				HeadersRequest:  []string{"*"},
+				HeadersSucceed:  []string{"-"},
+				Method:          "HEAD",
+				RedirectOnFail:  "/oauth2/start?rd=%[path]",
+				SecureCookies:   true,
+			}
+		} else {
+			b.Cookie = hatypes.Cookie{
+				Name:       "INGRESSCOOKIE",
+				Strategy:   "insert",
+				Keywords:   "indirect nocache httponly",
+				AutoSecure: true,
+			}
+		}
+		c.config.Global().Bind.FrontingBind = test.frontingBind
+		c.config.Global().Bind.FrontingSockID = 11
+		c.config.Global().Bind.FrontingUseProto = test.useProto
+
+		c.Update()
+		c.checkConfig(`
+<<global>>
+<<defaults>>` + test.expBackends + `
+<<backends-default>>` + test.expFronts + `
+<<support>>
+`)
+		if test.oauth && !c.config.Backends().FindBackend("d1", "oauth", "4180").AuthCookieSecure {
+			t.Errorf("expected auth backend with secure cookies on %d", i)
+		}
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestInstanceOAuthCookie(t *testing.T) {
+	testCases := []struct {
+		frontingBind string
+		expBackend   string
+	}{
+		// 0
+		{
+			expBackend: `
+backend d1_oauth_4180
+    mode http
+    acl https-request ssl_fc
+    http-after-response replace-header Set-Cookie (?i)^((?!.*;\s*secure\s*(;|$)).*)$ "\1; Secure" if https-request
+    http-request set-var(txn.authcookiedomain) str(corp.example.com) if { var(req.host) -m str corp.example.com } || { var(req.host) -m end .corp.example.com }
+    http-request set-var(txn.authcookiedomain) str(example.org) if { var(req.host) -m str example.org } || { var(req.host) -m end .example.org }
+    http-request del-header X-Auth-Request-Redirect
+    http-after-response replace-header Set-Cookie (?i)^((?!.*;\s*domain=).*)$ "\1; Domain=%[var(txn.authcookiedomain)]" if { var(txn.authcookiedomain) -m found }
+    server s1 172.17.0.12:4180 weight 100`,
+		},
+		// 1
+		{
+			frontingBind: ":8000",
+			expBackend: `
+backend d1_oauth_4180
+    mode http
+    http-after-response replace-header Set-Cookie (?i)^((?!.*;\s*secure\s*(;|$)).*)$ "\1; Secure"
+    http-request set-var(txn.authcookiedomain) str(corp.example.com) if { var(req.host) -m str corp.example.com } || { var(req.host) -m end .corp.example.com }
+    http-request set-var(txn.authcookiedomain) str(example.org) if { var(req.host) -m str example.org } || { var(req.host) -m end .example.org }
+    http-request del-header X-Auth-Request-Redirect
+    http-after-response replace-header Set-Cookie (?i)^((?!.*;\s*domain=).*)$ "\1; Domain=%[var(txn.authcookiedomain)]" if { var(txn.authcookiedomain) -m found }
+    server s1 172.17.0.12:4180 weight 100`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+
+		oauth := c.config.Backends().AcquireBackend("d1", "oauth", "4180")
+		oauth.Endpoints = []*hatypes.Endpoint{{Name: "s1", IP: "172.17.0.12", Port: 4180, Enabled: true, Weight: 100}}
+		for _, hostname := range []string{"app1.corp.example.com", "app2.corp.example.com", "www.example.org"} {
+			b := c.config.Backends().AcquireBackend("d1", strings.Split(hostname, ".")[0], "8080")
+			b.Endpoints = []*hatypes.Endpoint{endpointS1}
+			h := c.config.Hosts().AcquireHost(hostname)
+			h.AddPath(b, "/", hatypes.MatchBegin)
+			h.AddPath(oauth, "/oauth2", hatypes.MatchBegin)
+			auth := &b.FindBackendPath(h.FindPath("/")[0].Link).AuthExternal
+			auth.AuthBackendName = oauth.ID
+			auth.CookieDomain = hostname[strings.Index(hostname, ".")+1:]
+			auth.CookieSetSecure = hostname != "www.example.org"
+		}
+		c.config.Global().Bind.FrontingBind = test.frontingBind
+		c.config.Global().Bind.FrontingSockID = 11
+
+		c.Update()
+		c.containsText(fmt.Sprintf("haproxy.cfg on %d", i), c.readConfig(c.tempdir+"/haproxy.cfg"), test.expBackend)
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestInstanceAuthProxyHeaders(t *testing.T) {
+	testCases := []struct {
+		frontingBind string
+		forwardFor   string
+		expBackend   string
+	}{
+		// 0
+		{
+			expBackend: `
+    http-request set-var(txn.auth_proxy_headers) str(X-Forwarded-For,X-Forwarded-Proto,X-Original-URL) if !{ path_beg /oauth2/ }
+    http-request lua.auth-intercept d1_oauth_4180 /oauth2/auth HEAD '*' '-' '-' if !{ path_beg /oauth2/ }`,
+		},
+		// 1
+		{
+			frontingBind: ":8000",
+			expBackend: `
+    http-request set-var(txn.auth_proxy_headers) str(X-Forwarded-For,X-Forwarded-Proto,X-Original-URL) if !{ path_beg /oauth2/ }
+    http-request set-var(txn.auth_proxy_trusted) bool(true) if fronting-proxy !{ ssl_fc } !{ path_beg /oauth2/ }
+    http-request lua.auth-intercept d1_oauth_4180 /oauth2/auth HEAD '*' '-' '-' if !{ path_beg /oauth2/ }`,
+		},
+		// 2
+		{
+			forwardFor: "update",
+			expBackend: `
+    http-request set-var(txn.auth_proxy_headers) str(X-Forwarded-For,X-Forwarded-Proto,X-Original-URL) if !{ path_beg /oauth2/ }
+    http-request set-var(txn.auth_proxy_trusted) bool(true) if !{ path_beg /oauth2/ }
+    http-request lua.auth-intercept d1_oauth_4180 /oauth2/auth HEAD '*' '-' '-' if !{ path_beg /oauth2/ }`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+
+		oauth := c.config.Backends().AcquireBackend("d1", "oauth", "4180")
+		oauth.Endpoints = []*hatypes.Endpoint{endpointS1}
+		b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h := c.config.Hosts().AcquireHost("d1.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		h.AddPath(oauth, "/oauth2", hatypes.MatchBegin)
+		auth := &b.FindBackendPath(h.FindPath("/")[0].Link).AuthExternal
+		auth.AllowedPath = "/oauth2/"
+		auth.AuthBackendName = oauth.ID
+		auth.AuthPath = "/oauth2/auth"
+		auth.HeadersRequest = []string{"*"}
+		auth.HeadersSucceed = []string{"-"}
+		auth.HeadersFail = []string{"-"}
+		auth.Method = "HEAD"
+		auth.ProxyHeaders = []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Original-URL"}
+		c.config.Global().Bind.FrontingBind = test.frontingBind
+		c.config.Global().Bind.FrontingSockID = 11
+		c.config.Global().Bind.FrontingUseProto = test.frontingBind != ""
+		c.config.Global().ForwardFor = test.forwardFor
+
+		c.Update()
+		c.containsText(fmt.Sprintf("haproxy.cfg on %d", i), c.readConfig(c.tempdir+"/haproxy.cfg"), test.expBackend)
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestInstanceTCPServices(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.ModeTCP = true
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b2 := c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b2.ModeTCP = true
+	b2.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
+	b3 := c.config.Backends().AcquireBackend("d3", "app", "8080")
+	b3.ModeTCP = true
+	b3.Endpoints = []*hatypes.Endpoint{endpointS31, endpointS32}
+
+	services := []struct {
+		port      int
+		hostname  string
+		backend   hatypes.BackendID
+		proxyProt bool
+		tls       hatypes.TLSConfig
+		alpn      []*hatypes.TCPServiceALPNRoute
+		custom    []string
+	}{
+		{
+			port: 7000,
+		},
+		{
+			port:    7001,
+			backend: b.BackendID(),
+		},
+		{
+			port:      7002,
+			backend:   b.BackendID(),
+			proxyProt: true,
+		},
+		{
+			port:      7003,
+			backend:   b.BackendID(),
+			proxyProt: true,
+			tls: hatypes.TLSConfig{
 				TLSFilename: "/ssl/7003.pem",
 			},
 		},
@@ -2105,6 +2733,18 @@ func TestInstanceTCPServices(t *testing.T) {
 			backend: b.BackendID(),
 			custom:  []string{"## custom for TCP 7014", "## multi line"},
 		},
+		{
+			port:    7015,
+			backend: b.BackendID(),
+			tls: hatypes.TLSConfig{
+				ALPN:        "xmpp-client,h2",
+				TLSFilename: "/ssl/7015.pem",
+			},
+			alpn: []*hatypes.TCPServiceALPNRoute{
+				{Protocol: "xmpp-client", Backend: b2.BackendID()},
+				{Protocol: "h2", Backend: b3.BackendID()},
+			},
+		},
 	}
 
 	for _, svc := range services {
@@ -2116,6 +2756,7 @@ func TestInstanceTCPServices(t *testing.T) {
 		p.ProxyProt = svc.proxyProt
 		p.TLS = svc.tls
 		p.CustomConfig = svc.custom
+		p.ALPNRoutes = svc.alpn
 		h.Backend = svc.backend
 	}
 
@@ -2203,6 +2844,12 @@ frontend _front_tcp_7014
     ## custom for TCP 7014
     ## multi line
     default_backend d1_app_8080
+frontend _front_tcp_7015
+    bind :7015 ssl crt /ssl/7015.pem alpn xmpp-client,h2
+    mode tcp
+    use_backend d2_app_8080 if { ssl_fc_alpn -m str xmpp-client }
+    use_backend d3_app_8080 if { ssl_fc_alpn -m str h2 }
+    default_backend d1_app_8080
 <<frontends-default>>
 <<support>>
 `)
@@ -2311,6 +2958,19 @@ listen _tcp_pq_5432
     mode tcp
     server srv001 172.17.0.2:5432 send-proxy-v2`,
 		},
+		// 6
+		{
+			doconfig: func(c *testConfig) {
+				b := c.config.TCPBackends().Acquire("pq", 5432)
+				b.AddEndpoint("172.17.0.2", 5432)
+				c.config.Global().Bind.TCPBindIPv6 = "::"
+			},
+			expected: `
+listen _tcp_pq_5432
+    bind :5432,:::5432 v6only
+    mode tcp
+    server srv001 172.17.0.2:5432`,
+		},
 	}
 	for _, test := range testCases {
 		c := setup(t)
@@ -2337,6 +2997,57 @@ backend _error404
 	}
 }
 
+func TestInstanceDualStack(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).AllowedIPHTTP.Rule = []string{"2001:db8::/32", "fd00::10"}
+	b.Limit.Connections = 100
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	bind := &c.config.Global().Bind
+	bind.HTTPBind = ":80,:::80 v6only"
+	bind.HTTPSBind = ":443,:::443 v6only"
+	bind.IPv6 = true
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    stick-table type ipv6 size 200k expire 5m store conn_cur,conn_rate(1s)
+    http-request track-sc1 src
+    http-request deny deny_status 429 if { sc1_conn_cur gt 100 }
+    acl allow_rule_src0 src 2001:db8::/32 fd00::10
+    http-request deny if !allow_rule_src0
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80,:::80 v6only
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443,:::443 v6only ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestInstanceDefaultHost(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -3274,17 +3985,255 @@ backend d1_app_8080
 	}
 }
 
-func TestInstanceFrontendAuthExternal(t *testing.T) {
-	backend1ID := "d_app1_8080"
-	allHeaders := []string{"*"}
+func TestInstanceAuthExternalCache(t *testing.T) {
 	testCases := []struct {
-		authext   *hatypes.AuthExternal
-		expconfig string
-		logging   string
+		cache      hatypes.AuthCache
+		strip      []string
+		stripRegex []string
+		expback    string
+		expfront   string
 	}{
 		// 0
-		{},
-		// 1
+		{
+			expback: `
+    http-request lua.auth-intercept _auth_4001 /oauth2/auth GET '*' '*' '*'
+    http-request deny if !{ var(txn.auth_response_successful) -m bool }`,
+		},
+		// 1
+		{
+			cache: hatypes.AuthCache{Duration: "1m", KeyHeader: "Authorization", Size: 10240},
+			expback: `
+    http-request set-var(txn.authcachekey) req.fhdr(Authorization),sha2(256) if { req.fhdr(Authorization) -m found }
+    http-request set-var(txn.auth_response_successful) bool(true) if { var(txn.authcachekey),in_table(_auth_cache_allow_a4291eac) }
+    http-request lua.auth-intercept _auth_4001 /oauth2/auth GET '*' '*' '*' if !{ var(txn.auth_response_successful) -m found }
+    http-request track-sc0 var(txn.authcachekey) table _auth_cache_allow_a4291eac if { var(txn.auth_response_code) -m found } { var(txn.auth_response_successful) -m bool }
+    http-request deny if !{ var(txn.auth_response_successful) -m bool }`,
+			expfront: `
+backend _auth_cache_allow_a4291eac
+    stick-table type binary len 32 size 10240 expire 1m`,
+		},
+		// 2
+		{
+			cache: hatypes.AuthCache{Duration: "1m", DenyDuration: "10s", KeyHeader: "X-Token", Size: 500},
+			expback: `
+    http-request set-var(txn.authcachekey) req.fhdr(X-Token),sha2(256) if { req.fhdr(X-Token) -m found }
+    http-request set-var(txn.auth_response_successful) bool(true) if { var(txn.authcachekey),in_table(_auth_cache_allow_f9878233) }
+    http-request set-var(txn.auth_response_successful) bool(false) if !{ var(txn.auth_response_successful) -m found } { var(txn.authcachekey),in_table(_auth_cache_deny_f9878233) }
+    http-request lua.auth-intercept _auth_4001 /oauth2/auth GET '*' '*' '*' if !{ var(txn.auth_response_successful) -m found }
+    http-request track-sc0 var(txn.authcachekey) table _auth_cache_allow_f9878233 if { var(txn.auth_response_code) -m found } { var(txn.auth_response_successful) -m bool }
+    http-request track-sc0 var(txn.authcachekey) table _auth_cache_deny_f9878233 if { var(txn.auth_response_code) -m int 401 403 }
+    http-request deny if !{ var(txn.auth_response_successful) -m bool }`,
+			expfront: `
+backend _auth_cache_allow_f9878233
+    stick-table type binary len 32 size 500 expire 1m
+backend _auth_cache_deny_f9878233
+    stick-table type binary len 32 size 500 expire 10s`,
+		},
+		// 3
+		{
+			cache:      hatypes.AuthCache{Duration: "1m", KeyHeader: "Authorization", Size: 10240},
+			strip:      []string{"X-Auth-User"},
+			stripRegex: []string{"^x-auth-group-.*$"},
+			expback: `
+    http-request set-var(txn.authcachekey) req.fhdr(Authorization),sha2(256) if { req.fhdr(Authorization) -m found }
+    http-request set-var(txn.auth_response_successful) bool(true) if { var(txn.authcachekey),in_table(_auth_cache_allow_a4291eac) }
+    http-request lua.auth-intercept _auth_4001 /oauth2/auth GET '*' '*' '*' if !{ var(txn.auth_response_successful) -m found }
+    http-request track-sc0 var(txn.authcachekey) table _auth_cache_allow_a4291eac if { var(txn.auth_response_code) -m found } { var(txn.auth_response_successful) -m bool }
+    http-request del-header X-Auth-User
+    http-request del-header ^x-auth-group-.*$ -m reg
+    http-request deny if !{ var(txn.auth_response_successful) -m bool }`,
+			expfront: `
+backend _auth_cache_allow_a4291eac
+    stick-table type binary len 32 size 10240 expire 1m`,
+		},
+	}
+	for _, test := range testCases {
+		c := setup(t)
+
+		authFront := &c.config.Frontend().AuthProxy
+		authFront.Name = "_front__auth"
+		authFront.RangeStart = 4001
+		authFront.RangeEnd = 4010
+		authBackend := c.config.Backends().AcquireAuthBackend([]string{"10.0.0.1"}, 8080, "")
+		authBackendName, _ := c.config.Frontend().AcquireAuthBackendName(authBackend.BackendID())
+
+		b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h := c.config.Hosts().AcquireHost("d1.local")
+		h.AddPath(b, "/", hatypes.MatchBegin)
+		auth := &b.FindBackendPath(h.FindPath("/")[0].Link).AuthExternal
+		auth.AuthBackendName = authBackendName
+		auth.AuthPath = "/oauth2/auth"
+		auth.Cache = test.cache
+		auth.HeadersRequest = []string{"*"}
+		auth.HeadersSucceed = []string{"*"}
+		auth.HeadersFail = []string{"*"}
+		auth.HeadersStrip = test.strip
+		auth.HeadersStripRegex = test.stripRegex
+		auth.Method = "GET"
+
+		c.Update()
+		c.checkConfig(`
+<<global>>
+<<defaults>>
+backend _auth_backend001_8080
+    mode http
+    server srv001 10.0.0.1:8080 weight 1
+backend d1_app_8080
+    mode http` + test.expback + `
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+backend _auth_4001
+    mode http
+    server _auth_4001 127.0.0.1:4001` + test.expfront + `
+frontend _front__auth
+    mode http
+    bind 127.0.0.1:4001
+    use_backend _auth_backend001_8080
+<<frontends-default>>
+<<support>>
+`)
+
+		c.logger.CompareLogging(defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestInstanceEndpointsMaintenance(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	metrics := c.instance.metrics.(*helper_test.MetricsMock)
+	b1 := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	ep := *endpointS21
+	ep.Maintenance = true
+	b1.Endpoints = []*hatypes.Endpoint{endpointS1, &ep}
+	b2 := c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b2.Endpoints = []*hatypes.Endpoint{endpointS22}
+	c.Update()
+	if len(metrics.EndpointsMaint) != 1 || metrics.EndpointsMaint["d1_app_8080"] != 1 {
+		t.Errorf("unexpected endpoints in maintenance after adding backends: %v", metrics.EndpointsMaint)
+	}
+
+	c.config.Backends().RemoveAll([]string{"d1_app_8080"})
+	c.Update()
+	if len(metrics.EndpointsMaint) > 0 {
+		t.Errorf("unexpected endpoints in maintenance after removing backends: %v", metrics.EndpointsMaint)
+	}
+	c.logger.Logging = []string{}
+}
+
+func TestInstanceACLListsSpilled(t *testing.T) {
+	buildList := func(size int) []string {
+		list := make([]string, size)
+		for i := range list {
+			list[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		}
+		return list
+	}
+	testCases := []struct {
+		size    int
+		spilled int
+		acl     string
+	}{
+		// 0
+		{
+			size: 10,
+			acl: `
+    acl wlist_conn src 10.0.0.0 10.0.0.1 10.0.0.2 10.0.0.3 10.0.0.4 10.0.0.5 10.0.0.6 10.0.0.7 10.0.0.8 10.0.0.9
+    http-request deny`,
+		},
+		// 1
+		{
+			size: 11,
+			acl: `
+    acl wlist_conn src 10.0.0.0 10.0.0.1 10.0.0.2 10.0.0.3 10.0.0.4 10.0.0.5 10.0.0.6 10.0.0.7 10.0.0.8 10.0.0.9
+    acl wlist_conn src 10.0.0.10
+    http-request deny`,
+		},
+		// 2
+		{
+			size: 100,
+			acl: `
+    acl wlist_conn src 10.0.0.90 10.0.0.91 10.0.0.92 10.0.0.93 10.0.0.94 10.0.0.95 10.0.0.96 10.0.0.97 10.0.0.98 10.0.0.99
+    http-request deny`,
+		},
+		// 3
+		{
+			size:    101,
+			spilled: 1,
+			acl: `
+    http-request track-sc1 src
+    acl wlist_conn src -f /etc/haproxy/maps/_back_d1_app_8080_src_6e086d24.list
+    http-request deny`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		metrics := c.instance.metrics.(*helper_test.MetricsMock)
+		whitelist := buildList(test.size)
+		acquireBackend := func() {
+			b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+			b.Endpoints = []*hatypes.Endpoint{endpointS1}
+			b.Limit.Connections = 200
+			b.Limit.Whitelist = whitelist
+		}
+
+		acquireBackend()
+		c.Update()
+		cfg := strings.Replace(c.readConfig(c.tempdir+"/haproxy.cfg"), c.tempdir, "/etc/haproxy/maps", -1)
+		c.containsText(fmt.Sprintf("haproxy.cfg on %d", i), cfg, test.acl)
+		if metrics.ACLListsSpilled["backend"] != test.spilled {
+			t.Errorf("spilled lists differ on %d - expected: %d, actual: %v", i, test.spilled, metrics.ACLListsSpilled)
+		}
+		if test.spilled > 0 {
+			c.checkMap("_back_d1_app_8080_src_6e086d24.list", "\n"+strings.Join(whitelist, "\n"))
+		}
+		c.logger.CompareLogging(defaultLogging)
+
+		// same content on a new sync should not reload
+		c.config.Backends().RemoveAll([]string{"d1_app_8080"})
+		acquireBackend()
+		c.Update()
+		c.logger.CompareLogging(`
+INFO old and new configurations match`)
+
+		if test.spilled > 0 {
+			// a changed list is written to a new file, the former one is removed after the reload
+			whitelist = buildList(test.size + 1)
+			c.config.Backends().RemoveAll([]string{"d1_app_8080"})
+			acquireBackend()
+			c.Update()
+			if _, err := os.Stat(c.tempdir + "/_back_d1_app_8080_src_6e086d24.list"); !os.IsNotExist(err) {
+				t.Errorf("expected superseded list file removed on %d, but stat returned: %v", i, err)
+			}
+			if metrics.ACLListsSpilled["backend"] != test.spilled {
+				t.Errorf("spilled lists differ on %d after changing the list - expected: %d, actual: %v", i, test.spilled, metrics.ACLListsSpilled)
+			}
+			c.logger.Logging = []string{}
+		}
+
+		c.config.Backends().RemoveAll([]string{"d1_app_8080"})
+		c.Update()
+		if len(metrics.ACLListsSpilled) > 0 {
+			t.Errorf("unexpected spilled lists on %d after removing backends: %v", i, metrics.ACLListsSpilled)
+		}
+		c.logger.Logging = []string{}
+		c.teardown()
+	}
+}
+
+func TestInstanceFrontendAuthExternal(t *testing.T) {
+	backend1ID := "d_app1_8080"
+	allHeaders := []string{"*"}
+	testCases := []struct {
+		authext   *hatypes.AuthExternal
+		expconfig string
+		logging   string
+	}{
+		// 0
+		{},
+		// 1
 		{
 			authext: &hatypes.AuthExternal{},
 		},
@@ -3357,6 +4306,63 @@ func TestInstanceFrontendAuthExternal(t *testing.T) {
 			expconfig: `
     http-request deny if { var(req.base) -m str beg 'd.local#/' }`,
 		},
+		// 7
+		{
+			authext: &hatypes.AuthExternal{
+				AuthBackendName: backend1ID,
+				AuthPath:        "/auth",
+				Method:          "GET",
+				HeadersFail:     []string{"-"},
+				HeadersRequest:  allHeaders,
+				HeadersSucceed:  allHeaders,
+				RedirectOnFail:  "/login?rd=%[url,url_enc]",
+				RedirHTMLOnly:   true,
+				AllowedPath:     "/login",
+			},
+			expconfig: `
+    http-request lua.auth-intercept d_app1_8080 /auth GET '*' '*' '-' if !{ path_beg /login } { var(req.base) -m str beg 'd.local#/' }
+    http-request redirect location /login?rd=%[url,url_enc] if !{ var(txn.auth_response_successful) -m bool } !{ path_beg /login } { req.fhdr(accept) -m sub -i text/html } { var(req.base) -m str beg 'd.local#/' }
+    http-request deny deny_status 401 if !{ var(txn.auth_response_successful) -m bool } !{ path_beg /login } { var(req.base) -m str beg 'd.local#/' }`,
+		},
+		// 8
+		{
+			authext: &hatypes.AuthExternal{
+				AuthBackendName: backend1ID,
+				AuthPath:        "/oauth2/auth",
+				Method:          "HEAD",
+				HeadersFail:     []string{"-"},
+				HeadersRequest:  allHeaders,
+				HeadersSucceed:  []string{"-"},
+				HeadersVars:     map[string]string{"X-Auth-Request-Email": "req.auth_response_header.x_auth_request_email"},
+				DenyStatus:      401,
+				DenyBody:        `{"error":"unauthorized"}`,
+				AllowedPath:     "/oauth2/",
+			},
+			expconfig: `
+    http-request lua.auth-intercept d_app1_8080 /oauth2/auth HEAD '*' '-' '-' if !{ path_beg /oauth2/ } { var(req.base) -m str beg 'd.local#/' }
+    http-request deny deny_status 401 content-type application/json string '{"error":"unauthorized"}' if !{ var(txn.auth_response_successful) -m bool } !{ path_beg /oauth2/ } { var(req.base) -m str beg 'd.local#/' }
+    http-request set-header X-Auth-Request-Email %[var(req.auth_response_header.x_auth_request_email)] if { var(req.auth_response_header.x_auth_request_email) -m found } !{ path_beg /oauth2/ } { var(req.base) -m str beg 'd.local#/' }`,
+		},
+		// 9
+		{
+			authext: &hatypes.AuthExternal{
+				AuthBackendName: backend1ID,
+				AuthPath:        "/oauth2/auth",
+				Method:          "HEAD",
+				HeadersFail:     []string{"-"},
+				HeadersRequest:  allHeaders,
+				HeadersSucceed:  []string{"-"},
+				RedirectOnFail:  "/oauth2/start?rd=%[path]",
+				RedirHTMLOnly:   true,
+				RedirNoXHR:      true,
+				DenyStatus:      403,
+				AllowedPath:     "/oauth2/",
+			},
+			expconfig: `
+    http-request lua.auth-intercept d_app1_8080 /oauth2/auth HEAD '*' '-' '-' if !{ path_beg /oauth2/ } { var(req.base) -m str beg 'd.local#/' }
+    http-request redirect location /oauth2/start?rd=%[path] if !{ var(txn.auth_response_successful) -m bool } !{ path_beg /oauth2/ } { req.fhdr(accept) -m sub -i text/html } !{ req.fhdr(x-requested-with) -m str -i xmlhttprequest } { var(req.base) -m str beg 'd.local#/' }
+    http-request deny deny_status 403 if !{ var(txn.auth_response_successful) -m bool } !{ path_beg /oauth2/ } { var(req.base) -m str beg 'd.local#/' }`,
+		},
 	}
 
 	for _, test := range testCases {
@@ -3909,6 +4915,119 @@ location: https://other.local
 	c.logger.CompareLogging(defaultLogging)
 }
 
+func TestInstanceUniqueID(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.global.UniqueID = hatypes.UniqueIDConfig{
+		Format:         "%{+X}o%ci:%cp",
+		ResponseHeader: "X-Request-ID",
+	}
+	c.config.global.CustomHTTPHAResponses = []hatypes.HTTPResponse{
+		{
+			Name:         "403",
+			StatusCode:   403,
+			StatusReason: "Forbidden",
+			LogFormat: &hatypes.HTTPResponseLogFormat{
+				ContentType: "text/html",
+				Body:        `<p>request id: %[unique-id,bytes(0,64),url_enc]</p>\n`,
+				Headers:     []hatypes.HTTPHeader{{Name: "Cache-Control", Value: "no-cache"}},
+			},
+		},
+	}
+	c.config.global.CustomHTTPLuaResponses = []hatypes.HTTPResponse{
+		{
+			Name: "send-404",
+			Headers: []hatypes.HTTPHeader{
+				{Name: "Content-Length", Value: "33"},
+				{Name: "Content-Type", Value: "text/plain"},
+			},
+			Body:         []string{"404 Not Found", "id: %[unique-id]"},
+			StatusCode:   404,
+			StatusReason: "Not Found",
+			UniqueIDBody: true,
+		},
+	}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+defaults
+    log global
+    maxconn 2000
+    option redispatch
+    option dontlognull
+    option http-server-close
+    option http-keep-alive
+    http-error status 403 content-type "text/html" lf-string "<p>request id: %[unique-id,bytes(0,64),url_enc]</p>\n" hdr Cache-Control "no-cache"
+    timeout client          50s
+    timeout client-fin      50s
+    timeout connect         5s
+    timeout http-keep-alive 1m
+    timeout http-request    5s
+    timeout queue           5s
+    timeout server          50s
+    timeout server-fin      50s
+    timeout tunnel          1h
+    unique-id-format        %{+X}o%ci:%cp
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-after-response set-header X-Request-ID "%[unique-id,bytes(0,64),url_enc]" if !{ res.hdr(X-Request-ID) -m found }
+    http-request set-header X-Forwarded-Proto http
+    http-request del-header X-SSL-Client-CN
+    http-request del-header X-SSL-Client-DN
+    http-request del-header X-SSL-Client-SHA1
+    http-request del-header X-SSL-Client-SHA2
+    http-request del-header X-SSL-Client-Cert
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    http-request set-var(req.path) path
+    http-request set-var(req.host) hdr(host),field(1,:),lower
+    http-request set-var(req.base) var(req.host),concat(\#,req.path)
+    http-after-response set-header X-Request-ID "%[unique-id,bytes(0,64),url_enc]" if !{ res.hdr(X-Request-ID) -m found }
+    http-request set-header X-Forwarded-Proto https
+    http-request del-header X-SSL-Client-CN
+    http-request del-header X-SSL-Client-DN
+    http-request del-header X-SSL-Client-SHA1
+    http-request del-header X-SSL-Client-SHA2
+    http-request del-header X-SSL-Client-Cert
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+
+	c.compareText("responses.lua", c.readConfig(c.tempdir+"/responses.lua"), `
+core.register_service("send-404", "http", function(applet)
+    response = [==[
+404 Not Found
+id: %[unique-id]
+]==]
+    local uniqueid = string.sub(applet.f:unique_id() or "", 1, 64)
+    uniqueid = string.gsub(uniqueid, "[^%w%-%._~]", function(c) return string.format("%%%02X", string.byte(c)) end)
+    response = string.gsub(response, "%%%[unique%-id%]", function() return uniqueid end)
+    applet:set_status(404, "Not Found")
+    applet:add_header("Content-Length", tostring(string.len(response)))
+    applet:add_header("Content-Type", "text/plain")
+    applet:start_response()
+    applet:send(response)
+end)
+`)
+
+	if _, err := os.Stat(c.tempdir + "/errorfiles/403.http"); !os.IsNotExist(err) {
+		t.Errorf("errorfile of a log-format based response should not be created")
+	}
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestInstanceSSLPassthrough(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -3989,82 +5108,1332 @@ listen _front__tls
     default_backend _error404
 frontend _front_https__local
     mode http
-    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    bind unix@/var/run/haproxy/_https_socket.sock accept-proxy ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_front_sslpassthrough__exact.map", `
+d2.local d2_app_8080
+d3.local d3_app-ssl_8443
+`)
+	c.checkMap("_front_http_host__begin.map", `
+d2.local#/ _redirect_https
+d3.local#/app d3_app2-http_8080
+d3.local#/ d3_app1-http_8080
+`)
+	c.checkMap("_front_bind_crt.list", `
+/var/haproxy/ssl/certs/default.pem !*
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceRootRedirect(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.global.SSL.SSLRedirect = true
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("*.d1.local")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.RootRedirect = "/app"
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
+	h.TLS.TLSHash = "0"
+	h.AddPath(b, "/app1", hatypes.MatchBegin)
+	h.AddPath(b, "/app2", hatypes.MatchBegin)
+	h.RootRedirect = "/app1"
+	for _, path := range b.Paths {
+		path.SSLRedirect = true
+	}
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	c.Update()
+
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    acl https-request ssl_fc
+    http-request redirect scheme https if !https-request
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    http-request redirect scheme https if { path / } { var(req.host) -i -m str -f /etc/haproxy/maps/_front_redir_root_ssl__exact.map }
+    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
+    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
+    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
+    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
+    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_front_http_host__regex.map", `
+^[^.]+\.d1\.local#/ d1_app_8080
+`)
+	c.checkMap("_front_http_host__begin.map", `
+d2.local#/app2 d2_app_8080
+d2.local#/app1 d2_app_8080
+`)
+	c.checkMap("_front_http_host__regex.map", `
+^[^.]+\.d1\.local#/ d1_app_8080
+`)
+	c.checkMap("_front_redir_fromroot__exact.map", `
+d2.local /app1
+`)
+	c.checkMap("_front_redir_root_ssl__exact.map", `
+d2.local
+`)
+	c.checkMap("_front_redir_fromroot__regex.map", `
+^[^.]+\.d1\.local$ /app
+`)
+	c.checkMap("_front_https_host__begin.map", `
+d2.local#/app2 d2_app_8080
+d2.local#/app1 d2_app_8080
+`)
+	c.checkMap("_front_https_host__regex.map", `
+^[^.]+\.d1\.local#/ d1_app_8080
+`)
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstancePathNormalization(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/admin", hatypes.MatchBegin)
+	h.PathNormalization = hatypes.PathNormalizationStrict
+	b.FindBackendPath(h.FindPath("/admin")[0].Link).AllowedIPHTTP.Rule = []string{"10.0.0.0/8"}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("*.d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.PathNormalization = hatypes.PathNormalizationLowercase
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	b = c.config.Backends().AcquireBackend("d3", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS31}
+
+	c.config.Userlists().Replace("default_auth1", []hatypes.User{{Name: "usr1", Passwd: "clear1"}})
+	b = c.config.Backends().AcquireBackend("d4", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d4.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/Admin", hatypes.MatchExact)
+	h.AddPath(b, "/Reports", hatypes.MatchPrefix)
+	h.PathNormalization = hatypes.PathNormalizationLowercase
+	b.FindBackendPath(h.FindPath("/Admin")[0].Link).AuthHTTP = hatypes.AuthHTTP{UserlistName: "default_auth1"}
+	b.FindBackendPath(h.FindPath("/Reports")[0].Link).AllowedIPHTTP.Rule = []string{"10.0.0.0/8"}
+	b.Endpoints = []*hatypes.Endpoint{endpointS32}
+
+	c.Update()
+
+	// txn.pathID of the backend is read from req.base, which is updated
+	// with the normalized path, so /app//../admin will match /admin
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+userlist default_auth1
+    user usr1 insecure-password clear1
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/admin
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    acl allow_rule_src1 src 10.0.0.0/8
+    http-request deny if { var(txn.pathID) -m str path02 } !allow_rule_src1
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+backend d3_app_8080
+    mode http
+    server s31 172.17.0.131:8080 weight 100
+backend d4_app_8080
+    mode http
+    # path01 = d4.local/
+    # path02 = d4.local/Admin
+    # path03 = d4.local/Reports
+    http-request set-var(txn.pathID) var(req.base),map_str(/etc/haproxy/maps/_back_d4_app_8080_idpath__exact.map)
+    http-request set-var(txn.pathID) var(req.base),map_dir(/etc/haproxy/maps/_back_d4_app_8080_idpath__prefix_02.map) if !{ var(txn.pathID) -m found }
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d4_app_8080_idpath__begin.map) if !{ var(txn.pathID) -m found }
+    acl allow_rule_src1 src 10.0.0.0/8
+    http-request deny if { var(txn.pathID) -m str path03 } !allow_rule_src1
+    http-request auth if { var(txn.pathID) -m str path02 } !{ http_auth(default_auth1) }
+    server s32 172.17.0.132:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    http-request set-var(req.pathnormalize) var(req.host),map_str(/etc/haproxy/maps/_front_path_normalize__exact.map)
+    http-request set-var(req.pathnormalize) var(req.host),map_reg(/etc/haproxy/maps/_front_path_normalize__regex.map) if !{ var(req.pathnormalize) -m found }
+    http-request normalize-uri path-merge-slashes if { var(req.pathnormalize) -m found }
+    http-request normalize-uri path-strip-dot if { var(req.pathnormalize) -m found }
+    http-request normalize-uri path-strip-dotdot if { var(req.pathnormalize) -m found }
+    http-request normalize-uri percent-decode-unreserved if { var(req.pathnormalize) -m found }
+    http-request set-var(req.path) path if { var(req.pathnormalize) -m found }
+    http-request set-var(req.path) path,lower if { var(req.pathnormalize) -m str lowercase }
+    http-request set-var(req.base) var(req.host),concat(\#,req.path) if { var(req.pathnormalize) -m found }
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),map_str(/etc/haproxy/maps/_front_http_host__exact.map)
+    http-request set-var(req.backend) var(req.base),map_dir(/etc/haproxy/maps/_front_http_host__prefix_02.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.pathnormalize) var(req.host),map_str(/etc/haproxy/maps/_front_path_normalize__exact.map)
+    http-request set-var(req.pathnormalize) var(req.host),map_reg(/etc/haproxy/maps/_front_path_normalize__regex.map) if !{ var(req.pathnormalize) -m found }
+    http-request normalize-uri path-merge-slashes if { var(req.pathnormalize) -m found }
+    http-request normalize-uri path-strip-dot if { var(req.pathnormalize) -m found }
+    http-request normalize-uri path-strip-dotdot if { var(req.pathnormalize) -m found }
+    http-request normalize-uri percent-decode-unreserved if { var(req.pathnormalize) -m found }
+    http-request set-var(req.path) path if { var(req.pathnormalize) -m found }
+    http-request set-var(req.path) path,lower if { var(req.pathnormalize) -m str lowercase }
+    http-request set-var(req.base) var(req.host),concat(\#,req.path) if { var(req.pathnormalize) -m found }
+    http-request set-var(req.hostbackend) var(req.base),map_str(/etc/haproxy/maps/_front_https_host__exact.map)
+    http-request set-var(req.hostbackend) var(req.base),map_dir(/etc/haproxy/maps/_front_https_host__prefix_02.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+
+	c.checkMap("_front_path_normalize__exact.map", `
+d1.local strict
+d4.local lowercase
+`)
+	c.checkMap("_front_path_normalize__regex.map", `
+^[^.]+\.d2\.local$ lowercase
+`)
+
+	// paths of a host in lowercase mode are lowercased in the maps as well,
+	// since the lookup uses the lowercased path of the request
+	c.checkMap("_front_http_host__exact.map", `
+d4.local#/admin d4_app_8080
+`)
+	c.checkMap("_front_http_host__prefix_02.map", `
+d4.local#/reports d4_app_8080
+`)
+	c.checkMap("_back_d4_app_8080_idpath__exact.map", `
+d4.local#/admin path02
+`)
+	c.checkMap("_back_d4_app_8080_idpath__prefix_02.map", `
+d4.local#/reports path03
+`)
+
+	// the path groups of the allowlist and auth rules are selected by the
+	// normalized request path, looked up the same way haproxy does
+	lookup := func(base string, maps ...string) string {
+		for _, mapName := range maps {
+			for _, line := range strings.Split(c.readConfig(c.tempdir+"/"+mapName), "\n") {
+				key, value, found := strings.Cut(line, " ")
+				if !found {
+					continue
+				}
+				var match bool
+				switch {
+				case strings.Contains(mapName, "__exact"):
+					match = base == key
+				case strings.Contains(mapName, "__prefix"):
+					match = base == key || strings.HasPrefix(base, strings.TrimSuffix(key, "/")+"/")
+				case strings.Contains(mapName, "__begin"):
+					match = strings.HasPrefix(strings.ToLower(base), key)
+				}
+				if match {
+					return value
+				}
+			}
+		}
+		return ""
+	}
+	d1maps := []string{"_back_d1_app_8080_idpath__begin.map"}
+	d4maps := []string{"_back_d4_app_8080_idpath__exact.map", "_back_d4_app_8080_idpath__prefix_02.map", "_back_d4_app_8080_idpath__begin.map"}
+	for _, test := range []struct {
+		request string
+		base    string
+		maps    []string
+		expPath string
+	}{
+		{request: "d1.local/app//../admin", base: "d1.local#/admin", maps: d1maps, expPath: "path02"},
+		{request: "d1.local/./admin/", base: "d1.local#/admin/", maps: d1maps, expPath: "path02"},
+		{request: "d1.local/app", base: "d1.local#/app", maps: d1maps, expPath: "path01"},
+		{request: "d4.local/ADMIN", base: "d4.local#/admin", maps: d4maps, expPath: "path02"},
+		{request: "d4.local//Admin", base: "d4.local#/admin", maps: d4maps, expPath: "path02"},
+		{request: "d4.local/Reports/../REPORTS/2026", base: "d4.local#/reports/2026", maps: d4maps, expPath: "path03"},
+		{request: "d4.local/Admin/x/..", base: "d4.local#/admin/", maps: d4maps, expPath: "path01"},
+	} {
+		if path := lookup(test.base, test.maps...); path != test.expPath {
+			t.Errorf("request '%s' normalized to '%s' should select %s, found '%s'", test.request, test.base, test.expPath, path)
+		}
+	}
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceAudit(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	audit := c.config.Backends().AcquireBackend("audit", "spoa", "12345")
+	audit.ModeTCP = true
+	audit.Endpoints = []*hatypes.Endpoint{endpointS32}
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.Audit.Backend = audit.BackendID()
+	h.Audit.SamplePercent = 1
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("*.d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.Audit.Backend = audit.BackendID()
+	h.Audit.SamplePercent = 50
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	b = c.config.Backends().AcquireBackend("d3", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.Audit.Backend = audit.BackendID()
+	b.Endpoints = []*hatypes.Endpoint{endpointS31}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend audit_spoa_12345
+    mode tcp
+    server s32 172.17.0.132:8080 weight 100
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+backend d3_app_8080
+    mode http
+    server s31 172.17.0.131:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    filter spoe engine audit-audit_spoa_12345 config /etc/haproxy/spoe-audit.conf
+    http-request set-var(txn.auditpercent) var(req.host),map_str(/etc/haproxy/maps/_front_audit_percent__exact.map)
+    http-request set-var(txn.auditpercent) var(req.host),map_reg(/etc/haproxy/maps/_front_audit_percent__regex.map) if !{ var(txn.auditpercent) -m found }
+    http-request set-var(txn.auditsample) src_port,concat(:,req.base),xxh32,mod(100),sub(txn.auditpercent) if { var(txn.auditpercent) -m found }
+    http-request set-var(txn.audit) var(req.host),map_str(/etc/haproxy/maps/_front_audit_backend__exact.map) if { var(txn.auditsample) -m int lt 0 }
+    http-request set-var(txn.audit) var(req.host),map_reg(/etc/haproxy/maps/_front_audit_backend__regex.map) if { var(txn.auditsample) -m int lt 0 } !{ var(txn.audit) -m found }
+    http-request send-spoe-group audit-audit_spoa_12345 audit if { var(txn.audit) -m str audit_spoa_12345 }
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    filter spoe engine audit-audit_spoa_12345 config /etc/haproxy/spoe-audit.conf
+    http-request set-var(txn.auditpercent) var(req.host),map_str(/etc/haproxy/maps/_front_audit_percent__exact.map)
+    http-request set-var(txn.auditpercent) var(req.host),map_reg(/etc/haproxy/maps/_front_audit_percent__regex.map) if !{ var(txn.auditpercent) -m found }
+    http-request set-var(txn.auditsample) src_port,concat(:,req.base),xxh32,mod(100),sub(txn.auditpercent) if { var(txn.auditpercent) -m found }
+    http-request set-var(txn.audit) var(req.host),map_str(/etc/haproxy/maps/_front_audit_backend__exact.map) if { var(txn.auditsample) -m int lt 0 }
+    http-request set-var(txn.audit) var(req.host),map_reg(/etc/haproxy/maps/_front_audit_backend__regex.map) if { var(txn.auditsample) -m int lt 0 } !{ var(txn.audit) -m found }
+    http-request send-spoe-group audit-audit_spoa_12345 audit if { var(txn.audit) -m str audit_spoa_12345 }
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+	c.checkMap("_front_audit_backend__exact.map", `
+d1.local audit_spoa_12345
+`)
+	c.checkMap("_front_audit_percent__regex.map", `
+^[^.]+\.d2\.local$ 50
+`)
+	c.checkConfigFile(`
+[audit-audit_spoa_12345]
+spoe-agent audit-agent
+    groups       audit
+    option       var-prefix  audit
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  100ms
+    use-backend  audit_spoa_12345
+    log          global
+    option       dontlog-normal
+spoe-message audit-request
+    args   method=method path=path headers=req.hdrs_bin src=src
+spoe-group audit
+    messages  audit-request
+`, "spoe-audit.conf")
+
+	c.logger.CompareLogging(defaultLogging)
+
+	// turning audit off removes all the related configuration
+	hostnames := []string{"d1.local", "*.d2.local", "d3.local"}
+	c.config.Hosts().RemoveAll(hostnames)
+	for i, hostname := range hostnames {
+		b = c.config.Backends().FindBackend(fmt.Sprintf("d%d", i+1), "app", "8080")
+		c.config.Hosts().AcquireHost(hostname).AddPath(b, "/", hatypes.MatchBegin)
+	}
+	c.Update()
+	cfg := c.readConfig(c.tempdir + "/haproxy.cfg")
+	if strings.Contains(cfg, "spoe-audit") || strings.Contains(cfg, "txn.audit") {
+		t.Errorf("audit filters and rules should be removed:\n%s", cfg)
+	}
+	c.checkConfigFile(``, "spoe-audit.conf")
+
+	// hosts are logged in the map order
+	c.logger.Logging = []string{}
+}
+
+func TestInstanceBandwidthLimit(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.BandwidthLimit = hatypes.BackendBandwidthLimit{Download: 10485760, Upload: 1024}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.BandwidthLimit = hatypes.BackendBandwidthLimit{Download: 10485760, Shared: true}
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	b = c.config.Backends().AcquireBackend("d3", "app", "8080")
+	b.ModeTCP = true
+	b.BandwidthLimit = hatypes.BackendBandwidthLimit{Download: 10485760, Upload: 1024}
+	b.Endpoints = []*hatypes.Endpoint{endpointS31}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    filter bwlim-in bwlim-upload default-limit 1024 default-period 1s
+    http-request set-bandwidth-limit bwlim-upload
+    filter bwlim-out bwlim-download default-limit 10485760 default-period 1s
+    http-response set-bandwidth-limit bwlim-download
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    filter bwlim-out bwlim-download limit 10485760 key be_id table _bwlim_d2_app_8080
+    http-response set-bandwidth-limit bwlim-download
+    server s21 172.17.0.121:8080 weight 100
+backend _bwlim_d2_app_8080
+    stick-table type integer size 1 expire 1m store bytes_in_rate(1s),bytes_out_rate(1s)
+backend d3_app_8080
+    mode tcp
+    filter bwlim-in bwlim-upload default-limit 1024 default-period 1s
+    tcp-request content set-bandwidth-limit bwlim-upload
+    filter bwlim-out bwlim-download default-limit 10485760 default-period 1s
+    tcp-response content set-bandwidth-limit bwlim-download
+    server s31 172.17.0.131:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceChaos(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Chaos = hatypes.BackendChaos{Latency: "2s", LatencyPercent: 10}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Chaos = hatypes.BackendChaos{Latency: "500ms", LatencyPercent: 100, AbortStatus: 503, AbortPercent: 5}
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    tcp-request inspect-delay 2s
+    tcp-request content set-var(txn.chaos_latency) src_port,concat(:,req.base),xxh32(1),mod(100)
+    tcp-request content accept if !{ var(txn.chaos_latency) -m int lt 10 }
+    tcp-request content accept if WAIT_END
+    http-after-response add-header X-Chaos-Injected latency if { var(txn.chaos_latency) -m int lt 10 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    tcp-request inspect-delay 500ms
+    tcp-request content set-var(txn.chaos_latency) src_port,concat(:,req.base),xxh32(1),mod(100)
+    tcp-request content accept if !{ var(txn.chaos_latency) -m int lt 100 }
+    tcp-request content accept if WAIT_END
+    http-after-response add-header X-Chaos-Injected latency if { var(txn.chaos_latency) -m int lt 100 }
+    http-request set-var(txn.chaos_abort) src_port,concat(:,req.base),xxh32(2),mod(100)
+    http-request deny deny_status 503 hdr X-Chaos-Injected abort if { var(txn.chaos_abort) -m int lt 5 }
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceAuthBruteforce(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.Userlists().Replace("default_auth1", []hatypes.User{{Name: "usr1", Passwd: "clear1"}})
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/admin", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/admin")[0].Link).AuthHTTP = hatypes.AuthHTTP{UserlistName: "default_auth1"}
+	b.AuthBruteforce = hatypes.BackendAuthBruteforce{Limit: 10, Window: "1m", Ban: "10m"}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).AuthHTTP = hatypes.AuthHTTP{UserlistName: "default_auth1"}
+	b.AuthBruteforce = hatypes.BackendAuthBruteforce{Limit: 5, Window: "30s", Ban: "1h"}
+	b.Limit.Whitelist = []string{"10.0.0.0/8", "192.168.0.0/16"}
+	b.TrafficClass.LimitExempt = []string{"internal"}
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+userlist default_auth1
+    user usr1 insecure-password clear1
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/admin
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request track-sc2 src table _auth_bruteforce_d1_app_8080 if { var(txn.pathID) -m str path02 }
+    http-request deny deny_status 429 if { sc2_get_gpt0 gt 0 }
+    http-after-response sc-inc-gpc0(2) if { status 401 }
+    http-after-response sc-set-gpt0(2) 1 if { sc2_gpc0_rate gt 10 }
+    http-request auth if { var(txn.pathID) -m str path02 } !{ http_auth(default_auth1) }
+    server s1 172.17.0.11:8080 weight 100
+backend _auth_bruteforce_d1_app_8080
+    stick-table type ip size 200k expire 10m store gpc0,gpc0_rate(1m),gpt0
+backend d2_app_8080
+    mode http
+    acl wlist_auth_bruteforce src 10.0.0.0/8 192.168.0.0/16
+    http-request track-sc2 src table _auth_bruteforce_d2_app_8080 if !wlist_auth_bruteforce !{ var(txn.class_internal) -m bool }
+    http-request deny deny_status 429 if { sc2_get_gpt0 gt 0 }
+    http-after-response sc-inc-gpc0(2) if { status 401 }
+    http-after-response sc-set-gpt0(2) 1 if { sc2_gpc0_rate gt 5 }
+    http-request auth if !{ http_auth(default_auth1) }
+    server s21 172.17.0.121:8080 weight 100
+backend _auth_bruteforce_d2_app_8080
+    stick-table type ip size 200k expire 1h store gpc0,gpc0_rate(30s),gpt0
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceAuthJWT(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	key1 := hatypes.AuthJWTKey{Algs: []string{"RS256", "PS256"}, ID: "key1", PEM: "-----BEGIN PUBLIC KEY-----\nkey1\n-----END PUBLIC KEY-----\n"}
+	key2 := hatypes.AuthJWTKey{Algs: []string{"ES256"}, PEM: "-----BEGIN PUBLIC KEY-----\nkey2\n-----END PUBLIC KEY-----\n"}
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/api", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/api")[0].Link).AuthJWT = hatypes.AuthJWT{
+		Audience: "app1",
+		Keys:     []hatypes.AuthJWTKey{key1, key2},
+		Realm:    "api",
+	}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/admin", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).AuthJWT = hatypes.AuthJWT{
+		Issuer: "https://issuer.local",
+		Keys:   []hatypes.AuthJWTKey{key2},
+		Realm:  "localhost",
+	}
+	b.FindBackendPath(h.FindPath("/admin")[0].Link).AuthJWT = hatypes.AuthJWT{AlwaysDeny: true}
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	c.Update()
+	c.checkConfig(`
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    hard-stop-after 15m
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/jwt-auth.lua
+    lua-load /etc/haproxy/lua/services.lua
+    lua-load /etc/haproxy/lua/responses.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
+<<defaults>>
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/api
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request lua.jwt-auth "-" "app1" if { var(txn.pathID) -m str path02 }
+    http-request set-var(txn.jwt_verified) http_auth_bearer,jwt_verify(txn.jwt_alg,"/etc/haproxy/maps/_back_d1_app_8080_jwt_da9dd360.pem") if { var(txn.pathID) -m str path02 } { var(txn.jwt_alg) -m found } !{ var(txn.jwt_verified) -m int 1 } { var(txn.jwt_alg) -m str RS256 PS256 } { var(txn.jwt_kid) -m str key1 }
+    http-request set-var(txn.jwt_verified) http_auth_bearer,jwt_verify(txn.jwt_alg,"/etc/haproxy/maps/_back_d1_app_8080_jwt_6909fea3.pem") if { var(txn.pathID) -m str path02 } { var(txn.jwt_alg) -m found } !{ var(txn.jwt_verified) -m int 1 } { var(txn.jwt_alg) -m str ES256 }
+    http-request set-var(txn.jwt_error) str(invalid_token) if { var(txn.pathID) -m str path02 } !{ var(txn.jwt_error) -m found } !{ var(txn.jwt_verified) -m int 1 }
+    http-request return status 401 hdr WWW-Authenticate "Bearer realm=\"api\"" if { var(txn.pathID) -m str path02 } { var(txn.jwt_error) -m str missing_token }
+    http-request return status 401 hdr WWW-Authenticate "Bearer realm=\"api\", error=\"invalid_token\"" if { var(txn.pathID) -m str path02 } { var(txn.jwt_error) -m found }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    # path01 = d2.local/
+    # path02 = d2.local/admin
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d2_app_8080_idpath__begin.map)
+    http-request lua.jwt-auth "https://issuer.local" "-" if { var(txn.pathID) -m str path01 }
+    http-request set-var(txn.jwt_verified) http_auth_bearer,jwt_verify(txn.jwt_alg,"/etc/haproxy/maps/_back_d2_app_8080_jwt_6909fea3.pem") if { var(txn.pathID) -m str path01 } { var(txn.jwt_alg) -m found } !{ var(txn.jwt_verified) -m int 1 } { var(txn.jwt_alg) -m str ES256 }
+    http-request set-var(txn.jwt_error) str(invalid_token) if { var(txn.pathID) -m str path01 } !{ var(txn.jwt_error) -m found } !{ var(txn.jwt_verified) -m int 1 }
+    http-request return status 401 hdr WWW-Authenticate "Bearer realm=\"localhost\"" if { var(txn.pathID) -m str path01 } { var(txn.jwt_error) -m str missing_token }
+    http-request return status 401 hdr WWW-Authenticate "Bearer realm=\"localhost\", error=\"invalid_token\"" if { var(txn.pathID) -m str path01 } { var(txn.jwt_error) -m found }
+    http-request deny if { var(txn.pathID) -m str path02 }
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	if pem := c.readRawConfig(c.tempdir + "/_back_d1_app_8080_jwt_da9dd360.pem"); pem != key1.PEM {
+		t.Errorf("public key differs - expected: %q - actual: %q", key1.PEM, pem)
+	}
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceLoadServerState(t *testing.T) {
+	showProc := `#<PID>          <type>          <relative PID>  <reloads>       <uptime>        <version>
+1               master          0               1               0d00h00m28s     2.2.3-0e58a34
+# workers
+2               worker          1               0               0d00h00m00s     2.2.3-0e58a34
+# old workers
+# programs
+
+`
+	serversState := "1\n# be_id be_name srv_id srv_name srv_addr\n"
+	testCases := []struct {
+		global     bool
+		backends   map[string]bool
+		expConfig  []string
+		expMissing []string
+		expCmds    string
+	}{
+		// 0
+		{
+			backends:   map[string]bool{"d1": false},
+			expMissing: []string{"server-state-file", "load-server-state-from-file"},
+			expCmds:    "reload\nshow proc",
+		},
+		// 1
+		{
+			global:   true,
+			backends: map[string]bool{"d1": true, "d2": false},
+			expConfig: []string{
+				"    server-state-file state-global\n",
+				"defaults\n    log global\n    load-server-state-from-file global\n",
+				"backend d2_app_8080\n    mode http\n    load-server-state-from-file none\n",
+			},
+			expMissing: []string{"load-server-state-from-file global\n    server s1"},
+			expCmds:    "show servers state\nreload\nshow proc",
+		},
+		// 2
+		{
+			backends: map[string]bool{"d1": true, "d2": false},
+			expConfig: []string{
+				"    server-state-file state-global\n",
+				"backend d1_app_8080\n    mode http\n    load-server-state-from-file global\n",
+			},
+			expMissing: []string{"load-server-state-from-file none"},
+			expCmds:    "show servers state\nreload\nshow proc",
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		stateDir := filepath.Join(c.tempdir, "var/lib/haproxy")
+		if err := os.MkdirAll(stateDir, 0755); err != nil {
+			t.Errorf("error creating state dir: %v", err)
+		}
+		c.config.Global().LocalFSPrefix = c.tempdir
+		c.config.Global().LoadServerState = test.global
+		for name, load := range test.backends {
+			b := c.config.Backends().AcquireBackend(name, "app", "8080")
+			b.LoadServerState = load
+			b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		}
+		c.Update()
+		cfg := c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+		for _, exp := range test.expConfig {
+			c.containsText(fmt.Sprintf("haproxy.cfg on %d", i), cfg, exp)
+		}
+		for _, missing := range test.expMissing {
+			if strings.Contains(cfg, missing) {
+				t.Errorf("config on %d should not contain %q:\n%s", i, missing, cfg)
+			}
+		}
+
+		// the same socket is used as the admin and the master one,
+		// so the order of the commands across both of them is preserved
+		socketMock := &ha_helper.SocketMock{
+			CmdOutput: map[string][]string{
+				"show servers state": {serversState},
+				"show proc":          {showProc},
+			},
+		}
+		c.instance.conns.admin = socketMock
+		c.instance.conns.master = socketMock
+		c.instance.options.fake = false
+		c.instance.options.IsExternal = true
+		c.instance.up = true
+		if err := c.instance.reloadHAProxy(); err != nil {
+			t.Errorf("error reloading haproxy on %d: %v", i, err)
+		}
+		if cmds := socketMock.Commands(); cmds != test.expCmds {
+			t.Errorf("commands differ on %d - expected: %q, actual: %q", i, test.expCmds, cmds)
+		}
+		state, err := os.ReadFile(filepath.Join(stateDir, "state-global"))
+		if test.expCmds == "reload\nshow proc" {
+			if err == nil {
+				t.Errorf("servers state should not be persisted on %d", i)
+			}
+		} else if string(state) != serversState {
+			t.Errorf("persisted servers state differs on %d - expected: %q, actual: %q", i, serversState, string(state))
+		}
+		c.logger.CompareLoggingID(strconv.Itoa(i), defaultLogging)
+		c.teardown()
+	}
+}
+
+func TestInstanceEarlyHints(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.AddPath(b, "/app", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/app")[0].Link).EarlyHints = []string{
+		"</app/style.css>; rel=preload; as=style",
+		"</app/script.js>; rel=preload; as=script",
+	}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.FindBackendPath(h.FindPath("/")[0].Link).EarlyHints = []string{"</style.css>; rel=preload; as=style"}
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    # path01 = d1.local/
+    # path02 = d1.local/app
+    http-request set-var(txn.pathID) var(req.base),lower,map_beg(/etc/haproxy/maps/_back_d1_app_8080_idpath__begin.map)
+    http-request early-hint Link "</app/style.css>; rel=preload; as=style" if !{ req.ver 1.0 } { var(txn.pathID) -m str path02 }
+    http-request early-hint Link "</app/script.js>; rel=preload; as=script" if !{ req.ver 1.0 } { var(txn.pathID) -m str path02 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    http-request early-hint Link "</style.css>; rel=preload; as=style" if !{ req.ver 1.0 }
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceFallbackBackend(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Fallback = hatypes.BackendID{Namespace: "d1", Name: "fallback1", Port: "8080"}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d1", "fallback1", "8080")
+	b.Fallback = hatypes.BackendID{Namespace: "d1", Name: "fallback2", Port: "8080"}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d1", "fallback2", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+backend d1_fallback1_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+backend d1_fallback2_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    use_backend d1_fallback1_8080 if { var(req.backend) -m str d1_app_8080 } { nbsrv(d1_app_8080) eq 0 }
+    use_backend d1_fallback2_8080 if { var(req.backend) -m str d1_app_8080 } { nbsrv(d1_app_8080) eq 0 } { nbsrv(d1_fallback1_8080) eq 0 }
+    use_backend d1_fallback2_8080 if { var(req.backend) -m str d1_fallback1_8080 } { nbsrv(d1_fallback1_8080) eq 0 }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    use_backend d1_fallback1_8080 if { var(req.hostbackend) -m str d1_app_8080 } { nbsrv(d1_app_8080) eq 0 }
+    use_backend d1_fallback2_8080 if { var(req.hostbackend) -m str d1_app_8080 } { nbsrv(d1_app_8080) eq 0 } { nbsrv(d1_fallback1_8080) eq 0 }
+    use_backend d1_fallback2_8080 if { var(req.hostbackend) -m str d1_fallback1_8080 } { nbsrv(d1_fallback1_8080) eq 0 }
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceForceClose(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.ForceCloseUAs = []string{"EmbeddedHTTP", `^Wget/1\.1[0-9]`, "Acme Sensor v[0-9]+", "Bob's client", "c5", "c6"}
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	// requests that do not match the user agents use the keep-alive config from defaults
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    acl force-close-ua req.fhdr(User-Agent) -m reg 'EmbeddedHTTP' '^Wget/1\.1[0-9]' 'Acme Sensor v[0-9]+' 'Bob'"'"'s client' 'c5'
+    acl force-close-ua req.fhdr(User-Agent) -m reg 'c6'
+    http-request set-var(txn.force_close) bool(true) if force-close-ua
+    http-request set-header Connection close if force-close-ua
+    http-response set-header Connection close if { var(txn.force_close) -m bool }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontend-http>>
+    default_backend _error404
+<<frontend-https>>
+    default_backend _error404
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceForwardedHeaders(t *testing.T) {
+	testCases := map[string]struct {
+		forwardFor string
+		expected   string
+	}{
+		"untrusted": {
+			forwardFor: "add",
+			expected: `
+backend d1_app_8080
+    mode http
+    http-request set-header X-Forwarded-Host %[req.hdr(host)]
+    http-request set-header X-Fwd-Port %[dst_port]
+    http-request set-header X-Original-Forwarded-For %[var(txn.fwd_xff)] if { var(txn.fwd_xff) -m found }
+    http-request del-header X-Original-Forwarded-For if !{ var(txn.fwd_xff) -m found }
+    http-request set-header Host app.internal
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(txn.fwd_xff) req.fhdr(x-forwarded-for) if { req.hdr(x-forwarded-for) -m found }
+    http-request del-header x-forwarded-for
+    option forwardfor
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    http-request set-var(txn.fwd_xff) req.fhdr(x-forwarded-for) if { req.hdr(x-forwarded-for) -m found }
+    http-request del-header x-forwarded-for
+    option forwardfor
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`,
+		},
+		"trusted": {
+			forwardFor: "update",
+			expected: `
+backend d1_app_8080
+    mode http
+    http-request set-header X-Forwarded-Host %[req.hdr(host)] if !{ req.hdr(X-Forwarded-Host) -m found }
+    http-request set-header X-Fwd-Port %[dst_port] if !{ req.hdr(X-Fwd-Port) -m found }
+    http-request set-header X-Original-Forwarded-For %[var(txn.fwd_xff)] if { var(txn.fwd_xff) -m found } !{ req.hdr(X-Original-Forwarded-For) -m found }
+    http-request set-header Host app.internal
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(txn.fwd_xff) req.fhdr(x-forwarded-for) if { req.hdr(x-forwarded-for) -m found }
+    option forwardfor
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    http-request set-var(txn.fwd_xff) req.fhdr(x-forwarded-for) if { req.hdr(x-forwarded-for) -m found }
+    option forwardfor
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`,
+		},
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := setup(t)
+			defer c.teardown()
+
+			c.config.global.ForwardFor = test.forwardFor
+
+			var h *hatypes.Host
+			var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+			h = c.config.Hosts().AcquireHost("d1.local")
+			h.AddPath(b, "/", hatypes.MatchBegin)
+			b.ForwardedHeaders = hatypes.BackendForwardedHeaders{
+				Host:        "X-Forwarded-Host",
+				OriginalFor: "X-Original-Forwarded-For",
+				Port:        "X-Fwd-Port",
+			}
+			// the forwarded host is the original one, despite of a Host rewrite
+			b.Headers = []*hatypes.BackendHeader{{Name: "Host", Value: "app.internal"}}
+			b.Endpoints = []*hatypes.Endpoint{endpointS1}
+
+			b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+			h = c.config.Hosts().AcquireHost("d2.local")
+			h.AddPath(b, "/", hatypes.MatchBegin)
+			b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+			c.Update()
+			c.checkConfig("\n<<global>>\n<<defaults>>" + test.expected)
+			c.logger.CompareLogging(defaultLogging)
+		})
+	}
+}
+
+func TestInstanceTrafficClasses(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.Global().TrafficClasses = []*hatypes.TrafficClass{
+		{Name: "internal", Criteria: []hatypes.TrafficClassCriterion{
+			{Fetch: "src", Values: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+		}},
+		{Name: "bots", Criteria: []hatypes.TrafficClassCriterion{
+			{Fetch: "req.hdr(User-Agent)", Match: "reg", Values: []string{"(bot|crawler)"}},
+		}},
+		{Name: "api", Criteria: []hatypes.TrafficClassCriterion{
+			{Fetch: "path", Match: "beg", Values: []string{"/api/"}},
+			{Fetch: "src", Values: []string{"10.0.0.0/8"}},
+		}},
+		{Name: "partners", Criteria: []hatypes.TrafficClassCriterion{
+			{Fetch: "src", Values: []string{
+				"172.16.0.1", "172.16.0.2", "172.16.0.3", "172.16.0.4", "172.16.0.5", "172.16.0.6",
+				"172.16.0.7", "172.16.0.8", "172.16.0.9", "172.16.0.10", "172.16.0.11",
+			}},
+		}},
+	}
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Limit.RPS = 10
+	b.TrafficClass = hatypes.BackendTrafficClass{
+		Deny:        []string{"bots"},
+		LimitExempt: []string{"internal"},
+		Routes: []hatypes.BackendClassRoute{
+			{Class: "api", Target: hatypes.BackendID{Namespace: "d1", Name: "apiv2", Port: "8080"}},
+		},
+	}
+
+	b = c.config.Backends().AcquireBackend("d1", "apiv2", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS21}
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_apiv2_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+backend d1_app_8080
+    mode http
+    stick-table type ip size 200k expire 5m store conn_cur,conn_rate(1s)
+    http-request track-sc1 src
+    http-request deny deny_status 429 if !{ var(txn.class_internal) -m bool } { sc1_conn_rate gt 10 }
+    http-request deny if { var(txn.class_bots) -m bool }
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(txn.class_internal) bool(true) if { src 10.0.0.0/8 192.168.0.0/16 }
+    http-request set-var(txn.class_bots) bool(true) if { req.hdr(User-Agent) -m reg (bot|crawler) }
+    http-request set-var(txn.class_api) bool(true) if { path -m beg /api/ } { src 10.0.0.0/8 }
+    http-request set-var(txn.class_partners) bool(true) if { src -f /etc/haproxy/maps/_front_class_partners_6237afde.list }
+    use_backend d1_apiv2_8080 if { var(req.backend) -m str d1_app_8080 } { var(txn.class_api) -m bool }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    <<https-headers>>
+    http-request set-var(txn.class_internal) bool(true) if { src 10.0.0.0/8 192.168.0.0/16 }
+    http-request set-var(txn.class_bots) bool(true) if { req.hdr(User-Agent) -m reg (bot|crawler) }
+    http-request set-var(txn.class_api) bool(true) if { path -m beg /api/ } { src 10.0.0.0/8 }
+    http-request set-var(txn.class_partners) bool(true) if { src -f /etc/haproxy/maps/_front_class_partners_6237afde.list }
+    use_backend d1_apiv2_8080 if { var(req.hostbackend) -m str d1_app_8080 } { var(txn.class_api) -m bool }
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    default_backend _error404
+<<support>>
+`)
+	c.checkMap("_front_class_partners_6237afde.list", `
+172.16.0.1
+172.16.0.2
+172.16.0.3
+172.16.0.4
+172.16.0.5
+172.16.0.6
+172.16.0.7
+172.16.0.8
+172.16.0.9
+172.16.0.10
+172.16.0.11`)
+	metrics := c.instance.metrics.(*helper_test.MetricsMock)
+	if metrics.ACLListsSpilled["traffic-class"] != 1 {
+		t.Errorf("expected one spilled traffic class list: %v", metrics.ACLListsSpilled)
+	}
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceHTTPProtocol(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.Global().HTTPProtocol.ExemptPaths = []string{"/healthz", "/ping"}
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.HTTPProtocol.BlockHTTP10 = true
+	h = c.config.Hosts().AcquireHost("*.d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.HTTPProtocol.BlockHTTP10 = true
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h = c.config.Hosts().AcquireHost(hatypes.DefaultHost)
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.HTTPProtocol.RequireHostHeader = true
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    acl http_protocol_exempt path /healthz /ping
+    http-request deny deny_status 400 if { req.ver 1.0 } { var(req.host),map_str(/etc/haproxy/maps/_front_block_http10__exact.map) -m found } !http_protocol_exempt
+    http-request deny deny_status 400 if { req.ver 1.0 } { var(req.host),map_reg(/etc/haproxy/maps/_front_block_http10__regex.map) -m found } !http_protocol_exempt
+    http-request deny deny_status 400 if !{ req.hdr(host) -m found } !http_protocol_exempt
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.defaultbackend) str(<default>\#),concat(,req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    use_backend %[var(req.defaultbackend)]
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    <<set-req-base>>
+    acl http_protocol_exempt path /healthz /ping
+    http-request deny deny_status 400 if { req.ver 1.0 } { var(req.host),map_str(/etc/haproxy/maps/_front_block_http10__exact.map) -m found } !http_protocol_exempt
+    http-request deny deny_status 400 if { req.ver 1.0 } { var(req.host),map_reg(/etc/haproxy/maps/_front_block_http10__regex.map) -m found } !http_protocol_exempt
+    http-request deny deny_status 400 if !{ req.hdr(host) -m found } !http_protocol_exempt
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.defaultbackend) str(<default>\#),concat(,req.path),lower,map_beg(/etc/haproxy/maps/_front_defaulthost__begin.map) if !{ var(req.hostbackend) -m found }
+    <<https-headers>>
+    use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
+    use_backend %[var(req.defaultbackend)]
+    default_backend _error404
+<<support>>
+`)
+	c.checkMap("_front_block_http10__exact.map", `
+d1.local true`)
+	c.checkMap("_front_block_http10__regex.map", `
+^[^.]+\.d2\.local$ true`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceQUIC(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.Global().QUIC = hatypes.QUICConfig{Bind: "quic4@:443", Port: 443}
+
+	var h *hatypes.Host
+	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h = c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSHash = "1"
+	h.QUIC.AltSvcMaxAge = 3600
+	h = c.config.Hosts().AcquireHost("*.d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSHash = "1"
+	h.QUIC.AltSvcMaxAge = 86400
+	h = c.config.Hosts().AcquireHost("d3.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSHash = "1"
+	h = c.config.Hosts().AcquireHost("d4.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.UseDefaultCrt = false
+	h.QUIC.AltSvcMaxAge = 3600
+
+	c.Update()
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    server s1 172.17.0.11:8080 weight 100
+<<backends-default>>
+frontend _front_http
+    mode http
+    bind :80
+    <<set-req-base>>
+    <<http-headers>>
+    http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
+    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    use_backend %[var(req.backend)] if { var(req.backend) -m found }
+    default_backend _error404
+frontend _front_https
+    mode http
+    bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
+    bind quic4@:443 ssl alpn h3 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
     <<set-req-base>>
+    http-request set-var(txn.altsvc) var(req.host),map_str(/etc/haproxy/maps/_front_quic_altsvc__exact.map)
+    http-request set-var(txn.altsvc) var(req.host),map_reg(/etc/haproxy/maps/_front_quic_altsvc__regex.map) if !{ var(txn.altsvc) -m found }
+    http-after-response set-header alt-svc "h3=\":443\"; ma=%[var(txn.altsvc)]" if { var(txn.altsvc) -m found } !{ res.hdr(alt-svc) -m found }
+    http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
+    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
     <<https-headers>>
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
     default_backend _error404
 <<support>>
 `)
-
-	c.checkMap("_front_sslpassthrough__exact.map", `
-d2.local d2_app_8080
-d3.local d3_app-ssl_8443
-`)
-	c.checkMap("_front_http_host__begin.map", `
-d2.local#/ _redirect_https
-d3.local#/app d3_app2-http_8080
-d3.local#/ d3_app1-http_8080
-`)
-	c.checkMap("_front_bind_crt.list", `
-/var/haproxy/ssl/certs/default.pem !*
-`)
-
+	c.checkMap("_front_quic_altsvc__exact.map", `
+d1.local 3600`)
+	c.checkMap("_front_quic_altsvc__regex.map", `
+^[^.]+\.d2\.local$ 86400`)
 	c.logger.CompareLogging(defaultLogging)
 }
 
-func TestInstanceRootRedirect(t *testing.T) {
+func TestInstanceSplitBackends(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
 
-	c.config.global.SSL.SSLRedirect = true
-
 	var h *hatypes.Host
 	var b = c.config.Backends().AcquireBackend("d1", "app", "8080")
-	h = c.config.Hosts().AcquireHost("*.d1.local")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
+	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
-	h.RootRedirect = "/app"
+	h.AddPath(b, "/api", hatypes.MatchBegin)
 	b.Endpoints = []*hatypes.Endpoint{endpointS1}
-
-	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
-	h = c.config.Hosts().AcquireHost("d2.local")
-	h.TLS.TLSFilename = "/var/haproxy/ssl/certs/default.pem"
-	h.TLS.TLSHash = "0"
-	h.AddPath(b, "/app1", hatypes.MatchBegin)
-	h.AddPath(b, "/app2", hatypes.MatchBegin)
-	h.RootRedirect = "/app1"
-	for _, path := range b.Paths {
-		path.SSLRedirect = true
+	b.FindBackendPath(h.FindPath("/")[0].Link).SplitBackends = []hatypes.BackendSplit{
+		{Target: hatypes.BackendID{Namespace: "d1", Name: "app-v2", Port: "8080"}, Weight: 20},
+		{Target: hatypes.BackendID{Namespace: "d1", Name: "app-v3", Port: "8080"}, Weight: 10},
 	}
+
+	b = c.config.Backends().AcquireBackend("d1", "app-v2", "8080")
 	b.Endpoints = []*hatypes.Endpoint{endpointS21}
 
-	c.Update()
+	b = c.config.Backends().AcquireBackend("d1", "app-v3", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS31}
 
+	c.Update()
 	c.checkConfig(`
 <<global>>
 <<defaults>>
+backend d1_app-v2_8080
+    mode http
+    server s21 172.17.0.121:8080 weight 100
+backend d1_app-v3_8080
+    mode http
+    server s31 172.17.0.131:8080 weight 100
 backend d1_app_8080
     mode http
     server s1 172.17.0.11:8080 weight 100
-backend d2_app_8080
-    mode http
-    acl https-request ssl_fc
-    http-request redirect scheme https if !https-request
-    server s21 172.17.0.121:8080 weight 100
 <<backends-default>>
 frontend _front_http
     mode http
     bind :80
     <<set-req-base>>
-    http-request redirect scheme https if { path / } { var(req.host) -i -m str -f /etc/haproxy/maps/_front_redir_root_ssl__exact.map }
-    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
-    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
-    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
     <<http-headers>>
     http-request set-var(req.backend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_http_host__begin.map)
-    http-request set-var(req.backend) var(req.base),map_reg(/etc/haproxy/maps/_front_http_host__regex.map) if !{ var(req.backend) -m found }
+    http-request set-var(req.splitpath) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_split_path__begin.map)
+    http-request set-var(req.splitrand) rand(100) if { var(req.splitpath) -m found } !{ var(req.splitpath) -m str - }
+    use_backend d1_app-v2_8080 if { var(req.splitpath) -m str d1_app_8080_path01 } { var(req.splitrand) -m int 0:19 }
+    use_backend d1_app-v3_8080 if { var(req.splitpath) -m str d1_app_8080_path01 } { var(req.splitrand) -m int 20:29 }
     use_backend %[var(req.backend)] if { var(req.backend) -m found }
     default_backend _error404
 frontend _front_https
@@ -4072,43 +6441,19 @@ frontend _front_https
     bind :443 ssl alpn h2,http/1.1 crt-list /etc/haproxy/maps/_front_bind_crt.list ca-ignore-err all crt-ignore-err all
     <<set-req-base>>
     http-request set-var(req.hostbackend) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_https_host__begin.map)
-    http-request set-var(req.hostbackend) var(req.base),map_reg(/etc/haproxy/maps/_front_https_host__regex.map) if !{ var(req.hostbackend) -m found }
-    http-request set-var(req.rootredir) var(req.host),map_str(/etc/haproxy/maps/_front_redir_fromroot__exact.map)
-    http-request set-var(req.rootredir) var(req.host),map_reg(/etc/haproxy/maps/_front_redir_fromroot__regex.map) if !{ var(req.rootredir) -m found }
-    http-request redirect location %[var(req.rootredir)] if { path / } { var(req.rootredir) -m found }
     <<https-headers>>
+    http-request set-var(req.splitpath) var(req.base),lower,map_beg(/etc/haproxy/maps/_front_split_path__begin.map)
+    http-request set-var(req.splitrand) rand(100) if { var(req.splitpath) -m found } !{ var(req.splitpath) -m str - }
+    use_backend d1_app-v2_8080 if { var(req.splitpath) -m str d1_app_8080_path01 } { var(req.splitrand) -m int 0:19 }
+    use_backend d1_app-v3_8080 if { var(req.splitpath) -m str d1_app_8080_path01 } { var(req.splitrand) -m int 20:29 }
     use_backend %[var(req.hostbackend)] if { var(req.hostbackend) -m found }
     default_backend _error404
 <<support>>
 `)
-
-	c.checkMap("_front_http_host__regex.map", `
-^[^.]+\.d1\.local#/ d1_app_8080
-`)
-	c.checkMap("_front_http_host__begin.map", `
-d2.local#/app2 d2_app_8080
-d2.local#/app1 d2_app_8080
-`)
-	c.checkMap("_front_http_host__regex.map", `
-^[^.]+\.d1\.local#/ d1_app_8080
-`)
-	c.checkMap("_front_redir_fromroot__exact.map", `
-d2.local /app1
-`)
-	c.checkMap("_front_redir_root_ssl__exact.map", `
-d2.local
-`)
-	c.checkMap("_front_redir_fromroot__regex.map", `
-^[^.]+\.d1\.local$ /app
-`)
-	c.checkMap("_front_https_host__begin.map", `
-d2.local#/app2 d2_app_8080
-d2.local#/app1 d2_app_8080
-`)
-	c.checkMap("_front_https_host__regex.map", `
-^[^.]+\.d1\.local#/ d1_app_8080
+	c.checkMap("_front_split_path__begin.map", `
+d1.local#/api -
+d1.local#/ d1_app_8080_path01
 `)
-
 	c.logger.CompareLogging(defaultLogging)
 }
 
@@ -4260,6 +6605,32 @@ sub.d2.local d2.local
 			expMaps: map[string]string{
 				"_front_redir_from__regex.map": `
 ^[^.]+\.d1\.local$ d1.local
+`,
+			},
+		},
+		// 3
+		{
+			data: [3]hatypes.HostRedirectConfig{
+				{RedirectHost: "www.d1.local", HTTPSPort: 8443},
+				{RedirectHost: "www.d2.local"},
+				{HTTPSPort: 9443},
+			},
+			code: 301,
+			expHTTP: `
+    http-request set-var(req.redirdest) var(req.host),map_str(/etc/haproxy/maps/_front_redir_from__exact.map) if !{ var(req.backend) -m found }
+    http-request redirect prefix //%[var(req.redirdest)] code 301 if { var(req.redirdest) -m found }`,
+			expHTTPS: `
+    http-request set-var(req.redirdest) var(req.host),map_str(/etc/haproxy/maps/_front_redir_from__exact.map) if !{ var(req.hostbackend) -m found }
+    http-request set-var(req.redirport) var(req.redirdest),map_str(/etc/haproxy/maps/_front_redir_from_port__exact.map) if { var(req.redirdest) -m found }
+    http-request set-var(req.redirdest) var(req.redirdest),concat(:,req.redirport) if { var(req.redirport) -m found }
+    http-request redirect prefix //%[var(req.redirdest)] code 301 if { var(req.redirdest) -m found }`,
+			expMaps: map[string]string{
+				"_front_redir_from__exact.map": `
+www.d1.local d1.local
+www.d2.local d2.local
+`,
+				"_front_redir_from_port__exact.map": `
+d1.local 8443
 `,
 			},
 		},
@@ -4633,12 +7004,14 @@ func TestDNS(t *testing.T) {
 	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
 	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
 	b.Resolver = "k8s"
+	b.DNS.ResolvePrefer = "ipv4"
 	h = c.config.Hosts().AcquireHost("d1.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
 
 	b = c.config.Backends().AcquireBackend("d2", "app", "http")
 	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
 	b.Resolver = "k8s"
+	b.DNS = hatypes.BackendDNS{HoldValid: "30s", ResolvePrefer: "ipv6", Slots: 8}
 	h = c.config.Hosts().AcquireHost("d2.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
 
@@ -4646,6 +7019,7 @@ func TestDNS(t *testing.T) {
 	b.DNSPort = "named"
 	b.Endpoints = []*hatypes.Endpoint{endpointS21, endpointS22}
 	b.Resolver = "k8s"
+	b.DNS = hatypes.BackendDNS{HoldValid: "30s", ResolvePrefer: "ipv4"}
 	h = c.config.Hosts().AcquireHost("d3.local")
 	h.AddPath(b, "/", hatypes.MatchBegin)
 
@@ -4661,15 +7035,94 @@ resolvers k8s
     hold obsolete         0s
     hold valid            1s
     timeout retry         2s
+resolvers k8s_hold_30s
+    nameserver coredns1 10.0.1.11
+    nameserver coredns2 10.0.1.12
+    nameserver coredns3 10.0.1.13
+    accepted_payload_size 8192
+    hold obsolete         0s
+    hold valid            30s
+    timeout retry         2s
 backend d1_app_8080
     mode http
     server-template srv 2 app.d1.svc.cluster.local:8080 resolvers k8s resolve-prefer ipv4 init-addr none weight 1
 backend d2_app_http
     mode http
-    server-template srv 2 _http._tcp.app.d2.svc.cluster.local resolvers k8s resolve-prefer ipv4 init-addr none weight 1
+    server-template srv 8 _http._tcp.app.d2.svc.cluster.local resolvers k8s_hold_30s resolve-prefer ipv6 init-addr none weight 1
 backend d3_app_http
     mode http
-    server-template srv 2 _named._tcp.app.d3.svc.cluster.local resolvers k8s resolve-prefer ipv4 init-addr none weight 1
+    server-template srv 2 _named._tcp.app.d3.svc.cluster.local resolvers k8s_hold_30s resolve-prefer ipv4 init-addr none weight 1
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestPeers(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.Global().Peers = hatypes.PeersConfig{
+		Name:      "ingress",
+		LocalPeer: "haproxy-a",
+		Port:      10000,
+		Servers: []hatypes.PeerServer{
+			{Name: "haproxy-b", Endpoint: "10.0.0.2:10000"},
+			{Name: "haproxy-c", Endpoint: "10.0.0.3:10000"},
+		},
+	}
+
+	b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Limit.RPS = 10
+	b.Limit.Peers = "ingress"
+	h := c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	b = c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Limit.RPS = 10
+	h = c.config.Hosts().AcquireHost("d2.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.Update()
+	c.checkConfig(`
+global
+    daemon
+    unix-bind mode 0600
+    stats socket /var/run/haproxy.sock level admin expose-fd listeners mode 600
+    maxconn 2000
+    localpeer haproxy-a
+    hard-stop-after 15m
+    lua-prepend-path /etc/haproxy/lua/?.lua
+    lua-load /etc/haproxy/lua/auth-request.lua
+    lua-load /etc/haproxy/lua/services.lua
+    lua-load /etc/haproxy/lua/responses.lua
+    ssl-dh-param-file /var/haproxy/tls/dhparam.pem
+    ssl-default-bind-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-bind-ciphersuites TLS_AES_128_GCM_SHA256
+    ssl-default-bind-options no-sslv3
+    ssl-default-server-ciphers ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES128-GCM-SHA256
+    ssl-default-server-ciphersuites TLS_AES_128_GCM_SHA256
+<<defaults>>
+peers ingress
+    bind :10000
+    server haproxy-a
+    server haproxy-b 10.0.0.2:10000
+    server haproxy-c 10.0.0.3:10000
+backend d1_app_8080
+    mode http
+    stick-table type ip size 200k expire 5m store conn_cur,conn_rate(1s) peers ingress
+    http-request track-sc1 src
+    http-request deny deny_status 429 if { sc1_conn_rate gt 10 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    stick-table type ip size 200k expire 5m store conn_cur,conn_rate(1s)
+    http-request track-sc1 src
+    http-request deny deny_status 429 if { sc1_conn_rate gt 10 }
+    server s1 172.17.0.11:8080 weight 100
 <<backends-default>>
 <<frontends-default>>
 <<support>>
@@ -4677,6 +7130,49 @@ backend d3_app_http
 	c.logger.CompareLogging(defaultLogging)
 }
 
+// TestPeersReplicatedCounters documents how a global rate limit scope behaves
+// with two replicas. Peers copy the stick table entries between the replicas,
+// the last update wins, so the counters are replicated and not summed: the
+// table is declared just like a local one, and every replica compares the
+// whole limit with the counters of the connections it received.
+func TestPeersReplicatedCounters(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	c.config.Global().Peers = hatypes.PeersConfig{
+		Name:      "ingress",
+		LocalPeer: "haproxy-a",
+		Port:      10000,
+		Servers: []hatypes.PeerServer{
+			{Name: "haproxy-b", Endpoint: "10.0.0.2:10000"},
+		},
+	}
+
+	b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.Limit.Connections = 5
+	b.Limit.RPS = 10
+	b.Limit.Peers = "ingress"
+	h := c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+
+	c.Update()
+	cfg := c.readConfig(c.tempdir + "/haproxy.cfg")
+	c.containsText("peers", cfg, `
+peers ingress
+    bind :10000
+    server haproxy-a
+    server haproxy-b 10.0.0.2:10000`)
+	c.containsText("backend", cfg, `
+backend d1_app_8080
+    mode http
+    stick-table type ip size 200k expire 5m store conn_cur,conn_rate(1s) peers ingress
+    http-request track-sc1 src
+    http-request deny deny_status 429 if { sc1_conn_cur gt 5 }
+    http-request deny deny_status 429 if { sc1_conn_rate gt 10 }`)
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestUserlist(t *testing.T) {
 	type list struct {
 		name  string
@@ -5162,6 +7658,177 @@ backend d1_app_8080
 	}
 }
 
+func TestInstanceSPOEAgents(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	b1 := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b1.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b1.SPOE = hatypes.BackendSPOE{Agent: "risk", DenyOnError: true}
+	c.config.Hosts().AcquireHost("d1.local").AddPath(b1, "/", hatypes.MatchBegin)
+	b2 := c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b2.Endpoints = []*hatypes.Endpoint{endpointS21}
+	b2.SPOE = hatypes.BackendSPOE{Agent: "risk"}
+	c.config.Hosts().AcquireHost("d2.local").AddPath(b2, "/", hatypes.MatchBegin)
+
+	timeout := hatypes.SPOETimeoutConfig{Connect: "5s", Server: "5s", Hello: "100ms", Idle: "30s", Processing: "1s"}
+	c.config.Global().SPOEAgents = []*hatypes.SPOEAgent{
+		{Name: "ipcheck", Endpoints: []string{"10.0.0.20:9000"}, Messages: []string{"check-ip"}, VarPrefix: "ipcheck", Timeout: timeout, ModeSPOP: true},
+		{Name: "risk", Endpoints: []string{"10.0.0.10:9000", "10.0.0.11:9000"}, Messages: []string{"score-req", "score-ip"}, Args: []string{"src", "path"}, VarPrefix: "risk", Timeout: timeout},
+	}
+	c.Update()
+
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    filter spoe engine risk config /etc/haproxy/spoe-agents.conf
+    http-request deny deny_status 500 if { var(txn.risk.error) -m int gt 0 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    filter spoe engine risk config /etc/haproxy/spoe-agents.conf
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+backend spoe-ipcheck
+    mode spop
+    timeout connect 5s
+    timeout server  5s
+    server spoa0 10.0.0.20:9000
+backend spoe-risk
+    mode tcp
+    timeout connect 5s
+    timeout server  5s
+    server spoa0 10.0.0.10:9000
+    server spoa1 10.0.0.11:9000
+`)
+
+	c.checkConfigFile(`
+[ipcheck]
+spoe-agent ipcheck-agent
+    messages     check-ip
+    option       var-prefix  ipcheck
+    option       set-on-error  error
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  1s
+    use-backend  spoe-ipcheck
+    log          global
+    option       dontlog-normal
+spoe-message check-ip
+    event  on-backend-http-request
+[risk]
+spoe-agent risk-agent
+    messages     score-req score-ip
+    option       var-prefix  risk
+    option       set-on-error  error
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  1s
+    use-backend  spoe-risk
+    log          global
+    option       dontlog-normal
+spoe-message score-req
+    args   src path
+    event  on-backend-http-request
+spoe-message score-ip
+    args   src path
+    event  on-backend-http-request
+`, "spoe-agents.conf")
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
+func TestInstanceSPOEAgentsModSecurity(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	b1 := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b1.Endpoints = []*hatypes.Endpoint{endpointS1}
+	h1 := c.config.Hosts().AcquireHost("d1.local")
+	h1.AddPath(b1, "/", hatypes.MatchBegin)
+	b1.FindBackendPath(h1.FindPath("/")[0].Link).WAF = hatypes.WAF{Module: "modsecurity", Mode: "deny"}
+	b2 := c.config.Backends().AcquireBackend("d2", "app", "8080")
+	b2.Endpoints = []*hatypes.Endpoint{endpointS21}
+	b2.SPOE = hatypes.BackendSPOE{Agent: "risk"}
+	c.config.Hosts().AcquireHost("d2.local").AddPath(b2, "/", hatypes.MatchBegin)
+
+	timeout := hatypes.SPOETimeoutConfig{Connect: "5s", Server: "5s", Hello: "100ms", Idle: "30s", Processing: "1s"}
+	globalModsec := &c.config.Global().ModSecurity
+	globalModsec.Endpoints = []string{"10.0.0.101:12345"}
+	globalModsec.Timeout = timeout
+	globalModsec.Args = []string{"unique-id", "method"}
+	c.config.Global().SPOEAgents = []*hatypes.SPOEAgent{
+		{Name: "risk", Endpoints: []string{"10.0.0.10:9000"}, Messages: []string{"score-req"}, VarPrefix: "risk", Timeout: timeout},
+	}
+	c.Update()
+
+	c.checkConfig(`
+<<global>>
+<<defaults>>
+backend d1_app_8080
+    mode http
+    filter spoe engine modsecurity config /etc/haproxy/spoe-modsecurity.conf
+    http-request deny if { var(txn.modsec.code) -m int gt 0 }
+    server s1 172.17.0.11:8080 weight 100
+backend d2_app_8080
+    mode http
+    filter spoe engine risk config /etc/haproxy/spoe-agents.conf
+    server s21 172.17.0.121:8080 weight 100
+<<backends-default>>
+<<frontends-default>>
+<<support>>
+backend spoe-modsecurity
+    mode tcp
+    timeout connect 5s
+    timeout server  5s
+    server modsec-spoa0 10.0.0.101:12345
+backend spoe-risk
+    mode tcp
+    timeout connect 5s
+    timeout server  5s
+    server spoa0 10.0.0.10:9000
+`)
+
+	// set-on-error is only added to the declared agents, the modsecurity
+	// agent keeps its own file and the configuration it always had
+	c.checkConfigFile(`
+[modsecurity]
+spoe-agent modsecurity-agent
+    messages     check-request
+    option       var-prefix  modsec
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  1s
+    use-backend  spoe-modsecurity
+    log          global
+    option       dontlog-normal
+spoe-message check-request
+    args   unique-id method
+    event  on-backend-http-request
+`, "spoe-modsecurity.conf")
+	c.checkConfigFile(`
+[risk]
+spoe-agent risk-agent
+    messages     score-req
+    option       var-prefix  risk
+    option       set-on-error  error
+    timeout      hello       100ms
+    timeout      idle        30s
+    timeout      processing  1s
+    use-backend  spoe-risk
+    log          global
+    option       dontlog-normal
+spoe-message score-req
+    event  on-backend-http-request
+`, "spoe-agents.conf")
+
+	c.logger.CompareLogging(defaultLogging)
+}
+
 func TestInstanceWildcardHostname(t *testing.T) {
 	c := setup(t)
 	defer c.teardown()
@@ -5411,8 +8078,10 @@ type testConfig struct {
 }
 
 type testOptions struct {
-	t          *testing.T
-	shardCount int
+	t           *testing.T
+	shardCount  int
+	partitioned bool
+	orphanFiles bool
 }
 
 func setup(t *testing.T) *testConfig {
@@ -5430,11 +8099,25 @@ func setupOptions(options testOptions) *testConfig {
 	if err != nil {
 		t.Errorf("error creating temp subdir: %v", err)
 	}
+	mapsDir := tempdir
+	var orphanFilesDirs []string
+	if options.orphanFiles {
+		mapsDir = filepath.Join(tempdir, "maps")
+		certsDir := filepath.Join(tempdir, "certs")
+		for _, dir := range []string{mapsDir, certsDir} {
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Errorf("error creating temp subdir: %v", err)
+			}
+		}
+		orphanFilesDirs = []string{mapsDir, certsDir, tempdir}
+	}
 	instance := CreateInstance(logger, InstanceOptions{
-		HAProxyCfgDir:  tempdir,
-		HAProxyMapsDir: tempdir,
-		Metrics:        helper_test.NewMetricsMock(),
-		BackendShards:  options.shardCount,
+		HAProxyCfgDir:     tempdir,
+		HAProxyMapsDir:    mapsDir,
+		Metrics:           helper_test.NewMetricsMock(),
+		BackendShards:     options.shardCount,
+		PartitionBackends: options.partitioned,
+		OrphanFilesDirs:   orphanFilesDirs,
 		//
 		fake: true,
 	}).(*instance)
@@ -5483,6 +8166,24 @@ func setupOptions(options testOptions) *testConfig {
 	); err != nil {
 		t.Errorf("error parsing modsecurity.tmpl: %v", err)
 	}
+	if err := instance.spoeTmpl.NewTemplate(
+		"spoe.tmpl",
+		"../../rootfs/etc/templates/spoe/spoe.tmpl",
+		filepath.Join(tempdir, "spoe-agents.conf"),
+		0,
+		1024,
+	); err != nil {
+		t.Errorf("error parsing spoe.tmpl: %v", err)
+	}
+	if err := instance.auditTmpl.NewTemplate(
+		"audit.tmpl",
+		"../../rootfs/etc/templates/audit/audit.tmpl",
+		filepath.Join(tempdir, "spoe-audit.conf"),
+		0,
+		1024,
+	); err != nil {
+		t.Errorf("error parsing audit.tmpl: %v", err)
+	}
 	config := instance.Config().(*config)
 	config.frontend.DefaultCrtFile = "/var/haproxy/ssl/certs/default.pem"
 	c := &testConfig{
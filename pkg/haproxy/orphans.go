@@ -0,0 +1,175 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// orphanTrashDir is the subdirectory of the orphan files directories where
+// unreferenced files wait for the next successful reload before being removed.
+const orphanTrashDir = ".trash"
+
+// orphanFilesDirs lists the directories whose unreferenced files should be
+// removed. The configuration directory is never cleaned up, its top level
+// files are the configuration files themselves.
+func (i *instance) orphanFilesDirs() []string {
+	cfgDir := filepath.Clean(i.options.HAProxyCfgDir)
+	var dirs []string
+	for _, dir := range i.options.OrphanFilesDirs {
+		if dir = filepath.Clean(dir); dir != cfgDir {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// collectOrphanFiles moves the files of the orphan files directories that
+// the loaded configuration doesn't refer to to the trash subdirectory. The
+// trash content of the former call is removed: its files were not used by
+// the haproxy instance replaced by the last reload. The first call after
+// the controller starts only lists the files that would be moved, so an
+// unexpected removal can be spotted in the logs before it happens.
+func (i *instance) collectOrphanFiles() {
+	dirs := i.orphanFilesDirs()
+	if len(dirs) == 0 {
+		return
+	}
+	referenced, err := i.referencedFiles(dirs)
+	if err != nil {
+		i.logger.Warn("error reading the referenced files, skipping orphan files cleanup: %v", err)
+		return
+	}
+	for _, file := range i.options.OrphanFilesKeep {
+		referenced[filepath.Clean(file)] = true
+	}
+	var orphans []string
+	for _, dir := range dirs {
+		if i.orphansListed {
+			if err := os.RemoveAll(filepath.Join(dir, orphanTrashDir)); err != nil {
+				i.logger.Warn("error removing orphan files: %v", err)
+			}
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				i.logger.Warn("error reading orphan files directory: %v", err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if entry.Type().IsRegular() && !referenced[path] {
+				orphans = append(orphans, path)
+			}
+		}
+	}
+	if len(orphans) == 0 {
+		i.orphansListed = true
+		return
+	}
+	if !i.orphansListed {
+		i.orphansListed = true
+		i.logger.Info("found %d file(s) not referenced by the configuration, they will be removed after the next reloads: %s",
+			len(orphans), strings.Join(orphans, ","))
+		return
+	}
+	var moved []string
+	for _, path := range orphans {
+		trash := filepath.Join(filepath.Dir(path), orphanTrashDir)
+		if err := os.MkdirAll(trash, 0700); err != nil {
+			i.logger.Warn("error moving orphan file: %v", err)
+			continue
+		}
+		if err := os.Rename(path, filepath.Join(trash, filepath.Base(path))); err != nil {
+			i.logger.Warn("error moving orphan file: %v", err)
+			continue
+		}
+		moved = append(moved, path)
+	}
+	i.logger.Info("moved %d file(s) not referenced by the configuration to trash: %s", len(moved), strings.Join(moved, ","))
+}
+
+// referencedFiles lists the files of dirs that the configuration files
+// refer to, either directly or via a list of the maps directory, eg the
+// certificates of the crt-list.
+func (i *instance) referencedFiles(dirs []string) (map[string]bool, error) {
+	cfgDir := i.options.HAProxyCfgDir
+	entries, err := os.ReadDir(cfgDir)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !isRotatedConfig(entry.Name()) {
+			pending = append(pending, filepath.Join(cfgDir, entry.Name()))
+		}
+	}
+	mapsDir := filepath.Clean(i.options.HAProxyMapsDir)
+	referenced := map[string]bool{}
+	for len(pending) > 0 {
+		path := pending[0]
+		pending = pending[1:]
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			// referenced but missing, nothing to look for
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range findFileRefs(string(content), dirs) {
+			if !referenced[file] {
+				referenced[file] = true
+				if filepath.Dir(file) == mapsDir {
+					pending = append(pending, file)
+				}
+			}
+		}
+	}
+	for _, file := range i.configCertFiles() {
+		referenced[filepath.Clean(file)] = true
+	}
+	return referenced, nil
+}
+
+// findFileRefs lists the paths found in content that point to a file
+// directly in one of the dirs. A path ends in a space, quote, or in the
+// comma and parenthesis of a converter, eg map(<path>,<default>).
+func findFileRefs(content string, dirs []string) []string {
+	var refs []string
+	for _, dir := range dirs {
+		prefix := dir + "/"
+		for pos := strings.Index(content, prefix); pos >= 0; {
+			ref := content[pos:]
+			if end := strings.IndexAny(ref, " \t\r\n,)\"'"); end >= 0 {
+				ref = ref[:end]
+			}
+			if filepath.Dir(ref) == dir {
+				refs = append(refs, ref)
+			}
+			next := strings.Index(content[pos+len(prefix):], prefix)
+			if next < 0 {
+				break
+			}
+			pos += len(prefix) + next
+		}
+	}
+	return refs
+}
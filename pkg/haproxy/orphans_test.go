@@ -0,0 +1,141 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+func TestOrphanFiles(t *testing.T) {
+	c := setupOptions(testOptions{t: t, orphanFiles: true})
+	defer c.teardown()
+
+	certsDir := filepath.Join(c.tempdir, "certs")
+	mapsDir := filepath.Join(c.tempdir, "maps")
+	c.instance.options.OrphanFilesKeep = []string{certsDir + "/_fake-default.pem"}
+	files := map[string]string{
+		"certs/_fake-default.pem": "fake",
+		"certs/d1_h1.pem":         "h1",
+		"certs/d1_h2.pem":         "h2",
+		"certs/d1_old.pem":        "old",
+		"maps/_back_old.map":      "old",
+		"maps/custom.list":        certsDir + "/d1_h2.pem\n",
+		"unknown.txt":             "cfg dir is never cleaned up",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(c.tempdir, name), []byte(content), 0600); err != nil {
+			t.Errorf("error writing file: %v", err)
+		}
+	}
+	listFiles := func() []string {
+		var files []string
+		_ = filepath.WalkDir(c.tempdir, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(c.tempdir, path)
+				if strings.HasPrefix(rel, "certs/") || strings.HasPrefix(rel, "maps/") || rel == "unknown.txt" {
+					files = append(files, rel)
+				}
+			}
+			return err
+		})
+		sort.Strings(files)
+		return files
+	}
+	compareFiles := func(step string, expected []string) {
+		if actual := listFiles(); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("files differ on %s - expected: %v, actual: %v", step, expected, actual)
+		}
+	}
+	compareLogging := func(expected string) {
+		logging := []string{}
+		for _, line := range c.logger.Logging {
+			if !strings.HasPrefix(line, "INFO-V(2) ") && line != "INFO (test) reload was skipped" {
+				logging = append(logging, strings.ReplaceAll(line, c.tempdir, "<tmp>"))
+			}
+		}
+		c.logger.Logging = logging
+		c.logger.CompareLogging(expected)
+	}
+
+	b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.CustomConfig = []string{"http-request deny if { ssl_c_used } !{ ssl_c_s_dn(cn) -f " + mapsDir + "/custom.list }"}
+	h := c.config.Hosts().AcquireHost("d1.local")
+	h.AddPath(b, "/", hatypes.MatchBegin)
+	h.TLS.TLSFilename = certsDir + "/d1_h1.pem"
+
+	// first reload after the startup only lists the orphan files
+	c.Update()
+	compareFiles("first reload", []string{
+		"certs/_fake-default.pem",
+		"certs/d1_h1.pem",
+		"certs/d1_h2.pem",
+		"certs/d1_old.pem",
+		"maps/_back_old.map",
+		"maps/_front_bind_crt.list",
+		"maps/_front_http_host__begin.map",
+		"maps/_front_https_host__begin.map",
+		"maps/custom.list",
+		"unknown.txt",
+	})
+	compareLogging(`
+INFO found 2 file(s) not referenced by the configuration, they will be removed after the next reloads: <tmp>/maps/_back_old.map,<tmp>/certs/d1_old.pem
+INFO haproxy successfully reloaded (embedded daemon)`)
+
+	// orphan files are moved to the trash
+	c.instance.Reload(utils.NewTimer(nil))
+	compareFiles("second reload", []string{
+		"certs/.trash/d1_old.pem",
+		"certs/_fake-default.pem",
+		"certs/d1_h1.pem",
+		"certs/d1_h2.pem",
+		"maps/.trash/_back_old.map",
+		"maps/_front_bind_crt.list",
+		"maps/_front_http_host__begin.map",
+		"maps/_front_https_host__begin.map",
+		"maps/custom.list",
+		"unknown.txt",
+	})
+	compareLogging(`
+INFO moved 2 file(s) not referenced by the configuration to trash: <tmp>/maps/_back_old.map,<tmp>/certs/d1_old.pem
+INFO haproxy successfully reloaded (embedded daemon)`)
+
+	// trash is removed, files of the removed host are moved to the trash
+	c.config.Hosts().RemoveAll([]string{"d1.local"})
+	c.Update()
+	compareFiles("third reload", []string{
+		"certs/.trash/d1_h1.pem",
+		"certs/_fake-default.pem",
+		"certs/d1_h2.pem",
+		"maps/.trash/_front_http_host__begin.map",
+		"maps/.trash/_front_https_host__begin.map",
+		"maps/_front_bind_crt.list",
+		"maps/custom.list",
+		"unknown.txt",
+	})
+	compareLogging(`
+INFO moved 3 file(s) not referenced by the configuration to trash: <tmp>/maps/_front_http_host__begin.map,<tmp>/maps/_front_https_host__begin.map,<tmp>/certs/d1_h1.pem
+INFO haproxy successfully reloaded (embedded daemon)`)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// reloadDeferCheck is the interval between two checks of a deferred reload.
+const reloadDeferCheck = 15 * time.Second
+
+// reloadDefer defers the reloads that are not urgent, eg endpoint only or
+// balance and timeout changes, while the reload sensitive backends have
+// long-lived sessions, so they are not moved to an old instance that will
+// be stopped. A deferred reload is made as soon as the sessions are closed,
+// an urgent change is applied, or the maximum deferral is reached.
+type reloadDefer struct {
+	logger types.Logger
+	stats  func() (map[string]backendStat, error)
+	now    func() time.Time
+	mutex  sync.Mutex
+	since  time.Time
+	next   time.Time
+}
+
+// check returns true if the reload should be deferred. backends are the IDs
+// of the reload sensitive backends.
+func (r *reloadDefer) check(urgent bool, cfg hatypes.ReloadDeferConfig, backends []string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if urgent || cfg.Sessions <= 0 || len(backends) == 0 {
+		r.reset()
+		return false
+	}
+	now := r.now()
+	if r.since.IsZero() {
+		r.since = now
+	}
+	remaining := cfg.MaxWait - now.Sub(r.since)
+	if remaining <= 0 {
+		r.logger.Info("reloading haproxy: deferred for %s, maximum deferral reached", now.Sub(r.since).Round(time.Second))
+		r.reset()
+		return false
+	}
+	stats, err := r.stats()
+	if err != nil {
+		r.logger.Warn("reloading haproxy: cannot read current sessions of reload sensitive backends: %v", err)
+		r.reset()
+		return false
+	}
+	var busy []string
+	for _, id := range backends {
+		if sessions := stats[id].sessions; sessions >= cfg.Sessions {
+			busy = append(busy, fmt.Sprintf("%s (%d)", id, sessions))
+		}
+	}
+	if len(busy) == 0 {
+		if now.After(r.since) {
+			r.logger.Info("reloading haproxy: sessions of reload sensitive backends were closed")
+		}
+		r.reset()
+		return false
+	}
+	wait := reloadDeferCheck
+	if remaining < wait {
+		wait = remaining
+	}
+	r.next = now.Add(wait)
+	r.logger.Info("deferring haproxy reload, reload sensitive backends have long-lived sessions: %s", strings.Join(busy, ", "))
+	return true
+}
+
+// pending returns true if a deferred reload should be checked again.
+func (r *reloadDefer) pending() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return !r.since.IsZero()
+}
+
+// nextCheck returns how long until a deferred reload should be checked again,
+// or zero if there is no deferred reload.
+func (r *reloadDefer) nextCheck() time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.since.IsZero() {
+		return 0
+	}
+	wait := r.next.Sub(r.now())
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+func (r *reloadDefer) reset() {
+	r.since = time.Time{}
+	r.next = time.Time{}
+}
+
+// NextReloadCheck returns how long until a deferred reload should be checked
+// again, or zero if there is no deferred reload, see reload-defer-sessions.
+func (i *instance) NextReloadCheck() time.Duration {
+	return i.reloadDefer.nextCheck()
+}
+
+// reloadSensitiveBackends returns the sorted IDs of the reload sensitive backends.
+func reloadSensitiveBackends(backends *hatypes.Backends) []string {
+	var ids []string
+	for _, backend := range backends.Items() {
+		if backend.ReloadSensitive {
+			ids = append(ids, backend.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestReloadDefer(t *testing.T) {
+	type check struct {
+		elapsed  time.Duration // since the last check
+		urgent   bool
+		sessions map[string]int
+		statsErr bool
+		deferred bool
+		next     time.Duration
+	}
+	cfg := hatypes.ReloadDeferConfig{Sessions: 10, MaxWait: time.Minute}
+	testCases := []struct {
+		cfg      hatypes.ReloadDeferConfig
+		backends []string
+		checks   []check
+		logging  string
+	}{
+		// 0
+		{
+			cfg:      hatypes.ReloadDeferConfig{},
+			backends: []string{"default_app_8080"},
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 100}},
+			},
+		},
+		// 1
+		{
+			cfg: cfg,
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 100}},
+			},
+		},
+		// 2
+		{
+			cfg:      cfg,
+			backends: []string{"default_app_8080"},
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 9, "default_other_8080": 100}},
+			},
+		},
+		// 3
+		{
+			cfg:      cfg,
+			backends: []string{"default_app_8080"},
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 10}, urgent: true},
+			},
+		},
+		// 4
+		{
+			cfg:      cfg,
+			backends: []string{"default_app_8080", "default_ws_8080"},
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 2, "default_ws_8080": 10}, deferred: true, next: 15 * time.Second},
+				{elapsed: 15 * time.Second, sessions: map[string]int{"default_ws_8080": 5}},
+			},
+			logging: `
+INFO deferring haproxy reload, reload sensitive backends have long-lived sessions: default_ws_8080 (10)
+INFO reloading haproxy: sessions of reload sensitive backends were closed`,
+		},
+		// 5
+		{
+			cfg:      cfg,
+			backends: []string{"default_app_8080"},
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 20}, deferred: true, next: 15 * time.Second},
+				{elapsed: 15 * time.Second, sessions: map[string]int{"default_app_8080": 20}, deferred: true, next: 15 * time.Second},
+				{elapsed: 35 * time.Second, sessions: map[string]int{"default_app_8080": 20}, deferred: true, next: 10 * time.Second},
+				{elapsed: 10 * time.Second, sessions: map[string]int{"default_app_8080": 20}},
+			},
+			logging: `
+INFO deferring haproxy reload, reload sensitive backends have long-lived sessions: default_app_8080 (20)
+INFO deferring haproxy reload, reload sensitive backends have long-lived sessions: default_app_8080 (20)
+INFO deferring haproxy reload, reload sensitive backends have long-lived sessions: default_app_8080 (20)
+INFO reloading haproxy: deferred for 1m0s, maximum deferral reached`,
+		},
+		// 6
+		{
+			cfg:      cfg,
+			backends: []string{"default_app_8080"},
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 20}, deferred: true, next: 15 * time.Second},
+				{elapsed: 5 * time.Second, sessions: map[string]int{"default_app_8080": 20}, urgent: true},
+				{elapsed: 5 * time.Second, sessions: map[string]int{"default_app_8080": 20}, deferred: true, next: 15 * time.Second},
+			},
+			logging: `
+INFO deferring haproxy reload, reload sensitive backends have long-lived sessions: default_app_8080 (20)
+INFO deferring haproxy reload, reload sensitive backends have long-lived sessions: default_app_8080 (20)`,
+		},
+		// 7
+		{
+			cfg:      cfg,
+			backends: []string{"default_app_8080"},
+			checks: []check{
+				{sessions: map[string]int{"default_app_8080": 20}, deferred: true, next: 15 * time.Second},
+				{elapsed: 15 * time.Second, statsErr: true},
+			},
+			logging: `
+INFO deferring haproxy reload, reload sensitive backends have long-lived sessions: default_app_8080 (20)
+WARN reloading haproxy: cannot read current sessions of reload sensitive backends: socket closed`,
+		},
+	}
+	for i, test := range testCases {
+		logger := &helper_test.LoggerMock{T: t}
+		now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+		var current check
+		r := &reloadDefer{
+			logger: logger,
+			now:    func() time.Time { return now },
+			stats: func() (map[string]backendStat, error) {
+				if current.statsErr {
+					return nil, fmt.Errorf("socket closed")
+				}
+				stats := map[string]backendStat{}
+				for id, sessions := range current.sessions {
+					stats[id] = backendStat{sessions: sessions}
+				}
+				return stats, nil
+			},
+		}
+		for j, chk := range test.checks {
+			current = chk
+			now = now.Add(chk.elapsed)
+			if deferred := r.check(chk.urgent, test.cfg, test.backends); deferred != chk.deferred {
+				t.Errorf("deferred expected as '%t' on %d/%d, but was '%t'", chk.deferred, i, j, deferred)
+			}
+			if next := r.nextCheck(); next != chk.next {
+				t.Errorf("next check expected as '%s' on %d/%d, but was '%s'", chk.next, i, j, next)
+			}
+		}
+		logger.CompareLogging(test.logging)
+	}
+}
@@ -0,0 +1,704 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/utils"
+)
+
+// RejectedBackend describes a backend whose configuration was identified
+// as the cause of a failed haproxy reload. haproxy was rolled back to the
+// last successfully loaded configuration.
+type RejectedBackend struct {
+	Backend string
+	Error   string
+}
+
+// configSnapshot is the content of the configuration files, maps and
+// certificates used by haproxy, indexed by the file path.
+type configSnapshot map[string][]byte
+
+func (i *instance) RejectedBackends() []RejectedBackend {
+	rejected := i.rejected
+	i.rejected = nil
+	return rejected
+}
+
+func (i *instance) LastReloadError() error {
+	return i.reloadErr
+}
+
+// trackChangedBackends keeps the last loaded state of the backends changed
+// since the last successful reload, so they can be reverted when
+// identifying a backend that haproxy refuses to load. A nil value means
+// that the backend didn't exist in the last loaded state. Backends rejected
+// by haproxy are retried as soon as they are changed or removed.
+func (i *instance) trackChangedBackends() {
+	backends := i.config.Backends()
+	for id, backend := range i.rejectedBackends {
+		_, added := backends.ItemsAdd()[id]
+		_, removed := backends.ItemsDel()[id]
+		if added || removed {
+			delete(i.rejectedBackends, id)
+			if _, found := i.changedBackends[id]; !found {
+				i.changedBackends[id] = backend
+			}
+		}
+	}
+	for id := range backends.ItemsAdd() {
+		if _, found := i.changedBackends[id]; !found {
+			i.changedBackends[id] = backends.ItemsDel()[id]
+		}
+	}
+}
+
+// trackChangedHosts keeps the last loaded state of the hosts changed since
+// the last successful reload, the same way trackChangedBackends does with
+// the backends. It should be called before the frontend maps are written,
+// so hosts rejected by haproxy are retried as soon as they change.
+func (i *instance) trackChangedHosts() {
+	hosts := i.config.Hosts()
+	for hostname, host := range i.rejectedHosts {
+		_, added := hosts.ItemsAdd()[hostname]
+		_, removed := hosts.ItemsDel()[hostname]
+		if added || removed {
+			delete(i.rejectedHosts, hostname)
+			if _, found := i.changedHosts[hostname]; !found {
+				i.changedHosts[hostname] = host
+			}
+		}
+	}
+	for hostname := range hosts.ItemsAdd() {
+		if _, found := i.changedHosts[hostname]; !found {
+			i.changedHosts[hostname] = hosts.ItemsDel()[hostname]
+		}
+	}
+}
+
+// writeFrontendMaps writes the frontend maps with the hosts rejected by
+// haproxy in their last loaded state. rebuild writes the maps even if no
+// host has changed, e.g. when hosts are temporarily reverted.
+func (i *instance) writeFrontendMaps(rebuild bool) error {
+	restore := i.config.Hosts().ReplaceHosts(i.rejectedHosts)
+	defer restore()
+	if rebuild {
+		i.config.Frontend().Maps = nil
+	}
+	return i.config.WriteFrontendMaps()
+}
+
+// managedFiles lists the files written by the instance, the only ones
+// that a snapshot saves and that a restore removes. Other files of the
+// instance directories, eg lua scripts and certificates mounted along with
+// the configuration, are left untouched.
+func (i *instance) managedFiles() ([]string, error) {
+	cfgDir := i.options.HAProxyCfgDir
+	mapsDir := i.options.HAProxyMapsDir
+	patterns := []string{
+		filepath.Join(cfgDir, "*.cfg"),
+		filepath.Join(cfgDir, "spoe-*.conf"),
+		filepath.Join(cfgDir, "errorfiles", "*.http"),
+		filepath.Join(cfgDir, "lua", "responses.lua"),
+		filepath.Join(mapsDir, "*.map"),
+		filepath.Join(mapsDir, "*.list"),
+	}
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func (i *instance) takeSnapshot() (configSnapshot, error) {
+	files, err := i.managedFiles()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := configSnapshot{}
+	for _, file := range append(files, i.configCertFiles()...) {
+		if _, found := snapshot[file]; !found {
+			if err := snapshot.add(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// configCertFiles lists the certificate related files, stored outside
+// of the instance directories, that the current configuration refers to.
+func (i *instance) configCertFiles() []string {
+	var files []string
+	add := func(filenames ...string) {
+		for _, filename := range filenames {
+			if filename != "" {
+				files = append(files, filename)
+			}
+		}
+	}
+	for _, host := range i.config.Hosts().Items() {
+		add(host.TLS.TLSFilename, host.TLS.CAFilename, host.TLS.CRLFilename)
+	}
+	for _, backend := range i.config.Backends().Items() {
+		add(backend.Server.CrtFilename, backend.Server.CAFilename, backend.Server.CRLFilename)
+	}
+	add(i.config.Frontend().DefaultCrtFile)
+	return files
+}
+
+func (s configSnapshot) add(path string) error {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// files referenced but not created, eg on tests, or removed
+		// in the meantime, are safe to ignore
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s[path] = content
+	return nil
+}
+
+// restoreSnapshot writes back the files of the snapshot, removing the files
+// written by the instance that didn't exist when the snapshot was taken.
+func (i *instance) restoreSnapshot(snapshot configSnapshot) error {
+	files, err := i.managedFiles()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if _, found := snapshot[file]; !found {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+	}
+	for path, content := range snapshot {
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isRotatedConfig(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "haproxy.cfg.")
+}
+
+func (i *instance) saveLastGood() {
+	snapshot, err := i.takeSnapshot()
+	if err != nil {
+		i.logger.Warn("error saving the last known good configuration: %v", err)
+		return
+	}
+	i.lastGood = snapshot
+	i.changedBackends = map[string]*hatypes.Backend{}
+	i.changedHosts = map[string]*hatypes.Host{}
+	i.reloadErr = nil
+}
+
+// rollback reloads haproxy with the last successfully loaded configuration
+// after haproxy rejected the current one. The rejected changes are then
+// validated with haproxy -c on a scratch directory, looking for the backend
+// files, the hosts of a partition, or the single backend, that caused the
+// failure. If found, haproxy
+// is reloaded once with the current model except them, which keep their
+// last loaded state until they change. Otherwise the rejected files are
+// written back after haproxy is restored, so the instance directories
+// continue to reflect the current model, whose maps are only written when
+// changed, and the next reload retries them.
+func (i *instance) rollback(timer *utils.Timer, reloadErr error) {
+	if i.lastGood == nil {
+		i.reloadErr = fmt.Errorf("haproxy rejected the configuration and there is no known good configuration to roll back to: %w", reloadErr)
+		return
+	}
+	rejected, err := i.takeSnapshot()
+	if err != nil {
+		i.reloadErr = fmt.Errorf("error reading the rejected configuration: %w", err)
+		i.logger.Error("error rolling back haproxy configuration: %v", i.reloadErr)
+		return
+	}
+	if err := i.reloadLastGood(); err != nil {
+		i.reloadErr = err
+		i.logger.Error("error rolling back haproxy configuration: %v", err)
+		_ = i.restoreSnapshot(rejected)
+		return
+	}
+	timer.Tick("rollback_haproxy")
+	i.logger.Warn("haproxy configuration rolled back to the last known good state")
+	// the rejected files are written back unless haproxy is reloaded
+	// without the rejected backend files, hosts or backend
+	writeBack := true
+	defer func() {
+		if !writeBack {
+			return
+		}
+		if err := i.restoreSnapshot(rejected); err != nil {
+			i.logger.Error("error writing back the rejected configuration: %v", err)
+		}
+	}()
+	if i.options.IsExternal {
+		// an external haproxy cannot validate a configuration from here
+		return
+	}
+	scratch, err := os.MkdirTemp("", "haproxy-check-")
+	if err != nil {
+		i.logger.Error("error creating the scratch directory to check the configuration: %v", err)
+		return
+	}
+	defer os.RemoveAll(scratch)
+	if i.options.PartitionBackends && i.options.BackendShards > 0 {
+		isolated := i.isolatePartitions(rejected, reloadErr, scratch)
+		timer.Tick("isolate_partitions")
+		if !isolated {
+			isolated = i.isolateHostPartitions(reloadErr, scratch)
+			timer.Tick("isolate_host_partitions")
+		}
+		if isolated {
+			writeBack = false
+			return
+		}
+	}
+	backend := i.bisectBackends(scratch)
+	timer.Tick("bisect_backends")
+	if backend == "" {
+		return
+	}
+	i.logger.Error("configuration of backend '%s' was rejected by haproxy", backend)
+	i.rejected = append(i.rejected, RejectedBackend{
+		Backend: backend,
+		Error:   reloadErr.Error(),
+	})
+	// the rejected files, maps included, are written back and
+	// the configuration is rendered again without the backend
+	i.rejectedBackends[backend] = i.changedBackends[backend]
+	err = i.restoreSnapshot(rejected)
+	if err == nil {
+		err = i.writeConfig()
+	}
+	if err == nil {
+		err = i.reloadFnc()
+	}
+	timer.Tick("reload_haproxy")
+	if err == nil {
+		i.logger.Warn("haproxy reloaded with the changes of the other backends, backend '%s' uses its last loaded configuration", backend)
+		i.saveLastGood()
+		writeBack = false
+		return
+	}
+	i.logger.Error("error reloading haproxy without the rejected backend: %v", err)
+	if err := i.reloadLastGood(); err != nil {
+		i.reloadErr = err
+		i.logger.Error("error rolling back haproxy configuration: %v", err)
+	}
+}
+
+func (i *instance) reloadLastGood() error {
+	if err := i.restoreSnapshot(i.lastGood); err != nil {
+		return fmt.Errorf("error restoring the last known good configuration: %w", err)
+	}
+	if err := i.reloadFnc(); err != nil {
+		return fmt.Errorf("error reloading the last known good configuration: %w", err)
+	}
+	i.reloadErr = nil
+	return nil
+}
+
+// checkScratch copies the configuration files of the instance to the
+// scratch directory and validates them with haproxy -c, so a partial
+// configuration is never loaded by haproxy. The copied files refer to the
+// maps and the other files of the instance directories. writeErr is
+// non-nil if the files couldn't be copied.
+func (i *instance) checkScratch(scratch string) (valid bool, writeErr error) {
+	old, err := filepath.Glob(filepath.Join(scratch, "*.cfg"))
+	if err != nil {
+		return false, err
+	}
+	for _, file := range old {
+		if err := os.Remove(file); err != nil {
+			return false, err
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(i.options.HAProxyCfgDir, "*.cfg"))
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(filepath.Join(scratch, filepath.Base(file)), content, 0644); err != nil {
+			return false, err
+		}
+	}
+	return i.checkFnc(scratch) == nil, nil
+}
+
+// bisectBackends looks for a single changed backend whose configuration
+// makes haproxy reject the configuration. The configuration is rendered
+// and checked several times, on each step with part of the changed
+// backends reverted to their last loaded state.
+func (i *instance) bisectBackends(scratch string) string {
+	ids := make([]string, 0, len(i.changedBackends))
+	for id := range i.changedBackends {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	sort.Strings(ids)
+	var writeErr error
+	loads := func(include []string) bool {
+		revert := make(map[string]*hatypes.Backend, len(ids))
+		for _, id := range ids {
+			revert[id] = i.changedBackends[id]
+		}
+		for _, id := range include {
+			delete(revert, id)
+		}
+		restore := i.config.Backends().ReplaceBackends(revert)
+		defer restore()
+		if writeErr = i.writeConfig(); writeErr != nil {
+			return false
+		}
+		var valid bool
+		valid, writeErr = i.checkScratch(scratch)
+		return valid
+	}
+	if !loads(nil) {
+		// the failure persists without the changed backends
+		if writeErr != nil {
+			i.logger.Error("error writing configuration: %v", writeErr)
+		}
+		return ""
+	}
+	suspects := ids
+	for len(suspects) > 1 {
+		half := suspects[:len(suspects)/2]
+		if !loads(half) {
+			if writeErr != nil {
+				i.logger.Error("error writing configuration: %v", writeErr)
+				return ""
+			}
+			suspects = half
+		} else {
+			suspects = suspects[len(half):]
+		}
+	}
+	// a single changed backend is the original failure, otherwise the
+	// suspect needs to be confirmed: the failure might be caused by
+	// backends of distinct halves
+	if len(ids) > 1 && loads(suspects) {
+		return ""
+	}
+	return suspects[0]
+}
+
+// isolatePartitions looks for the backend files, or partitions, that make
+// haproxy reject the configuration. haproxy is reloaded once with the
+// rejected configuration, except the rejected partitions, which keep their
+// last known good content until one of their backends changes again. It
+// returns false, with the last known good files restored, if the failure
+// cannot be isolated to partitions.
+func (i *instance) isolatePartitions(rejected configSnapshot, reloadErr error, scratch string) bool {
+	var changed []int
+	for shard := 0; shard < i.options.BackendShards; shard++ {
+		file := i.backendShardFile(shard)
+		cur, foundCur := rejected[file]
+		old, foundOld := i.lastGood[file]
+		if foundCur != foundOld || !bytes.Equal(cur, old) {
+			changed = append(changed, shard)
+		}
+	}
+	if len(changed) == 0 {
+		return false
+	}
+	var writeErr error
+	// loads checks the rejected configuration, reverting the
+	// partitions of revert to their last known good content
+	loads := func(revert []int) bool {
+		snapshot := make(configSnapshot, len(rejected))
+		for path, content := range rejected {
+			snapshot[path] = content
+		}
+		for _, shard := range revert {
+			file := i.backendShardFile(shard)
+			if content, found := i.lastGood[file]; found {
+				snapshot[file] = content
+			} else {
+				delete(snapshot, file)
+			}
+		}
+		if writeErr = i.restoreSnapshot(snapshot); writeErr != nil {
+			return false
+		}
+		var valid bool
+		valid, writeErr = i.checkScratch(scratch)
+		return valid
+	}
+	// findRejected looks for a single partition, other than the ones
+	// already rejected, that makes haproxy reject the configuration
+	findRejected := func(rejectedShards []int) (int, bool) {
+		var suspects []int
+		for _, shard := range changed {
+			if !containsShard(rejectedShards, shard) {
+				suspects = append(suspects, shard)
+			}
+		}
+		loadsWith := func(include []int) bool {
+			revert := append([]int{}, rejectedShards...)
+			for _, shard := range suspects {
+				if !containsShard(include, shard) {
+					revert = append(revert, shard)
+				}
+			}
+			return loads(revert)
+		}
+		if len(suspects) == 0 || !loadsWith(nil) {
+			// the failure persists without the changed partitions
+			return 0, false
+		}
+		candidates := suspects
+		for len(candidates) > 1 {
+			half := candidates[:len(candidates)/2]
+			if !loadsWith(half) {
+				candidates = half
+			} else {
+				candidates = candidates[len(half):]
+			}
+		}
+		if len(suspects) > 1 && loadsWith(candidates) {
+			// the failure is caused by partitions of distinct halves
+			return 0, false
+		}
+		return candidates[0], true
+	}
+	// restoreLastGood leaves the instance directories as bisectBackends expects
+	restoreLastGood := func() {
+		if err := i.restoreSnapshot(i.lastGood); err != nil {
+			i.logger.Error("error restoring the last known good configuration: %v", err)
+		}
+	}
+	var rejectedShards []int
+	for len(rejectedShards) == 0 || !loads(rejectedShards) {
+		shard, found := findRejected(rejectedShards)
+		if !found {
+			if writeErr != nil {
+				i.logger.Error("error writing configuration: %v", writeErr)
+			}
+			restoreLastGood()
+			return false
+		}
+		rejectedShards = append(rejectedShards, shard)
+	}
+	if err := i.reloadFnc(); err != nil {
+		i.logger.Error("error reloading haproxy without the rejected backend files: %v", err)
+		if err := i.reloadLastGood(); err != nil {
+			i.reloadErr = err
+			i.logger.Error("error rolling back haproxy configuration: %v", err)
+		}
+		return false
+	}
+	sort.Ints(rejectedShards)
+	for _, shard := range rejectedShards {
+		i.logger.Error("backend file '%s' was rejected by haproxy, using its last known good content",
+			filepath.Base(i.backendShardFile(shard)))
+		for _, backend := range i.config.Backends().BuildSortedShard(shard) {
+			if _, found := i.changedBackends[backend.ID]; found {
+				i.rejected = append(i.rejected, RejectedBackend{
+					Backend: backend.ID,
+					Error:   reloadErr.Error(),
+				})
+			}
+		}
+	}
+	i.logger.Warn("haproxy reloaded with the changes of the other backend files")
+	i.saveLastGood()
+	return true
+}
+
+func containsShard(shards []int, shard int) bool {
+	for _, s := range shards {
+		if s == shard {
+			return true
+		}
+	}
+	return false
+}
+
+// hostPartition returns the partition of a host: the one of the namespace
+// of its first path, so the hosts and the backends of a namespace are
+// isolated together. Hosts without backends share the same partition.
+func (i *instance) hostPartition(host *hatypes.Host) int {
+	var namespace string
+	for _, path := range host.Paths {
+		if path.Backend.Namespace != "" {
+			namespace = path.Backend.Namespace
+			break
+		}
+	}
+	return i.config.Backends().NamespaceShard(namespace)
+}
+
+// isolateHostPartitions looks for the partition whose changed hosts make
+// haproxy reject the configuration. Hosts are not rendered in their own
+// files, instead the frontend maps and the main configuration file are
+// rendered again with part of the changed hosts reverted to their last
+// loaded state. haproxy is reloaded once with the hosts of the rejected
+// partition reverted, they keep their last loaded state until they change
+// again. It returns false, with the last known good files restored, if the
+// failure cannot be isolated to the hosts of a partition.
+func (i *instance) isolateHostPartitions(reloadErr error, scratch string) bool {
+	hosts := i.config.Hosts()
+	partitions := map[int][]string{}
+	for hostname, host := range i.changedHosts {
+		if cur := hosts.Items()[hostname]; cur != nil {
+			host = cur
+		}
+		partition := i.hostPartition(host)
+		partitions[partition] = append(partitions[partition], hostname)
+	}
+	if len(partitions) == 0 {
+		return false
+	}
+	changed := make([]int, 0, len(partitions))
+	for partition, hostnames := range partitions {
+		sort.Strings(hostnames)
+		changed = append(changed, partition)
+	}
+	sort.Ints(changed)
+	var writeErr error
+	// loadsWith checks the current configuration, reverting the changed
+	// hosts of all the partitions except the ones of include
+	loadsWith := func(include []int) bool {
+		revert := map[string]*hatypes.Host{}
+		for _, partition := range changed {
+			if !containsShard(include, partition) {
+				for _, hostname := range partitions[partition] {
+					revert[hostname] = i.changedHosts[hostname]
+				}
+			}
+		}
+		restore := hosts.ReplaceHosts(revert)
+		defer restore()
+		if writeErr = i.writeFrontendMaps(true); writeErr != nil {
+			return false
+		}
+		if writeErr = i.writeConfig(); writeErr != nil {
+			return false
+		}
+		var valid bool
+		valid, writeErr = i.checkScratch(scratch)
+		return valid
+	}
+	// restoreLastGood leaves the instance directories, and the frontend
+	// maps of the model, as bisectBackends expects
+	restoreLastGood := func() {
+		if err := i.writeFrontendMaps(true); err != nil {
+			i.logger.Error("error writing frontend maps: %v", err)
+		}
+		if err := i.restoreSnapshot(i.lastGood); err != nil {
+			i.logger.Error("error restoring the last known good configuration: %v", err)
+		}
+	}
+	partition, found := func() (int, bool) {
+		if !loadsWith(nil) {
+			// the failure persists without the changed hosts
+			return 0, false
+		}
+		candidates := changed
+		for len(candidates) > 1 {
+			half := candidates[:len(candidates)/2]
+			if !loadsWith(half) {
+				if writeErr != nil {
+					return 0, false
+				}
+				candidates = half
+			} else {
+				candidates = candidates[len(half):]
+			}
+		}
+		if len(changed) > 1 && loadsWith(candidates) {
+			// the failure is caused by hosts of distinct partitions
+			return 0, false
+		}
+		return candidates[0], true
+	}()
+	if !found {
+		if writeErr != nil {
+			i.logger.Error("error writing configuration: %v", writeErr)
+		}
+		restoreLastGood()
+		return false
+	}
+	rejectedHosts := partitions[partition]
+	for _, hostname := range rejectedHosts {
+		i.rejectedHosts[hostname] = i.changedHosts[hostname]
+	}
+	err := i.writeFrontendMaps(true)
+	if err == nil {
+		err = i.writeConfig()
+	}
+	if err == nil {
+		err = i.reloadFnc()
+	}
+	if err != nil {
+		i.logger.Error("error reloading haproxy without the rejected hosts: %v", err)
+		for _, hostname := range rejectedHosts {
+			delete(i.rejectedHosts, hostname)
+		}
+		restoreLastGood()
+		if err := i.reloadLastGood(); err != nil {
+			i.reloadErr = err
+			i.logger.Error("error rolling back haproxy configuration: %v", err)
+		}
+		return false
+	}
+	reported := map[string]bool{}
+	for _, hostname := range rejectedHosts {
+		i.logger.Error("configuration of host '%s' was rejected by haproxy, using its last loaded state", hostname)
+		// ingress resources are notified via the backends of the rejected host
+		if host := hosts.Items()[hostname]; host != nil {
+			for _, path := range host.Paths {
+				if backend := path.Backend.ID; backend != "" && !reported[backend] {
+					reported[backend] = true
+					i.rejected = append(i.rejected, RejectedBackend{
+						Backend: backend,
+						Error:   reloadErr.Error(),
+					})
+				}
+			}
+		}
+	}
+	i.logger.Warn("haproxy reloaded with the changes of the other hosts")
+	i.saveLastGood()
+	return true
+}
@@ -0,0 +1,618 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+)
+
+func TestRollback(t *testing.T) {
+	testCases := []struct {
+		backends    []string
+		changed     []string
+		bad         string
+		noLastGood  bool
+		failAll     bool
+		expReloads  []string
+		expChecks   []string
+		expRejected []RejectedBackend
+		expErr      string
+		expConfig   string
+		expNoConfig string
+		expLogging  string
+	}{
+		// 0
+		{
+			backends:   []string{"d1"},
+			changed:    []string{"d1"},
+			expReloads: []string{"ok"},
+			expConfig:  "X-Changed",
+			expLogging: `
+INFO haproxy successfully reloaded (embedded daemon)`,
+		},
+		// 1
+		{
+			backends:   []string{"d1"},
+			changed:    []string{"d1"},
+			bad:        "d1",
+			expReloads: []string{"fail", "ok", "ok"},
+			expChecks:  []string{"ok"},
+			expRejected: []RejectedBackend{
+				{Backend: "d1_app_8080", Error: "unknown keyword 'bad-snippet'"},
+			},
+			expConfig:   "backend d1_app_8080",
+			expNoConfig: "bad-snippet",
+			expLogging: `
+ERROR error reloading server: unknown keyword 'bad-snippet'
+WARN haproxy configuration rolled back to the last known good state
+ERROR configuration of backend 'd1_app_8080' was rejected by haproxy
+WARN haproxy reloaded with the changes of the other backends, backend 'd1_app_8080' uses its last loaded configuration
+ERROR haproxy failed to reload, first occurrence at <time>`,
+		},
+		// 2
+		{
+			backends:   []string{"d1", "d2", "d3", "d4"},
+			changed:    []string{"d1", "d2", "d3", "d4"},
+			bad:        "d3",
+			expReloads: []string{"fail", "ok", "ok"},
+			expChecks:  []string{"ok", "ok", "fail", "fail"},
+			expRejected: []RejectedBackend{
+				{Backend: "d3_app_8080", Error: "unknown keyword 'bad-snippet'"},
+			},
+			expConfig:   "X-Changed",
+			expNoConfig: "bad-snippet",
+			expLogging: `
+ERROR error reloading server: unknown keyword 'bad-snippet'
+WARN haproxy configuration rolled back to the last known good state
+ERROR configuration of backend 'd3_app_8080' was rejected by haproxy
+WARN haproxy reloaded with the changes of the other backends, backend 'd3_app_8080' uses its last loaded configuration
+ERROR haproxy failed to reload, first occurrence at <time>`,
+		},
+		// 3
+		{
+			backends:   []string{"d1", "d2"},
+			changed:    []string{"d1", "d2", "d3"},
+			bad:        "d3",
+			expReloads: []string{"fail", "ok", "ok"},
+			expChecks:  []string{"ok", "ok", "ok", "fail"},
+			expRejected: []RejectedBackend{
+				{Backend: "d3_app_8080", Error: "unknown keyword 'bad-snippet'"},
+			},
+			expConfig:   "X-Changed",
+			expNoConfig: "bad-snippet",
+			expLogging: `
+ERROR error reloading server: unknown keyword 'bad-snippet'
+WARN haproxy configuration rolled back to the last known good state
+ERROR configuration of backend 'd3_app_8080' was rejected by haproxy
+WARN haproxy reloaded with the changes of the other backends, backend 'd3_app_8080' uses its last loaded configuration
+ERROR haproxy failed to reload, first occurrence at <time>`,
+		},
+		// 4
+		{
+			changed:    []string{"d1"},
+			bad:        "d1",
+			noLastGood: true,
+			expReloads: []string{"fail"},
+			expErr:     "haproxy rejected the configuration and there is no known good configuration to roll back to: unknown keyword 'bad-snippet'",
+			expConfig:  "bad-snippet",
+			expLogging: `
+ERROR error reloading server: unknown keyword 'bad-snippet'
+ERROR haproxy failed to reload, first occurrence at <time>`,
+		},
+		// 5
+		{
+			backends:   []string{"d1"},
+			changed:    []string{"d1"},
+			failAll:    true,
+			expReloads: []string{"fail", "fail"},
+			expErr:     "error reloading the last known good configuration: haproxy is down",
+			expConfig:  "X-Changed",
+			expLogging: `
+ERROR error reloading server: haproxy is down
+ERROR error rolling back haproxy configuration: error reloading the last known good configuration: haproxy is down
+ERROR haproxy failed to reload, first occurrence at <time>`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		var reloads []string
+		var failAll bool
+		c.instance.reloadFnc = func() error {
+			cfg := c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+			var err error
+			if failAll {
+				err = fmt.Errorf("haproxy is down")
+			} else if strings.Contains(cfg, "bad-snippet") {
+				err = fmt.Errorf("unknown keyword 'bad-snippet'")
+			}
+			if err != nil {
+				reloads = append(reloads, "fail")
+			} else {
+				reloads = append(reloads, "ok")
+			}
+			return err
+		}
+		var checks []string
+		c.instance.checkFnc = func(cfgDir string) error {
+			cfg := c.readConfig(filepath.Join(cfgDir, "haproxy.cfg"))
+			if strings.Contains(cfg, "bad-snippet") {
+				checks = append(checks, "fail")
+				return fmt.Errorf("unknown keyword 'bad-snippet'")
+			}
+			checks = append(checks, "ok")
+			return nil
+		}
+		acquire := func(name, snippet string) {
+			b := c.config.Backends().AcquireBackend(name, "app", "8080")
+			b.Endpoints = []*hatypes.Endpoint{endpointS1}
+			if snippet != "" {
+				b.CustomConfig = []string{snippet}
+			}
+		}
+		if !test.noLastGood {
+			for _, name := range test.backends {
+				acquire(name, "")
+			}
+			c.Update()
+			reloads = nil
+			c.logger.Logging = []string{}
+		}
+		failAll = test.failAll
+		for _, name := range test.changed {
+			c.config.Backends().RemoveAll([]string{name + "_app_8080"})
+			snippet := "http-request set-header X-Changed 1"
+			if name == test.bad {
+				snippet = "bad-snippet"
+			}
+			acquire(name, snippet)
+		}
+		c.Update()
+		if !reflect.DeepEqual(reloads, test.expReloads) {
+			t.Errorf("reloads differ on %d - expected: %v, actual: %v", i, test.expReloads, reloads)
+		}
+		if !reflect.DeepEqual(checks, test.expChecks) {
+			t.Errorf("checks differ on %d - expected: %v, actual: %v", i, test.expChecks, checks)
+		}
+		rejected := c.instance.RejectedBackends()
+		if !reflect.DeepEqual(rejected, test.expRejected) {
+			t.Errorf("rejected backends differ on %d - expected: %+v, actual: %+v", i, test.expRejected, rejected)
+		}
+		var errMsg string
+		if err := c.instance.LastReloadError(); err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != test.expErr {
+			t.Errorf("reload error differs on %d - expected: %q, actual: %q", i, test.expErr, errMsg)
+		}
+		// the instance directory continues to reflect the current model,
+		// except the rejected backends
+		cfg := c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+		if !strings.Contains(cfg, test.expConfig) {
+			t.Errorf("config file on %d should contain %q", i, test.expConfig)
+		}
+		if test.expNoConfig != "" && strings.Contains(cfg, test.expNoConfig) {
+			t.Errorf("config file on %d should not contain %q", i, test.expNoConfig)
+		}
+		// dynamic update logging is out of scope and its order is not predictable
+		logging := []string{}
+		for _, line := range c.logger.Logging {
+			if strings.HasPrefix(line, "ERROR haproxy failed to reload, first occurrence at ") {
+				line = "ERROR haproxy failed to reload, first occurrence at <time>"
+			}
+			if !strings.HasPrefix(line, "INFO-V(2) ") {
+				logging = append(logging, line)
+			}
+		}
+		c.logger.Logging = logging
+		c.logger.CompareLoggingID(strconv.Itoa(i), test.expLogging)
+		c.teardown()
+	}
+}
+
+func TestRollbackRestore(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	newMap := filepath.Join(c.tempdir, "_back_new.map")
+	var bad, newMapFound bool
+	var restored string
+	c.instance.reloadFnc = func() error {
+		cfg := c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+		if bad && strings.Contains(cfg, "bad-snippet") {
+			return fmt.Errorf("unknown keyword 'bad-snippet'")
+		}
+		if bad && restored == "" {
+			_, err := os.Stat(newMap)
+			newMapFound = err == nil
+			restored = cfg
+		}
+		return nil
+	}
+	c.instance.checkFnc = func(cfgDir string) error {
+		return nil
+	}
+
+	b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	c.Update()
+	good := c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+
+	// files not written by the instance are never removed
+	userFile := filepath.Join(c.tempdir, "user.lua")
+
+	bad = true
+	c.config.Backends().RemoveAll([]string{"d1_app_8080"})
+	b = c.config.Backends().AcquireBackend("d1", "app", "8080")
+	b.Endpoints = []*hatypes.Endpoint{endpointS1}
+	b.CustomConfig = []string{"bad-snippet"}
+	if err := os.WriteFile(newMap, []byte("new"), 0600); err != nil {
+		t.Errorf("error writing map file: %v", err)
+	}
+	if err := os.WriteFile(userFile, []byte("user"), 0600); err != nil {
+		t.Errorf("error writing user file: %v", err)
+	}
+	c.Update()
+
+	if restored != good {
+		t.Errorf("haproxy should be reloaded with the last known good configuration, actual:\n%s", restored)
+	}
+	if newMapFound {
+		t.Errorf("files created after the last successful reload should be removed on rollback")
+	}
+	if info, err := os.Stat(newMap); err != nil {
+		t.Errorf("rejected files should be written back after the rollback: %v", err)
+	} else if mode := info.Mode().Perm(); mode != 0644 {
+		t.Errorf("rejected files should be written back with mode 0644, actual: %o", mode)
+	}
+	if _, err := os.Stat(userFile); err != nil {
+		t.Errorf("files not written by the instance should not be removed on rollback: %v", err)
+	}
+	c.logger.Logging = []string{}
+}
+
+func TestRollbackPartitions(t *testing.T) {
+	testCases := []struct {
+		poisoned    []string
+		expReloads  []string
+		expChecks   []string
+		expRejected []RejectedBackend
+		expLogging  string
+	}{
+		// 0
+		{
+			poisoned:   []string{"d2"},
+			expReloads: []string{"fail", "ok", "ok"},
+			expChecks:  []string{"ok", "fail", "fail", "ok"},
+			expRejected: []RejectedBackend{
+				{Backend: "d2_app_8080", Error: "unknown keyword 'bad-snippet'"},
+			},
+			expLogging: `
+ERROR error reloading server: unknown keyword 'bad-snippet'
+WARN haproxy configuration rolled back to the last known good state
+ERROR backend file 'haproxy5-backend000.cfg' was rejected by haproxy, using its last known good content
+WARN haproxy reloaded with the changes of the other backend files
+ERROR haproxy failed to reload, first occurrence at <time>`,
+		},
+		// 1
+		{
+			poisoned:   []string{"d2", "d3"},
+			expReloads: []string{"fail", "ok", "ok"},
+			expChecks:  []string{"ok", "fail", "fail", "fail", "ok", "fail", "fail", "ok"},
+			expRejected: []RejectedBackend{
+				{Backend: "d2_app_8080", Error: "unknown keyword 'bad-snippet'"},
+				{Backend: "d3_app_8080", Error: "unknown keyword 'bad-snippet'"},
+			},
+			expLogging: `
+ERROR error reloading server: unknown keyword 'bad-snippet'
+WARN haproxy configuration rolled back to the last known good state
+ERROR backend file 'haproxy5-backend000.cfg' was rejected by haproxy, using its last known good content
+ERROR backend file 'haproxy5-backend001.cfg' was rejected by haproxy, using its last known good content
+WARN haproxy reloaded with the changes of the other backend files
+ERROR haproxy failed to reload, first occurrence at <time>`,
+		},
+	}
+	namespaces := []string{"d1", "d2", "d3"}
+	for i, test := range testCases {
+		c := setupOptions(testOptions{t: t, shardCount: 3, partitioned: true})
+		var reloads []string
+		var loaded string
+		c.instance.reloadFnc = func() error {
+			files, _ := filepath.Glob(filepath.Join(c.tempdir, "*.cfg"))
+			var cfg string
+			for _, file := range files {
+				cfg += c.readConfig(file)
+			}
+			if strings.Contains(cfg, "bad-snippet") {
+				reloads = append(reloads, "fail")
+				return fmt.Errorf("unknown keyword 'bad-snippet'")
+			}
+			reloads = append(reloads, "ok")
+			loaded = cfg
+			return nil
+		}
+		var checks []string
+		c.instance.checkFnc = func(cfgDir string) error {
+			files, _ := filepath.Glob(filepath.Join(cfgDir, "*.cfg"))
+			for _, file := range files {
+				if strings.Contains(c.readConfig(file), "bad-snippet") {
+					checks = append(checks, "fail")
+					return fmt.Errorf("unknown keyword 'bad-snippet'")
+				}
+			}
+			checks = append(checks, "ok")
+			return nil
+		}
+		acquire := func(namespace, snippet string) {
+			c.config.Backends().RemoveAll([]string{namespace + "_app_8080"})
+			b := c.config.Backends().AcquireBackend(namespace, "app", "8080")
+			b.Endpoints = []*hatypes.Endpoint{endpointS1}
+			if snippet != "" {
+				b.CustomConfig = []string{snippet}
+			}
+		}
+		poisoned := func(namespace string) bool {
+			for _, ns := range test.poisoned {
+				if ns == namespace {
+					return true
+				}
+			}
+			return false
+		}
+		for _, ns := range namespaces {
+			acquire(ns, "")
+		}
+		c.Update()
+		reloads = nil
+		c.logger.Logging = []string{}
+
+		// the poisoned namespaces are rejected, the other ones are applied
+		for _, ns := range namespaces {
+			snippet := "http-request set-header X-Changed-" + ns + " 1"
+			if poisoned(ns) {
+				snippet = "bad-snippet"
+			}
+			acquire(ns, snippet)
+		}
+		c.Update()
+		if !reflect.DeepEqual(reloads, test.expReloads) {
+			t.Errorf("reloads differ on %d - expected: %v, actual: %v", i, test.expReloads, reloads)
+		}
+		if !reflect.DeepEqual(checks, test.expChecks) {
+			t.Errorf("checks differ on %d - expected: %v, actual: %v", i, test.expChecks, checks)
+		}
+		rejected := c.instance.RejectedBackends()
+		if !reflect.DeepEqual(rejected, test.expRejected) {
+			t.Errorf("rejected backends differ on %d - expected: %+v, actual: %+v", i, test.expRejected, rejected)
+		}
+		if err := c.instance.LastReloadError(); err != nil {
+			t.Errorf("reload error on %d should be nil, actual: %v", i, err)
+		}
+		for _, ns := range namespaces {
+			if changed := strings.Contains(loaded, "X-Changed-"+ns); changed == poisoned(ns) {
+				t.Errorf("changes of namespace '%s' on %d should be loaded: %t", ns, i, !poisoned(ns))
+			}
+		}
+		logging := []string{}
+		for _, line := range c.logger.Logging {
+			if strings.HasPrefix(line, "ERROR haproxy failed to reload, first occurrence at ") {
+				line = "ERROR haproxy failed to reload, first occurrence at <time>"
+			}
+			if !strings.HasPrefix(line, "INFO-V(2) ") {
+				logging = append(logging, line)
+			}
+		}
+		c.logger.Logging = logging
+		c.logger.CompareLoggingID(strconv.Itoa(i), test.expLogging)
+
+		// the other namespaces continue to be updated
+		reloads = nil
+		acquire("d1", "http-request set-header X-Updated-d1 1")
+		c.Update()
+		if !reflect.DeepEqual(reloads, []string{"ok"}) {
+			t.Errorf("reloads after isolating partitions differ on %d, actual: %v", i, reloads)
+		}
+		if !strings.Contains(loaded, "X-Updated-d1") || strings.Contains(loaded, "bad-snippet") {
+			t.Errorf("d1 should be updated on %d without the poisoned namespaces", i)
+		}
+		c.logger.Logging = []string{}
+		c.teardown()
+	}
+}
+
+func TestRollbackHostPartitions(t *testing.T) {
+	c := setupOptions(testOptions{t: t, shardCount: 3, partitioned: true})
+	defer c.teardown()
+
+	frontendMaps := func() string {
+		files, _ := filepath.Glob(filepath.Join(c.tempdir, "_front_*.map"))
+		var maps string
+		for _, file := range files {
+			maps += c.readConfig(file)
+		}
+		return maps
+	}
+	var reloads []string
+	var loaded string
+	c.instance.reloadFnc = func() error {
+		maps := frontendMaps()
+		if strings.Contains(maps, "bad-host") {
+			reloads = append(reloads, "fail")
+			return fmt.Errorf("invalid map entry 'bad-host'")
+		}
+		reloads = append(reloads, "ok")
+		loaded = maps
+		return nil
+	}
+	var checks []string
+	c.instance.checkFnc = func(cfgDir string) error {
+		if strings.Contains(frontendMaps(), "bad-host") {
+			checks = append(checks, "fail")
+			return fmt.Errorf("invalid map entry 'bad-host'")
+		}
+		checks = append(checks, "ok")
+		return nil
+	}
+	acquire := func(namespace string, paths ...string) {
+		hostname := namespace + ".local"
+		c.config.Backends().RemoveAll([]string{namespace + "_app_8080"})
+		c.config.Hosts().RemoveAll([]string{hostname})
+		b := c.config.Backends().AcquireBackend(namespace, "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		h := c.config.Hosts().AcquireHost(hostname)
+		for _, path := range append([]string{"/"}, paths...) {
+			h.AddPath(b, path, hatypes.MatchBegin)
+		}
+	}
+	namespaces := []string{"d1", "d2", "d3"}
+	for _, ns := range namespaces {
+		acquire(ns)
+	}
+	c.Update()
+	reloads = nil
+	checks = nil
+	c.logger.Logging = []string{}
+
+	// the hosts of d2 are rejected, the other ones are applied
+	acquire("d1", "/changed-d1")
+	acquire("d2", "/bad-host")
+	acquire("d3", "/changed-d3")
+	c.Update()
+	expReloads := []string{"fail", "ok", "ok"}
+	if !reflect.DeepEqual(reloads, expReloads) {
+		t.Errorf("reloads differ - expected: %v, actual: %v", expReloads, reloads)
+	}
+	expChecks := []string{"ok", "fail", "fail"}
+	if !reflect.DeepEqual(checks, expChecks) {
+		t.Errorf("checks differ - expected: %v, actual: %v", expChecks, checks)
+	}
+	expRejected := []RejectedBackend{
+		{Backend: "d2_app_8080", Error: "invalid map entry 'bad-host'"},
+	}
+	if rejected := c.instance.RejectedBackends(); !reflect.DeepEqual(rejected, expRejected) {
+		t.Errorf("rejected backends differ - expected: %+v, actual: %+v", expRejected, rejected)
+	}
+	if err := c.instance.LastReloadError(); err != nil {
+		t.Errorf("reload error should be nil, actual: %v", err)
+	}
+	if !strings.Contains(loaded, "d1.local#/changed-d1") || !strings.Contains(loaded, "d3.local#/changed-d3") {
+		t.Errorf("changes of the hosts of d1 and d3 should be loaded")
+	}
+	if !strings.Contains(loaded, "d2.local#/ ") {
+		t.Errorf("host of d2 should be loaded with its last loaded state")
+	}
+	logging := []string{}
+	for _, line := range c.logger.Logging {
+		if strings.HasPrefix(line, "ERROR haproxy failed to reload, first occurrence at ") {
+			line = "ERROR haproxy failed to reload, first occurrence at <time>"
+		}
+		if !strings.HasPrefix(line, "INFO-V(2) ") {
+			logging = append(logging, line)
+		}
+	}
+	c.logger.Logging = logging
+	c.logger.CompareLogging(`
+ERROR error reloading server: invalid map entry 'bad-host'
+WARN haproxy configuration rolled back to the last known good state
+ERROR configuration of host 'd2.local' was rejected by haproxy, using its last loaded state
+WARN haproxy reloaded with the changes of the other hosts
+ERROR haproxy failed to reload, first occurrence at <time>`)
+
+	// the other hosts continue to be updated
+	reloads = nil
+	acquire("d1", "/updated-d1")
+	c.Update()
+	if !reflect.DeepEqual(reloads, []string{"ok"}) {
+		t.Errorf("reloads after isolating hosts differ, actual: %v", reloads)
+	}
+	if !strings.Contains(loaded, "d1.local#/updated-d1") || strings.Contains(loaded, "bad-host") {
+		t.Errorf("d1 should be updated without the rejected hosts")
+	}
+
+	// the rejected host is retried when it changes
+	reloads = nil
+	acquire("d2", "/fixed-d2")
+	c.Update()
+	if !reflect.DeepEqual(reloads, []string{"ok"}) {
+		t.Errorf("reloads after fixing the rejected host differ, actual: %v", reloads)
+	}
+	if !strings.Contains(loaded, "d2.local#/fixed-d2") {
+		t.Errorf("d2 should be updated after its host is fixed")
+	}
+	c.logger.Logging = []string{}
+}
+
+func TestRollbackRejectedBackend(t *testing.T) {
+	c := setup(t)
+	defer c.teardown()
+
+	var reloads []string
+	c.instance.reloadFnc = func() error {
+		cfg := c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+		if strings.Contains(cfg, "bad-snippet") {
+			reloads = append(reloads, "fail")
+			return fmt.Errorf("unknown keyword 'bad-snippet'")
+		}
+		reloads = append(reloads, "ok")
+		return nil
+	}
+	c.instance.checkFnc = func(cfgDir string) error {
+		if strings.Contains(c.readConfig(filepath.Join(cfgDir, "haproxy.cfg")), "bad-snippet") {
+			return fmt.Errorf("unknown keyword 'bad-snippet'")
+		}
+		return nil
+	}
+	update := func(name, snippet string) {
+		c.config.Backends().RemoveAll([]string{name + "_app_8080"})
+		b := c.config.Backends().AcquireBackend(name, "app", "8080")
+		b.Endpoints = []*hatypes.Endpoint{endpointS1}
+		b.CustomConfig = []string{snippet}
+		reloads = nil
+		c.Update()
+	}
+	update("d1", "http-request set-header X-D1 1")
+	update("d2", "http-request set-header X-D2 1")
+	update("d1", "bad-snippet")
+	if !reflect.DeepEqual(reloads, []string{"fail", "ok", "ok"}) {
+		t.Errorf("rejected backend should be reverted, reloads: %v", reloads)
+	}
+
+	// other backends are changed without retrying the rejected one
+	update("d2", "http-request set-header X-D2 2")
+	cfg := c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+	if !reflect.DeepEqual(reloads, []string{"ok"}) {
+		t.Errorf("rejected backend should not be retried until it changes, reloads: %v", reloads)
+	}
+	if !strings.Contains(cfg, "X-D1 1") || !strings.Contains(cfg, "X-D2 2") {
+		t.Errorf("rejected backend should use its last loaded state, config:\n%s", cfg)
+	}
+
+	// the rejected backend is retried when it changes
+	update("d1", "http-request set-header X-D1 2")
+	cfg = c.readConfig(filepath.Join(c.tempdir, "haproxy.cfg"))
+	if !reflect.DeepEqual(reloads, []string{"ok"}) || !strings.Contains(cfg, "X-D1 2") {
+		t.Errorf("changed backend should be retried, reloads: %v, config:\n%s", reloads, cfg)
+	}
+	c.logger.Logging = []string{}
+}
@@ -23,6 +23,8 @@ import (
 	"syscall"
 	"testing"
 	"time"
+
+	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
 )
 
 func TestSocket(t *testing.T) {
@@ -408,10 +410,12 @@ func TestHAProxyProcs(t *testing.T) {
 	}
 	for i, test := range testCases {
 		c := setup(t)
-		cli := &clientMock{
-			cmdOutput: test.cmdOutput,
-			cmdError:  test.cmdError,
-			hasSock:   test.hasSock,
+		cli := &ha_helper.SocketMock{
+			Output: test.cmdOutput,
+			Err:    test.cmdError,
+		}
+		if test.hasSock {
+			cli.Addr = "/dev/null"
 		}
 		out, err := HAProxyProcs(cli)
 		if !reflect.DeepEqual(out, test.expOutput) {
@@ -451,10 +455,10 @@ func TestHAProxyProcsLoop(t *testing.T) {
 	}
 	for i, test := range testCases {
 		c := setup(t)
-		cli := &clientMock{
-			cmdError: syscall.ECONNREFUSED,
+		cli := &ha_helper.SocketMock{
+			Err: syscall.ECONNREFUSED,
 		}
-		time.AfterFunc(test.reload, func() { cli.cmdError = nil })
+		time.AfterFunc(test.reload, func() { cli.SetErr(nil) })
 		start := time.Now()
 		_, err := HAProxyProcs(cli)
 		if err != nil {
@@ -464,8 +468,8 @@ func TestHAProxyProcsLoop(t *testing.T) {
 		if elapsed < test.minDelay {
 			t.Errorf("elapsed in %d is '%s' and should not be lower than min '%s'", i, elapsed.String(), test.minDelay.String())
 		}
-		if cli.callCnt > test.maxCnt {
-			t.Errorf("callCnt in %d is '%d' and should not be greater than max '%d'", i, cli.callCnt, test.maxCnt)
+		if calls := cli.Calls(); calls > test.maxCnt {
+			t.Errorf("callCnt in %d is '%d' and should not be greater than max '%d'", i, calls, test.maxCnt)
 		}
 		c.tearDown()
 	}
@@ -483,34 +487,3 @@ func setup(t *testing.T) *testConfig {
 }
 
 func (c *testConfig) tearDown() {}
-
-type clientMock struct {
-	cmdOutput []string
-	cmdError  error
-	callCnt   int
-	hasSock   bool
-}
-
-func (c *clientMock) Address() string {
-	if c.hasSock {
-		return "/dev/null"
-	}
-	return ""
-}
-
-func (c *clientMock) HasConn() bool {
-	return true
-}
-
-func (c *clientMock) Send(observer func(duration time.Duration), command ...string) ([]string, error) {
-	c.callCnt++
-	return c.cmdOutput, c.cmdError
-}
-
-func (c *clientMock) Unlistening() error {
-	return nil
-}
-
-func (c *clientMock) Close() error {
-	return nil
-}
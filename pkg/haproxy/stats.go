@@ -0,0 +1,458 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/socket"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// RetryBudgetExceeded describes a backend whose retries, in the last
+// sampling window, went above its configured retry budget.
+type RetryBudgetExceeded struct {
+	Backend  string
+	Retries  int
+	Requests int
+	Budget   float64
+}
+
+// UnavailableHost describes a hostname whose backends don't have any
+// available server.
+type UnavailableHost struct {
+	Hostname string
+	Backends []string
+}
+
+type backendStat struct {
+	requests     int
+	retries      int
+	redispatches int
+	available    int
+	endpoints    int
+	sessions     int
+	queue        int
+	connectTime  int
+	responseTime int
+}
+
+// StatsSample is a sample of the haproxy stats. NewStatsSample and
+// CalcBackendStats read the model, so they should be called with the model
+// locked. Read only uses the admin socket, so it can be called without the
+// lock, and the model is not locked during the socket round-trip.
+type StatsSample struct {
+	sock     socket.HAProxySocket
+	observer func(duration time.Duration)
+	peers    string
+	servers  bool
+	//
+	backends    map[string]backendStat
+	backendsErr error
+	serverStats map[string]serverStat
+	serversErr  error
+	peersOut    string
+	peersErr    error
+}
+
+// NewStatsSample creates a sample of the stats that should be read from the
+// current haproxy instance. It returns nil if haproxy wasn't started yet.
+func (i *instance) NewStatsSample() *StatsSample {
+	if !i.up || i.config == nil {
+		return nil
+	}
+	return &StatsSample{
+		sock:     i.conns.StatsChk(),
+		observer: i.metrics.HAProxyShowStatResponseTime,
+		peers:    i.config.Global().Peers.Name,
+		servers:  len(i.blueGreenBackends()) > 0,
+	}
+}
+
+// Read reads the stats of the sample from the admin socket.
+func (s *StatsSample) Read() {
+	if s == nil {
+		return
+	}
+	// type 2 filters out everything but the backend proxies
+	msg, err := s.sock.Send(s.observer, "show stat -1 2 -1")
+	if err != nil {
+		s.backendsErr = err
+		return
+	}
+	s.backends = parseBackendStats(msg[0])
+	if s.servers {
+		// type 4 filters out everything but the servers
+		if msg, err := s.sock.Send(nil, "show stat -1 4 -1"); err == nil {
+			s.serverStats = parseServerStats(msg[0])
+		} else {
+			s.serversErr = err
+		}
+	}
+	if s.peers != "" {
+		if msg, err := s.sock.Send(nil, "show peers "+s.peers); err == nil {
+			s.peersOut = msg[0]
+		} else {
+			s.peersErr = err
+		}
+	}
+}
+
+// CalcBackendStats updates the metrics and the analysis of the backends with
+// the stats of sample, and returns the backends that went above their retry
+// budget on this sample.
+func (i *instance) CalcBackendStats(sample *StatsSample) []RetryBudgetExceeded {
+	if sample == nil || i.config == nil {
+		return nil
+	}
+	if sample.backendsErr != nil {
+		i.logger.Error("error reading admin socket: %v", sample.backendsErr)
+		return nil
+	}
+	exceeded := i.updateBackendStats(sample.backends)
+	i.updatePeerSessions(sample)
+	i.updateBlueGreenAnalysis(sample)
+	return exceeded
+}
+
+// readBackendStats reads the current stats of the backend proxies.
+func (i *instance) readBackendStats() (map[string]backendStat, error) {
+	if !i.up {
+		return nil, fmt.Errorf("haproxy wasn't started yet")
+	}
+	msg, err := i.conns.StatsChk().Send(nil, "show stat -1 2 -1")
+	if err != nil {
+		return nil, err
+	}
+	return parseBackendStats(msg[0]), nil
+}
+
+// updatePeerSessions exports the number of remote peers whose sessions are,
+// or aren't, established. Nothing is exported if peers aren't configured.
+func (i *instance) updatePeerSessions(sample *StatsSample) {
+	if sample.peers == "" {
+		return
+	}
+	if sample.peersErr != nil {
+		i.logger.Error("error reading peers from admin socket: %v", sample.peersErr)
+		return
+	}
+	established, notEstablished := parsePeerSessions(sample.peersOut)
+	i.metrics.SetPeerSessions("established", established)
+	i.metrics.SetPeerSessions("not_established", notEstablished)
+}
+
+// updateBackendStats updates retry and redispatch metrics with the difference
+// between the current and the last sample, and also checks the retry budget
+// of every backend that has one configured. A backend is reported when it
+// goes above its budget, and not again while it stays there. Counters are
+// reset on reloads, so a counter lesser than the one from the last sample is
+// used as is.
+func (i *instance) updateBackendStats(current map[string]backendStat) []RetryBudgetExceeded {
+	delta := func(cur, last int) int {
+		if cur < last {
+			return cur
+		}
+		return cur - last
+	}
+	backends := i.config.Backends().Items()
+	var exceeded []RetryBudgetExceeded
+	overBudget := map[string]bool{}
+	for name, cur := range current {
+		last, hasLast := i.backendStats[name]
+		if backend := backends[name]; backend != nil {
+			cur.endpoints = countUsableEndpoints(backend)
+			current[name] = cur
+		}
+		retries := delta(cur.retries, last.retries)
+		requests := delta(cur.requests, last.requests)
+		if retries > 0 {
+			i.metrics.AddBackendRetries(name, retries)
+		}
+		if redispatches := delta(cur.redispatches, last.redispatches); redispatches > 0 {
+			i.metrics.AddBackendRedispatches(name, redispatches)
+		}
+		// the first sample has no window, it only counts since haproxy started
+		backend := backends[name]
+		if !hasLast || backend == nil || backend.RetryBudgetWarn == 0 {
+			continue
+		}
+		if requests == 0 {
+			// no requests in the window, the backend keeps its state
+			overBudget[name] = i.overBudget[name]
+			continue
+		}
+		if pct := float64(retries) * 100 / float64(requests); pct > backend.RetryBudgetWarn {
+			overBudget[name] = true
+			if i.overBudget[name] {
+				continue
+			}
+			i.logger.Warn("retries of backend '%s' above the budget of %.1f%%: %d retries on %d requests (%.1f%%)",
+				name, backend.RetryBudgetWarn, retries, requests, pct)
+			exceeded = append(exceeded, RetryBudgetExceeded{
+				Backend:  name,
+				Retries:  retries,
+				Requests: requests,
+				Budget:   backend.RetryBudgetWarn,
+			})
+		}
+	}
+	sort.Slice(exceeded, func(j, k int) bool {
+		return exceeded[j].Backend < exceeded[k].Backend
+	})
+	i.backendStats = current
+	i.overBudget = overBudget
+	if i.options.ScalingSignalsMax > 0 {
+		i.updateLoadSignals(current)
+	}
+	return exceeded
+}
+
+// updateLoadSignals exports the load of the backends as metrics labeled with
+// the namespace and name of their services, so they can be used as scaling
+// signals. Labels don't depend on the backend naming, so the series survive
+// a backend rename, e.g. a service port referenced by name instead of number.
+// Backends of the same service are aggregated: sessions and queue are summed,
+// and the worst of the average times is used. Up to ScalingSignalsMax services
+// are exported, in alphabetical order, the remaining ones are dropped.
+func (i *instance) updateLoadSignals(current map[string]backendStat) {
+	backends := i.config.Backends().Items()
+	loads := map[string]*types.BackendLoad{}
+	for name, stat := range current {
+		backend := backends[name]
+		if backend == nil || backend.Namespace == "" {
+			continue
+		}
+		svc := backend.Namespace + "/" + backend.Name
+		load := loads[svc]
+		if load == nil {
+			load = &types.BackendLoad{}
+			loads[svc] = load
+		}
+		load.Sessions += stat.sessions
+		load.Queue += stat.queue
+		if connectTime := time.Duration(stat.connectTime) * time.Millisecond; connectTime > load.ConnectTime {
+			load.ConnectTime = connectTime
+		}
+		if responseTime := time.Duration(stat.responseTime) * time.Millisecond; responseTime > load.ResponseTime {
+			load.ResponseTime = responseTime
+		}
+	}
+	services := make([]string, 0, len(loads))
+	for svc := range loads {
+		services = append(services, svc)
+	}
+	sort.Strings(services)
+	var dropped int
+	if limit := i.options.ScalingSignalsMax; len(services) > limit {
+		dropped = len(services) - limit
+		services = services[:limit]
+	}
+	if dropped != i.loadDropped {
+		if dropped > 0 {
+			i.logger.Warn("scaling signals of %d service(s) were not exported, max of %d reached", dropped, i.options.ScalingSignalsMax)
+		}
+		i.loadDropped = dropped
+	}
+	signals := make(map[string]bool, len(services))
+	for _, svc := range services {
+		ns, name, _ := strings.Cut(svc, "/")
+		i.metrics.SetBackendLoad(ns, name, loads[svc])
+		signals[svc] = true
+	}
+	for svc := range i.loadSignals {
+		if !signals[svc] {
+			ns, name, _ := strings.Cut(svc, "/")
+			i.metrics.SetBackendLoad(ns, name, nil)
+		}
+	}
+	i.loadSignals = signals
+}
+
+// UnavailableHosts lists the hostnames whose backends don't have any available
+// server. Backends are checked against the model, and also against the servers
+// that were up on the last stats sample. The sample is ignored if the backend
+// changed its endpoints since then, the model is used in this case.
+func (i *instance) UnavailableHosts() []UnavailableHost {
+	if i.config == nil {
+		return nil
+	}
+	backends := i.config.Backends().Items()
+	isAvailable := func(backendID string) bool {
+		return i.isBackendAvailable(backends[backendID])
+	}
+	var unavailable []UnavailableHost
+	for _, host := range i.config.Hosts().BuildSortedItems() {
+		down := map[string]bool{}
+		var hasAvailable bool
+		for _, path := range host.Paths {
+			backendID := path.Backend.ID
+			if backendID == "" || down[backendID] {
+				// redirect, or backend already checked
+				continue
+			}
+			if isAvailable(backendID) {
+				hasAvailable = true
+				break
+			}
+			down[backendID] = true
+		}
+		if len(down) > 0 && !hasAvailable {
+			backendIDs := make([]string, 0, len(down))
+			for backendID := range down {
+				backendIDs = append(backendIDs, backendID)
+			}
+			sort.Strings(backendIDs)
+			unavailable = append(unavailable, UnavailableHost{
+				Hostname: host.Hostname,
+				Backends: backendIDs,
+			})
+		}
+	}
+	return unavailable
+}
+
+// UnavailableBackends lists, in sorted order, the IDs of the backends that
+// don't have any available server, using the same rules of UnavailableHosts.
+func (i *instance) UnavailableBackends() []string {
+	if i.config == nil {
+		return nil
+	}
+	var unavailable []string
+	for _, backend := range i.config.Backends().Items() {
+		if !i.isBackendAvailable(backend) {
+			unavailable = append(unavailable, backend.ID)
+		}
+	}
+	sort.Strings(unavailable)
+	return unavailable
+}
+
+func (i *instance) isBackendAvailable(backend *hatypes.Backend) bool {
+	if backend == nil {
+		return false
+	}
+	endpoints := countUsableEndpoints(backend)
+	if stat, found := i.backendStats[backend.ID]; found && stat.endpoints == endpoints {
+		return stat.available > 0
+	}
+	return endpoints > 0
+}
+
+// countUsableEndpoints counts the endpoints that can receive requests. Empty
+// slots and endpoints in maintenance are disabled, and haproxy doesn't use
+// servers whose weight is zero.
+func countUsableEndpoints(backend *hatypes.Backend) int {
+	var count int
+	for _, ep := range backend.Endpoints {
+		if ep.Enabled && !ep.Maintenance && ep.Weight > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// parseBackendStats reads the CSV output of haproxy's `show stat` command
+// and returns the request, retry and redispatch counters of all the backends,
+// the number of active and backup servers that are up, and the current load:
+// sessions, queue, and average connect and response times in milliseconds.
+//
+// the first line is the header, starting with `# `:
+//
+//	# pxname,svname,qcur,qmax,...,wretr,wredis,...,req_tot,...
+//	default_app_8080,BACKEND,0,0,...,3,1,...,250,...
+func parseBackendStats(csv string) map[string]backendStat {
+	lines := strings.Split(csv, "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "# ") {
+		return nil
+	}
+	fields := map[string]int{}
+	for j, field := range strings.Split(strings.TrimPrefix(lines[0], "# "), ",") {
+		fields[field] = j
+	}
+	value := func(row []string, field string) int {
+		j, found := fields[field]
+		if !found || j >= len(row) {
+			return 0
+		}
+		v, _ := strconv.Atoi(row[j])
+		return v
+	}
+	pxname, found1 := fields["pxname"]
+	svname, found2 := fields["svname"]
+	if !found1 || !found2 {
+		return nil
+	}
+	stats := map[string]backendStat{}
+	for _, line := range lines[1:] {
+		row := strings.Split(line, ",")
+		if len(row) <= svname || row[svname] != "BACKEND" {
+			continue
+		}
+		// req_tot is only filled on http mode, stot (sessions) is used on tcp mode
+		requests := value(row, "req_tot")
+		if requests == 0 {
+			requests = value(row, "stot")
+		}
+		stats[row[pxname]] = backendStat{
+			requests:     requests,
+			retries:      value(row, "wretr"),
+			redispatches: value(row, "wredis"),
+			available:    value(row, "act") + value(row, "bck"),
+			sessions:     value(row, "scur"),
+			queue:        value(row, "qcur"),
+			connectTime:  value(row, "ctime"),
+			responseTime: value(row, "rtime"),
+		}
+	}
+	return stats
+}
+
+// parsePeerSessions reads the output of haproxy's `show peers` command and
+// returns the number of remote peers whose last session status is, or isn't,
+// established. The local peer is not counted.
+//
+//	0x55d1c8: [16/Oct/2026:10:00:00] id=ingress disabled=0 flags=0x6213 ...
+//	  0x55d1c9: id=pod-b(remote,active) addr=10.0.0.2:10000 last_status=ESTA last_hdshk=2s
+//	  0x55d1ca: id=pod-a(local,inactive) addr=0.0.0.0:10000 last_status=NONE last_hdshk=<NEVER>
+func parsePeerSessions(out string) (established, notEstablished int) {
+	for _, line := range strings.Split(out, "\n") {
+		var remote bool
+		var status string
+		for _, field := range strings.Fields(line) {
+			if id, found := strings.CutPrefix(field, "id="); found {
+				remote = strings.Contains(id, "(remote")
+			} else if st, found := strings.CutPrefix(field, "last_status="); found {
+				status = st
+			}
+		}
+		if !remote {
+			continue
+		}
+		if status == "ESTA" {
+			established++
+		} else {
+			notEstablished++
+		}
+	}
+	return established, notEstablished
+}
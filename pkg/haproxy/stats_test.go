@@ -0,0 +1,476 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestParseBackendStats(t *testing.T) {
+	testCases := []struct {
+		csv      string
+		expected map[string]backendStat
+	}{
+		// 0
+		{
+			csv:      "",
+			expected: nil,
+		},
+		// 1
+		{
+			csv: `# pxname,svname,stot,wretr,wredis,req_tot
+d1_app_8080,BACKEND,120,3,1,250
+d2_app_8080,BACKEND,40,,,
+d2_app_8080,srv001,40,,,
+_error404,BACKEND,0,0,0,0
+`,
+			expected: map[string]backendStat{
+				"d1_app_8080": {requests: 250, retries: 3, redispatches: 1},
+				"d2_app_8080": {requests: 40},
+				"_error404":   {},
+			},
+		},
+		// 2
+		{
+			csv: `# pxname,svname,wretr
+d1_app_8080,BACKEND
+`,
+			expected: map[string]backendStat{
+				"d1_app_8080": {},
+			},
+		},
+		// 3
+		{
+			csv: `# pxname,svname,stot,act,bck
+d1_app_8080,BACKEND,10,2,1
+d2_app_8080,BACKEND,10,0,0
+`,
+			expected: map[string]backendStat{
+				"d1_app_8080": {requests: 10, available: 3},
+				"d2_app_8080": {requests: 10},
+			},
+		},
+		// 4
+		{
+			csv: `# pxname,svname,qcur,qmax,scur,smax,stot,ctime,rtime
+d1_app_8080,BACKEND,4,10,25,40,300,2,180
+d1_app_8080,srv001,4,10,25,40,300,2,180
+`,
+			expected: map[string]backendStat{
+				"d1_app_8080": {requests: 300, sessions: 25, queue: 4, connectTime: 2, responseTime: 180},
+			},
+		},
+	}
+	for i, test := range testCases {
+		actual := parseBackendStats(test.csv)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("stats differ on %d - expected: %+v - actual: %+v", i, test.expected, actual)
+		}
+	}
+}
+
+func TestParsePeerSessions(t *testing.T) {
+	testCases := []struct {
+		out            string
+		established    int
+		notEstablished int
+	}{
+		// 0
+		{
+			out: "",
+		},
+		// 1
+		{
+			out: `0x55d1c8: [16/Oct/2026:10:00:00] id=ingress disabled=0 flags=0x6213 resync_timeout=<PAST> task_calls=12
+  0x55d1c9: id=pod-b(remote,active) addr=10.0.0.2:10000 last_status=ESTA last_hdshk=2s
+        reconnect=4s heartbeat=2s confirm=0 tx_hbt=10 rx_hbt=10 no_hbt=0 new_conn=1 proto_err=0 coll=0
+  0x55d1ca: id=pod-c(remote,active) addr=10.0.0.3:10000 last_status=CONN last_hdshk=<NEVER>
+  0x55d1cb: id=pod-a(local,inactive) addr=0.0.0.0:10000 last_status=NONE last_hdshk=<NEVER>
+  0x55d1cc: id=pod-d(remote,inactive) addr=10.0.0.4:10000 last_status=ESTA last_hdshk=1s
+`,
+			established:    2,
+			notEstablished: 1,
+		},
+	}
+	for i, test := range testCases {
+		established, notEstablished := parsePeerSessions(test.out)
+		if established != test.established || notEstablished != test.notEstablished {
+			t.Errorf("peer sessions differ on %d - expected: %d/%d - actual: %d/%d",
+				i, test.established, test.notEstablished, established, notEstablished)
+		}
+	}
+}
+
+func TestUpdateBackendStats(t *testing.T) {
+	testCases := []struct {
+		budget          float64
+		samples         []map[string]backendStat
+		expected        []RetryBudgetExceeded
+		expRetries      int
+		expRedispatches int
+		logging         string
+	}{
+		// 0
+		{
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 100, retries: 2, redispatches: 1}},
+			},
+			expRetries:      2,
+			expRedispatches: 1,
+		},
+		// 1
+		{
+			budget: 5,
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 100, retries: 20}},
+			},
+			expRetries: 20,
+		},
+		// 2
+		{
+			budget: 5,
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 100, retries: 2}},
+				{"d1_app_8080": {requests: 200, retries: 6}},
+			},
+			expRetries: 6,
+		},
+		// 3
+		{
+			budget: 5,
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 100, retries: 2}},
+				{"d1_app_8080": {requests: 200, retries: 12}},
+			},
+			expected: []RetryBudgetExceeded{
+				{Backend: "d1_app_8080", Retries: 10, Requests: 100, Budget: 5},
+			},
+			expRetries: 12,
+			logging:    `WARN retries of backend 'd1_app_8080' above the budget of 5.0%: 10 retries on 100 requests (10.0%)`,
+		},
+		// 4
+		{
+			budget: 5,
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 500, retries: 20}},
+				{"d1_app_8080": {requests: 50, retries: 10}},
+			},
+			expected: []RetryBudgetExceeded{
+				{Backend: "d1_app_8080", Retries: 10, Requests: 50, Budget: 5},
+			},
+			expRetries: 30,
+			logging:    `WARN retries of backend 'd1_app_8080' above the budget of 5.0%: 10 retries on 50 requests (20.0%)`,
+		},
+		// 5
+		{
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 100, retries: 2}},
+				{"d1_app_8080": {requests: 200, retries: 12}},
+			},
+			expRetries: 12,
+		},
+		// 6
+		{
+			budget: 5,
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 100, retries: 2}},
+				{"d1_app_8080": {requests: 200, retries: 12}},
+				{"d1_app_8080": {requests: 200, retries: 12}},
+				{"d1_app_8080": {requests: 300, retries: 22}},
+			},
+			expRetries: 22,
+			logging:    `WARN retries of backend 'd1_app_8080' above the budget of 5.0%: 10 retries on 100 requests (10.0%)`,
+		},
+		// 7
+		{
+			budget: 5,
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {requests: 100, retries: 2}},
+				{"d1_app_8080": {requests: 200, retries: 12}},
+				{"d1_app_8080": {requests: 300, retries: 14}},
+				{"d1_app_8080": {requests: 400, retries: 24}},
+			},
+			expected: []RetryBudgetExceeded{
+				{Backend: "d1_app_8080", Retries: 10, Requests: 100, Budget: 5},
+			},
+			expRetries: 24,
+			logging: `
+WARN retries of backend 'd1_app_8080' above the budget of 5.0%: 10 retries on 100 requests (10.0%)
+WARN retries of backend 'd1_app_8080' above the budget of 5.0%: 10 retries on 100 requests (10.0%)`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		b := c.config.Backends().AcquireBackend("d1", "app", "8080")
+		b.RetryBudgetWarn = test.budget
+		var actual []RetryBudgetExceeded
+		for _, sample := range test.samples {
+			actual = c.instance.updateBackendStats(sample)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("exceeded budget differ on %d - expected: %+v - actual: %+v", i, test.expected, actual)
+		}
+		metrics := c.instance.metrics.(*helper_test.MetricsMock)
+		if retries := metrics.BackendRetries["d1_app_8080"]; retries != test.expRetries {
+			t.Errorf("retries differ on %d - expected: %d - actual: %d", i, test.expRetries, retries)
+		}
+		if redispatches := metrics.BackendRedispatch["d1_app_8080"]; redispatches != test.expRedispatches {
+			t.Errorf("redispatches differ on %d - expected: %d - actual: %d", i, test.expRedispatches, redispatches)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestLoadSignals(t *testing.T) {
+	type backend struct {
+		namespace, name, port string
+	}
+	testCases := []struct {
+		max      int
+		backends []backend
+		samples  []map[string]backendStat
+		expected map[string]types.BackendLoad
+		logging  string
+	}{
+		// 0
+		{
+			backends: []backend{{"d1", "app", "8080"}},
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {sessions: 10, queue: 2}},
+			},
+			expected: map[string]types.BackendLoad{},
+		},
+		// 1
+		{
+			max:      10,
+			backends: []backend{{"d1", "app", "8080"}},
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {sessions: 10, queue: 2, connectTime: 1, responseTime: 150}},
+			},
+			expected: map[string]types.BackendLoad{
+				"d1/app": {Sessions: 10, Queue: 2, ConnectTime: time.Millisecond, ResponseTime: 150 * time.Millisecond},
+			},
+		},
+		// 2
+		{
+			max:      10,
+			backends: []backend{{"d1", "app", "8080"}, {"d1", "app", "8443"}},
+			samples: []map[string]backendStat{
+				{
+					"d1_app_8080": {sessions: 10, queue: 2, connectTime: 3, responseTime: 150},
+					"d1_app_8443": {sessions: 5, queue: 1, connectTime: 5, responseTime: 90},
+				},
+			},
+			expected: map[string]types.BackendLoad{
+				"d1/app": {Sessions: 15, Queue: 3, ConnectTime: 5 * time.Millisecond, ResponseTime: 150 * time.Millisecond},
+			},
+		},
+		// 3
+		{
+			max:      10,
+			backends: []backend{{"d1", "app", "8080"}, {"d1", "app", "http"}},
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {sessions: 10}},
+				{"d1_app_http": {sessions: 12}},
+			},
+			expected: map[string]types.BackendLoad{
+				"d1/app": {Sessions: 12},
+			},
+		},
+		// 4
+		{
+			max:      10,
+			backends: []backend{{"d1", "app1", "8080"}, {"d1", "app2", "8080"}},
+			samples: []map[string]backendStat{
+				{"d1_app1_8080": {sessions: 10}, "d1_app2_8080": {sessions: 20}},
+				{"d1_app2_8080": {sessions: 22}},
+			},
+			expected: map[string]types.BackendLoad{
+				"d1/app2": {Sessions: 22},
+			},
+		},
+		// 5
+		{
+			max:      2,
+			backends: []backend{{"d1", "app1", "8080"}, {"d1", "app2", "8080"}, {"d2", "app1", "8080"}},
+			samples: []map[string]backendStat{
+				{"d1_app1_8080": {sessions: 1}, "d1_app2_8080": {sessions: 2}, "d2_app1_8080": {sessions: 3}},
+				{"d1_app1_8080": {sessions: 1}, "d1_app2_8080": {sessions: 2}, "d2_app1_8080": {sessions: 3}},
+			},
+			expected: map[string]types.BackendLoad{
+				"d1/app1": {Sessions: 1},
+				"d1/app2": {Sessions: 2},
+			},
+			logging: `WARN scaling signals of 1 service(s) were not exported, max of 2 reached`,
+		},
+		// 6
+		{
+			max:      10,
+			backends: []backend{{"d1", "app", "8080"}},
+			samples: []map[string]backendStat{
+				{"d1_app_8080": {sessions: 10}, "_error404": {sessions: 1}},
+			},
+			expected: map[string]types.BackendLoad{
+				"d1/app": {Sessions: 10},
+			},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		c.instance.options.ScalingSignalsMax = test.max
+		for _, b := range test.backends {
+			c.config.Backends().AcquireBackend(b.namespace, b.name, b.port)
+		}
+		for _, sample := range test.samples {
+			c.instance.updateBackendStats(sample)
+		}
+		metrics := c.instance.metrics.(*helper_test.MetricsMock)
+		if !reflect.DeepEqual(metrics.BackendLoad, test.expected) {
+			t.Errorf("backend load differ on %d - expected: %+v - actual: %+v", i, test.expected, metrics.BackendLoad)
+		}
+		c.logger.CompareLogging(test.logging)
+		c.teardown()
+	}
+}
+
+func TestUnavailableHosts(t *testing.T) {
+	testCases := []struct {
+		endpoints1 int
+		endpoints2 int
+		drain1     bool
+		sample     map[string]backendStat
+		addAfter1  int
+		expected   []UnavailableHost
+		expBacks   []string
+	}{
+		// 0
+		{
+			endpoints1: 1,
+			endpoints2: 1,
+		},
+		// 1
+		{
+			endpoints2: 1,
+			expected: []UnavailableHost{
+				{Hostname: "h1.local", Backends: []string{"d1_app1_8080"}},
+			},
+			expBacks: []string{"d1_app1_8080"},
+		},
+		// 2
+		{
+			expected: []UnavailableHost{
+				{Hostname: "h1.local", Backends: []string{"d1_app1_8080"}},
+				{Hostname: "h2.local", Backends: []string{"d1_app1_8080", "d1_app2_8080"}},
+			},
+			expBacks: []string{"d1_app1_8080", "d1_app2_8080"},
+		},
+		// 3
+		{
+			endpoints1: 2,
+			endpoints2: 1,
+			drain1:     true,
+			expected: []UnavailableHost{
+				{Hostname: "h1.local", Backends: []string{"d1_app1_8080"}},
+			},
+			expBacks: []string{"d1_app1_8080"},
+		},
+		// 4
+		{
+			endpoints1: 1,
+			endpoints2: 1,
+			sample: map[string]backendStat{
+				"d1_app1_8080": {available: 1},
+				"d1_app2_8080": {available: 1},
+			},
+		},
+		// 5
+		{
+			endpoints1: 1,
+			endpoints2: 1,
+			sample: map[string]backendStat{
+				"d1_app1_8080": {available: 0},
+				"d1_app2_8080": {available: 1},
+			},
+			expected: []UnavailableHost{
+				{Hostname: "h1.local", Backends: []string{"d1_app1_8080"}},
+			},
+			expBacks: []string{"d1_app1_8080"},
+		},
+		// 6
+		{
+			endpoints2: 1,
+			sample: map[string]backendStat{
+				"d1_app1_8080": {available: 0},
+				"d1_app2_8080": {available: 1},
+			},
+			addAfter1: 1,
+		},
+		// 7
+		{
+			endpoints1: 1,
+			sample: map[string]backendStat{
+				"d1_app1_8080": {available: 1},
+				"d1_app2_8080": {available: 0},
+			},
+			expBacks: []string{"d1_app2_8080"},
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		addEndpoints := func(b *hatypes.Backend, count, weight int) {
+			for j := 0; j < count; j++ {
+				ep := b.AcquireEndpoint("172.17.0.11", 8080+len(b.Endpoints), "")
+				ep.Weight = weight
+			}
+		}
+		weight1 := 1
+		if test.drain1 {
+			weight1 = 0
+		}
+		b1 := c.config.Backends().AcquireBackend("d1", "app1", "8080")
+		b2 := c.config.Backends().AcquireBackend("d1", "app2", "8080")
+		b1.AddEmptyEndpoint()
+		b2.AddEmptyEndpoint()
+		addEndpoints(b1, test.endpoints1, weight1)
+		addEndpoints(b2, test.endpoints2, 1)
+		c.config.Hosts().AcquireHost("h1.local").AddPath(b1, "/", hatypes.MatchBegin)
+		h2 := c.config.Hosts().AcquireHost("h2.local")
+		h2.AddPath(b1, "/", hatypes.MatchBegin)
+		h2.AddPath(b1, "/app", hatypes.MatchBegin)
+		h2.AddPath(b2, "/api", hatypes.MatchBegin)
+		c.config.Hosts().AcquireHost("h3.local").AddRedirect("/", hatypes.MatchBegin, "h1.local")
+		if test.sample != nil {
+			c.instance.updateBackendStats(test.sample)
+		}
+		addEndpoints(b1, test.addAfter1, 1)
+		actual := c.instance.UnavailableHosts()
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("unavailable hosts differ on %d - expected: %+v - actual: %+v", i, test.expected, actual)
+		}
+		if backends := c.instance.UnavailableBackends(); !reflect.DeepEqual(backends, test.expBacks) {
+			t.Errorf("unavailable backends differ on %d - expected: %+v - actual: %+v", i, test.expBacks, backends)
+		}
+		c.teardown()
+	}
+}
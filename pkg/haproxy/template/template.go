@@ -18,6 +18,7 @@ package template
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	gotemplate "text/template"
@@ -28,9 +29,21 @@ func CreateConfig() *Config {
 	return &Config{}
 }
 
+// ErrMaxSize is returned, wrapped, by Write and WriteOutput when the
+// rendered output of a template exceeds the size configured by SetMaxSize.
+var ErrMaxSize = errors.New("rendered output exceeds the maximum size")
+
 // Config ...
 type Config struct {
 	templates []*template
+	maxSize   int
+}
+
+// SetMaxSize limits the size, in bytes, of the rendered output of every
+// template. Nothing is written to disk if a limit is exceeded. Zero, the
+// default value, disables the limit.
+func (c *Config) SetMaxSize(maxSize int) {
+	c.maxSize = maxSize
 }
 
 // ClearTemplates ...
@@ -65,6 +78,9 @@ func (c *Config) WriteOutput(data interface{}, output string) error {
 		if err := t.tmpl.Execute(t.rawConfig, data); err != nil {
 			return err
 		}
+		if c.maxSize > 0 && t.rawConfig.Len() > c.maxSize {
+			return fmt.Errorf("%w: %s has %d bytes, limit is %d", ErrMaxSize, t.tmpl.Name(), t.rawConfig.Len(), c.maxSize)
+		}
 	}
 	for _, t := range c.templates {
 		if err := t.writeToDisk(output); err != nil {
@@ -72,6 +72,7 @@ func TestWrite(t *testing.T) {
 		templates []tmplContent
 		datas     []interface{}
 		tempdir   string
+		maxSize   int
 	}{
 		// 0
 		{
@@ -230,6 +231,23 @@ func TestWrite(t *testing.T) {
 				data3{List: []int{1, 2, 3, 4, 5, 6, 7}},
 			},
 		},
+		// 11
+		{
+			templates: []tmplContent{
+				{
+					content: "{{ .Name }}",
+					rotate:  1,
+					outputs: []string{"jim1", "jim-long"},
+					logging: `ERROR from writer: rendered output exceeds the maximum size: h1.tmpl has 9 bytes, limit is 8`,
+				},
+			},
+			datas: []interface{}{
+				data1{Name: "jim1"},
+				data1{Name: "jim-long"},
+				data1{Name: "jim-long2"},
+			},
+			maxSize: 8,
+		},
 	}
 
 	for i, test := range testCases {
@@ -238,6 +256,7 @@ func TestWrite(t *testing.T) {
 			c.tempdirOutput = test.tempdir
 		}
 		defer c.teardown()
+		c.templateConfig.SetMaxSize(test.maxSize)
 		for _, tmpl := range test.templates {
 			c.newTemplate(tmpl.content, tmpl.rotate)
 		}
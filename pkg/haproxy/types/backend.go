@@ -18,9 +18,11 @@ package types
 
 import (
 	"fmt"
+	"hash/crc32"
 	"math/rand"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -28,11 +30,17 @@ import (
 func (b *Backend) BackendID() BackendID {
 	// IMPLEMENT as pointer
 	// TODO immutable internal state
-	return BackendID{
+	backendID := BackendID{
 		Namespace: b.Namespace,
 		Name:      b.Name,
 		Port:      b.Port,
 	}
+	// id is only kept if it cannot be derived from the other fields, eg
+	// when backends are named by hash, so BackendID can be compared by value.
+	if b.ID != buildID(b.Namespace, b.Name, b.Port) {
+		backendID.id = b.ID
+	}
+	return backendID
 }
 
 // FindEndpoint ...
@@ -131,6 +139,42 @@ func (b *Backend) CookieAffinity() bool {
 	return !b.ModeTCP && b.Cookie.Name != "" && !b.Cookie.Dynamic
 }
 
+// NameRegex is the cookie name escaped to be used in a haproxy regex. Regex
+// metachars are escaped in a bracket expression, since haproxy's config
+// parser removes the backslash of some escape sequences. The caret cannot be
+// alone in a bracket expression, so its backslash is doubled instead.
+func (c *Cookie) NameRegex() string {
+	var name strings.Builder
+	for _, r := range c.Name {
+		switch {
+		case r == '^':
+			name.WriteString(`\\^`)
+		case strings.ContainsRune(`.$*+?|()[]{}\`, r):
+			name.WriteString("[" + string(r) + "]")
+		default:
+			name.WriteRune(r)
+		}
+	}
+	return name.String()
+}
+
+// ResolverName is the name of the resolvers section used by the backend. A
+// backend that changes the hold valid period has a copy of its resolver.
+func (b *Backend) ResolverName() string {
+	if b.DNS.HoldValid == "" {
+		return b.Resolver
+	}
+	return b.Resolver + "_hold_" + b.DNS.HoldValid
+}
+
+// DNSSlots is the number of server slots of a backend that uses a resolver.
+func (b *Backend) DNSSlots() int {
+	if b.DNS.Slots > 0 {
+		return b.DNS.Slots
+	}
+	return len(b.Endpoints)
+}
+
 // FindBackendPath ...
 func (b *Backend) FindBackendPath(link *PathLink) *BackendPath {
 	// IMPLEMENT change to a map
@@ -231,6 +275,16 @@ func (b *Backend) HasSSLRedirect() bool {
 	return false
 }
 
+// HasSSLRedirectPort ...
+func (b *Backend) HasSSLRedirectPort() bool {
+	for _, path := range b.Paths {
+		if path.SSLRedirect && path.SSLRedirectPort > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // HasSSLRedirectPaths ...
 func (b *Backend) HasSSLRedirectPaths(paths []*BackendPath) bool {
 	for _, path := range paths {
@@ -241,6 +295,87 @@ func (b *Backend) HasSSLRedirectPaths(paths []*BackendPath) bool {
 	return false
 }
 
+// SpillACLLists assigns a file to the lists of source addresses with more
+// than max values, so they are referenced with -f instead of being rendered
+// in the configuration file. The file name is derived from the content of
+// the list, so the configuration only changes if the list changes.
+func (b *Backend) SpillACLLists(prefix string, max int) {
+	b.AllowedIPTCP.spillACLLists(prefix, max)
+	b.DeniedIPTCP.spillACLLists(prefix, max)
+	b.Limit.WhitelistFile = aclListFile(prefix, max, b.Limit.Whitelist)
+	for _, path := range b.Paths {
+		path.AllowedIPHTTP.spillACLLists(prefix, max)
+		path.DeniedIPHTTP.spillACLLists(prefix, max)
+	}
+	// path config has copies of the access configs, rebuild it
+	b.pathConfig = nil
+}
+
+// ACLLists returns the lists of the backend assigned to a file by
+// SpillACLLists, indexed by the file name.
+func (b *Backend) ACLLists() map[string][]string {
+	lists := map[string][]string{}
+	b.AllowedIPTCP.addACLLists(lists)
+	b.DeniedIPTCP.addACLLists(lists)
+	addACLList(lists, b.Limit.WhitelistFile, b.Limit.Whitelist)
+	for _, path := range b.Paths {
+		path.AllowedIPHTTP.addACLLists(lists)
+		path.DeniedIPHTTP.addACLLists(lists)
+	}
+	return lists
+}
+
+func (c *AccessConfig) spillACLLists(prefix string, max int) {
+	c.RuleFile = aclListFile(prefix, max, c.Rule)
+	c.ExceptionFile = aclListFile(prefix, max, c.Exception)
+}
+
+func (c *AccessConfig) addACLLists(lists map[string][]string) {
+	addACLList(lists, c.RuleFile, c.Rule)
+	addACLList(lists, c.ExceptionFile, c.Exception)
+}
+
+func aclListFile(prefix string, max int, values []string) string {
+	if len(values) <= max {
+		return ""
+	}
+	return fmt.Sprintf("%s_%08x.list", prefix, crc32.ChecksumIEEE([]byte(strings.Join(values, "\n"))))
+}
+
+func addACLList(lists map[string][]string, file string, values []string) {
+	if file != "" {
+		lists[file] = values
+	}
+}
+
+// AssignAuthJWTFiles assigns a file to the public keys used to validate
+// JWT tokens. The file name is derived from the key, so the configuration
+// only changes if the key changes.
+func (b *Backend) AssignAuthJWTFiles(prefix string) {
+	for _, path := range b.Paths {
+		for i := range path.AuthJWT.Keys {
+			key := &path.AuthJWT.Keys[i]
+			key.File = fmt.Sprintf("%s_%08x.pem", prefix, crc32.ChecksumIEEE([]byte(key.PEM)))
+		}
+	}
+	// path config has copies of the jwt configs, rebuild it
+	b.pathConfig = nil
+}
+
+// AuthJWTFiles returns the public keys assigned to a file by
+// AssignAuthJWTFiles, indexed by the file name.
+func (b *Backend) AuthJWTFiles() map[string]string {
+	files := map[string]string{}
+	for _, path := range b.Paths {
+		for _, key := range path.AuthJWT.Keys {
+			if key.File != "" {
+				files[key.File] = key.PEM
+			}
+		}
+	}
+	return files
+}
+
 // PathConfig ...
 func (b *Backend) PathConfig(attr string) *BackendPathConfig {
 	b.ensurePathConfig(attr)
@@ -275,8 +410,9 @@ func (b *Backend) createPathConfig() map[string]*BackendPathConfig {
 	pathType := reflect.TypeOf(BackendPath{})
 	for i := 0; i < pathType.NumField(); i++ {
 		name := pathType.Field(i).Name
-		// filter out core fields
-		if name != "ID" && name != "Link" && name != "Host" {
+		// filter out core fields, and split backends which are
+		// configured in the frontend and don't need backend acls
+		if name != "ID" && name != "Link" && name != "Host" && name != "SplitBackends" {
 			pathconfig[name] = &BackendPathConfig{}
 		}
 	}
@@ -389,6 +525,35 @@ func (p *BackendPath) Match() MatchType {
 	return p.Link.match
 }
 
+// CacheTableName returns the name of the stick table that caches successful
+// responses of the auth service, or an empty string if caching is disabled.
+func (a AuthExternal) CacheTableName() string {
+	if a.AuthBackendName == "" || a.Cache.Duration == "" {
+		return ""
+	}
+	return a.cacheTableName("allow")
+}
+
+// CacheDenyTableName returns the name of the stick table that caches denied
+// responses of the auth service, or an empty string if caching is disabled.
+func (a AuthExternal) CacheDenyTableName() string {
+	if a.AuthBackendName == "" || a.Cache.DenyDuration == "" {
+		return ""
+	}
+	return a.cacheTableName("deny")
+}
+
+// cacheTableName names the table after everything that can change the
+// response of the auth service, so distinct auth configurations never share
+// cached responses.
+func (a AuthExternal) cacheTableName(kind string) string {
+	key := strings.Join([]string{
+		a.AuthBackendName, a.AuthPath, a.Method, a.Cache.KeyHeader,
+		strings.Join(a.HeadersRequest, ","), strconv.FormatInt(a.Cache.Size, 10),
+	}, "|")
+	return fmt.Sprintf("_auth_cache_%s_%08x", kind, crc32.ChecksumIEEE([]byte(key)))
+}
+
 // String ...
 func (b *TCPBackend) String() string {
 	return fmt.Sprintf("%+v", *b)
@@ -138,7 +138,7 @@ func TestFillSourceIPs(t *testing.T) {
 	}
 	for i, test := range testCases {
 		c := setup(t)
-		b := createBackend(0, "default", test.name, "8080")
+		b := createBackend(0, "default_"+test.name+"_8080", "default", test.name, "8080")
 		for _, e := range test.ep {
 			if e != "" {
 				b.AcquireEndpoint(e, 8080, "")
@@ -318,3 +318,37 @@ func TestPathIDs(t *testing.T) {
 		c.teardown()
 	}
 }
+
+func TestCookieNameRegex(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		// 0
+		{
+			name:     "INGRESSCOOKIE",
+			expected: `INGRESSCOOKIE`,
+		},
+		// 1
+		{
+			name:     "app.sid",
+			expected: `app[.]sid`,
+		},
+		// 2
+		{
+			name:     "a+b*c|d$e",
+			expected: `a[+]b[*]c[|]d[$]e`,
+		},
+		// 3
+		{
+			name:     "^sid",
+			expected: `\\^sid`,
+		},
+	}
+	for i, test := range testCases {
+		c := setup(t)
+		cookie := Cookie{Name: test.name}
+		c.compareObjects("name regex", i, cookie.NameRegex(), test.expected)
+		c.teardown()
+	}
+}
@@ -67,6 +67,8 @@ func (b *Backends) Clear() {
 		}
 	}
 	nb.itemsDel = b.items
+	nb.Naming = b.Naming
+	nb.ShardByNamespace = b.ShardByNamespace
 	*b = *nb
 }
 
@@ -223,6 +225,75 @@ func (b *Backends) buildSortedItems(backendItems map[string]*Backend) []*Backend
 	return items
 }
 
+// BuildResolvers returns the resolvers, followed by the copies of the ones
+// whose hold valid period is changed by a backend.
+func (b *Backends) BuildResolvers(resolvers []*DNSResolver) []*DNSResolver {
+	byName := make(map[string]*DNSResolver, len(resolvers))
+	for _, resolver := range resolvers {
+		byName[resolver.Name] = resolver
+	}
+	copies := map[string]*DNSResolver{}
+	for _, backend := range b.items {
+		name := backend.ResolverName()
+		resolver := byName[backend.Resolver]
+		if resolver == nil || name == backend.Resolver || copies[name] != nil {
+			continue
+		}
+		resolverCopy := *resolver
+		resolverCopy.Name = name
+		resolverCopy.HoldValid = backend.DNS.HoldValid
+		copies[name] = &resolverCopy
+	}
+	if len(copies) == 0 {
+		return resolvers
+	}
+	names := make([]string, 0, len(copies))
+	for name := range copies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := make([]*DNSResolver, 0, len(resolvers)+len(copies))
+	result = append(result, resolvers...)
+	for _, name := range names {
+		result = append(result, copies[name])
+	}
+	return result
+}
+
+// HasLoadServerState ...
+func (b *Backends) HasLoadServerState() bool {
+	for _, backend := range b.items {
+		if backend.LoadServerState {
+			return true
+		}
+	}
+	return false
+}
+
+// HasOriginalForwardedFor returns true if at least one backend needs the
+// X-Forwarded-For header sent by the client, before it is changed.
+func (b *Backends) HasOriginalForwardedFor() bool {
+	for _, backend := range b.items {
+		if backend.ForwardedHeaders.OriginalFor != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAuthJWT returns true if at least one path of a backend validates
+// JWT tokens.
+func (b *Backends) HasAuthJWT() bool {
+	for _, backend := range b.items {
+		for _, path := range backend.Paths {
+			if len(path.AuthJWT.Keys) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // BuildUsedAuthBackends ...
 func (b *Backends) BuildUsedAuthBackends() map[string]bool {
 	usedNames := map[string]bool{}
@@ -237,13 +308,154 @@ func (b *Backends) BuildUsedAuthBackends() map[string]bool {
 	return usedNames
 }
 
+// BuildSecureCookieAuthBackends lists the IDs of the auth backends used by
+// at least one path that asks for secure cookies.
+func (b *Backends) BuildSecureCookieAuthBackends() map[string]bool {
+	secure := map[string]bool{}
+	for _, backend := range b.items {
+		for _, path := range backend.Paths {
+			if path.AuthExternal.SecureCookies && path.AuthExternal.AuthBackendName != "" {
+				secure[path.AuthExternal.AuthBackendName] = true
+			}
+		}
+	}
+	return secure
+}
+
+// BuildSetSecureCookieAuthBackends lists the IDs of the auth backends used
+// by at least one path that asks for the Secure flag on https requests.
+func (b *Backends) BuildSetSecureCookieAuthBackends() map[string]bool {
+	secure := map[string]bool{}
+	for _, backend := range b.items {
+		for _, path := range backend.Paths {
+			if path.AuthExternal.CookieSetSecure && path.AuthExternal.AuthBackendName != "" {
+				secure[path.AuthExternal.AuthBackendName] = true
+			}
+		}
+	}
+	return secure
+}
+
+// BuildCookieDomainAuthBackends lists the sorted cookie domains that the
+// paths using an auth backend ask for, indexed by the auth backend ID.
+func (b *Backends) BuildCookieDomainAuthBackends() map[string][]string {
+	found := map[string]map[string]bool{}
+	for _, backend := range b.items {
+		for _, path := range backend.Paths {
+			auth := &path.AuthExternal
+			if auth.CookieDomain != "" && auth.AuthBackendName != "" {
+				if found[auth.AuthBackendName] == nil {
+					found[auth.AuthBackendName] = map[string]bool{}
+				}
+				found[auth.AuthBackendName][auth.CookieDomain] = true
+			}
+		}
+	}
+	domains := make(map[string][]string, len(found))
+	for name, names := range found {
+		for domain := range names {
+			domains[name] = append(domains[name], domain)
+		}
+		sort.Strings(domains[name])
+	}
+	return domains
+}
+
+// BuildFallbackRules builds the use_backend rules of backends that have a
+// fallback backend, in the order they should be declared: every backend of
+// the chain is used only if the primary and all the former ones in the chain
+// don't have any available server.
+func (b *Backends) BuildFallbackRules() []*BackendFallbackRule {
+	var rules []*BackendFallbackRule
+	for _, backend := range b.buildSortedItems(b.items) {
+		unavailable := []string{backend.ID}
+		visited := map[string]bool{backend.ID: true}
+		for fallback := b.FindBackendID(backend.Fallback); fallback != nil && !visited[fallback.ID]; fallback = b.FindBackendID(fallback.Fallback) {
+			rules = append(rules, &BackendFallbackRule{
+				Backend:     backend.ID,
+				Target:      fallback.ID,
+				Unavailable: unavailable,
+			})
+			unavailable = append(unavailable[:len(unavailable):len(unavailable)], fallback.ID)
+			visited[fallback.ID] = true
+		}
+	}
+	return rules
+}
+
+// BuildClassRules builds the use_backend rules of backends that route
+// the requests of a traffic class to another backend.
+func (b *Backends) BuildClassRules() []*BackendClassRule {
+	var rules []*BackendClassRule
+	for _, backend := range b.buildSortedItems(b.items) {
+		for _, route := range backend.TrafficClass.Routes {
+			if target := b.FindBackendID(route.Target); target != nil {
+				rules = append(rules, &BackendClassRule{
+					Backend: backend.ID,
+					Class:   route.Class,
+					Target:  target.ID,
+				})
+			}
+		}
+	}
+	return rules
+}
+
+// BuildSplitRules builds the use_backend rules of backend paths that split
+// their requests with other backends. Every rule routes a range of the
+// random number, from 0 to 99, assigned to the request. Numbers out of the
+// ranges of the rules continue to be routed to the path's own backend.
+func (b *Backends) BuildSplitRules() []*BackendSplitRule {
+	var rules []*BackendSplitRule
+	for _, backend := range b.buildSortedItems(b.items) {
+		for _, path := range backend.Paths {
+			from := 0
+			for _, split := range path.SplitBackends {
+				if target := b.FindBackendID(split.Target); target != nil && split.Weight > 0 {
+					rules = append(rules, &BackendSplitRule{
+						SplitID: buildSplitID(backend, path),
+						Target:  target.ID,
+						From:    from,
+						To:      from + split.Weight - 1,
+					})
+				}
+				from += split.Weight
+			}
+		}
+	}
+	return rules
+}
+
+// SplitID returns the ID used by the frontend to identify the requests of
+// a host path whose backend splits them with other backends, or an empty
+// string if the requests are not split.
+func (b *Backends) SplitID(path *HostPath) string {
+	backend := b.FindBackend(path.Backend.Namespace, path.Backend.Name, path.Backend.Port)
+	if backend == nil {
+		return ""
+	}
+	backendPath := backend.FindBackendPath(path.Link)
+	if backendPath == nil || len(backendPath.SplitBackends) == 0 {
+		return ""
+	}
+	return buildSplitID(backend, backendPath)
+}
+
+func buildSplitID(backend *Backend, path *BackendPath) string {
+	return backend.ID + "_" + path.ID
+}
+
 // AcquireBackend ...
 func (b *Backends) AcquireBackend(namespace, name, port string) *Backend {
 	if backend := b.FindBackend(namespace, name, port); backend != nil {
 		return backend
 	}
 	shardCount := len(b.shards)
-	backend := createBackend(shardCount, namespace, name, port)
+	backend := createBackend(shardCount, b.buildID(namespace, name, port), namespace, name, port)
+	if b.ShardByNamespace && shardCount > 0 {
+		// all the backends of a namespace share the same shard
+		backend.shard = b.NamespaceShard(namespace)
+	}
 	b.items[backend.ID] = backend
 	b.itemsAdd[backend.ID] = backend
 	if shardCount > 0 {
@@ -253,6 +465,15 @@ func (b *Backends) AcquireBackend(namespace, name, port string) *Backend {
 	return backend
 }
 
+// NamespaceShard returns the shard of the backends of a namespace when
+// the backends are sharded by namespace.
+func (b *Backends) NamespaceShard(namespace string) int {
+	if len(b.shards) == 0 {
+		return 0
+	}
+	return int(buildHash64(namespace) % uint64(len(b.shards)))
+}
+
 // AcquireAuthBackend ...
 func (b *Backends) AcquireAuthBackend(ipList []string, port int, hostname string) *Backend {
 	sort.Strings(ipList)
@@ -274,7 +495,7 @@ func (b *Backends) AcquireAuthBackend(ipList []string, port int, hostname string
 
 // FindBackend ...
 func (b *Backends) FindBackend(namespace, name, port string) *Backend {
-	return b.items[buildID(namespace, name, port)]
+	return b.items[b.buildID(namespace, name, port)]
 }
 
 // FindBackendID ...
@@ -299,6 +520,37 @@ func (b *Backends) RemoveAll(backendID []string) {
 	}
 }
 
+// ReplaceBackends temporarily replaces the backends of the current state by
+// the ones found in the backends map, indexed by the backend ID. A nil value
+// removes the backend. The returned func restores the former state.
+func (b *Backends) ReplaceBackends(backends map[string]*Backend) (restore func()) {
+	saved := make(map[string]*Backend, len(backends))
+	for id, backend := range backends {
+		saved[id] = b.items[id]
+		b.setItem(id, backend)
+	}
+	return func() {
+		for id, backend := range saved {
+			b.setItem(id, backend)
+		}
+	}
+}
+
+func (b *Backends) setItem(id string, backend *Backend) {
+	if cur, found := b.items[id]; found {
+		if len(b.shards) > 0 {
+			delete(b.shards[cur.shard], id)
+		}
+		delete(b.items, id)
+	}
+	if backend != nil {
+		if len(b.shards) > 0 {
+			b.shards[backend.shard][id] = backend
+		}
+		b.items[id] = backend
+	}
+}
+
 // IsEmpty ...
 func (b BackendID) IsEmpty() bool {
 	return b.Name == ""
@@ -311,8 +563,39 @@ func (b BackendID) String() string {
 	return b.id
 }
 
-func createBackend(shards int, namespace, name, port string) *Backend {
+func createBackend(shards int, id, namespace, name, port string) *Backend {
+	hash64 := buildHash64(buildID(namespace, name, port))
+	var shard int
+	if shards > 0 {
+		shard = int(hash64 % uint64(shards))
+	}
+	return &Backend{
+		hash64:    hash64,
+		shard:     shard,
+		ID:        id,
+		Namespace: namespace,
+		Name:      name,
+		Port:      port,
+		Server:    ServerConfig{InitialWeight: 1},
+	}
+}
+
+// buildID builds the name of the backend according to the naming scheme.
+// Internal backends, whose namespace starts with an underscore, always use
+// the namespace_name_port format.
+func (b *Backends) buildID(namespace, name, port string) string {
 	id := buildID(namespace, name, port)
+	if b.Naming != BackendNamingHash || strings.HasPrefix(namespace, "_") {
+		return id
+	}
+	return fmt.Sprintf("b_%016x", buildHash64(id))
+}
+
+func buildID(namespace, name, port string) string {
+	return namespace + "_" + name + "_" + port
+}
+
+func buildHash64(id string) uint64 {
 	hash := md5.Sum([]byte(id))
 	part0 := uint64(hash[0])<<56 |
 		uint64(hash[1])<<48 |
@@ -330,22 +613,5 @@ func createBackend(shards int, namespace, name, port string) *Backend {
 		uint64(hash[13])<<16 |
 		uint64(hash[14])<<8 |
 		uint64(hash[15])
-	hash64 := part0 ^ part1
-	var shard int
-	if shards > 0 {
-		shard = int(hash64 % uint64(shards))
-	}
-	return &Backend{
-		hash64:    hash64,
-		shard:     shard,
-		ID:        id,
-		Namespace: namespace,
-		Name:      name,
-		Port:      port,
-		Server:    ServerConfig{InitialWeight: 1},
-	}
-}
-
-func buildID(namespace, name, port string) string {
-	return namespace + "_" + name + "_" + port
+	return part0 ^ part1
 }
@@ -26,6 +26,7 @@ import (
 func TestBackendCrud(t *testing.T) {
 	testCases := []struct {
 		shardCnt  int
+		shardNs   bool
 		add       []string
 		del       []string
 		expected  []string
@@ -82,6 +83,19 @@ func TestBackendCrud(t *testing.T) {
 				{"default_app3_8080"},
 			},
 		},
+		// 6
+		{
+			shardCnt: 3,
+			shardNs:  true,
+			add:      []string{"d1_app1_8080", "d1_app2_8080", "d2_app1_8080", "d2_app2_8080", "d3_app1_8080"},
+			expected: []string{"d1_app1_8080", "d1_app2_8080", "d2_app1_8080", "d2_app2_8080", "d3_app1_8080"},
+			expAdd:   []string{"d1_app1_8080", "d1_app2_8080", "d2_app1_8080", "d2_app2_8080", "d3_app1_8080"},
+			expShards: [][]string{
+				{"d2_app1_8080", "d2_app2_8080"},
+				{"d3_app1_8080"},
+				{"d1_app1_8080", "d1_app2_8080"},
+			},
+		},
 	}
 	toarray := func(items map[string]*Backend) []string {
 		if len(items) == 0 {
@@ -99,6 +113,7 @@ func TestBackendCrud(t *testing.T) {
 	for i, test := range testCases {
 		c := setup(t)
 		backends := CreateBackends(test.shardCnt)
+		backends.ShardByNamespace = test.shardNs
 		for _, add := range test.add {
 			p := strings.Split(add, "_")
 			backends.AcquireBackend(p[0], p[1], p[2])
@@ -366,6 +381,104 @@ func TestBuildID(t *testing.T) {
 	}
 }
 
+func TestBackendNaming(t *testing.T) {
+	testCases := []struct {
+		naming    BackendNaming
+		namespace string
+		name      string
+		port      string
+		expected  string
+	}{
+		// 0
+		{
+			naming:    BackendNamingPort,
+			namespace: "default",
+			name:      "echo",
+			port:      "8080",
+			expected:  "default_echo_8080",
+		},
+		// 1
+		{
+			naming:    BackendNamingPortName,
+			namespace: "default",
+			name:      "echo",
+			port:      "http",
+			expected:  "default_echo_http",
+		},
+		// 2
+		{
+			naming:    BackendNamingHash,
+			namespace: "default",
+			name:      "echo",
+			port:      "8080",
+			expected:  "b_0f7c7d403c67fafd",
+		},
+		// 3
+		{
+			naming:    BackendNamingHash,
+			namespace: "default",
+			name:      "echo",
+			port:      "8081",
+			expected:  "b_a97965d808e27a7d",
+		},
+		// 4
+		{
+			naming:    BackendNamingHash,
+			namespace: "_auth",
+			name:      "backend001",
+			port:      "8080",
+			expected:  "_auth_backend001_8080",
+		},
+	}
+	for i, test := range testCases {
+		backends := CreateBackends(0)
+		backends.Naming = test.naming
+		backend := backends.AcquireBackend(test.namespace, test.name, test.port)
+		if backend.ID != test.expected {
+			t.Errorf("%d: expected '%s' but was '%s'", i, test.expected, backend.ID)
+		}
+		if id := backend.BackendID().String(); id != backend.ID {
+			t.Errorf("%d: expected backend ID '%s' but was '%s'", i, backend.ID, id)
+		}
+		if found := backends.FindBackend(test.namespace, test.name, test.port); found != backend {
+			t.Errorf("%d: expected to find backend '%s'", i, backend.ID)
+		}
+		if found := backends.FindBackendID(backend.BackendID()); found != backend {
+			t.Errorf("%d: expected to find backend ID '%s'", i, backend.ID)
+		}
+	}
+}
+
+func TestReplaceBackends(t *testing.T) {
+	backends := CreateBackends(2)
+	b1 := backends.AcquireBackend("d1", "app", "8080")
+	b2 := backends.AcquireBackend("d2", "app", "8080")
+	b1old := createBackend(2, b1.ID, "d1", "app", "8080")
+	restore := backends.ReplaceBackends(map[string]*Backend{
+		b1.ID: b1old,
+		b2.ID: nil,
+	})
+	if found := backends.FindBackend("d1", "app", "8080"); found != b1old {
+		t.Errorf("expected replaced backend '%s'", b1.ID)
+	}
+	if found := backends.FindBackend("d2", "app", "8080"); found != nil {
+		t.Errorf("expected removed backend '%s'", b2.ID)
+	}
+	if shard := backends.BuildSortedShard(b1.shard); len(shard) != 1 || shard[0] != b1old {
+		t.Errorf("expected replaced backend '%s' in its shard", b1.ID)
+	}
+	restore()
+	if found := backends.FindBackend("d1", "app", "8080"); found != b1 {
+		t.Errorf("expected restored backend '%s'", b1.ID)
+	}
+	if found := backends.FindBackend("d2", "app", "8080"); found != b2 {
+		t.Errorf("expected restored backend '%s'", b2.ID)
+	}
+	if len(backends.Items()) != 2 {
+		t.Errorf("expected 2 backends after restore")
+	}
+}
+
 func BenchmarkBuildIDFmt(b *testing.B) {
 	namespace := "default"
 	name := "app"
@@ -151,3 +151,53 @@ func (b GlobalBindConfig) ShareHTTPPort() bool {
 func (b GlobalBindConfig) HasFrontingProxy() bool {
 	return b.FrontingBind != ""
 }
+
+// FindSPOEAgent returns the SPOE agent declared with name, or nil if not found.
+func (g *Global) FindSPOEAgent(name string) *SPOEAgent {
+	for _, agent := range g.SPOEAgents {
+		if agent.Name == name {
+			return agent
+		}
+	}
+	return nil
+}
+
+// UniqueIDPlaceholder is replaced by the request unique ID in the body
+// of the custom responses.
+const UniqueIDPlaceholder = "%[unique-id]"
+
+// UniqueIDSafeFormat is the log-format of the unique ID sent to the client.
+// It is size bounded and url encoded, so a unique-id-format based on the
+// request content cannot be used to inject content in the response.
+const UniqueIDSafeFormat = "%[unique-id,bytes(0,64),url_enc]"
+
+// SafeFormat returns the log-format of the unique ID sent to the client.
+func (u UniqueIDConfig) SafeFormat() string {
+	return UniqueIDSafeFormat
+}
+
+// TrustForwardFor returns true if the X-Forwarded-For header sent by the
+// client is preserved, so haproxy is expected to run behind trusted proxies.
+func (g *Global) TrustForwardFor() bool {
+	return g.ForwardFor == "update" || g.ForwardFor == "ifmissing"
+}
+
+// SpillACLLists assigns a file to the criteria with more than max values,
+// see Backend.SpillACLLists. Criteria are rendered as anonymous ACLs, so
+// they cannot be split in several lines like the named ones.
+func (c *TrafficClass) SpillACLLists(prefix string, max int) {
+	for i := range c.Criteria {
+		criterion := &c.Criteria[i]
+		criterion.ValuesFile = aclListFile(prefix+"_"+c.Name, max, criterion.Values)
+	}
+}
+
+// ACLLists returns the criteria values assigned to a file by
+// SpillACLLists, indexed by the file name.
+func (c *TrafficClass) ACLLists() map[string][]string {
+	lists := map[string][]string{}
+	for _, criterion := range c.Criteria {
+		addACLList(lists, criterion.ValuesFile, criterion.Values)
+	}
+	return lists
+}
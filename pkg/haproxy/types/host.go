@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 )
 
 // CreateHosts ...
@@ -74,6 +75,35 @@ func (h *Hosts) RemoveAll(hostnames []string) {
 	}
 }
 
+// ReplaceHosts temporarily replaces the hosts of the current state by the
+// ones found in the hosts map, indexed by the hostname. A nil value removes
+// the host. The returned func restores the former state.
+func (h *Hosts) ReplaceHosts(hosts map[string]*Host) (restore func()) {
+	saved := make(map[string]*Host, len(hosts))
+	for hostname, host := range hosts {
+		saved[hostname] = h.items[hostname]
+		h.setItem(hostname, host)
+	}
+	return func() {
+		for hostname, host := range saved {
+			h.setItem(hostname, host)
+		}
+	}
+}
+
+func (h *Hosts) setItem(hostname string, host *Host) {
+	if cur, found := h.items[hostname]; found {
+		h.releaseHost(cur)
+		delete(h.items, hostname)
+	}
+	if host != nil {
+		if host.sslPassthrough {
+			h.sslPassthroughCount++
+		}
+		h.items[hostname] = host
+	}
+}
+
 // FindTargetRedirect ...
 func (h *Hosts) FindTargetRedirect(redirfrom string, isRegex bool) *Host {
 	if redirfrom == "" {
@@ -213,6 +243,24 @@ func (h *Hosts) HasVarNamespace() bool {
 	return false
 }
 
+// BuildAuditBackends lists, sorted and without duplicates, the ID
+// of the backends that receive sampled requests of at least one host.
+func (h *Hosts) BuildAuditBackends() []string {
+	var backends []string
+	found := map[string]bool{}
+	for _, host := range h.items {
+		if host.HasAudit() {
+			id := host.Audit.Backend.String()
+			if !found[id] {
+				backends = append(backends, id)
+				found[id] = true
+			}
+		}
+	}
+	sort.Strings(backends)
+	return backends
+}
+
 // FindPath ...
 func (h *Host) FindPath(path string, match ...MatchType) (paths []*HostPath) {
 	for _, p := range h.Paths {
@@ -259,10 +307,56 @@ func (h *Host) AddRedirect(path string, match MatchType, redirTo string) {
 	_ = h.addPath(path, match, nil, redirTo)
 }
 
+// AddRedirectIntent registers a redirect rule declared on this host and
+// emits it.
+func (h *Host) AddRedirectIntent(kind RedirectKind, link *PathLink, target, source string) {
+	intent := &HostRedirectIntent{
+		Kind:   kind,
+		Link:   link,
+		Target: target,
+		Source: source,
+	}
+	h.RedirectIntents = append(h.RedirectIntents, intent)
+	h.EnableRedirect(intent)
+}
+
+// EnableRedirect emits the rule declared by a redirect intent of this host.
+func (h *Host) EnableRedirect(intent *HostRedirectIntent) {
+	switch intent.Kind {
+	case RedirectAppRoot:
+		h.RootRedirect = intent.Target
+	case RedirectFrom:
+		h.Redirect.RedirectHost = intent.Target
+	case RedirectTo:
+		if path := h.FindPathWithLink(intent.Link); path != nil {
+			path.Backend = HostBackend{}
+			path.RedirTo = intent.Target
+		}
+	}
+}
+
+// DisableRedirect removes the rule declared by a redirect intent of this host.
+// A redirect-to path is changed to answer with 404.
+func (h *Host) DisableRedirect(intent *HostRedirectIntent) {
+	switch intent.Kind {
+	case RedirectAppRoot:
+		h.RootRedirect = ""
+	case RedirectFrom:
+		h.Redirect.RedirectHost = ""
+	case RedirectTo:
+		if path := h.FindPathWithLink(intent.Link); path != nil {
+			path.Backend = HostBackend{ID: "_error404"}
+			path.RedirTo = ""
+		}
+	}
+}
+
 type hostResolver struct {
 	useDefaultCrt  *bool
 	followRedirect *bool
 	crtFilename    *string
+	httpOnly       *bool
+	sslRedirect    **bool
 }
 
 func (h *Host) addPath(path string, match MatchType, backend *Backend, redirTo string) *HostPath {
@@ -285,6 +379,8 @@ func (h *Host) addLink(backend *Backend, link *PathLink, redirTo string) *HostPa
 			useDefaultCrt:  &h.TLS.UseDefaultCrt,
 			followRedirect: &h.TLS.FollowRedirect,
 			crtFilename:    &h.TLS.TLSFilename,
+			httpOnly:       &h.TLS.HTTPOnly,
+			sslRedirect:    &h.TLS.SSLRedirect,
 		}
 	} else if redirTo == "" {
 		hback = HostBackend{ID: "_error404"}
@@ -330,6 +426,10 @@ func (h *Host) HasTLS() bool {
 }
 
 func (h *hostResolver) UseTLS() bool {
+	if *h.httpOnly {
+		return false
+	}
+
 	// whether the ingress resource has the `tls:` entry for the host
 	hasTLSEntry := *h.crtFilename != ""
 
@@ -339,11 +439,47 @@ func (h *hostResolver) UseTLS() bool {
 	return hasTLSEntry || autoTLSEnabled
 }
 
+func (h *hostResolver) HTTPOnly() bool {
+	return *h.httpOnly
+}
+
+func (h *hostResolver) SSLRedirect() (redirect, found bool) {
+	if *h.sslRedirect == nil {
+		return false, false
+	}
+	return **h.sslRedirect, true
+}
+
 // HasTLSAuth ...
 func (h *Host) HasTLSAuth() bool {
 	return h.TLS.CAHash != ""
 }
 
+// HasAudit returns true if a sample of the requests of the host should be
+// sent to an audit backend.
+func (h *Host) HasAudit() bool {
+	return !h.Audit.Backend.IsEmpty() && h.Audit.SamplePercent > 0 && !h.sslPassthrough
+}
+
+// MapPath returns the path to be added in the maps. Hosts that lowercase
+// the path of the request before the lookup have their paths lowercased as
+// well, except regex paths, which are added as declared.
+func (h *Host) MapPath(path *HostPath) *HostPath {
+	if h.PathNormalization != PathNormalizationLowercase || path.Link.match == MatchRegex {
+		return path
+	}
+	lower := strings.ToLower(path.Link.path)
+	if lower == path.Link.path {
+		return path
+	}
+	link := *path.Link
+	link.path = lower
+	link.updatehash()
+	mapPath := *path
+	mapPath.Link = &link
+	return &mapPath
+}
+
 // SSLPassthrough ...
 func (h *Host) SSLPassthrough() bool {
 	return h.sslPassthrough
@@ -445,6 +581,16 @@ func (l *PathLink) Hostname() string {
 	return l.hostname
 }
 
+// Path ...
+func (l *PathLink) Path() string {
+	return l.path
+}
+
+// Match ...
+func (l *PathLink) Match() MatchType {
+	return l.match
+}
+
 // IsEmpty ...
 func (l *PathLink) IsEmpty() bool {
 	return l.hostname == "" && l.path == ""
@@ -277,3 +277,38 @@ func TestRemovePath(t *testing.T) {
 		c.teardown()
 	}
 }
+
+func TestReplaceHosts(t *testing.T) {
+	hosts := CreateHosts()
+	h1 := hosts.AcquireHost("d1.local")
+	h2 := hosts.AcquireHost("d2.local")
+	h2.SetSSLPassthrough(true)
+	h1old := hosts.createHost("d1.local")
+	h1old.sslPassthrough = true
+	restore := hosts.ReplaceHosts(map[string]*Host{
+		"d1.local": h1old,
+		"d2.local": nil,
+	})
+	if found := hosts.FindHost("d1.local"); found != h1old {
+		t.Errorf("expected replaced host 'd1.local'")
+	}
+	if found := hosts.FindHost("d2.local"); found != nil {
+		t.Errorf("expected removed host 'd2.local'")
+	}
+	if count := hosts.sslPassthroughCount; count != 1 {
+		t.Errorf("expected 1 ssl-passthrough host after replace, found %d", count)
+	}
+	restore()
+	if found := hosts.FindHost("d1.local"); found != h1 {
+		t.Errorf("expected restored host 'd1.local'")
+	}
+	if found := hosts.FindHost("d2.local"); found != h2 {
+		t.Errorf("expected restored host 'd2.local'")
+	}
+	if count := hosts.sslPassthroughCount; count != 1 {
+		t.Errorf("expected 1 ssl-passthrough host after restore, found %d", count)
+	}
+	if len(hosts.Items()) != 2 {
+		t.Errorf("expected 2 hosts after restore")
+	}
+}
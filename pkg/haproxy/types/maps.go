@@ -19,6 +19,7 @@ package types
 import (
 	"container/list"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
@@ -275,6 +276,8 @@ func (hm *HostsMap) rebuildMatchFiles() (matchFiles []*MatchFile) {
 	for e := order.Front(); e != nil; e = e.Next() {
 		i++
 		matchFile := e.Value.(*hostsMapMatchFile)
+		// the suffix is added before the extension, the directory can have dots as well
+		ext := filepath.Ext(hm.basename)
 		var suffix string
 		if matchFile.priority {
 			suffix = fmt.Sprintf("__%s_%02d", matchFile.match, i)
@@ -284,7 +287,7 @@ func (hm *HostsMap) rebuildMatchFiles() (matchFiles []*MatchFile) {
 		matchFile.sort()
 		matchFiles = append(matchFiles, &MatchFile{
 			matchFile: matchFile,
-			filename:  strings.Replace(hm.basename, ".", suffix+".", 1),
+			filename:  strings.TrimSuffix(hm.basename, ext) + suffix + ext,
 			first:     i == 1,
 			last:      false,
 		})
@@ -53,6 +53,7 @@ type Acme struct {
 // Global ...
 type Global struct {
 	Bind                    GlobalBindConfig
+	QUIC                    QUICConfig
 	Procs                   ProcsConfig
 	Syslog                  SyslogConfig
 	MaxConn                 int
@@ -63,21 +64,29 @@ type Global struct {
 	Cookie                  CookieConfig
 	DrainSupport            DrainConfig
 	Acme                    Acme
+	EnableChaos             bool
 	ForwardFor              string
 	OriginalForwardedForHdr string
 	RealIPHdr               string
 	LoadServerState         bool
+	ServerStateFile         bool
+	LintDisabledRules       []string
 	AdminSocket             string
 	LocalFSPrefix           string
 	External                ExternalConfig
 	Healthz                 HealthzConfig
+	HTTPProtocol            HTTPProtocolConfig
 	Master                  MasterConfig
 	MatchOrder              []MatchType
+	Peers                   PeersConfig
 	Prometheus              PromConfig
+	ReloadDefer             ReloadDeferConfig
 	Security                SecurityConfig
 	Stats                   StatsConfig
+	UniqueID                UniqueIDConfig
 	CloseSessionsDuration   time.Duration
 	TimeoutStopDuration     time.Duration
+	ProductionHardening     bool
 	StrictHost              bool
 	UseHTX                  bool
 	DefaultBackendRedir     string
@@ -92,6 +101,49 @@ type Global struct {
 	CustomHTTPHAResponses   []HTTPResponse
 	CustomSections          []string
 	CustomTCP               []string
+	SPOEAgents              []*SPOEAgent
+	TrafficClasses          []*TrafficClass
+}
+
+// TrafficClass is a named combination of request criteria. The http
+// frontends evaluate every class once per request, backends refer to the
+// class by its name.
+type TrafficClass struct {
+	Name     string
+	Criteria []TrafficClassCriterion
+}
+
+// TrafficClassCriterion ...
+type TrafficClassCriterion struct {
+	Fetch      string
+	Match      string
+	Values     []string
+	ValuesFile string
+}
+
+// ReloadDeferConfig configures the deferral of the reloads that are not
+// urgent, while the reload sensitive backends have at least Sessions current
+// sessions. Reloads are deferred up to MaxWait, and are never deferred if
+// Sessions is zero.
+type ReloadDeferConfig struct {
+	Sessions int
+	MaxWait  time.Duration
+}
+
+// PeersConfig describes the peers section used to share stick tables
+// between the haproxy instances of all the controller replicas.
+// The section is not configured if Name is empty.
+type PeersConfig struct {
+	Name      string
+	LocalPeer string
+	Port      int
+	Servers   []PeerServer
+}
+
+// PeerServer is a remote peer, the local one is not listed.
+type PeerServer struct {
+	Name     string
+	Endpoint string
 }
 
 // GlobalBindConfig ...
@@ -100,11 +152,19 @@ type GlobalBindConfig struct {
 	HTTPBind         string
 	HTTPSBind        string
 	TCPBindIP        string
+	TCPBindIPv6      string
+	IPv6             bool
 	FrontingBind     string
 	FrontingSockID   int
 	FrontingUseProto bool
 }
 
+// QUICConfig ...
+type QUICConfig struct {
+	Bind string
+	Port int
+}
+
 // ProcsConfig ...
 type ProcsConfig struct {
 	Nbproc          int
@@ -185,9 +245,23 @@ type DNSNameserver struct {
 // ModSecurityConfig ...
 type ModSecurityConfig struct {
 	Endpoints []string
-	Timeout   ModSecurityTimeoutConfig
+	Timeout   SPOETimeoutConfig
 	Args      []string
 	UseCoraza bool
+	ModeSPOP  bool
+}
+
+// SPOEAgent is an external agent that receives SPOE messages from the
+// backends that attach its filter. The agent name is also used as the
+// SPOE engine name.
+type SPOEAgent struct {
+	Name      string
+	Endpoints []string
+	Messages  []string
+	Args      []string
+	VarPrefix string
+	Timeout   SPOETimeoutConfig
+	ModeSPOP  bool
 }
 
 // CookieConfig ...
@@ -213,6 +287,18 @@ type HealthzConfig struct {
 	Port   int
 }
 
+// HTTPProtocolConfig ...
+type HTTPProtocolConfig struct {
+	ExemptPaths       []string
+	RequireHostHeader bool
+}
+
+// UniqueIDConfig ...
+type UniqueIDConfig struct {
+	Format         string
+	ResponseHeader string
+}
+
 // MasterConfig ...
 type MasterConfig struct {
 	ExitOnFailure    bool
@@ -243,8 +329,8 @@ type StatsConfig struct {
 	TLSHash     string
 }
 
-// ModSecurityTimeoutConfig ...
-type ModSecurityTimeoutConfig struct {
+// SPOETimeoutConfig ...
+type SPOETimeoutConfig struct {
 	// Backend
 	Connect string
 	Server  string
@@ -261,6 +347,19 @@ type HTTPResponse struct {
 	Body         []string
 	StatusCode   int
 	StatusReason string
+	// UniqueIDBody means that the Lua based response should add the
+	// request unique ID in the body, see UniqueIDPlaceholder.
+	UniqueIDBody bool
+	// LogFormat is used instead of the errorfile on HAProxy based responses
+	// that add the request unique ID in the body.
+	LogFormat *HTTPResponseLogFormat
+}
+
+// HTTPResponseLogFormat is the log-format version of a HAProxy based response.
+type HTTPResponseLogFormat struct {
+	ContentType string
+	Body        string
+	Headers     []HTTPHeader
 }
 
 // HTTPHeader ...
@@ -280,6 +379,7 @@ type TCPServicePort struct {
 	port         int
 	hosts        map[string]*TCPServiceHost
 	defaultHost  *TCPServiceHost
+	ALPNRoutes   []*TCPServiceALPNRoute
 	CustomConfig []string
 	LogFormat    string
 	ProxyProt    bool
@@ -288,6 +388,12 @@ type TCPServicePort struct {
 	SNIMap *HostsMap
 }
 
+// TCPServiceALPNRoute ...
+type TCPServiceALPNRoute struct {
+	Protocol string
+	Backend  BackendID
+}
+
 // TCPServiceHost ...
 type TCPServiceHost struct {
 	hostname string
@@ -310,6 +416,7 @@ type TLSConfig struct {
 	CAFilename    string
 	CAHash        string
 	CAVerify      CAVerify
+	CAVerifyDepth int
 	Ciphers       string
 	CipherSuites  string
 	CRLFilename   string
@@ -408,9 +515,16 @@ type FrontendMaps struct {
 	RedirFromRootMap  *HostsMap
 	RedirRootSSLMap   *HostsMap
 	RedirFromMap      *HostsMap
+	RedirFromPortMap  *HostsMap
 	RedirToMap        *HostsMap
 	SSLPassthroughMap *HostsMap
 	VarNamespaceMap   *HostsMap
+	PathNormalizeMap  *HostsMap
+	AuditBackendMap   *HostsMap
+	AuditPercentMap   *HostsMap
+	SplitPathMap      *HostsMap
+	BlockHTTP10Map    *HostsMap
+	QUICAltSvcMap     *HostsMap
 	//
 	TLSAuthList           *HostsMap
 	TLSNeedCrtList        *HostsMap
@@ -422,10 +536,11 @@ type FrontendMaps struct {
 
 // AuthProxy ...
 type AuthProxy struct {
-	BindList   []*AuthProxyBind
-	Name       string
-	RangeEnd   int
-	RangeStart int
+	BindList    []*AuthProxyBind
+	CacheTables []*AuthCacheTable
+	Name        string
+	RangeEnd    int
+	RangeStart  int
 }
 
 // AuthProxyBind ...
@@ -436,6 +551,13 @@ type AuthProxyBind struct {
 	SocketID        int
 }
 
+// AuthCacheTable ...
+type AuthCacheTable struct {
+	Name   string
+	Expire string
+	Size   int64
+}
+
 // Frontend ...
 type Frontend struct {
 	changed     bool
@@ -453,6 +575,8 @@ type Frontend struct {
 	//
 	RedirectFromCode int
 	RedirectToCode   int
+	//
+	RequireHostHeader bool
 }
 
 // DefaultHost ...
@@ -477,8 +601,13 @@ type Host struct {
 	Paths    []*HostPath
 	//
 	Alias                  HostAliasConfig
+	Audit                  HostAuditConfig
+	HTTPProtocol           HostHTTPProtocolConfig
 	Redirect               HostRedirectConfig
+	RedirectIntents        []*HostRedirectIntent
 	HTTPPassthroughBackend string
+	PathNormalization      PathNormalization
+	QUIC                   HostQUICConfig
 	RootRedirect           string
 	TLS                    HostTLSConfig
 	VarNamespace           bool
@@ -487,6 +616,16 @@ type Host struct {
 	sslPassthrough bool
 }
 
+// PathNormalization ...
+type PathNormalization string
+
+// ...
+const (
+	PathNormalizationOff       = PathNormalization("")
+	PathNormalizationStrict    = PathNormalization("strict")
+	PathNormalizationLowercase = PathNormalization("lowercase")
+)
+
 // MatchType ...
 type MatchType string
 
@@ -562,10 +701,51 @@ type HostAliasConfig struct {
 	AliasRegex string
 }
 
+// HostAuditConfig ...
+type HostAuditConfig struct {
+	Backend       BackendID
+	SamplePercent int
+}
+
+// HostQUICConfig ...
+type HostQUICConfig struct {
+	AltSvcMaxAge int
+}
+
+// HostHTTPProtocolConfig ...
+type HostHTTPProtocolConfig struct {
+	BlockHTTP10       bool
+	RequireHostHeader bool
+}
+
 // HostRedirectConfig ...
 type HostRedirectConfig struct {
 	RedirectHost      string
 	RedirectHostRegex string
+	HTTPSPort         int
+}
+
+// RedirectKind ...
+type RedirectKind string
+
+// ...
+const (
+	RedirectAppRoot = RedirectKind("app-root")
+	RedirectFrom    = RedirectKind("redirect-from")
+	RedirectTo      = RedirectKind("redirect-to")
+)
+
+// HostRedirectIntent is a redirect rule declared on a host. Intents are kept
+// in the host so the redirect graph can be validated, and the offending rules
+// removed, after all the hosts are built.
+type HostRedirectIntent struct {
+	Kind RedirectKind
+	// Link is the redirected path of a redirect-to intent, nil otherwise
+	Link *PathLink
+	// Target is the app-root path, the redirect-from hostname,
+	// or the redirect-to URL, depending on Kind
+	Target string
+	Source string
 }
 
 // HostTLSConfig ...
@@ -574,6 +754,9 @@ type HostTLSConfig struct {
 	CAErrorPage    string
 	UseDefaultCrt  bool
 	FollowRedirect bool
+	HTTPOnly       bool
+	// SSLRedirect overrides the global ssl-redirect default of the host paths, nil if not configured
+	SSLRedirect *bool
 }
 
 // EndpointNaming ...
@@ -593,6 +776,7 @@ type EndpointCookieStrategy int
 const (
 	EpCookieName EndpointCookieStrategy = iota
 	EpCookiePodUID
+	EpCookiePodNameHash
 )
 
 // Backends ...
@@ -603,8 +787,28 @@ type Backends struct {
 	shards         []map[string]*Backend
 	changedShards  map[int]bool
 	DefaultBackend *Backend
+	Naming         BackendNaming
+	// ShardByNamespace groups the backends of the same namespace in the
+	// same shard, instead of distributing them by backend ID.
+	ShardByNamespace bool
 }
 
+// BackendNaming defines how the names of the backends are built.
+type BackendNaming int
+
+// ...
+const (
+	// BackendNamingPort builds names in the namespace_name_port format.
+	BackendNamingPort BackendNaming = iota
+	// BackendNamingPortName is the same as BackendNamingPort, the port is
+	// the name of the service port when available. Implemented by the
+	// converters, which choose the port used to acquire the backend.
+	BackendNamingPortName
+	// BackendNamingHash builds names from a short hash of namespace, name
+	// and port.
+	BackendNamingHash
+)
+
 // BackendID ...
 type BackendID struct {
 	id        string
@@ -640,29 +844,50 @@ type Backend struct {
 	//
 	// per backend config
 	//
-	AgentCheck       AgentCheck
-	AllowedIPTCP     AccessConfig
-	BalanceAlgorithm string
-	BlueGreen        BlueGreenConfig
-	Cookie           Cookie
-	CustomConfig     []string
-	DeniedIPTCP      AccessConfig
-	Dynamic          DynBackendConfig
-	EpCookieStrategy EndpointCookieStrategy
-	Headers          []*BackendHeader
-	HealthCheck      HealthCheck
-	Limit            BackendLimit
-	ModeTCP          bool
-	Resolver         string
-	Server           ServerConfig
-	Timeout          BackendTimeoutConfig
-	TLS              BackendTLSConfig
+	AccessDebug         bool
+	AgentCheck          AgentCheck
+	AllDownResponse     BackendAllDownResponse
+	AllowedIPTCP        AccessConfig
+	AuthBruteforce      BackendAuthBruteforce
+	AuthCookieDomains   []string
+	AuthCookieSecure    bool
+	AuthCookieSetSecure bool
+	BalanceAlgorithm    string
+	BandwidthLimit      BackendBandwidthLimit
+	BlueGreen           BlueGreenConfig
+	Chaos               BackendChaos
+	Cookie              Cookie
+	CustomConfig        []string
+	DeniedIPTCP         AccessConfig
+	DNS                 BackendDNS
+	Dynamic             DynBackendConfig
+	EpCookieStrategy    EndpointCookieStrategy
+	Fallback            BackendID
+	ForceCloseUAs       []string
+	ForwardedHeaders    BackendForwardedHeaders
+	Headers             []*BackendHeader
+	HealthCheck         HealthCheck
+	Limit               BackendLimit
+	LoadServerState     bool
+	ModeTCP             bool
+	ReloadSensitive     bool
+	Resolver            string
+	RetryBudgetWarn     float64
+	Server              ServerConfig
+	SPOE                BackendSPOE
+	StickAffinity       BackendStickAffinity
+	Timeout             BackendTimeoutConfig
+	TLS                 BackendTLSConfig
+	TrafficClass        BackendTrafficClass
+	WarmUp              BackendWarmUp
 }
 
 // Endpoint ...
 type Endpoint struct {
 	Enabled     bool
+	Maintenance bool
 	Label       string
+	Group       string
 	IP          string
 	Name        string
 	Port        int
@@ -674,10 +899,54 @@ type Endpoint struct {
 	PUID        int32 // Proxy Unique ID, referenced as "id" in haproxy server lines
 }
 
+// BackendAllDownResponse ...
+type BackendAllDownResponse struct {
+	StatusCode int
+	Location   string
+}
+
 // BlueGreenConfig ...
 type BlueGreenConfig struct {
 	CookieName string
 	HeaderName string
+	AutoPause  BlueGreenAutoPause
+}
+
+// BlueGreenAutoPause configures the analysis of the error rate of the canary
+// group of a blue/green balance. Threshold is zero if the analysis is disabled.
+// Balance has the weights in use, they are kept while the balance is paused.
+type BlueGreenAutoPause struct {
+	Balance   string
+	Paused    bool
+	Threshold float64
+}
+
+// blue/green groups of an endpoint, assigned only if auto pause is enabled
+const (
+	BlueGreenGroupBaseline = "baseline"
+	BlueGreenGroupCanary   = "canary"
+)
+
+// BackendFallbackRule ...
+type BackendFallbackRule struct {
+	Backend     string
+	Target      string
+	Unavailable []string
+}
+
+// BackendClassRule ...
+type BackendClassRule struct {
+	Backend string
+	Class   string
+	Target  string
+}
+
+// BackendSplitRule ...
+type BackendSplitRule struct {
+	SplitID string
+	Target  string
+	From    int
+	To      int
 }
 
 // BackendPathConfig ...
@@ -694,6 +963,8 @@ type BackendPathItem struct {
 // HostResolver ...
 type HostResolver interface {
 	UseTLS() bool
+	HTTPOnly() bool
+	SSLRedirect() (redirect, found bool)
 }
 
 // BackendPath ...
@@ -707,16 +978,54 @@ type BackendPath struct {
 	//
 	// config fields
 	//
-	AllowedIPHTTP AccessConfig
-	AuthHTTP      AuthHTTP
-	AuthExternal  AuthExternal
-	Cors          Cors
-	DeniedIPHTTP  AccessConfig
-	HSTS          HSTS
-	MaxBodySize   int64
-	RewriteURL    string
-	SSLRedirect   bool
-	WAF           WAF
+	AllowedIPHTTP   AccessConfig
+	AuthHTTP        AuthHTTP
+	AuthExternal    AuthExternal
+	AuthJWT         AuthJWT
+	CacheControl    CacheControl
+	Cors            Cors
+	DeniedIPHTTP    AccessConfig
+	EarlyHints      []string
+	HSTS            HSTS
+	MaxBodySize     int64
+	ProxyRedirect   ProxyRedirect
+	RewriteURL      string
+	SSLRedirect     bool
+	SSLRedirectPort int
+	SplitBackends   []BackendSplit
+	Sunset          PathSunset
+	TimeoutServer   string
+	WAF             WAF
+}
+
+// PathSunset announces, in the Deprecation and Sunset response headers,
+// that a path of a deleted ingress resource is going to be removed.
+type PathSunset struct {
+	Deprecation string
+	Sunset      string
+}
+
+// ProxyRedirect rewrites the From prefix of the Location response header
+// to To. Absolute URLs are only rewritten if Host is true.
+type ProxyRedirect struct {
+	From string
+	To   string
+	Host bool
+}
+
+// BackendSplit ...
+type BackendSplit struct {
+	Target BackendID
+	Weight int
+}
+
+// BackendForwardedHeaders has the names of the headers, added to the
+// request, with the original values of the request. An empty name means
+// that the header should not be added.
+type BackendForwardedHeaders struct {
+	Host        string
+	OriginalFor string
+	Port        string
 }
 
 // BackendHeader ...
@@ -752,16 +1061,81 @@ type HealthCheck struct {
 
 // BackendLimit ...
 type BackendLimit struct {
-	Connections int
-	RPS         int
-	Whitelist   []string
+	Connections   int
+	Peers         string
+	RPS           int
+	Whitelist     []string
+	WhitelistFile string
+}
+
+// BackendSPOE ...
+type BackendSPOE struct {
+	Agent       string
+	DenyOnError bool
+}
+
+// BackendTrafficClass ...
+type BackendTrafficClass struct {
+	Deny        []string
+	LimitExempt []string
+	Routes      []BackendClassRoute
+}
+
+// BackendClassRoute ...
+type BackendClassRoute struct {
+	Class  string
+	Target BackendID
+}
+
+// BackendDNS configures the servers of a backend that uses a resolver.
+// HoldValid is only assigned if it differs from the resolver's one, and
+// Slots is only assigned if it should not follow the number of endpoints.
+type BackendDNS struct {
+	HoldValid     string
+	ResolvePrefer string
+	Slots         int
+}
+
+// BackendAuthBruteforce ...
+type BackendAuthBruteforce struct {
+	Limit  int
+	Window string
+	Ban    string
+}
+
+// BackendStickAffinity configures the stick table based affinity, which
+// is enabled if Size is assigned. Clients are identified by the value of
+// the Header request header if assigned, or by their source IP otherwise.
+type BackendStickAffinity struct {
+	Header string
+	Size   int64
+	Expire string
+}
+
+// BackendBandwidthLimit ...
+type BackendBandwidthLimit struct {
+	Download int64
+	Upload   int64
+	Shared   bool
+}
+
+// BackendChaos injects latency, or aborts with an error status, a
+// percentage of the requests, used on resilience tests.
+type BackendChaos struct {
+	AbortPercent   int
+	AbortStatus    int
+	Latency        string
+	LatencyPercent int
 }
 
 // AccessConfig ...
 type AccessConfig struct {
-	Rule         []string
-	Exception    []string
-	SourceHeader string
+	ID            string
+	Rule          []string
+	RuleFile      string
+	Exception     []string
+	ExceptionFile string
+	SourceHeader  string
 }
 
 // ServerConfig ...
@@ -781,10 +1155,20 @@ type ServerConfig struct {
 	Protocol      string
 	Secure        bool
 	SendProxy     string
+	SlowStart     string
 	SNI           string
 	VerifyHost    string
 }
 
+// BackendWarmUp configures the staggered enabling of the servers of a
+// backend after a reload. MinCapacity and AbortCapacity are percentages
+// of the servers of the backend.
+type BackendWarmUp struct {
+	Window        time.Duration
+	MinCapacity   int
+	AbortCapacity int
+}
+
 // BackendTimeoutConfig ...
 type BackendTimeoutConfig struct {
 	Connect     string
@@ -812,28 +1196,56 @@ type UserlistConfig struct {
 
 // Cookie ...
 type Cookie struct {
-	Name     string
-	Domain   string
-	Dynamic  bool
-	Preserve bool
-	SameSite bool
-	Shared   bool
-	Strategy string
-	Keywords string
+	Name       string
+	Domain     string
+	AutoSecure bool
+	Dynamic    bool
+	DynamicKey string
+	Failover   string
+	HTTPOnly   bool
+	MaxIdle    time.Duration
+	MaxLife    time.Duration
+	Preserve   bool
+	SameSite   string
+	Secure     bool
+	Shared     bool
+	Strategy   string
+	Keywords   string
+	URLParam   string
 }
 
 // AuthExternal ...
 type AuthExternal struct {
-	AllowedPath     string
-	AlwaysDeny      bool
-	AuthBackendName string
-	AuthPath        string
-	HeadersFail     []string
-	HeadersRequest  []string
-	HeadersSucceed  []string
-	HeadersVars     map[string]string
-	Method          string
-	RedirectOnFail  string
+	AllowedPath       string
+	AlwaysDeny        bool
+	AuthBackendName   string
+	AuthPath          string
+	BearerTokenVar    string
+	Cache             AuthCache
+	CookieDomain      string
+	CookieSetSecure   bool
+	DenyBody          string
+	DenyStatus        int
+	HeadersFail       []string
+	HeadersRequest    []string
+	HeadersSucceed    []string
+	HeadersStrip      []string
+	HeadersStripRegex []string
+	HeadersVars       map[string]string
+	Method            string
+	ProxyHeaders      []string
+	RedirectOnFail    string
+	RedirHTMLOnly     bool
+	RedirNoXHR        bool
+	SecureCookies     bool
+}
+
+// AuthCache ...
+type AuthCache struct {
+	DenyDuration string
+	Duration     string
+	KeyHeader    string
+	Size         int64
 }
 
 // AuthHTTP ...
@@ -842,6 +1254,29 @@ type AuthHTTP struct {
 	Realm        string
 }
 
+// AuthJWT validates the bearer token of the requests. Issuer and Audience,
+// if not empty, should match the iss and aud claims of the token, which
+// should be signed by one of the Keys. AlwaysDeny denies all the requests,
+// used when the configuration fails.
+type AuthJWT struct {
+	AlwaysDeny bool
+	Audience   string
+	Issuer     string
+	Keys       []AuthJWTKey
+	Realm      string
+}
+
+// AuthJWTKey is a public key used to verify the signature of a token. Algs are
+// the signing algorithms accepted by the key, ID is the kid of the key, if
+// declared, and PEM is the PKIX encoded public key. File is assigned when the
+// configuration is synced.
+type AuthJWTKey struct {
+	Algs []string
+	ID   string
+	PEM  string
+	File string
+}
+
 // Cors ...
 type Cors struct {
 	Enabled bool
@@ -855,6 +1290,13 @@ type Cors struct {
 	MaxAge           int
 }
 
+// CacheControl ...
+type CacheControl struct {
+	CacheControl     string
+	SurrogateControl string
+	IfMissing        bool
+}
+
 // HSTS ...
 type HSTS struct {
 	Enabled    bool
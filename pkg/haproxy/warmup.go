@@ -0,0 +1,217 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/socket"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
+)
+
+// warmUpTick is how often a running warm-up checks the capacity of the
+// backends and enables the servers whose turn has come.
+const warmUpTick = time.Second
+
+// warmUp staggers the enabling of the servers of the backends configured
+// with slow-start after a reload, so they don't ramp up all at once. Part
+// of the servers are put in maintenance when the warm-up starts, and are
+// enabled one by one along the warm-up window. All the remaining servers
+// of a backend are enabled at once if its available capacity drops below
+// the abort threshold.
+type warmUp struct {
+	mutex    sync.Mutex
+	logger   types.Logger
+	socket   socket.HAProxySocket
+	backends []*warmUpBackend
+	stop     chan struct{}
+}
+
+type warmUpBackend struct {
+	id       string
+	servers  int
+	pending  []string
+	abort    int
+	interval time.Duration
+	next     time.Time
+}
+
+// newWarmUp builds the warm-up of the backends that have it configured,
+// or returns nil if no backend need to be warmed up. Backends are expected
+// to be in a stable order, all the servers that can receive requests are
+// used, the first ones are kept enabled.
+func newWarmUp(logger types.Logger, sock socket.HAProxySocket, backends []*hatypes.Backend) *warmUp {
+	var warmUpBackends []*warmUpBackend
+	for _, backend := range backends {
+		cfg := backend.WarmUp
+		if cfg.Window == 0 || backend.Server.SlowStart == "" {
+			continue
+		}
+		var servers []string
+		for _, ep := range backend.Endpoints {
+			if ep.Enabled && !ep.Maintenance && ep.Weight > 0 {
+				servers = append(servers, ep.Name)
+			}
+		}
+		// rounding up, the min capacity should always be preserved
+		keep := (len(servers)*cfg.MinCapacity + 99) / 100
+		if keep < 1 {
+			keep = 1
+		}
+		if keep >= len(servers) {
+			continue
+		}
+		pending := servers[keep:]
+		warmUpBackends = append(warmUpBackends, &warmUpBackend{
+			id:       backend.ID,
+			servers:  len(servers),
+			pending:  pending,
+			abort:    (len(servers)*cfg.AbortCapacity + 99) / 100,
+			interval: cfg.Window / time.Duration(len(pending)),
+		})
+	}
+	if len(warmUpBackends) == 0 {
+		return nil
+	}
+	return &warmUp{
+		logger:   logger,
+		socket:   sock,
+		backends: warmUpBackends,
+		stop:     make(chan struct{}),
+	}
+}
+
+// start puts the servers waiting for their turn in maintenance.
+func (w *warmUp) start(now time.Time) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var cmd []string
+	for _, backend := range w.backends {
+		backend.next = now.Add(backend.interval)
+		for _, server := range backend.pending {
+			cmd = append(cmd, fmt.Sprintf("set server %s/%s state maint", backend.id, server))
+		}
+		w.logger.Info("warming up backend '%s': enabling %d of %d servers along %s",
+			backend.id, len(backend.pending), backend.servers, backend.interval*time.Duration(len(backend.pending)))
+	}
+	_, err := w.socket.Send(nil, cmd...)
+	return err
+}
+
+// run calls step on every tick, until the warm-up finishes or is stopped.
+func (w *warmUp) run() {
+	ticker := time.NewTicker(warmUpTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-ticker.C:
+			if w.step(now) {
+				return
+			}
+		}
+	}
+}
+
+// step aborts the warm-up of the backends whose available capacity is below
+// the abort threshold, and enables the servers whose turn has come. step
+// returns true if the warm-up of all the backends is finished.
+func (w *warmUp) step(now time.Time) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.backends) == 0 {
+		return true
+	}
+	// type 2 filters out everything but the backend proxies
+	msg, err := w.socket.Send(nil, "show stat -1 2 -1")
+	if err != nil {
+		// capacity is unknown, enabling everything is the safest option
+		w.logger.Error("error reading capacity of the backends in warm-up, enabling all the servers: %v", err)
+		w.enableAll()
+		return true
+	}
+	stats := parseBackendStats(msg[0])
+	var cmd []string
+	var backends []*warmUpBackend
+	for _, backend := range w.backends {
+		stat, found := stats[backend.id]
+		if !found || stat.available < backend.abort {
+			w.logger.Warn("aborting warm-up of backend '%s', %d of %d servers available: enabling %d pending server(s)",
+				backend.id, stat.available, backend.servers, len(backend.pending))
+			cmd = append(cmd, backend.enableCmd(len(backend.pending))...)
+			continue
+		}
+		var count int
+		for ; count < len(backend.pending) && !now.Before(backend.next); count++ {
+			backend.next = backend.next.Add(backend.interval)
+		}
+		cmd = append(cmd, backend.enableCmd(count)...)
+		if len(backend.pending) > 0 {
+			backends = append(backends, backend)
+		} else {
+			w.logger.InfoV(2, "warm-up of backend '%s' finished", backend.id)
+		}
+	}
+	w.backends = backends
+	if len(cmd) > 0 {
+		if _, err := w.socket.Send(nil, cmd...); err != nil {
+			w.logger.Error("error enabling servers of the backends in warm-up: %v", err)
+		}
+	}
+	return len(w.backends) == 0
+}
+
+// finish stops the warm-up and enables all the servers still in maintenance.
+// It is safe to be called more than once.
+func (w *warmUp) finish() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	w.enableAll()
+}
+
+func (w *warmUp) enableAll() {
+	var cmd []string
+	for _, backend := range w.backends {
+		cmd = append(cmd, backend.enableCmd(len(backend.pending))...)
+	}
+	w.backends = nil
+	if len(cmd) > 0 {
+		if _, err := w.socket.Send(nil, cmd...); err != nil {
+			w.logger.Error("error enabling servers of the backends in warm-up: %v", err)
+		}
+	}
+}
+
+// enableCmd removes the first count servers from the pending list, and
+// returns the commands that enable them.
+func (b *warmUpBackend) enableCmd(count int) []string {
+	cmd := make([]string, 0, count)
+	for _, server := range b.pending[:count] {
+		cmd = append(cmd, fmt.Sprintf("set server %s/%s state ready", b.id, server))
+	}
+	b.pending = b.pending[count:]
+	return cmd
+}
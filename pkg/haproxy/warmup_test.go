@@ -0,0 +1,266 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package haproxy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylelemons/godebug/diff"
+
+	ha_helper "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/helper_test"
+	hatypes "github.com/jcmoraisjr/haproxy-ingress/pkg/haproxy/types"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types/helper_test"
+)
+
+func TestWarmUp(t *testing.T) {
+	type step struct {
+		elapsed   time.Duration
+		available map[string]int
+		sockErr   error
+		finish    bool
+		expCmd    string
+		expDone   bool
+		logging   string
+	}
+	type backend struct {
+		id      string
+		servers int
+		warmUp  hatypes.BackendWarmUp
+		noSlow  bool
+	}
+	warmUp := func(window time.Duration, minCapacity, abortCapacity int) hatypes.BackendWarmUp {
+		return hatypes.BackendWarmUp{Window: window, MinCapacity: minCapacity, AbortCapacity: abortCapacity}
+	}
+	testCases := map[string]struct {
+		backends []backend
+		expStart string
+		logging  string
+		steps    []step
+	}{
+		"no warm-up configured": {
+			backends: []backend{{id: "d_app_8080", servers: 4}},
+		},
+		"missing slow-start": {
+			backends: []backend{{id: "d_app_8080", servers: 4, warmUp: warmUp(time.Minute, 50, 25), noSlow: true}},
+		},
+		"min capacity keeps all the servers": {
+			backends: []backend{{id: "d_app_8080", servers: 3, warmUp: warmUp(time.Minute, 90, 25)}},
+		},
+		"enable along the window": {
+			backends: []backend{{id: "d_app_8080", servers: 4, warmUp: warmUp(time.Minute, 50, 25)}},
+			expStart: `
+set server d_app_8080/srv003 state maint
+set server d_app_8080/srv004 state maint`,
+			logging: `INFO warming up backend 'd_app_8080': enabling 2 of 4 servers along 1m0s`,
+			steps: []step{
+				{
+					elapsed:   10 * time.Second,
+					available: map[string]int{"d_app_8080": 2},
+					expCmd:    `show stat -1 2 -1`,
+				},
+				{
+					elapsed:   20 * time.Second,
+					available: map[string]int{"d_app_8080": 2},
+					expCmd: `
+show stat -1 2 -1
+set server d_app_8080/srv003 state ready`,
+				},
+				{
+					elapsed:   30 * time.Second,
+					available: map[string]int{"d_app_8080": 3},
+					expCmd: `
+show stat -1 2 -1
+set server d_app_8080/srv004 state ready`,
+					expDone: true,
+					logging: `INFO-V(2) warm-up of backend 'd_app_8080' finished`,
+				},
+			},
+		},
+		"late tick enables all the servers whose turn has come": {
+			backends: []backend{{id: "d_app_8080", servers: 10, warmUp: warmUp(50*time.Second, 50, 25)}},
+			expStart: `
+set server d_app_8080/srv006 state maint
+set server d_app_8080/srv007 state maint
+set server d_app_8080/srv008 state maint
+set server d_app_8080/srv009 state maint
+set server d_app_8080/srv010 state maint`,
+			logging: `INFO warming up backend 'd_app_8080': enabling 5 of 10 servers along 50s`,
+			steps: []step{
+				{
+					elapsed:   35 * time.Second,
+					available: map[string]int{"d_app_8080": 5},
+					expCmd: `
+show stat -1 2 -1
+set server d_app_8080/srv006 state ready
+set server d_app_8080/srv007 state ready
+set server d_app_8080/srv008 state ready`,
+				},
+			},
+		},
+		"abort below the safety threshold": {
+			backends: []backend{
+				{id: "d_app1_8080", servers: 4, warmUp: warmUp(time.Minute, 50, 40)},
+				{id: "d_app2_8080", servers: 2, warmUp: warmUp(time.Minute, 50, 25)},
+			},
+			expStart: `
+set server d_app1_8080/srv003 state maint
+set server d_app1_8080/srv004 state maint
+set server d_app2_8080/srv002 state maint`,
+			logging: `
+INFO warming up backend 'd_app1_8080': enabling 2 of 4 servers along 1m0s
+INFO warming up backend 'd_app2_8080': enabling 1 of 2 servers along 1m0s`,
+			steps: []step{
+				{
+					elapsed:   10 * time.Second,
+					available: map[string]int{"d_app1_8080": 1, "d_app2_8080": 1},
+					expCmd: `
+show stat -1 2 -1
+set server d_app1_8080/srv003 state ready
+set server d_app1_8080/srv004 state ready`,
+					logging: `WARN aborting warm-up of backend 'd_app1_8080', 1 of 4 servers available: enabling 2 pending server(s)`,
+				},
+				{
+					elapsed:   10 * time.Second,
+					available: map[string]int{"d_app1_8080": 4},
+					expCmd: `
+show stat -1 2 -1
+set server d_app2_8080/srv002 state ready`,
+					expDone: true,
+					logging: `WARN aborting warm-up of backend 'd_app2_8080', 0 of 2 servers available: enabling 1 pending server(s)`,
+				},
+			},
+		},
+		"enable all on socket failure": {
+			backends: []backend{{id: "d_app_8080", servers: 3, warmUp: warmUp(time.Minute, 50, 25)}},
+			expStart: `
+set server d_app_8080/srv003 state maint`,
+			logging: `INFO warming up backend 'd_app_8080': enabling 1 of 3 servers along 1m0s`,
+			steps: []step{
+				{
+					elapsed: 10 * time.Second,
+					sockErr: fmt.Errorf("socket timeout"),
+					expCmd: `
+show stat -1 2 -1
+set server d_app_8080/srv003 state ready`,
+					expDone: true,
+					logging: `
+ERROR error reading capacity of the backends in warm-up, enabling all the servers: socket timeout
+ERROR error enabling servers of the backends in warm-up: socket timeout`,
+				},
+			},
+		},
+		"finish enables all the pending servers": {
+			backends: []backend{{id: "d_app_8080", servers: 4, warmUp: warmUp(time.Minute, 25, 0)}},
+			expStart: `
+set server d_app_8080/srv002 state maint
+set server d_app_8080/srv003 state maint
+set server d_app_8080/srv004 state maint`,
+			logging: `INFO warming up backend 'd_app_8080': enabling 3 of 4 servers along 1m0s`,
+			steps: []step{
+				{
+					elapsed:   20 * time.Second,
+					available: map[string]int{"d_app_8080": 1},
+					expCmd: `
+show stat -1 2 -1
+set server d_app_8080/srv002 state ready`,
+				},
+				{
+					finish: true,
+					expCmd: `
+set server d_app_8080/srv003 state ready
+set server d_app_8080/srv004 state ready`,
+					expDone: true,
+				},
+				{
+					finish:  true,
+					expDone: true,
+				},
+			},
+		},
+	}
+	compareCmd := func(name string, actual, expected string) {
+		actual = "\n" + actual
+		expected = "\n" + strings.Trim(expected, "\n")
+		if actual != expected {
+			t.Errorf("%s differs:\n%s", name, diff.Diff(expected, actual))
+		}
+	}
+	for name, test := range testCases {
+		t.Run(name, func(t *testing.T) {
+			logger := helper_test.NewLoggerMock(t)
+			var backends []*hatypes.Backend
+			for _, b := range test.backends {
+				backend := &hatypes.Backend{ID: b.id, WarmUp: b.warmUp}
+				if !b.noSlow {
+					backend.Server.SlowStart = "30s"
+				}
+				for j := 1; j <= b.servers; j++ {
+					backend.Endpoints = append(backend.Endpoints, &hatypes.Endpoint{
+						Enabled: true,
+						Name:    fmt.Sprintf("srv%03d", j),
+						Weight:  1,
+					})
+				}
+				// empty slot, always skipped
+				backend.Endpoints = append(backend.Endpoints, &hatypes.Endpoint{Name: "srv999"})
+				backends = append(backends, backend)
+			}
+			sock := &ha_helper.SocketMock{}
+			w := newWarmUp(logger, sock, backends)
+			if w == nil {
+				if test.expStart != "" {
+					t.Fatalf("expected a warm-up")
+				}
+				return
+			}
+			now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+			if err := w.start(now); err != nil {
+				t.Fatalf("error starting warm-up: %v", err)
+			}
+			compareCmd("start", sock.Commands(), test.expStart)
+			logger.CompareLogging(test.logging)
+			for i, step := range test.steps {
+				now = now.Add(step.elapsed)
+				var stat strings.Builder
+				stat.WriteString("# pxname,svname,act,bck\n")
+				for _, b := range test.backends {
+					fmt.Fprintf(&stat, "%s,BACKEND,%d,0\n", b.id, step.available[b.id])
+				}
+				sock := &ha_helper.SocketMock{
+					CmdOutput: map[string][]string{"show stat -1 2 -1": {stat.String()}},
+					Err:       step.sockErr,
+				}
+				w.socket = sock
+				var done bool
+				if step.finish {
+					w.finish()
+					done = len(w.backends) == 0
+				} else {
+					done = w.step(now)
+				}
+				if done != step.expDone {
+					t.Errorf("done differs on step %d - expected: %t, actual: %t", i, step.expDone, done)
+				}
+				compareCmd(fmt.Sprintf("commands on step %d", i), sock.Commands(), step.expCmd)
+				logger.CompareLogging(step.logging)
+			}
+		})
+	}
+}
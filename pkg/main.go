@@ -29,6 +29,8 @@ import (
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/config"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/launch"
 	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/legacy"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/migration"
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/controller/snapshot"
 )
 
 func main() {
@@ -47,10 +49,22 @@ func run() {
 	if err != nil {
 		log.Fatalf("unable to parse command-line arguments: %s\n", err)
 	}
+	if opt.SnapshotDir != "" {
+		if err := snapshot.Run(opt); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 	cfg, err := config.Create(opt)
 	if err != nil {
 		log.Fatalf("unable to parse static config: %s\n", err)
 	}
+	if cfg.MigrationReport {
+		if err := migration.Run(cfg); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 	if err := launch.Run(cfg); err != nil {
 		log.Fatal(err.Error())
 	}
@@ -19,23 +19,55 @@ package helper_test
 import (
 	"testing"
 	"time"
+
+	"github.com/jcmoraisjr/haproxy-ingress/pkg/types"
 )
 
 // MetricsMock ...
 type MetricsMock struct {
-	Logging []string
-	T       *testing.T
+	Logging            []string
+	T                  *testing.T
+	SocketCmdAttempted int
+	SocketCmdSucceeded int
+	SocketCmdFailed    int
+	BackendRetries     map[string]int
+	BackendRedispatch  map[string]int
+	LintFindings       map[string]int
+	AnnotationLimits   map[string]int
+	AnnotationsDropped map[string]int
+	HostClassConflicts map[string]int
+	EndpointsMaint     map[string]int
+	ACLListsSpilled    map[string]int
+	ModelLimitRejected map[string]int
+	PeerSessions       map[string]int
+	BackendLoad        map[string]types.BackendLoad
 }
 
 // NewMetricsMock ...
 func NewMetricsMock() *MetricsMock {
-	return &MetricsMock{}
+	return &MetricsMock{
+		BackendRetries:     map[string]int{},
+		BackendRedispatch:  map[string]int{},
+		LintFindings:       map[string]int{},
+		AnnotationLimits:   map[string]int{},
+		AnnotationsDropped: map[string]int{},
+		HostClassConflicts: map[string]int{},
+		EndpointsMaint:     map[string]int{},
+		ACLListsSpilled:    map[string]int{},
+		ModelLimitRejected: map[string]int{},
+		PeerSessions:       map[string]int{},
+		BackendLoad:        map[string]types.BackendLoad{},
+	}
 }
 
 // HAProxyShowInfoResponseTime ...
 func (m *MetricsMock) HAProxyShowInfoResponseTime(duration time.Duration) {
 }
 
+// HAProxyShowStatResponseTime ...
+func (m *MetricsMock) HAProxyShowStatResponseTime(duration time.Duration) {
+}
+
 // HAProxySetServerResponseTime ...
 func (m *MetricsMock) HAProxySetServerResponseTime(duration time.Duration) {
 }
@@ -44,6 +76,21 @@ func (m *MetricsMock) HAProxySetServerResponseTime(duration time.Duration) {
 func (m *MetricsMock) HAProxySetSSLCertResponseTime(duration time.Duration) {
 }
 
+// AddSocketCmdAttempted ...
+func (m *MetricsMock) AddSocketCmdAttempted(count int) {
+	m.SocketCmdAttempted += count
+}
+
+// AddSocketCmdSucceeded ...
+func (m *MetricsMock) AddSocketCmdSucceeded(count int) {
+	m.SocketCmdSucceeded += count
+}
+
+// AddSocketCmdFailed ...
+func (m *MetricsMock) AddSocketCmdFailed(count int) {
+	m.SocketCmdFailed += count
+}
+
 // ControllerProcTime ...
 func (m *MetricsMock) ControllerProcTime(task string, duration time.Duration) {
 
@@ -53,6 +100,38 @@ func (m *MetricsMock) ControllerProcTime(task string, duration time.Duration) {
 func (m *MetricsMock) AddIdleFactor(idle int) {
 }
 
+// AddBackendRetries ...
+func (m *MetricsMock) AddBackendRetries(backend string, count int) {
+	m.BackendRetries[backend] += count
+}
+
+// AddBackendRedispatches ...
+func (m *MetricsMock) AddBackendRedispatches(backend string, count int) {
+	m.BackendRedispatch[backend] += count
+}
+
+// IncAnnotationLimit ...
+func (m *MetricsMock) IncAnnotationLimit(limit string) {
+	m.AnnotationLimits[limit]++
+}
+
+// IncAnnotationDropped ...
+func (m *MetricsMock) IncAnnotationDropped(namespace, class, key string) {
+	if class != "" {
+		key += "@" + class
+	}
+	m.AnnotationsDropped[namespace+"/"+key]++
+}
+
+// IncHostClassConflict ...
+func (m *MetricsMock) IncHostClassConflict(class string) {
+	m.HostClassConflicts[class]++
+}
+
+// IncHealthPushFailure ...
+func (m *MetricsMock) IncHealthPushFailure() {
+}
+
 // IncUpdateNoop ...
 func (m *MetricsMock) IncUpdateNoop() {
 }
@@ -77,6 +156,56 @@ func (m *MetricsMock) SetCertExpireDate(domain, cn string, notAfter *time.Time)
 func (m *MetricsMock) ClearCertExpire() {
 }
 
+// SetEndpointsMaintenance ...
+func (m *MetricsMock) SetEndpointsMaintenance(backend string, count int) {
+	if count == 0 {
+		delete(m.EndpointsMaint, backend)
+		return
+	}
+	m.EndpointsMaint[backend] = count
+}
+
+// SetACLListsSpilled ...
+func (m *MetricsMock) SetACLListsSpilled(section string, count int) {
+	if count == 0 {
+		delete(m.ACLListsSpilled, section)
+		return
+	}
+	m.ACLListsSpilled[section] = count
+}
+
+// SetModelLimitRejected ...
+func (m *MetricsMock) SetModelLimitRejected(limit string, count int) {
+	if count == 0 {
+		delete(m.ModelLimitRejected, limit)
+		return
+	}
+	m.ModelLimitRejected[limit] = count
+}
+
+// SetLintFindings ...
+func (m *MetricsMock) SetLintFindings(rule string, count int) {
+	if count == 0 {
+		delete(m.LintFindings, rule)
+		return
+	}
+	m.LintFindings[rule] = count
+}
+
+// SetPeerSessions ...
+func (m *MetricsMock) SetPeerSessions(status string, count int) {
+	m.PeerSessions[status] = count
+}
+
+// SetBackendLoad ...
+func (m *MetricsMock) SetBackendLoad(namespace, service string, load *types.BackendLoad) {
+	if load == nil {
+		delete(m.BackendLoad, namespace+"/"+service)
+		return
+	}
+	m.BackendLoad[namespace+"/"+service] = *load
+}
+
 // IncCertSigningMissing ...
 func (m *MetricsMock) IncCertSigningMissing(domains string, success bool) {
 }
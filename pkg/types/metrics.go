@@ -20,19 +20,43 @@ import (
 	"time"
 )
 
+// BackendLoad is the load of the backends of a service, used as a scaling signal.
+type BackendLoad struct {
+	Sessions     int
+	Queue        int
+	ConnectTime  time.Duration
+	ResponseTime time.Duration
+}
+
 // Metrics ...
 type Metrics interface {
 	HAProxyShowInfoResponseTime(duration time.Duration)
+	HAProxyShowStatResponseTime(duration time.Duration)
 	HAProxySetServerResponseTime(duration time.Duration)
 	HAProxySetSSLCertResponseTime(duration time.Duration)
+	AddSocketCmdAttempted(count int)
+	AddSocketCmdSucceeded(count int)
+	AddSocketCmdFailed(count int)
 	ControllerProcTime(task string, duration time.Duration)
 	AddIdleFactor(idle int)
+	AddBackendRetries(backend string, count int)
+	AddBackendRedispatches(backend string, count int)
+	IncAnnotationLimit(limit string)
+	IncAnnotationDropped(namespace, class, key string)
+	IncHostClassConflict(class string)
+	IncHealthPushFailure()
 	IncUpdateNoop()
 	IncUpdateDynamic()
 	IncUpdateFull()
 	UpdateSuccessful(success bool)
 	SetCertExpireDate(domain, cn string, notAfter *time.Time)
 	ClearCertExpire()
+	SetEndpointsMaintenance(backend string, count int)
+	SetACLListsSpilled(section string, count int)
+	SetModelLimitRejected(limit string, count int)
+	SetLintFindings(rule string, count int)
+	SetPeerSessions(status string, count int)
+	SetBackendLoad(namespace, service string, load *BackendLoad)
 	IncCertSigningMissing(domains string, success bool)
 	IncCertSigningExpiring(domains string, success bool)
 	IncCertSigningOutdated(domains string, success bool)
@@ -0,0 +1,230 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Durations and sizes are parsed per key class, so `15m` is 15 minutes on a
+// duration key and 15 MiB on a size key. Both syntaxes, the HAProxy one, eg
+// `500ms`, `1d` and `64k`, and the Kubernetes and Go one, eg `1.5s`, `1h30m`
+// and `64Ki`, are accepted. Durations already in the HAProxy syntax are used
+// as declared, other ones are formatted in the HAProxy syntax using the
+// largest unit that represents the value exactly.
+
+type unit struct {
+	name string
+	mult int64
+}
+
+// durationUnits are the units in the HAProxy time format, from the largest one.
+var durationUnits = []unit{
+	{"d", int64(24 * time.Hour)},
+	{"h", int64(time.Hour)},
+	{"m", int64(time.Minute)},
+	{"s", int64(time.Second)},
+	{"ms", int64(time.Millisecond)},
+	{"us", int64(time.Microsecond)},
+}
+
+// durationAliases are the other accepted spellings of the duration units.
+var durationAliases = map[string]string{
+	"µs":      "us",
+	"sec":     "s",
+	"min":     "m",
+	"mins":    "m",
+	"hr":      "h",
+	"day":     "d",
+	"days":    "d",
+	"seconds": "s",
+	"minutes": "m",
+	"hours":   "h",
+}
+
+// sizeUnits are the units in the HAProxy size format, from the largest one.
+// Units are binary multiples, so `1k` and `1Ki` are 1024 bytes.
+var sizeUnits = []unit{
+	{"g", 1 << 30},
+	{"m", 1 << 20},
+	{"k", 1 << 10},
+}
+
+var (
+	durationTermRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([a-zµ]+)`)
+	haproxyTimeRegex  = regexp.MustCompile(`^[0-9]+(us|ms|s|m|h|d)$`)
+	numberRegex       = regexp.MustCompile(`^[0-9]+(?:\.[0-9]+)?$`)
+	sizeRegex         = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([A-Za-z]*)$`)
+)
+
+func findUnit(units []unit, name string) (unit, bool) {
+	for _, u := range units {
+		if u.name == name {
+			return u, true
+		}
+	}
+	return unit{}, false
+}
+
+// ParseDuration parses a duration in the HAProxy time format, eg `500ms` or
+// `1d`, or in the Kubernetes and Go format, eg `1.5s` or `1h30m`. The unit is
+// mandatory. Durations must be multiple of a microsecond, the smallest
+// HAProxy time unit.
+func ParseDuration(value string) (duration time.Duration, err error) {
+	if value == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+	if numberRegex.MatchString(value) {
+		return 0, fmt.Errorf("duration %q without unit", value)
+	}
+	for remaining := value; remaining != ""; {
+		term := durationTermRegex.FindStringSubmatch(remaining)
+		if term == nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		name := term[2]
+		if alias, found := durationAliases[name]; found {
+			name = alias
+		}
+		u, found := findUnit(durationUnits, name)
+		if !found {
+			return 0, fmt.Errorf("unknown unit %q in duration %q", term[2], value)
+		}
+		d, exact, ok := multiply(term[1], u.mult)
+		if !ok || int64(duration) > math.MaxInt64-d {
+			return 0, fmt.Errorf("duration %q is out of range", value)
+		}
+		if !exact {
+			return 0, fmt.Errorf("duration %q should be a multiple of 1us", value)
+		}
+		duration += time.Duration(d)
+		remaining = remaining[len(term[0]):]
+	}
+	if duration%time.Microsecond != 0 {
+		return 0, fmt.Errorf("duration %q should be a multiple of 1us", value)
+	}
+	return duration, nil
+}
+
+// FormatDuration formats duration in the HAProxy time format, using the
+// largest unit that represents duration exactly. Fractions of a microsecond
+// are rounded.
+func FormatDuration(duration time.Duration) string {
+	duration = duration.Round(time.Microsecond)
+	if duration <= 0 {
+		return "0s"
+	}
+	for _, u := range durationUnits {
+		if int64(duration)%u.mult == 0 {
+			return strconv.FormatInt(int64(duration)/u.mult, 10) + u.name
+		}
+	}
+	// not reached, us divides any rounded duration
+	return duration.String()
+}
+
+// HAProxyTime returns a valid duration, see ParseDuration, in the HAProxy
+// time format. Durations already in this format are returned as declared,
+// other ones are formatted with FormatDuration.
+func HAProxyTime(value string) (string, error) {
+	duration, err := ParseDuration(value)
+	if err != nil {
+		return "", err
+	}
+	if haproxyTimeRegex.MatchString(value) {
+		return value, nil
+	}
+	return FormatDuration(duration), nil
+}
+
+// ParseSize parses a size in bytes in the HAProxy size format, eg `64k`, or
+// in the Kubernetes format, eg `64Ki`. k, m and g, and their kb, ki and kib
+// variants in any case, are binary multiples: `1k`, `1KB` and `1Ki` are 1024
+// bytes. unitless is true if value does not declare a unit, it is read as
+// bytes. Fractions are accepted if the size is a whole number of bytes.
+func ParseSize(value string) (size int64, unitless bool, err error) {
+	if value == "" {
+		return 0, false, fmt.Errorf("empty size")
+	}
+	match := sizeRegex.FindStringSubmatch(value)
+	if match == nil {
+		return 0, false, fmt.Errorf("invalid size %q", value)
+	}
+	name := strings.ToLower(match[2])
+	mult := int64(1)
+	if name != "" && name != "b" {
+		u, found := findUnit(sizeUnits, strings.TrimSuffix(strings.TrimSuffix(name, "b"), "i"))
+		if !found {
+			return 0, false, fmt.Errorf("unknown unit %q in size %q", match[2], value)
+		}
+		mult = u.mult
+	}
+	size, exact, ok := multiply(match[1], mult)
+	if !ok {
+		return 0, false, fmt.Errorf("size %q is out of range", value)
+	}
+	if !exact {
+		return 0, false, fmt.Errorf("size %q is not a whole number of bytes", value)
+	}
+	return size, name == "", nil
+}
+
+// FormatSize formats size in the HAProxy size format, using the largest unit
+// that represents size exactly.
+func FormatSize(size int64) string {
+	if size != 0 {
+		for _, u := range sizeUnits {
+			if size%u.mult == 0 {
+				return strconv.FormatInt(size/u.mult, 10) + u.name
+			}
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
+
+// multiply converts number, an integer or a decimal number, to an integer
+// amount of mult. exact is false if the result needed to be truncated.
+func multiply(number string, mult int64) (n int64, exact bool, ok bool) {
+	intPart, fracPart, _ := strings.Cut(number, ".")
+	n, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || n > math.MaxInt64/mult {
+		return 0, false, false
+	}
+	n *= mult
+	if fracPart == "" {
+		return n, true, true
+	}
+	if len(fracPart) > 9 {
+		// finer than 1ns on a second, or than 1 byte on a gigabyte
+		return 0, false, false
+	}
+	frac, _ := strconv.ParseInt(fracPart, 10, 64)
+	den := int64(math.Pow10(len(fracPart)))
+	// frac is lower than den, so neither product overflows
+	fracMult := frac * (mult / den)
+	rem := frac * (mult % den)
+	fracMult += rem / den
+	if n > math.MaxInt64-fracMult {
+		return 0, false, false
+	}
+	return n + fracMult, rem%den == 0, true
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestCanonicalDuration(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+		err      string
+	}{
+		// haproxy syntax, kept as declared
+		{value: "0s", expected: "0s"},
+		{value: "500us", expected: "500us"},
+		{value: "1000us", expected: "1000us"},
+		{value: "500ms", expected: "500ms"},
+		{value: "1000ms", expected: "1000ms"},
+		{value: "60s", expected: "60s"},
+		{value: "15m", expected: "15m"},
+		{value: "120m", expected: "120m"},
+		{value: "24h", expected: "24h"},
+		{value: "7d", expected: "7d"},
+		// kubernetes and go syntax
+		{value: "1.5s", expected: "1500ms"},
+		{value: "0.5m", expected: "30s"},
+		{value: "1h30m", expected: "90m"},
+		{value: "1m30s", expected: "90s"},
+		{value: "2h0m0s", expected: "2h"},
+		{value: "1.5h", expected: "90m"},
+		{value: "250µs", expected: "250us"},
+		{value: "15min", expected: "15m"},
+		{value: "30sec", expected: "30s"},
+		{value: "1day", expected: "1d"},
+		{value: "60sec", expected: "1m"},
+		// invalid
+		{value: "", err: `empty duration`},
+		{value: "0", err: `duration "0" without unit`},
+		{value: "1500", err: `duration "1500" without unit`},
+		{value: "2.5", err: `duration "2.5" without unit`},
+		{value: "15M", err: `invalid duration "15M"`},
+		{value: "10x", err: `unknown unit "x" in duration "10x"`},
+		{value: "1ns", err: `unknown unit "ns" in duration "1ns"`},
+		{value: "-1s", err: `invalid duration "-1s"`},
+		{value: "1s 500ms", err: `invalid duration "1s 500ms"`},
+		{value: "1.0000001s", err: `duration "1.0000001s" should be a multiple of 1us`},
+		{value: "1000000000d", err: `duration "1000000000d" is out of range`},
+	}
+	for i, test := range testCases {
+		actual, err := HAProxyTime(test.value)
+		var errStr string
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.err {
+			t.Errorf("error differs on %d (%s) - expected: %s - actual: %s", i, test.value, test.err, errStr)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("duration differs on %d (%s) - expected: %s - actual: %s", i, test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestCanonicalSize(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+		bytes    int64
+		unitless bool
+		err      string
+	}{
+		// haproxy syntax
+		{value: "10k", expected: "10k", bytes: 10240},
+		{value: "10K", expected: "10k", bytes: 10240},
+		{value: "1024k", expected: "1m", bytes: 1048576},
+		{value: "15m", expected: "15m", bytes: 15728640},
+		{value: "2048m", expected: "2g", bytes: 2147483648},
+		{value: "1g", expected: "1g", bytes: 1073741824},
+		// kubernetes syntax and variants, all of them binary
+		{value: "64Ki", expected: "64k", bytes: 65536},
+		{value: "1Mi", expected: "1m", bytes: 1048576},
+		{value: "1Gi", expected: "1g", bytes: 1073741824},
+		{value: "10mb", expected: "10m", bytes: 10485760},
+		{value: "10MB", expected: "10m", bytes: 10485760},
+		{value: "1MiB", expected: "1m", bytes: 1048576},
+		{value: "1.5m", expected: "1536k", bytes: 1572864},
+		{value: "0.5k", expected: "512", bytes: 512},
+		{value: "100b", expected: "100", bytes: 100},
+		// unit-less values are bytes
+		{value: "0", expected: "0", unitless: true},
+		{value: "1000", expected: "1000", bytes: 1000, unitless: true},
+		{value: "4096", expected: "4k", bytes: 4096, unitless: true},
+		// invalid
+		{value: "", err: `empty size`},
+		{value: "10e", err: `unknown unit "e" in size "10e"`},
+		{value: "10mbps", err: `unknown unit "mbps" in size "10mbps"`},
+		{value: "1t", err: `unknown unit "t" in size "1t"`},
+		{value: "-1k", err: `invalid size "-1k"`},
+		{value: "1.3k", err: `size "1.3k" is not a whole number of bytes`},
+		{value: "1.5", err: `size "1.5" is not a whole number of bytes`},
+		{value: "10000000000g", err: `size "10000000000g" is out of range`},
+	}
+	for i, test := range testCases {
+		size, unitless, err := ParseSize(test.value)
+		var errStr string
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != test.err {
+			t.Errorf("error differs on %d (%s) - expected: %s - actual: %s", i, test.value, test.err, errStr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if size != test.bytes {
+			t.Errorf("size differs on %d (%s) - expected: %d - actual: %d", i, test.value, test.bytes, size)
+		}
+		if actual := FormatSize(size); actual != test.expected {
+			t.Errorf("formatted size differs on %d (%s) - expected: %s - actual: %s", i, test.value, test.expected, actual)
+		}
+		if unitless != test.unitless {
+			t.Errorf("unitless differs on %d (%s) - expected: %t - actual: %t", i, test.value, test.unitless, unitless)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var haproxyVersionRegex = regexp.MustCompile(`HA-?Proxy version ([0-9]+\.[0-9]+(\.[0-9]+)?)`)
+
+// HAProxyVersion returns the major.minor[.patch] version of the haproxy
+// binary found in the PATH, or an empty string if the version cannot be read.
+func HAProxyVersion() string {
+	out, err := exec.Command("haproxy", "-v").Output()
+	if err != nil {
+		return ""
+	}
+	match := haproxyVersionRegex.FindSubmatch(out)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
+// HAProxyFeatures returns the features enabled on the build of the haproxy
+// binary found in the PATH, e.g. QUIC, or nil if they cannot be read.
+func HAProxyFeatures() []string {
+	out, err := exec.Command("haproxy", "-vv").Output()
+	if err != nil {
+		return nil
+	}
+	return parseFeatures(string(out))
+}
+
+// parseFeatures reads the enabled features from the `Feature list` of the
+// `haproxy -vv` output. Some haproxy versions break the list in several
+// lines, the continuation lines are indented.
+func parseFeatures(out string) []string {
+	var list []string
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		if !found {
+			if after, ok := strings.CutPrefix(line, "Feature list :"); ok {
+				found = true
+				list = append(list, strings.Fields(after)...)
+			}
+		} else if trimmed := strings.TrimSpace(line); trimmed != line && (strings.HasPrefix(trimmed, "+") || strings.HasPrefix(trimmed, "-")) {
+			list = append(list, strings.Fields(trimmed)...)
+		} else {
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	features := []string{}
+	for _, feature := range list {
+		if name, ok := strings.CutPrefix(feature, "+"); ok {
+			features = append(features, name)
+		}
+	}
+	return features
+}
+
+var versionRegex = regexp.MustCompile(`^([0-9]+)\.([0-9]+)`)
+
+// VersionAtLeast returns true if version, in the major.minor[.patch] format,
+// is equal to or greater than major.minor. An empty or unknown version is
+// considered as the most recent one.
+func VersionAtLeast(version string, major, minor int) bool {
+	match := versionRegex.FindStringSubmatch(version)
+	if match == nil {
+		return true
+	}
+	vmajor, _ := strconv.Atoi(match[1])
+	vminor, _ := strconv.Atoi(match[2])
+	return vmajor > major || (vmajor == major && vminor >= minor)
+}
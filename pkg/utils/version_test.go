@@ -0,0 +1,116 @@
+/*
+Copyright 2026 The HAProxy Ingress Controller Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	testCases := []struct {
+		version  string
+		major    int
+		minor    int
+		expected bool
+	}{
+		// 0
+		{
+			version:  "",
+			major:    2,
+			minor:    4,
+			expected: true,
+		},
+		// 1
+		{
+			version:  "2.2.30",
+			major:    2,
+			minor:    4,
+			expected: false,
+		},
+		// 2
+		{
+			version:  "2.4",
+			major:    2,
+			minor:    4,
+			expected: true,
+		},
+		// 3
+		{
+			version:  "2.6.17",
+			major:    2,
+			minor:    4,
+			expected: true,
+		},
+		// 4
+		{
+			version:  "3.0.1",
+			major:    2,
+			minor:    8,
+			expected: true,
+		},
+		// 5
+		{
+			version:  "1.9.16",
+			major:    2,
+			minor:    0,
+			expected: false,
+		},
+	}
+	for i, test := range testCases {
+		if actual := VersionAtLeast(test.version, test.major, test.minor); actual != test.expected {
+			t.Errorf("version %s on %d, expected %v but was %v", test.version, i, test.expected, actual)
+		}
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	testCases := []struct {
+		out      string
+		expected []string
+	}{
+		// 0
+		{
+			out:      "",
+			expected: nil,
+		},
+		// 1
+		{
+			out: `HAProxy version 2.8.5 2023/12/07
+Build options :
+  TARGET  = linux-musl
+Feature list : -51DEGREES +ACCEPT4 -KQUEUE +OPENSSL +QUIC -WURFL
+Default settings :`,
+			expected: []string{"ACCEPT4", "OPENSSL", "QUIC"},
+		},
+		// 2
+		{
+			out: `HAProxy version 3.0.2 2024/06/14
+Feature list : -51DEGREES +ACCEPT4 -KQUEUE
+  +OPENSSL -QUIC +THREAD
+
+Default settings :`,
+			expected: []string{"ACCEPT4", "OPENSSL", "THREAD"},
+		},
+	}
+	for i, test := range testCases {
+		actual := parseFeatures(test.out)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("features on %d differ, expected %v but was %v", i, test.expected, actual)
+		}
+	}
+}
@@ -54,6 +54,9 @@ function globToLuaPattern(glob)
 	--    counterparts;
 	-- 3. adding start and finish boundaries outside the whole string and,
 	--    being a comma-separated list, between every single item as well.
+	--
+	-- header names are compared in lower case.
+	glob = glob:lower()
 	return "^" .. glob:gsub("[%^%$%(%)%%%.%[%]%+%-]", "%%%1"):gsub("*", ".*"):gsub("?", "."):gsub(",", "$,^") .. "$"
 end
 
@@ -113,6 +116,47 @@ function send_response(txn, response, hdr_fail)
 	txn:done(reply)
 end
 
+-- proxy_header_value builds the value of a header that describes the
+-- client request. The X-Forwarded-For sent by the client is only used if
+-- haproxy is configured to trust it, otherwise the client IP is the
+-- source of the connection.
+function proxy_header_value(txn, header)
+	local proto = txn.f:req_fhdr("x-forwarded-proto")
+	if proto == nil then
+		proto = txn.f:ssl_fc() and "https" or "http"
+	end
+	local xff = nil
+	if txn:get_var("txn.auth_proxy_trusted") then
+		local values = txn.http:req_get_headers()["x-forwarded-for"]
+		if values ~= nil then
+			xff = {}
+			for i = 0, #values do
+				table.insert(xff, values[i])
+			end
+		end
+	end
+	if header == "x-forwarded-for" then
+		if xff == nil then
+			return txn.f:src()
+		end
+		return table.concat(xff, ", ") .. ", " .. txn.f:src()
+	elseif header == "x-real-ip" then
+		if xff == nil then
+			return txn.f:src()
+		end
+		return xff[1]:match("^%s*([^,%s]+)")
+	elseif header == "x-forwarded-proto" then
+		return proto
+	elseif header == "x-forwarded-host" then
+		return txn.f:req_fhdr("host")
+	elseif header == "x-forwarded-uri" then
+		return txn.f:pathq()
+	elseif header == "x-original-url" then
+		return proto .. "://" .. (txn.f:req_fhdr("host") or "") .. txn.f:pathq()
+	end
+	return nil
+end
+
 -- auth_request makes the request to the external authentication service
 -- and waits for the response. hdr_* params receive a comma-separated
 -- list of Lua Patterns used to identify the headers that should be
@@ -162,6 +206,18 @@ function auth_request(txn, be, path, method, hdr_req, hdr_succeed, hdr_fail)
 		end
 	end
 
+	-- Add the headers built by haproxy, see `auth-proxy-headers`. They
+	-- override the ones sent by the client.
+	local proxy_headers = txn:get_var("txn.auth_proxy_headers")
+	if proxy_headers ~= nil then
+		for header in proxy_headers:gmatch("[^,]+") do
+			local value = proxy_header_value(txn, header:lower())
+			if value ~= nil then
+				headers[header:lower()] = value
+			end
+		end
+	end
+
 	-- Make request to backend.
 	if method == "*" then
 		method = txn.sf:method()
@@ -189,6 +245,17 @@ function auth_request(txn, be, path, method, hdr_req, hdr_succeed, hdr_fail)
 	set_var(txn, "txn.auth_response_code", response.status_code)
 	local response_ok = 200 <= response.status_code and response.status_code < 300
 
+	-- Headers copied from the auth response replace the ones sent by the client,
+	-- so they are removed even if missing in the response, which avoids spoofing.
+	-- Not possible if all the headers are copied.
+	if response_ok and hdr_succeed ~= "-" and hdr_succeed ~= "^.*$" then
+		for header, _ in pairs(txn.http:req_get_headers()) do
+			if header_match(header, hdr_succeed) then
+				txn.http:req_del_header(header)
+			end
+		end
+	end
+
 	for header, value in response:get_headers(true) do
 		set_var(txn, "req.auth_response_header." .. sanitize_header_for_variable(header), value)
 		if response_ok and hdr_succeed ~= "-" and header_match(header, hdr_succeed) then
@@ -0,0 +1,96 @@
+-- Copyright 2026 The HAProxy Ingress Controller Authors.
+--
+-- Licensed under the Apache License, Version 2.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--     http://www.apache.org/licenses/LICENSE-2.0
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- jwt-auth validates the claims of the bearer token of the request. The
+-- signature is verified by the backend using haproxy's jwt_verify converter,
+-- which needs the signing algorithm and the key ID that this action reads
+-- from the token header. An issuer or audience of "-" is not checked.
+--
+-- On success, txn.jwt_alg and txn.jwt_kid, if declared, are assigned.
+-- On failure, txn.jwt_error is assigned with "missing_token" if the request
+-- doesn't have a bearer token, or "invalid_token" otherwise.
+
+local json = require "json"
+
+local function decode_json(s)
+	if not s or s == "" then
+		return nil
+	end
+	local ok, obj = pcall(json.decode, s)
+	if not ok then
+		return nil
+	end
+	return obj
+end
+
+local function decode_jwt(txn, token)
+	local h, p = token:match("^([%w_-]+)%.([%w_-]+)%.[%w_-]+$")
+	if not h then
+		return nil
+	end
+	local header = decode_json(txn.c:ub64dec(h))
+	local payload = decode_json(txn.c:ub64dec(p))
+	if type(header) ~= "table" or type(payload) ~= "table" or type(header.alg) ~= "string" then
+		return nil
+	end
+	return header, payload
+end
+
+local function validate_claims(payload, issuer, audience)
+	local now = core.now().sec
+	-- exp is mandatory, tokens without expiration would be valid forever
+	if type(payload.exp) ~= "number" or payload.exp <= now then
+		return false
+	end
+	if payload.nbf ~= nil and (type(payload.nbf) ~= "number" or payload.nbf > now) then
+		return false
+	end
+	if issuer ~= "-" and payload.iss ~= issuer then
+		return false
+	end
+	if audience ~= "-" then
+		if type(payload.aud) == "table" then
+			for _, aud in ipairs(payload.aud) do
+				if aud == audience then
+					return true
+				end
+			end
+			return false
+		end
+		return payload.aud == audience
+	end
+	return true
+end
+
+core.register_action("jwt-auth", { "http-req" }, function(txn, issuer, audience)
+	txn:unset_var("txn.jwt_alg")
+	txn:unset_var("txn.jwt_kid")
+	txn:unset_var("txn.jwt_verified")
+	local hdr = txn.http:req_get_headers()["authorization"]
+	local token = hdr and hdr[0] and hdr[0]:match("^[Bb][Ee][Aa][Rr][Ee][Rr]%s+(%S+)%s*$")
+	if not token then
+		txn:set_var("txn.jwt_error", "missing_token")
+		return
+	end
+	local header, payload = decode_jwt(txn, token)
+	if not header or not payload or not validate_claims(payload, issuer, audience) then
+		txn:set_var("txn.jwt_error", "invalid_token")
+		return
+	end
+	txn:unset_var("txn.jwt_error")
+	txn:set_var("txn.jwt_alg", header.alg)
+	if type(header.kid) == "string" then
+		txn:set_var("txn.jwt_kid", header.kid)
+	end
+end, 2)